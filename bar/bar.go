@@ -15,6 +15,7 @@
 // Package bar allows a user to create a go binary that follows the i3bar protocol.
 package bar // import "barista.run/bar"
 
+import "image"
 import "image/color"
 import "time"
 
@@ -51,15 +52,18 @@ type Segment struct {
 	// i3bar. (e.g. the default separatorWidth is not 0).
 	attrSet int
 	onClick func(Event)
+	detail  func() string
 
 	text      string
 	pango     bool
 	shortText string
 	err       error
+	image     image.Image
 
-	color      color.Color
-	background color.Color
-	border     color.Color
+	color        color.Color
+	background   color.Color
+	border       color.Color
+	borderWidths [4]int
 
 	// Minimum width can be specified as either a numeric pixel value
 	// or a string placeholder value. The unexported field is interface{}
@@ -70,6 +74,10 @@ type Segment struct {
 	urgent    bool
 	separator bool
 	padding   int
+
+	maxLength   int
+	shortLength int
+	truncation  TruncationMode
 }
 
 // sa* (Segment Attribute) consts are used as bitwise flags in attrSet
@@ -79,6 +87,10 @@ const (
 	saUrgent
 	saSeparator
 	saPadding
+	saBorderWidth
+	saMaxLength
+	saShortLength
+	saTruncation
 )
 
 // Output is an interface for displaying objects on the bar.
@@ -136,15 +148,55 @@ X, Y describe event co-ordinates relative to the output segment, and
 Width, Height are set to the size of the output segment.
 
 ScreenX, ScreenY are the event co-ordinates relative to the root window.
+
+Release is set for button release events, which are only sent by bars that
+support them. i3bar and swaybar only send button presses.
+
+Modifiers are the keyboard modifiers held during the event, if reported by
+the bar.
 */
 type Event struct {
-	Button  Button `json:"button"`
-	X       int    `json:"relative_x,omitempty"`
-	Y       int    `json:"relative_y,omitempty"`
-	Width   int    `json:"width,omitempty"`
-	Height  int    `json:"height,omitempty"`
-	ScreenX int    `json:"x,omitempty"`
-	ScreenY int    `json:"y,omitempty"`
+	Button    Button    `json:"button"`
+	X         int       `json:"relative_x,omitempty"`
+	Y         int       `json:"relative_y,omitempty"`
+	Width     int       `json:"width,omitempty"`
+	Height    int       `json:"height,omitempty"`
+	ScreenX   int       `json:"x,omitempty"`
+	ScreenY   int       `json:"y,omitempty"`
+	Release   bool      `json:"release,omitempty"`
+	Modifiers Modifiers `json:"modifiers,omitempty"`
+}
+
+// GestureKind identifies the type of a Gesture.
+type GestureKind int
+
+const (
+	// GestureClick is a single click of a button.
+	GestureClick GestureKind = iota
+	// GestureDoubleClick is two clicks of the same button in quick succession.
+	GestureDoubleClick
+	// GestureLongPress is a button held down without moving the pointer.
+	// It requires a bar that sends button release events.
+	GestureLongPress
+	// GestureDrag is a button held down while moving the pointer. It requires
+	// a bar that sends button release events.
+	GestureDrag
+	// GestureScroll is one or more scroll events in the same direction.
+	GestureScroll
+)
+
+/*
+Gesture represents a higher level mouse gesture, synthesized from one or more
+Events using click.Gestures.
+
+The embedded Event is the last event of the gesture, except for a drag, where
+it is the initial button press and DeltaX is the horizontal distance the
+pointer moved, in pixels.
+*/
+type Gesture struct {
+	Kind GestureKind
+	Event
+	DeltaX int
 }
 
 /*
@@ -162,6 +214,20 @@ type ErrorEvent struct {
 	Event
 }
 
+/*
+DetailEvent represents a mouse event that requested the extended information
+of a segment. This is fired when a segment with a detail function is clicked
+using the button configured for details, and the detail function is only
+called at that time, so it can be more expensive than producing the output.
+
+As with ErrorEvent, the Event that triggered the detail handler is embedded,
+so handlers can position their display relative to the clicked segment.
+*/
+type DetailEvent struct {
+	Detail string
+	Event
+}
+
 // Sink represents a destination for module output.
 type Sink func(Output)
 
@@ -183,3 +249,15 @@ type RefresherModule interface {
 	Module
 	Refresh()
 }
+
+// PausableModule extends module with methods to stop and resume updates while
+// its output is not visible (e.g. in a collapsed group), to save resources.
+// Resume should update any output that became stale while paused. Schedulers
+// owned by a module (see timing.Scheduler.OwnedBy) are paused along with it
+// regardless, so this is only needed for other sources of work, such as
+// external processes.
+type PausableModule interface {
+	Module
+	Pause()
+	Resume()
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bar
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Modifiers is a set of keyboard modifiers held during an event.
+type Modifiers int
+
+// Keyboard modifiers, as reported by i3bar and swaybar.
+const (
+	ModShift Modifiers = 1 << iota
+	ModLock
+	ModControl
+	// ModMod1 is usually Alt.
+	ModMod1
+	ModMod2
+	ModMod3
+	// ModMod4 is usually Super (the "Windows" key).
+	ModMod4
+	ModMod5
+)
+
+var modifierNames = map[string]Modifiers{
+	"Shift": ModShift, "Lock": ModLock, "Control": ModControl,
+	"Mod1": ModMod1, "Mod2": ModMod2, "Mod3": ModMod3,
+	"Mod4": ModMod4, "Mod5": ModMod5,
+}
+
+// Has returns true if all of the given modifiers are set.
+func (m Modifiers) Has(mods Modifiers) bool {
+	return m&mods == mods
+}
+
+// UnmarshalJSON parses the list of modifier names sent by the bar. Unknown
+// modifiers are ignored.
+func (m *Modifiers) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	*m = 0
+	for _, n := range names {
+		*m |= modifierNames[n]
+	}
+	return nil
+}
+
+// MarshalJSON encodes the modifiers as a list of modifier names.
+func (m Modifiers) MarshalJSON() ([]byte, error) {
+	names := []string{}
+	for n, mod := range modifierNames {
+		if m.Has(mod) {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return json.Marshal(names)
+}
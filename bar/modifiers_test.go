@@ -0,0 +1,43 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bar
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventModifiers(t *testing.T) {
+	var e Event
+	require.NoError(t, json.Unmarshal(
+		[]byte(`{"button": 1, "modifiers": ["Shift", "Mod4", "Unknown"]}`), &e))
+	require.Equal(t, ButtonLeft, e.Button)
+	require.True(t, e.Modifiers.Has(ModShift))
+	require.True(t, e.Modifiers.Has(ModMod4|ModShift))
+	require.False(t, e.Modifiers.Has(ModControl))
+	require.False(t, e.Modifiers.Has(ModControl|ModShift))
+
+	data, err := json.Marshal(ModMod4 | ModShift | ModControl)
+	require.NoError(t, err)
+	require.Equal(t, `["Control","Mod4","Shift"]`, string(data))
+
+	e = Event{}
+	require.NoError(t, json.Unmarshal([]byte(`{"button": 3}`), &e))
+	require.Equal(t, Modifiers(0), e.Modifiers, "no modifiers")
+
+	require.Error(t, json.Unmarshal([]byte(`{"modifiers": 4}`), &e))
+}
@@ -14,7 +14,10 @@
 
 package bar
 
-import "image/color"
+import (
+	"image"
+	"image/color"
+)
 
 // TextSegment creates a new output segment with text content.
 func TextSegment(text string) *Segment {
@@ -58,6 +61,21 @@ func (s *Segment) Content() (text string, isPango bool) {
 	return s.text, s.pango
 }
 
+// Image sets a small image to show before the content of this segment, e.g.
+// album art or a weather icon. Images are scaled to the height of the bar,
+// and must not be modified after being added to a segment. Bars that cannot
+// show images get an approximation using coloured block characters.
+func (s *Segment) Image(img image.Image) *Segment {
+	s.image = img
+	return s
+}
+
+// GetImage returns the image shown before the content of this segment.
+// The second value indicates whether it was explicitly set.
+func (s *Segment) GetImage() (image.Image, bool) {
+	return s.image, s.image != nil
+}
+
 // ShortText sets the shortened text, used if the default text
 // for all segments does not fit in the bar.
 func (s *Segment) ShortText(shortText string) *Segment {
@@ -72,6 +90,69 @@ func (s *Segment) GetShortText() (string, bool) {
 	return s.shortText, s.attrSet&saShortText != 0
 }
 
+// MaxLength truncates the text of the segment to at most maxLength
+// characters when it is displayed, replacing the removed text with an
+// ellipsis.
+func (s *Segment) MaxLength(maxLength int) *Segment {
+	s.maxLength = maxLength
+	s.attrSet |= saMaxLength
+	return s
+}
+
+// GetMaxLength returns the maximum length of the text of this segment.
+// The second value indicates whether it was explicitly set.
+func (s *Segment) GetMaxLength() (int, bool) {
+	return s.maxLength, s.attrSet&saMaxLength != 0
+}
+
+// ShortLength generates short text for the segment by truncating its text
+// to at most shortLength characters, unless short text is explicitly set.
+// i3bar and swaybar use the short text of all segments when the full text
+// does not fit on the bar, e.g. to keep long song titles from pushing the
+// tray off-screen.
+func (s *Segment) ShortLength(shortLength int) *Segment {
+	s.shortLength = shortLength
+	s.attrSet |= saShortLength
+	return s
+}
+
+// GetShortLength returns the length of generated short text for this
+// segment. The second value indicates whether it was explicitly set.
+func (s *Segment) GetShortLength() (int, bool) {
+	return s.shortLength, s.attrSet&saShortLength != 0
+}
+
+// Truncation sets which part of the text is removed when truncating it
+// for MaxLength or ShortLength. Defaults to TruncateEnd.
+func (s *Segment) Truncation(mode TruncationMode) *Segment {
+	s.truncation = mode
+	s.attrSet |= saTruncation
+	return s
+}
+
+// GetTruncation returns which part of the text is removed when truncating.
+// The second value indicates whether it was explicitly set.
+func (s *Segment) GetTruncation() (TruncationMode, bool) {
+	return s.truncation, s.attrSet&saTruncation != 0
+}
+
+// DisplayText returns the text content of the segment and its short text,
+// after applying MaxLength and ShortLength. The last value indicates whether
+// the segment has short text.
+func (s *Segment) DisplayText() (text, shortText string, hasShortText bool) {
+	text = s.text
+	if s.attrSet&saMaxLength != 0 {
+		text = Truncate(s.text, s.pango, s.maxLength, s.truncation)
+	}
+	if s.attrSet&saShortText != 0 {
+		return text, s.shortText, true
+	}
+	if s.attrSet&saShortLength != 0 {
+		return text, Truncate(s.text, s.pango, s.shortLength, s.truncation), true
+	}
+	return text, "", false
+}
+
 // Error associates an error with the segment. Setting an error
 // changes event handling to display the full error text on left
 // click, and restart the module on right/middle click.
@@ -123,6 +204,24 @@ func (s *Segment) GetBorder() (color.Color, bool) {
 	return s.border, s.border != nil
 }
 
+// BorderWidth sets the width of the border on each side of the segment, in
+// pixels. i3bar defaults to a 1px border on each side.
+func (s *Segment) BorderWidth(top, right, bottom, left int) *Segment {
+	s.borderWidths = [4]int{top, right, bottom, left}
+	s.attrSet |= saBorderWidth
+	return s
+}
+
+// GetBorderWidth returns the width of the border on each side of this
+// segment. The last value indicates whether it was explicitly set.
+func (s *Segment) GetBorderWidth() (top, right, bottom, left int, isSet bool) {
+	if s.attrSet&saBorderWidth != 0 {
+		w := s.borderWidths
+		return w[0], w[1], w[2], w[3], true
+	}
+	return 1, 1, 1, 1, false
+}
+
 // MinWidth sets the minimum width for the segment.
 func (s *Segment) MinWidth(minWidth int) *Segment {
 	s.minWidth = minWidth
@@ -232,6 +331,61 @@ func (s *Segment) Click(e Event) {
 	}
 }
 
+// Detail sets a function that provides extended information about the
+// segment, e.g. a full list of items where the segment shows a count. The
+// function is only called when the bar's detail handler is triggered, which
+// emulates a tooltip since i3bar does not support hovering.
+func (s *Segment) Detail(fn func() string) *Segment {
+	s.detail = fn
+	return s
+}
+
+// GetDetail returns the function that provides extended information about
+// the segment, if set.
+func (s *Segment) GetDetail() (func() string, bool) {
+	return s.detail, s.detail != nil
+}
+
+// ApplyDefaults sets any attributes of this segment that are not already set
+// to their values in the given defaults. The content, urgency, error, image,
+// detail, and click handler of the segment are never changed.
+func (s *Segment) ApplyDefaults(defaults *Segment) *Segment {
+	if s.color == nil {
+		s.color = defaults.color
+	}
+	if s.background == nil {
+		s.background = defaults.background
+	}
+	if s.border == nil {
+		s.border = defaults.border
+	}
+	if s.minWidth == nil {
+		s.minWidth = defaults.minWidth
+	}
+	if s.align == "" {
+		s.align = defaults.align
+	}
+	if sep, ok := defaults.HasSeparator(); ok && s.attrSet&saSeparator == 0 {
+		s.Separator(sep)
+	}
+	if padding, ok := defaults.GetPadding(); ok && s.attrSet&saPadding == 0 {
+		s.Padding(padding)
+	}
+	if t, r, b, l, ok := defaults.GetBorderWidth(); ok && s.attrSet&saBorderWidth == 0 {
+		s.BorderWidth(t, r, b, l)
+	}
+	if maxLength, ok := defaults.GetMaxLength(); ok && s.attrSet&saMaxLength == 0 {
+		s.MaxLength(maxLength)
+	}
+	if shortLength, ok := defaults.GetShortLength(); ok && s.attrSet&saShortLength == 0 {
+		s.ShortLength(shortLength)
+	}
+	if mode, ok := defaults.GetTruncation(); ok && s.attrSet&saTruncation == 0 {
+		s.Truncation(mode)
+	}
+	return s
+}
+
 // Segments implements bar.Output for a single Segment.
 func (s *Segment) Segments() []*Segment {
 	return []*Segment{s}
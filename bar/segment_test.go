@@ -17,6 +17,7 @@ package bar
 import (
 	"errors"
 	"fmt"
+	"image"
 	"image/color"
 	"testing"
 
@@ -59,6 +60,7 @@ func TestSegment(t *testing.T) {
 	assertUnset(segment.GetBackground())
 	assertUnset(segment.GetBorder())
 	assertUnset(segment.GetMinWidth())
+	assertUnset(segment.GetImage())
 	require.False(segment.HasClick())
 
 	defaultUrgent := assertUnset(segment.IsUrgent())
@@ -98,6 +100,20 @@ func TestSegment(t *testing.T) {
 	assertColorEqual(t, color.RGBA{0, 0, 0, 0},
 		assertSet(segment.GetBorder()).(color.Color))
 
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	segment.Image(img)
+	require.Equal(img, assertSet(segment.GetImage()))
+	segment.Image(nil)
+	assertUnset(segment.GetImage())
+
+	top, right, bottom, left, isSet := segment.GetBorderWidth()
+	require.False(isSet)
+	require.Equal([]int{1, 1, 1, 1}, []int{top, right, bottom, left})
+	segment.BorderWidth(0, 2, 3, 0)
+	top, right, bottom, left, isSet = segment.GetBorderWidth()
+	require.True(isSet)
+	require.Equal([]int{0, 2, 3, 0}, []int{top, right, bottom, left})
+
 	segment.Urgent(true)
 	require.True(assertSet(segment.IsUrgent()).(bool))
 
@@ -133,6 +149,16 @@ func TestSegment(t *testing.T) {
 	require.NotNil(clickedEvent)
 	require.Equal(Event{Button: ButtonLeft}, *clickedEvent)
 
+	_, isSet = segment.GetDetail()
+	require.False(isSet)
+	calls := 0
+	segment.Detail(func() string { calls++; return "details" })
+	detail, isSet := segment.GetDetail()
+	require.True(isSet)
+	require.Equal(0, calls, "detail is not computed until requested")
+	require.Equal("details", detail())
+	require.Equal(1, calls)
+
 	segment = ErrorSegment(fmt.Errorf("something went wrong"))
 	txt, pango = segment.Content()
 	require.Equal("Error", txt)
@@ -160,6 +186,72 @@ func TestBarOutput(t *testing.T) {
 	require.Equal(t, segment1, barOut[1])
 }
 
+func TestApplyDefaults(t *testing.T) {
+	require := require.New(t)
+	defaults := TextSegment("defaults").
+		ShortText("d").
+		Color(color.Gray{0x11}).
+		Background(color.Gray{0x22}).
+		Border(color.Gray{0x33}).
+		BorderWidth(0, 0, 2, 0).
+		MinWidth(40).
+		Align(AlignEnd).
+		Urgent(true).
+		Separator(false).
+		Padding(0).
+		MaxLength(20).
+		ShortLength(5).
+		Truncation(TruncateMiddle).
+		OnClick(func(Event) {}).
+		Detail(func() string { return "defaults" })
+
+	s := TextSegment("empty").ApplyDefaults(defaults)
+	txt, _ := s.Content()
+	require.Equal("empty", txt, "content unchanged")
+	_, isSet := s.GetShortText()
+	require.False(isSet, "short text unchanged")
+	_, isSet = s.IsUrgent()
+	require.False(isSet, "urgency unchanged")
+	require.False(s.HasClick(), "click handler unchanged")
+	_, isSet = s.GetDetail()
+	require.False(isSet, "detail unchanged")
+	assertColorEqual(t, color.Gray{0x11}, s.color)
+	assertColorEqual(t, color.Gray{0x22}, s.background)
+	assertColorEqual(t, color.Gray{0x33}, s.border)
+	_, _, bottom, _, isSet := s.GetBorderWidth()
+	require.True(isSet)
+	require.Equal(2, bottom)
+	require.Equal(40, s.minWidth)
+	require.Equal(AlignEnd, s.align)
+	require.False(s.HasSeparator())
+	require.Equal(0, s.padding)
+	require.Equal(20, s.maxLength)
+	require.Equal(5, s.shortLength)
+	require.Equal(TruncateMiddle, s.truncation)
+
+	s = TextSegment("set").
+		Color(color.White).
+		BorderWidth(1, 1, 1, 1).
+		MinWidthPlaceholder("00").
+		Align(AlignStart).
+		Separator(true).
+		Padding(5).
+		ShortLength(3).
+		ApplyDefaults(defaults)
+	assertColorEqual(t, color.White, s.color, "set attributes override defaults")
+	assertColorEqual(t, color.Gray{0x22}, s.background)
+	_, _, bottom, _, _ = s.GetBorderWidth()
+	require.Equal(1, bottom)
+	require.Equal("00", s.minWidth)
+	require.Equal(AlignStart, s.align)
+	require.True(s.HasSeparator())
+	require.Equal(5, s.padding)
+	require.Equal(3, s.shortLength)
+
+	s = TextSegment("none").ApplyDefaults(new(Segment))
+	require.Equal(TextSegment("none"), s, "no changes from empty defaults")
+}
+
 func TestClone(t *testing.T) {
 	require := require.New(t)
 	a := TextSegment("10 deg C").
@@ -0,0 +1,110 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bar
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TruncationMode controls which part of the text is removed when truncating
+// text that is too long.
+type TruncationMode int
+
+const (
+	// TruncateEnd keeps the start of the text, e.g. "A very lon…".
+	TruncateEnd TruncationMode = iota
+	// TruncateMiddle keeps the start and end of the text, e.g. "A ver…itle".
+	TruncateMiddle
+	// TruncateStart keeps the end of the text, e.g. "…long title".
+	TruncateStart
+)
+
+// ellipsis replaces the removed text when truncating.
+const ellipsis = "…"
+
+// Truncate shortens text to at most length characters, including an ellipsis
+// that replaces the removed text. For pango markup, only the visible text is
+// counted and truncated, and tags are kept intact.
+func Truncate(text string, isPango bool, length int, mode TruncationMode) string {
+	tokens := tokenize(text, isPango)
+	visible := 0
+	for _, t := range tokens {
+		if !t.tag {
+			visible++
+		}
+	}
+	if visible <= length {
+		return text
+	}
+	if length < 1 {
+		length = 1
+	}
+	// Keep the first keepStart and last keepEnd visible characters, with the
+	// ellipsis taking up the remaining character.
+	keepStart, keepEnd := length-1, 0
+	switch mode {
+	case TruncateMiddle:
+		keepStart = length / 2
+		keepEnd = length - 1 - keepStart
+	case TruncateStart:
+		keepStart, keepEnd = 0, length-1
+	}
+	var out strings.Builder
+	idx := 0
+	for _, t := range tokens {
+		if t.tag {
+			out.WriteString(t.text)
+			continue
+		}
+		switch {
+		case idx < keepStart || idx >= visible-keepEnd:
+			out.WriteString(t.text)
+		case idx == keepStart:
+			out.WriteString(ellipsis)
+		}
+		idx++
+	}
+	return out.String()
+}
+
+type token struct {
+	text string
+	tag  bool
+}
+
+// tokenize splits text into visible characters and, for pango markup, tags.
+// Entities such as &amp; are treated as a single visible character.
+func tokenize(text string, isPango bool) []token {
+	var tokens []token
+	for len(text) > 0 {
+		size := 0
+		tag := false
+		switch {
+		case isPango && text[0] == '<':
+			size = strings.IndexByte(text, '>') + 1
+			tag = true
+		case isPango && text[0] == '&':
+			size = strings.IndexByte(text, ';') + 1
+		}
+		if size <= 0 {
+			_, size = utf8.DecodeRuneInString(text)
+			tag = false
+		}
+		tokens = append(tokens, token{text[:size], tag})
+		text = text[size:]
+	}
+	return tokens
+}
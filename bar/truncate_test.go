@@ -0,0 +1,85 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncate(t *testing.T) {
+	for _, tc := range []struct {
+		text     string
+		isPango  bool
+		length   int
+		mode     TruncationMode
+		expected string
+	}{
+		{"short", false, 10, TruncateEnd, "short"},
+		{"exactly10!", false, 10, TruncateEnd, "exactly10!"},
+		{"A very long title", false, 10, TruncateEnd, "A very lo…"},
+		{"A very long title", false, 10, TruncateMiddle, "A ver…itle"},
+		{"A very long title", false, 10, TruncateStart, "…ong title"},
+		{"A very long title", false, 1, TruncateEnd, "…"},
+		{"A very long title", false, 0, TruncateMiddle, "…"},
+		{"日本語のテキスト", false, 4, TruncateEnd, "日本語…"},
+		{"<b>bold</b> &amp; <i>italic</i>", true, 6, TruncateEnd,
+			"<b>bold</b> …<i></i>"},
+		{"<b>bold</b> &amp; <i>italic</i>", true, 6, TruncateStart,
+			"<b>…</b><i>talic</i>"},
+		{"<b>bold</b> &amp; <i>italic</i>", true, 8, TruncateMiddle,
+			"<b>bold</b>…<i>lic</i>"},
+		{"<b>bold</b> &amp; <i>italic</i>", true, 13, TruncateEnd,
+			"<b>bold</b> &amp; <i>italic</i>"},
+		{"<b>a &lt; b</b>", false, 6, TruncateEnd, "<b>a …"},
+	} {
+		require.Equal(t, tc.expected,
+			Truncate(tc.text, tc.isPango, tc.length, tc.mode),
+			"%q (pango: %v) to %d", tc.text, tc.isPango, tc.length)
+	}
+}
+
+func TestDisplayText(t *testing.T) {
+	require := require.New(t)
+	s := TextSegment("A very long title")
+	txt, _, hasShort := s.DisplayText()
+	require.Equal("A very long title", txt)
+	require.False(hasShort)
+
+	s.MaxLength(12)
+	txt, _, hasShort = s.DisplayText()
+	require.Equal("A very long…", txt)
+	require.False(hasShort)
+
+	s.ShortLength(6)
+	txt, short, hasShort := s.DisplayText()
+	require.Equal("A very long…", txt)
+	require.Equal("A ver…", short, "short text from original text")
+	require.True(hasShort)
+
+	s.Truncation(TruncateStart)
+	txt, short, _ = s.DisplayText()
+	require.Equal("… long title", txt)
+	require.Equal("…title", short)
+
+	s.ShortText("title")
+	_, short, hasShort = s.DisplayText()
+	require.Equal("title", short, "explicit short text preferred")
+	require.True(hasShort)
+
+	txt, _ = s.Content()
+	require.Equal("A very long title", txt, "content unchanged")
+}
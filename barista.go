@@ -15,20 +15,30 @@
 package barista // import "barista.run"
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"html"
+	"image"
 	"image/color"
+	"image/png"
 	"io"
 	"os"
 	"os/exec"
 	"os/signal"
 	"strconv"
 	"sync"
+	"time"
 
 	"barista.run/bar"
+	"barista.run/base/images"
+	"barista.run/colors"
 	"barista.run/core"
 	l "barista.run/logging"
 	"barista.run/oauth"
+	"barista.run/pango"
 	"barista.run/timing"
 
 	"github.com/lucasb-eyer/go-colorful"
@@ -39,6 +49,8 @@ import (
 type i3Event struct {
 	bar.Event
 	Name string `json:"name"`
+	// The view that the event was sent to, or nil for the main bar.
+	view *view
 }
 
 // i3Header is sent at the beginning of output.
@@ -55,10 +67,20 @@ type i3Bar struct {
 	// The list of modules that make up this bar.
 	modules   []bar.Module
 	moduleSet *core.ModuleSet
-	// A map of previously set click handlers for each segment.
-	clickHandlers map[string]func(bar.Event)
+	// The number of modules shown on the main bar. Modules after these are
+	// only shown on other monitors.
+	mainModules int
+	// The rendering state of the main bar.
+	view
+	// Additional bars for other monitors, and the connected i3bar instances.
+	monitors     []*Monitor
+	monitorConns []*monitorConn
 	// The function to call when an error segment is right-clicked.
 	errorHandler func(bar.ErrorEvent)
+	// The function to call when a segment with a detail function is
+	// clicked using detailButton, or nil if details are disabled.
+	detailHandler func(bar.DetailEvent)
+	detailButton  bar.Button
 	// The channel that receives a signal on module updates.
 	update chan struct{}
 	// The channel that aggregates all events from i3.
@@ -67,18 +89,47 @@ type i3Bar struct {
 	reader io.Reader
 	// The Writer to write bar output to (e.g. stdout)
 	writer io.Writer
-	// A json encoder set to write to the output stream.
-	encoder *json.Encoder
 	// Flipped when Run() is called, to prevent issues with modules
 	// being added after the bar has been started.
 	started bool
 	// Suppress pause/resume signal handling to workaround potential
 	// weirdness with signals.
 	suppressSignals bool
+	// Suppress urgent flags on non-error segments, e.g. while
+	// do-not-disturb is enabled.
+	suppressUrgent bool
+	// Defaults for attributes not set on segments.
+	defaults *bar.Segment
+	// Transforms the segments of all modules before they are printed.
+	layout func([]*bar.Segment) []*bar.Segment
+	// Coalesce module updates within a time window into a single write,
+	// and skip writes that are identical to the last one.
+	coalesce       bool
+	coalesceWindow time.Duration
+	// Set when any setting that affects the output of segments changes,
+	// to clear the encoded segments of all views.
+	clearEncoded bool
+	// Modules that do not update within this duration of starting or being
+	// refreshed are marked stale, if positive.
+	watchdog time.Duration
+	// Number of times the bar was printed, and the total time spent.
+	renders    int
+	renderTime time.Duration
+	// Images in segments are sent to the bar scaled to imageHeight if set,
+	// otherwise they are rendered as pango markup. Rendered images are
+	// cached since the bar is redrawn on each update.
+	imageHeight int
+	images      *images.Cache
 	// Keeps track of whether the bar is currently paused, and
 	// whether it needs to be refreshed on resume.
 	paused          bool
 	refreshOnResume bool
+	// Names of modules and pagers that can be controlled using the control
+	// socket, and modules hidden using the control socket.
+	names         map[string]int
+	pagers        map[string]Pager
+	hidden        map[int]bool
+	controlSocket string
 	// For testing, output the associated error in the json as well.
 	// This allows output tester to accurately check for errors.
 	includeErrorsInOutput bool
@@ -116,6 +167,10 @@ func construct() {
 			paused: true,
 			// Default to i3-nagbar when right-clicking errors.
 			errorHandler: DefaultErrorHandler,
+			images:       images.NewCache(imageCacheSize, pangoImage),
+			names:        map[string]int{},
+			pagers:       map[string]Pager{},
+			hidden:       map[int]bool{},
 		}
 	})
 }
@@ -131,6 +186,58 @@ func Add(module bar.Module) {
 	instance.modules = append(instance.modules, module)
 }
 
+// AddNamed adds a module to the bar with a name, which can be used to refer
+// to the module in commands sent to the control socket.
+func AddNamed(name string, module bar.Module) {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot add modules after .Run()")
+	}
+	if _, ok := instance.names[name]; ok {
+		panic("Duplicate module name " + name)
+	}
+	l.Label(module, name)
+	instance.names[name] = len(instance.modules)
+	instance.modules = append(instance.modules, module)
+}
+
+// Refresh refreshes the module with the given name, as set by AddNamed.
+// It returns an error if the module does not support refreshing, or if the
+// bar is not running.
+func Refresh(name string) error {
+	construct()
+	instance.Lock()
+	idx, ok := instance.names[name]
+	moduleSet := instance.moduleSet
+	instance.Unlock()
+	switch {
+	case !ok:
+		return fmt.Errorf("unknown module %q", name)
+	case moduleSet == nil:
+		return errors.New("bar is not running")
+	case !moduleSet.Refresh(idx):
+		return fmt.Errorf("%s does not support refresh", name)
+	}
+	return nil
+}
+
+// RefreshAll refreshes all modules that support refreshing. It does nothing
+// if the bar is not running.
+func RefreshAll() {
+	construct()
+	instance.Lock()
+	moduleSet := instance.moduleSet
+	instance.Unlock()
+	if moduleSet == nil {
+		return
+	}
+	for idx := 0; idx < moduleSet.Len(); idx++ {
+		moduleSet.Refresh(idx)
+	}
+}
+
 // SuppressSignals instructs the bar to skip the pause/resume signal handling.
 // Must be called before Run.
 func SuppressSignals(suppressSignals bool) {
@@ -152,6 +259,151 @@ func SetErrorHandler(handler func(bar.ErrorEvent)) {
 	instance.errorHandler = handler
 }
 
+// SetDetailHandler sets the function to be called when a segment that
+// provides extended information using Segment.Detail is clicked with the
+// given button, emulating tooltips. The segment's own click handler is not
+// called for that button. By default details are not shown, since many
+// modules use all buttons for other actions. Can be called at any time.
+func SetDetailHandler(button bar.Button, handler func(bar.DetailEvent)) {
+	construct()
+	instance.Lock()
+	instance.detailButton = button
+	instance.detailHandler = handler
+	started := instance.started
+	instance.Unlock()
+	if started {
+		instance.refresh()
+	}
+}
+
+// DetailCommand returns a detail handler that runs the given command with the
+// detail text as the final argument, e.g. DetailCommand("notify-send", "Info")
+// or DetailCommand("rofi", "-e").
+func DetailCommand(name string, args ...string) func(bar.DetailEvent) {
+	return func(e bar.DetailEvent) {
+		// Copy the arguments, since appending could modify the caller's slice.
+		cmdArgs := append(append([]string(nil), args...), e.Detail)
+		if err := exec.Command(name, cmdArgs...).Run(); err != nil {
+			l.Log("Error showing detail using %s: %v", name, err)
+		}
+	}
+}
+
+// CoalesceUpdates configures the bar to combine module updates that happen
+// within the given window into a single update, and to skip updates that are
+// identical to the previous one, reducing redraws by i3bar when many modules
+// update at the same time. A window of 0 only skips identical updates.
+// Must be called before Run.
+func CoalesceUpdates(window time.Duration) {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot change update coalescing after .Run()")
+	}
+	instance.coalesce = true
+	instance.coalesceWindow = window
+}
+
+// Watchdog configures the bar to mark the output of modules that have not
+// updated within the given duration of starting or being refreshed as stale,
+// by using the "stale" colour from the scheme, or grey. This helps distinguish
+// modules that are stuck from modules that are showing old data because the
+// source did not change.
+// Must be called before Run.
+func Watchdog(deadline time.Duration) {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot change watchdog after .Run()")
+	}
+	instance.watchdog = deadline
+}
+
+// The number of rendered images to cache.
+const imageCacheSize = 32
+
+// SendImages configures the bar to send images in segments using the "_image"
+// extension to the i3bar protocol, as base64 encoded PNGs scaled to the given
+// height in pixels, for bars that can display images. By default, images are
+// approximated using pango markup, which works with i3bar and swaybar.
+// Must be called before Run.
+func SendImages(height int) {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot change image handling after .Run()")
+	}
+	instance.imageHeight = height
+	instance.images = images.NewCache(imageCacheSize, func(img image.Image) string {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, images.Scale(img, height)); err != nil {
+			l.Log("Failed to encode image: %v", err)
+			return ""
+		}
+		return base64.StdEncoding.EncodeToString(buf.Bytes())
+	})
+}
+
+func pangoImage(img image.Image) string {
+	return pango.Image(img).String()
+}
+
+// SuppressUrgent controls whether segments can be marked urgent on the bar,
+// e.g. to avoid distractions while do-not-disturb is enabled. Error segments
+// are always marked urgent. Can be called at any time.
+func SuppressUrgent(suppressUrgent bool) {
+	construct()
+	instance.Lock()
+	changed := instance.suppressUrgent != suppressUrgent
+	instance.suppressUrgent = suppressUrgent
+	instance.clearEncoded = true
+	started := instance.started
+	instance.Unlock()
+	if changed && started {
+		instance.refresh()
+	}
+}
+
+// SetDefaults sets the default attributes of all segments on the bar, such
+// as colours, borders, separators, padding, minimum width, and alignment.
+// Attributes set on a segment take precedence over the defaults, so that
+// SetDefaults(new(bar.Segment).Separator(false)) removes separators from
+// all segments that do not explicitly set them. Content, urgency, and click
+// handlers are not affected by the defaults. Can be called at any time.
+func SetDefaults(defaults *bar.Segment) {
+	if defaults != nil {
+		defaults = defaults.Clone()
+	}
+	construct()
+	instance.Lock()
+	instance.defaults = defaults
+	instance.clearEncoded = true
+	started := instance.started
+	instance.Unlock()
+	if started {
+		instance.refresh()
+	}
+}
+
+// SetLayout sets a function that transforms the segments from all modules
+// before they are sent to the bar, after applying any defaults. This allows
+// changes that depend on adjacent segments, such as outputs.Powerline.
+// The layout function must not modify the given segments, but can return
+// modified clones of them. Can be called at any time.
+func SetLayout(layout func([]*bar.Segment) []*bar.Segment) {
+	construct()
+	instance.Lock()
+	instance.layout = layout
+	started := instance.started
+	instance.Unlock()
+	if started {
+		instance.refresh()
+	}
+}
+
 // Run sets up all the streams and enters the main loop.
 // If any modules are provided, they are added to the bar now.
 // This allows both styles of bar construction:
@@ -173,8 +425,26 @@ func Run(modules ...bar.Module) error {
 		signal.Notify(signalChan, unix.SIGUSR1, unix.SIGUSR2)
 	}
 
+	b.Lock()
 	b.modules = append(b.modules, modules...)
+	b.mainModules = len(b.modules)
+	for _, m := range b.monitors {
+		b.modules = m.resolve(b.modules)
+	}
 	b.moduleSet = core.NewModuleSet(b.modules)
+	b.moduleSet.SetWatchdog(b.watchdog)
+	b.Unlock()
+
+	if b.controlSocket != "" {
+		if err := b.listenControl(b.controlSocket); err != nil {
+			return err
+		}
+	}
+	for _, m := range b.monitors {
+		if err := b.listenMonitor(m); err != nil {
+			return err
+		}
+	}
 
 	// Mark the bar as started.
 	b.started = true
@@ -186,32 +456,24 @@ func Run(modules ...bar.Module) error {
 		}
 	}(b.moduleSet.Stream())
 
+	// Redraw the bar with the new colours when the theme changes.
+	themeChanged, _ := colors.ThemeChanged()
+	go func() {
+		for range themeChanged {
+			b.Lock()
+			b.clearEncoded = true
+			b.Unlock()
+			b.refresh()
+		}
+	}()
+
 	errChan := make(chan error)
 	// Read events from the input stream, pipe them to the events channel.
 	go func(e chan<- error) {
-		e <- b.readEvents()
+		e <- b.readEvents(b.reader, nil)
 	}(errChan)
 
-	// Write header.
-	header := i3Header{
-		Version:     1,
-		ClickEvents: true,
-	}
-
-	if !b.suppressSignals {
-		// Go doesn't allow us to handle the default SIGSTOP,
-		// so we'll use SIGUSR1 and SIGUSR2 for pause/resume.
-		header.StopSignal = int(unix.SIGUSR1)
-		header.ContSignal = int(unix.SIGUSR2)
-	}
-	// Set up the encoder for the output stream,
-	// so that module outputs can be written directly.
-	b.encoder = json.NewEncoder(b.writer)
-	if err := b.encoder.Encode(&header); err != nil {
-		return err
-	}
-	// Start the infinite array.
-	if _, err := io.WriteString(b.writer, "["); err != nil {
+	if err := writeHeader(b.writer, !b.suppressSignals); err != nil {
 		return err
 	}
 
@@ -219,15 +481,33 @@ func Run(modules ...bar.Module) error {
 	b.resume()
 
 	// Infinite arrays on both sides.
+	var flush <-chan time.Time
 	for {
 		select {
 		case <-b.update:
+			if b.coalesceWindow > 0 {
+				// Wait for the window to elapse, ignoring updates in the
+				// meantime, since print always uses the latest outputs.
+				if flush == nil {
+					flush = time.After(b.coalesceWindow)
+				}
+				continue
+			}
 			// The complete bar needs to printed on each update.
 			if err := b.print(); err != nil {
 				return err
 			}
+		case <-flush:
+			flush = nil
+			if err := b.print(); err != nil {
+				return err
+			}
 		case event := <-b.events:
-			if onClick, ok := b.clickHandlers[event.Name]; ok {
+			handlers := b.clickHandlers
+			if event.view != nil {
+				handlers = event.view.clickHandlers
+			}
+			if onClick, ok := handlers[event.Name]; ok {
 				go onClick(event.Event)
 			}
 		case sig := <-signalChan:
@@ -257,9 +537,10 @@ func colorString(c color.Color) string {
 // the format used by i3bar.
 func i3map(s *bar.Segment) map[string]interface{} {
 	i3map := make(map[string]interface{})
-	txt, pango := s.Content()
+	_, pango := s.Content()
+	txt, shortText, hasShortText := s.DisplayText()
 	i3map["full_text"] = txt
-	if shortText, ok := s.GetShortText(); ok {
+	if hasShortText {
 		i3map["short_text"] = shortText
 	}
 	if color, ok := s.GetColor(); ok {
@@ -271,6 +552,12 @@ func i3map(s *bar.Segment) map[string]interface{} {
 	if border, ok := s.GetBorder(); ok {
 		i3map["border"] = colorString(border)
 	}
+	if top, right, bottom, left, ok := s.GetBorderWidth(); ok {
+		i3map["border_top"] = top
+		i3map["border_right"] = right
+		i3map["border_bottom"] = bottom
+		i3map["border_left"] = left
+	}
 	if minWidth, ok := s.GetMinWidth(); ok {
 		i3map["min_width"] = minWidth
 	}
@@ -294,57 +581,274 @@ func i3map(s *bar.Segment) map[string]interface{} {
 	return i3map
 }
 
+// bufPool holds buffers used to assemble the output of the bar.
+var bufPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// view is the rendering state for a single i3bar instance.
+type view struct {
+	// The indices of modules shown, in order, or nil for the main bar.
+	modules []int
+	// The layout used instead of the bar's layout, if set.
+	layout func([]*bar.Segment) []*bar.Segment
+	// A map of previously set click handlers for each segment.
+	clickHandlers map[string]func(bar.Event)
+	// The encoded output of each segment in the last print, so that segments
+	// from modules that have not updated are not encoded again. Defaults are
+	// applied to clones of segments, which are kept for the same reason.
+	encoded   map[*bar.Segment][]byte
+	defaulted map[*bar.Segment]*bar.Segment
+	// The number of updates of each module as of the last print.
+	updates   []int
+	lastWrite []byte
+}
+
+// barState is a snapshot of the bar used to render each view.
+type barState struct {
+	outputs        []bar.Segments
+	stats          []core.ModuleStats
+	hidden         map[int]bool
+	mainModules    int
+	suppressUrgent bool
+	defaults       *bar.Segment
+	layout         func([]*bar.Segment) []*bar.Segment
+	detailHandler  func(bar.DetailEvent)
+	detailButton   bar.Button
+	clearEncoded   bool
+	coalesce       bool
+}
+
 // print outputs the entire bar, using the last output for each module.
 func (b *i3Bar) print() error {
-	// Store the set of click handlers for any segments that can handle clicks.
-	// When i3bar sends us the click event, it will include an identifier that
-	// we can use to look up the function to call.
-	b.clickHandlers = map[string]func(bar.Event){}
+	defer b.recordRender(time.Now())
+	b.Lock()
+	state := barState{
+		hidden:         map[int]bool{},
+		mainModules:    b.mainModules,
+		suppressUrgent: b.suppressUrgent,
+		defaults:       b.defaults,
+		layout:         b.layout,
+		detailHandler:  b.detailHandler,
+		detailButton:   b.detailButton,
+		clearEncoded:   b.clearEncoded,
+		coalesce:       b.coalesce,
+	}
+	for idx := range b.hidden {
+		state.hidden[idx] = true
+	}
+	b.clearEncoded = false
+	conns := append([]*monitorConn(nil), b.monitorConns...)
+	b.Unlock()
 	// i3bar requires the entire bar to be printed at once, so we just take the
 	// last cached value for each module and construct the current bar.
-	output := make([]map[string]interface{}, 0)
-	for _, segments := range b.moduleSet.LastOutputs() {
-		for _, segment := range segments {
-			out := i3map(segment)
-			var clickHandler func(bar.Event)
-			if err := segment.GetError(); err != nil {
-				// because go.
-				segment := segment
-				clickHandler = func(e bar.Event) {
-					if e.Button == bar.ButtonRight {
-						b.errorHandler(bar.ErrorEvent{Error: err, Event: e})
-					} else {
-						segment.Click(e)
-					}
+	// Stats are read first, so that an update between the two calls is seen
+	// again on the next print, and its segments are encoded again then.
+	state.stats = b.moduleSet.Stats()
+	state.outputs = b.moduleSet.LastOutputs()
+	if err := b.write(b.writer, &b.view, state); err != nil {
+		return err
+	}
+	for _, c := range conns {
+		if err := b.write(c, &c.view, state); err != nil {
+			l.Log("Disconnecting monitor bar: %v", err)
+			b.disconnect(c)
+		}
+	}
+	return nil
+}
+
+// write renders the view and writes it to the given writer, unless it is
+// identical to the last write and updates are being coalesced.
+func (b *i3Bar) write(w io.Writer, v *view, state barState) error {
+	buf := bufPool.Get().(*bytes.Buffer)
+	defer bufPool.Put(buf)
+	buf.Reset()
+	if err := b.render(buf, v, state); err != nil {
+		return err
+	}
+	if state.coalesce {
+		if bytes.Equal(buf.Bytes(), v.lastWrite) {
+			l.Fine("Skipping identical update")
+			return nil
+		}
+		v.lastWrite = append(v.lastWrite[:0], buf.Bytes()...)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// render writes the output of the view to the buffer, and stores the set of
+// click handlers for any segments that can handle clicks. When i3bar sends
+// us the click event, it will include an identifier that we can use to look
+// up the function to call.
+func (b *i3Bar) render(buf *bytes.Buffer, v *view, state barState) error {
+	prevEncoded, prevDefaulted := v.encoded, v.defaulted
+	if state.clearEncoded {
+		prevEncoded, prevDefaulted = nil, nil
+	}
+	v.clickHandlers = map[string]func(bar.Event){}
+	v.encoded = map[*bar.Segment][]byte{}
+	v.defaulted = map[*bar.Segment]*bar.Segment{}
+	if len(v.updates) != len(state.stats) {
+		v.updates = make([]int, len(state.stats))
+	}
+	// Segments from modules that updated since the last print are encoded
+	// again, even if they were sent before, in case they were modified.
+	updated := map[*bar.Segment]bool{}
+	for idx, stats := range state.stats {
+		if stats.Updates == v.updates[idx] {
+			continue
+		}
+		v.updates[idx] = stats.Updates
+		for _, segment := range state.outputs[idx] {
+			updated[segment] = true
+		}
+	}
+	modules := v.modules
+	if modules == nil {
+		for idx := 0; idx < state.mainModules; idx++ {
+			modules = append(modules, idx)
+		}
+	}
+	var segments []*bar.Segment
+	for _, idx := range modules {
+		if state.hidden[idx] {
+			continue
+		}
+		for _, segment := range state.outputs[idx] {
+			if state.defaults != nil {
+				orig := segment
+				segment = prevDefaulted[orig]
+				if segment == nil || updated[orig] {
+					segment = orig.Clone().ApplyDefaults(state.defaults)
+					updated[segment] = true
+				}
+				v.defaulted[orig] = segment
+			}
+			segments = append(segments, segment)
+		}
+	}
+	layout := state.layout
+	if v.layout != nil {
+		layout = v.layout
+	}
+	if layout != nil {
+		segments = layout(segments)
+	}
+	buf.WriteByte('[')
+	for i, segment := range segments {
+		encoded, ok := prevEncoded[segment]
+		if !ok || updated[segment] {
+			var err error
+			if encoded, err = b.encode(segment, state.suppressUrgent); err != nil {
+				return err
+			}
+		}
+		v.encoded[segment] = encoded
+		var clickHandler func(bar.Event)
+		if err := segment.GetError(); err != nil {
+			// because go.
+			segment := segment
+			clickHandler = func(e bar.Event) {
+				if e.Button == bar.ButtonRight {
+					b.errorHandler(bar.ErrorEvent{Error: err, Event: e})
+				} else {
+					segment.Click(e)
 				}
-			} else if segment.HasClick() {
-				clickHandler = segment.Click
 			}
-			if clickHandler != nil {
-				name := strconv.Itoa(len(b.clickHandlers))
-				out["name"] = name
-				b.clickHandlers[name] = clickHandler
+		} else if detail, ok := segment.GetDetail(); ok && state.detailHandler != nil {
+			segment := segment
+			clickHandler = func(e bar.Event) {
+				if e.Button == state.detailButton {
+					state.detailHandler(bar.DetailEvent{Detail: detail(), Event: e})
+				} else {
+					segment.Click(e)
+				}
 			}
-			output = append(output, out)
+		} else if segment.HasClick() {
+			clickHandler = segment.Click
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if clickHandler == nil {
+			buf.Write(encoded)
+			continue
+		}
+		// Names depend on the position of the segment on the bar, so they
+		// are added to the encoded segment, which is always a JSON object.
+		name := strconv.Itoa(len(v.clickHandlers))
+		v.clickHandlers[name] = clickHandler
+		fmt.Fprintf(buf, `{"name":%q,`, name)
+		buf.Write(encoded[1:])
+	}
+	buf.WriteString("]\n,\n")
+	return nil
+}
+
+// encode returns the i3bar output of a segment as a JSON object.
+func (b *i3Bar) encode(segment *bar.Segment, suppressUrgent bool) ([]byte, error) {
+	out := i3map(segment)
+	if img, ok := segment.GetImage(); ok {
+		b.addImage(out, segment, img)
+	}
+	if suppressUrgent && segment.GetError() == nil {
+		delete(out, "urgent")
+	}
+	return json.Marshal(out)
+}
+
+// addImage adds the segment's image to its i3bar output, either using the
+// image extension or as pango markup before the segment's content.
+func (b *i3Bar) addImage(out map[string]interface{}, s *bar.Segment, img image.Image) {
+	if b.imageHeight > 0 {
+		out["_image"] = b.images.Get(img)
+		return
+	}
+	_, isPango := s.Content()
+	txt, shortText, hasShortText := s.DisplayText()
+	if !isPango {
+		txt = html.EscapeString(txt)
+		if hasShortText {
+			out["short_text"] = html.EscapeString(shortText)
 		}
 	}
-	if err := b.encoder.Encode(output); err != nil {
+	if txt != "" {
+		txt = " " + txt
+	}
+	out["full_text"] = b.images.Get(img) + txt
+	out["markup"] = "pango"
+}
+
+// writeHeader writes the header and starts the infinite array of outputs.
+func writeHeader(w io.Writer, signals bool) error {
+	header := i3Header{
+		Version:     1,
+		ClickEvents: true,
+	}
+	if signals {
+		// Go doesn't allow us to handle the default SIGSTOP,
+		// so we'll use SIGUSR1 and SIGUSR2 for pause/resume.
+		header.StopSignal = int(unix.SIGUSR1)
+		header.ContSignal = int(unix.SIGUSR2)
+	}
+	if err := json.NewEncoder(w).Encode(&header); err != nil {
 		return err
 	}
-	_, err := io.WriteString(b.writer, ",\n")
+	_, err := io.WriteString(w, "[")
 	return err
 }
 
-// readEvents parses the infinite stream of events received from i3.
-func (b *i3Bar) readEvents() error {
-	decoder := json.NewDecoder(b.reader)
+// readEvents parses the infinite stream of events received from i3,
+// for the given view or the main bar if nil.
+func (b *i3Bar) readEvents(r io.Reader, v *view) error {
+	decoder := json.NewDecoder(r)
 	// Consume opening '['
 	_, err := decoder.Token()
 	if err != nil {
 		return err
 	}
 	for decoder.More() {
-		var event i3Event
+		event := i3Event{view: v}
 		err = decoder.Decode(&event)
 		if err != nil {
 			return err
@@ -358,12 +862,16 @@ func (b *i3Bar) readEvents() error {
 func (b *i3Bar) pause() {
 	l.Log("Bar paused")
 	b.Lock()
-	defer b.Unlock()
 	if b.paused {
+		b.Unlock()
 		return
 	}
 	b.paused = true
 	timing.Pause()
+	b.Unlock()
+	for idx := 0; idx < b.moduleSet.Len(); idx++ {
+		b.moduleSet.Pause(idx)
+	}
 	b.emitDebugEvent(dEvtPaused, "")
 }
 
@@ -371,8 +879,8 @@ func (b *i3Bar) pause() {
 func (b *i3Bar) resume() {
 	l.Log("Bar resumed")
 	b.Lock()
-	defer b.Unlock()
 	if !b.paused {
+		b.Unlock()
 		return
 	}
 	b.paused = false
@@ -381,6 +889,12 @@ func (b *i3Bar) resume() {
 		b.refreshOnResume = false
 		b.maybeUpdate()
 	}
+	b.Unlock()
+	// Modules are resumed without holding the lock, since they can update
+	// immediately, which requires the lock to refresh the bar.
+	for idx := 0; idx < b.moduleSet.Len(); idx++ {
+		b.moduleSet.Resume(idx)
+	}
 	b.emitDebugEvent(dEvtResumed, "")
 }
 
@@ -15,16 +15,21 @@
 package barista
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
 	"image/color"
+	"image/png"
 	"os"
 	"os/signal"
 	"testing"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/colors"
 	"barista.run/outputs"
 	"barista.run/testing/mockio"
 	testModule "barista.run/testing/module"
@@ -329,6 +334,35 @@ func TestPauseResume(t *testing.T) {
 		"Partial updates while paused")
 }
 
+type pausableModule struct {
+	*testModule.TestModule
+	paused chan bool
+}
+
+func (p pausableModule) Pause()  { p.paused <- true }
+func (p pausableModule) Resume() { p.paused <- false }
+
+func TestPauseModules(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+	pauseChan := debugEvents(dEvtPaused, dEvtResumed)
+
+	module := pausableModule{testModule.New(t), make(chan bool, 10)}
+	go Run(module)
+	<-pauseChan
+	require.False(t, <-module.paused, "resumed on start")
+	module.AssertStarted()
+
+	unix.Kill(unix.Getpid(), unix.SIGUSR1)
+	require.Equal(t, dEvtPaused, (<-pauseChan).kind)
+	require.True(t, <-module.paused, "paused with bar")
+
+	unix.Kill(unix.Getpid(), unix.SIGUSR2)
+	require.Equal(t, dEvtResumed, (<-pauseChan).kind)
+	require.False(t, <-module.paused, "resumed with bar")
+}
+
 func TestClickEvents(t *testing.T) {
 	mockStdin := mockio.Stdin()
 	mockStdout := mockio.Stdout()
@@ -552,6 +586,348 @@ func TestErrorHandling(t *testing.T) {
 		"restarting from regular segment also clears errors")
 }
 
+func TestDetailHandler(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+
+	module := testModule.New(t)
+	go Run(module)
+
+	module.AssertStarted()
+	mockStdin.WriteString("[")
+	mockStdout.ReadUntil('[', time.Second)
+
+	detailCalls := 0
+	module.Output(outputs.Group(
+		outputs.Text("detail").Detail(func() string {
+			detailCalls++
+			return "more info"
+		}),
+		outputs.Text("plain"),
+	))
+	out := readOutput(t, mockStdout)
+	mockStdin.WriteString(fmt.Sprintf(`{"name": "%s", "button": 2},`, out[0]["name"]))
+	module.AssertClicked("without detail handler")
+
+	detailChan := make(chan bar.DetailEvent)
+	SetDetailHandler(bar.ButtonMiddle, func(e bar.DetailEvent) { detailChan <- e })
+	out = readOutput(t, mockStdout)
+	detailSegmentName := out[0]["name"].(string)
+	require.Equal(t, 0, detailCalls, "detail not computed on output")
+
+	mockStdin.WriteString(fmt.Sprintf(`{"name": "%s", "button": 1},`, detailSegmentName))
+	module.AssertClicked("on left click of detail segment")
+
+	mockStdin.WriteString(fmt.Sprintf(`{"name": "%s", "x": 7, "button": 2},`, detailSegmentName))
+	module.AssertNotClicked("on middle click of detail segment")
+	select {
+	case e := <-detailChan:
+		require.Equal(t, "more info", e.Detail)
+		require.Equal(t, bar.Event{ScreenX: 7, Button: bar.ButtonMiddle}, e.Event)
+	case <-time.After(time.Second):
+		require.Fail(t, "should trigger detail handler on middle click")
+	}
+	require.Equal(t, 1, detailCalls)
+
+	plainSegmentName := out[1]["name"].(string)
+	mockStdin.WriteString(fmt.Sprintf(`{"name": "%s", "button": 2},`, plainSegmentName))
+	select {
+	case <-detailChan:
+		require.Fail(t, "segments without detail use their own click handler")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	module.AssertClicked("on middle click of plain segment")
+
+	SetDetailHandler(bar.ButtonMiddle, nil)
+	out = readOutput(t, mockStdout)
+	mockStdin.WriteString(fmt.Sprintf(`{"name": "%s", "button": 2},`, out[0]["name"]))
+	module.AssertClicked("after detail handler is removed")
+	require.Equal(t, 1, detailCalls)
+}
+
+func TestDetailCommand(t *testing.T) {
+	args := make([]string, 1, 4)
+	args[0] = "-e"
+	handler := DetailCommand("true", args...)
+	require.NotPanics(t, func() { handler(bar.DetailEvent{Detail: "info"}) })
+	require.Equal(t, "", args[:2][1], "caller's slice is not modified")
+	require.NotPanics(t, func() {
+		DetailCommand("/nonexistent/command")(bar.DetailEvent{Detail: "info"})
+	}, "errors are logged")
+}
+
+func TestSuppressUrgent(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+	SuppressUrgent(true)
+
+	module := testModule.New(t)
+	go Run(module)
+
+	module.AssertStarted()
+	mockStdout.ReadUntil('[', time.Second)
+	module.Output(outputs.Group(
+		outputs.Text("urgent").Urgent(true),
+		outputs.Errorf("error"),
+	))
+	out := readOutput(t, mockStdout)
+	require.NotContains(t, out[0], "urgent", "urgent suppressed")
+	require.Equal(t, true, out[1]["urgent"], "errors are always urgent")
+
+	SuppressUrgent(true)
+	require.False(t, mockStdout.WaitForWrite(10*time.Millisecond),
+		"no update if unchanged")
+
+	SuppressUrgent(false)
+	out = readOutput(t, mockStdout)
+	require.Equal(t, true, out[0]["urgent"], "urgent restored")
+	require.Equal(t, true, out[1]["urgent"], "errors are always urgent")
+}
+
+func TestSetDefaults(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+	SetDefaults(new(bar.Segment).
+		Separator(false).
+		Padding(0).
+		Border(colors.Hex("#333")).
+		BorderWidth(0, 0, 2, 0).
+		ShortLength(4))
+
+	module := testModule.New(t)
+	go Run(module)
+
+	module.AssertStarted()
+	mockStdout.ReadUntil('[', time.Second)
+	module.Output(outputs.Group(
+		outputs.Text("default"),
+		outputs.Text("override").Separator(true).BorderWidth(1, 1, 1, 1),
+	))
+	out := readOutput(t, mockStdout)
+	require.Equal(t, false, out[0]["separator"])
+	require.Equal(t, float64(0), out[0]["separator_block_width"])
+	require.Equal(t, "#333333", out[0]["border"])
+	require.Equal(t, float64(0), out[0]["border_top"])
+	require.Equal(t, float64(2), out[0]["border_bottom"])
+	require.Equal(t, "def…", out[0]["short_text"], "short text generated")
+	require.Equal(t, true, out[1]["separator"], "segment overrides default")
+	require.Equal(t, "#333333", out[1]["border"])
+	require.Equal(t, float64(1), out[1]["border_bottom"], "segment overrides default")
+	require.Equal(t, "ove…", out[1]["short_text"])
+
+	SetDefaults(nil)
+	out = readOutput(t, mockStdout)
+	require.NotContains(t, out[0], "separator", "redrawn without defaults")
+	require.NotContains(t, out[0], "border")
+	require.NotContains(t, out[0], "short_text")
+	require.Equal(t, true, out[1]["separator"])
+}
+
+func TestSetLayout(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+	SetDefaults(new(bar.Segment).Background(colors.Hex("#f00")))
+	SetLayout(outputs.Powerline(outputs.PowerlineArrow))
+
+	module := testModule.New(t)
+	go Run(module)
+
+	_, err := mockStdout.ReadUntil('[', time.Second)
+	require.Nil(t, err, "output array started without any errors")
+	mockStdin.WriteString("[")
+	module.AssertStarted()
+
+	module.Output(outputs.Group(
+		outputs.Text("a"),
+		outputs.Text("b").Background(colors.Hex("#00f")),
+	))
+	out := readOutput(t, mockStdout)
+	require.Equal(t, 4, len(out), "separators added")
+	require.Equal(t, outputs.PowerlineArrow, out[0]["full_text"])
+	require.Equal(t, "#ff0000", out[0]["color"], "using defaults")
+	require.Equal(t, "a", out[1]["full_text"])
+	require.Equal(t, "#0000ff", out[2]["color"])
+	require.Equal(t, "#ff0000", out[2]["background"])
+	require.Equal(t, "b", out[3]["full_text"])
+	require.NotContains(t, out[2], "name", "separators are not clickable")
+
+	mockStdin.WriteString(fmt.Sprintf(`{"name": "%s"},`, out[3]["name"]))
+	module.AssertClicked("click on segment after layout")
+
+	SetLayout(nil)
+	out = readOutput(t, mockStdout)
+	require.Equal(t, 2, len(out), "redrawn without layout")
+}
+
+func TestImages(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+
+	module := testModule.New(t)
+	go Run(module)
+
+	module.AssertStarted()
+	mockStdout.ReadUntil('[', time.Second)
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 2))
+	img.Set(0, 0, color.NRGBA{0xff, 0, 0, 0xff})
+	module.Output(outputs.Group(
+		outputs.Text("a<b").ShortText("<").Image(img),
+		bar.PangoSegment("<b>b</b>").Image(img),
+		bar.TextSegment("").Image(img),
+	))
+	out := readOutput(t, mockStdout)
+	require.Equal(t, "pango", out[0]["markup"])
+	require.Equal(t, "<span color='#ff0000'>▀</span> a&lt;b", out[0]["full_text"],
+		"image rendered as pango before escaped text")
+	require.Equal(t, "&lt;", out[0]["short_text"])
+	require.Equal(t, "<span color='#ff0000'>▀</span> <b>b</b>", out[1]["full_text"])
+	require.Equal(t, "<span color='#ff0000'>▀</span>", out[2]["full_text"])
+}
+
+func TestSendImages(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+	SendImages(4)
+
+	module := testModule.New(t)
+	go Run(module)
+
+	module.AssertStarted()
+	mockStdout.ReadUntil('[', time.Second)
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 2))
+	module.Output(outputs.Text("art").Image(img))
+	out := readOutput(t, mockStdout)
+	require.Equal(t, "art", out[0]["full_text"])
+	require.Equal(t, "none", out[0]["markup"])
+
+	data, err := base64.StdEncoding.DecodeString(out[0]["_image"].(string))
+	require.NoError(t, err)
+	decoded, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 2, 4), decoded.Bounds(), "scaled to height")
+
+	require.Panics(t, func() { SendImages(8) }, "after Run")
+}
+
+func TestCoalesceUpdates(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+	CoalesceUpdates(50 * time.Millisecond)
+
+	m0 := testModule.New(t)
+	m1 := testModule.New(t)
+	go Run(m0, m1)
+
+	m0.AssertStarted()
+	m1.AssertStarted()
+	mockStdout.ReadUntil('[', time.Second)
+
+	m0.OutputText("a")
+	m1.OutputText("b")
+	m0.OutputText("c")
+	require.Equal(t, []string{"c", "b"}, readOutputTexts(t, mockStdout),
+		"updates within window are combined")
+	require.False(t, mockStdout.WaitForWrite(100*time.Millisecond),
+		"no further writes after combined update")
+
+	m1.OutputText("b")
+	require.False(t, mockStdout.WaitForWrite(100*time.Millisecond),
+		"identical output is not written")
+
+	m1.OutputText("d")
+	require.Equal(t, []string{"c", "d"}, readOutputTexts(t, mockStdout),
+		"changed output is written")
+
+	require.Panics(t, func() { CoalesceUpdates(0) },
+		"changing coalescing after Run")
+}
+
+func TestEncodedOutputReuse(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+	SetDefaults(new(bar.Segment).Padding(4))
+
+	clock := testModule.New(t)
+	other := testModule.New(t)
+	go Run(other, clock)
+	other.AssertStarted()
+	clock.AssertStarted()
+	mockStdout.ReadUntil('[', time.Second)
+	mockStdin.WriteString("[")
+
+	encodedText := func(text string) []byte {
+		for _, encoded := range instance.encoded {
+			if bytes.Contains(encoded, []byte(`"full_text":"`+text+`"`)) {
+				return encoded
+			}
+		}
+		return nil
+	}
+
+	other.OutputText("a")
+	readOutput(t, mockStdout)
+	before := encodedText("a")
+	require.NotNil(t, before)
+
+	clock.OutputText("12:00")
+	readOutput(t, mockStdout)
+	clock.OutputText("12:01")
+	out := readOutput(t, mockStdout)
+	require.Equal(t, "a", out[0]["full_text"])
+	require.Equal(t, 4.0, out[0]["separator_block_width"])
+	require.Equal(t, "12:01", out[1]["full_text"])
+	require.True(t, &before[0] == &encodedText("a")[0],
+		"unchanged segment is not encoded again")
+
+	mockStdin.WriteString(fmt.Sprintf(`{"name": "%s"},`, out[0]["name"]))
+	other.AssertClicked("click on reused segment")
+
+	other.OutputText("a")
+	readOutput(t, mockStdout)
+	require.False(t, &before[0] == &encodedText("a")[0],
+		"segment encoded again after module updates")
+
+	before = encodedText("a")
+	SetDefaults(new(bar.Segment).Padding(6))
+	out = readOutput(t, mockStdout)
+	require.Equal(t, 6.0, out[0]["separator_block_width"])
+	require.False(t, &before[0] == &encodedText("a")[0],
+		"segment encoded again after defaults change")
+	SetDefaults(nil)
+}
+
+func TestThemeRedraw(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+
+	colors.AddTheme("test-a", map[string]string{"good": "#00ff00"})
+	colors.AddTheme("test-b", map[string]string{"good": "#008800"})
+	require.NoError(t, colors.SetTheme("test-a"))
+
+	module := testModule.New(t)
+	go Run(module)
+	module.AssertStarted()
+	mockStdout.ReadUntil('[', time.Second)
+
+	module.Output(outputs.Text("good").Color(colors.Scheme("good")))
+	out := readOutput(t, mockStdout)
+	require.Equal(t, "#00ff00", out[0]["color"])
+
+	require.NoError(t, colors.SetTheme("test-b"))
+	out = readOutput(t, mockStdout)
+	require.Equal(t, "#008800", out[0]["color"], "redrawn on theme change")
+}
+
 func testIoError(
 	t *testing.T,
 	setup func(*mockio.Readable, *mockio.Writable),
@@ -54,14 +54,15 @@ func Button(do func(bar.Button), btns ...bar.Button) func(bar.Event) {
 }
 
 // ButtonE filters out events triggered by buttons not listed in btns before
-// invoking the given click handler.
+// invoking the given click handler. Button release events are ignored, so
+// that handlers are only called once per click.
 func ButtonE(handler func(bar.Event), btns ...bar.Button) func(bar.Event) {
 	btnMap := map[bar.Button]bool{}
 	for _, b := range btns {
 		btnMap[b] = true
 	}
 	return func(e bar.Event) {
-		if btnMap[e.Button] {
+		if btnMap[e.Button] && !e.Release {
 			handler(e)
 		}
 	}
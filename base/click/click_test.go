@@ -109,6 +109,9 @@ func TestButton(t *testing.T) {
 
 	triggerHandler(handler, bar.ScrollUp)
 	require.Equal(t, bar.ScrollUp, checkE())
+
+	handler(bar.Event{Button: bar.ScrollUp, Release: true})
+	require.Equal(t, notClicked, checkE(), "release events are ignored")
 }
 
 func TestRunLeft(t *testing.T) {
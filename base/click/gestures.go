@@ -0,0 +1,199 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package click
+
+import (
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/timing"
+)
+
+// GestureDetector synthesizes gestures from click events, and passes them to
+// a handler. Its Handle method is used as the click handler, e.g.
+// out.OnClick(click.Gestures(handleGesture).Handle).
+//
+// Bars that only send button presses (i3bar, swaybar) produce clicks, double
+// clicks, and scrolls. Long presses and drags are detected once the bar has
+// sent a button release event.
+type GestureDetector struct {
+	handler       func(bar.Gesture)
+	doubleClick   time.Duration
+	longPress     time.Duration
+	dragThreshold int
+	scrollStep    int
+
+	mu sync.Mutex
+	// Click waiting for a second click, until the double click timeout.
+	pending   *bar.Event
+	pendingAt time.Time
+	// Last button press, used to detect gestures on release.
+	pressed    *bar.Event
+	pressedAt  time.Time
+	sawRelease bool
+	// Scroll events accumulated since the last scroll gesture.
+	scrollButton bar.Button
+	scrollTicks  int
+}
+
+// Gestures creates a gesture detector that calls the handler for each gesture.
+// By default, double clicks must be within 250ms, which delays single clicks
+// by the same amount, long presses take 500ms, drags must move the pointer at
+// least 10px, and each scroll event is a scroll gesture.
+func Gestures(handler func(bar.Gesture)) *GestureDetector {
+	return &GestureDetector{
+		handler:       handler,
+		doubleClick:   250 * time.Millisecond,
+		longPress:     500 * time.Millisecond,
+		dragThreshold: 10,
+		scrollStep:    1,
+	}
+}
+
+// DoubleClick sets the maximum time between clicks of a double click. Single
+// clicks are only reported once this time has passed without a second click.
+// A duration of 0 disables double clicks, reporting single clicks immediately.
+func (g *GestureDetector) DoubleClick(timeout time.Duration) *GestureDetector {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.doubleClick = timeout
+	return g
+}
+
+// LongPress sets how long a button must be held down for a long press.
+func (g *GestureDetector) LongPress(duration time.Duration) *GestureDetector {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.longPress = duration
+	return g
+}
+
+// DragThreshold sets how far the pointer must move, in pixels, while a button
+// is held down for a drag.
+func (g *GestureDetector) DragThreshold(pixels int) *GestureDetector {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.dragThreshold = pixels
+	return g
+}
+
+// ScrollStep sets the number of scroll events in the same direction that make
+// up a single scroll gesture, e.g. for sensitive touchpads.
+func (g *GestureDetector) ScrollStep(events int) *GestureDetector {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if events < 1 {
+		events = 1
+	}
+	g.scrollStep = events
+	return g
+}
+
+// Handle processes a click event, and calls the handler for any gestures
+// completed by the event.
+func (g *GestureDetector) Handle(e bar.Event) {
+	for _, gesture := range g.process(e) {
+		g.handler(gesture)
+	}
+}
+
+func isScroll(btn bar.Button) bool {
+	switch btn {
+	case bar.ScrollUp, bar.ScrollDown, bar.ScrollLeft, bar.ScrollRight:
+		return true
+	}
+	return false
+}
+
+func (g *GestureDetector) process(e bar.Event) (gestures []bar.Gesture) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := timing.Now()
+	if isScroll(e.Button) {
+		if e.Release {
+			return nil
+		}
+		if e.Button != g.scrollButton {
+			g.scrollButton, g.scrollTicks = e.Button, 0
+		}
+		g.scrollTicks++
+		if g.scrollTicks < g.scrollStep {
+			return nil
+		}
+		g.scrollTicks = 0
+		return []bar.Gesture{{Kind: bar.GestureScroll, Event: e}}
+	}
+	if !e.Release {
+		g.pressed, g.pressedAt = &e, now
+		if g.sawRelease {
+			// Wait for the release to determine the gesture.
+			return nil
+		}
+		return g.click(e, now)
+	}
+	pressed := g.pressed
+	g.pressed = nil
+	if !g.sawRelease {
+		// The press was already handled as a click.
+		g.sawRelease = true
+		return nil
+	}
+	if pressed == nil || pressed.Button != e.Button {
+		return nil
+	}
+	if dx := e.ScreenX - pressed.ScreenX; dx >= g.dragThreshold || -dx >= g.dragThreshold {
+		return []bar.Gesture{{Kind: bar.GestureDrag, Event: *pressed, DeltaX: dx}}
+	}
+	if now.Sub(g.pressedAt) >= g.longPress {
+		return []bar.Gesture{{Kind: bar.GestureLongPress, Event: e}}
+	}
+	return g.click(*pressed, now)
+}
+
+// click handles a single click, which may be the second click of a double
+// click, or need to wait for a possible second click.
+func (g *GestureDetector) click(e bar.Event, now time.Time) (gestures []bar.Gesture) {
+	if p := g.pending; p != nil {
+		g.pending = nil
+		if p.Button == e.Button && now.Sub(g.pendingAt) <= g.doubleClick {
+			return []bar.Gesture{{Kind: bar.GestureDoubleClick, Event: e}}
+		}
+		gestures = append(gestures, bar.Gesture{Kind: bar.GestureClick, Event: *p})
+	}
+	if g.doubleClick <= 0 {
+		return append(gestures, bar.Gesture{Kind: bar.GestureClick, Event: e})
+	}
+	g.pending, g.pendingAt = &e, now
+	go g.flushOnTimeout(g.pending, timing.NewScheduler().After(g.doubleClick))
+	return gestures
+}
+
+// flushOnTimeout reports a pending click as a single click once the double
+// click timeout has passed, unless it was already handled by another click.
+// The timer always fires, so the goroutine exits and closes it either way.
+func (g *GestureDetector) flushOnTimeout(p *bar.Event, timer *timing.Scheduler) {
+	<-timer.C
+	timer.Close()
+	g.mu.Lock()
+	flush := g.pending == p
+	if flush {
+		g.pending = nil
+	}
+	g.mu.Unlock()
+	if flush {
+		g.handler(bar.Gesture{Kind: bar.GestureClick, Event: *p})
+	}
+}
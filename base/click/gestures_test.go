@@ -0,0 +1,151 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package click
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type gestureRecorder chan bar.Gesture
+
+func (r gestureRecorder) handle(g bar.Gesture) { r <- g }
+
+func (r gestureRecorder) next(t *testing.T, msg string) bar.Gesture {
+	select {
+	case g := <-r:
+		return g
+	case <-time.After(time.Second):
+		require.Fail(t, "Expected a gesture", msg)
+	}
+	return bar.Gesture{}
+}
+
+func (r gestureRecorder) assertNone(t *testing.T, msg string) {
+	select {
+	case g := <-r:
+		require.Fail(t, "Unexpected gesture", "%s: %+v", msg, g)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestDoubleClick(t *testing.T) {
+	timing.TestMode()
+	r := make(gestureRecorder, 10)
+	g := Gestures(r.handle)
+
+	g.Handle(bar.Event{Button: bar.ButtonLeft})
+	r.assertNone(t, "waiting for double click")
+	timing.AdvanceBy(100 * time.Millisecond)
+	g.Handle(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, bar.GestureDoubleClick, r.next(t, "double click").Kind)
+	timing.NextTick()
+	r.assertNone(t, "no click after double click")
+
+	g.Handle(bar.Event{Button: bar.ButtonLeft, X: 5})
+	timing.NextTick()
+	gesture := r.next(t, "click after timeout")
+	require.Equal(t, bar.GestureClick, gesture.Kind)
+	require.Equal(t, 5, gesture.X)
+
+	g.Handle(bar.Event{Button: bar.ButtonLeft})
+	timing.AdvanceBy(300 * time.Millisecond)
+	r.next(t, "click after timeout")
+	g.Handle(bar.Event{Button: bar.ButtonLeft})
+	r.assertNone(t, "slow clicks are not a double click")
+	g.Handle(bar.Event{Button: bar.ButtonRight})
+	gesture = r.next(t, "click on other button")
+	require.Equal(t, bar.GestureClick, gesture.Kind)
+	require.Equal(t, bar.ButtonLeft, gesture.Button, "pending click reported")
+	timing.NextTick()
+	gesture = r.next(t, "click after timeout")
+	require.Equal(t, bar.ButtonRight, gesture.Button)
+
+	g.DoubleClick(0)
+	g.Handle(bar.Event{Button: bar.ButtonMiddle})
+	gesture = r.next(t, "immediate click without double click")
+	require.Equal(t, bar.GestureClick, gesture.Kind)
+	g.Handle(bar.Event{Button: bar.ButtonMiddle})
+	require.Equal(t, bar.GestureClick, r.next(t, "second click").Kind)
+}
+
+func TestScrollStep(t *testing.T) {
+	timing.TestMode()
+	r := make(gestureRecorder, 10)
+	g := Gestures(r.handle).ScrollStep(3)
+
+	for i := 0; i < 2; i++ {
+		g.Handle(bar.Event{Button: bar.ScrollUp})
+	}
+	r.assertNone(t, "fewer than 3 scroll events")
+	g.Handle(bar.Event{Button: bar.ScrollUp})
+	gesture := r.next(t, "3 scroll events")
+	require.Equal(t, bar.GestureScroll, gesture.Kind)
+	require.Equal(t, bar.ScrollUp, gesture.Button)
+
+	g.Handle(bar.Event{Button: bar.ScrollUp})
+	g.Handle(bar.Event{Button: bar.ScrollUp})
+	g.Handle(bar.Event{Button: bar.ScrollDown})
+	g.Handle(bar.Event{Button: bar.ScrollDown})
+	r.assertNone(t, "direction change resets count")
+	g.Handle(bar.Event{Button: bar.ScrollDown})
+	require.Equal(t, bar.ScrollDown, r.next(t, "3 scroll down events").Button)
+
+	g.ScrollStep(0)
+	g.Handle(bar.Event{Button: bar.ScrollLeft})
+	require.Equal(t, bar.ScrollLeft, r.next(t, "every scroll event").Button)
+}
+
+func TestReleaseGestures(t *testing.T) {
+	timing.TestMode()
+	r := make(gestureRecorder, 10)
+	g := Gestures(r.handle).DoubleClick(0).DragThreshold(5)
+
+	g.Handle(bar.Event{Button: bar.ButtonLeft})
+	require.Equal(t, bar.GestureClick, r.next(t, "click on press").Kind)
+	g.Handle(bar.Event{Button: bar.ButtonLeft, Release: true})
+	r.assertNone(t, "first release ignored")
+
+	g.Handle(bar.Event{Button: bar.ButtonLeft, ScreenX: 100})
+	r.assertNone(t, "waiting for release")
+	g.Handle(bar.Event{Button: bar.ButtonLeft, ScreenX: 102, Release: true})
+	require.Equal(t, bar.GestureClick, r.next(t, "click on release").Kind)
+
+	g.Handle(bar.Event{Button: bar.ButtonLeft, ScreenX: 100})
+	g.Handle(bar.Event{Button: bar.ButtonLeft, ScreenX: 80, Release: true})
+	gesture := r.next(t, "drag")
+	require.Equal(t, bar.GestureDrag, gesture.Kind)
+	require.Equal(t, -20, gesture.DeltaX)
+	require.Equal(t, 100, gesture.ScreenX, "drag has initial event")
+
+	g.Handle(bar.Event{Button: bar.ButtonRight})
+	timing.AdvanceBy(600 * time.Millisecond)
+	g.Handle(bar.Event{Button: bar.ButtonRight, Release: true})
+	require.Equal(t, bar.GestureLongPress, r.next(t, "long press").Kind)
+
+	g.Handle(bar.Event{Button: bar.ButtonRight})
+	g.Handle(bar.Event{Button: bar.ButtonLeft, Release: true})
+	r.assertNone(t, "release of other button")
+
+	g.Handle(bar.Event{Button: bar.ScrollUp})
+	g.Handle(bar.Event{Button: bar.ScrollUp, Release: true})
+	require.Equal(t, bar.GestureScroll, r.next(t, "scroll").Kind)
+	r.assertNone(t, "scroll release ignored")
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package click
+
+import "barista.run/bar"
+
+// Regions divides a segment horizontally into regions with the given
+// relative widths, and returns a click handler that routes each event to the
+// handler for the region that was clicked. This allows a single segment to
+// act as several buttons, e.g. "⏮ ⏯ ⏭" for a media player, with widths
+// proportional to the number of characters in each part.
+//
+// The region is determined from the relative_x and width sent by the bar,
+// and the event is adjusted so that X and Width are relative to the region.
+// Events from bars that do not send the segment width are ignored, as are
+// events outside the segment, and events for regions without a handler.
+func Regions(widths []int, handlers ...func(bar.Event)) func(bar.Event) {
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	return func(e bar.Event) {
+		if e.Width <= 0 || total <= 0 || e.X < 0 || e.X >= e.Width {
+			return
+		}
+		start := 0
+		for i, w := range widths {
+			end := start + w
+			// Pixel boundaries are computed from the cumulative widths, so
+			// that rounding errors do not accumulate across regions.
+			startPx, endPx := start*e.Width/total, end*e.Width/total
+			if e.X < endPx {
+				if i < len(handlers) && handlers[i] != nil {
+					e.X -= startPx
+					e.Width = endPx - startPx
+					handlers[i](e)
+				}
+				return
+			}
+			start = end
+		}
+	}
+}
+
+// Split divides a segment into equal regions, one for each handler, and
+// routes click events to the handler for the region that was clicked.
+// See Regions for details.
+func Split(handlers ...func(bar.Event)) func(bar.Event) {
+	widths := make([]int, len(handlers))
+	for i := range widths {
+		widths[i] = 1
+	}
+	return Regions(widths, handlers...)
+}
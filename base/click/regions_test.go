@@ -0,0 +1,73 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package click
+
+import (
+	"testing"
+
+	"barista.run/bar"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegions(t *testing.T) {
+	events := make(chan string, 10)
+	record := func(name string) func(bar.Event) {
+		return func(e bar.Event) {
+			events <- name
+			require.True(t, e.X >= 0 && e.X < e.Width,
+				"X (%d) adjusted to within region (%d)", e.X, e.Width)
+		}
+	}
+	next := func() string {
+		select {
+		case name := <-events:
+			return name
+		default:
+			return ""
+		}
+	}
+
+	handler := Regions([]int{1, 2, 1}, record("prev"), record("play"), record("next"))
+	for x, expected := range map[int]string{
+		0: "prev", 24: "prev", 25: "play", 74: "play", 75: "next", 99: "next",
+	} {
+		handler(bar.Event{Button: bar.ButtonLeft, X: x, Width: 100})
+		require.Equal(t, expected, next(), "click at %d", x)
+	}
+
+	handler(bar.Event{Button: bar.ButtonLeft, X: 20})
+	require.Empty(t, next(), "without width")
+	handler(bar.Event{Button: bar.ButtonLeft, X: 120, Width: 100})
+	require.Empty(t, next(), "outside the segment")
+
+	handler = Regions([]int{1, 1, 1}, record("a"), nil)
+	handler(bar.Event{Button: bar.ButtonLeft, X: 5, Width: 30})
+	require.Equal(t, "a", next())
+	handler(bar.Event{Button: bar.ButtonLeft, X: 15, Width: 30})
+	require.Empty(t, next(), "nil handler")
+	handler(bar.Event{Button: bar.ButtonLeft, X: 25, Width: 30})
+	require.Empty(t, next(), "missing handler")
+}
+
+func TestSplit(t *testing.T) {
+	var widths []int
+	record := func(e bar.Event) { widths = append(widths, e.Width) }
+	handler := Split(record, record, record)
+	for _, x := range []int{0, 40, 80} {
+		handler(bar.Event{Button: bar.ScrollUp, X: x, Width: 100})
+	}
+	require.Equal(t, []int{33, 33, 34}, widths,
+		"regions cover the complete segment")
+}
@@ -0,0 +1,384 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpclient provides an HTTP client for modules that fetch data from
+// web services. Responses are cached and revalidated using ETag and
+// Last-Modified, requests are rate limited per host, and the last good
+// response can be served when a request fails (offline mode). While the
+// machine has no connectivity, requests fail immediately instead of waiting
+// for a timeout. While the bar is paused, requests wait until it resumes.
+//
+// The cache and rate limits are shared by all clients, so that multiple
+// modules using the same service share a single quota.
+package httpclient // import "barista.run/base/httpclient"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"barista.run/base/watchers/connectivity"
+	"barista.run/timing"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultTimeout is the timeout for clients created by this package.
+const DefaultTimeout = 30 * time.Second
+
+// Overridden in tests.
+var requestTimeout = DefaultTimeout
+
+// ErrOffline is returned for requests made while the machine is offline.
+var ErrOffline = errors.New("network is not available")
+
+// maxEntries is the maximum number of cached responses, after which the
+// oldest responses are evicted.
+const maxEntries = 500
+
+type entry struct {
+	header  http.Header
+	body    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+var (
+	mu       sync.Mutex
+	cache    = map[string]*entry{}
+	limits   = map[string]*rate.Limiter{}
+	defLimit = rate.Every(time.Second)
+	defBurst = 10
+)
+
+// RateLimit sets the rate limit for requests to the given host, which should
+// not include the port. By default, each host is limited to one request per
+// second, with bursts of up to 10 requests.
+func RateLimit(host string, limit rate.Limit, burst int) {
+	mu.Lock()
+	defer mu.Unlock()
+	limits[host] = rate.NewLimiter(limit, burst)
+}
+
+func limiterFor(host string) *rate.Limiter {
+	mu.Lock()
+	defer mu.Unlock()
+	l, ok := limits[host]
+	if !ok {
+		l = rate.NewLimiter(defLimit, defBurst)
+		limits[host] = l
+	}
+	return l
+}
+
+// TestMode clears the cache and removes all rate limits. For tests.
+func TestMode() {
+	mu.Lock()
+	defer mu.Unlock()
+	cache = map[string]*entry{}
+	limits = map[string]*rate.Limiter{}
+	defLimit = rate.Inf
+}
+
+// Transport is an http.RoundTripper that adds caching, revalidation, and rate
+// limiting to an underlying transport.
+type Transport struct {
+	base       http.RoundTripper
+	serveStale bool
+}
+
+// NewTransport creates a caching, rate limited transport that sends requests
+// using the given transport. If base is nil, http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{base: base}
+}
+
+// ServeStale configures the transport to return the last good response if a
+// request fails or the server returns an error. Stale responses can be
+// identified using IsStale.
+func (t *Transport) ServeStale(serveStale bool) *Transport {
+	t.serveStale = serveStale
+	return t
+}
+
+// Client returns an http.Client that uses this transport, with the default
+// timeout. The timeout is applied by the transport, and does not include the
+// time spent waiting for the bar to resume, so that requests made just before
+// the bar is hidden do not fail when it is shown again.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// New creates an http.Client that uses a caching, rate limited transport, with
+// the default timeout.
+func New() *http.Client {
+	return NewTransport(nil).Client()
+}
+
+// staleWarning is added to stale responses, see RFC 7234 section 5.5.
+const staleWarning = `111 - "Revalidation Failed"`
+
+// IsStale returns true if the response was served from the cache because the
+// request failed.
+func IsStale(r *http.Response) bool {
+	return r.Header.Get("Warning") == staleWarning
+}
+
+func (t *Transport) roundTripper() http.RoundTripper {
+	if t.base != nil {
+		return t.base
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !cacheable(req) {
+		if offline(req) {
+			return nil, ErrOffline
+		}
+		if err := awaitResumed(req); err != nil {
+			return nil, err
+		}
+		req, done := withTimeout(req)
+		if err := limiterFor(req.URL.Hostname()).Wait(req.Context()); err != nil {
+			return done(nil, err)
+		}
+		return done(t.roundTripper().RoundTrip(req))
+	}
+	key := cacheKey(req)
+	e := get(key)
+	if e != nil && timing.Now().Before(e.expires) {
+		return e.response(req), nil
+	}
+	if offline(req) {
+		return t.stale(req, e, nil, ErrOffline)
+	}
+	if err := awaitResumed(req); err != nil {
+		return t.stale(req, e, nil, err)
+	}
+	req, done := withTimeout(req)
+	return done(t.revalidate(req, key, e))
+}
+
+// revalidate sends a request for a cacheable resource, conditional on the
+// cached entry e if there is one, and updates the cache from the response.
+func (t *Transport) revalidate(req *http.Request, key string, e *entry) (*http.Response, error) {
+	if err := limiterFor(req.URL.Hostname()).Wait(req.Context()); err != nil {
+		return t.stale(req, e, nil, err)
+	}
+	r := req
+	if e != nil {
+		r = req.Clone(req.Context())
+		if etag := e.header.Get("ETag"); etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		if lastMod := e.header.Get("Last-Modified"); lastMod != "" {
+			r.Header.Set("If-Modified-Since", lastMod)
+		}
+	}
+	resp, err := t.roundTripper().RoundTrip(r)
+	switch {
+	case err != nil || resp.StatusCode >= 500:
+		return t.stale(req, e, resp, err)
+	case resp.StatusCode == http.StatusNotModified && e != nil:
+		resp.Body.Close()
+		e = put(key, e.header, e.body, resp.Header)
+		return e.response(req), nil
+	case resp.StatusCode == http.StatusOK:
+		if hasDirective(resp.Header, "no-store") {
+			return resp, nil
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return t.stale(req, e, nil, err)
+		}
+		put(key, resp.Header, body, resp.Header)
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+	return resp, err
+}
+
+// stale returns the cached response in offline mode, and the original
+// response or error otherwise.
+func (t *Transport) stale(req *http.Request, e *entry, resp *http.Response, err error) (*http.Response, error) {
+	if e == nil || !t.serveStale {
+		return resp, err
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	r := e.response(req)
+	r.Header.Set("Warning", staleWarning)
+	return r, nil
+}
+
+// withTimeout returns a copy of req that is cancelled after the request
+// timeout, and a func that passes through the response, cancelling the timeout
+// once the response body is closed.
+func withTimeout(req *http.Request) (*http.Request, func(*http.Response, error) (*http.Response, error)) {
+	ctx, cancel := context.WithTimeout(req.Context(), requestTimeout)
+	return req.WithContext(ctx), func(resp *http.Response, err error) (*http.Response, error) {
+		if resp == nil {
+			cancel()
+			return resp, err
+		}
+		resp.Body = &cancelOnClose{resp.Body, cancel}
+		return resp, err
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// awaitResumed waits until the bar is resumed, so that modules polling a web
+// service do not make requests while the bar is hidden. Fresh cached responses
+// are still returned while the bar is paused.
+func awaitResumed(req *http.Request) error {
+	select {
+	case <-timing.Resumed():
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// offline returns true if the request cannot succeed because the machine is
+// offline. Requests to the local machine are always allowed. Limited
+// connectivity (e.g. a captive portal) does not fail requests, since hosts on
+// the local network may still be reachable.
+func offline(req *http.Request) bool {
+	host := req.URL.Hostname()
+	if host == "localhost" {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return false
+	}
+	return connectivity.Get() == connectivity.Offline
+}
+
+// cacheable returns true for requests that can use the cache. Conditional
+// requests made by the caller are sent as-is, so that the caller sees the
+// 304 responses it expects.
+func cacheable(req *http.Request) bool {
+	return req.Method == "GET" &&
+		req.Header.Get("Range") == "" &&
+		req.Header.Get("If-None-Match") == "" &&
+		req.Header.Get("If-Modified-Since") == ""
+}
+
+// cacheKey keys responses by the URL and the request headers that most often
+// affect the response. Authorization is included so that responses for one
+// account are never returned for another.
+func cacheKey(req *http.Request) string {
+	return strings.Join([]string{
+		req.URL.String(),
+		req.Header.Get("Authorization"),
+		req.Header.Get("Accept"),
+	}, "\n")
+}
+
+func get(key string) *entry {
+	mu.Lock()
+	defer mu.Unlock()
+	return cache[key]
+}
+
+// put stores a response, using the freshness information from the given
+// headers, which are the headers of the 304 response when revalidating.
+func put(key string, header http.Header, body []byte, fresh http.Header) *entry {
+	now := timing.Now()
+	e := &entry{header: header, body: body, stored: now, expires: expiry(now, fresh)}
+	mu.Lock()
+	defer mu.Unlock()
+	cache[key] = e
+	if len(cache) > maxEntries {
+		evictOldestLocked()
+	}
+	return e
+}
+
+func evictOldestLocked() {
+	var oldestKey string
+	var oldest time.Time
+	for k, e := range cache {
+		if oldestKey == "" || e.stored.Before(oldest) {
+			oldestKey, oldest = k, e.stored
+		}
+	}
+	delete(cache, oldestKey)
+}
+
+// expiry returns the time until which a response is fresh, based on the
+// Cache-Control and Expires headers. Responses without either are always
+// revalidated.
+func expiry(now time.Time, h http.Header) time.Time {
+	if hasDirective(h, "no-cache") || hasDirective(h, "no-store") {
+		return time.Time{}
+	}
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		d = strings.TrimSpace(d)
+		if strings.HasPrefix(d, "max-age=") {
+			if secs, err := strconv.Atoi(strings.TrimPrefix(d, "max-age=")); err == nil {
+				return now.Add(time.Duration(secs) * time.Second)
+			}
+		}
+	}
+	if exp, err := http.ParseTime(h.Get("Expires")); err == nil {
+		return exp
+	}
+	return time.Time{}
+}
+
+func hasDirective(h http.Header, directive string) bool {
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		if strings.TrimSpace(d) == directive {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *entry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/base/watchers/connectivity"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+type testServer struct {
+	*httptest.Server
+	sync.Mutex
+	requests    int
+	status      int
+	body        string
+	headers     map[string]string
+	lastRequest *http.Request
+}
+
+func newTestServer() *testServer {
+	s := &testServer{status: 200, headers: map[string]string{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Lock()
+		defer s.Unlock()
+		s.requests++
+		s.lastRequest = r
+		for k, v := range s.headers {
+			w.Header().Set(k, v)
+		}
+		etag := s.headers["ETag"]
+		if s.status == 200 && etag != "" && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(s.status)
+		fmt.Fprint(w, s.body)
+	}))
+	return s
+}
+
+func (s *testServer) set(status int, body string, headers map[string]string) {
+	s.Lock()
+	defer s.Unlock()
+	s.status, s.body, s.headers = status, body, headers
+}
+
+func (s *testServer) count() int {
+	s.Lock()
+	defer s.Unlock()
+	r := s.requests
+	s.requests = 0
+	return r
+}
+
+func fetch(t *testing.T, c *http.Client, url string) (*http.Response, string) {
+	r, err := c.Get(url)
+	require.NoError(t, err)
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	require.NoError(t, err)
+	return r, string(body)
+}
+
+func TestRevalidation(t *testing.T) {
+	TestMode()
+	timing.TestMode()
+	s := newTestServer()
+	defer s.Close()
+	c := New()
+
+	s.set(200, "foo", map[string]string{"ETag": `"1"`})
+	r, body := fetch(t, c, s.URL)
+	require.Equal(t, 200, r.StatusCode)
+	require.Equal(t, "foo", body)
+	require.Equal(t, 1, s.count())
+
+	r, body = fetch(t, c, s.URL)
+	require.Equal(t, 200, r.StatusCode, "304 served as cached 200")
+	require.Equal(t, "foo", body)
+	require.Equal(t, 1, s.count(), "revalidated")
+	require.Equal(t, `"1"`, s.lastRequest.Header.Get("If-None-Match"))
+
+	s.set(200, "bar", map[string]string{"ETag": `"2"`})
+	_, body = fetch(t, c, s.URL)
+	require.Equal(t, "bar", body, "updated response")
+
+	lastMod := "Mon, 02 Jan 2006 15:04:05 GMT"
+	s.set(200, "baz", map[string]string{"Last-Modified": lastMod})
+	fetch(t, c, s.URL+"/other")
+	fetch(t, c, s.URL+"/other")
+	require.Equal(t, lastMod, s.lastRequest.Header.Get("If-Modified-Since"))
+
+	req, _ := http.NewRequest("GET", s.URL, nil)
+	req.Header.Set("If-None-Match", `"0"`)
+	r, err := c.Do(req)
+	require.NoError(t, err)
+	r.Body.Close()
+	require.Equal(t, 200, r.StatusCode)
+	require.Equal(t, `"0"`, s.lastRequest.Header.Get("If-None-Match"),
+		"conditional requests from the caller are not modified")
+}
+
+func TestFreshness(t *testing.T) {
+	TestMode()
+	timing.TestMode()
+	s := newTestServer()
+	defer s.Close()
+	c := New()
+
+	s.set(200, "foo", map[string]string{"Cache-Control": "public, max-age=60"})
+	fetch(t, c, s.URL)
+	require.Equal(t, 1, s.count())
+
+	timing.AdvanceBy(59 * time.Second)
+	_, body := fetch(t, c, s.URL)
+	require.Equal(t, "foo", body)
+	require.Equal(t, 0, s.count(), "fresh response served from cache")
+
+	timing.AdvanceBy(time.Second)
+	fetch(t, c, s.URL)
+	require.Equal(t, 1, s.count(), "stale response refetched")
+
+	s.set(200, "bar", map[string]string{
+		"Expires": timing.Now().Add(time.Minute).UTC().Format(http.TimeFormat),
+	})
+	fetch(t, c, s.URL+"/expires")
+	fetch(t, c, s.URL+"/expires")
+	require.Equal(t, 1, s.count(), "fresh until expiry")
+
+	s.set(200, "baz", map[string]string{"Cache-Control": "no-store, max-age=60"})
+	fetch(t, c, s.URL+"/nostore")
+	fetch(t, c, s.URL+"/nostore")
+	require.Equal(t, 2, s.count(), "not cached")
+
+	req, _ := http.NewRequest("GET", s.URL, nil)
+	req.Header.Set("Authorization", "Bearer other")
+	s.set(200, "secret", nil)
+	r, err := c.Do(req)
+	require.NoError(t, err)
+	r.Body.Close()
+	require.Equal(t, 1, s.count(), "cache is per-authorization")
+}
+
+func TestServeStale(t *testing.T) {
+	TestMode()
+	timing.TestMode()
+	s := newTestServer()
+	defer s.Close()
+	c := New()
+	stale := NewTransport(nil).ServeStale(true).Client()
+
+	s.set(200, "foo", nil)
+	r, _ := fetch(t, stale, s.URL)
+	require.False(t, IsStale(r))
+
+	s.set(503, "unavailable", nil)
+	r, body := fetch(t, stale, s.URL)
+	require.Equal(t, 200, r.StatusCode)
+	require.Equal(t, "foo", body, "last good response on server error")
+	require.True(t, IsStale(r))
+
+	r, _ = fetch(t, c, s.URL)
+	require.Equal(t, 503, r.StatusCode, "without offline mode")
+
+	r, _ = fetch(t, stale, s.URL+"/uncached")
+	require.Equal(t, 503, r.StatusCode, "no previous response")
+
+	s.Close()
+	r, body = fetch(t, stale, s.URL)
+	require.Equal(t, "foo", body, "last good response on network error")
+	require.True(t, IsStale(r))
+
+	_, err := c.Get(s.URL)
+	require.Error(t, err, "without offline mode")
+}
+
+func TestRateLimit(t *testing.T) {
+	TestMode()
+	s := newTestServer()
+	defer s.Close()
+	c := New()
+	RateLimit("127.0.0.1", rate.Every(time.Hour), 2)
+
+	fetch(t, c, s.URL)
+	fetch(t, c, s.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest("GET", s.URL, nil)
+	_, err := c.Do(req.WithContext(ctx))
+	require.Error(t, err, "over rate limit")
+	require.Equal(t, 2, s.count())
+
+	RateLimit("127.0.0.1", rate.Every(time.Hour), 1)
+	s.set(200, "foo", map[string]string{"Cache-Control": "max-age=60"})
+	timing.TestMode()
+	fetch(t, c, s.URL+"/fresh")
+	_, body := fetch(t, c, s.URL+"/fresh")
+	require.Equal(t, "foo", body, "fresh responses do not count against limit")
+}
+
+func TestEviction(t *testing.T) {
+	TestMode()
+	timing.TestMode()
+	s := newTestServer()
+	defer s.Close()
+	c := New()
+
+	for i := 0; i <= maxEntries; i++ {
+		timing.AdvanceBy(time.Second)
+		fetch(t, c, fmt.Sprintf("%s/%d", s.URL, i))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, cache, maxEntries)
+	for k := range cache {
+		require.NotEqual(t, s.URL+"/0", k[:len(s.URL)+2], "oldest entry evicted")
+	}
+}
+
+type fakeTransport struct{ requests int }
+
+func (f *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.requests++
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader("remote")),
+		Request:    req,
+	}, nil
+}
+
+func TestOffline(t *testing.T) {
+	TestMode()
+	connectivity.SetForTest(connectivity.Online)
+	s := newTestServer()
+	defer s.Close()
+	remote := &fakeTransport{}
+	c := NewTransport(remote).Client()
+	stale := NewTransport(remote).ServeStale(true).Client()
+
+	_, body := fetch(t, stale, "https://example.com/foo")
+	require.Equal(t, "remote", body)
+	require.Equal(t, 1, remote.requests)
+
+	connectivity.SetForTest(connectivity.Offline)
+	_, err := c.Get("https://example.com/foo")
+	require.Error(t, err, "while offline")
+	require.Contains(t, err.Error(), ErrOffline.Error())
+
+	_, err = c.Post("https://example.com/bar", "text/plain", nil)
+	require.Error(t, err, "while offline")
+
+	r, body := fetch(t, stale, "https://example.com/foo")
+	require.Equal(t, "remote", body, "stale response while offline")
+	require.True(t, IsStale(r))
+	require.Equal(t, 1, remote.requests, "no requests while offline")
+
+	fetch(t, New(), s.URL)
+	require.Equal(t, 1, s.count(), "local requests while offline")
+
+	connectivity.SetForTest(connectivity.Unknown)
+	fetch(t, c, "https://example.com/foo")
+	require.Equal(t, 2, remote.requests, "requests when connectivity is unknown")
+
+	connectivity.SetForTest(connectivity.Limited)
+	fetch(t, c, "https://192.168.1.1/status")
+	require.Equal(t, 3, remote.requests, "requests with limited connectivity")
+}
+
+func TestPaused(t *testing.T) {
+	TestMode()
+	timing.TestMode()
+	s := newTestServer()
+	defer s.Close()
+	c := New()
+
+	s.set(200, "foo", map[string]string{"Cache-Control": "max-age=60"})
+	fetch(t, c, s.URL+"/fresh")
+	require.Equal(t, 1, s.count())
+
+	timing.Pause()
+	defer timing.Resume()
+	_, body := fetch(t, c, s.URL+"/fresh")
+	require.Equal(t, "foo", body, "fresh cached response while paused")
+
+	done := make(chan string)
+	go func() {
+		_, body := fetch(t, c, s.URL+"/other")
+		done <- body
+	}()
+	select {
+	case <-done:
+		require.Fail(t, "request made while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.Equal(t, 0, s.count(), "no requests while paused")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("POST", s.URL, nil)
+	cancel()
+	_, err := c.Do(req.WithContext(ctx))
+	require.Error(t, err, "cancelled while paused")
+
+	timing.Resume()
+	select {
+	case body := <-done:
+		require.Equal(t, "foo", body)
+	case <-time.After(time.Second):
+		require.Fail(t, "request not made on resume")
+	}
+	require.Equal(t, 1, s.count())
+}
+
+func TestTimeoutExcludesPause(t *testing.T) {
+	TestMode()
+	timing.TestMode()
+	s := newTestServer()
+	defer s.Close()
+	c := New()
+	defer func(d time.Duration) { requestTimeout = d }(requestTimeout)
+	requestTimeout = 50 * time.Millisecond
+
+	timing.Pause()
+	defer timing.Resume()
+	done := make(chan error)
+	go func() {
+		_, err := c.Get(s.URL + "/paused")
+		done <- err
+	}()
+	time.Sleep(2 * requestTimeout)
+	timing.Resume()
+	select {
+	case err := <-done:
+		require.NoError(t, err, "time spent paused is not part of the timeout")
+	case <-time.After(time.Second):
+		require.Fail(t, "request not made on resume")
+	}
+	require.Equal(t, 1, s.count())
+}
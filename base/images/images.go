@@ -0,0 +1,112 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package images provides helpers for displaying small images on the bar,
+// such as album art or weather icons.
+package images // import "barista.run/base/images"
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"sync"
+)
+
+// Scale scales an image to the given height, preserving its aspect ratio.
+// Pixels are averaged when scaling down, to keep small images legible.
+func Scale(img image.Image, height int) image.Image {
+	b := img.Bounds()
+	if height <= 0 || b.Empty() || b.Dy() == height {
+		return img
+	}
+	width := (b.Dx()*height + b.Dy()/2) / b.Dy()
+	if width < 1 {
+		width = 1
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := span(b.Min.Y, b.Dy(), y, height)
+		for x := 0; x < width; x++ {
+			x0, x1 := span(b.Min.X, b.Dx(), x, width)
+			out.Set(x, y, average(img, image.Rect(x0, y0, x1, y1)))
+		}
+	}
+	return out
+}
+
+// span returns the range of source pixels that map to the given pixel.
+func span(min, size, idx, count int) (start, end int) {
+	start = min + idx*size/count
+	end = min + (idx+1)*size/count
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+func average(img image.Image, rect image.Rectangle) color.Color {
+	var sr, sg, sb, sa, n uint64
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			sr, sg, sb, sa = sr+uint64(r), sg+uint64(g), sb+uint64(b), sa+uint64(a)
+			n++
+		}
+	}
+	return color.RGBA64{
+		R: uint16(sr / n), G: uint16(sg / n), B: uint16(sb / n), A: uint16(sa / n),
+	}
+}
+
+// Cache caches the results of rendering images, to avoid re-encoding the
+// same image each time the bar is redrawn. Images are cached by identity,
+// so they must not be modified once rendered. Images that cannot be used
+// as map keys are rendered each time.
+type Cache struct {
+	mu     sync.Mutex
+	render func(image.Image) string
+	size   int
+	keys   []image.Image
+	values map[image.Image]string
+}
+
+// NewCache creates a cache that holds the rendered output of up to size
+// images, evicting the oldest image when full.
+func NewCache(size int, render func(image.Image) string) *Cache {
+	return &Cache{
+		render: render,
+		size:   size,
+		values: map[image.Image]string{},
+	}
+}
+
+// Get returns the rendered output for the image, rendering it if needed.
+func (c *Cache) Get(img image.Image) string {
+	if !reflect.TypeOf(img).Comparable() {
+		return c.render(img)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.values[img]; ok {
+		return v
+	}
+	v := c.render(img)
+	if len(c.keys) >= c.size {
+		delete(c.values, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	c.keys = append(c.keys, img)
+	c.values[img] = v
+	return v
+}
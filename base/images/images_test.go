@@ -0,0 +1,88 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package images
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScale(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		img.Set(x, 0, color.NRGBA{0xff, 0, 0, 0xff})
+		img.Set(x, 1, color.NRGBA{0, 0, 0xff, 0xff})
+	}
+	require.Same(t, img, Scale(img, 2), "unchanged at same height")
+	require.Same(t, img, Scale(img, 0), "unchanged at invalid height")
+
+	small := Scale(img, 1)
+	require.Equal(t, image.Rect(0, 0, 2, 1), small.Bounds(), "keeps aspect ratio")
+	require.Equal(t, color.NRGBA{0x7f, 0, 0x7f, 0xff},
+		color.NRGBAModel.Convert(small.At(1, 0)), "averages pixels")
+
+	big := Scale(img, 4)
+	require.Equal(t, image.Rect(0, 0, 8, 4), big.Bounds())
+	require.Equal(t, color.NRGBA{0xff, 0, 0, 0xff}, big.At(7, 1))
+	require.Equal(t, color.NRGBA{0, 0, 0xff, 0xff}, big.At(0, 2))
+
+	sub := img.SubImage(image.Rect(1, 1, 4, 2))
+	require.Equal(t, image.Rect(0, 0, 6, 2), Scale(sub, 2).Bounds(),
+		"handles offset bounds")
+	require.Equal(t, color.NRGBA{0, 0, 0xff, 0xff}, Scale(sub, 2).At(5, 1))
+
+	tall := image.NewNRGBA(image.Rect(0, 0, 1, 10))
+	require.Equal(t, image.Rect(0, 0, 1, 2), Scale(tall, 2).Bounds(),
+		"at least one pixel wide")
+}
+
+// uncomparable is an image type that cannot be used as a map key.
+type uncomparable struct {
+	image.Image
+	_ []int
+}
+
+func TestCache(t *testing.T) {
+	rendered := 0
+	c := NewCache(2, func(img image.Image) string {
+		rendered++
+		return img.Bounds().String()
+	})
+	a := image.NewGray(image.Rect(0, 0, 1, 1))
+	b := image.NewGray(image.Rect(0, 0, 2, 2))
+	d := image.NewGray(image.Rect(0, 0, 1, 1))
+
+	require.Equal(t, "(0,0)-(1,1)", c.Get(a))
+	require.Equal(t, "(0,0)-(1,1)", c.Get(a))
+	require.Equal(t, 1, rendered, "cached on repeated use")
+
+	require.Equal(t, "(0,0)-(1,1)", c.Get(d))
+	require.Equal(t, 2, rendered, "cached by identity")
+
+	c.Get(b)
+	require.Equal(t, 3, rendered)
+	c.Get(a)
+	require.Equal(t, 4, rendered, "oldest image evicted")
+	c.Get(b)
+	require.Equal(t, 4, rendered)
+
+	u := uncomparable{Image: a}
+	require.Equal(t, "(0,0)-(1,1)", c.Get(u))
+	c.Get(u)
+	require.Equal(t, 6, rendered, "uncomparable images rendered each time")
+}
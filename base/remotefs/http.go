@@ -0,0 +1,95 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotefs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"barista.run/base/httpclient"
+)
+
+// client is used for all requests made by backends. Overridden in tests.
+var client = &http.Client{Timeout: httpclient.DefaultTimeout}
+
+// statusError is returned for unexpected HTTP responses.
+type statusError struct {
+	method, url string
+	status      int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("%s %s: HTTP %d", e.method, e.url, e.status)
+}
+
+// fetch performs a conditional GET request, after passing the request through
+// the given function for authentication.
+func fetch(url, etag string, auth func(*http.Request, []byte)) (*Object, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	auth(req, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, ErrNotModified
+	case http.StatusNotFound:
+		return nil, os.ErrNotExist
+	default:
+		return nil, statusError{"GET", url, resp.StatusCode}
+	}
+	obj := &Object{ETag: resp.Header.Get("ETag")}
+	if obj.Contents, err = ioutil.ReadAll(resp.Body); err != nil {
+		return nil, err
+	}
+	if lastMod, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		obj.ModTime = lastMod
+	} else {
+		obj.ModTime = time.Now()
+	}
+	return obj, nil
+}
+
+// do performs a request with the given body, returning the response
+// if the server responded with a 2xx status code.
+func do(method, url string, body []byte, auth func(*http.Request, []byte)) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	auth(req, body)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, statusError{method, url, resp.StatusCode}
+	}
+	return resp, nil
+}
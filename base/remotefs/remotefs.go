@@ -0,0 +1,220 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remotefs provides an afero filesystem backed by remote storage, such
+// as a WebDAV server or an S3-compatible object store, so that modules can read
+// and persist configuration or state on self-hosted storage.
+//
+// Fetched files are cached in memory and revalidated using their etags, and
+// files opened for writing are stored remotely when they are closed.
+package remotefs // import "barista.run/base/remotefs"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// ErrNotModified is returned by backends when a file has not changed since
+// it was last fetched.
+var ErrNotModified = errors.New("not modified")
+
+// Object is a file fetched from a backend.
+type Object struct {
+	Contents []byte
+	ETag     string
+	ModTime  time.Time
+}
+
+// Backend fetches and stores files on remote storage.
+type Backend interface {
+	// Fetch returns the file at the given path. If etag is not empty and the
+	// file has the same etag, it returns ErrNotModified. If the file does not
+	// exist, it returns an error for which os.IsNotExist is true.
+	Fetch(path, etag string) (*Object, error)
+	// Store writes the file at the given path, creating it if needed, and
+	// returns the etag of the new contents, if known.
+	Store(path string, contents []byte) (etag string, err error)
+}
+
+// Fs is an afero filesystem backed by remote storage.
+type Fs struct {
+	// readonly view into the backing fs.
+	afero.Fs
+	// backing mem-mapped fs.
+	backingFs afero.Fs
+	backend   Backend
+	maxAge    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	etag    string
+	fetched time.Time
+}
+
+// New creates a filesystem that reads files from, and writes files to, the
+// given backend.
+func New(backend Backend) *Fs {
+	backingFs := afero.NewMemMapFs()
+	return &Fs{
+		Fs:        afero.NewReadOnlyFs(backingFs),
+		backingFs: backingFs,
+		backend:   backend,
+		cache:     map[string]cacheEntry{},
+	}
+}
+
+// MaxAge sets the duration for which fetched files are used without checking
+// the backend for changes. By default, every read revalidates the file.
+func (f *Fs) MaxAge(maxAge time.Duration) *Fs {
+	f.maxAge = maxAge
+	return f
+}
+
+// clean returns the absolute form of a file name.
+func clean(name string) string {
+	return path.Clean("/" + name)
+}
+
+func (f *Fs) fetch(name string) error {
+	f.mu.Lock()
+	entry, cached := f.cache[name]
+	f.mu.Unlock()
+	if cached && time.Since(entry.fetched) < f.maxAge {
+		return nil
+	}
+	etag := ""
+	if cached {
+		etag = entry.etag
+	}
+	obj, err := f.backend.Fetch(name, etag)
+	if err == ErrNotModified {
+		f.setCache(name, cacheEntry{etag: etag, fetched: time.Now()})
+		return nil
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			f.backingFs.Remove(name)
+			f.mu.Lock()
+			delete(f.cache, name)
+			f.mu.Unlock()
+		}
+		return &os.PathError{Op: "fetch", Path: name, Err: err}
+	}
+	f.backingFs.MkdirAll(path.Dir(name), 0755)
+	if err := afero.WriteFile(f.backingFs, name, obj.Contents, 0644); err != nil {
+		return err
+	}
+	if !obj.ModTime.IsZero() {
+		f.backingFs.Chtimes(name, obj.ModTime, obj.ModTime)
+	}
+	f.setCache(name, cacheEntry{etag: obj.ETag, fetched: time.Now()})
+	return nil
+}
+
+func (f *Fs) setCache(name string, entry cacheEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cache[name] = entry
+}
+
+// Open opens a file, returning it or an error, if any happens.
+func (f *Fs) Open(name string) (afero.File, error) {
+	name = clean(name)
+	if err := f.fetch(name); err != nil {
+		return nil, err
+	}
+	return f.Fs.Open(name)
+}
+
+// OpenFile opens a file using the given flags and the given mode. Files opened
+// for writing are stored to the backend when closed.
+func (f *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_CREATE | os.O_TRUNC
+	if flag&writeFlags != 0 {
+		return f.openForWrite(clean(name), flag, perm)
+	}
+	name = clean(name)
+	if err := f.fetch(name); err != nil {
+		return nil, err
+	}
+	return f.Fs.OpenFile(name, flag, perm)
+}
+
+// Create creates a file, which will be stored to the backend when closed.
+func (f *Fs) Create(name string) (afero.File, error) {
+	return f.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+// Stat returns a FileInfo describing the named file, or an error, if any
+// happens.
+func (f *Fs) Stat(name string) (os.FileInfo, error) {
+	name = clean(name)
+	if err := f.fetch(name); err != nil {
+		return nil, err
+	}
+	return f.Fs.Stat(name)
+}
+
+// Name returns the name of this FileSystem.
+func (f *Fs) Name() string {
+	return fmt.Sprintf("RemoteFS/%T", f.backend)
+}
+
+func (f *Fs) openForWrite(name string, flag int, perm os.FileMode) (afero.File, error) {
+	// Fetch the existing contents, unless they will be discarded anyway.
+	if flag&os.O_TRUNC == 0 {
+		err := f.fetch(name)
+		if err != nil && (!os.IsNotExist(err) || flag&os.O_CREATE == 0) {
+			return nil, err
+		}
+	}
+	f.backingFs.MkdirAll(path.Dir(name), 0755)
+	file, err := f.backingFs.OpenFile(name, flag|os.O_CREATE, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &writeFile{File: file, fs: f, name: name}, nil
+}
+
+// writeFile is a file that is stored to the backend when closed.
+type writeFile struct {
+	afero.File
+	fs   *Fs
+	name string
+}
+
+func (w *writeFile) Close() error {
+	if err := w.File.Close(); err != nil {
+		return err
+	}
+	contents, err := afero.ReadFile(w.fs.backingFs, w.name)
+	if err != nil {
+		return err
+	}
+	etag, err := w.fs.backend.Store(w.name, contents)
+	if err != nil {
+		return &os.PathError{Op: "store", Path: w.name, Err: err}
+	}
+	w.fs.setCache(w.name, cacheEntry{etag: etag, fetched: time.Now()})
+	return nil
+}
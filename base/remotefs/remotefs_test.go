@@ -0,0 +1,138 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotefs
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend stores files in memory, using a version counter as the etag.
+type fakeBackend struct {
+	sync.Mutex
+	files   map[string]string
+	etags   map[string]string
+	fetches int
+	version int
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{files: map[string]string{}, etags: map[string]string{}}
+}
+
+func (b *fakeBackend) set(name, contents string) string {
+	b.version++
+	b.files[name] = contents
+	b.etags[name] = fmt.Sprintf(`"v%d"`, b.version)
+	return b.etags[name]
+}
+
+func (b *fakeBackend) Fetch(name, etag string) (*Object, error) {
+	b.Lock()
+	defer b.Unlock()
+	b.fetches++
+	contents, ok := b.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	if etag == b.etags[name] {
+		return nil, ErrNotModified
+	}
+	return &Object{Contents: []byte(contents), ETag: b.etags[name]}, nil
+}
+
+func (b *fakeBackend) Store(name string, contents []byte) (string, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.set(name, string(contents)), nil
+}
+
+func (b *fakeBackend) fetchCount() int {
+	b.Lock()
+	defer b.Unlock()
+	return b.fetches
+}
+
+func TestFs(t *testing.T) {
+	b := newFakeBackend()
+	b.set("/config/foo", "bar")
+	fs := New(b)
+	require.Contains(t, fs.Name(), "RemoteFS")
+
+	_, err := fs.Open("/missing")
+	require.True(t, os.IsNotExist(err), "missing file: %v", err)
+
+	contents, err := afero.ReadFile(fs, "config/foo")
+	require.NoError(t, err)
+	require.Equal(t, "bar", string(contents))
+
+	b.Lock()
+	b.set("/config/foo", "baz")
+	b.Unlock()
+	contents, err = afero.ReadFile(fs, "/config/foo")
+	require.NoError(t, err)
+	require.Equal(t, "baz", string(contents), "picks up remote changes")
+
+	b.Lock()
+	delete(b.files, "/config/foo")
+	b.Unlock()
+	_, err = fs.Stat("/config/foo")
+	require.True(t, os.IsNotExist(err), "removed remotely: %v", err)
+
+	require.Error(t, fs.Remove("/config/foo"), "fs is otherwise read-only")
+}
+
+func TestMaxAge(t *testing.T) {
+	b := newFakeBackend()
+	b.set("/foo", "bar")
+	fs := New(b).MaxAge(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		contents, err := afero.ReadFile(fs, "/foo")
+		require.NoError(t, err)
+		require.Equal(t, "bar", string(contents))
+	}
+	require.Equal(t, 1, b.fetchCount(), "cached within max age")
+}
+
+func TestWrite(t *testing.T) {
+	b := newFakeBackend()
+	fs := New(b)
+
+	require.NoError(t, afero.WriteFile(fs, "/state/count", []byte("1"), 0644))
+	require.Equal(t, "1", b.files["/state/count"])
+
+	f, err := fs.OpenFile("/state/count", os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("2"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "12", b.files["/state/count"])
+
+	fetches := b.fetchCount()
+	contents, err := afero.ReadFile(fs, "/state/count")
+	require.NoError(t, err)
+	require.Equal(t, "12", string(contents))
+	require.Equal(t, fetches+1, b.fetchCount())
+
+	_, err = fs.OpenFile("/state/other", os.O_WRONLY, 0644)
+	require.True(t, os.IsNotExist(err), "write without create: %v", err)
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotefs
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// WebDAVBackend stores files on a WebDAV server.
+type WebDAVBackend struct {
+	baseURL            string
+	username, password string
+}
+
+// WebDAV creates a backend that stores files under the given WebDAV URL.
+func WebDAV(baseURL string) *WebDAVBackend {
+	return &WebDAVBackend{baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Auth sets the credentials used for HTTP basic authentication.
+func (w *WebDAVBackend) Auth(username, password string) *WebDAVBackend {
+	w.username, w.password = username, password
+	return w
+}
+
+func (w *WebDAVBackend) auth(req *http.Request, body []byte) {
+	if w.username != "" || w.password != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+}
+
+func (w *WebDAVBackend) url(name string) string {
+	return w.baseURL + path.Clean("/"+name)
+}
+
+// Fetch implements Backend.
+func (w *WebDAVBackend) Fetch(name, etag string) (*Object, error) {
+	return fetch(w.url(name), etag, w.auth)
+}
+
+// Store implements Backend. Missing parent collections are created as needed.
+func (w *WebDAVBackend) Store(name string, contents []byte) (string, error) {
+	resp, err := do("PUT", w.url(name), contents, w.auth)
+	if resp != nil && resp.StatusCode == http.StatusConflict {
+		if err = w.mkdirAll(path.Dir(path.Clean("/" + name))); err != nil {
+			return "", err
+		}
+		resp, err = do("PUT", w.url(name), contents, w.auth)
+	}
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// mkdirAll creates the given collection and all of its parents.
+func (w *WebDAVBackend) mkdirAll(dir string) error {
+	if dir == "/" {
+		return nil
+	}
+	parts := strings.Split(strings.Trim(dir, "/"), "/")
+	for i := range parts {
+		resp, err := do("MKCOL", w.url(strings.Join(parts[:i+1], "/"))+"/", nil, w.auth)
+		// 405 Method Not Allowed means that the collection already exists.
+		if err != nil && (resp == nil || resp.StatusCode != http.StatusMethodNotAllowed) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotefs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWebDAV is a minimal WebDAV server that supports GET, PUT and MKCOL.
+type fakeWebDAV struct {
+	sync.Mutex
+	files map[string]string
+	dirs  map[string]bool
+	etag  int
+}
+
+func (d *fakeWebDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.Lock()
+	defer d.Unlock()
+	if user, pass, _ := r.BasicAuth(); user != "user" || pass != "hunter2" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/dav")
+	switch r.Method {
+	case "GET":
+		contents, ok := d.files[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		etag := fmt.Sprintf(`"%x"`, len(contents))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(contents))
+	case "PUT":
+		if !d.dirs[path.Dir(name)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		d.files[name] = string(body)
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, len(body)))
+		w.WriteHeader(http.StatusCreated)
+	case "MKCOL":
+		name = strings.TrimSuffix(name, "/")
+		if d.dirs[name] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !d.dirs[path.Dir(name)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		d.dirs[name] = true
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestWebDAV(t *testing.T) {
+	dav := &fakeWebDAV{
+		files: map[string]string{"/a/config": "foo"},
+		dirs:  map[string]bool{"/": true, "/a": true},
+	}
+	ts := httptest.NewServer(dav)
+	defer ts.Close()
+
+	_, err := afero.ReadFile(New(WebDAV(ts.URL+"/dav")), "/a/config")
+	require.Error(t, err, "without credentials")
+
+	fs := New(WebDAV(ts.URL+"/dav/").Auth("user", "hunter2"))
+	contents, err := afero.ReadFile(fs, "/a/config")
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(contents))
+
+	require.NoError(t, afero.WriteFile(fs, "/a/b/c/state", []byte("bar"), 0644))
+	dav.Lock()
+	require.Equal(t, "bar", dav.files["/a/b/c/state"])
+	require.True(t, dav.dirs["/a/b"])
+	dav.Unlock()
+
+	require.NoError(t, afero.WriteFile(fs, "/a/config", []byte("baz"), 0644))
+	contents, err = afero.ReadFile(fs, "/a/config")
+	require.NoError(t, err)
+	require.Equal(t, "baz", string(contents))
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package value
+
+import (
+	"sync"
+
+	"barista.run/base/notifier"
+)
+
+// Derived returns a value that is computed from the given source values using
+// fn, and is recomputed whenever any of the sources change. fn is called with
+// the current value of each source, in order. The returned function stops
+// tracking changes to the sources.
+func Derived(fn func(values ...interface{}) interface{}, sources ...*Value) (*Value, func()) {
+	derived := new(Value)
+	compute := func() {
+		values := make([]interface{}, len(sources))
+		for i, s := range sources {
+			values[i] = s.Get()
+		}
+		derived.Set(fn(values...))
+	}
+	changes, stop := watch(sources...)
+	compute()
+	go func() {
+		for range changes {
+			compute()
+		}
+	}()
+	return derived, stop
+}
+
+// Map returns a value that holds the result of applying fn to the source
+// value, and is updated whenever the source changes.
+func Map(source *Value, fn func(interface{}) interface{}) (*Value, func()) {
+	return Derived(func(values ...interface{}) interface{} {
+		return fn(values[0])
+	}, source)
+}
+
+// Combine returns a value that holds a []interface{} of the current values of
+// all the sources, and is updated whenever any of them change.
+func Combine(sources ...*Value) (*Value, func()) {
+	return Derived(func(values ...interface{}) interface{} {
+		return values
+	}, sources...)
+}
+
+// watch returns a channel that receives an empty struct{} whenever any of the
+// given values change, and is closed when the returned function is called.
+func watch(sources ...*Value) (<-chan struct{}, func()) {
+	notifyFn, notifyCh := notifier.New()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, s := range sources {
+		sub, unsub := s.Subscribe()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer unsub()
+			for {
+				select {
+				case <-sub:
+					notifyFn()
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	changes := make(chan struct{})
+	go func() {
+		defer close(changes)
+		for {
+			select {
+			case <-notifyCh:
+			case <-done:
+				wg.Wait()
+				return
+			}
+			select {
+			case changes <- struct{}{}:
+			case <-done:
+				wg.Wait()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return changes, func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package value
+
+import (
+	"testing"
+
+	"barista.run/testing/notifier"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDerived(t *testing.T) {
+	var a, b Value
+	a.Set(1)
+	b.Set("x")
+	d, stop := Derived(func(values ...interface{}) interface{} {
+		return values[1].(string) + string(rune('0'+values[0].(int)))
+	}, &a, &b)
+	require.Equal(t, "x1", d.Get(), "initial value")
+
+	sub, done := d.Subscribe()
+	defer done()
+	a.Set(2)
+	notifier.AssertNotified(t, sub, "on first source change")
+	require.Equal(t, "x2", d.Get())
+
+	b.Set("y")
+	notifier.AssertNotified(t, sub, "on second source change")
+	require.Equal(t, "y2", d.Get())
+
+	stop()
+	stop()
+	a.Set(3)
+	notifier.AssertNoUpdate(t, sub, "after stop")
+	require.Equal(t, "y2", d.Get())
+}
+
+func TestMapCombine(t *testing.T) {
+	var a, b Value
+	a.Set(2)
+	b.Set(false)
+	m, stopMap := Map(&a, func(v interface{}) interface{} { return v.(int) * 10 })
+	defer stopMap()
+	c, stopCombine := Combine(&a, &b)
+	defer stopCombine()
+	require.Equal(t, 20, m.Get())
+	require.Equal(t, []interface{}{2, false}, c.Get())
+
+	mSub, mDone := m.Subscribe()
+	defer mDone()
+	cSub, cDone := c.Subscribe()
+	defer cDone()
+	a.Set(3)
+	notifier.AssertNotified(t, mSub)
+	notifier.AssertNotified(t, cSub)
+	require.Equal(t, 30, m.Get())
+	require.Equal(t, []interface{}{3, false}, c.Get())
+
+	b.Set(true)
+	notifier.AssertNotified(t, cSub)
+	notifier.AssertNoUpdate(t, mSub, "unrelated source")
+	require.Equal(t, []interface{}{3, true}, c.Get())
+}
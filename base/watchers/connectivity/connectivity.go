@@ -0,0 +1,255 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package connectivity watches the network connectivity of the machine, so
+// that modules that fetch data from the internet can stop polling while
+// offline, and refresh as soon as connectivity is restored.
+//
+// Connectivity is read from NetworkManager if it is running, and otherwise
+// derived from the state of the network links using netlink.
+package connectivity // import "barista.run/base/watchers/connectivity"
+
+import (
+	"sync"
+
+	"barista.run/base/value"
+	"barista.run/base/watchers/dbus"
+	"barista.run/base/watchers/netlink"
+	l "barista.run/logging"
+)
+
+// State represents the connectivity state of the machine.
+type State int
+
+const (
+	// Unknown means that connectivity could not be determined. Modules
+	// should assume that the network is available.
+	Unknown State = iota
+	// Offline means that the machine is not connected to any network.
+	Offline
+	// Limited means that the machine is connected to a network, but cannot
+	// reach the internet, e.g. behind a captive portal.
+	Limited
+	// Online means that the machine has full internet access.
+	Online
+)
+
+func (s State) String() string {
+	switch s {
+	case Offline:
+		return "offline"
+	case Limited:
+		return "limited"
+	case Online:
+		return "online"
+	}
+	return "unknown"
+}
+
+// Available returns true if requests to the internet are expected to
+// succeed, i.e. the machine is online or the state is unknown.
+func (s State) Available() bool {
+	return s == Online || s == Unknown
+}
+
+var (
+	once    sync.Once
+	current value.Value // of State
+)
+
+func init() {
+	current.Set(Unknown)
+}
+
+// Get returns the current connectivity state.
+func Get() State {
+	once.Do(start)
+	return current.Get().(State)
+}
+
+// Next returns a channel that will be closed on the next change in
+// connectivity.
+func Next() <-chan struct{} {
+	once.Do(start)
+	return current.Next()
+}
+
+// SetForTest sets the connectivity state in tests, and prevents the real
+// connectivity watcher from starting.
+func SetForTest(s State) {
+	once.Do(func() {})
+	current.Set(s)
+}
+
+const (
+	nmService = "org.freedesktop.NetworkManager"
+	nmPath    = "/org/freedesktop/NetworkManager"
+	nmIface   = "org.freedesktop.NetworkManager"
+)
+
+// Overridden in tests.
+var busType = dbus.System
+
+func start() {
+	go watch(watchNetworkManager(), netlink.All())
+}
+
+// watchNetworkManager returns a watcher for the NetworkManager connectivity,
+// or nil if the system bus is not available.
+func watchNetworkManager() (w *dbus.PropertiesWatcher) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.Log("Not using NetworkManager for connectivity: %v", r)
+			w = nil
+		}
+	}()
+	return dbus.WatchProperties(busType, nmService, nmPath, nmIface).
+		Add("Connectivity")
+}
+
+func watch(nm *dbus.PropertiesWatcher, links netlink.MultiSubscription) {
+	var nmUpdates <-chan dbus.PropertiesChange
+	if nm != nil {
+		nmUpdates = nm.Updates
+		defer nm.Unsubscribe()
+	}
+	for {
+		next := links.Next()
+		s := fromNetworkManager(nm)
+		if s == Unknown {
+			s = fromLinks(links.Get())
+		}
+		if s != current.Get().(State) {
+			l.Log("Connectivity changed to %v", s)
+			current.Set(s)
+		}
+		select {
+		case <-nmUpdates:
+		case <-next:
+		}
+	}
+}
+
+// fromNetworkManager converts the NMConnectivityState of NetworkManager, see
+// https://developer.gnome.org/NetworkManager/stable/nm-dbus-types.html.
+func fromNetworkManager(nm *dbus.PropertiesWatcher) State {
+	if nm == nil {
+		return Unknown
+	}
+	c, _ := nm.Get()["Connectivity"].(uint32)
+	switch c {
+	case 1:
+		return Offline
+	case 2, 3:
+		return Limited
+	case 4:
+		return Online
+	}
+	return Unknown
+}
+
+// fromLinks returns Online if any link other than loopback is up and has a
+// global unicast address. Links in an unknown state are included, since some
+// virtual links (e.g. VPNs) do not report their state. Links that only connect
+// the machine to itself, such as the bridges and veth pairs used by
+// containers and virtual machines, are not counted.
+func fromLinks(links []netlink.Link) State {
+	if len(links) == 0 {
+		// At least the loopback link is always present, so no links means
+		// that the netlink watcher failed.
+		return Unknown
+	}
+	for _, link := range links {
+		if link.State != netlink.Up && link.State != netlink.Unknown {
+			continue
+		}
+		if isLocal(link, links) {
+			continue
+		}
+		for _, ip := range link.IPs {
+			if ip.IsGlobalUnicast() {
+				return Online
+			}
+		}
+	}
+	return Offline
+}
+
+// localPorts are kinds of links that do not lead off the machine when used
+// as the ports of a bridge.
+var localPorts = map[string]bool{
+	"veth":  true,
+	"dummy": true,
+	"tun":   true, // Also used for taps, e.g. the ports of virbr0.
+}
+
+// isLocal returns true if a link does not connect the machine to a network:
+// links enslaved to a bridge or bond (the addresses are on the master), veth
+// pairs, and bridges without any port that leads off the machine (docker0,
+// virbr0, and so on).
+func isLocal(link netlink.Link, links []netlink.Link) bool {
+	switch {
+	case link.Master != "":
+		return true
+	case link.Kind == "veth", link.Kind == "dummy":
+		return true
+	case link.Kind != "bridge":
+		return false
+	}
+	for _, port := range links {
+		if port.Master == link.Name && !localPorts[port.Kind] {
+			return false
+		}
+	}
+	return true
+}
+
+// Gate gates the refresh ticks of a module (e.g. a scheduler's C) on
+// connectivity. The returned channel receives ticks from c while the network
+// is available, drops them while offline, and also receives when connectivity
+// is restored, so that the module refreshes immediately instead of waiting
+// for the next tick. The returned func stops gating, and is usually `defer`d.
+func Gate(c <-chan struct{}) (<-chan struct{}, func()) {
+	out := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		next := Next()
+		available := Get().Available()
+		for {
+			select {
+			case <-c:
+				if !available {
+					l.Fine("Skipping refresh while offline")
+					continue
+				}
+			case <-next:
+				next = Next()
+				wasAvailable := available
+				available = Get().Available()
+				if wasAvailable || !available {
+					continue
+				}
+			case <-done:
+				return
+			}
+			select {
+			case out <- struct{}{}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	var stop sync.Once
+	return out, func() { stop.Do(func() { close(done) }) }
+}
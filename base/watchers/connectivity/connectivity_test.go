@@ -0,0 +1,158 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connectivity
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"barista.run/base/watchers/dbus"
+	"barista.run/base/watchers/netlink"
+
+	"github.com/stretchr/testify/require"
+)
+
+func assertState(t *testing.T, next <-chan struct{}, expected State, msg string) <-chan struct{} {
+	for {
+		if Get() == expected {
+			return Next()
+		}
+		select {
+		case <-next:
+			next = Next()
+		case <-time.After(time.Second):
+			require.Fail(t, "state did not change", "%s: expected %v, got %v", msg, expected, Get())
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	SetForTest(Unknown)
+	next := Next()
+	busType = dbus.Test
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService(nmService)
+	obj := srv.Object(nmPath, nmIface)
+	obj.SetProperties(map[string]interface{}{
+		"Connectivity": uint32(4),
+	}, dbus.SignalTypeNone)
+
+	links := netlink.TestMode()
+	lo := links.AddLink(netlink.Link{Name: "lo", State: netlink.Unknown})
+	links.AddIP(lo, net.IPv4(127, 0, 0, 1))
+	go watch(watchNetworkManager(), netlink.All())
+	next = assertState(t, next, Online, "from NetworkManager")
+
+	obj.SetProperty("Connectivity", uint32(2))
+	next = assertState(t, next, Limited, "behind captive portal")
+
+	obj.SetProperty("Connectivity", uint32(1))
+	next = assertState(t, next, Offline, "no network")
+
+	wlan := links.AddLink(netlink.Link{Name: "wlan0", State: netlink.Up})
+	links.AddIP(wlan, net.IPv4(192, 168, 1, 100))
+	obj.SetProperty("Connectivity", uint32(0))
+	next = assertState(t, next, Online, "from links if unknown in NetworkManager")
+
+	srv.Unregister()
+	require.Equal(t, Online, Get(), "from links without NetworkManager")
+
+	links.UpdateLink(wlan, netlink.Link{State: netlink.Down})
+	next = assertState(t, next, Offline, "link down")
+
+	srv = bus.RegisterService(nmService)
+	srv.Object(nmPath, nmIface).SetProperties(map[string]interface{}{
+		"Connectivity": uint32(4),
+	}, dbus.SignalTypeNone)
+	assertState(t, next, Online, "NetworkManager restarted")
+}
+
+func TestFromLinks(t *testing.T) {
+	require.Equal(t, Unknown, fromLinks(nil))
+	require.Equal(t, Offline, fromLinks([]netlink.Link{
+		{Name: "lo", State: netlink.Unknown, IPs: []net.IP{net.IPv6loopback}},
+		{Name: "wlan0", State: netlink.Up, IPs: []net.IP{net.ParseIP("fe80::1")}},
+	}), "link-local addresses are ignored")
+	require.Equal(t, Online, fromLinks([]netlink.Link{
+		{Name: "tun0", State: netlink.Unknown, IPs: []net.IP{net.ParseIP("10.8.0.2")}},
+	}), "links in unknown state are included")
+
+	docker0 := netlink.Link{Name: "docker0", State: netlink.Up, Kind: "bridge",
+		IPs: []net.IP{net.ParseIP("172.17.0.1")}}
+	veth := netlink.Link{Name: "veth1a2b", State: netlink.Up, Kind: "veth",
+		Master: "docker0", IPs: []net.IP{net.ParseIP("172.17.0.2")}}
+	require.Equal(t, Offline, fromLinks([]netlink.Link{docker0, veth}),
+		"container bridges and veth links are ignored")
+	require.Equal(t, Offline, fromLinks([]netlink.Link{
+		{Name: "veth0", State: netlink.Up, Kind: "veth", IPs: []net.IP{net.ParseIP("10.0.3.1")}},
+	}), "veth links are ignored")
+	require.Equal(t, Online, fromLinks([]netlink.Link{
+		{Name: "br0", State: netlink.Up, Kind: "bridge", IPs: []net.IP{net.ParseIP("192.168.1.2")}},
+		{Name: "eth0", State: netlink.Up, Master: "br0"},
+	}), "bridges with a physical port are included")
+
+	require.True(t, Unknown.Available())
+	require.False(t, Limited.Available())
+	require.Equal(t, "limited", Limited.String())
+	require.Equal(t, "unknown", State(42).String())
+}
+
+func TestGate(t *testing.T) {
+	SetForTest(Online)
+	ticks := make(chan struct{})
+	gated, done := Gate(ticks)
+	defer done()
+
+	assertTick := func(msg string) {
+		select {
+		case <-gated:
+		case <-time.After(time.Second):
+			require.Fail(t, "no tick", msg)
+		}
+	}
+	assertNoTick := func(msg string) {
+		select {
+		case <-gated:
+			require.Fail(t, "unexpected tick", msg)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	ticks <- struct{}{}
+	assertTick("while online")
+
+	SetForTest(Offline)
+	assertNoTick("on going offline")
+	ticks <- struct{}{}
+	assertNoTick("while offline")
+
+	SetForTest(Limited)
+	assertNoTick("limited connectivity")
+
+	SetForTest(Online)
+	assertTick("on connectivity restored")
+	assertNoTick("only once")
+
+	SetForTest(Unknown)
+	assertNoTick("unknown after online")
+
+	done()
+	select {
+	case ticks <- struct{}{}:
+		require.Fail(t, "tick consumed after done")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
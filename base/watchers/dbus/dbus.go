@@ -12,8 +12,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package dbus provides watchers that notify when dbus name owners or object
-// properties change, and infrastructure for testing code that uses them.
+// Package dbus provides watchers that notify when dbus name owners, objects, or
+// object properties change, or when signals are emitted, and infrastructure
+// for testing code that uses them.
 package dbus // import "barista.run/base/watchers/dbus"
 
 import (
@@ -71,8 +72,12 @@ var (
 	listNames        = dbusName{bus, "ListNames"}
 	getNameOwner     = dbusName{bus, "GetNameOwner"}
 	nameOwnerChanged = dbusName{bus, "NameOwnerChanged"}
+	requestName      = dbusName{bus, "RequestName"}
+	releaseName      = dbusName{bus, "ReleaseName"}
 
 	propsChanged = dbusName{props, "PropertiesChanged"}
+	getAllProps  = dbusName{props, "GetAll"}
+	setProp      = dbusName{props, "Set"}
 )
 
 // dbusName represents a DBus name, specifying an interface and member pair.
@@ -0,0 +1,46 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbus
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// RequestName acquires a well-known name on the bus, for services that expect
+// callers to identify themselves by name (e.g. StatusNotifierHost). It returns
+// an error if the name is already owned, and otherwise a function that
+// releases the name.
+func RequestName(busType BusType, name string) (release func(), err error) {
+	conn := busType()
+	var reply uint32
+	err = requestName.call(conn, name, uint32(dbus.NameFlagDoNotQueue)).Store(&reply)
+	if err == nil && dbus.RequestNameReply(reply) != dbus.RequestNameReplyPrimaryOwner {
+		err = fmt.Errorf("name %s is already owned", name)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			releaseName.call(conn, name)
+			conn.Close()
+		})
+	}, nil
+}
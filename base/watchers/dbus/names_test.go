@@ -0,0 +1,52 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestName(t *testing.T) {
+	SetupTestBus()
+	w := WatchNameOwner(Test, "org.i3barista.test.Host")
+	defer w.Unsubscribe()
+	assertNoUpdate(t, w.Updates, "on start")
+
+	release, err := RequestName(Test, "org.i3barista.test.Host")
+	require.NoError(t, err)
+	u := assertNotified(t, w.Updates, "name acquired")
+	require.NotEmpty(t, u.Owner)
+	require.Equal(t, u.Owner, w.GetOwner())
+
+	_, err = RequestName(Test, "org.i3barista.test.Host")
+	require.Error(t, err, "name already owned")
+	assertNoUpdate(t, w.Updates, "on failed request")
+
+	release()
+	u = assertNotified(t, w.Updates, "name released")
+	require.Empty(t, u.Owner)
+	require.Empty(t, w.GetOwner())
+
+	require.NotPanics(t, release, "releasing twice")
+	assertNoUpdate(t, w.Updates, "on second release")
+
+	release, err = RequestName(Test, "org.i3barista.test.Host")
+	require.NoError(t, err, "after release")
+	assertNotified(t, w.Updates, "name acquired again")
+	release()
+	assertNotified(t, w.Updates, "name released again")
+}
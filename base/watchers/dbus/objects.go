@@ -0,0 +1,288 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbus
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const objectManager string = "org.freedesktop.DBus.ObjectManager"
+
+var (
+	getManagedObjects = dbusName{objectManager, "GetManagedObjects"}
+	interfacesAdded   = dbusName{objectManager, "InterfacesAdded"}
+	interfacesRemoved = dbusName{objectManager, "InterfacesRemoved"}
+)
+
+// Interfaces maps the names of the interfaces implemented by an object to
+// their properties. Property values are extracted from dbus.Variant values.
+type Interfaces map[string]map[string]interface{}
+
+// ObjectsWatcher is a watcher for the objects exposed by a DBus service that
+// implements org.freedesktop.DBus.ObjectManager. It keeps track of objects
+// and interfaces being added or removed, and of property changes on all the
+// objects it knows about.
+type ObjectsWatcher struct {
+	// Updates receives a value whenever any object changes. Multiple changes
+	// may be coalesced into a single update.
+	Updates  <-chan struct{}
+	onChange chan<- struct{}
+
+	conn   dbusConn
+	dbusCh chan *Signal
+
+	service string
+	root    dbus.ObjectPath
+
+	mu sync.RWMutex
+
+	owner   string
+	objects map[dbus.ObjectPath]Interfaces
+}
+
+// Get returns the latest snapshot of all objects, keyed by path.
+func (o *ObjectsWatcher) Get() map[dbus.ObjectPath]Interfaces {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	r := map[dbus.ObjectPath]Interfaces{}
+	for path, ifaces := range o.objects {
+		rIfaces := Interfaces{}
+		for iface, props := range ifaces {
+			rProps := map[string]interface{}{}
+			for k, v := range props {
+				rProps[k] = v
+			}
+			rIfaces[iface] = rProps
+		}
+		r[path] = rIfaces
+	}
+	return r
+}
+
+// Call calls a DBus method on one of the objects exposed by the service and
+// returns the result. The method name must include the interface.
+func (o *ObjectsWatcher) Call(path dbus.ObjectPath, method string, args ...interface{}) ([]interface{}, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.owner == "" {
+		return nil, errors.New("Disconnected")
+	}
+	c := o.conn.Object(o.service, path).Call(method, 0, args...)
+	return c.Body, c.Err
+}
+
+// Unsubscribe clears all subscriptions and internal state. The watcher cannot
+// be used after calling this method. Usually `defer`d when creating a watcher.
+func (o *ObjectsWatcher) Unsubscribe() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.conn.RemoveSignal(o.dbusCh)
+	o.conn.Close()
+	o.objects = nil
+	o.owner = ""
+}
+
+func (o *ObjectsWatcher) listen() {
+	for sig := range o.dbusCh {
+		if sig.Name == nameOwnerChanged.String() {
+			o.ownerChanged(sig.Body[2].(string))
+		} else {
+			o.handleSignal(sig)
+		}
+	}
+}
+
+func (o *ObjectsWatcher) handleSignal(sig *Signal) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.owner == "" || sig.Sender != o.owner {
+		return
+	}
+	changed := false
+	switch sig.Name {
+	case interfacesAdded.String():
+		changed = o.addInterfaces(sig.Body)
+	case interfacesRemoved.String():
+		changed = o.removeInterfaces(sig.Body)
+	case propsChanged.String():
+		changed = o.updateProperties(sig.Path, sig.Body)
+	}
+	if changed {
+		o.notify()
+	}
+}
+
+func (o *ObjectsWatcher) addInterfaces(body []interface{}) bool {
+	if len(body) < 2 {
+		return false
+	}
+	path, _ := body[0].(dbus.ObjectPath)
+	ifaces, _ := body[1].(map[string]map[string]dbus.Variant)
+	if path == "" || len(ifaces) == 0 {
+		return false
+	}
+	obj, ok := o.objects[path]
+	if !ok {
+		obj = Interfaces{}
+		o.objects[path] = obj
+	}
+	for iface, props := range ifaces {
+		obj[iface] = extractValues(iface, props)
+	}
+	return true
+}
+
+func (o *ObjectsWatcher) removeInterfaces(body []interface{}) bool {
+	if len(body) < 2 {
+		return false
+	}
+	path, _ := body[0].(dbus.ObjectPath)
+	ifaces, _ := body[1].([]string)
+	obj, ok := o.objects[path]
+	if !ok {
+		return false
+	}
+	for _, iface := range ifaces {
+		delete(obj, iface)
+	}
+	if len(obj) == 0 {
+		delete(o.objects, path)
+	}
+	return true
+}
+
+func (o *ObjectsWatcher) updateProperties(path dbus.ObjectPath, body []interface{}) bool {
+	if len(body) < 3 {
+		return false
+	}
+	iface, _ := body[0].(string)
+	props, ok := o.objects[path][iface]
+	if !ok {
+		// Properties of interfaces that were never added to the object are
+		// not tracked, since ObjectManager will emit InterfacesAdded for them.
+		return false
+	}
+	changed, _ := body[1].(map[string]dbus.Variant)
+	for k, v := range extractValues(iface, changed) {
+		props[k] = v
+	}
+	invalidated, _ := body[2].([]string)
+	obj := o.conn.Object(o.service, path)
+	for _, k := range invalidated {
+		if v, err := obj.GetProperty(expand(iface, k)); err == nil {
+			props[shorten(iface, k)] = v.Value()
+		} else {
+			delete(props, shorten(iface, k))
+		}
+	}
+	return len(changed)+len(invalidated) > 0
+}
+
+func extractValues(iface string, props map[string]dbus.Variant) map[string]interface{} {
+	r := map[string]interface{}{}
+	for k, v := range props {
+		r[shorten(iface, k)] = v.Value()
+	}
+	return r
+}
+
+// notify sends an update without blocking, since pending updates already
+// notify the listener that objects have changed.
+func (o *ObjectsWatcher) notify() {
+	select {
+	case o.onChange <- struct{}{}:
+	default:
+	}
+}
+
+func (o *ObjectsWatcher) matchOptions() []dbus.MatchOption {
+	return []dbus.MatchOption{
+		dbus.WithMatchOption("sender", o.owner),
+		dbus.WithMatchOption("path_namespace", string(o.root)),
+	}
+}
+
+func (o *ObjectsWatcher) ownerChanged(owner string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	signals := []dbusName{interfacesAdded, interfacesRemoved, propsChanged}
+	if o.owner != "" {
+		m := o.matchOptions()
+		for _, s := range signals {
+			s.removeMatch(o.conn, m...)
+		}
+	}
+	o.owner = owner
+	hadObjects := len(o.objects) > 0
+	o.objects = map[dbus.ObjectPath]Interfaces{}
+	if o.owner == "" {
+		if hadObjects {
+			o.notify()
+		}
+		return
+	}
+	m := o.matchOptions()
+	for _, s := range signals {
+		s.addMatch(o.conn, m...)
+	}
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	err := o.conn.Object(o.service, o.root).
+		Call(getManagedObjects.String(), 0).
+		Store(&objects)
+	if err != nil && !hadObjects {
+		return
+	}
+	for path, ifaces := range objects {
+		obj := Interfaces{}
+		for iface, props := range ifaces {
+			obj[iface] = extractValues(iface, props)
+		}
+		o.objects[path] = obj
+	}
+	o.notify()
+}
+
+// WatchObjects constructs a DBus watcher for all objects exposed by a service
+// through the ObjectManager interface at the given root object. Watchers must
+// be cleaned up by calling Unsubscribe.
+func WatchObjects(busType BusType, service string, root string) *ObjectsWatcher {
+	conn := busType()
+	updates := make(chan struct{}, 1)
+	w := &ObjectsWatcher{
+		Updates:  updates,
+		onChange: updates,
+		conn:     conn,
+		dbusCh:   make(chan *Signal, 10),
+		service:  service,
+		root:     dbus.ObjectPath(root),
+		objects:  map[dbus.ObjectPath]Interfaces{},
+	}
+	var owner string
+	if err := getNameOwner.call(conn, service).Store(&owner); err == nil {
+		w.ownerChanged(owner)
+	}
+	// Initial objects are available from Get(), so drop the update.
+	select {
+	case <-updates:
+	default:
+	}
+	nameOwnerChanged.addMatch(conn, dbus.WithMatchOption("arg0", service))
+	w.conn.Signal(w.dbusCh)
+	go w.listen()
+	return w
+}
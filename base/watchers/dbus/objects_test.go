@@ -0,0 +1,118 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func assertObjectsUpdated(t *testing.T, w *ObjectsWatcher, formatAndArgs ...interface{}) {
+	select {
+	case <-w.Updates:
+	case <-time.After(time.Second):
+		require.Fail(t, "ObjectsWatcher not updated", formatAndArgs...)
+	}
+}
+
+func assertObjectsNotUpdated(t *testing.T, w *ObjectsWatcher, formatAndArgs ...interface{}) {
+	select {
+	case <-w.Updates:
+		require.Fail(t, "ObjectsWatcher unexpectedly updated", formatAndArgs...)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+type managedObjects = map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+
+func TestObjectsWatcher(t *testing.T) {
+	bus := SetupTestBus()
+	srv := bus.RegisterService("org.i3barista.services.FooService")
+	root := srv.Object("/org/i3barista/Foo", "org.i3barista.Service")
+	root.On("org.freedesktop.DBus.ObjectManager.GetManagedObjects",
+		func(...interface{}) ([]interface{}, error) {
+			return []interface{}{managedObjects{
+				"/org/i3barista/Foo/a": {
+					"org.i3barista.Thing": {
+						"Name": dbus.MakeVariant("a"),
+						"Size": dbus.MakeVariant(uint64(10)),
+					},
+				},
+			}}, nil
+		})
+
+	w := WatchObjects(Test, "org.i3barista.services.FooService", "/org/i3barista/Foo")
+	defer w.Unsubscribe()
+	assertObjectsNotUpdated(t, w, "on start")
+	require.Equal(t, map[dbus.ObjectPath]Interfaces{
+		"/org/i3barista/Foo/a": {
+			"org.i3barista.Thing": {"Name": "a", "Size": uint64(10)},
+		},
+	}, w.Get(), "initial objects")
+
+	root.Emit("org.freedesktop.DBus.ObjectManager.InterfacesAdded",
+		dbus.ObjectPath("/org/i3barista/Foo/b"),
+		map[string]map[string]dbus.Variant{
+			"org.i3barista.Thing": {"Name": dbus.MakeVariant("b")},
+			"org.i3barista.Other": {},
+		})
+	assertObjectsUpdated(t, w, "on interfaces added")
+	require.Equal(t, Interfaces{
+		"org.i3barista.Thing": {"Name": "b"},
+		"org.i3barista.Other": {},
+	}, w.Get()["/org/i3barista/Foo/b"])
+
+	a := srv.Object("/org/i3barista/Foo/a", "org.i3barista.Thing")
+	a.SetProperty("Size", uint64(20))
+	assertObjectsUpdated(t, w, "on property change")
+	require.Equal(t, uint64(20),
+		w.Get()["/org/i3barista/Foo/a"]["org.i3barista.Thing"]["Size"])
+
+	a.SetPropertyForTest("Name", "aa", SignalTypeInvalidated)
+	assertObjectsUpdated(t, w, "on property invalidated")
+	require.Equal(t, "aa",
+		w.Get()["/org/i3barista/Foo/a"]["org.i3barista.Thing"]["Name"])
+
+	srv.Object("/org/i3barista/Foo/a", "org.i3barista.Unknown").
+		SetProperty("Foo", "bar")
+	assertObjectsNotUpdated(t, w, "on change to untracked interface")
+
+	root.Emit("org.freedesktop.DBus.ObjectManager.InterfacesRemoved",
+		dbus.ObjectPath("/org/i3barista/Foo/b"), []string{"org.i3barista.Thing"})
+	assertObjectsUpdated(t, w, "on interface removed")
+	require.Equal(t, Interfaces{"org.i3barista.Other": {}},
+		w.Get()["/org/i3barista/Foo/b"])
+
+	root.Emit("org.freedesktop.DBus.ObjectManager.InterfacesRemoved",
+		dbus.ObjectPath("/org/i3barista/Foo/b"), []string{"org.i3barista.Other"})
+	assertObjectsUpdated(t, w, "on last interface removed")
+	require.NotContains(t, w.Get(), dbus.ObjectPath("/org/i3barista/Foo/b"))
+
+	a.On("Frob", func(args ...interface{}) ([]interface{}, error) {
+		return []interface{}{"frobbed", args[0]}, nil
+	})
+	r, err := w.Call("/org/i3barista/Foo/a", "org.i3barista.Thing.Frob", 5)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"frobbed", 5}, r)
+
+	srv.Unregister()
+	assertObjectsUpdated(t, w, "on service disconnect")
+	require.Empty(t, w.Get())
+	_, err = w.Call("/org/i3barista/Foo/a", "org.i3barista.Thing.Frob", 5)
+	require.Error(t, err, "when disconnected")
+}
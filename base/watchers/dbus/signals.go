@@ -0,0 +1,164 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbus
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// SignalWatcher is a watcher for arbitrary signals emitted by a DBus service.
+// It follows the owner of the service name, so that signal matches are
+// managed across service restarts, and only signals from the current owner
+// are delivered.
+type SignalWatcher struct {
+	// Signals receives all watched signals emitted by the service. It also
+	// receives the NameOwnerChanged signals for the service name, so that
+	// consumers can tell when the service starts or stops.
+	Signals  <-chan *Signal
+	onSignal chan<- *Signal
+
+	conn   dbusConn
+	dbusCh chan *Signal
+
+	service string
+	object  dbus.ObjectPath
+
+	mu sync.RWMutex
+
+	owner   string
+	signals map[dbusName]bool
+}
+
+// Add specifies signals to watch. Signal names must include the interface,
+// e.g. "org.mpris.MediaPlayer2.Player.Seeked".
+func (s *SignalWatcher) Add(names ...string) *SignalWatcher {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range names {
+		nm := makeDbusName(name)
+		if s.signals[nm] {
+			continue
+		}
+		s.signals[nm] = true
+		if s.owner != "" {
+			nm.addMatch(s.conn, s.matchOptions()...)
+		}
+	}
+	return s
+}
+
+// Owner returns the current owner of the service name, or an empty string if
+// the service is not running.
+func (s *SignalWatcher) Owner() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.owner
+}
+
+// Call calls a DBus method on one of the objects exposed by the service and
+// returns the result. The method name must include the interface.
+func (s *SignalWatcher) Call(path dbus.ObjectPath, method string, args ...interface{}) ([]interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.owner == "" {
+		return nil, errors.New("Disconnected")
+	}
+	c := s.conn.Object(s.service, path).Call(method, 0, args...)
+	return c.Body, c.Err
+}
+
+// Unsubscribe clears all subscriptions and internal state. The watcher cannot
+// be used after calling this method. Usually `defer`d when creating a watcher.
+func (s *SignalWatcher) Unsubscribe() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.RemoveSignal(s.dbusCh)
+	s.conn.Close()
+	s.owner = ""
+}
+
+func (s *SignalWatcher) listen() {
+	for sig := range s.dbusCh {
+		if sig.Name == nameOwnerChanged.String() {
+			s.ownerChanged(sig.Body[2].(string))
+			s.onSignal <- sig
+		} else if s.shouldDeliver(sig) {
+			s.onSignal <- sig
+		}
+	}
+}
+
+func (s *SignalWatcher) shouldDeliver(sig *Signal) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.owner != "" && sig.Sender == s.owner &&
+		s.signals[makeDbusName(sig.Name)]
+}
+
+func (s *SignalWatcher) matchOptions() []dbus.MatchOption {
+	m := []dbus.MatchOption{dbus.WithMatchOption("sender", s.owner)}
+	if s.object != "" {
+		m = append(m, dbus.WithMatchOption("path", string(s.object)))
+	}
+	return m
+}
+
+func (s *SignalWatcher) ownerChanged(owner string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner != "" {
+		m := s.matchOptions()
+		for nm := range s.signals {
+			nm.removeMatch(s.conn, m...)
+		}
+	}
+	s.owner = owner
+	if s.owner == "" {
+		return
+	}
+	m := s.matchOptions()
+	for nm := range s.signals {
+		nm.addMatch(s.conn, m...)
+	}
+}
+
+// WatchSignals constructs a DBus signal watcher for the given service and
+// object path. If the object path is empty, signals from all objects exposed
+// by the service are delivered. Watchers must be cleaned up by calling
+// Unsubscribe.
+func WatchSignals(busType BusType, service string, object string) *SignalWatcher {
+	conn := busType()
+	signals := make(chan *Signal, 10)
+	w := &SignalWatcher{
+		Signals:  signals,
+		onSignal: signals,
+		conn:     conn,
+		dbusCh:   make(chan *Signal, 10),
+		service:  service,
+		object:   dbus.ObjectPath(object),
+		signals:  map[dbusName]bool{},
+	}
+	var owner string
+	if err := getNameOwner.call(conn, service).Store(&owner); err == nil {
+		w.ownerChanged(owner)
+	}
+	nameOwnerChanged.addMatch(conn, dbus.WithMatchOption("arg0", service))
+	w.conn.Signal(w.dbusCh)
+	go w.listen()
+	return w
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func assertSignal(t *testing.T, w *SignalWatcher, formatAndArgs ...interface{}) *Signal {
+	select {
+	case s := <-w.Signals:
+		return s
+	case <-time.After(time.Second):
+		require.Fail(t, "SignalWatcher did not receive signal", formatAndArgs...)
+	}
+	return nil
+}
+
+func assertNoSignal(t *testing.T, w *SignalWatcher, formatAndArgs ...interface{}) {
+	select {
+	case <-w.Signals:
+		require.Fail(t, "SignalWatcher unexpectedly received signal", formatAndArgs...)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestSignals(t *testing.T) {
+	bus := SetupTestBus()
+	srv := bus.RegisterService("org.i3barista.services.FooService")
+	obj := srv.Object("/org/i3barista/objects/Foo", "org.i3barista.Service")
+	other := srv.Object("/org/i3barista/objects/Bar", "org.i3barista.Service")
+
+	w := WatchSignals(Test,
+		"org.i3barista.services.FooService",
+		"/org/i3barista/objects/Foo").
+		Add("org.i3barista.Service.Ping")
+	defer w.Unsubscribe()
+	require.NotEmpty(t, w.Owner())
+	assertNoSignal(t, w, "on start")
+
+	obj.Emit("Ping", 1)
+	s := assertSignal(t, w, "on watched signal")
+	require.Equal(t, "org.i3barista.Service.Ping", s.Name)
+	require.Equal(t, []interface{}{1}, s.Body)
+
+	obj.Emit("Pong", 2)
+	assertNoSignal(t, w, "on unwatched signal")
+
+	other.Emit("Ping", 3)
+	assertNoSignal(t, w, "on signal from different object")
+
+	w.Add("org.i3barista.Service.Pong")
+	obj.Emit("Pong", 4)
+	s = assertSignal(t, w, "on signal added later")
+	require.Equal(t, []interface{}{4}, s.Body)
+
+	srv.Unregister()
+	s = assertSignal(t, w, "on service disconnect")
+	require.Equal(t, "org.freedesktop.DBus.NameOwnerChanged", s.Name)
+	require.Empty(t, w.Owner())
+
+	srv = bus.RegisterService("org.i3barista.services.FooService")
+	s = assertSignal(t, w, "on service reconnect")
+	require.Equal(t, "org.freedesktop.DBus.NameOwnerChanged", s.Name)
+	require.NotEmpty(t, w.Owner())
+
+	obj = srv.Object("/org/i3barista/objects/Foo", "org.i3barista.Service")
+	obj.Emit("Ping", 5)
+	s = assertSignal(t, w, "on signal from new owner")
+	require.Equal(t, []interface{}{5}, s.Body)
+
+	obj.SetPropertyForTest("Name", "foo", SignalTypeNone)
+	obj.SetPropertyForTest("org.i3barista.Other.Name", "bar", SignalTypeNone)
+	r, err := w.Call("/org/i3barista/objects/Foo",
+		"org.freedesktop.DBus.Properties.GetAll", "org.i3barista.Service")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{
+		map[string]dbus.Variant{"Name": dbus.MakeVariant("foo")},
+	}, r, "GetAll on test object")
+
+	srv.Unregister()
+	assertSignal(t, w, "on service disconnect")
+	_, err = w.Call("/org/i3barista/objects/Foo",
+		"org.freedesktop.DBus.Properties.GetAll", "org.i3barista.Service")
+	require.Error(t, err, "when disconnected")
+}
+
+func TestSignalsAllObjects(t *testing.T) {
+	bus := SetupTestBus()
+	w := WatchSignals(Test, "org.i3barista.services.FooService", "").
+		Add("org.i3barista.Service.Ping")
+	defer w.Unsubscribe()
+	require.Empty(t, w.Owner())
+
+	srv := bus.RegisterService("org.i3barista.services.FooService")
+	assertSignal(t, w, "on service connect")
+
+	srv.Object("/org/i3barista/objects/Foo", "org.i3barista.Service").Emit("Ping")
+	s := assertSignal(t, w, "on signal from any object")
+	require.Equal(t, "/org/i3barista/objects/Foo", string(s.Path))
+
+	srv.Object("/org/i3barista/objects/Bar", "org.i3barista.Service").Emit("Ping")
+	s = assertSignal(t, w, "on signal from any object")
+	require.Equal(t, "/org/i3barista/objects/Bar", string(s.Path))
+
+	other := bus.RegisterService("org.i3barista.services.BarService")
+	other.Object("/org/i3barista/objects/Foo", "org.i3barista.Service").Emit("Ping")
+	assertNoSignal(t, w, "on signal from different service")
+}
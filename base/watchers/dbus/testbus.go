@@ -65,6 +65,30 @@ func newTestBus() *TestBus {
 		}
 		return []interface{}{svc.id}, nil
 	})
+	// Names requested by connections are backed by a separate service each.
+	// Calls on the bus object are serialised, so the map needs no lock.
+	requested := map[string]*TestBusService{}
+	t.busObj.On("RequestName", func(args ...interface{}) ([]interface{}, error) {
+		nm := args[0].(string)
+		t.mu.Lock()
+		_, exists := t.services[nm]
+		t.mu.Unlock()
+		if exists {
+			return []interface{}{uint32(dbus.RequestNameReplyExists)}, nil
+		}
+		requested[nm] = t.RegisterService(nm)
+		return []interface{}{uint32(dbus.RequestNameReplyPrimaryOwner)}, nil
+	})
+	t.busObj.On("ReleaseName", func(args ...interface{}) ([]interface{}, error) {
+		nm := args[0].(string)
+		svc, ok := requested[nm]
+		if !ok {
+			return []interface{}{uint32(dbus.ReleaseNameReplyNotOwner)}, nil
+		}
+		delete(requested, nm)
+		svc.Unregister()
+		return []interface{}{uint32(dbus.ReleaseNameReplyReleased)}, nil
+	})
 	return t
 }
 
@@ -64,6 +64,14 @@ func (t *TestBusObject) Call(method string, flags dbus.Flags, args ...interface{
 			return t.eCall(method, args...)
 		}
 	}
+	if h == nil {
+		switch {
+		case method == getAllProps.String() && len(args) == 1:
+			h = t.getAll
+		case method == setProp.String() && len(args) == 3:
+			h = t.setProp
+		}
+	}
 	if h == nil {
 		call.Err = errors.New("No such method: " + method)
 	} else {
@@ -156,6 +164,39 @@ func (t *TestBusObject) GetProperty(p string) (dbus.Variant, error) {
 	return dbus.Variant{}, errors.New("No such property: " + p)
 }
 
+// getAll returns the values of all properties of an interface, as the
+// org.freedesktop.DBus.Properties.GetAll method would. Must be called with
+// the lock held.
+func (t *testBusObject) getAll(args ...interface{}) ([]interface{}, error) {
+	iface, _ := args[0].(string)
+	r := map[string]dbus.Variant{}
+	for k, v := range t.props {
+		if !strings.HasPrefix(k, iface+".") {
+			continue
+		}
+		if name := strings.TrimPrefix(k, iface+"."); !strings.ContainsRune(name, '.') {
+			r[name] = dbus.MakeVariant(v)
+		}
+	}
+	return []interface{}{r}, nil
+}
+
+// setProp sets the value of a property, as the
+// org.freedesktop.DBus.Properties.Set method would, and emits a
+// PropertiesChanged signal. Must be called with the lock held.
+func (t *TestBusObject) setProp(args ...interface{}) ([]interface{}, error) {
+	iface, _ := args[0].(string)
+	name, _ := args[1].(string)
+	val, ok := args[2].(dbus.Variant)
+	if !ok {
+		return nil, errors.New("Property value must be a variant")
+	}
+	t.props[expand(iface, name)] = val.Value()
+	chg := map[string]dbus.Variant{expand(iface, name): val}
+	go t.Emit(propsChanged.String(), iface, chg, []string{})
+	return nil, nil
+}
+
 // StoreProperty stores the value of a named property into a given pointer.
 func (t *TestBusObject) StoreProperty(p string, dest interface{}) error {
 	val, err := t.GetProperty(p)
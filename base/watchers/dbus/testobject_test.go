@@ -133,3 +133,30 @@ func TestObjects(t *testing.T) {
 	require.NotPanics(t, func() { o0.Destination() },
 		"Object obtained from TestService, after connection closed")
 }
+
+func TestPropertiesInterface(t *testing.T) {
+	b := SetupTestBus()
+	svc := b.RegisterService("org.i3barista.Misc.BarService")
+	o := svc.Object("/org/i3barista/Misc/Bar", "org.i3barista.Bar")
+	o.SetPropertyForTest("color", "red", SignalTypeNone)
+
+	w := WatchProperties(Test, "org.i3barista.Misc.BarService",
+		"/org/i3barista/Misc/Bar", "org.i3barista.Bar").Add("color")
+	defer w.Unsubscribe()
+
+	r, err := w.Call("org.freedesktop.DBus.Properties.GetAll", "org.i3barista.Bar")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{
+		map[string]dbus.Variant{"color": dbus.MakeVariant("red")},
+	}, r)
+
+	_, err = w.Call("org.freedesktop.DBus.Properties.Set",
+		"org.i3barista.Bar", "color", dbus.MakeVariant("blue"))
+	require.NoError(t, err)
+	u := assertUpdated(t, w, "on property set")
+	require.Equal(t, PropertiesChange{"color": {"red", "blue"}}, u)
+
+	_, err = w.Call("org.freedesktop.DBus.Properties.Set",
+		"org.i3barista.Bar", "color", "green")
+	require.Error(t, err, "value is not a variant")
+}
@@ -49,6 +49,14 @@ type Link struct {
 	State        OperState
 	HardwareAddr net.HardwareAddr
 	IPs          []net.IP
+	// Kind is the kind of a virtual link (e.g. "bridge", "veth", "tun"), and
+	// is empty for physical links.
+	Kind string
+	// Master is the name of the link this link is enslaved to (e.g. the
+	// bridge or bond it is a port of), and is empty if it is not enslaved.
+	Master string
+
+	masterIndex LinkIndex
 }
 
 var (
@@ -74,6 +82,9 @@ func addLink(index LinkIndex, link Link) {
 		if link.HardwareAddr.String() != oldLink.HardwareAddr.String() {
 			changed = true
 		}
+		if link.Kind != oldLink.Kind || link.masterIndex != oldLink.masterIndex {
+			changed = true
+		}
 		if !changed {
 			l.Fine("Link %s@%d unchaged, skipping update",
 				link.Name, index)
@@ -244,6 +255,9 @@ func (s *Subscription) notify(links []Link) {
 func sortedLinks() []Link {
 	allLinks := []Link{}
 	for _, link := range links {
+		if link.masterIndex != 0 {
+			link.Master = links[link.masterIndex].Name
+		}
 		allLinks = append(allLinks, link)
 	}
 	sort.Slice(allLinks, func(ai, bi int) bool {
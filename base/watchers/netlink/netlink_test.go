@@ -289,6 +289,31 @@ func TestFiltering(t *testing.T) {
 	require.Equal(t, wwan0, subAny.Get(), "Re-order on state change")
 }
 
+func TestKindAndMaster(t *testing.T) {
+	reset()
+	setInitialData(testNlRequest{}, testNlRequest{})
+	msgCh, _ := returnTestSubscriber()
+
+	sub := All()
+	next := sub.Next()
+	msgCh <- msgNewLink(1, Link{Name: "br0", State: Up, Kind: "bridge"})
+	next = assertUpdated(t, next, sub, "bridge added")
+	msgCh <- msgNewLink(2, Link{Name: "eth0", State: Up, masterIndex: 1})
+	next = assertUpdated(t, next, sub, "port added")
+	require.Equal(t, []Link{
+		{Name: "br0", State: Up, Kind: "bridge", HardwareAddr: []byte{}},
+		{Name: "eth0", State: Up, Master: "br0", HardwareAddr: []byte{}, masterIndex: 1},
+	}, sub.Get())
+
+	msgCh <- msgNewLink(1, Link{Name: "bridge0", State: Up, Kind: "bridge"})
+	next = assertUpdated(t, next, sub, "bridge renamed")
+	require.Equal(t, "bridge0", sub.Get()[1].Master, "master is renamed")
+
+	msgCh <- msgNewLink(2, Link{Name: "eth0", State: Up})
+	assertUpdated(t, next, sub, "port released")
+	require.Equal(t, "", sub.Get()[1].Master, "master is removed")
+}
+
 func TestTestMode(t *testing.T) {
 	nlt := TestMode()
 
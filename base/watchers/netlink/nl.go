@@ -16,6 +16,7 @@ package netlink
 
 import (
 	"net"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -33,6 +34,9 @@ func linkFromMsg(msg []byte) (LinkIndex, Link) {
 	linksMu.RLock()
 	link := links[linkIndex]
 	linksMu.RUnlock()
+	// Only set if present, so that a link released from its master is not
+	// still shown as enslaved.
+	link.masterIndex = 0
 	attrs, _ := nl.ParseRouteAttr(msg[ifmsg.Len():])
 	for _, attr := range attrs {
 		switch attr.Attr.Type {
@@ -42,11 +46,25 @@ func linkFromMsg(msg []byte) (LinkIndex, Link) {
 			link.HardwareAddr = net.HardwareAddr(attr.Value)
 		case unix.IFLA_OPERSTATE:
 			link.State = OperState(native.Uint32(attr.Value[0:4]))
+		case unix.IFLA_MASTER:
+			link.masterIndex = LinkIndex(native.Uint32(attr.Value[0:4]))
+		case unix.IFLA_LINKINFO:
+			link.Kind = kindFromLinkInfo(attr.Value)
 		}
 	}
 	return linkIndex, link
 }
 
+func kindFromLinkInfo(info []byte) string {
+	attrs, _ := nl.ParseRouteAttr(info)
+	for _, attr := range attrs {
+		if attr.Attr.Type == unix.IFLA_INFO_KIND {
+			return strings.TrimRight(string(attr.Value), "\x00")
+		}
+	}
+	return ""
+}
+
 func addrFromMsg(msg []byte) (LinkIndex, net.IP) {
 	ifmsg := nl.DeserializeIfAddrmsg(msg)
 	linkIndex := LinkIndex(ifmsg.Index)
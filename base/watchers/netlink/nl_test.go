@@ -101,13 +101,21 @@ func makeNetlinkMessage(
 func msgNewLink(linkIdx int, l Link) syscall.NetlinkMessage {
 	data := nl.NewIfInfomsg(unix.AF_UNSPEC)
 	data.Index = int32(linkIdx)
-	return makeNetlinkMessage(
-		unix.RTM_NEWLINK,
-		data,
+	attrs := []*nl.RtAttr{
 		nl.NewRtAttr(unix.IFLA_IFNAME, append([]byte(l.Name), 0)),
 		nl.NewRtAttr(unix.IFLA_ADDRESS, l.HardwareAddr),
 		nl.NewRtAttr(unix.IFLA_OPERSTATE, []byte{byte(l.State)}),
-	)
+	}
+	if l.masterIndex != 0 {
+		attrs = append(attrs, nl.NewRtAttr(unix.IFLA_MASTER,
+			nl.Uint32Attr(uint32(l.masterIndex))))
+	}
+	if l.Kind != "" {
+		info := nl.NewRtAttr(unix.IFLA_LINKINFO, nil)
+		info.AddRtAttr(unix.IFLA_INFO_KIND, append([]byte(l.Kind), 0))
+		attrs = append(attrs, info)
+	}
+	return makeNetlinkMessage(unix.RTM_NEWLINK, data, attrs...)
 }
 
 func msgNewAddrs(linkIdx int, addr, localAddr net.IP) syscall.NetlinkMessage {
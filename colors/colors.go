@@ -59,7 +59,11 @@ func Hex(hex string) ColorfulColor {
 
 // Scheme gets a color from the user-defined color scheme.
 // Some common names are 'good', 'bad', and 'degraded'.
+// Once a theme is set, the returned color follows theme changes.
 func Scheme(name string) ColorfulColor {
+	if themeActive() {
+		return themeScheme(name)
+	}
 	return scheme[name]
 }
 
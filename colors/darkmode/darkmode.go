@@ -0,0 +1,112 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package darkmode switches between a light and a dark theme following the
+// desktop's colour scheme preference, as reported by the XDG desktop portal
+// (GNOME, KDE, and others).
+package darkmode // import "barista.run/colors/darkmode"
+
+import (
+	"fmt"
+
+	"barista.run/base/watchers/dbus"
+	"barista.run/colors"
+	l "barista.run/logging"
+
+	godbus "github.com/godbus/dbus/v5"
+)
+
+const (
+	portalService   = "org.freedesktop.portal.Desktop"
+	portalObject    = "/org/freedesktop/portal/desktop"
+	settingsIface   = "org.freedesktop.portal.Settings"
+	appearance      = "org.freedesktop.appearance"
+	colorSchemeKey  = "color-scheme"
+	preferDarkValue = uint32(1)
+)
+
+// Overridden in tests.
+var busType = dbus.Session
+
+// Follow sets the light or dark theme based on the desktop's preference, and
+// switches themes whenever the preference changes. If the desktop has no
+// preference, or the portal is not available, the light theme is used.
+func Follow(light, dark string) error {
+	for _, name := range []string{light, dark} {
+		if !hasTheme(name) {
+			return fmt.Errorf("unknown theme %q", name)
+		}
+	}
+	w := dbus.WatchSignals(busType, portalService, portalObject).
+		Add(settingsIface + ".SettingChanged")
+	setTheme := func(preference uint32) {
+		theme := light
+		if preference == preferDarkValue {
+			theme = dark
+		}
+		if colors.Theme() != theme {
+			colors.SetTheme(theme)
+		}
+	}
+	setTheme(read(w))
+	go func() {
+		for sig := range w.Signals {
+			switch sig.Name {
+			case "org.freedesktop.DBus.NameOwnerChanged":
+				setTheme(read(w))
+			default:
+				if len(sig.Body) == 3 && sig.Body[0] == appearance && sig.Body[1] == colorSchemeKey {
+					setTheme(unwrap(sig.Body[2]))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func hasTheme(name string) bool {
+	for _, t := range colors.Themes() {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// read gets the current colour scheme preference from the portal.
+func read(w *dbus.SignalWatcher) uint32 {
+	r, err := w.Call(portalObject, settingsIface+".Read", appearance, colorSchemeKey)
+	if err != nil {
+		l.Log("Failed to read colour scheme preference: %v", err)
+		return 0
+	}
+	if len(r) == 0 {
+		return 0
+	}
+	return unwrap(r[0])
+}
+
+// unwrap extracts the preference from a value, which the portal wraps in
+// one or more variants.
+func unwrap(v interface{}) uint32 {
+	for {
+		variant, ok := v.(godbus.Variant)
+		if !ok {
+			break
+		}
+		v = variant.Value()
+	}
+	pref, _ := v.(uint32)
+	return pref
+}
@@ -0,0 +1,55 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package darkmode
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/base/watchers/dbus"
+	"barista.run/colors"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func assertTheme(t *testing.T, expected string, msg string) {
+	require.Eventually(t, func() bool { return colors.Theme() == expected },
+		time.Second, time.Millisecond, msg)
+}
+
+func TestFollow(t *testing.T) {
+	busType = dbus.Test
+	bus := dbus.SetupTestBus()
+	pref := uint32(1)
+	srv := bus.RegisterService(portalService)
+	obj := srv.Object(portalObject, settingsIface)
+	obj.On("Read", func(args ...interface{}) ([]interface{}, error) {
+		require.Equal(t, []interface{}{appearance, colorSchemeKey}, args)
+		return []interface{}{godbus.MakeVariant(godbus.MakeVariant(pref))}, nil
+	})
+
+	require.Error(t, Follow("light", "nope"))
+	require.NoError(t, Follow("light", "dark"))
+	require.Equal(t, "dark", colors.Theme(), "initial preference")
+
+	obj.Emit("SettingChanged", appearance, colorSchemeKey, godbus.MakeVariant(uint32(2)))
+	assertTheme(t, "light", "on preference change")
+
+	obj.Emit("SettingChanged", "org.gnome.desktop.interface", colorSchemeKey,
+		godbus.MakeVariant(uint32(1)))
+	obj.Emit("SettingChanged", appearance, colorSchemeKey, godbus.MakeVariant(uint32(1)))
+	assertTheme(t, "dark", "on preference change")
+}
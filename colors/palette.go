@@ -0,0 +1,177 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package colors
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Gradient maps values to colours, interpolating between the closest values
+// for values in between. For example, the colour of
+// Gradient{0: red, 50: yellow, 100: green}.At(75) is a yellowish green.
+type Gradient map[float64]color.Color
+
+// At returns the colour for the given value. Values outside the range of the
+// gradient get the colour of the closest end. An empty gradient has no colour.
+func (g Gradient) At(value float64) ColorfulColor {
+	if len(g) == 0 {
+		return nil
+	}
+	stops := make([]float64, 0, len(g))
+	for v := range g {
+		stops = append(stops, v)
+	}
+	sort.Float64s(stops)
+	idx := sort.SearchFloat64s(stops, value)
+	switch {
+	case idx == 0:
+		return colorAt(g[stops[0]])
+	case idx == len(stops):
+		return colorAt(g[stops[idx-1]])
+	}
+	lo, hi := stops[idx-1], stops[idx]
+	from, _ := colorful.MakeColor(g[lo])
+	to, _ := colorful.MakeColor(g[hi])
+	return &colorfulColor{from.BlendLab(to, (value-lo)/(hi-lo)).Clamped()}
+}
+
+func colorAt(c color.Color) ColorfulColor {
+	cful, _ := colorful.MakeColor(c)
+	return &colorfulColor{cful}
+}
+
+// EvenGradient creates a gradient with the given colours evenly spaced
+// between 0 and 1.
+func EvenGradient(colors ...color.Color) Gradient {
+	g := Gradient{}
+	for i, c := range colors {
+		if len(colors) == 1 {
+			g[0] = c
+		} else {
+			g[float64(i)/float64(len(colors)-1)] = c
+		}
+	}
+	return g
+}
+
+// palettes are the built-in named palettes. Each palette defines the common
+// scheme names "good", "degraded", and "bad", along with its own colours.
+var palettes = map[string]map[string]string{
+	"solarized-dark": solarized("#002b36", "#073642", "#586e75", "#657b83",
+		"#839496", "#93a1a1", "#eee8d5", "#fdf6e3"),
+	"solarized-light": solarized("#fdf6e3", "#eee8d5", "#93a1a1", "#839496",
+		"#657b83", "#586e75", "#073642", "#002b36"),
+	// Okabe-Ito colours, distinguishable with all common forms of colour
+	// blindness.
+	"okabe-ito": {
+		"good": "#009e73", "degraded": "#e69f00", "bad": "#d55e00",
+		"orange": "#e69f00", "sky-blue": "#56b4e9", "bluish-green": "#009e73",
+		"yellow": "#f0e442", "blue": "#0072b2", "vermillion": "#d55e00",
+		"reddish-purple": "#cc79a7",
+	},
+	// Blue/orange, for red-green colour blindness.
+	"blue-orange": {
+		"good": "#3b8bd9", "degraded": "#f0c419", "bad": "#e8710a",
+	},
+}
+
+// solarized creates a solarized palette with the given base colours, from
+// darkest to lightest in the dark variant.
+func solarized(bases ...string) map[string]string {
+	p := map[string]string{
+		"yellow": "#b58900", "orange": "#cb4b16", "red": "#dc322f",
+		"magenta": "#d33682", "violet": "#6c71c4", "blue": "#268bd2",
+		"cyan": "#2aa198", "green": "#859900",
+		"good": "#859900", "degraded": "#b58900", "bad": "#dc322f",
+	}
+	for i, name := range []string{"base03", "base02", "base01", "base00",
+		"base0", "base1", "base2", "base3"} {
+		p[name] = bases[i]
+	}
+	p["background"] = p["base03"]
+	p["foreground"] = p["base0"]
+	return p
+}
+
+// Palettes returns the names of the built-in palettes.
+func Palettes() []string {
+	var names []string
+	for name := range palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadPalette sets the colour scheme from a built-in palette, see Palettes
+// for the available names. Colours that are not in the palette are kept.
+func LoadPalette(name string) error {
+	p, ok := palettes[name]
+	if !ok {
+		return fmt.Errorf("unknown palette %q", name)
+	}
+	LoadFromMap(p)
+	return nil
+}
+
+// base16Names maps base16 colours to the common scheme names, following the
+// base16 styling guidelines.
+var base16Names = map[string][]string{
+	"base00": {"background"},
+	"base05": {"foreground"},
+	"base08": {"bad"},
+	"base09": {"orange"},
+	"base0A": {"degraded"},
+	"base0B": {"good"},
+}
+
+// LoadBase16 sets the colour scheme from a base16 scheme file, which sets the
+// colours base00 through base0F, and also sets "good", "degraded", "bad",
+// "background", and "foreground" from the corresponding base16 colours.
+func LoadBase16(filename string) error {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[:idx])
+		value := strings.Fields(line[idx+1:])
+		if !strings.HasPrefix(name, "base") || len(value) == 0 {
+			continue
+		}
+		color := Hex("#" + strings.TrimPrefix(strings.Trim(value[0], `"'`), "#"))
+		if color == nil {
+			continue
+		}
+		scheme[name] = color
+		for _, alias := range base16Names[name] {
+			scheme[alias] = color
+		}
+	}
+	return s.Err()
+}
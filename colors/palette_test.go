@@ -0,0 +1,107 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package colors
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGradient(t *testing.T) {
+	require.Nil(t, Gradient{}.At(10), "empty gradient")
+
+	g := Gradient{0: Hex("#f00"), 50: Hex("#ff0"), 100: Hex("#0f0")}
+	for _, tc := range []struct {
+		value    float64
+		expected string
+	}{
+		{-10, "#ff0000"},
+		{0, "#ff0000"},
+		{50, "#ffff00"},
+		{100, "#00ff00"},
+		{1000, "#00ff00"},
+	} {
+		require.Equal(t, tc.expected, g.At(tc.value).Colorful().Hex(),
+			"At(%v)", tc.value)
+	}
+
+	mid := g.At(75).Colorful()
+	require.True(t, mid.R > 0.1 && mid.R < 0.9, "interpolated red: %v", mid)
+	require.True(t, mid.G > 0.9, "interpolated green: %v", mid)
+
+	single := Gradient{42: color.Black}
+	require.Equal(t, "#000000", single.At(0).Colorful().Hex())
+
+	even := EvenGradient(Hex("#000"), Hex("#fff"), Hex("#000"))
+	require.Equal(t, "#ffffff", even.At(0.5).Colorful().Hex())
+	require.Equal(t, "#000000", even.At(1).Colorful().Hex())
+	require.Equal(t, "#ffffff", EvenGradient(Hex("#fff")).At(0.3).Colorful().Hex())
+}
+
+func TestPalettes(t *testing.T) {
+	require.Contains(t, Palettes(), "solarized-dark")
+	require.Contains(t, Palettes(), "okabe-ito")
+
+	for _, name := range Palettes() {
+		scheme = map[string]ColorfulColor{}
+		require.NoError(t, LoadPalette(name))
+		for _, c := range []string{"good", "degraded", "bad"} {
+			require.NotNil(t, Scheme(c), "%s in %s", c, name)
+		}
+	}
+
+	scheme = map[string]ColorfulColor{"custom": Hex("#123")}
+	require.NoError(t, LoadPalette("solarized-light"))
+	assertColorEquals(t, Hex("#fdf6e3"), Scheme("background"), "solarized")
+	assertColorEquals(t, Hex("#859900"), Scheme("good"), "solarized")
+	assertColorEquals(t, Hex("#123"), Scheme("custom"), "existing colours kept")
+
+	require.Error(t, LoadPalette("nope"))
+}
+
+func TestLoadBase16(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	afero.WriteFile(fs, "onedark.yaml", []byte(`
+scheme: "OneDark"
+author: "Lalit Magant (http://github.com/tilal6991)"
+base00: "282c34" # background
+base05: 'abb2bf'
+base08: "#e06c75"
+base0A: e5c07b
+base0B: "98c379"
+base0C: "invalid"
+base0D:
+`), 0644)
+
+	require.Error(t, LoadBase16("non-existent"))
+
+	scheme = map[string]ColorfulColor{}
+	require.NoError(t, LoadBase16("onedark.yaml"))
+	assertSchemeEquals(t, map[string]color.Color{
+		"base00":     Hex("#282c34"),
+		"background": Hex("#282c34"),
+		"base05":     Hex("#abb2bf"),
+		"foreground": Hex("#abb2bf"),
+		"base08":     Hex("#e06c75"),
+		"bad":        Hex("#e06c75"),
+		"base0A":     Hex("#e5c07b"),
+		"degraded":   Hex("#e5c07b"),
+		"base0B":     Hex("#98c379"),
+		"good":       Hex("#98c379"),
+	}, "base16")
+}
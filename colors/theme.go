@@ -0,0 +1,180 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package colors
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+
+	"barista.run/base/value"
+	l "barista.run/logging"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Themes allow switching between sets of colours while the bar is running,
+// e.g. to follow the desktop's light or dark mode. Modules use semantic colour
+// names such as "good", "warning", "critical", and "dim" through Scheme, and
+// the bar is redrawn with the new colours when the theme changes.
+var (
+	themeMu sync.RWMutex
+	themes  = map[string]map[string]ColorfulColor{}
+	// current holds the name of the current theme, or "" if none is set.
+	current value.Value // of string
+)
+
+func init() {
+	current.Set("")
+	AddTheme("dark", map[string]string{
+		"good": "#8ae234", "warning": "#fce94f", "critical": "#ef2929",
+		"dim": "#888a85", "degraded": "#fce94f", "bad": "#ef2929",
+	})
+	AddTheme("light", map[string]string{
+		"good": "#4e9a06", "warning": "#c4a000", "critical": "#a40000",
+		"dim": "#555753", "degraded": "#c4a000", "bad": "#a40000",
+	})
+}
+
+// AddTheme registers a theme with the given colours, as name=hex pairs,
+// replacing any existing theme with the same name. The built-in "dark" and
+// "light" themes define "good", "warning", "critical", and "dim", as well as
+// "degraded" and "bad" for modules that use the older names.
+func AddTheme(name string, colors map[string]string) {
+	theme := map[string]ColorfulColor{}
+	for n, v := range colors {
+		if c := Hex(v); c != nil {
+			theme[n] = c
+		}
+	}
+	themeMu.Lock()
+	themes[name] = theme
+	themeMu.Unlock()
+	if Theme() == name {
+		// Redraw with the updated colours.
+		current.Set(name)
+	}
+}
+
+// Themes returns the names of all registered themes.
+func Themes() []string {
+	themeMu.RLock()
+	defer themeMu.RUnlock()
+	var names []string
+	for n := range themes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetTheme switches to the named theme. Theme colours take precedence over
+// colours in the scheme, which are still used for names the theme does not
+// define. Colours are resolved when the bar is drawn, so outputs already
+// using colours from Scheme switch to the new theme without any changes to
+// the modules, but colours already converted to pango markup do not.
+func SetTheme(name string) error {
+	themeMu.RLock()
+	_, ok := themes[name]
+	themeMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown theme %q", name)
+	}
+	l.Log("Switching to theme %s", name)
+	current.Set(name)
+	return nil
+}
+
+// Theme returns the name of the current theme, or "" if no theme is set.
+func Theme() string {
+	return current.Get().(string)
+}
+
+// ThemeChanged returns a channel that receives an empty struct{} on each
+// theme change, and a func to close the subscription.
+func ThemeChanged() (<-chan struct{}, func()) {
+	return current.Subscribe()
+}
+
+// SwitchThemeOnSignal cycles through the given themes each time the process
+// receives the signal, e.g. SIGRTMIN+1 to switch themes from an i3/sway key
+// binding. The first theme is set immediately.
+func SwitchThemeOnSignal(sig os.Signal, names ...string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("no themes to switch between")
+	}
+	if err := SetTheme(names[0]); err != nil {
+		return err
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig)
+	go func() {
+		idx := 0
+		for range ch {
+			idx = (idx + 1) % len(names)
+			if err := SetTheme(names[idx]); err != nil {
+				l.Log("Failed to switch theme: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func themeActive() bool {
+	return Theme() != ""
+}
+
+// lookup returns the colour with the given name from the current theme,
+// falling back to the scheme.
+func lookup(name string) ColorfulColor {
+	themeMu.RLock()
+	c, ok := themes[Theme()][name]
+	themeMu.RUnlock()
+	if ok {
+		return c
+	}
+	return scheme[name]
+}
+
+// themeScheme returns a colour that follows theme changes, or nil if the
+// colour is not currently defined.
+func themeScheme(name string) ColorfulColor {
+	c := lookup(name)
+	if c == nil {
+		return nil
+	}
+	return &themeColor{name, c.Colorful()}
+}
+
+// themeColor is a named colour that is resolved using the current theme
+// each time it is used. If the current theme and scheme do not define the
+// colour, the colour at the time of creation is used.
+type themeColor struct {
+	name     string
+	fallback colorful.Color
+}
+
+func (t *themeColor) Colorful() colorful.Color {
+	if c := lookup(t.name); c != nil {
+		return c.Colorful()
+	}
+	return t.fallback
+}
+
+func (t *themeColor) RGBA() (r, g, b, a uint32) {
+	return t.Colorful().RGBA()
+}
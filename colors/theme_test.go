@@ -0,0 +1,99 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package colors
+
+import (
+	"os"
+	"os/signal"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func assertThemeChanged(t *testing.T, ch <-chan struct{}, msg string) {
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		require.Fail(t, "Expected theme change", msg)
+	}
+}
+
+func TestThemes(t *testing.T) {
+	defer current.Set("")
+	defer delete(themes, "custom")
+	scheme = map[string]ColorfulColor{}
+	LoadFromMap(map[string]string{"good": "#00ff00", "custom": "#123456"})
+
+	good := Scheme("good")
+	require.Equal(t, "", Theme())
+	require.Equal(t, []string{"dark", "light"}, Themes())
+	require.Error(t, SetTheme("nope"))
+	require.Equal(t, "", Theme(), "unchanged on unknown theme")
+
+	changed, done := ThemeChanged()
+	defer done()
+	require.NoError(t, SetTheme("dark"))
+	assertThemeChanged(t, changed, "on SetTheme")
+	require.Equal(t, "dark", Theme())
+	assertColorEquals(t, Hex("#00ff00"), good, "colours from before theme unchanged")
+
+	good = Scheme("good")
+	custom := Scheme("custom")
+	assertColorEquals(t, Hex("#8ae234"), good, "theme colour")
+	assertColorEquals(t, Hex("#123456"), custom, "scheme colour not in theme")
+	require.Nil(t, Scheme("undefined"))
+
+	require.NoError(t, SetTheme("light"))
+	assertColorEquals(t, Hex("#4e9a06"), good, "follows theme change")
+	require.Equal(t, Hex("#4e9a06").Colorful(), good.Colorful())
+	assertColorEquals(t, Hex("#123456"), custom)
+
+	AddTheme("custom", map[string]string{"custom": "#abcdef", "invalid": "#ghi"})
+	require.Contains(t, Themes(), "custom")
+	require.NoError(t, SetTheme("custom"))
+	assertThemeChanged(t, changed, "on SetTheme")
+	assertColorEquals(t, Hex("#abcdef"), custom, "theme overrides scheme")
+	assertColorEquals(t, Hex("#00ff00"), good, "falls back to scheme")
+	require.Nil(t, Scheme("invalid"))
+
+	AddTheme("custom", map[string]string{"custom": "#fedcba"})
+	assertThemeChanged(t, changed, "on current theme update")
+	assertColorEquals(t, Hex("#fedcba"), custom)
+
+	delete(scheme, "good")
+	assertColorEquals(t, Hex("#8ae234"), good, "uses colour at creation")
+}
+
+func TestSwitchThemeOnSignal(t *testing.T) {
+	defer current.Set("")
+	defer signal.Reset(unix.SIGUSR1)
+	require.Error(t, SwitchThemeOnSignal(unix.SIGUSR1))
+	require.Error(t, SwitchThemeOnSignal(unix.SIGUSR1, "nope"))
+
+	require.NoError(t, SwitchThemeOnSignal(unix.SIGUSR1, "light", "dark"))
+	require.Equal(t, "light", Theme())
+
+	changed, done := ThemeChanged()
+	defer done()
+	unix.Kill(os.Getpid(), unix.SIGUSR1)
+	assertThemeChanged(t, changed, "on signal")
+	require.Equal(t, "dark", Theme())
+
+	unix.Kill(os.Getpid(), unix.SIGUSR1)
+	assertThemeChanged(t, changed, "on signal")
+	require.Equal(t, "light", Theme(), "wraps around")
+}
@@ -0,0 +1,135 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+
+	"barista.run/bar"
+	"barista.run/modules/clock"
+	"barista.run/modules/cpuload"
+	"barista.run/modules/diskspace"
+	"barista.run/modules/shell"
+	"barista.run/modules/static"
+	"barista.run/outputs"
+)
+
+func init() {
+	Register("static", staticModule)
+	Register("clock", clockModule)
+	Register("shell", shellModule)
+	Register("cpuload", cpuloadModule)
+	Register("diskspace", diskspaceModule)
+}
+
+// staticModule displays fixed text. Options: text.
+func staticModule(o Options) (bar.Module, error) {
+	text, err := o.String("text", "")
+	if err != nil {
+		return nil, err
+	}
+	return static.New(outputs.Text(text)), nil
+}
+
+// clockModule displays the time. Options: format (a Go time layout),
+// timezone (e.g. "Europe/London").
+func clockModule(o Options) (bar.Module, error) {
+	format, err := o.String("format", "15:04")
+	if err != nil {
+		return nil, err
+	}
+	tz, err := o.String("timezone", "")
+	if err != nil {
+		return nil, err
+	}
+	m := clock.Local()
+	if tz != "" {
+		if m, err = clock.ZoneByName(tz); err != nil {
+			return nil, err
+		}
+	}
+	return m.OutputFormat(format), nil
+}
+
+// shellModule displays the output of a command run using sh. Options:
+// command, interval, format (a template for the output).
+func shellModule(o Options) (bar.Module, error) {
+	cmd, err := o.String("command", "")
+	if err != nil {
+		return nil, err
+	}
+	if cmd == "" {
+		return nil, errors.New("missing command")
+	}
+	interval, err := o.Duration("interval", 0)
+	if err != nil {
+		return nil, err
+	}
+	format, err := o.Format("format", "")
+	if err != nil {
+		return nil, err
+	}
+	m := shell.New("sh", "-c", cmd).Every(interval)
+	if format != nil {
+		m.Output(func(s string) bar.Output { return format(s) })
+	}
+	return m, nil
+}
+
+// cpuloadModule displays the load average. Options: interval, format (a
+// template for the cpuload.LoadAvg).
+func cpuloadModule(o Options) (bar.Module, error) {
+	interval, err := o.Duration("interval", 0)
+	if err != nil {
+		return nil, err
+	}
+	format, err := o.Format("format", "")
+	if err != nil {
+		return nil, err
+	}
+	m := cpuload.New()
+	if interval > 0 {
+		m.RefreshInterval(interval)
+	}
+	if format != nil {
+		m.Output(func(l cpuload.LoadAvg) bar.Output { return format(l) })
+	}
+	return m, nil
+}
+
+// diskspaceModule displays the space used on a disk. Options: path,
+// interval, format (a template for the diskspace.Info).
+func diskspaceModule(o Options) (bar.Module, error) {
+	path, err := o.String("path", "/")
+	if err != nil {
+		return nil, err
+	}
+	interval, err := o.Duration("interval", 0)
+	if err != nil {
+		return nil, err
+	}
+	format, err := o.Format("format", "")
+	if err != nil {
+		return nil, err
+	}
+	m := diskspace.New(path)
+	if interval > 0 {
+		m.RefreshInterval(interval)
+	}
+	if format != nil {
+		m.Output(func(i diskspace.Info) bar.Output { return format(i) })
+	}
+	return m, nil
+}
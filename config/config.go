@@ -0,0 +1,268 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config provides a module that constructs and arranges modules
+// from a declarative YAML file, and rearranges them whenever the file
+// changes, without restarting the bar. This allows intervals, formats, and
+// colors to be tweaked without recompiling.
+//
+// The file lists the modules to display, in order. Each module has a type,
+// options specific to that type, and the common options "color",
+// "background", and "border". For example:
+//
+//	modules:
+//	  - type: diskspace
+//	    path: /home
+//	    interval: 1m
+//	    format: "{{.AvailPct}}% free"
+//	  - type: clock
+//	    format: "Mon Jan 2 15:04"
+//	    color: "#ffcc00"
+//
+// Modules are kept running when only their common options change, or when
+// they are moved around. Changing any other option constructs a new module.
+// Since modules cannot be stopped once started, modules that are replaced or
+// removed from the file are paused instead: schedulers that they own (see
+// timing.Scheduler.OwnedBy) stop firing, and modules that implement
+// bar.PausableModule are also paused.
+// Modules that update only on external events (e.g. file or dbus watchers)
+// keep running, but their output is no longer shown. Modules that are added
+// back to the file are constructed again.
+package config // import "barista.run/config"
+
+import (
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"sync"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/base/watchers/file"
+	"barista.run/core"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/sink"
+
+	"gopkg.in/yaml.v2"
+)
+
+// commonOptions are handled by the config module for all module types.
+var commonOptions = []string{"type", "color", "background", "border"}
+
+// instance is a module constructed from the config file.
+type instance struct {
+	module  *core.Module
+	started bool
+	paused  bool
+
+	mu     sync.Mutex
+	output bar.Segments
+}
+
+func (i *instance) lastOutput() bar.Segments {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.output
+}
+
+// entry is a module at a position in the config file.
+type entry struct {
+	*instance
+	color, background, border color.Color
+}
+
+// Module represents a bar.Module that displays the modules from a config
+// file.
+type Module struct {
+	filename string
+	notifyFn func()
+	notifyCh <-chan struct{}
+	// Instances are keyed by type and options, so that unchanged modules
+	// are reused when the file is reloaded. Instances that are not in the
+	// file are dropped whenever it is loaded successfully. Only used by
+	// Stream.
+	instances map[string]*instance
+}
+
+// Load constructs a module that displays the modules from the given config
+// file, and updates them when the file changes.
+func Load(filename string) *Module {
+	m := &Module{filename: filename, instances: map[string]*instance{}}
+	m.notifyFn, m.notifyCh = notifier.New()
+	l.Label(m, filename)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	w := file.Watch(m.filename)
+	defer w.Unsubscribe()
+
+	entries, err := m.load()
+	m.activate(nil, entries)
+	for {
+		out := outputs.Group()
+		if err != nil {
+			// Keep the previous modules while the config is invalid, but
+			// show the error so it can be fixed.
+			out.Append(outputs.Error(err))
+		}
+		for _, e := range entries {
+			o := outputs.Group(e.lastOutput())
+			if e.color != nil {
+				o.Color(e.color)
+			}
+			if e.background != nil {
+				o.Background(e.background)
+			}
+			if e.border != nil {
+				o.Border(e.border)
+			}
+			out.Append(o)
+		}
+		s.Output(out)
+		select {
+		case <-m.notifyCh:
+		case <-w.Updates:
+			var newEntries []entry
+			newEntries, err = m.load()
+			if err == nil {
+				l.Log("Reloaded %s", m.filename)
+				m.activate(entries, newEntries)
+				entries = newEntries
+			}
+		case err := <-w.Errors:
+			s.Error(err)
+			return
+		}
+	}
+}
+
+// activate starts modules that were added to the config, and pauses modules
+// that were removed from it.
+func (m *Module) activate(old, new []entry) {
+	active := map[*instance]bool{}
+	for _, e := range new {
+		active[e.instance] = true
+	}
+	for _, e := range old {
+		if !active[e.instance] && !e.paused {
+			e.paused = true
+			e.module.Pause()
+		}
+	}
+	for _, e := range new {
+		if !e.started {
+			e.started = true
+			go e.module.Stream(m.sink(e.instance))
+		}
+	}
+}
+
+func (m *Module) sink(i *instance) bar.Sink {
+	return sink.Func(func(out bar.Segments) {
+		i.mu.Lock()
+		i.output = out
+		i.mu.Unlock()
+		m.notifyFn()
+	})
+}
+
+type configFile struct {
+	Modules []Options `yaml:"modules"`
+}
+
+// load reads the config file and returns the modules in it, reusing any
+// existing instances with the same type and options.
+func (m *Module) load() ([]entry, error) {
+	data, err := ioutil.ReadFile(m.filename)
+	if err != nil {
+		return nil, err
+	}
+	return m.parse(data)
+}
+
+func (m *Module) parse(data []byte) ([]entry, error) {
+	var f configFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", m.filename, err)
+	}
+	var entries []entry
+	instances := map[string]*instance{}
+	for idx, opts := range f.Modules {
+		e, err := m.entry(opts, instances)
+		if err != nil {
+			return nil, fmt.Errorf("%s: module #%d: %v", m.filename, idx+1, err)
+		}
+		entries = append(entries, e)
+	}
+	// Drop instances that are no longer in the config, so that they are
+	// constructed again if they are added back, instead of being kept around
+	// (and paused) indefinitely.
+	m.instances = instances
+	return entries, nil
+}
+
+// entry returns the entry for a module in the config, reusing an existing
+// instance if possible. The instance used is added to instances.
+func (m *Module) entry(opts Options, instances map[string]*instance) (e entry, err error) {
+	kind, err := opts.String("type", "")
+	if err != nil {
+		return e, err
+	}
+	if kind == "" {
+		return e, fmt.Errorf("missing type")
+	}
+	if e.color, err = opts.Color("color"); err != nil {
+		return e, err
+	}
+	if e.background, err = opts.Color("background"); err != nil {
+		return e, err
+	}
+	if e.border, err = opts.Color("border"); err != nil {
+		return e, err
+	}
+	moduleOpts := Options{}
+	for k, v := range opts {
+		moduleOpts[k] = v
+	}
+	for _, k := range commonOptions {
+		delete(moduleOpts, k)
+	}
+	// Maps are marshalled with sorted keys, so equal options have equal keys.
+	key, err := yaml.Marshal(moduleOpts)
+	if err != nil {
+		return e, err
+	}
+	instKey := kind + "\n" + string(key)
+	if e.instance = m.instances[instKey]; e.instance != nil {
+		instances[instKey] = e.instance
+		return e, nil
+	}
+	f, ok := factory(kind)
+	if !ok {
+		return e, fmt.Errorf("unknown type %q", kind)
+	}
+	mod, err := f(moduleOpts)
+	if err != nil {
+		return e, fmt.Errorf("%s: %v", kind, err)
+	}
+	e.instance = &instance{module: core.NewModule(mod)}
+	// Also kept if the rest of the config is invalid, so that the module is
+	// not constructed again while the config is being fixed.
+	m.instances[instKey] = e.instance
+	instances[instKey] = e.instance
+	return e, nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/colors"
+	"barista.run/modules/static"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pausable is a static module that records pauses and resumes.
+type pausable struct {
+	*static.Module
+	name   string
+	events *eventLog
+}
+
+func (p pausable) Pause()  { p.events.add("pause " + p.name) }
+func (p pausable) Resume() { p.events.add("resume " + p.name) }
+
+type eventLog struct {
+	sync.Mutex
+	events []string
+}
+
+func (e *eventLog) add(event string) {
+	e.Lock()
+	defer e.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *eventLog) get() []string {
+	e.Lock()
+	defer e.Unlock()
+	r := e.events
+	e.events = nil
+	return r
+}
+
+func setupTestModules() *eventLog {
+	log := &eventLog{}
+	Register("test", func(o Options) (bar.Module, error) {
+		name, err := o.String("name", "")
+		if err != nil {
+			return nil, err
+		}
+		log.add("new " + name)
+		return pausable{static.New(outputs.Text(name)), name, log}, nil
+	})
+	return log
+}
+
+func writeConfig(t *testing.T, filename, content string) {
+	tmp := filename + ".tmp"
+	require.NoError(t, ioutil.WriteFile(tmp, []byte(content), 0644))
+	require.NoError(t, os.Rename(tmp, filename))
+}
+
+const drainWait = 200 * time.Millisecond
+
+func TestReload(t *testing.T) {
+	log := setupTestModules()
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "bar.yaml")
+	writeConfig(t, filename, `
+modules:
+  - type: test
+    name: a
+  - type: test
+    name: b
+    color: "#ff0000"
+`)
+
+	testBar.New(t)
+	testBar.Run(Load(filename))
+	out := testBar.Drain(drainWait, "on start")
+	out.AssertText([]string{"a", "b"})
+	col, _ := out.At(1).Segment().GetColor()
+	require.Equal(t, colors.Hex("#ff0000"), col)
+	require.ElementsMatch(t, []string{"new a", "new b"}, log.get())
+
+	writeConfig(t, filename, `
+modules:
+  - type: test
+    name: b
+    color: bad
+  - type: test
+    name: a
+`)
+	out = testBar.Drain(drainWait, "on reorder")
+	out.AssertText([]string{"b", "a"})
+	col, _ = out.At(0).Segment().GetColor()
+	require.Equal(t, colors.Scheme("bad"), col)
+	require.Empty(t, log.get(), "modules are reused")
+
+	writeConfig(t, filename, `
+modules:
+  - type: test
+    name: c
+`)
+	testBar.Drain(drainWait, "on change").AssertText([]string{"c"})
+	require.ElementsMatch(t, []string{"new c", "pause a", "pause b"}, log.get())
+
+	writeConfig(t, filename, `
+modules:
+  - type: test
+    name: a
+  - type: unknown
+`)
+	out = testBar.Drain(drainWait, "on invalid config")
+	require.Contains(t, out.At(0).AssertError(), `unknown type "unknown"`)
+	out.At(1).AssertText("c", "previous modules kept")
+	require.Equal(t, []string{"new a"}, log.get(), "not started")
+
+	writeConfig(t, filename, `
+modules:
+  - type: static
+    text: "|"
+  - type: test
+    name: a
+`)
+	testBar.Drain(drainWait, "on fix").AssertText([]string{"|", "a"})
+	require.Equal(t, []string{"pause c"}, log.get())
+
+	writeConfig(t, filename, `
+modules:
+  - type: test
+    name: c
+`)
+	testBar.Drain(drainWait, "on re-adding").AssertText([]string{"c"})
+	require.ElementsMatch(t, []string{"new c", "pause a"}, log.get(),
+		"removed modules are constructed again")
+}
+
+func TestErrors(t *testing.T) {
+	setupTestModules()
+	m := Load("/config.yaml")
+	for _, tc := range []struct {
+		config string
+		err    string
+	}{
+		{"modules: [{name: a}]", "missing type"},
+		{"modules: [{type: foo}]", `unknown type "foo"`},
+		{"modules: [{type: test, name: 1}]", "name: expected a string"},
+		{"modules: [{type: test, color: '#zz'}]", "invalid color"},
+		{"modules: [{type: clock, timezone: Nowhere/Land}]", "unknown time zone"},
+		{"modules: [{type: shell}]", "missing command"},
+		{"modules: [{type: cpuload, interval: soon}]", "interval"},
+		{"modules: [{type: diskspace, format: '{{.Foo'}]", "format"},
+		{"modules: {", "config.yaml"},
+	} {
+		_, err := m.parse([]byte(tc.config))
+		require.Error(t, err, tc.config)
+		require.Contains(t, err.Error(), tc.err, tc.config)
+	}
+
+	entries, err := m.parse([]byte(`
+modules:
+  - {type: clock, timezone: Europe/London, format: "15:04:05"}
+  - {type: shell, command: echo hi, interval: 5s, format: "[{{.}}]"}
+  - {type: cpuload, interval: 5s, format: "{{printf \"%.1f\" .Min1}}"}
+  - {type: diskspace, path: /home, interval: 1m, format: "{{.AvailPct}}%"}
+`))
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/colors"
+	"barista.run/outputs"
+)
+
+// Options holds the settings for a single module from the config file.
+type Options map[string]interface{}
+
+// String returns the string value of an option, or def if it is not set.
+func (o Options) String(key, def string) (string, error) {
+	v, ok := o[key]
+	if !ok {
+		return def, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return def, fmt.Errorf("%s: expected a string, got %v", key, v)
+	}
+	return s, nil
+}
+
+// Int returns the integer value of an option, or def if it is not set.
+func (o Options) Int(key string, def int) (int, error) {
+	v, ok := o[key]
+	if !ok {
+		return def, nil
+	}
+	i, ok := v.(int)
+	if !ok {
+		return def, fmt.Errorf("%s: expected an integer, got %v", key, v)
+	}
+	return i, nil
+}
+
+// Duration returns the value of a duration option (e.g. "5s"), or def if
+// it is not set.
+func (o Options) Duration(key string, def time.Duration) (time.Duration, error) {
+	s, err := o.String(key, "")
+	if err != nil || s == "" {
+		return def, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return def, fmt.Errorf("%s: %v", key, err)
+	}
+	return d, nil
+}
+
+// Color returns the value of a color option, which can be either a hex
+// color (e.g. "#ff0000"), or the name of a color from the scheme (e.g.
+// "bad"). It returns nil if the option is not set.
+func (o Options) Color(key string) (color.Color, error) {
+	s, err := o.String(key, "")
+	if err != nil || s == "" {
+		return nil, err
+	}
+	if strings.HasPrefix(s, "#") {
+		if c := colors.Hex(s); c != nil {
+			return c, nil
+		}
+		return nil, fmt.Errorf("%s: invalid color %q", key, s)
+	}
+	return colors.Scheme(s), nil
+}
+
+// Format returns a function that formats the module's data as text using
+// the text/template in the given option, or def if it is not set. For
+// example, "{{.AvailPct}}% free" for a diskspace module. It returns nil if
+// the option is not set and there is no default.
+func (o Options) Format(key, def string) (func(interface{}) bar.Output, error) {
+	s, err := o.String(key, def)
+	if err != nil || s == "" {
+		return nil, err
+	}
+	tmpl, err := template.New(key).Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", key, err)
+	}
+	return func(data interface{}) bar.Output {
+		var out strings.Builder
+		if err := tmpl.Execute(&out, data); err != nil {
+			return outputs.Error(err)
+		}
+		return outputs.Text(out.String())
+	}, nil
+}
+
+// Factory constructs a module from its options.
+type Factory func(Options) (bar.Module, error)
+
+var factoriesMu sync.RWMutex
+var factories = map[string]Factory{}
+
+// Register adds a module type that can be used in config files, replacing
+// any existing type with the same name. Options that apply to all modules,
+// such as "type" and "color", are not passed to the factory.
+func Register(kind string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[kind] = factory
+}
+
+func factory(kind string) (Factory, bool) {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	f, ok := factories[kind]
+	return f, ok
+}
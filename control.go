@@ -0,0 +1,256 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	l "barista.run/logging"
+)
+
+// Pager is implemented by groups that show one page at a time, such as
+// group.Paged and group.Cycling, so that pages can be switched using the
+// control socket.
+type Pager interface {
+	Next()
+	Previous()
+	Show(int)
+}
+
+// AddPager names a pager, so that its pages can be switched using the
+// control socket.
+func AddPager(name string, p Pager) {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	instance.pagers[name] = p
+}
+
+// ControlSocket enables a unix socket at the given path that accepts
+// commands to control the running bar, one per connection. Modules and
+// pagers are referred to by the names given in AddNamed and AddPager.
+// The supported commands are:
+//
+//	refresh [<module>]         refreshes a module, or all modules
+//	show|hide|toggle <module>  changes the visibility of a module
+//	page <pager> next|prev|<n> switches the page of a group
+//	finelog [<module>...]      sets the modules to fine log (debuglog only)
+//	loglevel <prefix> <level>  sets the log level (off, log, or fine) for
+//	                           modules with the prefix (debuglog only)
+//	logs                       prints recent log statements as JSON
+//	dump                       prints the current output as JSON
+//
+// The baristactl command can be used to send commands, e.g. for key
+// bindings. It uses $XDG_RUNTIME_DIR/barista.sock unless given a -socket
+// flag. Must be called before Run.
+func ControlSocket(path string) {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot add a control socket after .Run()")
+	}
+	instance.controlSocket = path
+}
+
+// listenControl starts listening for commands on the control socket,
+// replacing any socket left over from a previous run.
+func (b *i3Bar) listenControl(path string) error {
+	removeSocket(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	l.Log("Listening for commands on %s", path)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				l.Log("Control socket closed: %v", err)
+				return
+			}
+			go b.handleControl(conn)
+		}
+	}()
+	return nil
+}
+
+// removeSocket removes a socket left over at the path, but leaves any other
+// kind of file in place, so that a mistyped path cannot delete a file.
+func removeSocket(path string) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+}
+
+func (b *i3Bar) handleControl(conn net.Conn) {
+	defer conn.Close()
+	s := bufio.NewScanner(conn)
+	if !s.Scan() {
+		return
+	}
+	result, err := b.runCommand(strings.Fields(s.Text()))
+	if err != nil {
+		fmt.Fprintf(conn, "error: %v\n", err)
+		return
+	}
+	if result != "" {
+		fmt.Fprintln(conn, result)
+	}
+}
+
+func (b *i3Bar) runCommand(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("no command")
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "refresh":
+		switch len(args) {
+		case 0:
+			RefreshAll()
+			return "", nil
+		case 1:
+			return "", Refresh(args[0])
+		}
+		return "", fmt.Errorf("usage: refresh [<module>]")
+	case "show", "hide", "toggle":
+		if len(args) != 1 {
+			return "", fmt.Errorf("usage: %s <module>", cmd)
+		}
+		return "", b.controlModule(cmd, args[0])
+	case "page":
+		if len(args) != 2 {
+			return "", fmt.Errorf("usage: page <pager> next|prev|<n>")
+		}
+		return "", b.switchPage(args[0], args[1])
+	case "finelog":
+		l.SetFineLog(args...)
+		return "", nil
+	case "loglevel":
+		if len(args) != 2 {
+			return "", fmt.Errorf("usage: loglevel <prefix> off|log|fine")
+		}
+		level, err := l.ParseLevel(args[1])
+		if err != nil {
+			return "", err
+		}
+		l.SetLevel(args[0], level)
+		return "", nil
+	case "logs":
+		var out []string
+		for _, e := range l.Recent() {
+			j, err := json.Marshal(e)
+			if err != nil {
+				return "", err
+			}
+			out = append(out, string(j))
+		}
+		return strings.Join(out, "\n"), nil
+	case "dump":
+		return b.dump()
+	}
+	return "", fmt.Errorf("unknown command %q", cmd)
+}
+
+func (b *i3Bar) controlModule(cmd, name string) error {
+	b.Lock()
+	idx, ok := b.names[name]
+	if !ok {
+		b.Unlock()
+		return fmt.Errorf("unknown module %q", name)
+	}
+	switch cmd {
+	case "show":
+		delete(b.hidden, idx)
+	case "hide":
+		b.hidden[idx] = true
+	case "toggle":
+		if b.hidden[idx] {
+			delete(b.hidden, idx)
+		} else {
+			b.hidden[idx] = true
+		}
+	}
+	b.Unlock()
+	b.refresh()
+	return nil
+}
+
+func (b *i3Bar) switchPage(name, page string) error {
+	b.Lock()
+	p, ok := b.pagers[name]
+	b.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown pager %q", name)
+	}
+	switch page {
+	case "next":
+		p.Next()
+	case "prev", "previous":
+		p.Previous()
+	default:
+		n, err := strconv.Atoi(page)
+		if err != nil {
+			return fmt.Errorf("invalid page %q", page)
+		}
+		p.Show(n)
+	}
+	return nil
+}
+
+// moduleDump is the output of a module in the dump command.
+type moduleDump struct {
+	Name     string                   `json:"name,omitempty"`
+	Hidden   bool                     `json:"hidden,omitempty"`
+	Stale    bool                     `json:"stale,omitempty"`
+	Segments []map[string]interface{} `json:"segments"`
+}
+
+// dump returns the last output of each module as JSON, in the format sent
+// to i3bar.
+func (b *i3Bar) dump() (string, error) {
+	b.Lock()
+	names := map[int]string{}
+	for name, idx := range b.names {
+		names[idx] = name
+	}
+	hidden := map[int]bool{}
+	for idx := range b.hidden {
+		hidden[idx] = true
+	}
+	b.Unlock()
+	var dump []moduleDump
+	for idx, segments := range b.moduleSet.LastOutputs() {
+		d := moduleDump{
+			Name:     names[idx],
+			Hidden:   hidden[idx],
+			Stale:    b.moduleSet.Stale(idx),
+			Segments: []map[string]interface{}{},
+		}
+		for _, s := range segments {
+			d.Segments = append(d.Segments, i3map(s))
+		}
+		dump = append(dump, d)
+	}
+	out, err := json.MarshalIndent(dump, "", "  ")
+	return string(out), err
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	testModule "barista.run/testing/module"
+
+	"barista.run/testing/mockio"
+	"github.com/stretchr/testify/require"
+)
+
+type refresherModule struct {
+	*testModule.TestModule
+	refreshed chan bool
+}
+
+func (r refresherModule) Refresh() { r.refreshed <- true }
+
+type testPager struct{ commands chan string }
+
+func (p testPager) Next()      { p.commands <- "next" }
+func (p testPager) Previous()  { p.commands <- "prev" }
+func (p testPager) Show(n int) { p.commands <- strings.Repeat("+", n) }
+
+func sendCommand(t *testing.T, socket, cmd string) string {
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte(cmd + "\n"))
+	require.NoError(t, err)
+	out, err := ioutil.ReadAll(conn)
+	require.NoError(t, err)
+	return strings.TrimSpace(string(out))
+}
+
+func TestControlSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "barista")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "control.sock")
+
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+
+	weather := refresherModule{testModule.New(t), make(chan bool, 1)}
+	clock := testModule.New(t)
+	other := testModule.New(t)
+	AddNamed("weather", weather)
+	AddNamed("clock", clock)
+	require.Panics(t, func() { AddNamed("clock", other) })
+	pager := testPager{make(chan string, 1)}
+	AddPager("pages", pager)
+	ControlSocket(socket)
+	require.EqualError(t, Refresh("weather"), "bar is not running")
+	go Run(other)
+
+	_, err = mockStdout.ReadUntil('[', time.Second)
+	require.Nil(t, err, "output array started without any errors")
+	mockStdin.WriteString("[")
+	weather.AssertStarted()
+	clock.AssertStarted()
+	other.AssertStarted()
+
+	weather.OutputText("sunny")
+	readOutput(t, mockStdout)
+	clock.OutputText("12:00")
+	readOutput(t, mockStdout)
+	other.OutputText("other")
+	require.Equal(t, []string{"sunny", "12:00", "other"}, readOutputTexts(t, mockStdout))
+
+	require.Empty(t, sendCommand(t, socket, "refresh weather"))
+	require.True(t, <-weather.refreshed)
+	require.Equal(t, `error: clock does not support refresh`,
+		sendCommand(t, socket, "refresh clock"))
+	require.Empty(t, sendCommand(t, socket, "refresh"))
+	require.True(t, <-weather.refreshed, "refresh all")
+	require.NoError(t, Refresh("weather"))
+	require.True(t, <-weather.refreshed)
+	require.EqualError(t, Refresh("nope"), `unknown module "nope"`)
+	require.Equal(t, `error: unknown module "nope"`,
+		sendCommand(t, socket, "hide nope"))
+
+	require.Empty(t, sendCommand(t, socket, "toggle clock"))
+	require.Equal(t, []string{"sunny", "other"}, readOutputTexts(t, mockStdout))
+	require.Empty(t, sendCommand(t, socket, "hide weather"))
+	require.Equal(t, []string{"other"}, readOutputTexts(t, mockStdout))
+
+	var dump []moduleDump
+	require.NoError(t, json.Unmarshal([]byte(sendCommand(t, socket, "dump")), &dump))
+	require.Len(t, dump, 3)
+	require.Equal(t, "weather", dump[0].Name)
+	require.True(t, dump[0].Hidden)
+	require.Equal(t, "sunny", dump[0].Segments[0]["full_text"])
+	require.Equal(t, "", dump[2].Name)
+	require.False(t, dump[2].Hidden)
+
+	require.Empty(t, sendCommand(t, socket, "show weather"))
+	require.Equal(t, []string{"sunny", "other"}, readOutputTexts(t, mockStdout))
+	require.Empty(t, sendCommand(t, socket, "toggle clock"))
+	require.Equal(t, []string{"sunny", "12:00", "other"}, readOutputTexts(t, mockStdout))
+
+	require.Empty(t, sendCommand(t, socket, "page pages next"))
+	require.Equal(t, "next", <-pager.commands)
+	require.Empty(t, sendCommand(t, socket, "page pages 2"))
+	require.Equal(t, "++", <-pager.commands)
+	require.Equal(t, `error: invalid page "last"`,
+		sendCommand(t, socket, "page pages last"))
+
+	require.Empty(t, sendCommand(t, socket, "finelog bar:core"))
+	require.Empty(t, sendCommand(t, socket, "loglevel bar: fine"))
+	require.Equal(t, `error: unknown log level "loud"`,
+		sendCommand(t, socket, "loglevel bar: loud"))
+	require.NotContains(t, sendCommand(t, socket, "logs"), "error")
+	require.Equal(t, `error: unknown command "explode"`,
+		sendCommand(t, socket, "explode"))
+}
+
+func TestRemoveSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "barista")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file")
+	require.NoError(t, ioutil.WriteFile(file, []byte("data"), 0644))
+	removeSocket(file)
+	require.FileExists(t, file, "regular files are not removed")
+
+	socket := filepath.Join(dir, "stale.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+	removeSocket(socket)
+	_, err = os.Lstat(socket)
+	require.True(t, os.IsNotExist(err), "sockets are removed")
+
+	removeSocket(filepath.Join(dir, "missing"))
+}
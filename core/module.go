@@ -17,11 +17,15 @@
 package core
 
 import (
+	"fmt"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"barista.run/bar"
 	"barista.run/base/notifier"
+	"barista.run/colors"
 	l "barista.run/logging"
 	"barista.run/timing"
 )
@@ -31,24 +35,46 @@ import (
 // other modules (group, reformat), and for writing tests.
 // It handles restarting the wrapped module on a left/right/middle click,
 // as well as providing an option to "replay" the last output from the module.
-// It also provides timed output functionality.
+// It also provides timed output functionality, recovers from panics in the
+// wrapped module, restarting it after a delay, and can optionally mark the
+// output as stale if the wrapped module does not update after a refresh.
 type Module struct {
 	original  bar.Module
 	replayCh  <-chan struct{}
 	replayFn  func()
 	restartCh <-chan struct{}
 	restartFn func()
+	// The delay before restarting after a panic, doubled on each panic.
+	crashBackoff time.Duration
+	// If positive, the output is marked stale when the wrapped module does
+	// not produce output within this duration of starting or refreshing.
+	watchdog   time.Duration
+	watchdogFn func()
+	watchdogCh <-chan struct{}
+	stale      int32 // atomic
+	paused     int32 // atomic
 }
 
+const (
+	// minCrashBackoff is the delay before restarting a module that panicked.
+	minCrashBackoff = time.Second
+	// maxCrashBackoff is the maximum delay before restarting a module that
+	// keeps panicking. Modules that run for longer without panicking are
+	// restarted after minCrashBackoff again.
+	maxCrashBackoff = 5 * time.Minute
+)
+
 // NewModule wraps an existing bar.Module with core barista functionality,
 // such as restarts and the ability to replay the last output.
 func NewModule(original bar.Module) *Module {
 	m := &Module{original: original}
 	m.replayFn, m.replayCh = notifier.New()
 	m.restartFn, m.restartCh = notifier.New()
+	m.watchdogFn, m.watchdogCh = notifier.New()
 	l.Attach(original, m, "~core")
 	l.Register(m, "replayCh")
 	l.Register(m, "restartCh")
+	l.Register(m, "watchdogCh")
 	return m
 }
 
@@ -69,15 +95,50 @@ func (m *Module) runLoop(realSink bar.Sink) {
 	finished := false
 	var refreshFn func()
 	if r, ok := m.original.(bar.RefresherModule); ok {
-		refreshFn = r.Refresh
+		refreshFn = func() {
+			m.watchdogFn()
+			r.Refresh()
+		}
 	}
 	timedSink := newTimedSink(realSink, refreshFn)
 	l.Attach(m.original, timedSink, "~internal-sink")
 	outputCh := make(chan bar.Output)
 	innerSink := func(o bar.Output) { outputCh <- o }
 	doneCh := make(chan struct{})
+	crashCh := make(chan error)
+	startTime := timing.Now()
+	var autoRestart <-chan struct{}
+
+	watchdog := timing.NewScheduler()
+	defer watchdog.Close()
+	var watchdogStart time.Time
+	armWatchdog := func() {
+		if m.watchdog > 0 && watchdogStart.IsZero() {
+			watchdogStart = timing.Now()
+			watchdog.After(m.watchdog)
+		}
+	}
+	disarmWatchdog := func() {
+		watchdog.Stop()
+		watchdogStart = time.Time{}
+		if atomic.SwapInt32(&m.stale, 0) == 1 {
+			l.Log("%s is no longer stale", l.ID(m.original))
+		}
+	}
+	armWatchdog()
+	// Schedulers are released when the module stops, so any that it owns
+	// after restarting need to be paused again.
+	if atomic.LoadInt32(&m.paused) == 1 {
+		timing.PauseOwner(m.original)
+	}
 
 	go func(m bar.Module, innerSink bar.Sink, doneCh chan<- struct{}) {
+		defer func() {
+			if r := recover(); r != nil {
+				l.Log("%s panicked: %v\n%s", l.ID(m), r, debug.Stack())
+				crashCh <- fmt.Errorf("panic: %v", r)
+			}
+		}()
 		l.Fine("%s started", l.ID(m))
 		m.Stream(innerSink)
 		l.Fine("%s finished", l.ID(m))
@@ -89,15 +150,50 @@ func (m *Module) runLoop(realSink bar.Sink) {
 		select {
 		case out = <-outputCh:
 			started = true
+			disarmWatchdog()
 			timedSink.Output(out, true)
+		case <-m.watchdogCh:
+			if !finished {
+				armWatchdog()
+			}
+		case <-watchdog.C:
+			if finished {
+				continue
+			}
+			atomic.StoreInt32(&m.stale, 1)
+			l.Log("%s has not updated in %v, marking as stale",
+				l.ID(m.original), timing.Now().Sub(watchdogStart))
+			timedSink.Output(markStale(out), true)
 		case <-doneCh:
 			finished = true
+			timing.ReleaseOwner(m.original)
+			disarmWatchdog()
 			timedSink.Stop()
 			out = toSegments(out)
 			l.Fine("%s: set restart handlers", l.ID(m))
 			timedSink.Output(addRestartHandlers(out, m.restartFn), false)
+		case err := <-crashCh:
+			finished = true
+			timing.ReleaseOwner(m.original)
+			disarmWatchdog()
+			timedSink.Stop()
+			m.nextCrashBackoff(timing.Now().Sub(startTime))
+			l.Log("%s crashed, restarting in %v", l.ID(m.original), m.crashBackoff)
+			sch := timing.NewScheduler().After(m.crashBackoff)
+			defer sch.Close()
+			autoRestart = sch.C
+			out = bar.TextSegment("crashed (click to restart)").
+				Error(err).ShortText("!").Urgent(true)
+			timedSink.Output(addRestartHandlers(out, m.restartFn), false)
+		case <-autoRestart:
+			l.Fine("%s restarted after crash", l.ID(m.original))
+			timedSink.Output(stripErrors(out, l.ID(m)), false)
+			return
 		case <-m.replayCh:
-			if started {
+			if started && m.Stale() {
+				l.Fine("%s: replay last output as stale", l.ID(m))
+				timedSink.Output(markStale(out), true)
+			} else if started {
 				l.Fine("%s: replay last output", l.ID(m))
 				timedSink.Output(out, true)
 			}
@@ -111,11 +207,71 @@ func (m *Module) runLoop(realSink bar.Sink) {
 	}
 }
 
+// nextCrashBackoff updates the delay before restarting a module that
+// panicked after running for the given duration.
+func (m *Module) nextCrashBackoff(ranFor time.Duration) {
+	switch {
+	case m.crashBackoff == 0, ranFor > maxCrashBackoff:
+		m.crashBackoff = minCrashBackoff
+	case m.crashBackoff < maxCrashBackoff:
+		m.crashBackoff *= 2
+	}
+	if m.crashBackoff > maxCrashBackoff {
+		m.crashBackoff = maxCrashBackoff
+	}
+}
+
 // Replay sends the last output from the wrapped module to the sink.
 func (m *Module) Replay() {
 	m.replayFn()
 }
 
+// Refresh refreshes the wrapped module, if it supports refreshing, and
+// returns whether it does.
+func (m *Module) Refresh() bool {
+	r, ok := m.original.(bar.RefresherModule)
+	if ok {
+		l.Fine("%s refreshed", l.ID(m.original))
+		m.watchdogFn()
+		r.Refresh()
+	}
+	return ok
+}
+
+// SetWatchdog marks the output of the wrapped module as stale if it does not
+// produce any output within the given duration of starting or refreshing.
+// A zero duration disables the watchdog. It must be called before Stream.
+func (m *Module) SetWatchdog(deadline time.Duration) {
+	m.watchdog = deadline
+}
+
+// Stale returns true if the wrapped module has not produced any output
+// within the watchdog duration of starting or refreshing.
+func (m *Module) Stale() bool {
+	return atomic.LoadInt32(&m.stale) == 1
+}
+
+// Pause pauses the schedulers owned by the wrapped module (see
+// timing.Scheduler.OwnedBy), and the module itself if it supports pausing.
+func (m *Module) Pause() {
+	l.Fine("%s paused", l.ID(m.original))
+	atomic.StoreInt32(&m.paused, 1)
+	timing.PauseOwner(m.original)
+	if p, ok := m.original.(bar.PausableModule); ok {
+		p.Pause()
+	}
+}
+
+// Resume resumes the wrapped module and its schedulers.
+func (m *Module) Resume() {
+	l.Fine("%s resumed", l.ID(m.original))
+	atomic.StoreInt32(&m.paused, 0)
+	timing.ResumeOwner(m.original)
+	if p, ok := m.original.(bar.PausableModule); ok {
+		p.Resume()
+	}
+}
+
 // isRestartableClick checks whether a click event should restart the
 // wrapped module. A left/right/middle click will restart the module.
 func isRestartableClick(e bar.Event) bool {
@@ -140,6 +296,23 @@ func stripErrors(o bar.Output, logCtx string) bar.Segments {
 	return out
 }
 
+// defaultStaleColor is the text colour used for stale output if the colour
+// scheme does not define a "stale" colour.
+var defaultStaleColor = colors.Hex("#808080")
+
+// markStale sets the colour of all segments to indicate stale output.
+func markStale(o bar.Output) bar.Segments {
+	c := colors.Scheme("stale")
+	if c == nil {
+		c = defaultStaleColor
+	}
+	var out bar.Segments
+	for _, s := range toSegments(o) {
+		out = append(out, s.Clone().Color(c))
+	}
+	return out
+}
+
 // addRestartHandlers replaces all click handlers with a function
 // that restarts the module. This is used on the last output of
 // the wrapped module after the original finishes.
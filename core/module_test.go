@@ -127,6 +127,28 @@ func TestRestart(t *testing.T) {
 	tm.AssertStarted("on middle click")
 }
 
+func TestPauseReleasesOwnerOnFinish(t *testing.T) {
+	timing.TestMode()
+	tm := testModule.New(t)
+	sch := timing.NewScheduler().Every(time.Minute).OwnedBy(tm)
+	m := NewModule(tm)
+	ch, sink := sink.New()
+	go m.Stream(sink)
+	tm.AssertStarted()
+
+	m.Pause()
+	timing.AdvanceBy(time.Minute)
+	notifier.AssertNoUpdate(t, sch.C, "while paused")
+
+	tm.Output(outputs.Text("done"))
+	nextOutput(t, ch, "on output")
+	tm.Close()
+	nextOutput(t, ch, "on close (to set click handlers)")
+	notifier.AssertNotified(t, sch.C, "when module finishes")
+	timing.AdvanceBy(time.Minute)
+	notifier.AssertNotified(t, sch.C, "no longer paused after finishing")
+}
+
 func TestTimedOutput(t *testing.T) {
 	timing.TestMode()
 	tm := testModule.New(t).SkipClickHandlers()
@@ -280,6 +302,11 @@ func TestRefresh(t *testing.T) {
 	notifier.AssertNotified(t, refreshCh, "On middle-click")
 	tm.AssertNotClicked("on middle-click")
 
+	require.True(t, m.Refresh())
+	notifier.AssertNotified(t, refreshCh, "On programmatic refresh")
+	require.False(t, NewModule(testModule.New(t)).Refresh(),
+		"not refreshable")
+
 	m.Replay()
 	out = nextOutput(t, ch, "on replay")
 
@@ -329,3 +356,125 @@ func TestRefresh(t *testing.T) {
 	out[0].Click(bar.Event{Button: bar.ButtonLeft})
 	notifier.AssertNoUpdate(t, refreshCh, "left-click on finished module error")
 }
+
+type panickingModule struct {
+	started chan bar.Sink
+	panics  chan interface{}
+}
+
+func (p panickingModule) Stream(s bar.Sink) {
+	p.started <- s
+	panic(<-p.panics)
+}
+
+func TestPanicRecovery(t *testing.T) {
+	timing.TestMode()
+	pm := panickingModule{make(chan bar.Sink), make(chan interface{})}
+	m := NewModule(pm)
+	ch, sink := sink.New()
+	go m.Stream(sink)
+
+	s := <-pm.started
+	s.Output(outputs.Text("ok"))
+	nextOutput(t, ch, "before panic")
+	pm.panics <- "oops"
+
+	out := nextOutput(t, ch, "on panic")
+	require.Len(t, out, 1)
+	txt, _ := out[0].Content()
+	require.Equal(t, "crashed (click to restart)", txt)
+	require.EqualError(t, out[0].GetError(), "panic: oops")
+
+	start := timing.Now()
+	require.Equal(t, time.Second, timing.NextTick().Sub(start),
+		"restarted after backoff")
+	require.Empty(t, nextOutput(t, ch, "on restart"), "crash segment removed")
+	<-pm.started
+
+	pm.panics <- errors.New("again")
+	out = nextOutput(t, ch, "on second panic")
+	require.EqualError(t, out[0].GetError(), "panic: again")
+	start = timing.Now()
+
+	out[0].Click(bar.Event{Button: bar.ButtonLeft})
+	nextOutput(t, ch, "on restart by click")
+	<-pm.started
+
+	pm.panics <- "third"
+	nextOutput(t, ch, "on third panic")
+	require.Equal(t, 4*time.Second, timing.NextTick().Sub(start),
+		"backoff doubles on each panic")
+	nextOutput(t, ch, "on third restart")
+	<-pm.started
+}
+
+func TestCrashBackoff(t *testing.T) {
+	m := NewModule(nil)
+	var backoffs []time.Duration
+	for i := 0; i < 12; i++ {
+		m.nextCrashBackoff(time.Second)
+		backoffs = append(backoffs, m.crashBackoff)
+	}
+	require.Equal(t, minCrashBackoff, backoffs[0])
+	require.Equal(t, 8*time.Second, backoffs[3])
+	require.Equal(t, maxCrashBackoff, backoffs[11])
+
+	m.nextCrashBackoff(time.Hour)
+	require.Equal(t, minCrashBackoff, m.crashBackoff,
+		"reset after running without panics")
+}
+
+func TestWatchdog(t *testing.T) {
+	timing.TestMode()
+	refreshCh := make(chan struct{}, 1)
+	tm := refreshableModule{testModule.New(t), refreshCh}
+	m := NewModule(tm)
+	m.SetWatchdog(time.Minute)
+	ch, sink := sink.New()
+	go m.Stream(sink)
+	tm.AssertStarted()
+
+	start := timing.Now()
+	require.Equal(t, time.Minute, timing.NextTick().Sub(start),
+		"watchdog triggered after deadline")
+	require.Empty(t, nextOutput(t, ch, "on watchdog without output"))
+	require.True(t, m.Stale())
+
+	tm.Output(outputs.Text("foo"))
+	out := nextOutput(t, ch, "on output")
+	require.False(t, m.Stale())
+	_, isSet := out[0].GetColor()
+	require.False(t, isSet, "stale colour removed on output")
+
+	require.True(t, m.Refresh())
+	notifier.AssertNotified(t, refreshCh, "on refresh")
+	assertNoOutput(t, ch, "before deadline")
+	start = timing.Now()
+	require.Equal(t, time.Minute, timing.NextTick().Sub(start),
+		"watchdog triggered after refresh")
+	out = nextOutput(t, ch, "on watchdog")
+	require.True(t, m.Stale())
+	txt, _ := out[0].Content()
+	require.Equal(t, "foo", txt)
+	col, _ := out[0].GetColor()
+	require.Equal(t, defaultStaleColor, col)
+
+	m.Replay()
+	out = nextOutput(t, ch, "on replay")
+	col, _ = out[0].GetColor()
+	require.Equal(t, defaultStaleColor, col, "stale on replay")
+
+	out[0].Click(bar.Event{Button: bar.ButtonMiddle})
+	notifier.AssertNotified(t, refreshCh, "on middle-click while stale")
+
+	tm.Output(outputs.Text("bar"))
+	out = nextOutput(t, ch, "on output after stale")
+	require.False(t, m.Stale())
+	_, isSet = out[0].GetColor()
+	require.False(t, isSet, "stale colour removed on output")
+
+	tm.Output(outputs.Text("baz"))
+	nextOutput(t, ch, "on output without refresh")
+	timing.NextTick()
+	assertNoOutput(t, ch, "watchdog only armed by refresh")
+}
@@ -16,10 +16,12 @@ package core // import "barista.run/core"
 
 import (
 	"sync"
+	"time"
 
 	"barista.run/bar"
 	l "barista.run/logging"
 	"barista.run/sink"
+	"barista.run/timing"
 )
 
 // ModuleSet is a group of modules. It provides a channel for identifying module
@@ -28,14 +30,26 @@ type ModuleSet struct {
 	modules   []*Module
 	updateCh  chan int
 	outputs   []bar.Segments
+	stats     []ModuleStats
 	outputsMu sync.RWMutex
 }
 
+// ModuleStats contains statistics about the updates from a module.
+type ModuleStats struct {
+	// Updates is the number of times the module has updated its output.
+	Updates int
+	// Errors is the number of updates that included an error segment.
+	Errors int
+	// LastUpdate is the time of the most recent update.
+	LastUpdate time.Time
+}
+
 // NewModuleSet creates a ModuleSet with the given modules.
 func NewModuleSet(modules []bar.Module) *ModuleSet {
 	set := &ModuleSet{
 		modules:  make([]*Module, len(modules)),
 		outputs:  make([]bar.Segments, len(modules)),
+		stats:    make([]ModuleStats, len(modules)),
 		updateCh: make(chan int),
 	}
 	for i, m := range modules {
@@ -60,11 +74,50 @@ func (m *ModuleSet) sinkFn(idx int) bar.Sink {
 			l.ID(m), l.ID(m.modules[idx].original))
 		m.outputsMu.Lock()
 		m.outputs[idx] = out
+		m.stats[idx].Updates++
+		m.stats[idx].LastUpdate = timing.Now()
+		for _, s := range out {
+			if s.GetError() != nil {
+				m.stats[idx].Errors++
+				break
+			}
+		}
 		m.outputsMu.Unlock()
 		m.updateCh <- idx
 	})
 }
 
+// Pause pauses the module at a specific position, see Module.Pause.
+func (m *ModuleSet) Pause(idx int) {
+	m.modules[idx].Pause()
+}
+
+// Resume resumes the module at a specific position, see Module.Resume.
+func (m *ModuleSet) Resume(idx int) {
+	m.modules[idx].Resume()
+}
+
+// Refresh refreshes the module at a specific position, if it supports
+// refreshing, and returns whether it does.
+func (m *ModuleSet) Refresh(idx int) bool {
+	return m.modules[idx].Refresh()
+}
+
+// SetWatchdog marks the output of modules as stale if they do not produce
+// any output within the given duration of starting or refreshing. It must be
+// called before Stream.
+func (m *ModuleSet) SetWatchdog(deadline time.Duration) {
+	for _, mod := range m.modules {
+		mod.SetWatchdog(deadline)
+	}
+}
+
+// Stale returns true if the module at a specific position has not produced
+// any output within the watchdog duration of starting or refreshing.
+func (m *ModuleSet) Stale(idx int) bool {
+	return m.modules[idx].Stale()
+}
+
 // Len returns the number of modules in this ModuleSet.
 func (m *ModuleSet) Len() int {
 	return len(m.modules)
@@ -88,3 +141,13 @@ func (m *ModuleSet) LastOutputs() []bar.Segments {
 	copy(cp, m.outputs)
 	return cp
 }
+
+// Stats returns the update statistics of all modules in order. The returned
+// slice will have exactly Len() elements.
+func (m *ModuleSet) Stats() []ModuleStats {
+	m.outputsMu.RLock()
+	defer m.outputsMu.RUnlock()
+	cp := make([]ModuleStats, len(m.stats))
+	copy(cp, m.stats)
+	return cp
+}
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/outputs"
 	testModule "barista.run/testing/module"
 
 	"github.com/stretchr/testify/require"
@@ -77,4 +78,15 @@ func TestModuleSet(t *testing.T) {
 	txt, _ = out[1][0].Content()
 	require.Equal(t, "foo", txt)
 	require.Empty(t, out[2])
+
+	tms[1].Output(outputs.Errorf("oops"))
+	nextUpdate(t, updateCh, "on error")
+	stats := ms.Stats()
+	require.Len(t, stats, 3)
+	require.Equal(t, 1, stats[0].Updates)
+	require.Equal(t, 0, stats[0].Errors)
+	require.Equal(t, 2, stats[1].Updates)
+	require.Equal(t, 1, stats[1].Errors)
+	require.Zero(t, stats[2].Updates)
+	require.True(t, stats[2].LastUpdate.IsZero())
 }
@@ -0,0 +1,134 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/base/value"
+	"github.com/martinlindhe/unit"
+)
+
+var decimalSeparator value.Value // of string
+
+// SetDecimalSeparator sets the decimal separator used when formatting
+// numbers, e.g. "," for most European locales. Defaults to ".".
+func SetDecimalSeparator(sep string) {
+	decimalSeparator.Set(sep)
+}
+
+// localize replaces the decimal point in a formatted number with the
+// configured decimal separator.
+func localize(s string) string {
+	if sep, _ := decimalSeparator.Get().(string); sep != "" && sep != "." {
+		return strings.Replace(s, ".", sep, 1)
+	}
+	return s
+}
+
+// Float formats a number with three significant digits before and after the
+// decimal point combined, e.g. "1.23", "12.3", "123", and "1234".
+func Float(v float64) string {
+	abs := math.Abs(v)
+	prec := 0
+	switch {
+	case abs == 0:
+	case abs < 10:
+		prec = 2
+	case abs < 100:
+		prec = 1
+	}
+	return localize(strconv.FormatFloat(v, 'f', prec, 64))
+}
+
+var suffixesIEC = []string{"", "Ki", "Mi", "Gi", "Ti", "Pi", "Ei"}
+
+func scaled(v float64, base float64, suffixes []string, unit string) string {
+	i := 0
+	for math.Abs(v) >= base && i < len(suffixes)-1 {
+		v /= base
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", v, unit)
+	}
+	return fmt.Sprintf("%s %s%s", Float(v), suffixes[i], unit)
+}
+
+// Size formats a Datasize in SI units with adaptive precision.
+// e.g. Size(1234 * unit.Kilobyte) == "1.23 MB"
+func Size(v unit.Datasize) string {
+	return scaled(v.Bytes(), 1000, suffixesSI[8:], "B")
+}
+
+// ISize formats a Datasize in IEC units with adaptive precision.
+// e.g. ISize(1536 * unit.Mebibyte) == "1.50 GiB"
+func ISize(v unit.Datasize) string {
+	return scaled(v.Bytes(), 1024, suffixesIEC, "B")
+}
+
+// Rate formats a Datarate in SI units with adaptive precision.
+// e.g. Rate(12 * unit.MegabytePerSecond) == "12.0 MB/s"
+func Rate(v unit.Datarate) string {
+	return scaled(v.BytesPerSecond(), 1000, suffixesSI[8:], "B/s")
+}
+
+// IRate formats a Datarate in IEC units with adaptive precision.
+// e.g. IRate(2 * unit.KibibytePerSecond) == "2.00 KiB/s"
+func IRate(v unit.Datarate) string {
+	return scaled(v.BytesPerSecond(), 1024, suffixesIEC, "B/s")
+}
+
+var durationUnits = []struct {
+	d      time.Duration
+	suffix string
+}{
+	{24 * time.Hour, "d"},
+	{time.Hour, "h"},
+	{time.Minute, "m"},
+	{time.Second, "s"},
+}
+
+// ShortDuration formats a duration using up to two of the most significant
+// units, omitting zero values. e.g. "3d", "1h 12m", "5m 3s", "45s".
+// Durations shorter than a second are formatted as "0s".
+func ShortDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	if d < time.Second {
+		return "0s"
+	}
+	last := len(durationUnits) - 1
+	for i, u := range durationUnits {
+		if d < u.d && i < last {
+			continue
+		}
+		out := fmt.Sprintf("%s%d%s", sign, d/u.d, u.suffix)
+		if i < last {
+			next := durationUnits[i+1]
+			if n := d % u.d / next.d; n > 0 {
+				out += fmt.Sprintf(" %d%s", n, next.suffix)
+			}
+		}
+		return out
+	}
+	return ""
+}
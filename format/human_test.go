@@ -0,0 +1,71 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"testing"
+	"time"
+
+	"github.com/martinlindhe/unit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFloat(t *testing.T) {
+	for v, expected := range map[float64]string{
+		0: "0", 1.2345: "1.23", 12.345: "12.3", 123.45: "123",
+		1234.5: "1234", -4.567: "-4.57", 0.004: "0.00",
+	} {
+		require.Equal(t, expected, Float(v), "%v", v)
+	}
+}
+
+func TestSizes(t *testing.T) {
+	require := require.New(t)
+	require.Equal("512 B", Size(512*unit.Byte))
+	require.Equal("1.23 MB", Size(1234*unit.Kilobyte))
+	require.Equal("20.5 GB", Size(20480*unit.Megabyte))
+	require.Equal("1.50 GiB", ISize(1536*unit.Mebibyte))
+	require.Equal("1000 B", ISize(1000*unit.Byte))
+	require.Equal("12.0 MB/s", Rate(12*unit.MegabytePerSecond))
+	require.Equal("2.00 KiB/s", IRate(2*unit.KibibytePerSecond))
+	require.Equal("0 B/s", IRate(0))
+}
+
+func TestShortDuration(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		0:                             "0s",
+		500 * time.Millisecond:        "0s",
+		45 * time.Second:              "45s",
+		5*time.Minute + 3*time.Second: "5m 3s",
+		time.Hour + 12*time.Minute:    "1h 12m",
+		time.Hour + 30*time.Second:    "1h",
+		72 * time.Hour:                "3d",
+		50*time.Hour + 59*time.Minute: "2d 2h",
+		-(90 * time.Second):           "-1m 30s",
+		time.Hour + 12*time.Minute + 59*time.Second: "1h 12m",
+	} {
+		require.Equal(t, expected, ShortDuration(d), "%v", d)
+	}
+}
+
+func TestDecimalSeparator(t *testing.T) {
+	defer SetDecimalSeparator(".")
+	SetDecimalSeparator(",")
+	require := require.New(t)
+	require.Equal("1,23", Float(1.2345))
+	require.Equal("1,50 GiB", ISize(1536*unit.Mebibyte))
+	require.Equal("9,8 KiB", IBytesize(10*unit.Kilobyte))
+	require.Equal("1,5k", SI(1500, "").StringW(3))
+}
@@ -56,13 +56,13 @@ func (v Value) Number(width int) string {
 		width = minWidth
 	}
 	if width > len(v.number) {
-		return strings.Repeat(" ", width-len(v.number)) + v.number
+		return strings.Repeat(" ", width-len(v.number)) + localize(v.number)
 	}
 	out := v.number[:width]
 	if out[width-1] == '.' {
 		out = " " + out[:width-1]
 	}
-	return out
+	return localize(out)
 }
 
 // String formats the value as a string
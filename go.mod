@@ -5,13 +5,16 @@ go 1.13
 require (
 	github.com/coreos/go-systemd/v22 v22.1.0
 	github.com/dustin/go-humanize v1.0.0
+	github.com/emersion/go-imap v1.2.1
 	github.com/fsnotify/fsnotify v1.4.9
 	github.com/godbus/dbus/v5 v5.0.4-0.20200624030016-efee8394fa9a
+	github.com/jezek/xgb v1.3.1
 	github.com/lucasb-eyer/go-colorful v1.0.3
 	github.com/martinlindhe/unit v0.0.0-20190604142932-3b6be53d49af
 	github.com/maximbaz/yubikey-touch-detector v0.0.0-20200307130350-24f6f7449a30
 	github.com/spf13/afero v1.5.1
 	github.com/stretchr/testify v1.6.1
+	github.com/tidwall/gjson v1.14.4
 	github.com/vishvananda/netlink v1.1.0
 	github.com/zalando/go-keyring v0.1.0
 	golang.org/x/crypto v0.0.0-20201016220609-9e8e0b390897
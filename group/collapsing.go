@@ -0,0 +1,68 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package group
+
+import (
+	"sync"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	"barista.run/base/notifier"
+	"barista.run/outputs"
+)
+
+// collapsingGrouper shows a single toggle button when collapsed, and the
+// toggle button followed by all modules when expanded.
+type collapsingGrouper struct {
+	label string
+
+	sync.Mutex
+	expanded bool
+	notifyFn func()
+	notifyCh <-chan struct{}
+}
+
+// Collapsing groups modules behind a single toggle segment showing the label
+// (e.g. "▸ sys"), which expands or collapses the group on click. Modules are
+// paused while collapsed. See the collapsing package for custom buttons and
+// programmatic control.
+func Collapsing(label string, m ...bar.Module) bar.Module {
+	g := &collapsingGrouper{label: label}
+	g.notifyFn, g.notifyCh = notifier.New()
+	return New(g, m...)
+}
+
+func (g *collapsingGrouper) Visible(int) bool {
+	return g.expanded
+}
+
+func (g *collapsingGrouper) Buttons() (start, end bar.Output) {
+	arrow := "▸"
+	if g.expanded {
+		arrow = "▾"
+	}
+	return outputs.Textf("%s %s", arrow, g.label).OnClick(click.Left(g.toggle)), nil
+}
+
+func (g *collapsingGrouper) Signal() <-chan struct{} {
+	return g.notifyCh
+}
+
+func (g *collapsingGrouper) toggle() {
+	g.Lock()
+	defer g.Unlock()
+	g.expanded = !g.expanded
+	g.notifyFn()
+}
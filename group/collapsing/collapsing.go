@@ -18,6 +18,7 @@ and a controller to allow programmatic expansion/collapse.
 
 When collapsed (default state), only a button to expand is visible.
 When expanded, all module outputs are shown, and buttons to collapse.
+Modules are paused while the group is collapsed, see core.Module.Pause.
 */
 package collapsing // import "barista.run/group/collapsing"
 
@@ -0,0 +1,99 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package group
+
+import (
+	"bufio"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	l "barista.run/logging"
+
+	"golang.org/x/sys/unix"
+)
+
+// SwitchOnSignals switches to the next or previous page of a cycling or paged
+// group when the process receives the given signals, so that i3/sway key
+// bindings can switch pages using e.g. `pkill -RTMIN+1 barista`. Either
+// signal can be nil, and if both are, nothing happens. Note that barista uses
+// SIGUSR1 and SIGUSR2 to pause and resume the bar, so these signals can only
+// be used after calling barista.SuppressSignals(true).
+func SwitchOnSignals(p Pager, next, previous os.Signal) {
+	var sigs []os.Signal
+	for _, s := range []os.Signal{next, previous} {
+		if s != nil {
+			sigs = append(sigs, s)
+		}
+	}
+	if len(sigs) == 0 {
+		// signal.Notify would relay all signals, including SIGINT and SIGTERM.
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	go func() {
+		for sig := range ch {
+			l.Fine("%s received %v", l.ID(p), sig)
+			if sig == next {
+				p.Next()
+			} else {
+				p.Previous()
+			}
+		}
+	}()
+}
+
+// SwitchFromFIFO switches the page of a cycling or paged group based on
+// commands written to a named pipe at the given path, which is created if it
+// does not exist. Each line is a command, either "next", "previous", or the
+// index of the page to show, e.g. `echo 1 > /tmp/barista-pages`.
+func SwitchFromFIFO(p Pager, path string) error {
+	if err := unix.Mkfifo(path, 0600); err != nil && !os.IsExist(err) {
+		return err
+	}
+	// Opening the pipe for writing as well prevents EOF when writers close.
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			runCommand(p, strings.TrimSpace(s.Text()))
+		}
+		l.Log("Error reading page commands from %s: %v", path, s.Err())
+	}()
+	return nil
+}
+
+func runCommand(p Pager, cmd string) {
+	switch cmd {
+	case "":
+	case "next":
+		p.Next()
+	case "previous", "prev":
+		p.Previous()
+	default:
+		page, err := strconv.Atoi(cmd)
+		if err != nil {
+			l.Log("Unknown page command %q", cmd)
+			return
+		}
+		p.Show(page)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package group
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+type fakePager chan string
+
+func (f fakePager) Current() int  { return 0 }
+func (f fakePager) Count() int    { return 3 }
+func (f fakePager) Next()         { f <- "next" }
+func (f fakePager) Previous()     { f <- "previous" }
+func (f fakePager) Show(page int) { f <- "show " + strconv.Itoa(page) }
+
+func (f fakePager) assertCall(t *testing.T, expected string, msg string) {
+	select {
+	case c := <-f:
+		require.Equal(t, expected, c, msg)
+	case <-time.After(time.Second):
+		require.Fail(t, "Expected "+expected, msg)
+	}
+}
+
+func (f fakePager) assertNoCall(t *testing.T, msg string) {
+	select {
+	case c := <-f:
+		require.Fail(t, "Unexpected "+c, msg)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestSwitchOnSignals(t *testing.T) {
+	p := make(fakePager, 10)
+	SwitchOnSignals(p, unix.SIGUSR1, unix.SIGUSR2)
+
+	unix.Kill(os.Getpid(), unix.SIGUSR1)
+	p.assertCall(t, "next", "on next signal")
+	unix.Kill(os.Getpid(), unix.SIGUSR2)
+	p.assertCall(t, "previous", "on previous signal")
+
+	none := make(fakePager, 10)
+	SwitchOnSignals(none, nil, nil)
+	unix.Kill(os.Getpid(), unix.SIGUSR1)
+	p.assertCall(t, "next", "on next signal")
+	none.assertNoCall(t, "without any signals")
+}
+
+func TestSwitchFromFIFO(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fifo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pages")
+
+	p := make(fakePager, 10)
+	require.NoError(t, SwitchFromFIFO(p, path))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.ModeNamedPipe, fi.Mode()&os.ModeType, "creates fifo")
+
+	write := func(s string) {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		require.NoError(t, err)
+		f.WriteString(s)
+		f.Close()
+	}
+
+	write("next\n")
+	p.assertCall(t, "next", "on next command")
+	write("prev\nprevious\n")
+	p.assertCall(t, "previous", "on prev command")
+	p.assertCall(t, "previous", "on previous command")
+	write("\nfoo\n 2 \n")
+	p.assertCall(t, "show 2", "on page index")
+	p.assertNoCall(t, "on empty or unknown commands")
+
+	require.Error(t, SwitchFromFIFO(p, filepath.Join(dir, "missing", "pages")),
+		"on invalid path")
+}
@@ -51,14 +51,27 @@ type UpdateListener interface {
 
 // group is a general-purpose grouped module that can show
 // a subset of the wrapped modules, with buttons on either end.
+// Modules are paused while hidden, or while the group itself is paused,
+// which stops their schedulers (see core.Module.Pause).
 type group struct {
 	grouper   Grouper
 	moduleSet *core.ModuleSet
+
+	mu      sync.Mutex
+	paused  bool
+	visible []bool
+	running []bool
 }
 
 // New constructs a new group using the given Grouper and modules.
 func New(g Grouper, m ...bar.Module) bar.Module {
-	grp := &group{g, core.NewModuleSet(m)}
+	grp := &group{grouper: g, moduleSet: core.NewModuleSet(m)}
+	grp.visible = make([]bool, len(m))
+	grp.running = make([]bool, len(m))
+	for i := range grp.visible {
+		grp.visible[i] = true
+		grp.running[i] = true
+	}
 	l.Register(grp, "grouper", "moduleSet")
 	return grp
 }
@@ -99,7 +112,9 @@ func (g *group) output(moduleIdx int) (o bar.Output, changed bool) {
 	stBtn, eBtn := g.grouper.Buttons()
 	out.Append(stBtn)
 	for idx, o := range g.moduleSet.LastOutputs() {
-		if !g.grouper.Visible(idx) {
+		visible := g.grouper.Visible(idx)
+		g.setVisible(idx, visible)
+		if !visible {
 			continue
 		}
 		out.Append(o)
@@ -111,6 +126,48 @@ func (g *group) output(moduleIdx int) (o bar.Output, changed bool) {
 	return out, changed
 }
 
+// setVisible records the visibility of a module, and pauses or resumes it
+// as needed.
+func (g *group) setVisible(idx int, visible bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.visible[idx] = visible
+	g.updateRunningLocked(idx)
+}
+
+func (g *group) updateRunningLocked(idx int) {
+	running := g.visible[idx] && !g.paused
+	if running == g.running[idx] {
+		return
+	}
+	g.running[idx] = running
+	if running {
+		g.moduleSet.Resume(idx)
+	} else {
+		g.moduleSet.Pause(idx)
+	}
+}
+
+// Pause pauses all modules in the group, e.g. when the group itself is hidden
+// in another group.
+func (g *group) Pause() {
+	g.setPaused(true)
+}
+
+// Resume resumes all visible modules in the group.
+func (g *group) Resume() {
+	g.setPaused(false)
+}
+
+func (g *group) setPaused(paused bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = paused
+	for idx := range g.running {
+		g.updateRunningLocked(idx)
+	}
+}
+
 // nopGrouper implements a grouper that shows all modules.
 type nopGrouper bool
 
@@ -21,8 +21,10 @@ import (
 
 	"barista.run/bar"
 	"barista.run/outputs"
+	"barista.run/state"
 	testBar "barista.run/testing/bar"
 	testModule "barista.run/testing/module"
+	"barista.run/timing"
 
 	"github.com/stretchr/testify/require"
 )
@@ -340,3 +342,243 @@ func TestSimple(t *testing.T) {
 	out.At(1).Click(bar.Event{})
 	m2.AssertClicked("clicks pass through the group")
 }
+
+type pausableModule struct {
+	*testModule.TestModule
+	events chan string
+}
+
+func (p *pausableModule) Pause()  { p.events <- "pause" }
+func (p *pausableModule) Resume() { p.events <- "resume" }
+
+func (p *pausableModule) assertEvent(t *testing.T, expected string, msg string) {
+	select {
+	case e := <-p.events:
+		require.Equal(t, expected, e, msg)
+	case <-time.After(time.Second):
+		require.Fail(t, "Expected "+expected, msg)
+	}
+}
+
+func (p *pausableModule) assertNoEvent(t *testing.T, msg string) {
+	select {
+	case e := <-p.events:
+		require.Fail(t, "Unexpected "+e, msg)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestCollapsing(t *testing.T) {
+	testBar.New(t)
+
+	m0 := &pausableModule{testModule.New(t), make(chan string, 10)}
+	m1 := testModule.New(t)
+
+	testBar.Run(Collapsing("sys", m0, m1))
+	m0.AssertStarted("On group stream")
+	m1.AssertStarted()
+
+	out := testBar.NextOutput()
+	out.AssertText([]string{"▸ sys"}, "collapsed on start")
+	m0.assertEvent(t, "pause", "hidden module paused on start")
+
+	m0.OutputText("foo")
+	m1.OutputText("bar")
+	testBar.AssertNoOutput("while collapsed")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.AssertNoOutput("on non-left click")
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on expand")
+	out.AssertText([]string{"▾ sys", "foo", "bar"})
+	m0.assertEvent(t, "resume", "module resumed on expand")
+
+	out.At(1).LeftClick()
+	m0.AssertClicked("click on module segment")
+	testBar.AssertNoOutput("on module click")
+	m0.assertNoEvent(t, "on module click")
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on collapse").AssertText([]string{"▸ sys"})
+	m0.assertEvent(t, "pause", "module paused on collapse")
+}
+
+type pollingModule struct {
+	scheduler *timing.Scheduler
+	polls     int32
+}
+
+func (p *pollingModule) Stream(s bar.Sink) {
+	for {
+		s.Output(outputs.Textf("%d", atomic.AddInt32(&p.polls, 1)))
+		<-p.scheduler.C
+	}
+}
+
+func TestCollapsingPausesSchedulers(t *testing.T) {
+	testBar.New(t)
+	m := &pollingModule{scheduler: timing.NewScheduler().Every(time.Minute)}
+	m.scheduler.OwnedBy(m)
+	testBar.Run(Collapsing("poll", m))
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"▸ poll"})
+	testBar.Tick()
+	testBar.Tick()
+	testBar.AssertNoOutput("while collapsed")
+	require.Equal(t, int32(1), atomic.LoadInt32(&m.polls), "no polling while collapsed")
+
+	out.At(0).LeftClick()
+	testBar.Drain(50*time.Millisecond, "on expand").
+		AssertText([]string{"▾ poll", "2"}, "polls once on expand")
+
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"▾ poll", "3"})
+}
+
+func TestCycling(t *testing.T) {
+	testBar.New(t)
+
+	m0 := testModule.New(t)
+	m1 := &pausableModule{testModule.New(t), make(chan string, 10)}
+	grp, pager := Cycling(time.Minute, m0, m1)
+	testBar.Run(grp)
+	m0.AssertStarted()
+	m1.AssertStarted()
+	m0.OutputText("foo")
+	m1.OutputText("bar")
+
+	testBar.Drain(50*time.Millisecond, "on start").AssertText([]string{"foo"})
+	m1.assertEvent(t, "pause", "hidden module paused")
+	require.Equal(t, 2, pager.Count())
+
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"bar"})
+	m1.assertEvent(t, "resume", "shown module resumed")
+	require.Equal(t, 1, pager.Current())
+
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"foo"}, "wraps around")
+	m1.assertEvent(t, "pause", "hidden module paused")
+
+	pager.Previous()
+	testBar.NextOutput("on previous").AssertText([]string{"bar"})
+	m1.assertEvent(t, "resume", "shown module resumed")
+}
+
+func TestEmptyPagers(t *testing.T) {
+	testBar.New(t)
+	grp, pager := Cycling(time.Minute)
+	require.NotPanics(t, func() { pager.Next() }, "without modules")
+	testBar.Run(grp)
+	testBar.NextOutput("on start").AssertEmpty()
+	require.NotPanics(t, func() { testBar.Tick() }, "cycling without modules")
+	testBar.AssertNoOutput("on tick")
+	require.Equal(t, 0, pager.Current())
+
+	_, pager = Paged(func(page, count int) bar.Output { return nil })
+	require.NotPanics(t, func() {
+		pager.Previous()
+		pager.Show(3)
+	}, "without pages")
+}
+
+func TestCyclingStartsOnStream(t *testing.T) {
+	testBar.New(t)
+	m0, m1 := testModule.New(t), testModule.New(t)
+	grp, pager := Cycling(time.Minute, m0, m1)
+	timing.AdvanceBy(5 * time.Minute)
+	require.Equal(t, 0, pager.Current(), "does not cycle before streaming")
+
+	testBar.Run(grp)
+	m0.AssertStarted()
+	m1.AssertStarted()
+	m0.OutputText("a")
+	m1.OutputText("b")
+	testBar.Drain(50*time.Millisecond, "on start").AssertText([]string{"a"})
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"b"}, "cycles while streaming")
+}
+
+func TestPaged(t *testing.T) {
+	testBar.New(t)
+
+	m0 := testModule.New(t)
+	m1 := testModule.New(t)
+	m2 := &pausableModule{testModule.New(t), make(chan string, 10)}
+	grp, pager := Paged(func(page, count int) bar.Output {
+		return outputs.Textf("%d/%d", page+1, count)
+	}, Simple(m0, m1), Simple(m2))
+	testBar.Run(grp)
+	m0.AssertStarted()
+	m1.AssertStarted()
+	m2.AssertStarted()
+	m0.OutputText("a")
+	m1.OutputText("b")
+	m2.OutputText("c")
+
+	out := testBar.Drain(50*time.Millisecond, "on start")
+	out.AssertText([]string{"1/2", "a", "b"}, "first page shown")
+	m2.assertEvent(t, "pause", "modules on hidden pages paused")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll")
+	out.AssertText([]string{"2/2", "c"})
+	m2.assertEvent(t, "resume", "modules on shown page resumed")
+
+	out.At(1).LeftClick()
+	m2.AssertClicked("clicks on page modules")
+	testBar.AssertNoOutput("on page module click")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll")
+	out.AssertText([]string{"1/2", "a", "b"}, "wraps around")
+	m2.assertEvent(t, "pause", "modules on hidden pages paused")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.NextOutput("on scroll up").AssertText([]string{"2/2", "c"})
+	m2.assertEvent(t, "resume", "modules on shown page resumed")
+
+	pager.Show(2)
+	testBar.NextOutput("on show").AssertText([]string{"1/2", "a", "b"})
+	pager.Show(0)
+	testBar.AssertNoOutput("on show current page")
+}
+
+func TestRememberPage(t *testing.T) {
+	state.TestMode()
+	switcher := func(page, count int) bar.Output {
+		return outputs.Textf("%d/%d", page+1, count)
+	}
+
+	testBar.New(t)
+	m0, m1 := testModule.New(t), testModule.New(t)
+	grp, pager := Paged(switcher, m0, m1)
+	RememberPage(pager, "pages")
+	require.Equal(t, 0, pager.Current(), "without saved page")
+	testBar.Run(grp)
+	m0.AssertStarted()
+	m1.AssertStarted()
+	m0.OutputText("a")
+	m1.OutputText("b")
+	testBar.Drain(50*time.Millisecond, "on start").AssertText([]string{"1/2", "a"})
+	pager.Next()
+	testBar.NextOutput("on next").AssertText([]string{"2/2", "b"})
+
+	testBar.New(t)
+	m0, m1 = testModule.New(t), testModule.New(t)
+	grp, pager = Paged(switcher, m0, m1)
+	RememberPage(pager, "pages")
+	require.Equal(t, 1, pager.Current(), "restored saved page")
+	testBar.Run(grp)
+	m0.AssertStarted()
+	m1.AssertStarted()
+	m0.OutputText("a")
+	m1.OutputText("b")
+	testBar.Drain(50*time.Millisecond, "on restart").AssertText([]string{"2/2", "b"})
+
+	_, other := Paged(switcher, testModule.New(t))
+	RememberPage(other, "other")
+	require.Equal(t, 0, other.Current())
+}
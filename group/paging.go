@@ -0,0 +1,190 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package group
+
+import (
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/state"
+	"barista.run/timing"
+)
+
+// Pager controls the page shown by a cycling or paged group.
+type Pager interface {
+	// Current returns the index of the current page.
+	Current() int
+	// Count returns the number of pages.
+	Count() int
+	// Next switches to the next page, wrapping around at the end.
+	Next()
+	// Previous switches to the previous page, wrapping around at the start.
+	Previous()
+	// Show switches to a specific page.
+	Show(int)
+}
+
+// pager shows one module at a time, with an optional switcher button.
+type pager struct {
+	count    int
+	switcher func(page, count int) bar.Output
+
+	sync.Mutex
+	current  int
+	notifyFn func()
+	notifyCh <-chan struct{}
+	save     func(page int)
+}
+
+func newPager(count int) *pager {
+	p := &pager{count: count}
+	p.notifyFn, p.notifyCh = notifier.New()
+	return p
+}
+
+func (p *pager) Visible(idx int) bool { return p.current == idx }
+
+func (p *pager) Buttons() (start, end bar.Output) {
+	if p.switcher == nil {
+		return nil, nil
+	}
+	return outputs.Group(p.switcher(p.current, p.count)).OnClick(p.click), nil
+}
+
+func (p *pager) Signal() <-chan struct{} { return p.notifyCh }
+
+func (p *pager) Current() int {
+	p.Lock()
+	defer p.Unlock()
+	return p.current
+}
+
+func (p *pager) Count() int { return p.count }
+
+func (p *pager) Next() { p.move(1) }
+
+func (p *pager) Previous() { p.move(-1) }
+
+func (p *pager) Show(page int) {
+	p.Lock()
+	defer p.Unlock()
+	p.setLocked(page)
+}
+
+func (p *pager) move(delta int) {
+	p.Lock()
+	defer p.Unlock()
+	p.setLocked(p.current + delta)
+}
+
+func (p *pager) setLocked(page int) {
+	if p.count == 0 {
+		return
+	}
+	page = (page%p.count + p.count) % p.count
+	if page == p.current {
+		return
+	}
+	l.Fine("%s switched to page %d", l.ID(p), page)
+	p.current = page
+	p.notifyFn()
+	if p.save != nil {
+		p.save(page)
+	}
+}
+
+func (p *pager) click(e bar.Event) {
+	switch e.Button {
+	case bar.ButtonLeft, bar.ScrollDown, bar.ScrollRight:
+		p.Next()
+	case bar.ButtonRight, bar.ScrollUp, bar.ScrollLeft:
+		p.Previous()
+	}
+}
+
+// Cycling shows one module at a time, switching to the next module at the
+// given interval. To rotate between sets of modules, use Simple to combine
+// each set into a single module. Hidden modules are paused.
+func Cycling(interval time.Duration, m ...bar.Module) (bar.Module, Pager) {
+	p := newPager(len(m))
+	c := &cycling{
+		group:     New(p, m...).(*group),
+		pager:     p,
+		interval:  interval,
+		scheduler: timing.NewScheduler(),
+	}
+	l.Register(c, "group", "pager", "scheduler")
+	return c, p
+}
+
+// cycling is a group that switches pages at an interval while it is
+// streaming. The scheduler is stopped along with the group when the group is
+// paused, e.g. while hidden in another group.
+type cycling struct {
+	*group
+	pager     *pager
+	interval  time.Duration
+	scheduler *timing.Scheduler
+	start     sync.Once
+}
+
+// Stream starts switching pages, and streams the group.
+func (c *cycling) Stream(sink bar.Sink) {
+	c.start.Do(func() {
+		c.scheduler.Every(c.interval)
+		go func() {
+			for range c.scheduler.C {
+				c.pager.Next()
+			}
+		}()
+	})
+	c.group.Stream(sink)
+}
+
+// Paged shows one page at a time, with a switcher segment before it that
+// shows the next page on left click or scroll down, and the previous page on
+// right click or scroll up. The switcher output is computed from the current
+// page index and the number of pages. Hidden pages are paused.
+func Paged(switcher func(page, count int) bar.Output, pages ...bar.Module) (bar.Module, Pager) {
+	p := newPager(len(pages))
+	p.switcher = switcher
+	return New(p, pages...), p
+}
+
+// RememberPage restores the page of a pager returned by Cycling or Paged
+// from the state store, and saves the page whenever it changes, so that the
+// same page is shown after the bar restarts. The name should be unique to
+// each pager in the bar.
+func RememberPage(pg Pager, name string) {
+	p, ok := pg.(*pager)
+	if !ok {
+		l.Log("Cannot remember page of %s", l.ID(pg))
+		return
+	}
+	store := state.Open("group")
+	p.Lock()
+	defer p.Unlock()
+	var page int
+	if ok, err := store.Get(name, &page); err != nil {
+		l.Log("%s: failed to load page: %v", l.ID(p), err)
+	} else if ok {
+		p.setLocked(page)
+	}
+	p.save = func(page int) { store.SetLater(name, page) }
+}
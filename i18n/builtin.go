@@ -0,0 +1,72 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i18n
+
+func init() {
+	Register("de", map[string]string{
+		"Monday": "Montag", "Tuesday": "Dienstag", "Wednesday": "Mittwoch",
+		"Thursday": "Donnerstag", "Friday": "Freitag", "Saturday": "Samstag",
+		"Sunday": "Sonntag",
+
+		"Mon": "Mo", "Tue": "Di", "Wed": "Mi", "Thu": "Do", "Fri": "Fr",
+		"Sat": "Sa", "Sun": "So",
+		"January": "Januar", "February": "Februar", "March": "März",
+		"April": "April", "May": "Mai", "June": "Juni", "July": "Juli",
+		"August": "August", "September": "September", "October": "Oktober",
+		"November": "November", "December": "Dezember",
+		"Jan": "Jan", "Feb": "Feb", "Mar": "Mär", "Apr": "Apr", "Jun": "Jun",
+		"Jul": "Jul", "Aug": "Aug", "Sep": "Sep", "Oct": "Okt", "Nov": "Nov",
+		"Dec": "Dez",
+
+		"Charging": "Lädt", "Discharging": "Entlädt", "Full": "Voll",
+		"Not charging": "Lädt nicht", "Disconnected": "Getrennt",
+
+		"Thunderstorm": "Gewitter", "Drizzle": "Nieselregen", "Rain": "Regen",
+		"Snow": "Schnee", "Sleet": "Schneeregen", "Mist": "Dunst",
+		"Smoke": "Rauch", "Whirls": "Wirbel", "Haze": "Diesig", "Fog": "Nebel",
+		"Clear": "Klar", "Cloudy": "Bewölkt", "Partly cloudy": "Teilweise bewölkt",
+		"Overcast": "Bedeckt", "Tornado": "Tornado",
+		"Tropical storm": "Tropensturm", "Hurricane": "Hurrikan",
+		"Cold": "Kalt", "Hot": "Heiß", "Windy": "Windig", "Hail": "Hagel",
+	})
+	Register("fr", map[string]string{
+		"Monday": "lundi", "Tuesday": "mardi", "Wednesday": "mercredi",
+		"Thursday": "jeudi", "Friday": "vendredi", "Saturday": "samedi",
+		"Sunday": "dimanche",
+
+		"Mon": "lun.", "Tue": "mar.", "Wed": "mer.", "Thu": "jeu.",
+		"Fri": "ven.", "Sat": "sam.", "Sun": "dim.",
+		"January": "janvier", "February": "février", "March": "mars",
+		"April": "avril", "May": "mai", "June": "juin", "July": "juillet",
+		"August": "août", "September": "septembre", "October": "octobre",
+		"November": "novembre", "December": "décembre",
+		"Jan": "janv.", "Feb": "févr.", "Mar": "mars", "Apr": "avr.",
+		"Jun": "juin", "Jul": "juil.", "Aug": "août", "Sep": "sept.",
+		"Oct": "oct.", "Nov": "nov.", "Dec": "déc.",
+
+		"Charging": "En charge", "Discharging": "Sur batterie",
+		"Full": "Chargée", "Not charging": "Pas en charge",
+		"Disconnected": "Déconnectée",
+
+		"Thunderstorm": "Orage", "Drizzle": "Bruine", "Rain": "Pluie",
+		"Snow": "Neige", "Sleet": "Neige fondue", "Mist": "Brume",
+		"Smoke": "Fumée", "Whirls": "Tourbillons", "Haze": "Brume sèche",
+		"Fog": "Brouillard", "Clear": "Dégagé", "Cloudy": "Nuageux",
+		"Partly cloudy": "Partiellement nuageux", "Overcast": "Couvert",
+		"Tornado": "Tornade", "Tropical storm": "Tempête tropicale",
+		"Hurricane": "Ouragan", "Cold": "Froid", "Hot": "Chaud",
+		"Windy": "Venteux", "Hail": "Grêle",
+	})
+}
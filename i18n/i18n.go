@@ -0,0 +1,146 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package i18n provides translations for the text in default module outputs,
+// such as weekday and month names, weather conditions, and battery states.
+//
+// The language is read from the LC_ALL, LC_MESSAGES, or LANG environment
+// variables (in that order), and can be overridden using SetLanguage.
+// Translations for additional languages, or to replace the built-in ones,
+// can be added using Register.
+package i18n // import "barista.run/i18n"
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	mu       sync.RWMutex
+	language *string
+	catalogs = map[string]map[string]string{}
+)
+
+// Overridden in tests.
+var getenv = os.Getenv
+
+// Register adds translations for the given language, replacing any existing
+// translations of the same messages. The language is either a language code
+// (e.g. "de"), or a language and territory (e.g. "de_CH"), in which case it is
+// preferred over the language alone. Messages are keyed by their English text.
+func Register(lang string, translations map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	c, ok := catalogs[lang]
+	if !ok {
+		c = map[string]string{}
+		catalogs[lang] = c
+	}
+	for k, v := range translations {
+		c[k] = v
+	}
+}
+
+// SetLanguage sets the language used for translations, overriding the
+// environment. An empty string disables translations.
+func SetLanguage(lang string) {
+	mu.Lock()
+	defer mu.Unlock()
+	language = &lang
+}
+
+// Language returns the language used for translations, e.g. "de_DE".
+func Language() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if language != nil {
+		return *language
+	}
+	return fromEnv()
+}
+
+// fromEnv returns the language from the locale environment variables,
+// without the encoding or modifier, e.g. "de_DE.UTF-8@euro" becomes "de_DE".
+func fromEnv() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := getenv(env)
+		if locale == "" {
+			continue
+		}
+		if idx := strings.IndexAny(locale, ".@"); idx >= 0 {
+			locale = locale[:idx]
+		}
+		if locale == "C" || locale == "POSIX" {
+			return ""
+		}
+		return locale
+	}
+	return ""
+}
+
+// T returns the translation of the given message in the current language,
+// or the message itself if it is not translated.
+func T(msg string) string {
+	lang := Language()
+	if lang == "" || msg == "" {
+		return msg
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if t, ok := catalogs[lang][msg]; ok {
+		return t
+	}
+	if idx := strings.IndexRune(lang, '_'); idx >= 0 {
+		if t, ok := catalogs[lang[:idx]][msg]; ok {
+			return t
+		}
+	}
+	return msg
+}
+
+// Weekday returns the translated name of the given day of the week.
+func Weekday(d time.Weekday) string {
+	return T(d.String())
+}
+
+// Month returns the translated name of the given month.
+func Month(m time.Month) string {
+	return T(m.String())
+}
+
+// The name components of time layouts, longest first so that e.g. "Monday"
+// is not matched as "Mon".
+var layoutNames = []string{"Monday", "Mon", "January", "Jan"}
+
+// FormatTime is equivalent to t.Format(layout), but uses translated weekday
+// and month names.
+func FormatTime(t time.Time, layout string) string {
+	var out strings.Builder
+	for layout != "" {
+		idx, name := len(layout), ""
+		for _, n := range layoutNames {
+			if i := strings.Index(layout, n); i >= 0 && (i < idx || i == idx && len(n) > len(name)) {
+				idx, name = i, n
+			}
+		}
+		out.WriteString(t.Format(layout[:idx]))
+		if name != "" {
+			out.WriteString(T(t.Format(name)))
+		}
+		layout = layout[idx+len(name):]
+	}
+	return out.String()
+}
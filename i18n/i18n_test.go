@@ -0,0 +1,82 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i18n
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withEnv(env map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	language = nil
+	getenv = func(key string) string { return env[key] }
+}
+
+func TestLanguageFromEnv(t *testing.T) {
+	for _, tc := range []struct {
+		env      map[string]string
+		expected string
+	}{
+		{map[string]string{}, ""},
+		{map[string]string{"LANG": "de_DE.UTF-8"}, "de_DE"},
+		{map[string]string{"LANG": "de_DE.UTF-8", "LC_MESSAGES": "fr_FR"}, "fr_FR"},
+		{map[string]string{"LANG": "de_DE", "LC_MESSAGES": "fr_FR", "LC_ALL": "C"}, ""},
+		{map[string]string{"LC_ALL": "fr_BE@euro"}, "fr_BE"},
+	} {
+		withEnv(tc.env)
+		require.Equal(t, tc.expected, Language(), "%v", tc.env)
+	}
+	SetLanguage("de")
+	require.Equal(t, "de", Language(), "overrides environment")
+}
+
+func TestTranslate(t *testing.T) {
+	withEnv(map[string]string{"LANG": "de_CH.UTF-8"})
+	require.Equal(t, "Montag", T("Monday"), "falls back to language")
+	require.Equal(t, "unknown", T("unknown"), "untranslated message")
+
+	Register("de_CH", map[string]string{"Saturday": "Samschtig"})
+	require.Equal(t, "Samschtig", T("Saturday"), "prefers territory")
+	require.Equal(t, "Samschtig", Weekday(time.Saturday))
+
+	SetLanguage("de")
+	require.Equal(t, "Samstag", Weekday(time.Saturday))
+	require.Equal(t, "März", Month(time.March))
+
+	Register("xx", map[string]string{"Monday": "Lun"})
+	SetLanguage("xx")
+	require.Equal(t, "Lun", T("Monday"))
+	require.Equal(t, "Tuesday", T("Tuesday"))
+
+	SetLanguage("")
+	require.Equal(t, "Monday", T("Monday"), "translations disabled")
+}
+
+func TestFormatTime(t *testing.T) {
+	ts := time.Date(2017, time.March, 6, 15, 4, 5, 0, time.UTC)
+	SetLanguage("")
+	require.Equal(t, "Monday, 06 March 2017 (Mon Mar) 15:04",
+		FormatTime(ts, "Monday, 02 January 2006 (Mon Jan) 15:04"))
+
+	SetLanguage("fr_FR")
+	require.Equal(t, "lundi, 06 mars 2017 (lun. mars) 15:04",
+		FormatTime(ts, "Monday, 02 January 2006 (Mon Jan) 15:04"))
+	require.Equal(t, "15:04:05", FormatTime(ts, "15:04:05"))
+	require.Equal(t, "", FormatTime(ts, ""))
+}
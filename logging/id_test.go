@@ -201,7 +201,7 @@ func TestID(t *testing.T) {
 
 func TestLabel(t *testing.T) {
 	resetLoggingState()
-	fineLogModules = append(fineLogModules, "bar:logging")
+	SetLevel("bar:logging", LevelFine)
 	testingT = t
 
 	Label(fooer27, "27")
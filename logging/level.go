@@ -0,0 +1,79 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"fmt"
+	"time"
+)
+
+// Level controls which statements are logged for a module.
+type Level int
+
+const (
+	// LevelOff suppresses all statements.
+	LevelOff Level = iota
+	// LevelLog logs statements from Log. This is the default level.
+	LevelLog
+	// LevelFine logs statements from both Log and Fine.
+	LevelFine
+)
+
+var levelNames = map[Level]string{
+	LevelOff:  "off",
+	LevelLog:  "log",
+	LevelFine: "fine",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// MarshalText implements encoding.TextMarshaler, for JSON output.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (l *Level) UnmarshalText(text []byte) error {
+	var err error
+	*l, err = ParseLevel(string(text))
+	return err
+}
+
+// ParseLevel returns the level with the given name, one of "off", "log",
+// or "fine".
+func ParseLevel(name string) (Level, error) {
+	for l, n := range levelNames {
+		if n == name {
+			return l, nil
+		}
+	}
+	return LevelOff, fmt.Errorf("unknown log level %q", name)
+}
+
+// Entry is a single logged statement.
+type Entry struct {
+	Time  time.Time `json:"time"`
+	Level Level     `json:"level"`
+	// Module is the calling module, e.g. mod:clock or bar:core.
+	Module string `json:"module"`
+	// Location is the source location, if enabled using SetFlags.
+	Location string `json:"location,omitempty"`
+	Message  string `json:"message"`
+}
@@ -17,15 +17,18 @@
 package logging // import "barista.run/logging"
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 func trimSuffix(s, suffix string) (result string, trimmed bool) {
@@ -48,12 +51,16 @@ func construct() {
 	}
 	logger = log.New(os.Stderr, "", 0)
 	SetFlags(log.LstdFlags | log.Lshortfile)
+	SetBufferSize(defaultBufferSize)
 	for _, arg := range os.Args {
-		if mods, ok := trimPrefix(arg, "--finelog="); ok {
-			fineLogModules = append(fineLogModules, strings.Split(mods, ",")...)
+		mods, ok := trimPrefix(arg, "--finelog=")
+		if !ok {
+			mods, ok = trimPrefix(arg, "-finelog=")
 		}
-		if mods, ok := trimPrefix(arg, "-finelog="); ok {
-			fineLogModules = append(fineLogModules, strings.Split(mods, ",")...)
+		if ok {
+			for _, mod := range strings.Split(mods, ",") {
+				SetLevel(mod, LevelFine)
+			}
 		}
 	}
 }
@@ -91,24 +98,39 @@ func shorten(path string) string {
 	return path
 }
 
-var fineLogModules = []string{}
-var fineLogModulesCache sync.Map
+var moduleLevels = map[string]Level{}
+var moduleLevelsCache sync.Map
+var levelMu sync.RWMutex
 
-// fineLogEnabled returns true if finelog is enabled for the module.
-// It caches results in a sync.Map so subsequent lookups can be faster.
-func fineLogEnabled(mod string) bool {
-	cache, ok := fineLogModulesCache.Load(mod)
+// levelFor returns the level for the module, using the longest matching
+// prefix. It caches results in a sync.Map so subsequent lookups can be faster.
+func levelFor(mod string) Level {
+	levelMu.RLock()
+	defer levelMu.RUnlock()
+	cache, ok := moduleLevelsCache.Load(mod)
 	if ok {
-		return cache.(bool)
+		return cache.(Level)
 	}
-	for _, fineMod := range fineLogModules {
-		if strings.HasPrefix(mod, fineMod) {
-			fineLogModulesCache.Store(mod, true)
-			return true
+	level, matched := LevelLog, -1
+	for prefix, l := range moduleLevels {
+		if strings.HasPrefix(mod, prefix) && len(prefix) > matched {
+			level, matched = l, len(prefix)
 		}
 	}
-	fineLogModulesCache.Store(mod, false)
-	return false
+	moduleLevelsCache.Store(mod, level)
+	return level
+}
+
+// updateLevels updates module levels using the given function, and clears
+// the cache of computed levels.
+func updateLevels(fn func(map[string]Level)) {
+	levelMu.Lock()
+	defer levelMu.Unlock()
+	fn(moduleLevels)
+	moduleLevelsCache.Range(func(k, v interface{}) bool {
+		moduleLevelsCache.Delete(k)
+		return true
+	})
 }
 
 // callingModule returns the calling module's name and source location.
@@ -137,11 +159,37 @@ func callingModule() (mod string, loc string) {
 
 var fileFlags int64
 var logger *log.Logger
+var jsonOutput int32 // bool, but atomic.
+
+// The number of recent statements kept in memory by default.
+const defaultBufferSize = 500
+
+// buffer is a ring buffer of recently logged statements.
+var buffer struct {
+	sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
 
 // doLog actually logs the given statement, with appropriate file information
 // depending on the currently set flags.
-func doLog(mod, loc string, format string, args ...interface{}) {
-	out := fmt.Sprintf(format, args...)
+func doLog(mod, loc string, level Level, format string, args ...interface{}) {
+	e := Entry{
+		Time:     time.Now(),
+		Level:    level,
+		Module:   mod,
+		Location: loc,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	addToBuffer(e)
+	if atomic.LoadInt32(&jsonOutput) == 1 {
+		// Encode to a buffer first so that each entry is written at once.
+		out, _ := json.Marshal(e)
+		logger.Writer().Write(append(out, '\n'))
+		return
+	}
+	out := e.Message
 	fFlags := int(atomic.LoadInt64(&fileFlags))
 	if fFlags != 0 {
 		out = fmt.Sprintf("%s (%s) %s", loc, mod, out)
@@ -149,6 +197,19 @@ func doLog(mod, loc string, format string, args ...interface{}) {
 	logger.Output(3, out)
 }
 
+func addToBuffer(e Entry) {
+	buffer.Lock()
+	defer buffer.Unlock()
+	if len(buffer.entries) == 0 {
+		return
+	}
+	buffer.entries[buffer.next] = e
+	buffer.next = (buffer.next + 1) % len(buffer.entries)
+	if buffer.next == 0 {
+		buffer.full = true
+	}
+}
+
 // SetOutput sets the output stream for logging.
 func SetOutput(output io.Writer) {
 	logger.SetOutput(output)
@@ -161,10 +222,87 @@ func SetFlags(flags int) {
 	logger.SetFlags(flags &^ fFlags)
 }
 
+// SetFineLog enables fine logging for the given modules, replacing any
+// modules from the commandline flag. [Requires debug logging].
+func SetFineLog(modules ...string) {
+	updateLevels(func(levels map[string]Level) {
+		for prefix, level := range levels {
+			if level == LevelFine {
+				delete(levels, prefix)
+			}
+		}
+		for _, mod := range modules {
+			levels[mod] = LevelFine
+		}
+	})
+}
+
+// SetLevel sets the level for modules with the given prefix, e.g. "mod:"
+// for all modules included with barista. The longest matching prefix is
+// used for each module. [Requires debug logging].
+func SetLevel(prefix string, level Level) {
+	updateLevels(func(levels map[string]Level) {
+		levels[prefix] = level
+	})
+}
+
+// ToggleFineLogOnSignal toggles fine logging for all modules each time the
+// process receives the given signal. [Requires debug logging].
+func ToggleFineLogOnSignal(sig os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig)
+	go func() {
+		for range ch {
+			updateLevels(func(levels map[string]Level) {
+				if levels[""] == LevelFine {
+					delete(levels, "")
+				} else {
+					levels[""] = LevelFine
+				}
+			})
+		}
+	}()
+}
+
+// SetJSON controls whether statements are written as JSON objects, one per
+// line, instead of plain text. [Requires debug logging].
+func SetJSON(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&jsonOutput, v)
+}
+
+// SetBufferSize sets the number of recent statements kept in memory, which
+// can be retrieved using Recent, e.g. for bug reports. [Requires debug
+// logging].
+func SetBufferSize(size int) {
+	buffer.Lock()
+	defer buffer.Unlock()
+	buffer.entries = make([]Entry, size)
+	buffer.next = 0
+	buffer.full = false
+}
+
+// Recent returns the most recently logged statements, oldest first.
+// [Requires debug logging].
+func Recent() []Entry {
+	buffer.Lock()
+	defer buffer.Unlock()
+	var out []Entry
+	if buffer.full {
+		out = append(out, buffer.entries[buffer.next:]...)
+	}
+	return append(out, buffer.entries[:buffer.next]...)
+}
+
 // Log logs a formatted message.
 func Log(format string, args ...interface{}) {
 	mod, loc := callingModule()
-	doLog(mod, loc, format, args...)
+	if levelFor(mod) >= LevelLog {
+		doLog(mod, loc, LevelLog, format, args...)
+	}
 }
 
 // Fine logs a formatted message if fine logging is enabled for the
@@ -172,7 +310,7 @@ func Log(format string, args ...interface{}) {
 // `--finelog=$module1,$module2`. [Requires debug logging].
 func Fine(format string, args ...interface{}) {
 	mod, loc := callingModule()
-	if fineLogEnabled(mod) {
-		doLog(mod, loc, format, args...)
+	if levelFor(mod) >= LevelFine {
+		doLog(mod, loc, LevelFine, format, args...)
 	}
 }
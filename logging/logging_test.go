@@ -17,11 +17,14 @@
 package logging
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"runtime"
+	"syscall"
 	"testing"
+	"time"
 
 	"barista.run/testing/mockio"
 
@@ -36,14 +39,15 @@ func resetLoggingState() {
 
 	nodes = map[ident]node{}
 	instances = map[string]int{}
-	fineLogModules = []string{}
 	objectIDs = map[ident]string{}
 	labels = map[ident]string{}
 
-	fineLogModulesCache.Range(func(k, v interface{}) bool {
-		fineLogModulesCache.Delete(k)
-		return true
+	updateLevels(func(levels map[string]Level) {
+		for k := range levels {
+			delete(levels, k)
+		}
 	})
+	SetJSON(false)
 
 	construct()
 	mockStderr = mockio.Stdout()
@@ -124,6 +128,14 @@ func TestFine(t *testing.T) {
 	resetLoggingState()
 	Fine("foo")
 	require.Empty(t, mockStderr.ReadNow())
+
+	SetFineLog("bar:logging")
+	Fine("foo")
+	assertLogged(t, "foo")
+
+	SetFineLog()
+	Fine("foo")
+	require.Empty(t, mockStderr.ReadNow())
 }
 
 func TestFileLocations(t *testing.T) {
@@ -133,3 +145,92 @@ func TestFileLocations(t *testing.T) {
 	_, _, line, _ := runtime.Caller(0)
 	assertLogged(t, fmt.Sprintf("logging_test.go:%d (bar:logging.TestFileLocations) foo", line-1))
 }
+
+func TestLevels(t *testing.T) {
+	resetLoggingState()
+	SetLevel("bar:", LevelOff)
+	Log("foo")
+	require.Empty(t, mockStderr.ReadNow(), "off")
+
+	SetLevel("bar:logging", LevelFine)
+	Fine("foo")
+	assertLogged(t, "foo")
+
+	SetLevel("bar:logging.TestLevels", LevelLog)
+	Fine("foo")
+	require.Empty(t, mockStderr.ReadNow(), "longest prefix wins")
+	Log("bar")
+	assertLogged(t, "bar")
+
+	SetFineLog("bar:logging.TestLevels")
+	Fine("baz")
+	assertLogged(t, "baz")
+	SetFineLog()
+	Log("foo")
+	require.Empty(t, mockStderr.ReadNow(), "SetFineLog keeps other levels")
+
+	for _, name := range []string{"off", "log", "fine"} {
+		l, err := ParseLevel(name)
+		require.NoError(t, err)
+		require.Equal(t, name, l.String())
+	}
+	_, err := ParseLevel("verbose")
+	require.Error(t, err)
+	require.Equal(t, "Level(9)", Level(9).String())
+}
+
+func TestToggleFineLogOnSignal(t *testing.T) {
+	resetLoggingState()
+	ToggleFineLogOnSignal(syscall.SIGUSR1)
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	require.Eventually(t, func() bool {
+		Fine("foo")
+		return mockStderr.ReadNow() == "foo\n"
+	}, time.Second, time.Millisecond, "fine logging enabled on signal")
+
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	require.Eventually(t, func() bool {
+		Fine("foo")
+		return mockStderr.ReadNow() == ""
+	}, time.Second, time.Millisecond, "fine logging disabled on signal")
+}
+
+func TestJSON(t *testing.T) {
+	resetLoggingState()
+	SetJSON(true)
+	Log("foo: %d", 42)
+	var e Entry
+	require.NoError(t, json.Unmarshal([]byte(mockStderr.ReadNow()), &e))
+	require.Equal(t, "bar:logging.TestJSON", e.Module)
+	require.Equal(t, "foo: 42", e.Message)
+	require.False(t, e.Time.IsZero())
+
+	var fields map[string]interface{}
+	SetFineLog("bar:logging")
+	Fine("bar")
+	require.NoError(t, json.Unmarshal([]byte(mockStderr.ReadNow()), &fields))
+	require.Equal(t, "fine", fields["level"])
+}
+
+func TestRecent(t *testing.T) {
+	resetLoggingState()
+	SetBufferSize(3)
+	require.Empty(t, Recent())
+
+	Log("a")
+	Log("b")
+	var messages []string
+	for _, e := range Recent() {
+		messages = append(messages, e.Message)
+	}
+	require.Equal(t, []string{"a", "b"}, messages)
+
+	Fine("not logged")
+	Log("c")
+	Log("d")
+	messages = nil
+	for _, e := range Recent() {
+		messages = append(messages, e.Message)
+	}
+	require.Equal(t, []string{"b", "c", "d"}, messages, "oldest dropped")
+}
@@ -19,7 +19,10 @@
 // actual logging functions when built with `-tags debuglog`.
 package logging
 
-import "io"
+import (
+	"io"
+	"os"
+)
 
 // SetOutput sets the output stream for logging.
 func SetOutput(output io.Writer) {}
@@ -27,6 +30,32 @@ func SetOutput(output io.Writer) {}
 // SetFlags sets flags to control logging output.
 func SetFlags(flags int) {}
 
+// SetFineLog enables fine logging for the given modules, replacing any
+// modules from the commandline flag. [Requires debug logging].
+func SetFineLog(modules ...string) {}
+
+// SetLevel sets the level for modules with the given prefix, e.g. "mod:"
+// for all modules included with barista. The longest matching prefix is
+// used for each module. [Requires debug logging].
+func SetLevel(prefix string, level Level) {}
+
+// ToggleFineLogOnSignal toggles fine logging for all modules each time the
+// process receives the given signal. [Requires debug logging].
+func ToggleFineLogOnSignal(sig os.Signal) {}
+
+// SetJSON controls whether statements are written as JSON objects, one per
+// line, instead of plain text. [Requires debug logging].
+func SetJSON(enabled bool) {}
+
+// SetBufferSize sets the number of recent statements kept in memory, which
+// can be retrieved using Recent, e.g. for bug reports. [Requires debug
+// logging].
+func SetBufferSize(size int) {}
+
+// Recent returns the most recently logged statements, oldest first.
+// [Requires debug logging].
+func Recent() []Entry { return nil }
+
 // Log logs a formatted message.
 func Log(format string, args ...interface{}) {}
 
@@ -29,6 +29,7 @@ func TestNopMethods(t *testing.T) {
 	SetOutput(mockio.Stdout())
 	SetFlags(log.Lshortfile)
 	Log("foo: %d", 42)
+	SetFineLog("bar:logging")
 	Fine("bar: %g", 3.14159)
 	require.Equal(t, "", ID(4))
 	Label(&struct{}{}, "empty")
@@ -36,4 +37,9 @@ func TestNopMethods(t *testing.T) {
 	Attach(t, 4, "->int")
 	Attachf(t, 1.0, "->float:%g", 1.0)
 	Register(t, "Fail", "FailNow")
+	SetLevel("bar:", LevelFine)
+	SetJSON(true)
+	SetBufferSize(10)
+	Log("foo")
+	require.Empty(t, Recent())
 }
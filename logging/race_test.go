@@ -39,7 +39,7 @@ func invoke(method interface{}, args args) {
 
 func TestRace(t *testing.T) {
 	resetLoggingState()
-	fineLogModules = []string{"bar:logging"}
+	SetFineLog("bar:logging")
 
 	methods := []struct {
 		method interface{}
@@ -0,0 +1,117 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics exports statistics about the bar in the Prometheus text
+// format, so that the health of the bar can be monitored over time.
+package metrics // import "barista.run/metrics"
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"barista.run"
+	l "barista.run/logging"
+	"barista.run/timing"
+)
+
+// For tests.
+var getStats = barista.GetStats
+var getTickStats = timing.GetTickStats
+
+// Handler returns an http.Handler that serves the bar's metrics.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		write(w, getStats(), getTickStats())
+	})
+}
+
+// Serve serves the bar's metrics at /metrics on the given port on localhost.
+// It returns once the port is listening, and serves requests in the
+// background.
+func Serve(port int) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	go func() {
+		l.Log("metrics server stopped: %v", http.Serve(lis, mux))
+	}()
+	return nil
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metric writes the header for a metric, and returns a function that writes
+// its samples.
+func metric(w io.Writer, name, typ, help string) func(suffix, labels string, value interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	return func(suffix, labels string, value interface{}) {
+		if labels != "" {
+			labels = "{" + labels + "}"
+		}
+		fmt.Fprintf(w, "%s%s%s %v\n", name, suffix, labels, value)
+	}
+}
+
+func write(w io.Writer, s barista.Stats, t timing.TickStats) {
+	labels := make([]string, len(s.Modules))
+	for i, m := range s.Modules {
+		labels[i] = fmt.Sprintf(`module="%s",index="%d"`,
+			labelEscaper.Replace(m.Name), i)
+	}
+
+	sample := metric(w, "barista_module_updates_total", "counter",
+		"Number of output updates from each module.")
+	for i, m := range s.Modules {
+		sample("", labels[i], m.Updates)
+	}
+	sample = metric(w, "barista_module_errors_total", "counter",
+		"Number of output updates with an error from each module.")
+	for i, m := range s.Modules {
+		sample("", labels[i], m.Errors)
+	}
+	sample = metric(w, "barista_module_last_update_timestamp_seconds", "gauge",
+		"Time of the most recent output update from each module.")
+	for i, m := range s.Modules {
+		if !m.LastUpdate.IsZero() {
+			sample("", labels[i], float64(m.LastUpdate.UnixNano())/1e9)
+		}
+	}
+
+	sample = metric(w, "barista_output_write_duration_seconds", "summary",
+		"Time taken to write the bar output.")
+	sample("_sum", "", s.RenderTime.Seconds())
+	sample("_count", "", s.Renders)
+
+	sample = metric(w, "barista_scheduler_tick_latency_seconds", "summary",
+		"Delay between the scheduled and actual time of scheduler triggers.")
+	sample("_sum", "", t.TotalLatency.Seconds())
+	sample("_count", "", t.Ticks)
+	sample = metric(w, "barista_scheduler_tick_latency_max_seconds", "gauge",
+		"Highest delay of any scheduler trigger.")
+	sample("", "", t.MaxLatency.Seconds())
+
+	sample = metric(w, "barista_goroutines", "gauge",
+		"Number of goroutines that currently exist.")
+	sample("", "", s.Goroutines)
+	sample = metric(w, "barista_heap_bytes", "gauge",
+		"Number of bytes of allocated heap objects.")
+	sample("", "", s.HeapAlloc)
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"barista.run"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	getStats = func() barista.Stats {
+		return barista.Stats{
+			Goroutines: 42,
+			HeapAlloc:  1024,
+			Renders:    4,
+			RenderTime: 10 * time.Millisecond,
+			Modules: []barista.ModuleStats{
+				{Name: "clock", Updates: 10, LastUpdate: time.Unix(1500000000, 0)},
+				{Name: `say "hi"`, Updates: 3, Errors: 1},
+			},
+		}
+	}
+	getTickStats = func() timing.TickStats {
+		return timing.TickStats{
+			Ticks:        20,
+			TotalLatency: 40 * time.Millisecond,
+			MaxLatency:   5 * time.Millisecond,
+		}
+	}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+	require.NoError(t, Serve(port))
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/metrics", port))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, `# HELP barista_module_updates_total Number of output updates from each module.
+# TYPE barista_module_updates_total counter
+barista_module_updates_total{module="clock",index="0"} 10
+barista_module_updates_total{module="say \"hi\"",index="1"} 3
+# HELP barista_module_errors_total Number of output updates with an error from each module.
+# TYPE barista_module_errors_total counter
+barista_module_errors_total{module="clock",index="0"} 0
+barista_module_errors_total{module="say \"hi\"",index="1"} 1
+# HELP barista_module_last_update_timestamp_seconds Time of the most recent output update from each module.
+# TYPE barista_module_last_update_timestamp_seconds gauge
+barista_module_last_update_timestamp_seconds{module="clock",index="0"} 1.5e+09
+# HELP barista_output_write_duration_seconds Time taken to write the bar output.
+# TYPE barista_output_write_duration_seconds summary
+barista_output_write_duration_seconds_sum 0.01
+barista_output_write_duration_seconds_count 4
+# HELP barista_scheduler_tick_latency_seconds Delay between the scheduled and actual time of scheduler triggers.
+# TYPE barista_scheduler_tick_latency_seconds summary
+barista_scheduler_tick_latency_seconds_sum 0.04
+barista_scheduler_tick_latency_seconds_count 20
+# HELP barista_scheduler_tick_latency_max_seconds Highest delay of any scheduler trigger.
+# TYPE barista_scheduler_tick_latency_max_seconds gauge
+barista_scheduler_tick_latency_max_seconds 0.005
+# HELP barista_goroutines Number of goroutines that currently exist.
+# TYPE barista_goroutines gauge
+barista_goroutines 42
+# HELP barista_heap_bytes Number of bytes of allocated heap objects.
+# TYPE barista_heap_bytes gauge
+barista_heap_bytes 1024
+`, string(body))
+
+	require.Error(t, Serve(port), "port already in use")
+}
@@ -12,7 +12,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package battery provides a battery status i3bar module.
+// Package battery provides a battery status i3bar module. Batteries are read
+// from sysfs, or from UPower, which also provides the batteries of wireless
+// peripherals such as mice, keyboards, and headsets.
 package battery // import "barista.run/modules/battery"
 
 import (
@@ -25,6 +27,7 @@ import (
 
 	"barista.run/bar"
 	"barista.run/base/value"
+	"barista.run/i18n"
 	l "barista.run/logging"
 	"barista.run/outputs"
 	"barista.run/timing"
@@ -51,8 +54,19 @@ const (
 	Unknown Status = ""
 )
 
+// String returns the status translated to the current language.
+func (s Status) String() string {
+	return i18n.T(string(s))
+}
+
 // Info represents the current battery information.
 type Info struct {
+	// Name of the battery, e.g. "BAT0", or the model of a peripheral device,
+	// e.g. "MX Master 3". Empty for aggregated batteries.
+	Name string
+	// Kind of device powered by the battery, e.g. "battery", "mouse",
+	// "keyboard", or "headset". Only set by the UPower backend.
+	Kind string
 	// Capacity in *percents*, from 0 to 100.
 	Capacity int
 	// Energy when the battery is full, in Wh.
@@ -69,12 +83,15 @@ type Info struct {
 	Status Status
 	// Technology of the battery, e.g. "Li-Ion", "Li-Poly", "Ni-MH".
 	Technology string
+	// powerSupply is true for UPower batteries that power the system.
+	powerSupply bool
 }
 
 // Remaining returns the fraction of battery capacity remaining.
 func (i Info) Remaining() float64 {
 	if math.Nextafter(i.EnergyFull, 0) == 0 {
-		return 0
+		// Some batteries, e.g. in peripheral devices, only report capacity.
+		return float64(i.Capacity) / 100
 	}
 	return i.EnergyNow / i.EnergyFull
 }
@@ -126,6 +143,9 @@ func (i Info) SignedPower() float64 {
 // format, click handler, update frequency, and urgency/colour functions.
 type Module struct {
 	updateFunc func() Info
+	// watchFunc, if set, replaces polling with a channel that receives the
+	// battery info on each change, and a function to stop watching.
+	watchFunc  func() (<-chan Info, func())
 	scheduler  *timing.Scheduler
 	outputFunc value.Value // of func(Info) bar.Output
 }
@@ -156,13 +176,31 @@ func All() *Module {
 	return newModule(allBatteriesInfo)
 }
 
+// Combined constructs a battery module that aggregates the named batteries,
+// e.g. the internal and removable batteries of a laptop. The energy and power
+// of all batteries are summed, so the remaining time is an estimate for all
+// batteries together, even if they are discharged one after the other.
+// Batteries that are not present are ignored.
+func Combined(names ...string) *Module {
+	m := newModule(func() Info {
+		var infos []Info
+		for _, name := range names {
+			infos = append(infos, batteryInfo(name))
+		}
+		return aggregate(infos)
+	})
+	l.Label(m, strings.Join(names, "+"))
+	return m
+}
+
 // Output configures a module to display the output of a user-defined function.
 func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 	m.outputFunc.Set(outputFunc)
 	return m
 }
 
-// RefreshInterval configures the polling frequency for battery info.
+// RefreshInterval configures the polling frequency for battery info. It has no
+// effect for UPower batteries, which are updated whenever they change.
 func (m *Module) RefreshInterval(interval time.Duration) *Module {
 	m.scheduler.Every(interval)
 	return m
@@ -170,7 +208,16 @@ func (m *Module) RefreshInterval(interval time.Duration) *Module {
 
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
-	info := m.updateFunc()
+	var info Info
+	var updates <-chan Info
+	if m.watchFunc != nil {
+		var stop func()
+		updates, stop = m.watchFunc()
+		defer stop()
+		info = <-updates
+	} else {
+		info = m.updateFunc()
+	}
 	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
@@ -178,7 +225,10 @@ func (m *Module) Stream(s bar.Sink) {
 		s.Output(outputFunc(info))
 		select {
 		case <-m.scheduler.C:
-			info = m.updateFunc()
+			if m.updateFunc != nil {
+				info = m.updateFunc()
+			}
+		case info = <-updates:
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
 		}
@@ -241,13 +291,13 @@ func batteryInfo(name string) Info {
 	f, err := fs.Open(batteryPath)
 	if err != nil {
 		l.Log("Failed to read stats for %s: %s", name, err)
-		return Info{Status: Disconnected}
+		return Info{Name: name, Status: Disconnected}
 	}
 	defer f.Close()
 	s := bufio.NewScanner(f)
 	s.Split(bufio.ScanLines)
 
-	var info Info
+	info := Info{Name: name}
 	var energyNow, powerNow, energyFull, energyMax electricValue
 	for s.Scan() {
 		line := strings.TrimSpace(s.Text())
@@ -312,13 +362,23 @@ func allBatteriesInfo() Info {
 		}
 		infos = append(infos, batteryInfo(batt))
 	}
-	if len(infos) == 0 {
-		return Info{Status: Disconnected}
-	}
+	return aggregate(infos)
+}
+
+// aggregate combines multiple batteries into a single battery info.
+func aggregate(infos []Info) Info {
 	var allInfo Info
 	var techs []string
 	var voltEnergySum float64
+	var capacitySum, count int
 	for _, info := range infos {
+		// Batteries that are not present, e.g. a removable battery that was
+		// taken out, do not contribute to the combined battery.
+		if info.Status == Disconnected {
+			continue
+		}
+		count++
+		capacitySum += info.Capacity
 		allInfo.EnergyFull += info.EnergyFull
 		allInfo.EnergyMax += info.EnergyMax
 		allInfo.EnergyNow += info.EnergyNow
@@ -344,10 +404,20 @@ func allBatteriesInfo() Info {
 			}
 		}
 	}
+	if count == 0 {
+		return Info{Status: Disconnected}
+	}
 	// No meaningful voltage aggregator, so just average it by the energy
 	// stored at each voltage. (e.g. 10Wh @ 12V, 5Wh @ 9V = ~11V).
-	allInfo.Voltage = voltEnergySum / allInfo.EnergyNow
-	allInfo.Capacity = int(allInfo.EnergyNow * 100.0 / allInfo.EnergyFull)
+	if allInfo.EnergyNow > 0 {
+		allInfo.Voltage = voltEnergySum / allInfo.EnergyNow
+	}
+	if allInfo.EnergyFull > 0 {
+		allInfo.Capacity = int(allInfo.EnergyNow * 100.0 / allInfo.EnergyFull)
+	} else {
+		// Without energy information, all batteries are weighted equally.
+		allInfo.Capacity = capacitySum / count
+	}
 	allInfo.Technology = strings.Join(techs, ",")
 	return allInfo
 }
@@ -338,3 +338,45 @@ func TestCombined(t *testing.T) {
 	testBar.NextOutput().AssertText([]string{
 		"Discharging - 50/5h0m0s"})
 }
+
+func TestCombinedNamed(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	write(battery{
+		"NAME":        "BAT0",
+		"STATUS":      "Discharging",
+		"VOLTAGE_NOW": 12 * micros,
+		"POWER_NOW":   6 * micros,
+		"ENERGY_FULL": 24 * micros,
+		"ENERGY_NOW":  12 * micros,
+		"CAPACITY":    50,
+	})
+	write(battery{
+		"NAME":        "BAT2",
+		"STATUS":      "Discharging",
+		"VOLTAGE_NOW": 12 * micros,
+		"ENERGY_FULL": 48 * micros,
+		"ENERGY_NOW":  48 * micros,
+		"CAPACITY":    100,
+	})
+
+	testBar.New(t)
+	testBar.Run(Combined("BAT0", "BAT1", "BAT2").Output(func(i Info) bar.Output {
+		return outputs.Textf("%s - %v/%v", i.Status, i.Capacity, i.RemainingTime())
+	}))
+
+	// BAT1 is missing, so only BAT0 and BAT2 are combined.
+	// Available: 60Wh of 72Wh, discharge rate: 6W.
+	testBar.NextOutput("on start").AssertText([]string{"Discharging - 83/10h0m0s"})
+
+	fs = afero.NewMemMapFs()
+	write(battery{"NAME": "BAT0", "STATUS": "Full", "CAPACITY": 100})
+	write(battery{"NAME": "BAT1", "STATUS": "Not charging", "CAPACITY": 60})
+	testBar.Tick()
+
+	// Without energy information, capacity is averaged.
+	testBar.NextOutput("capacity only").AssertText([]string{"Not charging - 80/0s"})
+
+	fs = afero.NewMemMapFs()
+	testBar.Tick()
+	testBar.NextOutput("no batteries").AssertText([]string{"Disconnected - 0/0s"})
+}
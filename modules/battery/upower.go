@@ -0,0 +1,230 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package battery
+
+import (
+	"path"
+	"sort"
+
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+
+	godbus "github.com/godbus/dbus/v5"
+)
+
+const (
+	upowerService = "org.freedesktop.UPower"
+	upowerPath    = "/org/freedesktop/UPower"
+	upowerIface   = "org.freedesktop.UPower"
+	deviceIface   = "org.freedesktop.UPower.Device"
+)
+
+// Overridden in tests.
+var busType = dbus.System
+
+// upowerKinds maps UPower device types to the kind of device, see
+// https://upower.freedesktop.org/docs/Device.html#Device:Type.
+var upowerKinds = map[uint32]string{
+	2: "battery", 3: "ups", 5: "mouse", 6: "keyboard", 8: "phone",
+	9: "media player", 10: "tablet", 12: "gaming input", 13: "pen",
+	14: "touchpad", 17: "headset", 18: "speakers", 19: "headphones",
+	22: "remote control", 26: "wearable", 27: "toy",
+}
+
+var upowerTechnologies = map[uint32]string{
+	1: "Li-ion", 2: "Li-poly", 3: "LiFePO4", 4: "Lead acid", 5: "NiCd", 6: "NiMH",
+}
+
+// UPower constructs an instance of the battery module for the given battery
+// using UPower, which notifies the module of changes instead of polling.
+// The name can be the native name of a system battery (e.g. "BAT0"), or the
+// model of a peripheral device as shown by `upower --dump` (e.g. "MX Master
+// 3"), to display the battery level of wireless mice, keyboards, etc.
+func UPower(name string) *Module {
+	m := newUPowerModule(func(devices []Info) Info {
+		for _, d := range devices {
+			if d.Name == name {
+				return d
+			}
+		}
+		return Info{Name: name, Status: Disconnected}
+	})
+	l.Label(m, name)
+	return m
+}
+
+// UPowerAll constructs a battery module that aggregates all system batteries
+// known to UPower. Batteries of peripheral devices are not included.
+func UPowerAll() *Module {
+	return newUPowerModule(func(devices []Info) Info {
+		var infos []Info
+		for _, d := range devices {
+			if d.Kind == "battery" && d.powerSupply {
+				infos = append(infos, d)
+			}
+		}
+		return aggregate(infos)
+	})
+}
+
+func newUPowerModule(selectFunc func([]Info) Info) *Module {
+	m := newModule(nil)
+	m.scheduler.Stop()
+	m.watchFunc = func() (<-chan Info, func()) {
+		w := dbus.WatchSignals(busType, upowerService, "").Add(
+			upowerIface+".DeviceAdded",
+			upowerIface+".DeviceRemoved",
+			"org.freedesktop.DBus.Properties.PropertiesChanged",
+		)
+		u := &upower{w, map[godbus.ObjectPath]Info{}}
+		infos := make(chan Info)
+		done := make(chan struct{})
+		go func() {
+			u.enumerate()
+			info := selectFunc(u.list())
+			for {
+				select {
+				case infos <- info:
+				case <-done:
+					return
+				}
+				// Signals for other devices do not change the info, so
+				// wait until there is an update to send.
+				for last := info; info == last; {
+					select {
+					case sig := <-w.Signals:
+						u.handleSignal(sig)
+						info = selectFunc(u.list())
+					case <-done:
+						return
+					}
+				}
+			}
+		}()
+		return infos, func() {
+			close(done)
+			w.Unsubscribe()
+		}
+	}
+	return m
+}
+
+// upower tracks the UPower devices that have a battery.
+type upower struct {
+	w       *dbus.SignalWatcher
+	devices map[godbus.ObjectPath]Info
+}
+
+func (u *upower) list() []Info {
+	var infos []Info
+	for _, i := range u.devices {
+		infos = append(infos, i)
+	}
+	sort.Slice(infos, func(a, b int) bool { return infos[a].Name < infos[b].Name })
+	return infos
+}
+
+func (u *upower) enumerate() {
+	u.devices = map[godbus.ObjectPath]Info{}
+	r, err := u.w.Call(upowerPath, upowerIface+".EnumerateDevices")
+	if err != nil {
+		return
+	}
+	paths, _ := r[0].([]godbus.ObjectPath)
+	for _, p := range paths {
+		u.update(p)
+	}
+}
+
+func (u *upower) handleSignal(sig *dbus.Signal) {
+	switch sig.Name {
+	case "org.freedesktop.DBus.NameOwnerChanged":
+		u.enumerate()
+	case upowerIface + ".DeviceAdded":
+		if p, ok := sig.Body[0].(godbus.ObjectPath); ok {
+			u.update(p)
+		}
+	case upowerIface + ".DeviceRemoved":
+		if p, ok := sig.Body[0].(godbus.ObjectPath); ok {
+			delete(u.devices, p)
+		}
+	default:
+		if iface, _ := sig.Body[0].(string); iface == deviceIface {
+			u.update(sig.Path)
+		}
+	}
+}
+
+// update fetches the properties of a device, and removes it if it does not
+// have a battery.
+func (u *upower) update(p godbus.ObjectPath) {
+	delete(u.devices, p)
+	r, err := u.w.Call(p, "org.freedesktop.DBus.Properties.GetAll", deviceIface)
+	if err != nil {
+		l.Log("Failed to get properties of %s: %s", p, err)
+		return
+	}
+	props, _ := r[0].(map[string]godbus.Variant)
+	if i, ok := upowerInfo(p, props); ok {
+		u.devices[p] = i
+	}
+}
+
+func upowerInfo(p godbus.ObjectPath, props map[string]godbus.Variant) (Info, bool) {
+	get := func(name string) interface{} { return props[name].Value() }
+	typ, _ := get("Type").(uint32)
+	kind, ok := upowerKinds[typ]
+	if !ok {
+		return Info{}, false
+	}
+	i := Info{Kind: kind}
+	i.powerSupply, _ = get("PowerSupply").(bool)
+	// System batteries are named by their sysfs name, for consistency with
+	// the sysfs backend, and other devices by their model.
+	i.Name, _ = get("Model").(string)
+	if nativePath, _ := get("NativePath").(string); i.powerSupply && nativePath != "" {
+		i.Name = path.Base(nativePath)
+	}
+	if i.Name == "" {
+		i.Name = path.Base(string(p))
+	}
+	if present, ok := get("IsPresent").(bool); ok && !present {
+		i.Status = Disconnected
+		return i, true
+	}
+	percentage, _ := get("Percentage").(float64)
+	i.Capacity = int(percentage)
+	i.EnergyNow, _ = get("Energy").(float64)
+	i.EnergyFull, _ = get("EnergyFull").(float64)
+	i.EnergyMax, _ = get("EnergyFullDesign").(float64)
+	i.Power, _ = get("EnergyRate").(float64)
+	i.Voltage, _ = get("Voltage").(float64)
+	tech, _ := get("Technology").(uint32)
+	i.Technology = upowerTechnologies[tech]
+	state, _ := get("State").(uint32)
+	switch state {
+	case 1:
+		i.Status = Charging
+	case 2, 3, 6: // Discharging, empty, pending discharge.
+		i.Status = Discharging
+	case 4:
+		i.Status = Full
+	case 5: // Pending charge.
+		i.Status = NotCharging
+	default:
+		i.Status = Unknown
+	}
+	return i, true
+}
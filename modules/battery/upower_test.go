@@ -0,0 +1,166 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package battery
+
+import (
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	fakedbus "barista.run/testing/dbus"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func setupUPower() *fakedbus.UPower {
+	busType = dbus.Test
+	return fakedbus.New().UPower()
+}
+
+func TestUPower(t *testing.T) {
+	f := setupUPower()
+	f.AddDevice("line_power_AC", map[string]interface{}{
+		"Type": uint32(1), "Online": true, "PowerSupply": true,
+	})
+	bat := f.AddDevice("battery_BAT0", map[string]interface{}{
+		"Type":        uint32(2),
+		"NativePath":  "BAT0",
+		"Model":       "5B10W13930",
+		"PowerSupply": true,
+		"IsPresent":   true,
+		"State":       uint32(2),
+		"Percentage":  50.0,
+		"Energy":      25.0,
+		"EnergyFull":  50.0,
+		"EnergyRate":  12.5,
+		"Voltage":     12.0,
+		"Technology":  uint32(2),
+	})
+
+	testBar.New(t)
+	testBar.Run(UPower("BAT0").Output(func(i Info) bar.Output {
+		return outputs.Textf("%s %s %d%% %v %s", i.Name, i.Kind,
+			i.RemainingPct(), i.RemainingTime(), i.Technology)
+	}))
+	testBar.NextOutput("initial").AssertText([]string{"BAT0 battery 50% 2h0m0s Li-poly"})
+
+	bat.SetProperties(map[string]interface{}{
+		"State":      uint32(1),
+		"Energy":     30.0,
+		"EnergyRate": 10.0,
+	}, dbus.SignalTypeChanged)
+	testBar.NextOutput("on change").AssertText([]string{"BAT0 battery 60% 2h0m0s Li-poly"})
+
+	testBar.Tick()
+	testBar.AssertNoOutput("does not poll")
+
+	bat.SetPropertyForTest("IsPresent", false, dbus.SignalTypeInvalidated)
+	out := testBar.NextOutput("on battery removed")
+	out.AssertText([]string{"BAT0 battery 0% 0s "})
+
+	f.Unregister()
+	testBar.NextOutput("on service disconnect").AssertText([]string{"BAT0  0% 0s "})
+}
+
+func TestUPowerPeripherals(t *testing.T) {
+	f := setupUPower()
+	f.AddDevice("battery_BAT0", map[string]interface{}{
+		"Type": uint32(2), "NativePath": "BAT0", "PowerSupply": true,
+		"IsPresent": true, "State": uint32(4), "Percentage": 100.0,
+	})
+
+	testBar.New(t)
+	testBar.Run(UPower("MX Master 3").Output(func(i Info) bar.Output {
+		if i.Status == Disconnected {
+			return outputs.Textf("%s: %s", i.Name, i.Status)
+		}
+		return outputs.Textf("%s: %s %d%% %v", i.Name, i.Kind,
+			i.RemainingPct(), i.Discharging())
+	}))
+	testBar.NextOutput("initial").AssertText([]string{"MX Master 3: Disconnected"})
+
+	mouse := f.AddDevice("mouse_dev_AB_CD", map[string]interface{}{
+		"Type":        uint32(5),
+		"NativePath":  "/org/bluez/hci0/dev_AB_CD",
+		"Model":       "MX Master 3",
+		"PowerSupply": false,
+		"IsPresent":   true,
+		"State":       uint32(2),
+		"Percentage":  80.0,
+	})
+	testBar.NextOutput("on device added").AssertText([]string{"MX Master 3: mouse 80% true"})
+
+	f.AddDevice("keyboard_dev_12_34", map[string]interface{}{
+		"Type": uint32(6), "Model": "K380", "Percentage": 10.0,
+	})
+	testBar.AssertNoOutput("on other device added")
+
+	mouse.SetProperty("Percentage", 75.0)
+	testBar.NextOutput("on change").AssertText([]string{"MX Master 3: mouse 75% true"})
+
+	f.RemoveDevice("mouse_dev_AB_CD")
+	testBar.NextOutput("on device removed").AssertText([]string{"MX Master 3: Disconnected"})
+}
+
+func TestUPowerAll(t *testing.T) {
+	f := setupUPower()
+	f.AddDevice("battery_BAT0", map[string]interface{}{
+		"Type": uint32(2), "NativePath": "BAT0", "PowerSupply": true,
+		"IsPresent": true, "State": uint32(2), "Energy": 20.0,
+		"EnergyFull": 40.0, "EnergyRate": 5.0, "Voltage": 12.0,
+	})
+	f.AddDevice("battery_BAT1", map[string]interface{}{
+		"Type": uint32(2), "NativePath": "BAT1", "PowerSupply": true,
+		"IsPresent": true, "State": uint32(5), "Energy": 10.0,
+		"EnergyFull": 20.0, "Voltage": 12.0,
+	})
+	f.AddDevice("headset_dev_AB", map[string]interface{}{
+		"Type": uint32(17), "Model": "Headset", "PowerSupply": false,
+		"IsPresent": true, "State": uint32(2), "Energy": 1.0,
+		"EnergyFull": 2.0, "EnergyRate": 1.0,
+	})
+
+	testBar.New(t)
+	testBar.Run(UPowerAll().Output(func(i Info) bar.Output {
+		return outputs.Textf("%s %d%% %v", i.Status, i.RemainingPct(), i.RemainingTime())
+	}))
+	testBar.NextOutput("initial").AssertText([]string{"Discharging 50% 6h0m0s"})
+
+	f.Unregister()
+	testBar.NextOutput("on service disconnect").AssertText([]string{"Disconnected 0% 0s"})
+}
+
+func TestUPowerInfo(t *testing.T) {
+	_, ok := upowerInfo("/org/freedesktop/UPower/devices/line_power_AC",
+		map[string]godbus.Variant{"Type": godbus.MakeVariant(uint32(1))})
+	require.False(t, ok, "line power is not a battery")
+
+	for state, status := range map[uint32]Status{
+		0: Unknown, 1: Charging, 2: Discharging, 3: Discharging,
+		4: Full, 5: NotCharging, 6: Discharging, 42: Unknown,
+	} {
+		i, ok := upowerInfo("/org/freedesktop/UPower/devices/battery_BAT0",
+			map[string]godbus.Variant{
+				"Type":  godbus.MakeVariant(uint32(2)),
+				"State": godbus.MakeVariant(state),
+			})
+		require.True(t, ok)
+		require.Equal(t, status, i.Status, "state %d", state)
+		require.Equal(t, "battery_BAT0", i.Name, "named by path without model")
+	}
+}
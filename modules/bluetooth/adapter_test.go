@@ -17,12 +17,11 @@ package bluetooth
 import (
 	"testing"
 
-	godbus "github.com/godbus/dbus/v5"
-
 	"barista.run/bar"
 	"barista.run/base/watchers/dbus"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
+	fakedbus "barista.run/testing/dbus"
 )
 
 func init() {
@@ -96,11 +95,5 @@ func TestAdapterDisconnect(t *testing.T) {
 }
 
 func setupTestAdapter(adapterName string) *dbus.TestBusObject {
-	bus := dbus.SetupTestBus()
-	bluez := bus.RegisterService("org.bluez")
-
-	adapterObjPath := godbus.ObjectPath("/org/bluez/" + adapterName)
-	adapter := bluez.Object(adapterObjPath, "org.bluez.Adapter1")
-
-	return adapter
+	return fakedbus.New().Bluez().Adapter(adapterName, nil)
 }
@@ -15,15 +15,13 @@
 package bluetooth
 
 import (
-	"strings"
 	"testing"
 
-	godbus "github.com/godbus/dbus/v5"
-
 	"barista.run/bar"
 	"barista.run/base/watchers/dbus"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
+	fakedbus "barista.run/testing/dbus"
 )
 
 func TestDevice(t *testing.T) {
@@ -92,13 +90,5 @@ func TestDeviceDisconnect(t *testing.T) {
 }
 
 func setupTestDevice(adapterName, deviceMac string) (device, battery *dbus.TestBusObject) {
-	bus := dbus.SetupTestBus()
-	bluez := bus.RegisterService("org.bluez")
-
-	devicePath := "dev_" + strings.Replace(deviceMac, ":", "_", -1)
-	deviceObjPath := godbus.ObjectPath("/org/bluez/" + adapterName + "/" + devicePath)
-
-	device = bluez.Object(deviceObjPath, "org.bluez.Device1")
-	battery = bluez.Object(deviceObjPath, "org.bluez.Battery1")
-	return // device, battery
+	return fakedbus.New().Bluez().Device(adapterName, deviceMac, nil)
 }
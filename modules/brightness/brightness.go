@@ -0,0 +1,319 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package brightness provides an i3bar module that shows and controls the
+// brightness of a display backlight.
+package brightness // import "barista.run/modules/brightness"
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"golang.org/x/time/rate"
+)
+
+// Info represents the current brightness of a backlight device.
+type Info struct {
+	// Brightness is the current brightness, in device-specific units.
+	Brightness int
+	// Max is the maximum brightness supported by the device.
+	Max        int
+	controller controller
+	update     func(Info)
+}
+
+// Frac returns the current brightness as a fraction of the maximum.
+func (i Info) Frac() float64 {
+	if i.Max == 0 {
+		return 0
+	}
+	return float64(i.Brightness) / float64(i.Max)
+}
+
+// Pct returns the current brightness in the range 0-100.
+func (i Info) Pct() int {
+	return int((i.Frac() * 100) + 0.5)
+}
+
+// SetBrightness sets the brightness of the backlight, in device units.
+// Values outside the range [0, Max] are clamped.
+func (i Info) SetBrightness(brightness int) {
+	if brightness > i.Max {
+		brightness = i.Max
+	}
+	if brightness < 0 {
+		brightness = 0
+	}
+	if brightness == i.Brightness || i.controller == nil {
+		return
+	}
+	if err := i.controller.setBrightness(brightness); err != nil {
+		l.Log("Error updating brightness: %v", err)
+		return
+	}
+	i.Brightness = brightness
+	if i.update != nil {
+		i.update(i)
+	}
+}
+
+// SetPct sets the brightness of the backlight as a percentage of the maximum.
+func (i Info) SetPct(pct int) {
+	i.SetBrightness(i.fromPct(pct))
+}
+
+func (i Info) fromPct(pct int) int {
+	return int(float64(pct*i.Max)/100.0 + 0.5)
+}
+
+// controller sets the brightness of a backlight device.
+type controller interface {
+	setBrightness(int) error
+}
+
+// sysfsController writes the brightness directly to the device's sysfs file.
+// This usually requires a udev rule to grant the user write access.
+type sysfsController string
+
+func (s sysfsController) setBrightness(brightness int) error {
+	return ioutil.WriteFile(
+		filepath.Join(string(s), "brightness"),
+		[]byte(strconv.Itoa(brightness)),
+		0644)
+}
+
+// logindController sets the brightness using systemd-logind, which allows
+// any user with an active session to control the backlight.
+type logindController struct {
+	device  string
+	watcher *dbus.PropertiesWatcher
+}
+
+func (c logindController) setBrightness(brightness int) error {
+	_, err := c.watcher.Call("SetBrightness",
+		"backlight", c.device, uint32(brightness))
+	return err
+}
+
+// Module represents a bar.Module that displays backlight brightness.
+type Module struct {
+	device     string
+	scheduler  *timing.Scheduler
+	useLogind  value.Value // of bool
+	step       value.Value // of int
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// Overridden in tests.
+var backlightDir = "/sys/class/backlight"
+var busType = dbus.System
+
+// Device constructs a brightness module for the named backlight device,
+// e.g. "intel_backlight" or "acpi_video0".
+func Device(name string) *Module {
+	m := &Module{device: name, scheduler: timing.NewScheduler()}
+	l.Label(m, name)
+	l.Register(m, "scheduler", "outputFunc", "useLogind", "step")
+	m.useLogind.Set(false)
+	m.ScrollStep(5)
+	m.RefreshInterval(time.Second)
+	// Default output is just the brightness percentage.
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d%%", i.Pct())
+	})
+	return m
+}
+
+// New constructs a brightness module for the first available backlight device.
+func New() *Module {
+	return Device(firstDevice())
+}
+
+// Devices returns the names of all available backlight devices. It can be
+// used to create a module for each backlight when multiple are present.
+func Devices() []string {
+	files, _ := ioutil.ReadDir(backlightDir)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func firstDevice() string {
+	if devices := Devices(); len(devices) > 0 {
+		return devices[0]
+	}
+	return ""
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// UseLogind configures the module to change brightness using the logind
+// SetBrightness DBus call, instead of writing to sysfs directly. This works
+// without additional udev rules, but requires systemd-logind.
+func (m *Module) UseLogind() *Module {
+	m.useLogind.Set(true)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for the brightness. Changes
+// made using the module are shown immediately, but changes made elsewhere
+// (e.g. using the brightness keys) are only shown when the brightness is read,
+// since sysfs does not notify file watchers of changes to the brightness.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// ScrollStep sets the brightness change for each scroll event, as a
+// percentage of the maximum brightness. Each scroll event changes the
+// brightness by at least one device unit, so that backlights with only a few
+// levels can still be adjusted.
+func (m *Module) ScrollStep(pct int) *Module {
+	m.step.Set(pct)
+	return m
+}
+
+// RateLimiter throttles brightness updates to once every ~20ms to avoid
+// flooding the backlight device when scrolling quickly.
+var RateLimiter = rate.NewLimiter(rate.Every(20*time.Millisecond), 1)
+
+// defaultClickHandler raises/lowers the brightness on scroll.
+func (m *Module) defaultClickHandler(i Info) func(bar.Event) {
+	return func(e bar.Event) {
+		if !RateLimiter.Allow() {
+			return
+		}
+		step := m.step.Get().(int)
+		switch e.Button {
+		case bar.ScrollUp:
+			target := i.fromPct(i.Pct() + step)
+			if target <= i.Brightness {
+				target = i.Brightness + 1
+			}
+			i.SetBrightness(target)
+		case bar.ScrollDown:
+			target := i.fromPct(i.Pct() - step)
+			if target >= i.Brightness {
+				target = i.Brightness - 1
+			}
+			i.SetBrightness(target)
+		}
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	if m.device == "" {
+		s.Error(errors.New("No backlight device found"))
+		return
+	}
+	devDir := filepath.Join(backlightDir, m.device)
+
+	var ctrl controller = sysfsController(devDir)
+	if m.useLogind.Get().(bool) {
+		watcher := dbus.WatchProperties(busType, "org.freedesktop.login1",
+			"/org/freedesktop/login1/session/auto",
+			"org.freedesktop.login1.Session")
+		defer watcher.Unsubscribe()
+		ctrl = logindController{m.device, watcher}
+	}
+
+	var info value.ErrorValue
+	update := func() {
+		i, err := readInfo(devDir)
+		last, lastErr := info.Get()
+		if prev, ok := last.(Info); ok && lastErr == nil && err == nil &&
+			prev.Brightness == i.Brightness && prev.Max == i.Max {
+			// Unchanged since the last read.
+			return
+		}
+		i.controller = ctrl
+		i.update = func(i Info) { info.Set(i) }
+		info.SetOrError(i, err)
+	}
+	update()
+	i, err := info.Get()
+	nextInfo, done := info.Subscribe()
+	defer done()
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	for {
+		if s.Error(err) {
+			return
+		}
+		if in, ok := i.(Info); ok {
+			s.Output(outputs.Group(outputFunc(in)).
+				OnClick(m.defaultClickHandler(in)))
+		}
+		// Polling only updates the output if the brightness has changed.
+		for changed := false; !changed; {
+			select {
+			case <-m.scheduler.C:
+				update()
+			case <-nextInfo:
+				i, err = info.Get()
+				changed = true
+			case <-nextOutputFunc:
+				outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+				changed = true
+			}
+		}
+	}
+}
+
+func readInt(filename string) (int, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(bytes)))
+}
+
+func readInfo(devDir string) (Info, error) {
+	var i Info
+	var err error
+	i.Max, err = readInt(filepath.Join(devDir, "max_brightness"))
+	if err != nil {
+		return i, err
+	}
+	i.Brightness, err = readInt(filepath.Join(devDir, "actual_brightness"))
+	if os.IsNotExist(err) {
+		// Some drivers only provide the requested brightness.
+		i.Brightness, err = readInt(filepath.Join(devDir, "brightness"))
+	}
+	return i, err
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brightness
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	fakedbus "barista.run/testing/dbus"
+	"barista.run/testing/sysfs"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func init() {
+	busType = dbus.Test
+	RateLimiter = rate.NewLimiter(rate.Inf, 0)
+}
+
+var tree *sysfs.Tree
+
+func setupBacklightDir(t *testing.T) func() {
+	var cleanup func()
+	tree, cleanup = sysfs.TempDir(t)
+	require.NoError(t, tree.MkdirAll("/sys/class/backlight", 0755))
+	backlightDir = tree.Path("/sys/class/backlight")
+	return cleanup
+}
+
+func TestBrightness(t *testing.T) {
+	defer setupBacklightDir(t)()
+	testBar.New(t)
+	tree.Backlight("intel_backlight", 600, 1200)
+	tree.Backlight("acpi_video0", 10, 20)
+
+	require.Equal(t, []string{"acpi_video0", "intel_backlight"}, Devices())
+
+	b := Device("intel_backlight")
+	testBar.Run(b)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"50%"})
+
+	testBar.Tick()
+	testBar.AssertNoOutput("on tick without change")
+
+	tree.Write("/sys/class/backlight/intel_backlight/actual_brightness", 900)
+	testBar.Tick()
+	testBar.NextOutput("on change").AssertText([]string{"75%"})
+
+	b.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d/%d", i.Brightness, i.Max)
+	})
+	out = testBar.NextOutput("on output func change")
+	out.AssertText([]string{"900/1200"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on scroll up")
+	out.AssertText([]string{"960/1200"})
+	require.Equal(t, "960", tree.Read("/sys/class/backlight/intel_backlight/brightness"))
+
+	b.ScrollStep(50)
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("scroll past max")
+	out.AssertText([]string{"1200/1200"})
+	require.Equal(t, "1200", tree.Read("/sys/class/backlight/intel_backlight/brightness"))
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonLeft})
+	testBar.AssertNoOutput("on left click")
+
+	b.RefreshInterval(time.Minute)
+	start := timing.Now()
+	testBar.Tick()
+	require.Equal(t, time.Minute, timing.Now().Sub(start), "RefreshInterval")
+}
+
+func TestSmallMax(t *testing.T) {
+	defer setupBacklightDir(t)()
+	testBar.New(t)
+	tree.Backlight("acpi_video0", 3, 7)
+
+	b := New().Output(func(i Info) bar.Output {
+		return outputs.Textf("%d/%d", i.Brightness, i.Max)
+	})
+	testBar.Run(b)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"3/7"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on scroll up")
+	out.AssertText([]string{"4/7"}, "changes by at least one unit")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll down")
+	out.AssertText([]string{"3/7"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	testBar.NextOutput("on scroll down").AssertText([]string{"2/7"})
+}
+
+func TestLogind(t *testing.T) {
+	defer setupBacklightDir(t)()
+	testBar.New(t)
+	tree.Backlight("amdgpu_bl0", 40, 100)
+
+	calls := fakedbus.Record(fakedbus.New().Logind().Session(), "SetBrightness")
+
+	b := New().UseLogind()
+	testBar.Run(b)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"40%"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	require.Equal(t, []interface{}{"backlight", "amdgpu_bl0", uint32(35)}, <-calls)
+	testBar.NextOutput("on scroll down").AssertText([]string{"35%"})
+	require.Equal(t, "40", tree.Read("/sys/class/backlight/amdgpu_bl0/brightness"),
+		"sysfs not modified when using logind")
+}
+
+func TestErrors(t *testing.T) {
+	defer setupBacklightDir(t)()
+	testBar.New(t)
+
+	testBar.Run(New(), Device("missing"))
+	out := testBar.LatestOutput(0, 1)
+	out.At(0).AssertError("no backlight devices")
+	out.At(1).AssertError("device does not exist")
+
+	require.Equal(t, 0, Info{}.Pct())
+}
@@ -0,0 +1,512 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package caldav provides an i3bar module that shows upcoming events from
+// CalDAV calendars, such as those provided by Nextcloud, Fastmail, or
+// Radicale. It offers the same event lists as the Google Calendar module.
+package caldav // import "barista.run/modules/caldav"
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Status represents the status of an event.
+type Status string
+
+// Possible values for status per RFC 5545.
+const (
+	StatusUnknown   = Status("")
+	StatusConfirmed = Status("CONFIRMED")
+	StatusTentative = Status("TENTATIVE")
+	StatusCancelled = Status("CANCELLED")
+)
+
+// Event represents a single occurrence of a calendar event.
+type Event struct {
+	Start       time.Time
+	End         time.Time
+	Alert       time.Time
+	EventStatus Status
+	Location    string
+	Summary     string
+	// Recurring is true if this is an occurrence of a recurring event.
+	Recurring bool
+	// AllDay is true for events that span whole days rather than specific
+	// times. Their Start and End are at midnight in the local timezone.
+	AllDay bool
+}
+
+// UntilStart returns the time remaining until the event starts.
+func (e Event) UntilStart() time.Duration {
+	return e.Start.Sub(timing.Now())
+}
+
+// UntilEnd returns the time remaining until the event ends.
+func (e Event) UntilEnd() time.Duration {
+	return e.End.Sub(timing.Now())
+}
+
+// UntilAlert returns the time remaining until a notification should be
+// displayed for this event.
+func (e Event) UntilAlert() time.Duration {
+	return e.Alert.Sub(timing.Now())
+}
+
+// EventList represents the list of events split by the temporal state of each
+// event: in progress, alerting (upcoming but within notification duration), or
+// upcoming beyond the notification duration.
+type EventList struct {
+	// All events currently in progress
+	InProgress []Event
+	// Events where the time until start is less than the notification duration
+	Alerting []Event
+	// All other future events
+	Upcoming []Event
+}
+
+type config struct {
+	lookahead time.Duration
+	username  string
+	password  string
+	// keyringService, if set, is used to look up the password.
+	keyringService string
+}
+
+// Module represents a CalDAV calendar barista module.
+type Module struct {
+	urls       []string
+	config     value.Value // of config
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(EventList) bar.Output
+}
+
+// New creates a calendar module for the given calendar collection URLs,
+// e.g. "https://cloud.example.com/remote.php/dav/calendars/user/personal/".
+// Events from all calendars are merged.
+func New(urls ...string) *Module {
+	m := &Module{urls: urls, scheduler: timing.NewScheduler()}
+	l.Label(m, strings.Join(urls, ","))
+	l.Register(m, "scheduler", "outputFunc")
+	m.config.Set(config{})
+	m.RefreshInterval(10 * time.Minute)
+	m.TimeWindow(18 * time.Hour)
+	m.Output(func(evts EventList) bar.Output {
+		hasEvent := false
+		out := outputs.Group()
+		for _, e := range evts.InProgress {
+			out.Append(outputs.Textf("ends %s: %s",
+				e.End.Format("15:04"), e.Summary))
+			hasEvent = true
+		}
+		for _, e := range evts.Alerting {
+			out.Append(outputs.Textf("%s: %s",
+				e.Start.Format("15:04"), e.Summary))
+			hasEvent = true
+		}
+		if !hasEvent {
+			for _, e := range evts.Upcoming {
+				// If no other events have been displayed, show next upcoming.
+				out.Append(outputs.Textf("%s: %s",
+					e.Start.Format("15:04"), e.Summary))
+				break
+			}
+		}
+		return out
+	})
+	return m
+}
+
+// Output sets the output format for the module.
+func (m *Module) Output(outputFunc func(EventList) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval sets the interval for fetching new events. Note that this is
+// distinct from the rendering interval, which is determined by the events.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+func (m *Module) getConfig() config {
+	return m.config.Get().(config)
+}
+
+// TimeWindow controls the search window for future events.
+func (m *Module) TimeWindow(window time.Duration) *Module {
+	c := m.getConfig()
+	c.lookahead = window
+	m.config.Set(c)
+	return m
+}
+
+// BasicAuth sets the username and password used to access the calendars.
+// Many servers require an app-specific password for CalDAV access.
+func (m *Module) BasicAuth(username, password string) *Module {
+	c := m.getConfig()
+	c.username, c.password, c.keyringService = username, password, ""
+	m.config.Set(c)
+	return m
+}
+
+// Keyring sets the username used to access the calendars, with the password
+// read from the system keyring under the given service name.
+func (m *Module) Keyring(service, username string) *Module {
+	c := m.getConfig()
+	c.username, c.password, c.keyringService = username, "", service
+	m.config.Set(c)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	outf := m.outputFunc.Get().(func(EventList) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	conf := m.getConfig()
+	nextConfig, done := m.config.Subscribe()
+	defer done()
+	renderer := timing.NewScheduler()
+	evts, err := m.fetch(conf)
+	for {
+		if sink.Error(err) {
+			return
+		}
+		list, refresh := makeEventList(evts)
+		if !refresh.IsZero() {
+			renderer.At(refresh.Add(time.Duration(1)))
+		}
+		sink.Output(outf(list))
+		select {
+		case <-nextOutputFunc:
+			outf = m.outputFunc.Get().(func(EventList) bar.Output)
+		case <-nextConfig:
+			conf = m.getConfig()
+			evts, err = m.fetch(conf)
+		case <-m.scheduler.C:
+			evts, err = m.fetch(conf)
+		case <-renderer.C:
+		}
+	}
+}
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+const queryTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><C:calendar-data/></D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="%s" end="%s"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`
+
+type multistatus struct {
+	Responses []struct {
+		Propstats []struct {
+			Prop struct {
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+func (m *Module) fetch(conf config) ([]Event, error) {
+	timeMin := timing.Now()
+	timeMax := timeMin.Add(conf.lookahead)
+	password := conf.password
+	if conf.keyringService != "" {
+		var err error
+		password, err = keyring.Get(conf.keyringService, conf.username)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var events []Event
+	for _, url := range m.urls {
+		// The server returns all events with an occurrence in the time range.
+		// Recurring events are expanded locally, since server-side expansion
+		// is not consistently supported.
+		body := fmt.Sprintf(queryTemplate,
+			timeMin.UTC().Format("20060102T150405Z"),
+			timeMax.UTC().Format("20060102T150405Z"))
+		req, err := http.NewRequest("REPORT", url, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Depth", "1")
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+		if conf.username != "" {
+			req.SetBasicAuth(conf.username, password)
+		}
+		evts, err := query(req, timeMin, timeMax)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, evts...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+	return events, nil
+}
+
+func query(req *http.Request, timeMin, timeMax time.Time) ([]Event, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("%s: HTTP %s", req.URL, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var ms multistatus
+	if err := xml.Unmarshal(data, &ms); err != nil {
+		return nil, err
+	}
+	var events []Event
+	for _, r := range ms.Responses {
+		for _, p := range r.Propstats {
+			if p.Prop.CalendarData == "" {
+				continue
+			}
+			cal, err := parseICal(p.Prop.CalendarData)
+			if err != nil {
+				return nil, err
+			}
+			evts, err := occurrences(cal, timeMin, timeMax)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, evts...)
+		}
+	}
+	return events, nil
+}
+
+// occurrences returns all occurrences of the events in the calendar object
+// that end after timeMin and start before timeMax.
+func occurrences(cal *component, timeMin, timeMax time.Time) ([]Event, error) {
+	// A calendar object contains one event and any modified occurrences of it,
+	// which have a RECURRENCE-ID matching the original start time.
+	var master *component
+	overrides := map[time.Time]*component{}
+	for _, c := range cal.components {
+		if c.name != "VEVENT" {
+			continue
+		}
+		if rid, ok := c.get("RECURRENCE-ID"); ok {
+			t, _, err := parseTime(rid)
+			if err != nil {
+				return nil, err
+			}
+			overrides[t.UTC()] = c
+		} else if master == nil {
+			master = c
+		}
+	}
+	var events []Event
+	add := func(e Event, ok bool) {
+		if ok && e.End.After(timeMin) && e.Start.Before(timeMax) &&
+			e.EventStatus != StatusCancelled {
+			events = append(events, e)
+		}
+	}
+	if master == nil {
+		for _, c := range overrides {
+			e, ok, err := makeEvent(c, time.Time{})
+			if err != nil {
+				return nil, err
+			}
+			add(e, ok)
+		}
+		return events, nil
+	}
+	rule, isRecurring := master.get("RRULE")
+	if !isRecurring {
+		e, ok, err := makeEvent(master, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		add(e, ok)
+		return events, nil
+	}
+	// Occurrences that start before timeMin may still be in progress.
+	e, _, err := makeEvent(master, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	from := timeMin.Add(-e.End.Sub(e.Start))
+	dtstart, _ := master.get("DTSTART")
+	start, _, err := parseTime(dtstart)
+	if err != nil {
+		return nil, err
+	}
+	rr, err := parseRRule(rule.value, start.Location())
+	if err != nil {
+		return nil, err
+	}
+	excluded := map[time.Time]bool{}
+	for _, p := range master.getAll("EXDATE") {
+		for _, v := range strings.Split(p.value, ",") {
+			p.value = v
+			if t, _, err := parseTime(p); err == nil {
+				excluded[t.UTC()] = true
+			}
+		}
+	}
+	for _, t := range rr.expand(start, from, timeMax) {
+		if excluded[t.UTC()] {
+			continue
+		}
+		c := master
+		instance := t
+		if o, ok := overrides[t.UTC()]; ok {
+			c, instance = o, time.Time{}
+			delete(overrides, t.UTC())
+		}
+		e, ok, err := makeEvent(c, instance)
+		if err != nil {
+			return nil, err
+		}
+		e.Recurring = true
+		add(e, ok)
+	}
+	// Occurrences after the time window may have been moved into it.
+	for _, c := range overrides {
+		e, ok, err := makeEvent(c, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		e.Recurring = true
+		add(e, ok)
+	}
+	return events, nil
+}
+
+// makeEvent creates an event from a VEVENT component. If instance is set, it
+// replaces the start time of the event, keeping the same duration. All-day
+// events keep the same number of days instead, and last one day if they have
+// no end.
+func makeEvent(c *component, instance time.Time) (e Event, ok bool, err error) {
+	dtstart, found := c.get("DTSTART")
+	if !found {
+		return e, false, fmt.Errorf("event %q has no DTSTART", c.text("SUMMARY"))
+	}
+	start, allDay, err := parseTime(dtstart)
+	if err != nil {
+		return e, false, err
+	}
+	var duration time.Duration
+	if dtend, found := c.get("DTEND"); found {
+		end, _, err := parseTime(dtend)
+		if err != nil {
+			return e, false, err
+		}
+		duration = end.Sub(start)
+	} else if d, found := c.get("DURATION"); found {
+		if duration, err = parseDuration(d.value); err != nil {
+			return e, false, err
+		}
+	}
+	if !instance.IsZero() {
+		start = instance
+	}
+	end := start.Add(duration)
+	if allDay {
+		// Days can be shorter or longer than 24 hours across DST changes.
+		days := int((duration + 12*time.Hour) / (24 * time.Hour))
+		if days < 1 {
+			days = 1
+		}
+		end = start.AddDate(0, 0, days)
+	}
+	e = Event{
+		Start:       start,
+		End:         end,
+		Alert:       start,
+		EventStatus: Status(strings.ToUpper(c.text("STATUS"))),
+		Location:    c.text("LOCATION"),
+		Summary:     c.text("SUMMARY"),
+		AllDay:      allDay,
+	}
+	// Use the earliest display alarm relative to the start of the event.
+	for _, alarm := range c.components {
+		if alarm.name != "VALARM" || strings.ToUpper(alarm.text("ACTION")) != "DISPLAY" {
+			continue
+		}
+		trigger, found := alarm.get("TRIGGER")
+		if !found || trigger.params["VALUE"] == "DATE-TIME" || trigger.params["RELATED"] == "END" {
+			continue
+		}
+		before, err := parseDuration(trigger.value)
+		if err != nil {
+			return e, false, err
+		}
+		if alert := start.Add(before); alert.Before(e.Alert) {
+			e.Alert = alert
+		}
+	}
+	return e, true, nil
+}
+
+func makeEventList(events []Event) (EventList, time.Time) {
+	now := timing.Now()
+	var refresh time.Time
+	list := EventList{}
+	for _, e := range events {
+		switch {
+		case now.After(e.End):
+			continue
+		case now.After(e.Start) && e.End.After(now):
+			setIfEarlier(&refresh, e.End)
+			list.InProgress = append(list.InProgress, e)
+		case now.After(e.Alert) && e.Start.After(now):
+			setIfEarlier(&refresh, e.Start)
+			list.Alerting = append(list.Alerting, e)
+		default:
+			setIfEarlier(&refresh, e.Alert)
+			list.Upcoming = append(list.Upcoming, e)
+		}
+	}
+	return list, refresh
+}
+
+func setIfEarlier(target *time.Time, source time.Time) {
+	if target.IsZero() || source.Before(*target) {
+		*target = source
+	}
+}
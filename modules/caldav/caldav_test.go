@@ -0,0 +1,478 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caldav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func vcalendar(events ...string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+		strings.Join(events, "") + "END:VCALENDAR\r\n"
+}
+
+func vevent(lines ...string) string {
+	return "BEGIN:VEVENT\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VEVENT\r\n"
+}
+
+type calServer struct {
+	*httptest.Server
+	sync.Mutex
+	objects []string
+	auth    string
+}
+
+func (c *calServer) setObjects(objects ...string) {
+	c.Lock()
+	defer c.Unlock()
+	c.objects = objects
+}
+
+func newServer(t *testing.T) *calServer {
+	c := &calServer{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Lock()
+		defer c.Unlock()
+		require.Equal(t, "REPORT", r.Method)
+		require.Equal(t, "1", r.Header.Get("Depth"))
+		body, _ := ioutil.ReadAll(r.Body)
+		require.Contains(t, string(body), `<C:time-range start="20161125T204700Z"`)
+		if user, pass, _ := r.BasicAuth(); user+":"+pass != c.auth {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">`)
+		for i, o := range c.objects {
+			fmt.Fprintf(w, `<d:response><d:href>/cal/%d.ics</d:href><d:propstat><d:prop><cal:calendar-data>`, i)
+			xml.EscapeText(w, []byte(o))
+			fmt.Fprint(w, `</cal:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+		}
+		fmt.Fprint(w, `</d:multistatus>`)
+	}))
+	return c
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	srv := newServer(t)
+	defer srv.Close()
+	srv.auth = "user:secret"
+	srv.setObjects(
+		vcalendar(vevent(
+			"UID:standup",
+			"SUMMARY:Standup",
+			"DTSTART;TZID=America/New_York:20161121T160000",
+			"DURATION:PT15M",
+			"RRULE:FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+			"BEGIN:VALARM",
+			"ACTION:DISPLAY",
+			"TRIGGER:-PT10M",
+			"END:VALARM",
+		)),
+		vcalendar(vevent(
+			"UID:lunch",
+			"SUMMARY:Lunch\\, late",
+			"LOCATION:Cafe",
+			"DTSTART:20161125T203000Z",
+			"DTEND:20161125T213000Z",
+		)),
+		vcalendar(vevent(
+			"UID:holiday",
+			"SUMMARY:Holiday",
+			"DTSTART;VALUE=DATE:20161127",
+			"DTEND;VALUE=DATE:20161128",
+		)),
+	)
+
+	cal := New(srv.URL+"/cal/").BasicAuth("user", "secret").
+		TimeWindow(4 * 24 * time.Hour).RefreshInterval(time.Hour)
+	cal.Output(func(evts EventList) bar.Output {
+		out := outputs.Group()
+		for _, e := range evts.InProgress {
+			out.Append(outputs.Textf("now: %s @ %s", e.Summary, e.Location))
+		}
+		for _, e := range evts.Alerting {
+			out.Append(outputs.Textf("soon: %s in %v", e.Summary,
+				e.UntilStart().Round(time.Minute)))
+		}
+		for _, e := range evts.Upcoming {
+			if e.AllDay {
+				out.Append(outputs.Textf("later: %s on %s", e.Summary,
+					e.Start.Format("Mon")))
+				continue
+			}
+			out.Append(outputs.Textf("later: %s at %s", e.Summary,
+				e.Start.UTC().Format("Mon 15:04")))
+		}
+		return out
+	})
+	testBar.Run(cal)
+	testBar.NextOutput("initial events").AssertText([]string{
+		"now: Lunch, late @ Cafe",
+		"later: Standup at Fri 21:00",
+		"later: Holiday on Sun",
+		"later: Standup at Mon 21:00",
+	})
+
+	timing.NextTick()
+	testBar.NextOutput("alert for standup").AssertText([]string{
+		"now: Lunch, late @ Cafe",
+		"soon: Standup in 10m0s",
+		"later: Holiday on Sun",
+		"later: Standup at Mon 21:00",
+	})
+
+	timing.NextTick()
+	testBar.NextOutput("standup started").AssertText([]string{
+		"now: Lunch, late @ Cafe",
+		"now: Standup @ ",
+		"later: Holiday on Sun",
+		"later: Standup at Mon 21:00",
+	})
+}
+
+func TestErrors(t *testing.T) {
+	testBar.New(t)
+	srv := newServer(t)
+	defer srv.Close()
+	srv.auth = "user:secret"
+
+	testBar.Run(New(srv.URL+"/cal/").BasicAuth("user", "wrong"))
+	testBar.NextOutput("auth failure").At(0).AssertError()
+
+	testBar.New(t)
+	srv.setObjects(vcalendar(vevent("SUMMARY:Broken")))
+	testBar.Run(New(srv.URL+"/cal/").BasicAuth("user", "secret"))
+	testBar.NextOutput("missing DTSTART").At(0).AssertError()
+}
+
+func parseTimes(t *testing.T, times ...string) []time.Time {
+	var out []time.Time
+	for _, s := range times {
+		tm, err := time.Parse(time.RFC3339, s)
+		require.NoError(t, err)
+		out = append(out, tm)
+	}
+	return out
+}
+
+func TestOccurrences(t *testing.T) {
+	timeMin := time.Date(2016, time.November, 1, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		desc     string
+		lines    []string
+		expected []string
+	}{
+		{
+			"weekly across DST change",
+			[]string{
+				"DTSTART;TZID=Europe/Berlin:20161020T090000",
+				"RRULE:FREQ=WEEKLY;UNTIL=20161110T235959Z",
+			},
+			[]string{"2016-11-03T08:00:00Z", "2016-11-10T08:00:00Z"},
+		},
+		{
+			"every other week on two days",
+			[]string{
+				"DTSTART:20161114T120000Z",
+				"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=4",
+			},
+			[]string{
+				"2016-11-14T12:00:00Z", "2016-11-17T12:00:00Z",
+				"2016-11-28T12:00:00Z", "2016-12-01T12:00:00Z",
+			},
+		},
+		{
+			"last friday of the month",
+			[]string{
+				"DTSTART:20160129T170000Z",
+				"RRULE:FREQ=MONTHLY;BYDAY=-1FR",
+			},
+			[]string{"2016-11-25T17:00:00Z", "2016-12-30T17:00:00Z"},
+		},
+		{
+			"second tuesday of the month",
+			[]string{
+				"DTSTART:20160112T170000Z",
+				"RRULE:FREQ=MONTHLY;BYDAY=2TU",
+			},
+			[]string{"2016-11-08T17:00:00Z", "2016-12-13T17:00:00Z"},
+		},
+		{
+			"last day of the month",
+			[]string{
+				"DTSTART:20160131T170000Z",
+				"RRULE:FREQ=MONTHLY;BYMONTHDAY=-1",
+			},
+			[]string{"2016-11-30T17:00:00Z", "2016-12-31T17:00:00Z"},
+		},
+		{
+			"monthly on a day not in every month",
+			[]string{
+				"DTSTART:20160831T170000Z",
+				"RRULE:FREQ=MONTHLY",
+			},
+			[]string{"2016-12-31T17:00:00Z"},
+		},
+		{
+			"yearly",
+			[]string{
+				"DTSTART;VALUE=DATE-TIME:20101205T100000Z",
+				"RRULE:FREQ=YEARLY",
+			},
+			[]string{"2016-12-05T10:00:00Z"},
+		},
+		{
+			"started long ago",
+			[]string{
+				"DTSTART:19700101T100000Z",
+				"RRULE:FREQ=DAILY;BYMONTH=12;BYDAY=SU",
+			},
+			[]string{
+				"2016-12-04T10:00:00Z", "2016-12-11T10:00:00Z",
+				"2016-12-18T10:00:00Z", "2016-12-25T10:00:00Z",
+			},
+		},
+		{
+			"every other week since long ago",
+			[]string{
+				"DTSTART:19800107T090000Z",
+				"RRULE:FREQ=WEEKLY;INTERVAL=2",
+			},
+			[]string{
+				"2016-11-07T09:00:00Z", "2016-11-21T09:00:00Z",
+				"2016-12-05T09:00:00Z", "2016-12-19T09:00:00Z",
+			},
+		},
+		{
+			"daily with count",
+			[]string{
+				"DTSTART:20161229T100000Z",
+				"RRULE:FREQ=DAILY;COUNT=2",
+			},
+			[]string{"2016-12-29T10:00:00Z", "2016-12-30T10:00:00Z"},
+		},
+		{
+			"exdate",
+			[]string{
+				"DTSTART:20161201T100000Z",
+				"RRULE:FREQ=DAILY;COUNT=4",
+				"EXDATE:20161202T100000Z,20161203T100000Z",
+			},
+			[]string{"2016-12-01T10:00:00Z", "2016-12-04T10:00:00Z"},
+		},
+		{
+			"floating time",
+			[]string{"DTSTART:20161201T100000"},
+			[]string{time.Date(2016, time.December, 1, 10, 0, 0, 0, time.Local).
+				UTC().Format(time.RFC3339)},
+		},
+		{
+			"unknown timezone",
+			[]string{"DTSTART;TZID=\"Custom: Zone\":20161201T100000"},
+			[]string{time.Date(2016, time.December, 1, 10, 0, 0, 0, time.Local).
+				UTC().Format(time.RFC3339)},
+		},
+		{
+			"all day",
+			[]string{"DTSTART;VALUE=DATE:20161201"},
+			[]string{time.Date(2016, time.December, 1, 0, 0, 0, 0, time.Local).
+				UTC().Format(time.RFC3339)},
+		},
+		{
+			"yearly all day",
+			[]string{
+				"DTSTART;VALUE=DATE:19501224",
+				"RRULE:FREQ=YEARLY",
+			},
+			[]string{time.Date(2016, time.December, 24, 0, 0, 0, 0, time.Local).
+				UTC().Format(time.RFC3339)},
+		},
+		{
+			"in progress at start of window",
+			[]string{
+				"DTSTART;VALUE=DATE:20001030",
+				"DTEND;VALUE=DATE:20001102",
+				"RRULE:FREQ=YEARLY",
+			},
+			[]string{time.Date(2016, time.October, 30, 0, 0, 0, 0, time.Local).
+				UTC().Format(time.RFC3339)},
+		},
+		{
+			"cancelled",
+			[]string{"DTSTART:20161201T100000Z", "STATUS:CANCELLED"},
+			nil,
+		},
+		{
+			"outside window",
+			[]string{"DTSTART:20170101T100000Z"},
+			nil,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			cal, err := parseICal(vcalendar(vevent(tc.lines...)))
+			require.NoError(t, err)
+			evts, err := occurrences(cal, timeMin, timeMax)
+			require.NoError(t, err)
+			var starts []time.Time
+			for _, e := range evts {
+				starts = append(starts, e.Start.UTC())
+			}
+			require.Equal(t, parseTimes(t, tc.expected...), starts)
+		})
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	timeMin := time.Date(2016, time.November, 1, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2016, time.November, 5, 0, 0, 0, 0, time.UTC)
+	cal, err := parseICal(vcalendar(
+		vevent(
+			"SUMMARY:Daily",
+			"DTSTART:20161101T100000Z",
+			"DTEND:20161101T110000Z",
+			"RRULE:FREQ=DAILY",
+		),
+		vevent(
+			"SUMMARY:Moved",
+			"RECURRENCE-ID:20161102T100000Z",
+			"DTSTART:20161102T150000Z",
+			"DTEND:20161102T153000Z",
+		),
+		vevent(
+			"SUMMARY:Cancelled",
+			"RECURRENCE-ID:20161103T100000Z",
+			"DTSTART:20161103T100000Z",
+			"DTEND:20161103T110000Z",
+			"STATUS:CANCELLED",
+		),
+		vevent(
+			"SUMMARY:Moved earlier",
+			"RECURRENCE-ID:20161110T100000Z",
+			"DTSTART:20161104T150000Z",
+			"DTEND:20161104T153000Z",
+		),
+	))
+	require.NoError(t, err)
+	evts, err := occurrences(cal, timeMin, timeMax)
+	require.NoError(t, err)
+	var got []string
+	for _, e := range evts {
+		require.True(t, e.Recurring)
+		got = append(got, fmt.Sprintf("%s %s-%s", e.Summary,
+			e.Start.UTC().Format("02 15:04"), e.End.UTC().Format("15:04")))
+	}
+	require.Equal(t, []string{
+		"Daily 01 10:00-11:00",
+		"Moved 02 15:00-15:30",
+		"Daily 04 10:00-11:00",
+		"Moved earlier 04 15:00-15:30",
+	}, got)
+}
+
+func TestAllDay(t *testing.T) {
+	timeMin := time.Date(2016, time.November, 1, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2016, time.December, 1, 0, 0, 0, 0, time.UTC)
+	cal, err := parseICal(vcalendar(
+		vevent(
+			"SUMMARY:Conference",
+			"DTSTART;VALUE=DATE:20161114",
+			"DTEND;VALUE=DATE:20161117",
+		),
+		vevent(
+			"SUMMARY:Reminder",
+			"DTSTART;VALUE=DATE:20161120",
+		),
+		vevent(
+			"SUMMARY:Offsite",
+			"DTSTART;VALUE=DATE:20161122",
+			"DURATION:P2D",
+		),
+	))
+	require.NoError(t, err)
+	var got []string
+	for _, c := range cal.components {
+		e, ok, err := makeEvent(c, time.Time{})
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.True(t, e.AllDay)
+		require.Equal(t, 0, e.Start.Hour(), "starts at local midnight")
+		got = append(got, fmt.Sprintf("%s %s-%s", e.Summary,
+			e.Start.Format("Jan 02"), e.End.Format("Jan 02")))
+	}
+	require.Equal(t, []string{
+		"Conference Nov 14-Nov 17",
+		"Reminder Nov 20-Nov 21",
+		"Offsite Nov 22-Nov 24",
+	}, got)
+
+	evts, err := occurrences(cal, timeMin, timeMax)
+	require.NoError(t, err)
+	require.Len(t, evts, 1, "one event per calendar object")
+}
+
+func TestParseICal(t *testing.T) {
+	cal, err := parseICal("BEGIN:VCALENDAR\nBEGIN:VEVENT\n" +
+		"SUMMARY:A very long summary that has been\n  folded over two lines\n" +
+		"DESCRIPTION;ALTREP=\"cid:x:y\":Line one\\nLine two\\; three\n" +
+		"END:VEVENT\nEND:VCALENDAR\n")
+	require.NoError(t, err)
+	require.Len(t, cal.components, 1)
+	evt := cal.components[0]
+	require.Equal(t, "A very long summary that has been folded over two lines",
+		evt.text("SUMMARY"))
+	require.Equal(t, "Line one\nLine two; three", evt.text("DESCRIPTION"))
+	desc, _ := evt.get("DESCRIPTION")
+	require.Equal(t, "cid:x:y", desc.params["ALTREP"])
+
+	_, err = parseICal("BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n")
+	require.Error(t, err, "mismatched END")
+	_, err = parseICal("BEGIN:VCALENDAR\nBEGIN:VEVENT\n")
+	require.Error(t, err, "incomplete")
+	_, err = parseICal("BEGIN:VCALENDAR\nnot a property\nEND:VCALENDAR\n")
+	require.Error(t, err, "malformed")
+
+	for in, out := range map[string]time.Duration{
+		"-PT15M":  -15 * time.Minute,
+		"P1DT2H":  26 * time.Hour,
+		"+P1W":    7 * 24 * time.Hour,
+		"PT1M30S": 90 * time.Second,
+	} {
+		d, err := parseDuration(in)
+		require.NoError(t, err, in)
+		require.Equal(t, out, d, in)
+	}
+	for _, in := range []string{"15M", "PTM", "P1"} {
+		_, err := parseDuration(in)
+		require.Error(t, err, in)
+	}
+}
@@ -0,0 +1,497 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caldav
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// property is a single iCalendar content line, e.g.
+//
+//	DTSTART;TZID=Europe/Berlin:20200101T090000
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// component is an iCalendar component (VCALENDAR, VEVENT, VALARM, ...).
+type component struct {
+	name       string
+	props      []property
+	components []*component
+}
+
+func (c *component) get(name string) (property, bool) {
+	for _, p := range c.props {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return property{}, false
+}
+
+func (c *component) getAll(name string) []property {
+	var props []property
+	for _, p := range c.props {
+		if p.name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+func (c *component) text(name string) string {
+	p, _ := c.get(name)
+	return unescape(p.value)
+}
+
+// parseICal parses an iCalendar object (RFC 5545).
+func parseICal(data string) (*component, error) {
+	root := &component{}
+	stack := []*component{root}
+	s := bufio.NewScanner(strings.NewReader(data))
+	s.Buffer(nil, 1024*1024)
+	var lines []string
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		// Long lines are folded by inserting a CRLF followed by whitespace.
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch p.name {
+		case "BEGIN":
+			c := &component{name: strings.ToUpper(p.value)}
+			top.components = append(top.components, c)
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 1 || top.name != strings.ToUpper(p.value) {
+				return nil, fmt.Errorf("unexpected END:%s", p.value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			top.props = append(top.props, p)
+		}
+	}
+	if len(stack) != 1 || len(root.components) == 0 {
+		return nil, fmt.Errorf("incomplete calendar data")
+	}
+	return root.components[0], nil
+}
+
+func parseProperty(line string) (property, error) {
+	p := property{params: map[string]string{}}
+	// The value starts at the first colon that is not within a quoted
+	// parameter value.
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return p, fmt.Errorf("malformed line %q", line)
+	}
+	p.value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	p.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			p.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return p, nil
+}
+
+var unescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescape(text string) string {
+	return unescaper.Replace(text)
+}
+
+// parseTime parses a DATE-TIME or DATE property. Times with a TZID use that
+// timezone if it is known to the system, and floating times use the local
+// timezone. allDay is set for DATE values.
+func parseTime(p property) (t time.Time, allDay bool, err error) {
+	loc := time.Local
+	if tzid, ok := p.params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	return parseTimeIn(p.value, p.params["VALUE"] == "DATE", loc)
+}
+
+func parseTimeIn(value string, date bool, loc *time.Location) (time.Time, bool, error) {
+	if date || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// parseDuration parses an iCalendar duration, e.g. "-PT15M" or "P1DT2H".
+func parseDuration(value string) (time.Duration, error) {
+	orig := value
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(value, "-"):
+		sign = -1
+		value = value[1:]
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	}
+	if !strings.HasPrefix(value, "P") {
+		return 0, fmt.Errorf("malformed duration %q", orig)
+	}
+	value = value[1:]
+	var d time.Duration
+	num := ""
+	for _, r := range value {
+		var unit time.Duration
+		switch r {
+		case 'T':
+			continue
+		case 'W':
+			unit = 7 * 24 * time.Hour
+		case 'D':
+			unit = 24 * time.Hour
+		case 'H':
+			unit = time.Hour
+		case 'M':
+			unit = time.Minute
+		case 'S':
+			unit = time.Second
+		default:
+			num += string(r)
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("malformed duration %q", orig)
+		}
+		d += time.Duration(n) * unit
+		num = ""
+	}
+	if num != "" {
+		return 0, fmt.Errorf("malformed duration %q", orig)
+	}
+	return sign * d, nil
+}
+
+// weekday is an entry in BYDAY, e.g. "MO", "1MO", or "-1FR".
+type weekday struct {
+	n   int
+	day time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday,
+	"WE": time.Wednesday, "TH": time.Thursday, "FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// rrule is a recurrence rule. Only the commonly used parts of RFC 5545 are
+// supported: FREQ (DAILY to YEARLY), INTERVAL, COUNT, UNTIL, BYDAY,
+// BYMONTHDAY and BYMONTH.
+type rrule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	byDay      []weekday
+	byMonthDay []int
+	byMonth    []time.Month
+}
+
+func parseRRule(value string, loc *time.Location) (rrule, error) {
+	r := rrule{interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		var err error
+		switch strings.ToUpper(kv[0]) {
+		case "FREQ":
+			r.freq = strings.ToUpper(kv[1])
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(kv[1])
+		case "COUNT":
+			r.count, err = strconv.Atoi(kv[1])
+		case "UNTIL":
+			r.until, _, err = parseTimeIn(kv[1], false, loc)
+			if len(kv[1]) == 8 {
+				// Date-only UNTIL includes the whole day.
+				r.until = r.until.AddDate(0, 0, 1).Add(-time.Second)
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(kv[1], ",") {
+				if len(d) < 2 {
+					return r, fmt.Errorf("malformed BYDAY %q", kv[1])
+				}
+				wd, ok := weekdays[strings.ToUpper(d[len(d)-2:])]
+				if !ok {
+					return r, fmt.Errorf("malformed BYDAY %q", kv[1])
+				}
+				n := 0
+				if len(d) > 2 {
+					if n, err = strconv.Atoi(d[:len(d)-2]); err != nil {
+						return r, err
+					}
+				}
+				r.byDay = append(r.byDay, weekday{n, wd})
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(kv[1], ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil {
+					return r, err
+				}
+				r.byMonthDay = append(r.byMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, d := range strings.Split(kv[1], ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil {
+					return r, err
+				}
+				r.byMonth = append(r.byMonth, time.Month(n))
+			}
+		}
+		if err != nil {
+			return r, err
+		}
+	}
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return r, fmt.Errorf("unsupported FREQ %q", r.freq)
+	}
+	if r.interval < 1 {
+		r.interval = 1
+	}
+	return r, nil
+}
+
+// maxPeriods limits the expansion of rules that never produce an occurrence
+// within the window, e.g. yearly on February 30th.
+const maxPeriods = 10000
+
+// expand returns the start times of all occurrences of the rule starting at
+// dtstart, up to (but excluding) end. Occurrences keep the wall clock time of
+// dtstart in its timezone, so that they are unaffected by DST transitions.
+//
+// Periods before the one containing from are skipped, so some occurrences
+// before from may still be returned. Rules with a COUNT are always expanded
+// from dtstart, since every occurrence counts towards the limit.
+func (r rrule) expand(dtstart, from, end time.Time) []time.Time {
+	var starts []time.Time
+	count := 0
+	first := 0
+	if r.count == 0 {
+		first = r.periodOf(dtstart, from)
+	}
+	for period := first; period < first+maxPeriods; period++ {
+		candidates := r.candidates(dtstart, period)
+		for _, t := range candidates {
+			if t.Before(dtstart) {
+				continue
+			}
+			if !t.Before(end) || (!r.until.IsZero() && t.After(r.until)) {
+				return starts
+			}
+			if r.count > 0 && count >= r.count {
+				return starts
+			}
+			count++
+			starts = append(starts, t)
+		}
+	}
+	return starts
+}
+
+// candidates returns the sorted occurrences within the given period, where
+// period 0 is the day, week, month or year containing dtstart.
+func (r rrule) candidates(dtstart time.Time, period int) []time.Time {
+	y, m, d := dtstart.Date()
+	hh, mm, ss := dtstart.Clock()
+	loc := dtstart.Location()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hh, mm, ss, 0, loc)
+	}
+	var dates []time.Time
+	step := period * r.interval
+	switch r.freq {
+	case "DAILY":
+		t := at(y, m, d+step)
+		if r.matchesDay(t) && r.matchesMonth(t.Month()) {
+			dates = append(dates, t)
+		}
+	case "WEEKLY":
+		// Weeks start on Monday.
+		offset := (int(dtstart.Weekday()) + 6) % 7
+		monday := d - offset + 7*step
+		if len(r.byDay) == 0 {
+			dates = append(dates, at(y, m, monday+offset))
+		}
+		for _, wd := range r.byDay {
+			dates = append(dates, at(y, m, monday+(int(wd.day)+6)%7))
+		}
+	case "MONTHLY":
+		first := at(y, m+time.Month(step), 1)
+		if r.matchesMonth(first.Month()) {
+			dates = r.inMonth(first, d)
+		}
+	case "YEARLY":
+		months := r.byMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+		for _, month := range months {
+			dates = append(dates, r.inMonth(at(y+step, month, 1), d)...)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+// periodOf returns the period containing t, or 0 if t is before dtstart.
+func (r rrule) periodOf(dtstart, t time.Time) int {
+	if t.Before(dtstart) {
+		return 0
+	}
+	y, m, _ := dtstart.Date()
+	ty, tm, _ := t.In(dtstart.Location()).Date()
+	var n int
+	switch r.freq {
+	case "DAILY":
+		n = daysBetween(dtstart, t)
+	case "WEEKLY":
+		offset := (int(dtstart.Weekday()) + 6) % 7
+		n = (daysBetween(dtstart, t) + offset) / 7
+	case "MONTHLY":
+		n = (ty-y)*12 + int(tm-m)
+	case "YEARLY":
+		n = ty - y
+	}
+	return n / r.interval
+}
+
+// daysBetween returns the number of calendar days from a to b, in the
+// timezone of a.
+func daysBetween(a, b time.Time) int {
+	date := func(t time.Time) time.Time {
+		y, m, d := t.In(a.Location()).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	return int(date(b).Sub(date(a)) / (24 * time.Hour))
+}
+
+// inMonth returns the occurrences within the month starting at first, using
+// day if there are no BYDAY or BYMONTHDAY rules.
+func (r rrule) inMonth(first time.Time, day int) []time.Time {
+	var dates []time.Time
+	days := daysIn(first)
+	add := func(d int) {
+		if d >= 1 && d <= days {
+			dates = append(dates, first.AddDate(0, 0, d-1))
+		}
+	}
+	switch {
+	case len(r.byMonthDay) > 0:
+		for _, d := range r.byMonthDay {
+			if d < 0 {
+				d = days + d + 1
+			}
+			add(d)
+		}
+	case len(r.byDay) > 0:
+		for _, wd := range r.byDay {
+			firstMatch := 1 + (int(wd.day)-int(first.Weekday())+7)%7
+			switch {
+			case wd.n > 0:
+				add(firstMatch + 7*(wd.n-1))
+			case wd.n < 0:
+				lastMatch := firstMatch + 7*((days-firstMatch)/7)
+				add(lastMatch + 7*(wd.n+1))
+			default:
+				for d := firstMatch; d <= days; d += 7 {
+					add(d)
+				}
+			}
+		}
+	default:
+		add(day)
+	}
+	return dates
+}
+
+func (r rrule) matchesDay(t time.Time) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+	for _, wd := range r.byDay {
+		if wd.day == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r rrule) matchesMonth(m time.Month) bool {
+	if len(r.byMonth) == 0 {
+		return true
+	}
+	for _, month := range r.byMonth {
+		if month == m {
+			return true
+		}
+	}
+	return false
+}
+
+func daysIn(first time.Time) int {
+	return first.AddDate(0, 1, -1).Day()
+}
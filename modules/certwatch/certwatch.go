@@ -0,0 +1,186 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package certwatch provides an i3bar module that shows the time remaining
+// until TLS certificates expire, as a reminder to renew them.
+package certwatch // import "barista.run/modules/certwatch"
+
+import (
+	"crypto/tls"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Cert represents the certificate served by a host.
+type Cert struct {
+	// Host is the host:port that was checked.
+	Host string
+	// NotAfter is the expiry time of the leaf certificate.
+	NotAfter time.Time
+	// Err is set if the certificate could not be retrieved.
+	Err error
+}
+
+// Remaining returns the time until the certificate expires. It is negative
+// if the certificate has already expired.
+func (c Cert) Remaining() time.Duration {
+	return c.NotAfter.Sub(timing.Now())
+}
+
+// Days returns the number of whole days until the certificate expires.
+func (c Cert) Days() int {
+	return int(c.Remaining() / (24 * time.Hour))
+}
+
+// Info represents the certificates of all watched hosts.
+type Info struct {
+	// Certs contains the certificates for all hosts, sorted by expiry time.
+	// Hosts that could not be checked are first.
+	Certs     []Cert
+	threshold time.Duration
+}
+
+// Soonest returns the certificate that will expire first, or the first host
+// that could not be checked.
+func (i Info) Soonest() Cert {
+	if len(i.Certs) == 0 {
+		return Cert{}
+	}
+	return i.Certs[0]
+}
+
+// Urgent returns true if any certificate expires within the threshold, or
+// could not be checked.
+func (i Info) Urgent() bool {
+	c := i.Soonest()
+	return c.Err != nil || (c.Host != "" && c.Remaining() < i.threshold)
+}
+
+// Module represents a bar.Module that displays certificate expiry.
+type Module struct {
+	hosts      []string
+	threshold  value.Value // of time.Duration
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a certwatch module for the given hosts. Hosts may include a
+// port, e.g. "mail.example.com:993", otherwise port 443 is used.
+func New(hosts ...string) *Module {
+	m := &Module{hosts: hosts, scheduler: timing.NewScheduler()}
+	l.Label(m, strings.Join(hosts, ","))
+	l.Register(m, "threshold", "scheduler", "outputFunc")
+	m.Threshold(14 * 24 * time.Hour)
+	m.RefreshInterval(24 * time.Hour)
+	m.Output(func(i Info) bar.Output {
+		c := i.Soonest()
+		if c.Err != nil {
+			return outputs.Textf("%s: %v", c.Host, c.Err).Urgent(true)
+		}
+		return outputs.Textf("%s %dd", hostname(c.Host), c.Days()).Urgent(i.Urgent())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Threshold sets how long before expiry the module becomes urgent.
+// The default is 14 days.
+func (m *Module) Threshold(threshold time.Duration) *Module {
+	m.threshold.Set(threshold)
+	return m
+}
+
+// RefreshInterval configures the polling frequency. The default is daily.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	certs := m.check()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextThreshold, done := m.threshold.Subscribe()
+	defer done()
+	for {
+		s.Output(outputFunc(Info{certs, m.threshold.Get().(time.Duration)}))
+		select {
+		case <-m.scheduler.C:
+			certs = m.check()
+		case <-nextThreshold:
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) check() []Cert {
+	certs := make([]Cert, len(m.hosts))
+	for idx, host := range m.hosts {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "443")
+		}
+		certs[idx] = Cert{Host: host}
+		certs[idx].NotAfter, certs[idx].Err = getExpiry(host)
+	}
+	sort.SliceStable(certs, func(a, b int) bool {
+		if (certs[a].Err != nil) != (certs[b].Err != nil) {
+			return certs[a].Err != nil
+		}
+		return certs[a].NotAfter.Before(certs[b].NotAfter)
+	})
+	return certs
+}
+
+func hostname(hostport string) string {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil || port != "443" {
+		return hostport
+	}
+	return host
+}
+
+// tlsConfig is used when connecting to hosts.
+var tlsConfig = &tls.Config{
+	// Verification is skipped so that the expiry of expired (and otherwise
+	// invalid) certificates can still be shown.
+	InsecureSkipVerify: true,
+}
+
+func getExpiry(host string) (time.Time, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conf := tlsConfig.Clone()
+	conf.ServerName, _, _ = net.SplitHostPort(host)
+	conn, err := tls.DialWithDialer(dialer, "tcp", host, conf)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].NotAfter, nil
+}
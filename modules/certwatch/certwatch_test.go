@@ -0,0 +1,86 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certwatch
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertwatch(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	host := srv.Listener.Addr().String()
+
+	testBar.New(t)
+	days := int(srv.Certificate().NotAfter.Sub(timing.Now()) / (24 * time.Hour))
+	cw := New(host)
+	testBar.Run(cw)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{fmt.Sprintf("%s %dd", host, days)})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+
+	cw.Threshold(time.Duration(days+1) * 24 * time.Hour)
+	out = testBar.NextOutput("on threshold change")
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.True(t, urgent, "urgent when expiring within threshold")
+
+	cw.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d certs", len(i.Certs))
+	})
+	testBar.NextOutput("on output change").AssertText([]string{"1 certs"})
+
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"1 certs"})
+}
+
+func TestMultipleHosts(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := lis.Addr().String()
+	lis.Close()
+
+	testBar.New(t)
+	cw := New(srv.Listener.Addr().String(), closed)
+	testBar.Run(cw)
+	out := testBar.NextOutput("on start")
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent, "urgent when a host cannot be checked")
+
+	cw.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s %v", i.Soonest().Host, i.Soonest().Err != nil)
+	})
+	testBar.NextOutput("on output change").
+		AssertText([]string{closed + " true"}, "errors sorted first")
+
+	require.Equal(t, "example.com", hostname("example.com:443"))
+	require.Equal(t, "example.com:8443", hostname("example.com:8443"))
+	require.Equal(t, Cert{}, Info{}.Soonest())
+	require.False(t, Info{}.Urgent())
+}
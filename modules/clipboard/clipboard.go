@@ -0,0 +1,225 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clipboard provides an i3bar module that shows a preview of the
+// text in the clipboard, on X11 or wayland. By default, middle click clears
+// the clipboard.
+package clipboard // import "barista.run/modules/clipboard"
+
+import (
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// Info represents the clipboard contents.
+type Info struct {
+	// Text is the text in the clipboard, or empty if the clipboard is empty
+	// or does not contain text.
+	Text string
+	// History contains previous clipboard text, most recent first, if
+	// enabled using History.
+	History    []string
+	controller controller
+}
+
+// Empty returns true if the clipboard does not contain any text.
+func (i Info) Empty() bool {
+	return i.Text == ""
+}
+
+// Preview returns the clipboard text on a single line, truncated to at most
+// the given number of characters.
+func (i Info) Preview(maxLen int) string {
+	return Preview(i.Text, maxLen)
+}
+
+// Clear clears the clipboard.
+func (i Info) Clear() {
+	if i.controller == nil {
+		return
+	}
+	if err := i.controller.clear(); err != nil {
+		l.Log("Error clearing clipboard: %v", err)
+	}
+}
+
+// Preview returns the given text on a single line, with whitespace
+// collapsed, truncated to at most maxLen characters. It can be used to
+// display entries from the history.
+func Preview(text string, maxLen int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if maxLen <= 0 || utf8.RuneCountInString(text) <= maxLen {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:maxLen-1]) + "…"
+}
+
+// controller modifies the clipboard.
+type controller interface {
+	clear() error
+}
+
+// connection provides clipboard contents from a display server.
+type connection interface {
+	controller
+	// next blocks until the clipboard changes, and returns the new text.
+	// The first call returns the current text.
+	next() (string, error)
+	close()
+}
+
+// Module represents a bar.Module that displays the clipboard contents.
+type Module struct {
+	connect    func() (connection, error)
+	history    value.Value // of int
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+func newModule(name string, connect func() (connection, error)) *Module {
+	m := &Module{connect: connect}
+	l.Label(m, name)
+	l.Register(m, "history", "outputFunc")
+	m.history.Set(0)
+	m.Output(func(i Info) bar.Output {
+		if i.Empty() {
+			return nil
+		}
+		return outputs.Text(i.Preview(20))
+	})
+	return m
+}
+
+// New constructs a clipboard module for the current session, using wayland
+// if available, or X11 otherwise.
+func New() *Module {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return Wayland()
+	}
+	return X11()
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// History configures the number of previous clipboard entries to keep in
+// Info.History. Entries are only kept in memory, while the bar is running.
+func (m *Module) History(size int) *Module {
+	if size < 0 {
+		size = 0
+	}
+	m.history.Set(size)
+	return m
+}
+
+// defaultClickHandler clears the clipboard on middle click.
+func defaultClickHandler(i Info) func(bar.Event) {
+	return func(e bar.Event) {
+		if e.Button == bar.ButtonMiddle {
+			i.Clear()
+		}
+	}
+}
+
+// addHistory adds text to the front of the history, removing any previous
+// occurrence, and keeping at most size entries.
+func addHistory(history []string, text string, size int) []string {
+	if text == "" || size <= 0 {
+		return history
+	}
+	updated := []string{text}
+	for _, h := range history {
+		if h != text && len(updated) < size {
+			updated = append(updated, h)
+		}
+	}
+	return updated
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	conn, err := m.connect()
+	if s.Error(err) {
+		return
+	}
+	defer conn.close()
+
+	// Every change is needed for the history, so use channels rather than a
+	// value, which would only provide the latest text.
+	texts := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			t, err := conn.next()
+			if err != nil {
+				errs <- err
+				return
+			}
+			texts <- t
+		}
+	}()
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	size := m.history.Get().(int)
+	nextHistory, done := m.history.Subscribe()
+	defer done()
+
+	var history []string
+	var current string
+	select {
+	case current = <-texts:
+	case err := <-errs:
+		s.Error(err)
+		return
+	}
+	for {
+		info := Info{Text: current, controller: conn}
+		for _, h := range history {
+			if h != current {
+				info.History = append(info.History, h)
+			}
+		}
+		s.Output(outputs.Group(outputFunc(info)).OnClick(defaultClickHandler(info)))
+		select {
+		case t := <-texts:
+			if t != current {
+				// The history holds previous entries, so add the text that
+				// was just replaced.
+				history = addHistory(history, current, size)
+				current = t
+			}
+		case err := <-errs:
+			s.Error(err)
+			return
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextHistory:
+			size = m.history.Get().(int)
+			if len(history) > size {
+				history = history[:size]
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConn struct {
+	texts   chan string
+	errs    chan error
+	cleared chan bool
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		texts:   make(chan string, 10),
+		errs:    make(chan error, 1),
+		cleared: make(chan bool, 10),
+	}
+}
+
+func (f *fakeConn) next() (string, error) {
+	select {
+	case t := <-f.texts:
+		return t, nil
+	case err := <-f.errs:
+		return "", err
+	}
+}
+
+func (f *fakeConn) clear() error {
+	f.cleared <- true
+	return nil
+}
+
+func (f *fakeConn) close() {}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	conn := newFakeConn()
+	m := newModule("test", func() (connection, error) { return conn, nil })
+	conn.texts <- "some\n  text copied from   a terminal"
+	testBar.Run(m)
+	out := testBar.NextOutput("initial")
+	out.AssertText([]string{"some text copied fr…"})
+
+	out.At(0).LeftClick()
+	require.Empty(t, conn.cleared, "only middle click clears")
+	out.At(0).Click(bar.Event{Button: bar.ButtonMiddle})
+	<-conn.cleared
+
+	conn.texts <- ""
+	testBar.NextOutput("on clear").AssertEmpty()
+
+	m.History(3).Output(func(i Info) bar.Output {
+		return outputs.Textf("%s|%s", i.Text, strings.Join(i.History, ","))
+	})
+	testBar.LatestOutput().AssertText([]string{"|"})
+
+	for _, text := range []string{"a", "b", "c", "a", "d", "e"} {
+		conn.texts <- text
+		testBar.NextOutput("on copy")
+	}
+	testBar.LatestOutput().AssertText([]string{"e|d,a,c"})
+
+	m.History(1)
+	testBar.NextOutput("on history change").AssertText([]string{"e|d"})
+	m.History(-1)
+	testBar.NextOutput("on history change").AssertText([]string{"e|"})
+
+	conn.errs <- errors.New("something went wrong")
+	testBar.NextOutput("on error").At(0).AssertError()
+
+	testBar.New(t)
+	m = newModule("test", func() (connection, error) {
+		return nil, errors.New("no display")
+	})
+	testBar.Run(m)
+	testBar.NextOutput("connect error").At(0).AssertError()
+
+	Info{}.Clear() // Should not panic without a controller.
+}
+
+func TestPreview(t *testing.T) {
+	for _, tc := range []struct {
+		text     string
+		maxLen   int
+		expected string
+	}{
+		{"", 10, ""},
+		{"short", 10, "short"},
+		{"exactly 10", 10, "exactly 10"},
+		{"more than 10", 10, "more than…"},
+		{"  multi\nline\ttext  ", 0, "multi line text"},
+		{"ünïcödé téxt", 6, "ünïcö…"},
+	} {
+		require.Equal(t, tc.expected, Preview(tc.text, tc.maxLen),
+			"Preview(%q, %d)", tc.text, tc.maxLen)
+	}
+}
+
+func writeScript(t *testing.T, path, script string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755))
+}
+
+func TestWayland(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clipboard")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	changes := filepath.Join(dir, "changes")
+	clipboard := filepath.Join(dir, "clipboard")
+	require.NoError(t, syscall.Mkfifo(changes, 0600))
+
+	wlPaste = filepath.Join(dir, "wl-paste")
+	writeScript(t, wlPaste, fmt.Sprintf(`
+if [ "$1" = "--watch" ]; then exec cat %[1]q; fi
+[ "$*" = "--no-newline --type text" ] && exec cat %[2]q
+exit 2
+`, changes, clipboard))
+	wlCopy = filepath.Join(dir, "wl-copy")
+	writeScript(t, wlCopy, fmt.Sprintf(`[ "$1" = "--clear" ] && rm %q`, clipboard))
+	require.NoError(t, ioutil.WriteFile(clipboard, []byte("hello"), 0644))
+
+	testBar.New(t)
+	oldDisplay := os.Getenv("WAYLAND_DISPLAY")
+	defer os.Setenv("WAYLAND_DISPLAY", oldDisplay)
+	os.Setenv("WAYLAND_DISPLAY", "wayland-0")
+	testBar.Run(New())
+	out := testBar.NextOutput("initial")
+	out.AssertText([]string{"hello"})
+
+	fifo, err := os.OpenFile(changes, os.O_WRONLY, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(clipboard, []byte("world"), 0644))
+	fmt.Fprintln(fifo)
+	out = testBar.NextOutput("on change")
+	out.AssertText([]string{"world"})
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonMiddle})
+	fmt.Fprintln(fifo)
+	testBar.NextOutput("on clear").AssertEmpty()
+	_, err = os.Stat(clipboard)
+	require.True(t, os.IsNotExist(err), "clipboard cleared")
+
+	fifo.Close()
+	testBar.NextOutput("on exit").At(0).AssertError()
+
+	testBar.New(t)
+	os.Setenv("WAYLAND_DISPLAY", "")
+	oldX11 := os.Getenv("DISPLAY")
+	defer os.Setenv("DISPLAY", oldX11)
+	os.Setenv("DISPLAY", "")
+	testBar.Run(New())
+	testBar.NextOutput("no X11 display").At(0).AssertError()
+
+	testBar.New(t)
+	wlPaste = filepath.Join(dir, "not-installed")
+	testBar.Run(Wayland())
+	testBar.NextOutput("no wl-paste").At(0).AssertError()
+}
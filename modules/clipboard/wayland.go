@@ -0,0 +1,82 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clipboard
+
+import (
+	"bufio"
+	"io"
+	"os/exec"
+)
+
+// Wayland constructs a clipboard module that uses wl-paste and wl-copy from
+// wl-clipboard to watch the clipboard.
+func Wayland() *Module {
+	return newModule("wayland", connectWayland)
+}
+
+// Overridden in tests.
+var wlPaste = "wl-paste"
+var wlCopy = "wl-copy"
+
+type waylandConn struct {
+	watch   *exec.Cmd
+	changes *bufio.Reader
+	started bool
+}
+
+func connectWayland() (connection, error) {
+	// wl-paste runs the given command whenever the clipboard changes. The
+	// command's output is not the clipboard text, but it is used to detect
+	// the change, and the text is read separately.
+	watch := exec.Command(wlPaste, "--watch", "echo")
+	out, err := watch.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := watch.Start(); err != nil {
+		return nil, err
+	}
+	return &waylandConn{watch: watch, changes: bufio.NewReader(out)}, nil
+}
+
+func (c *waylandConn) next() (string, error) {
+	if c.started {
+		if _, err := c.changes.ReadString('\n'); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+	}
+	c.started = true
+	out, err := exec.Command(wlPaste, "--no-newline", "--type", "text").Output()
+	if err != nil {
+		// wl-paste fails if the clipboard is empty, or has no text.
+		return "", nil
+	}
+	if len(out) > maxLength {
+		out = out[:maxLength]
+	}
+	return string(out), nil
+}
+
+func (c *waylandConn) clear() error {
+	return exec.Command(wlCopy, "--clear").Run()
+}
+
+func (c *waylandConn) close() {
+	c.watch.Process.Kill()
+	c.watch.Wait()
+}
@@ -0,0 +1,159 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clipboard
+
+import (
+	"errors"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xfixes"
+	"github.com/jezek/xgb/xproto"
+)
+
+// X11 constructs a clipboard module that watches the CLIPBOARD selection
+// of the X server given by $DISPLAY. Changes are detected using the XFIXES
+// extension.
+func X11() *Module {
+	return newModule("x11", connectX11)
+}
+
+// maxLength is the maximum length of clipboard text that is read. Longer text
+// is truncated, since only a preview is needed.
+const maxLength = 64 * 1024
+
+type x11Conn struct {
+	conn      *xgb.Conn
+	window    xproto.Window
+	clipboard xproto.Atom
+	utf8      xproto.Atom
+	property  xproto.Atom
+	incr      xproto.Atom
+	started   bool
+}
+
+func connectX11() (connection, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, err
+	}
+	c := &x11Conn{conn: conn}
+	if err := c.init(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *x11Conn) atom(name string) (xproto.Atom, error) {
+	r, err := xproto.InternAtom(c.conn, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		return 0, err
+	}
+	return r.Atom, nil
+}
+
+func (c *x11Conn) init() error {
+	if err := xfixes.Init(c.conn); err != nil {
+		return err
+	}
+	// The version must be negotiated before using any xfixes requests.
+	if _, err := xfixes.QueryVersion(c.conn, 5, 0).Reply(); err != nil {
+		return err
+	}
+	var err error
+	for name, atom := range map[string]*xproto.Atom{
+		"CLIPBOARD":         &c.clipboard,
+		"UTF8_STRING":       &c.utf8,
+		"BARISTA_CLIPBOARD": &c.property,
+		"INCR":              &c.incr,
+	} {
+		if *atom, err = c.atom(name); err != nil {
+			return err
+		}
+	}
+	// Selections are converted into a property on a window, so create an
+	// invisible window to receive them.
+	if c.window, err = xproto.NewWindowId(c.conn); err != nil {
+		return err
+	}
+	root := xproto.Setup(c.conn).DefaultScreen(c.conn).Root
+	err = xproto.CreateWindowChecked(c.conn, 0, c.window, root, 0, 0, 1, 1, 0,
+		xproto.WindowClassInputOnly, 0, 0, nil).Check()
+	if err != nil {
+		return err
+	}
+	return xfixes.SelectSelectionInputChecked(c.conn, c.window, c.clipboard,
+		xfixes.SelectionEventMaskSetSelectionOwner|
+			xfixes.SelectionEventMaskSelectionWindowDestroy|
+			xfixes.SelectionEventMaskSelectionClientClose).Check()
+}
+
+// convert asks the selection owner to convert the clipboard to text.
+func (c *x11Conn) convert(time xproto.Timestamp) {
+	xproto.ConvertSelection(c.conn, c.window, c.clipboard, c.utf8, c.property, time)
+}
+
+// read reads the converted clipboard text.
+func (c *x11Conn) read(e xproto.SelectionNotifyEvent) (string, error) {
+	if e.Property == xproto.AtomNone {
+		// The clipboard is empty, or cannot be converted to text.
+		return "", nil
+	}
+	r, err := xproto.GetProperty(c.conn, true, c.window, e.Property,
+		xproto.GetPropertyTypeAny, 0, maxLength/4).Reply()
+	if err != nil {
+		return "", err
+	}
+	if r.Type == c.incr {
+		// Text too large to be sent at once. Incremental transfers are not
+		// supported, since the preview does not need all of the text.
+		return "", nil
+	}
+	return string(r.Value), nil
+}
+
+func (c *x11Conn) next() (string, error) {
+	if !c.started {
+		c.started = true
+		c.convert(xproto.TimeCurrentTime)
+	}
+	for {
+		ev, xerr := c.conn.WaitForEvent()
+		if xerr != nil {
+			return "", xerr
+		}
+		if ev == nil {
+			return "", errors.New("X connection closed")
+		}
+		switch e := ev.(type) {
+		case xfixes.SelectionNotifyEvent:
+			if e.Owner == 0 {
+				return "", nil
+			}
+			c.convert(e.SelectionTimestamp)
+		case xproto.SelectionNotifyEvent:
+			return c.read(e)
+		}
+	}
+}
+
+func (c *x11Conn) clear() error {
+	return xproto.SetSelectionOwnerChecked(c.conn, 0, c.clipboard,
+		xproto.TimeCurrentTime).Check()
+}
+
+func (c *x11Conn) close() {
+	c.conn.Close()
+}
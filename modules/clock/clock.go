@@ -12,16 +12,17 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package clock displays a clock.
+// Package clock displays a clock, optionally for several timezones at once,
+// and provides sunrise and sunset times for use in outputs.
 package clock // import "barista.run/modules/clock"
 
 import (
-	"strings"
 	"time"
 
 	"barista.run/bar"
 	"barista.run/base/value"
 	"barista.run/base/watchers/localtz"
+	"barista.run/i18n"
 	l "barista.run/logging"
 	"barista.run/outputs"
 	"barista.run/timing"
@@ -36,7 +37,8 @@ type Module struct {
 type config struct {
 	granularity time.Duration
 	outputFunc  func(time.Time) bar.Output
-	timezone    *time.Location
+	// A nil timezone represents the machine's current timezone.
+	timezones []*time.Location
 }
 
 func (m *Module) getConfig() config {
@@ -52,13 +54,47 @@ func Zone(timezone *time.Location) *Module {
 	m := &Module{}
 	l.Register(m, "config")
 	m.config.Set(config{
-		timezone:    timezone,
+		timezones:   []*time.Location{timezone},
 		granularity: time.Minute,
 		outputFunc:  defaultOutput,
 	})
 	return m
 }
 
+func defaultZonesOutput(now time.Time) bar.Output {
+	return outputs.Text(now.Format("15:04 MST"))
+}
+
+// Zones constructs a clock module that displays the time in each of the given
+// timezones, as a separate segment produced by the output function. A nil
+// timezone represents the current machine's timezone. The default output
+// includes the timezone abbreviation, to distinguish the times.
+func Zones(timezones ...*time.Location) *Module {
+	m := Zone(nil)
+	c := m.getConfig()
+	c.timezones = timezones
+	c.outputFunc = defaultZonesOutput
+	m.config.Set(c)
+	return m
+}
+
+// ZonesByName constructs a clock module for the given zone names (e.g.
+// "America/Los_Angeles", "Local"), and returns any errors.
+func ZonesByName(names ...string) (*Module, error) {
+	var timezones []*time.Location
+	for _, name := range names {
+		tz, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, err
+		}
+		if name == "Local" {
+			tz = nil
+		}
+		timezones = append(timezones, tz)
+	}
+	return Zones(timezones...), nil
+}
+
 // Local constructs a clock module for the current machine's timezone.
 func Local() *Module {
 	return Zone(nil)
@@ -96,29 +132,27 @@ func (m *Module) Output(
 }
 
 // OutputFormat configures a module to display the time in a given format.
+// Weekday and month names are translated to the current language. The module
+// refreshes at the smallest unit shown in the format, e.g. every minute if
+// the format does not include seconds.
 func (m *Module) OutputFormat(format string) *Module {
-	granularity := time.Hour
-	switch {
-	case strings.Contains(format, ".000"), strings.Contains(format, ".999"):
-		granularity = time.Millisecond
-	case strings.Contains(format, ".00"), strings.Contains(format, ".99"):
-		granularity = 10 * time.Millisecond
-	case strings.Contains(format, ".0"), strings.Contains(format, ".9"):
-		granularity = 100 * time.Millisecond
-	case strings.Contains(format, "05"), strings.Contains(format, "_5"):
-		granularity = time.Second
-	case strings.Contains(format, "04"), strings.Contains(format, "_4"):
-		granularity = time.Minute
-	}
+	granularity := timing.LayoutGranularity(format)
 	return m.Output(granularity, func(now time.Time) bar.Output {
-		return outputs.Text(now.Format(format))
+		return outputs.Text(i18n.FormatTime(now, format))
 	})
 }
 
-// Timezone configures the timezone for this clock.
+// Timezone configures the timezone for this clock, replacing any timezones
+// it was constructed with.
 func (m *Module) Timezone(timezone *time.Location) *Module {
+	return m.Timezones(timezone)
+}
+
+// Timezones configures the clock to display the time in each of the given
+// timezones, replacing any timezones it was constructed with.
+func (m *Module) Timezones(timezones ...*time.Location) *Module {
 	c := m.getConfig()
-	c.timezone = timezone
+	c.timezones = timezones
 	m.config.Set(c)
 	return m
 }
@@ -143,15 +177,30 @@ func (m *Module) Stream(s bar.Sink) {
 	for {
 		now := timing.Now()
 
-		if cfg.timezone == nil {
-			if tzChange == nil {
-				tzChange = localtz.Next()
+		usesLocal := false
+		times := make([]time.Time, len(cfg.timezones))
+		for i, tz := range cfg.timezones {
+			if tz == nil {
+				usesLocal = true
+				times[i] = now
+			} else {
+				times[i] = now.In(tz)
 			}
-		} else {
-			now = now.In(cfg.timezone)
+		}
+		if !usesLocal {
 			tzChange = nil
+		} else if tzChange == nil {
+			tzChange = localtz.Next()
+		}
+		if len(times) == 1 {
+			s.Output(cfg.outputFunc(times[0]))
+		} else {
+			out := outputs.Group()
+			for _, t := range times {
+				out.Append(cfg.outputFunc(t))
+			}
+			s.Output(out)
 		}
-		s.Output(cfg.outputFunc(now))
 
 		select {
 		case <-sch.C:
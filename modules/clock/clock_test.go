@@ -20,6 +20,7 @@ import (
 
 	"barista.run/bar"
 	"barista.run/base/watchers/localtz"
+	"barista.run/i18n"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/timing"
@@ -46,6 +47,17 @@ func TestSimpleTicking(t *testing.T) {
 		[]string{"00:02"}, "on next tick")
 }
 
+func TestLocalizedFormat(t *testing.T) {
+	testBar.New(t)
+	timing.AdvanceTo(fixedTime)
+	i18n.SetLanguage("de_DE")
+	defer i18n.SetLanguage("")
+
+	testBar.Run(Zone(time.UTC).OutputFormat("Monday, 2. January"))
+	testBar.NextOutput().AssertText(
+		[]string{"Mittwoch, 1. März"}, "on start")
+}
+
 func TestAutoGranularities(t *testing.T) {
 	testBar.New(t)
 	timing.AdvanceTo(fixedTime)
@@ -176,3 +188,63 @@ func TestZones(t *testing.T) {
 	testBar.LatestOutput(1).At(1).AssertText(
 		"05:15:01", "on timezone change")
 }
+
+func TestMultipleZones(t *testing.T) {
+	testBar.New(t)
+	timing.AdvanceTo(
+		time.Date(2017, time.March, 1, 13, 15, 0, 0, time.UTC))
+	localtz.SetForTest(time.UTC)
+
+	_, err := ZonesByName("Europe/Berlin", "Global/Unknown")
+	require.Error(t, err, "when loading unknown zone")
+
+	world, err := ZonesByName("America/Los_Angeles", "Asia/Tokyo", "Local")
+	require.NoError(t, err)
+	testBar.Run(world)
+	testBar.NextOutput().AssertText(
+		[]string{"05:15 PST", "22:15 JST", "13:15 UTC"}, "on start")
+
+	timing.NextTick()
+	testBar.NextOutput().AssertText(
+		[]string{"05:16 PST", "22:16 JST", "13:16 UTC"}, "on tick")
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	localtz.SetForTest(berlin)
+	testBar.NextOutput().AssertText(
+		[]string{"05:16 PST", "22:16 JST", "14:16 CET"},
+		"on local time zone change")
+
+	world.Timezones(time.UTC, berlin)
+	testBar.NextOutput().AssertText(
+		[]string{"13:16 UTC", "14:16 CET"}, "on timezones change")
+
+	world.OutputFormat("Mon 15:04")
+	testBar.NextOutput().AssertText(
+		[]string{"Wed 13:16", "Wed 14:16"}, "on format change")
+}
+
+func TestFormatRealignment(t *testing.T) {
+	testBar.New(t)
+	timing.AdvanceTo(fixedTime)
+
+	local := Zone(time.UTC).OutputFormat("15:04:05")
+	testBar.Run(local)
+	testBar.NextOutput().AssertText([]string{"00:00:00"}, "on start")
+
+	timing.NextTick()
+	testBar.NextOutput().AssertText([]string{"00:00:01"}, "every second")
+	timing.AdvanceBy(500 * time.Millisecond)
+	testBar.AssertNoOutput("within a second")
+
+	local.OutputFormat("15:04")
+	testBar.NextOutput().AssertText([]string{"00:00"}, "on format change")
+	require.Equal(t, fixedTime.Add(time.Minute), timing.NextTick(),
+		"realigned to the next minute")
+	testBar.NextOutput().AssertText([]string{"00:01"}, "on tick")
+
+	local.OutputFormat("2006.01.02 3PM")
+	testBar.NextOutput().AssertText([]string{"2017.03.01 12AM"}, "on format change")
+	require.Equal(t, fixedTime.Add(time.Hour), timing.NextTick(),
+		"realigned to the next hour")
+	testBar.NextOutput().AssertText([]string{"2017.03.01 1AM"}, "on tick")
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// Julian date of the unix epoch.
+	julianUnixEpoch = 2440587.5
+	// Julian date of 2000-01-01 12:00 UTC.
+	julian2000 = 2451545.0
+)
+
+func toJulian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + julianUnixEpoch
+}
+
+func fromJulian(j float64) time.Time {
+	secs := (j - julianUnixEpoch) * 86400
+	return time.Unix(0, int64(secs*float64(time.Second)))
+}
+
+func sin(deg float64) float64 { return math.Sin(deg * math.Pi / 180) }
+func cos(deg float64) float64 { return math.Cos(deg * math.Pi / 180) }
+
+// sunTimes returns the julian date of solar noon on the calendar day of the
+// given time, and the cosine of the hour angle of sunrise and sunset, which
+// is less than -1 during polar day and greater than 1 during polar night.
+func sunTimes(t time.Time, lat, lon float64) (transit, cosHourAngle float64) {
+	y, m, d := t.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	n := math.Ceil(toJulian(midnight) - julian2000 + 0.0008)
+	// Mean solar time, anomaly, and the equation of the center.
+	meanSolar := n - lon/360
+	anomaly := math.Mod(357.5291+0.98560028*meanSolar, 360)
+	center := 1.9148*sin(anomaly) + 0.02*sin(2*anomaly) + 0.0003*sin(3*anomaly)
+	eclipticLon := math.Mod(anomaly+center+180+102.9372, 360)
+	transit = julian2000 + meanSolar + 0.0053*sin(anomaly) - 0.0069*sin(2*eclipticLon)
+	sinDecl := sin(eclipticLon) * sin(23.4397)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	// -0.833 degrees accounts for refraction and the size of the solar disc.
+	cosHourAngle = (sin(-0.833) - sin(lat)*sinDecl) / (cos(lat) * cosDecl)
+	return transit, cosHourAngle
+}
+
+// SunriseSunset returns the times of sunrise and sunset on the calendar day of
+// the given time, for the given latitude and longitude in degrees (north and
+// east are positive). The returned times are in the same location as the given
+// time. It returns false if the sun does not rise or set on that day, i.e.
+// during polar day or night.
+//
+// The calculation is accurate to within a few minutes, which is sufficient
+// for display purposes.
+func SunriseSunset(t time.Time, lat, lon float64) (sunrise, sunset time.Time, ok bool) {
+	transit, cosHourAngle := sunTimes(t, lat, lon)
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hourAngle := math.Acos(cosHourAngle) * 180 / math.Pi
+	loc := t.Location()
+	sunrise = fromJulian(transit - hourAngle/360).In(loc)
+	sunset = fromJulian(transit + hourAngle/360).In(loc)
+	return sunrise, sunset, true
+}
+
+// IsDaytime returns true if the sun is up at the given time, latitude and
+// longitude, including during polar day.
+func IsDaytime(t time.Time, lat, lon float64) bool {
+	sunrise, sunset, ok := SunriseSunset(t, lat, lon)
+	if ok {
+		return !t.Before(sunrise) && t.Before(sunset)
+	}
+	_, cosHourAngle := sunTimes(t, lat, lon)
+	return cosHourAngle < -1
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func requireNear(t *testing.T, expected, actual time.Time, msg string) {
+	diff := actual.Sub(expected)
+	if diff < 0 {
+		diff = -diff
+	}
+	require.True(t, diff < 3*time.Minute, "%s: expected ~%v, got %v", msg, expected, actual)
+}
+
+func TestSunriseSunset(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	rise, set, ok := SunriseSunset(
+		time.Date(2020, time.June, 21, 9, 0, 0, 0, london), 51.5074, -0.1278)
+	require.True(t, ok)
+	requireNear(t, time.Date(2020, time.June, 21, 4, 43, 0, 0, london), rise, "sunrise")
+	requireNear(t, time.Date(2020, time.June, 21, 21, 21, 0, 0, london), set, "sunset")
+	require.Equal(t, london, rise.Location())
+
+	sydney, _ := time.LoadLocation("Australia/Sydney")
+	rise, set, ok = SunriseSunset(
+		time.Date(2020, time.June, 21, 23, 0, 0, 0, sydney), -33.8688, 151.2093)
+	require.True(t, ok)
+	requireNear(t, time.Date(2020, time.June, 21, 7, 0, 0, 0, sydney), rise, "sunrise")
+	requireNear(t, time.Date(2020, time.June, 21, 16, 54, 0, 0, sydney), set, "sunset")
+
+	la, _ := time.LoadLocation("America/Los_Angeles")
+	rise, set, ok = SunriseSunset(
+		time.Date(2020, time.December, 21, 0, 30, 0, 0, la), 34.0522, -118.2437)
+	require.True(t, ok)
+	requireNear(t, time.Date(2020, time.December, 21, 6, 55, 0, 0, la), rise, "sunrise")
+	requireNear(t, time.Date(2020, time.December, 21, 16, 48, 0, 0, la), set, "sunset")
+
+	_, _, ok = SunriseSunset(
+		time.Date(2020, time.June, 21, 12, 0, 0, 0, time.UTC), 69.6492, 18.9553)
+	require.False(t, ok, "during polar day")
+	_, _, ok = SunriseSunset(
+		time.Date(2020, time.December, 21, 12, 0, 0, 0, time.UTC), 69.6492, 18.9553)
+	require.False(t, ok, "during polar night")
+}
+
+func TestIsDaytime(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	require.True(t, IsDaytime(
+		time.Date(2020, time.June, 21, 12, 0, 0, 0, london), 51.5, -0.13))
+	require.False(t, IsDaytime(
+		time.Date(2020, time.June, 21, 23, 0, 0, 0, london), 51.5, -0.13))
+	require.False(t, IsDaytime(
+		time.Date(2020, time.June, 21, 3, 0, 0, 0, london), 51.5, -0.13))
+	require.True(t, IsDaytime(
+		time.Date(2020, time.June, 21, 0, 0, 0, 0, time.UTC), 69.6, 18.9),
+		"during polar day")
+	require.False(t, IsDaytime(
+		time.Date(2020, time.December, 21, 12, 0, 0, 0, time.UTC), 69.6, 18.9),
+		"during polar night")
+}
@@ -0,0 +1,297 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package containers provides an i3bar module that shows the status of
+// Docker or Podman containers. It uses the Docker-compatible API over a unix
+// socket, and updates whenever a container lifecycle event occurs.
+package containers // import "barista.run/modules/containers"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// Container represents a single container known to the container engine.
+type Container struct {
+	ID    string
+	Name  string
+	Image string
+	// State is the container state, e.g. "running", "exited", "paused".
+	State string
+	// Status is a human-readable status, e.g. "Up 2 hours (healthy)".
+	Status string
+}
+
+// Running returns true if the container is running.
+func (c Container) Running() bool {
+	return c.State == "running"
+}
+
+// Exited returns true if the container has stopped.
+func (c Container) Exited() bool {
+	return c.State == "exited" || c.State == "dead"
+}
+
+// Unhealthy returns true if the container's health check is failing.
+func (c Container) Unhealthy() bool {
+	return strings.Contains(c.Status, "(unhealthy)")
+}
+
+// Info represents the status of all containers.
+type Info struct {
+	// Containers contains all known containers, sorted by name.
+	Containers []Container
+	// Detailed is true if the user has requested per-container detail.
+	Detailed     bool
+	toggleDetail func()
+}
+
+func (i Info) count(pred func(Container) bool) int {
+	c := 0
+	for _, ctr := range i.Containers {
+		if pred(ctr) {
+			c++
+		}
+	}
+	return c
+}
+
+// Running returns the number of running containers.
+func (i Info) Running() int { return i.count(Container.Running) }
+
+// Exited returns the number of stopped containers.
+func (i Info) Exited() int { return i.count(Container.Exited) }
+
+// Unhealthy returns the number of containers with failing health checks.
+func (i Info) Unhealthy() int { return i.count(Container.Unhealthy) }
+
+// ToggleDetail switches between the summary and per-container detail.
+func (i Info) ToggleDetail() {
+	if i.toggleDetail != nil {
+		i.toggleDetail()
+	}
+}
+
+// Module represents a bar.Module that displays container status.
+type Module struct {
+	socket     string
+	detailed   value.Value // of bool
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// Socket constructs a containers module that connects to a Docker-compatible
+// API at the given unix socket.
+func Socket(path string) *Module {
+	m := &Module{socket: path}
+	l.Label(m, path)
+	l.Register(m, "outputFunc", "detailed")
+	m.detailed.Set(false)
+	m.Output(defaultOutput)
+	return m
+}
+
+// Docker constructs a containers module for the system docker daemon.
+func Docker() *Module {
+	if host := os.Getenv("DOCKER_HOST"); strings.HasPrefix(host, "unix://") {
+		return Socket(strings.TrimPrefix(host, "unix://"))
+	}
+	return Socket("/var/run/docker.sock")
+}
+
+// Podman constructs a containers module for the rootless podman service of
+// the current user. The podman.socket user unit must be enabled.
+func Podman() *Module {
+	return Socket(filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "podman", "podman.sock"))
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+func defaultOutput(i Info) bar.Output {
+	if !i.Detailed {
+		out := outputs.Textf("%d up", i.Running())
+		if u := i.Unhealthy(); u > 0 {
+			out = outputs.Textf("%d up, %d unhealthy", i.Running(), u)
+			out.Urgent(true)
+		}
+		return out
+	}
+	out := outputs.Group()
+	for _, c := range i.Containers {
+		out.Append(outputs.Textf("%s: %s", c.Name, c.Status))
+	}
+	return out
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	cl := newClient(m.socket)
+	events, err := cl.events()
+	if s.Error(err) {
+		return
+	}
+	defer events.Close()
+	changed := make(chan struct{}, 1)
+	streamErr := make(chan error, 1)
+	go func() {
+		dec := json.NewDecoder(events)
+		for {
+			var e struct{}
+			if err := dec.Decode(&e); err != nil {
+				streamErr <- err
+				return
+			}
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	ctrs, err := cl.list()
+	if s.Error(err) {
+		return
+	}
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextDetailed, done := m.detailed.Subscribe()
+	defer done()
+
+	toggle := func() { m.detailed.Set(!m.detailed.Get().(bool)) }
+	for {
+		info := Info{
+			Containers:   ctrs,
+			Detailed:     m.detailed.Get().(bool),
+			toggleDetail: toggle,
+		}
+		s.Output(outputs.Group(outputFunc(info)).OnClick(defaultClickHandler(info)))
+		select {
+		case <-changed:
+			if ctrs, err = cl.list(); s.Error(err) {
+				return
+			}
+		case err := <-streamErr:
+			s.Error(fmt.Errorf("event stream closed: %v", err))
+			return
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextDetailed:
+		}
+	}
+}
+
+// defaultClickHandler toggles per-container detail on left click.
+func defaultClickHandler(i Info) func(bar.Event) {
+	return func(e bar.Event) {
+		if e.Button == bar.ButtonLeft {
+			i.ToggleDetail()
+		}
+	}
+}
+
+type client struct {
+	http *http.Client
+}
+
+// The host is ignored since all requests are sent over the unix socket.
+const apiBase = "http://containers/v1.40"
+
+func newClient(socket string) client {
+	return client{&http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}}
+}
+
+func (c client) get(path string, query url.Values) (*http.Response, error) {
+	r, err := c.http.Get(apiBase + path + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		r.Body.Close()
+		return nil, fmt.Errorf("%s: HTTP %s", path, r.Status)
+	}
+	return r, nil
+}
+
+// events opens a streaming connection for container lifecycle events.
+func (c client) events() (io.ReadCloser, error) {
+	r, err := c.get("/events", url.Values{
+		"filters": {`{"type":["container"]}`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.Body, nil
+}
+
+type apiContainer struct {
+	ID     string `json:"Id"`
+	Names  []string
+	Image  string
+	State  string
+	Status string
+}
+
+func (c client) list() ([]Container, error) {
+	r, err := c.get("/containers/json", url.Values{"all": {"1"}})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	var resp []apiContainer
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	ctrs := make([]Container, len(resp))
+	for i, c := range resp {
+		name := c.ID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		ctrs[i] = Container{
+			ID:     c.ID,
+			Name:   name,
+			Image:  c.Image,
+			State:  c.State,
+			Status: c.Status,
+		}
+	}
+	sort.Slice(ctrs, func(a, b int) bool { return ctrs[a].Name < ctrs[b].Name })
+	return ctrs, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEngine struct {
+	sync.Mutex
+	containers string
+	events     chan string
+}
+
+func (f *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1.40/containers/json":
+		f.Lock()
+		defer f.Unlock()
+		fmt.Fprint(w, f.containers)
+	case "/v1.40/events":
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for e := range f.events {
+			fmt.Fprintln(w, e)
+			w.(http.Flusher).Flush()
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeEngine) setContainers(json string) {
+	f.Lock()
+	defer f.Unlock()
+	f.containers = json
+}
+
+func startEngine(t *testing.T) (*fakeEngine, string, func()) {
+	dir, err := ioutil.TempDir("", "containers")
+	require.NoError(t, err)
+	sock := filepath.Join(dir, "engine.sock")
+	lis, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	f := &fakeEngine{containers: "[]", events: make(chan string)}
+	srv := httptest.NewUnstartedServer(f)
+	srv.Listener = lis
+	srv.Start()
+	return f, sock, func() {
+		close(f.events)
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+const twoContainers = `[
+	{"Id": "abc", "Names": ["/web"], "Image": "nginx",
+	 "State": "running", "Status": "Up 2 hours (healthy)"},
+	{"Id": "def", "Names": ["/db"], "Image": "postgres",
+	 "State": "exited", "Status": "Exited (0) 5 minutes ago"}
+]`
+
+func TestContainers(t *testing.T) {
+	testBar.New(t)
+	f, sock, cleanup := startEngine(t)
+	defer cleanup()
+	f.setContainers(twoContainers)
+
+	m := Socket(sock)
+	testBar.Run(m)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"1 up"})
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{
+		"db: Exited (0) 5 minutes ago",
+		"web: Up 2 hours (healthy)",
+	})
+	out.At(1).LeftClick()
+	testBar.NextOutput("on second click").AssertText([]string{"1 up"})
+
+	f.setContainers(`[
+		{"Id": "abc", "Names": ["/web"], "State": "running",
+		 "Status": "Up 2 hours (unhealthy)"},
+		{"Id": "def", "Names": ["/db"], "State": "running", "Status": "Up 1 second"}
+	]`)
+	f.events <- `{"Type": "container", "Action": "start", "id": "def"}`
+	out = testBar.NextOutput("on event")
+	out.AssertText([]string{"2 up, 1 unhealthy"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent, "urgent when a container is unhealthy")
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d/%d/%d", i.Running(), i.Unhealthy(), i.Exited())
+	})
+	testBar.NextOutput("on output func change").AssertText([]string{"2/1/0"})
+}
+
+func TestErrors(t *testing.T) {
+	testBar.New(t)
+	f, sock, cleanup := startEngine(t)
+	defer cleanup()
+	f.setContainers(`not json`)
+
+	testBar.Run(Socket("/non/existent.sock"), Socket(sock))
+	out := testBar.LatestOutput(0, 1)
+	out.At(0).AssertError("on missing socket")
+	out.At(1).AssertError("on invalid json")
+}
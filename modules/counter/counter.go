@@ -22,14 +22,16 @@ import (
 	"barista.run/base/value"
 	l "barista.run/logging"
 	"barista.run/outputs"
+	"barista.run/state"
 )
 
 // Module represents a "counter" module that displays a count
 // in the given format, and adjusts the count on click/scroll.
 // This module exemplifies the event-based architecture of barista.
 type Module struct {
-	count  value.Value // of int
-	format value.Value // of string
+	count   value.Value // of int
+	format  value.Value // of string
+	persist value.Value // of string
 }
 
 // New constructs a new counter module.
@@ -38,6 +40,7 @@ func New(format string) *Module {
 	l.Register(m, "format", "count")
 	m.count.Set(0)
 	m.format.Set(format)
+	m.persist.Set("")
 	return m
 }
 
@@ -68,6 +71,19 @@ func (m *Module) Format(format string) *Module {
 	return m
 }
 
+// Persist saves the count under the given name, which should be unique to
+// each persisted counter, and restores the saved count if there is one.
+func (m *Module) Persist(name string) *Module {
+	var count int
+	if ok, err := state.Open("counter").Get(name, &count); err != nil {
+		l.Log("%s: failed to load count: %v", l.ID(m), err)
+	} else if ok {
+		m.count.Set(count)
+	}
+	m.persist.Set(name)
+	return m
+}
+
 // Click handles clicks on the module output.
 func (m *Module) click(e bar.Event) {
 	current := m.count.Get().(int)
@@ -78,4 +94,7 @@ func (m *Module) click(e bar.Event) {
 		current++
 	}
 	m.count.Set(current)
+	if name := m.persist.Get().(string); name != "" {
+		state.Open("counter").SetLater(name, current)
+	}
 }
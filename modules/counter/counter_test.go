@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"barista.run/bar"
+	"barista.run/state"
 	testBar "barista.run/testing/bar"
 )
 
@@ -51,3 +52,21 @@ func TestCounter(t *testing.T) {
 	testBar.NextOutput().AssertText(
 		[]string{"=0="}, "on click after format change")
 }
+
+func TestPersist(t *testing.T) {
+	state.TestMode()
+	testBar.New(t)
+	testBar.Run(New("%d").Persist("clicks"))
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"0"}, "without saved count")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on click")
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.NextOutput("on click").AssertText([]string{"2"})
+
+	testBar.New(t)
+	testBar.Run(New("%d").Persist("clicks"), New("%d").Persist("other"))
+	testBar.LatestOutput().AssertText([]string{"2", "0"},
+		"restored on restart")
+}
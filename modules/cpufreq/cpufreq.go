@@ -0,0 +1,249 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cpufreq provides an i3bar module that shows the current CPU
+// frequency and scaling governor, and the active power profile.
+//
+// Power profiles are read from and switched through power-profiles-daemon,
+// or any other service that implements its D-Bus interface, such as the
+// tlp-pd daemon that ships with TLP 1.6 and later.
+package cpufreq // import "barista.run/modules/cpufreq"
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/martinlindhe/unit"
+	"github.com/spf13/afero"
+)
+
+// Info represents the CPU frequency and power profile information.
+type Info struct {
+	// Frequency is the current frequency, averaged across all CPUs.
+	Frequency unit.Frequency
+	// Min and Max are the hardware limits of the CPU frequency.
+	Min, Max unit.Frequency
+	// Governor is the cpufreq scaling governor, e.g. "powersave".
+	Governor string
+	// Profile is the active power profile, e.g. "power-saver", "balanced",
+	// or "performance". It is empty if no power profiles daemon is running.
+	Profile string
+	// Profiles lists the available power profiles.
+	Profiles []string
+
+	setProfile func(string)
+}
+
+// Frac returns the current frequency as a fraction of the hardware range.
+func (i Info) Frac() float64 {
+	if i.Max <= i.Min {
+		return 0
+	}
+	return float64(i.Frequency-i.Min) / float64(i.Max-i.Min)
+}
+
+// SetProfile switches to the given power profile.
+func (i Info) SetProfile(profile string) {
+	if i.setProfile != nil && profile != i.Profile {
+		i.setProfile(profile)
+	}
+}
+
+// CycleProfile switches to the next (or with a negative delta, previous)
+// available power profile, wrapping around at either end.
+func (i Info) CycleProfile(delta int) {
+	count := len(i.Profiles)
+	if count == 0 {
+		return
+	}
+	idx := 0
+	for n, p := range i.Profiles {
+		if p == i.Profile {
+			idx = n
+		}
+	}
+	idx = ((idx+delta)%count + count) % count
+	i.SetProfile(i.Profiles[idx])
+}
+
+// Module represents a CPU frequency bar module.
+type Module struct {
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs an instance of the cpufreq module.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "outputFunc")
+	m.RefreshInterval(3 * time.Second)
+	// Default output, if no function is specified later.
+	m.Output(func(i Info) bar.Output {
+		if i.Profile == "" {
+			return outputs.Textf("%.2f GHz", i.Frequency.Gigahertz())
+		}
+		return outputs.Textf("%.2f GHz %s", i.Frequency.Gigahertz(), i.Profile)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for the CPU frequency.
+// Power profile changes are shown immediately.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+var fs = afero.NewOsFs()
+
+var busType = dbus.System
+
+// profileServices are the D-Bus names under which power-profiles-daemon is
+// available, in order of preference. Older versions only use the last.
+var profileServices = []struct{ service, object, iface string }{
+	{"org.freedesktop.UPower.PowerProfiles",
+		"/org/freedesktop/UPower/PowerProfiles",
+		"org.freedesktop.UPower.PowerProfiles"},
+	{"net.hadess.PowerProfiles",
+		"/net/hadess/PowerProfiles",
+		"net.hadess.PowerProfiles"},
+}
+
+// defaultClickHandler cycles through power profiles on left click and
+// scroll.
+func defaultClickHandler(i Info) func(bar.Event) {
+	return func(e bar.Event) {
+		switch e.Button {
+		case bar.ButtonLeft, bar.ScrollUp:
+			i.CycleProfile(1)
+		case bar.ScrollDown:
+			i.CycleProfile(-1)
+		}
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	var watchers []profileWatcher
+	for _, svc := range profileServices {
+		w := dbus.WatchProperties(busType, svc.service, svc.object, svc.iface).
+			Add("ActiveProfile", "Profiles")
+		defer w.Unsubscribe()
+		watchers = append(watchers, profileWatcher{w, svc.iface})
+	}
+
+	info, err := getInfo(watchers)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if s.Error(err) {
+			return
+		}
+		s.Output(outputs.Group(outputFunc(info)).
+			OnClick(defaultClickHandler(info)))
+		select {
+		case <-m.scheduler.C:
+			info, err = getInfo(watchers)
+		case <-watchers[0].Updates:
+			info, err = getInfo(watchers)
+		case <-watchers[1].Updates:
+			info, err = getInfo(watchers)
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// profileWatcher watches the properties of a power profiles service.
+type profileWatcher struct {
+	*dbus.PropertiesWatcher
+	iface string
+}
+
+func getInfo(watchers []profileWatcher) (Info, error) {
+	i := Info{}
+	files, err := afero.Glob(fs, "/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_cur_freq")
+	if err != nil {
+		return i, err
+	}
+	var total unit.Frequency
+	var count int
+	for _, f := range files {
+		if freq, err := readKHz(f); err == nil {
+			total += freq
+			count++
+		}
+	}
+	if count > 0 {
+		i.Frequency = total / unit.Frequency(count)
+	}
+	const cpu0 = "/sys/devices/system/cpu/cpu0/cpufreq/"
+	i.Min, _ = readKHz(cpu0 + "cpuinfo_min_freq")
+	i.Max, _ = readKHz(cpu0 + "cpuinfo_max_freq")
+	gov, _ := afero.ReadFile(fs, cpu0+"scaling_governor")
+	i.Governor = strings.TrimSpace(string(gov))
+
+	for _, w := range watchers {
+		props := w.Get()
+		profile, ok := props["ActiveProfile"].(string)
+		if !ok {
+			continue
+		}
+		i.Profile = profile
+		profiles, _ := props["Profiles"].([]map[string]godbus.Variant)
+		for _, p := range profiles {
+			if name, ok := p["Profile"].Value().(string); ok {
+				i.Profiles = append(i.Profiles, name)
+			}
+		}
+		w := w
+		i.setProfile = func(profile string) {
+			_, err := w.Call("org.freedesktop.DBus.Properties.Set",
+				w.iface, "ActiveProfile", godbus.MakeVariant(profile))
+			if err != nil {
+				l.Log("Error setting power profile: %v", err)
+			}
+		}
+		break
+	}
+	return i, nil
+}
+
+func readKHz(file string) (unit.Frequency, error) {
+	bytes, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return 0, err
+	}
+	kHz, err := strconv.ParseInt(strings.TrimSpace(string(bytes)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return unit.Frequency(kHz) * unit.Kilohertz, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpufreq
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	fakedbus "barista.run/testing/dbus"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	busType = dbus.Test
+}
+
+func setFreqs(kHz ...int) {
+	for cpu, f := range kHz {
+		afero.WriteFile(fs,
+			fmt.Sprintf("/sys/devices/system/cpu/cpu%d/cpufreq/scaling_cur_freq", cpu),
+			[]byte(fmt.Sprintf("%d\n", f)), 0644)
+	}
+}
+
+func setupFs() {
+	fs = afero.NewMemMapFs()
+	cpu0 := "/sys/devices/system/cpu/cpu0/cpufreq/"
+	afero.WriteFile(fs, cpu0+"cpuinfo_min_freq", []byte("400000\n"), 0644)
+	afero.WriteFile(fs, cpu0+"cpuinfo_max_freq", []byte("4400000\n"), 0644)
+	afero.WriteFile(fs, cpu0+"scaling_governor", []byte("powersave\n"), 0644)
+}
+
+func TestFrequency(t *testing.T) {
+	setupFs()
+	fakedbus.New()
+	testBar.New(t)
+	setFreqs(1200000, 1800000, 2400000, 3000000)
+
+	m := New()
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"2.10 GHz"})
+
+	setFreqs(800000, 800000, 800000, 800000)
+	testBar.AssertNoOutput("until refresh")
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"0.80 GHz"})
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%.0f-%.0f %s %.2f",
+			i.Min.Megahertz(), i.Max.Megahertz(), i.Governor, i.Frac())
+	})
+	testBar.NextOutput().AssertText([]string{"400-4400 powersave 0.10"})
+}
+
+func profiles(names ...string) []map[string]godbus.Variant {
+	r := []map[string]godbus.Variant{}
+	for _, n := range names {
+		r = append(r, map[string]godbus.Variant{
+			"Profile": godbus.MakeVariant(n),
+			"Driver":  godbus.MakeVariant("placeholder"),
+		})
+	}
+	return r
+}
+
+func TestPowerProfiles(t *testing.T) {
+	setupFs()
+	setFreqs(1000000)
+	bus := fakedbus.New()
+	obj := bus.Service("net.hadess.PowerProfiles").
+		Object("/net/hadess/PowerProfiles", "net.hadess.PowerProfiles")
+	obj.SetProperties(map[string]interface{}{
+		"ActiveProfile": "balanced",
+		"Profiles":      profiles("power-saver", "balanced", "performance"),
+	}, dbus.SignalTypeNone)
+	testBar.New(t)
+
+	testBar.Run(New())
+	out := testBar.NextOutput()
+	out.AssertText([]string{"1.00 GHz balanced"})
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"1.00 GHz performance"})
+	val, _ := obj.GetProperty("net.hadess.PowerProfiles.ActiveProfile")
+	require.Equal(t, "performance", val.Value())
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("wraps around")
+	out.AssertText([]string{"1.00 GHz power-saver"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("cycles backwards")
+	out.AssertText([]string{"1.00 GHz performance"})
+
+	obj.SetPropertyForTest("ActiveProfile", "balanced", dbus.SignalTypeChanged)
+	testBar.NextOutput("external change").AssertText([]string{"1.00 GHz balanced"})
+
+	upower := bus.Service("org.freedesktop.UPower.PowerProfiles").
+		Object("/org/freedesktop/UPower/PowerProfiles", "org.freedesktop.UPower.PowerProfiles")
+	upower.SetProperties(map[string]interface{}{
+		"ActiveProfile": "power-saver",
+		"Profiles":      profiles("power-saver", "balanced"),
+	}, dbus.SignalTypeChanged)
+	// The new service is picked up both from its name appearing on the bus
+	// and from the properties changed signal, so wait for both updates.
+	out = testBar.Drain(50*time.Millisecond, "preferred service")
+	out.AssertText([]string{"1.00 GHz power-saver"})
+
+	out.At(0).LeftClick()
+	testBar.NextOutput().AssertText([]string{"1.00 GHz balanced"})
+	val, _ = upower.GetProperty("org.freedesktop.UPower.PowerProfiles.ActiveProfile")
+	require.Equal(t, "balanced", val.Value())
+}
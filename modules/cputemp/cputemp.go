@@ -13,6 +13,8 @@
 // limitations under the License.
 
 // Package cputemp implements an i3bar module that shows the CPU temperature.
+// For other hwmon sensors (fans, voltages, per-core temperatures), see
+// package barista.run/modules/sensors.
 package cputemp // import "barista.run/modules/cputemp"
 
 import (
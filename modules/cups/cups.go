@@ -0,0 +1,395 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cups provides an i3bar module that shows the print queue and any
+// problems of a printer, using IPP to talk to a CUPS server.
+package cups // import "barista.run/modules/cups"
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"os/user"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// PrinterState represents the state of a printer.
+type PrinterState int
+
+// Possible printer states, as defined by IPP.
+const (
+	PrinterIdle       PrinterState = 3
+	PrinterProcessing PrinterState = 4
+	PrinterStopped    PrinterState = 5
+)
+
+// JobState represents the state of a print job. Completed jobs are never
+// included in the queue, so only pending and active states are defined.
+type JobState int
+
+// Possible states of queued jobs, as defined by IPP.
+const (
+	JobPending    JobState = 3
+	JobHeld       JobState = 4
+	JobProcessing JobState = 5
+	JobStopped    JobState = 6
+)
+
+// Job represents a print job in the queue.
+type Job struct {
+	ID    int
+	Name  string
+	User  string
+	State JobState
+
+	printer printer
+}
+
+// Cancel cancels the job.
+func (j Job) Cancel() {
+	if j.printer.host == "" {
+		return
+	}
+	if err := j.printer.cancel(j.ID); err != nil {
+		l.Log("Failed to cancel job %d: %s", j.ID, err)
+	}
+}
+
+// Info represents the state of a printer and its queue.
+type Info struct {
+	// Printer is the name of the printer.
+	Printer string
+	State   PrinterState
+	// Reasons are the IPP printer-state-reasons keywords, e.g.
+	// "media-jam-error" or "toner-low-warning", excluding "none".
+	Reasons []string
+	// Message is a human-readable description of the printer's state,
+	// which may be empty.
+	Message string
+	// Jobs are all jobs in the queue that have not completed.
+	Jobs []Job
+
+	printer printer
+}
+
+// reasonSuffixes are the severity suffixes of printer-state-reasons.
+var reasonSuffixes = []string{"-error", "-warning", "-report"}
+
+// HasReason returns true if the printer reports the given state reason,
+// ignoring the severity. For example, HasReason("media-empty") is true for
+// both "media-empty-warning" and "media-empty-error".
+func (i Info) HasReason(reason string) bool {
+	for _, r := range i.Reasons {
+		if trimSeverity(r) == trimSeverity(reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasError returns true if the printer is stopped or reports an error
+// that prevents printing, e.g. a paper jam or empty toner.
+func (i Info) HasError() bool {
+	return i.State == PrinterStopped || i.problem("-error") != ""
+}
+
+// HasWarning returns true if the printer reports a warning, e.g. low toner.
+func (i Info) HasWarning() bool {
+	return i.problem("-warning") != ""
+}
+
+// Problem returns a short human-readable description of the most severe
+// problem reported by the printer, e.g. "media jam", or an empty string if
+// there are no problems.
+func (i Info) Problem() string {
+	if p := i.problem("-error"); p != "" {
+		return p
+	}
+	if p := i.problem("-warning"); p != "" {
+		return p
+	}
+	if i.State == PrinterStopped {
+		return "stopped"
+	}
+	return ""
+}
+
+func (i Info) problem(severity string) string {
+	for _, r := range i.Reasons {
+		if strings.HasSuffix(r, severity) {
+			return strings.Replace(trimSeverity(r), "-", " ", -1)
+		}
+	}
+	return ""
+}
+
+func trimSeverity(reason string) string {
+	for _, s := range reasonSuffixes {
+		reason = strings.TrimSuffix(reason, s)
+	}
+	return reason
+}
+
+// URL returns the address of the printer's page in the CUPS web interface.
+func (i Info) URL() string {
+	return "http://" + i.printer.host + "/printers/" + url.PathEscape(i.Printer)
+}
+
+// CancelAll cancels all jobs in the queue.
+func (i Info) CancelAll() {
+	for _, j := range i.Jobs {
+		j.Cancel()
+	}
+}
+
+// Module represents a bar.Module that displays the state of a printer.
+type Module struct {
+	printer    printer
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a module for the given printer. A printer on a remote CUPS
+// server can be specified as "printer@hostname[:port]", otherwise the local
+// server is used. An empty printer name uses the default printer.
+func New(name string) *Module {
+	p := printer{host: "localhost:631", name: name}
+	if at := strings.LastIndex(name, "@"); at >= 0 {
+		p.name, p.host = name[:at], name[at+1:]
+	}
+	if _, _, err := net.SplitHostPort(p.host); err != nil {
+		p.host = net.JoinHostPort(p.host, "631")
+	}
+	m := &Module{printer: p, scheduler: timing.NewScheduler()}
+	l.Label(m, name)
+	l.Register(m, "scheduler", "outputFunc")
+	m.RefreshInterval(5 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		problem := i.Problem()
+		if len(i.Jobs) == 0 && problem == "" {
+			return nil
+		}
+		out := "Printer"
+		switch len(i.Jobs) {
+		case 0:
+		case 1:
+			out += " 1 job"
+		default:
+			out += fmt.Sprintf(" %d jobs", len(i.Jobs))
+		}
+		if problem != "" {
+			out += " (" + problem + ")"
+		}
+		return outputs.Text(out).Urgent(i.HasError())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Overridden in tests.
+var openURL = func(url string) error {
+	return exec.Command("xdg-open", url).Run()
+}
+
+// defaultClickHandler opens the CUPS web interface for the printer on left
+// click, and cancels all jobs on middle click.
+func defaultClickHandler(i Info) func(bar.Event) {
+	return func(e bar.Event) {
+		switch e.Button {
+		case bar.ButtonLeft:
+			if err := openURL(i.URL()); err != nil {
+				l.Log("Failed to open %s: %s", i.URL(), err)
+			}
+		case bar.ButtonMiddle:
+			i.CancelAll()
+		}
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.printer.info()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputs.Group(outputFunc(info)).
+				OnClick(defaultClickHandler(info)))
+		}
+		select {
+		case <-m.scheduler.C:
+			info, err = m.printer.info()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// printer is an IPP client for a printer on a CUPS server.
+type printer struct {
+	host string
+	name string
+}
+
+var requestID uint32
+
+// do sends an IPP request to the CUPS server, adding the required operation
+// attributes, and returns the response.
+func (p printer) do(op uint16, attrs ...attribute) (*message, error) {
+	req := &message{
+		code:      op,
+		requestID: atomic.AddUint32(&requestID, 1),
+		groups: []group{{tag: tagOperation, attrs: append([]attribute{
+			{"attributes-charset", tagCharset, []interface{}{"utf-8"}},
+			{"attributes-natural-language", tagNaturalLanguage, []interface{}{"en"}},
+		}, attrs...)}},
+	}
+	resp, err := client.Post("http://"+p.host+"/",
+		"application/ipp", bytes.NewReader(req.encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CUPS: HTTP %s", resp.Status)
+	}
+	msg, err := decodeMessage(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if msg.code >= statusClientError {
+		status := msg.group(tagOperation).string("status-message")
+		if status == "" {
+			status = fmt.Sprintf("status 0x%04x", msg.code)
+		}
+		return msg, fmt.Errorf("CUPS: %s", status)
+	}
+	return msg, nil
+}
+
+// Overridden in tests.
+var client = &http.Client{Timeout: 10 * time.Second}
+
+func (p printer) uri() attribute {
+	return attribute{"printer-uri", tagURI, []interface{}{
+		"ipp://" + p.host + "/printers/" + url.PathEscape(p.name)}}
+}
+
+func (p printer) requestingUser() attribute {
+	name := "barista"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return attribute{"requesting-user-name", tagName, []interface{}{name}}
+}
+
+func requestedAttributes(names ...string) attribute {
+	vals := make([]interface{}, len(names))
+	for i, n := range names {
+		vals[i] = n
+	}
+	return attribute{"requested-attributes", tagKeyword, vals}
+}
+
+// resolve returns the printer with the default printer name filled in, if
+// the printer was created without a name.
+func (p printer) resolve() (printer, error) {
+	if p.name != "" {
+		return p, nil
+	}
+	resp, err := p.do(opCupsGetDefault, requestedAttributes("printer-name"))
+	if err != nil {
+		return p, err
+	}
+	p.name = resp.group(tagPrinter).string("printer-name")
+	if p.name == "" {
+		return p, fmt.Errorf("CUPS: no default printer")
+	}
+	return p, nil
+}
+
+func (p printer) info() (Info, error) {
+	p, err := p.resolve()
+	if err != nil {
+		return Info{}, err
+	}
+	resp, err := p.do(opGetPrinterAttributes, p.uri(), p.requestingUser(),
+		requestedAttributes("printer-state", "printer-state-reasons",
+			"printer-state-message"))
+	if err != nil {
+		return Info{}, err
+	}
+	attrs := resp.group(tagPrinter)
+	info := Info{
+		Printer: p.name,
+		State:   PrinterState(attrs.int("printer-state")),
+		Message: attrs.string("printer-state-message"),
+		printer: p,
+	}
+	for _, r := range attrs.strings("printer-state-reasons") {
+		if r != "none" {
+			info.Reasons = append(info.Reasons, r)
+		}
+	}
+	resp, err = p.do(opGetJobs, p.uri(), p.requestingUser(),
+		attribute{"which-jobs", tagKeyword, []interface{}{"not-completed"}},
+		requestedAttributes("job-id", "job-name", "job-originating-user-name",
+			"job-state"))
+	if err != nil {
+		return Info{}, err
+	}
+	for _, g := range resp.groups {
+		if g.tag != tagJob {
+			continue
+		}
+		info.Jobs = append(info.Jobs, Job{
+			ID:      g.int("job-id"),
+			Name:    g.string("job-name"),
+			User:    g.string("job-originating-user-name"),
+			State:   JobState(g.int("job-state")),
+			printer: p,
+		})
+	}
+	return info, nil
+}
+
+func (p printer) cancel(jobID int) error {
+	_, err := p.do(opCancelJob, p.uri(),
+		attribute{"job-id", tagInteger, []interface{}{jobID}},
+		p.requestingUser())
+	return err
+}
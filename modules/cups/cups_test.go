@@ -0,0 +1,267 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cups
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCUPS is a minimal IPP server that serves a single printer.
+type fakeCUPS struct {
+	*httptest.Server
+	sync.Mutex
+	name     string
+	state    int
+	reasons  []string
+	message  string
+	jobs     []group
+	canceled []int
+}
+
+func newFakeCUPS(name string) *fakeCUPS {
+	f := &fakeCUPS{name: name, state: 3, reasons: []string{"none"}}
+	f.Server = httptest.NewServer(f)
+	return f
+}
+
+func (f *fakeCUPS) host() string {
+	return strings.TrimPrefix(f.URL, "http://")
+}
+
+func (f *fakeCUPS) addJob(id int, name string) {
+	f.Lock()
+	defer f.Unlock()
+	f.jobs = append(f.jobs, group{tagJob, []attribute{
+		{"job-id", tagInteger, []interface{}{id}},
+		{"job-name", tagNameWithLanguage, []interface{}{
+			// Language "en", followed by the name.
+			"\x00\x02en" + string([]byte{0, byte(len(name))}) + name}},
+		{"job-originating-user-name", tagName, []interface{}{"user"}},
+		{"job-state", tagEnum, []interface{}{3}},
+	}})
+}
+
+func (f *fakeCUPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	req, err := decodeMessage(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	op := req.group(tagOperation)
+	resp := &message{requestID: req.requestID}
+	resp.groups = []group{{tagOperation, []attribute{
+		{"attributes-charset", tagCharset, []interface{}{"utf-8"}},
+	}}}
+	uri := "ipp://" + f.host() + "/printers/" + f.name
+	if req.code != opCupsGetDefault && op.string("printer-uri") != uri {
+		resp.code = 0x0406
+		resp.groups[0].attrs = append(resp.groups[0].attrs, attribute{
+			"status-message", tagText, []interface{}{"The printer does not exist."}})
+		w.Write(resp.encode())
+		return
+	}
+	switch req.code {
+	case opCupsGetDefault:
+		resp.groups = append(resp.groups, group{tagPrinter, []attribute{
+			{"printer-name", tagName, []interface{}{f.name}},
+		}})
+	case opGetPrinterAttributes:
+		reasons := []interface{}{}
+		for _, r := range f.reasons {
+			reasons = append(reasons, r)
+		}
+		resp.groups = append(resp.groups, group{tagPrinter, []attribute{
+			{"printer-state", tagEnum, []interface{}{f.state}},
+			{"printer-state-reasons", tagKeyword, reasons},
+			{"printer-state-message", tagText, []interface{}{f.message}},
+		}})
+	case opGetJobs:
+		if op.string("which-jobs") != "not-completed" {
+			resp.code = 0x0400
+		}
+		resp.groups = append(resp.groups, f.jobs...)
+	case opCancelJob:
+		id := op.int("job-id")
+		f.canceled = append(f.canceled, id)
+		for i, j := range f.jobs {
+			if j.int("job-id") == id {
+				f.jobs = append(f.jobs[:i], f.jobs[i+1:]...)
+				break
+			}
+		}
+	default:
+		resp.code = 0x0501
+	}
+	w.Write(resp.encode())
+}
+
+func TestModule(t *testing.T) {
+	cups := newFakeCUPS("Office")
+	defer cups.Close()
+
+	var opened []string
+	openURL = func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}
+
+	testBar.New(t)
+	m := New("@" + cups.host())
+	testBar.Run(m)
+	testBar.NextOutput("initial").AssertEmpty("no jobs, no problems")
+
+	cups.addJob(12, "report.pdf")
+	cups.addJob(13, "Untitled")
+	testBar.Tick()
+	out := testBar.NextOutput("on jobs queued")
+	out.AssertText([]string{"Printer 2 jobs"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+
+	out.At(0).LeftClick()
+	require.Equal(t, []string{"http://" + cups.host() + "/printers/Office"}, opened)
+
+	cups.Lock()
+	cups.state = 5
+	cups.reasons = []string{"media-jam-error", "toner-low-warning"}
+	cups.Unlock()
+	testBar.Tick()
+	out = testBar.NextOutput("on error")
+	out.AssertText([]string{"Printer 2 jobs (media jam)"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonMiddle})
+	cups.Lock()
+	require.Equal(t, []int{12, 13}, cups.canceled)
+	cups.reasons = []string{"toner-low-warning"}
+	cups.state = 3
+	cups.Unlock()
+
+	testBar.Tick()
+	out = testBar.NextOutput("on warning")
+	out.AssertText([]string{"Printer (toner low)"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+
+	m.Output(func(i Info) bar.Output {
+		var jobs []string
+		for _, j := range i.Jobs {
+			jobs = append(jobs, fmt.Sprintf("%d:%s:%s", j.ID, j.Name, j.User))
+		}
+		return outputs.Textf("%s %v %v [%s]", i.Printer, i.HasWarning(),
+			i.HasReason("toner-low"), strings.Join(jobs, ","))
+	})
+	testBar.NextOutput("on output change").AssertText(
+		[]string{"Office true true []"})
+
+	cups.addJob(14, "photo.jpg")
+	testBar.Tick()
+	testBar.NextOutput("on job queued").AssertText(
+		[]string{"Office true true [14:photo.jpg:user]"})
+
+	cups.Close()
+	testBar.Tick()
+	testBar.NextOutput("on server stopped").At(0).AssertError()
+}
+
+func TestErrors(t *testing.T) {
+	cups := newFakeCUPS("Office")
+	defer cups.Close()
+
+	_, err := printer{cups.host(), "Home"}.info()
+	require.EqualError(t, err, "CUPS: The printer does not exist.")
+
+	openURL = func(string) error { return errors.New("no browser") }
+	info, err := printer{cups.host(), "Office"}.info()
+	require.NoError(t, err)
+	defaultClickHandler(info)(bar.Event{Button: bar.ButtonLeft}) // Should not panic.
+	Job{ID: 1}.Cancel()                                          // Should not panic without a printer.
+
+	require.Equal(t, printer{"localhost:631", "Office"}, New("Office").printer)
+	require.Equal(t, printer{"print-server:631", ""}, New("@print-server").printer)
+	require.Equal(t, printer{"[::1]:8631", "a@b"}, New("a@b@[::1]:8631").printer)
+}
+
+func TestInfo(t *testing.T) {
+	for _, tc := range []struct {
+		info       Info
+		hasError   bool
+		hasWarning bool
+		problem    string
+	}{
+		{Info{State: PrinterIdle}, false, false, ""},
+		{Info{State: PrinterProcessing, Reasons: []string{"toner-low-report"}}, false, false, ""},
+		{Info{State: PrinterIdle, Reasons: []string{"media-empty-warning"}}, false, true, "media empty"},
+		{Info{State: PrinterStopped, Reasons: []string{"paused"}}, true, false, "stopped"},
+		{Info{State: PrinterStopped, Reasons: []string{
+			"toner-low-warning", "marker-supply-empty-error"}}, true, true, "marker supply empty"},
+	} {
+		desc := fmt.Sprintf("%+v", tc.info)
+		require.Equal(t, tc.hasError, tc.info.HasError(), desc)
+		require.Equal(t, tc.hasWarning, tc.info.HasWarning(), desc)
+		require.Equal(t, tc.problem, tc.info.Problem(), desc)
+	}
+}
+
+func TestIPPEncoding(t *testing.T) {
+	msg := &message{
+		code:      opGetJobs,
+		requestID: 42,
+		groups: []group{
+			{tagOperation, []attribute{
+				{"attributes-charset", tagCharset, []interface{}{"utf-8"}},
+				{"requested-attributes", tagKeyword, []interface{}{"job-id", "job-name"}},
+				{"my-jobs", tagBoolean, []interface{}{true}},
+				{"limit", tagInteger, []interface{}{-1}},
+			}},
+			{tagJob, []attribute{
+				{"no-value", 0x13, []interface{}{nil}},
+				{"range", 0x33, []interface{}{[]byte{0, 0, 0, 1, 0, 0, 0, 2}}},
+			}},
+		},
+	}
+	encoded := msg.encode()
+	require.Equal(t, []byte{
+		1, 1, 0x00, 0x0A, 0, 0, 0, 42,
+		0x01,
+		0x47, 0, 18, 'a', 't', 't', 'r', 'i', 'b', 'u', 't', 'e', 's', '-',
+		'c', 'h', 'a', 'r', 's', 'e', 't', 0, 5, 'u', 't', 'f', '-', '8',
+	}, encoded[:37])
+
+	decoded, err := decodeMessage(bytes.NewReader(encoded))
+	require.NoError(t, err)
+	require.Equal(t, msg, decoded)
+
+	_, err = decodeMessage(bytes.NewReader(encoded[:len(encoded)-1]))
+	require.Error(t, err, "truncated message")
+	_, err = decodeMessage(bytes.NewReader([]byte{1, 1, 0, 0, 0, 0, 0, 1, 0x21, 0, 0}))
+	require.Error(t, err, "attribute outside of group")
+}
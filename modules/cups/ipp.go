@@ -0,0 +1,274 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cups
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// This file implements the subset of the IPP/1.1 encoding (RFC 8010) needed
+// to query printers and jobs, and to cancel jobs.
+
+const (
+	ippVersionMajor byte = 1
+	ippVersionMinor byte = 1
+	// maxValueLength limits the length of names and values, as IPP does.
+	maxValueLength = 1 << 15
+)
+
+// Operations.
+const (
+	opCancelJob            uint16 = 0x0008
+	opGetJobs              uint16 = 0x000A
+	opGetPrinterAttributes uint16 = 0x000B
+	opCupsGetDefault       uint16 = 0x4001
+)
+
+// statusClientError is the first error status code, all codes below it
+// indicate success.
+const statusClientError uint16 = 0x0400
+
+// Delimiter tags. All tags up to tagDelimiterLast start a new group.
+const (
+	tagOperation     byte = 0x01
+	tagJob           byte = 0x02
+	tagEnd           byte = 0x03
+	tagPrinter       byte = 0x04
+	tagDelimiterLast byte = 0x0F
+)
+
+// Value tags.
+const (
+	tagOutOfBandFirst   byte = 0x10
+	tagOutOfBandLast    byte = 0x1F
+	tagInteger          byte = 0x21
+	tagBoolean          byte = 0x22
+	tagEnum             byte = 0x23
+	tagTextWithLanguage byte = 0x35
+	tagNameWithLanguage byte = 0x36
+	tagStringFirst      byte = 0x40
+	tagText             byte = 0x41
+	tagName             byte = 0x42
+	tagKeyword          byte = 0x44
+	tagURI              byte = 0x45
+	tagCharset          byte = 0x47
+	tagNaturalLanguage  byte = 0x48
+	tagStringLast       byte = 0x5F
+)
+
+// attribute is a single, possibly multi-valued, IPP attribute. Values are
+// int for integers and enums, bool for booleans, string for all textual
+// types, nil for out-of-band values, and []byte for everything else.
+type attribute struct {
+	name   string
+	tag    byte
+	values []interface{}
+}
+
+// group is a group of attributes, e.g. all attributes of one job.
+type group struct {
+	tag   byte
+	attrs []attribute
+}
+
+func (g group) values(name string) []interface{} {
+	for _, a := range g.attrs {
+		if a.name == name {
+			return a.values
+		}
+	}
+	return nil
+}
+
+func (g group) strings(name string) []string {
+	var r []string
+	for _, v := range g.values(name) {
+		if s, ok := v.(string); ok {
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+func (g group) string(name string) string {
+	if s := g.strings(name); len(s) > 0 {
+		return s[0]
+	}
+	return ""
+}
+
+func (g group) int(name string) int {
+	if v := g.values(name); len(v) > 0 {
+		if i, ok := v[0].(int); ok {
+			return i
+		}
+	}
+	return 0
+}
+
+// message is an IPP request or response.
+type message struct {
+	// code is the operation-id for requests, and status-code for responses.
+	code      uint16
+	requestID uint32
+	groups    []group
+}
+
+// group returns the first group of the message with the given tag.
+func (m *message) group(tag byte) group {
+	for _, g := range m.groups {
+		if g.tag == tag {
+			return g
+		}
+	}
+	return group{tag: tag}
+}
+
+func (m *message) encode() []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{ippVersionMajor, ippVersionMinor})
+	binary.Write(&buf, binary.BigEndian, m.code)
+	binary.Write(&buf, binary.BigEndian, m.requestID)
+	for _, g := range m.groups {
+		buf.WriteByte(g.tag)
+		for _, a := range g.attrs {
+			for i, v := range a.values {
+				buf.WriteByte(a.tag)
+				name := a.name
+				if i > 0 {
+					// Additional values of the same attribute have no name.
+					name = ""
+				}
+				writeWithLength(&buf, []byte(name))
+				writeWithLength(&buf, encodeValue(v))
+			}
+		}
+	}
+	buf.WriteByte(tagEnd)
+	return buf.Bytes()
+}
+
+func writeWithLength(buf *bytes.Buffer, data []byte) {
+	binary.Write(buf, binary.BigEndian, uint16(len(data)))
+	buf.Write(data)
+}
+
+func encodeValue(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(int32(v)))
+		return b
+	case bool:
+		if v {
+			return []byte{1}
+		}
+		return []byte{0}
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	}
+	return nil
+}
+
+func decodeMessage(r io.Reader) (*message, error) {
+	rd := bufio.NewReader(r)
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(rd, header); err != nil {
+		return nil, err
+	}
+	m := &message{
+		code:      binary.BigEndian.Uint16(header[2:4]),
+		requestID: binary.BigEndian.Uint32(header[4:8]),
+	}
+	var g *group
+	for {
+		tag, err := rd.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if tag == tagEnd {
+			return m, nil
+		}
+		if tag <= tagDelimiterLast {
+			m.groups = append(m.groups, group{tag: tag})
+			g = &m.groups[len(m.groups)-1]
+			continue
+		}
+		if g == nil {
+			return nil, fmt.Errorf("IPP: attribute outside of group")
+		}
+		name, err := readWithLength(rd)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readWithLength(rd)
+		if err != nil {
+			return nil, err
+		}
+		val := decodeValue(tag, data)
+		if len(name) == 0 && len(g.attrs) > 0 {
+			last := &g.attrs[len(g.attrs)-1]
+			last.values = append(last.values, val)
+			continue
+		}
+		g.attrs = append(g.attrs, attribute{string(name), tag, []interface{}{val}})
+	}
+}
+
+func readWithLength(rd io.Reader) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(rd, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length > maxValueLength {
+		return nil, fmt.Errorf("IPP: value too long (%d bytes)", length)
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(rd, data)
+	return data, err
+}
+
+func decodeValue(tag byte, data []byte) interface{} {
+	switch {
+	case tag >= tagOutOfBandFirst && tag <= tagOutOfBandLast:
+		return nil
+	case tag == tagInteger || tag == tagEnum:
+		if len(data) == 4 {
+			return int(int32(binary.BigEndian.Uint32(data)))
+		}
+	case tag == tagBoolean:
+		if len(data) == 1 {
+			return data[0] != 0
+		}
+	case tag == tagTextWithLanguage || tag == tagNameWithLanguage:
+		// 2 byte length + language, followed by 2 byte length + text.
+		rd := bytes.NewReader(data)
+		if _, err := readWithLength(rd); err != nil {
+			return data
+		}
+		if text, err := readWithLength(rd); err == nil {
+			return string(text)
+		}
+	case tag >= tagStringFirst && tag <= tagStringLast:
+		return string(data)
+	}
+	return data
+}
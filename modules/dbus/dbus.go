@@ -0,0 +1,199 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dbus provides an i3bar module that displays the properties of an
+// arbitrary DBus object, and updates when they change or when the object
+// emits signals.
+package dbus // import "barista.run/modules/dbus"
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	dbusWatcher "barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Signal represents a signal emitted by the object.
+type Signal struct {
+	// Name of the signal, without the interface if it was emitted on the
+	// interface being watched.
+	Name string
+	Body []interface{}
+	// Time when the signal was received.
+	Time time.Time
+}
+
+// Info represents the current state of the DBus object.
+type Info struct {
+	// Connected is true if the service that exposes the object is running.
+	Connected bool
+	// Properties contains the latest values of the watched properties. Values
+	// are extracted from dbus.Variant values.
+	Properties map[string]interface{}
+	// Signals contains the most recent occurrence of each watched signal since
+	// the service started.
+	Signals map[string]Signal
+	// DBus 'call' method of the object.
+	call func(string, ...interface{}) ([]interface{}, error)
+}
+
+// Call calls a method on the object and returns the result. Method names
+// without an interface are called on the interface being watched.
+func (i Info) Call(method string, args ...interface{}) ([]interface{}, error) {
+	return i.call(method, args...)
+}
+
+// Module represents a bar.Module that displays information from a DBus
+// object.
+type Module struct {
+	busType    dbusWatcher.BusType
+	service    string
+	object     string
+	iface      string
+	props      []string
+	signals    []string
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// Overridden in tests.
+var (
+	sessionBus = dbusWatcher.Session
+	systemBus  = dbusWatcher.System
+)
+
+// Session constructs a module that watches an object on the session bus,
+// given the service name, the object path, and the interface.
+func Session(service, object, iface string) *Module {
+	return newModule(sessionBus, service, object, iface)
+}
+
+// System constructs a module that watches an object on the system bus,
+// given the service name, the object path, and the interface.
+func System(service, object, iface string) *Module {
+	return newModule(systemBus, service, object, iface)
+}
+
+func newModule(busType dbusWatcher.BusType, service, object, iface string) *Module {
+	m := &Module{
+		busType: busType,
+		service: service,
+		object:  object,
+		iface:   iface,
+	}
+	l.Label(m, service)
+	l.Register(m, "outputFunc")
+	m.Output(func(i Info) bar.Output {
+		if !i.Connected {
+			return nil
+		}
+		var values []string
+		for _, p := range m.props {
+			if v, ok := i.Properties[p]; ok {
+				values = append(values, fmt.Sprintf("%v", v))
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		return outputs.Text(strings.Join(values, " "))
+	})
+	return m
+}
+
+// Properties adds properties to watch. Property names without an interface
+// are on the interface being watched. The default output displays the values
+// of all watched properties in order. Properties and Signals must be
+// configured before the module is streamed.
+func (m *Module) Properties(props ...string) *Module {
+	m.props = append(m.props, props...)
+	return m
+}
+
+// Signals adds signals to watch, updating the output each time one of them is
+// emitted. Signal names without an interface are on the interface being
+// watched.
+func (m *Module) Signals(names ...string) *Module {
+	m.signals = append(m.signals, names...)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	props := dbusWatcher.WatchProperties(m.busType, m.service, m.object, m.iface).
+		Add(m.props...)
+	defer props.Unsubscribe()
+	signals := dbusWatcher.WatchSignals(m.busType, m.service, m.object)
+	defer signals.Unsubscribe()
+	for _, name := range m.signals {
+		signals.Add(m.expand(name))
+	}
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	info := Info{
+		Connected:  signals.Owner() != "",
+		Properties: props.Get(),
+		Signals:    map[string]Signal{},
+		call:       props.Call,
+	}
+	for {
+		s.Output(outputFunc(info))
+		select {
+		case <-props.Updates:
+			info.Properties = props.Get()
+		case sig := <-signals.Signals:
+			info = m.handleSignal(info, sig, signals.Owner())
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) handleSignal(info Info, sig *dbusWatcher.Signal, owner string) Info {
+	// Info may have been retained by the output function, so the signals
+	// are copied instead of updated in place.
+	signals := map[string]Signal{}
+	if sig.Name == "org.freedesktop.DBus.NameOwnerChanged" {
+		info.Connected = owner != ""
+	} else {
+		for k, v := range info.Signals {
+			signals[k] = v
+		}
+		name := strings.TrimPrefix(sig.Name, m.iface+".")
+		signals[name] = Signal{Name: name, Body: sig.Body, Time: timing.Now()}
+	}
+	info.Signals = signals
+	return info
+}
+
+func (m *Module) expand(name string) string {
+	if strings.ContainsRune(name, '.') {
+		return name
+	}
+	return m.iface + "." + name
+}
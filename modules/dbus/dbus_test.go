@@ -0,0 +1,106 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbus
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	dbusWatcher "barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	sessionBus = dbusWatcher.Test
+	systemBus = dbusWatcher.Test
+}
+
+func TestProperties(t *testing.T) {
+	bus := dbusWatcher.SetupTestBus()
+	srv := bus.RegisterService("org.i3barista.services.Foo")
+	obj := srv.Object("/org/i3barista/Foo", "org.i3barista.Foo")
+	obj.SetPropertyForTest("Name", "foo", dbusWatcher.SignalTypeNone)
+	obj.SetPropertyForTest("Count", int32(3), dbusWatcher.SignalTypeNone)
+
+	testBar.New(t)
+	testBar.Run(Session("org.i3barista.services.Foo", "/org/i3barista/Foo", "org.i3barista.Foo").
+		Properties("Name", "Count", "Missing"))
+	testBar.NextOutput("initial").AssertText([]string{"foo 3"})
+
+	obj.SetProperty("Count", int32(4))
+	testBar.NextOutput("on property change").AssertText([]string{"foo 4"})
+
+	obj.SetPropertyForTest("Missing", "here", dbusWatcher.SignalTypeInvalidated)
+	testBar.NextOutput("on invalidated property").AssertText([]string{"foo 4 here"})
+
+	srv.Unregister()
+	testBar.NextOutput("on disconnect").AssertEmpty()
+}
+
+func TestSignals(t *testing.T) {
+	bus := dbusWatcher.SetupTestBus()
+
+	testBar.New(t)
+	m := System("org.i3barista.services.Foo", "/org/i3barista/Foo", "org.i3barista.Foo").
+		Properties("Name").
+		Signals("Ping", "org.i3barista.Other.Pong").
+		Output(func(i Info) bar.Output {
+			return outputs.Textf("%v %v %v %v", i.Connected,
+				i.Properties["Name"], i.Signals["Ping"].Body, i.Signals["org.i3barista.Other.Pong"].Body)
+		})
+	testBar.Run(m)
+	testBar.NextOutput("initial").AssertText([]string{"false <nil> [] []"})
+
+	srv := bus.RegisterService("org.i3barista.services.Foo")
+	// Both the signal and properties watchers notify when the service starts.
+	testBar.Drain(50*time.Millisecond, "on connect").
+		AssertText([]string{"true <nil> [] []"})
+
+	obj := srv.Object("/org/i3barista/Foo", "org.i3barista.Foo")
+	obj.SetProperty("Name", "foo")
+	testBar.NextOutput("on property change").AssertText([]string{"true foo [] []"})
+
+	obj.Emit("Ping", 1, "a")
+	testBar.NextOutput("on signal").AssertText([]string{"true foo [1 a] []"})
+
+	srv.Object("/org/i3barista/Foo", "org.i3barista.Other").Emit("Pong", 2)
+	testBar.NextOutput("on signal").AssertText([]string{"true foo [1 a] [2]"})
+
+	obj.Emit("Pong", 3)
+	srv.Object("/org/i3barista/Bar", "org.i3barista.Foo").Emit("Ping", 4)
+	testBar.AssertNoOutput("on unwatched signals")
+
+	var pings []string
+	obj.On("Frob", func(args ...interface{}) ([]interface{}, error) {
+		pings = append(pings, fmt.Sprintf("%v", args))
+		return []interface{}{"ok"}, nil
+	})
+	m.Output(func(i Info) bar.Output {
+		return outputs.Text("frob").OnClick(func(bar.Event) {
+			r, err := i.Call("Frob", 5)
+			require.NoError(t, err)
+			require.Equal(t, []interface{}{"ok"}, r)
+		})
+	})
+	out := testBar.NextOutput("on output change")
+	out.AssertText([]string{"frob"})
+	out.At(0).LeftClick()
+	require.Equal(t, []string{"[5]"}, pings)
+}
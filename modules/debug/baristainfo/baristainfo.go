@@ -0,0 +1,202 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package baristainfo provides an i3bar module that shows the resource usage
+// of the bar itself, such as goroutines, heap usage, module update rates, and
+// render latency, and can serve pprof profiles on a local socket.
+package baristainfo // import "barista.run/modules/debug/baristainfo"
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"sort"
+	"time"
+
+	"barista.run"
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/format"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+	"golang.org/x/sys/unix"
+)
+
+// Info contains the resource usage of the bar. Rates and averages are
+// computed over the time since the previous update.
+type Info struct {
+	// Goroutines is the number of goroutines that currently exist.
+	Goroutines int
+	// Heap is the size of allocated heap objects.
+	Heap unit.Datasize
+	// CPUUsage is the cpu time used by the bar per unit of wall time, where
+	// 1.0 is a single core fully utilised.
+	CPUUsage float64
+	// Renders is the number of times the bar was printed.
+	Renders int
+	// RenderLatency is the average time taken to print the bar.
+	RenderLatency time.Duration
+	// Modules contains the update statistics of each module, in the order
+	// they were added to the bar.
+	Modules []ModuleInfo
+}
+
+// ModuleInfo contains the update statistics of a single module.
+type ModuleInfo struct {
+	// Name is the name given to barista.AddNamed, or the type of the module.
+	Name string
+	// Updates and Errors are the total number of updates and error updates.
+	Updates, Errors int
+	// UpdateRate is the rate at which the module updates its output.
+	UpdateRate unit.Frequency
+}
+
+// Busiest returns the modules sorted by update rate, fastest first.
+func (i Info) Busiest() []ModuleInfo {
+	mods := append([]ModuleInfo(nil), i.Modules...)
+	sort.SliceStable(mods, func(a, b int) bool {
+		return mods[a].UpdateRate > mods[b].UpdateRate
+	})
+	return mods
+}
+
+// Module represents a bar.Module that displays the bar's resource usage.
+type Module struct {
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New creates a new module that shows the bar's resource usage.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "outputFunc")
+	m.RefreshInterval(5 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d goroutines, %s heap",
+			i.Goroutines, format.IBytesize(i.Heap))
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined
+// function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// sample is a snapshot of the statistics used to compute rates.
+type sample struct {
+	when  time.Time
+	cpu   time.Duration
+	stats barista.Stats
+}
+
+// For tests.
+var getStats = barista.GetStats
+
+// For tests.
+var cpuTime = func() time.Duration {
+	var r unix.Rusage
+	if err := unix.Getrusage(unix.RUSAGE_SELF, &r); err != nil {
+		return 0
+	}
+	return time.Duration(r.Utime.Nano() + r.Stime.Nano())
+}
+
+func takeSample() sample {
+	return sample{timing.Now(), cpuTime(), getStats()}
+}
+
+// makeInfo computes the info for the interval between two samples.
+func makeInfo(prev, cur sample) Info {
+	i := Info{
+		Goroutines: cur.stats.Goroutines,
+		Heap:       unit.Datasize(cur.stats.HeapAlloc) * unit.Byte,
+		Renders:    cur.stats.Renders,
+	}
+	elapsed := cur.when.Sub(prev.when)
+	if elapsed > 0 {
+		i.CPUUsage = float64(cur.cpu-prev.cpu) / float64(elapsed)
+	}
+	if renders := cur.stats.Renders - prev.stats.Renders; renders > 0 {
+		i.RenderLatency = (cur.stats.RenderTime - prev.stats.RenderTime) /
+			time.Duration(renders)
+	}
+	for idx, s := range cur.stats.Modules {
+		mi := ModuleInfo{Name: s.Name, Updates: s.Updates, Errors: s.Errors}
+		if idx < len(prev.stats.Modules) && elapsed > 0 {
+			updates := s.Updates - prev.stats.Modules[idx].Updates
+			mi.UpdateRate = unit.Frequency(float64(updates)/elapsed.Seconds()) * unit.Hertz
+		}
+		i.Modules = append(i.Modules, mi)
+	}
+	return i
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	prev := takeSample()
+	info := makeInfo(prev, prev)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		s.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.C:
+			cur := takeSample()
+			info = makeInfo(prev, cur)
+			prev = cur
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// ServePprof serves the net/http/pprof handlers on a unix socket at the
+// given path. Profiles can be fetched using, for example,
+// `curl --unix-socket <path> http://bar/debug/pprof/heap`, and then examined
+// using `go tool pprof`. A socket left over at the path is replaced, but
+// other files are not. It returns once the socket is listening, and serves
+// requests in the background.
+func ServePprof(path string) error {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go func() {
+		l.Log("pprof server stopped: %v", http.Serve(lis, mux))
+	}()
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baristainfo
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"barista.run"
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/martinlindhe/unit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfo(t *testing.T) {
+	stats := barista.Stats{
+		Goroutines: 20,
+		HeapAlloc:  3 * 1024 * 1024,
+		Modules: []barista.ModuleStats{
+			{Name: "clock", Updates: 1},
+			{Name: "weather", Updates: 1},
+		},
+	}
+	getStats = func() barista.Stats { return stats }
+	cpu := time.Duration(0)
+	cpuTime = func() time.Duration { return cpu }
+
+	testBar.New(t)
+	var info Info
+	testBar.Run(New().Output(func(i Info) bar.Output {
+		info = i
+		return outputs.Textf("%d %v", i.Goroutines, i.Heap.Mebibytes())
+	}))
+	testBar.NextOutput("on start").AssertText([]string{"20 3"})
+	require.Zero(t, info.CPUUsage)
+	require.Zero(t, info.Modules[0].UpdateRate)
+
+	stats = barista.Stats{
+		Goroutines: 25,
+		HeapAlloc:  4 * 1024 * 1024,
+		Renders:    10,
+		RenderTime: 20 * time.Millisecond,
+		Modules: []barista.ModuleStats{
+			{Name: "clock", Updates: 6},
+			{Name: "weather", Updates: 2, Errors: 1},
+		},
+	}
+	cpu = 500 * time.Millisecond
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"25 4"})
+	require.InDelta(t, 0.1, info.CPUUsage, 0.001, "0.5s cpu in 5s")
+	require.Equal(t, 10, info.Renders)
+	require.Equal(t, 2*time.Millisecond, info.RenderLatency)
+	require.Equal(t, unit.Hertz, info.Modules[0].UpdateRate)
+	require.Equal(t, 1, info.Modules[1].Errors)
+	require.InDelta(t, 0.2, info.Modules[1].UpdateRate.Hertz(), 0.001)
+	require.Equal(t, "clock", info.Busiest()[0].Name)
+
+	testBar.Tick()
+	testBar.NextOutput("on tick without changes").AssertText([]string{"25 4"})
+	require.Zero(t, info.CPUUsage)
+	require.Zero(t, info.RenderLatency)
+	require.Zero(t, info.Modules[0].UpdateRate)
+}
+
+func TestDefaultOutput(t *testing.T) {
+	getStats = func() barista.Stats {
+		return barista.Stats{Goroutines: 12, HeapAlloc: 2 * 1024 * 1024}
+	}
+	testBar.New(t)
+	testBar.Run(New())
+	testBar.NextOutput("on start").AssertText(
+		[]string{"12 goroutines, 2.0 MiB heap"})
+}
+
+func TestServePprof(t *testing.T) {
+	dir, err := ioutil.TempDir("", "baristainfo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "pprof.sock")
+	require.NoError(t, ServePprof(socket))
+
+	client := &http.Client{Transport: &http.Transport{
+		Dial: func(string, string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://bar/debug/pprof/goroutine?debug=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := ioutil.ReadAll(resp.Body)
+	require.Contains(t, string(body), "goroutine profile")
+}
+
+func TestServePprofKeepsFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "baristainfo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "notes.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte("notes"), 0644))
+	require.Error(t, ServePprof(path), "path is not a socket")
+	require.FileExists(t, path, "existing file is not removed")
+
+	socket := filepath.Join(dir, "pprof.sock")
+	require.NoError(t, ServePprof(socket))
+	require.NoError(t, ServePprof(socket), "replaces existing socket")
+}
@@ -47,8 +47,6 @@ func (i IO) Total() unit.Datarate {
 }
 
 type diskInfo struct {
-	ioChan     chan<- IO
-	lastIO     *IO
 	lastRead   uint64
 	lastWrite  uint64
 	updateTime time.Time
@@ -60,6 +58,10 @@ var lock sync.Mutex
 var modules map[string]*diskInfo
 var updater *timing.Scheduler
 
+// currentIO stores the io rates of all disks from the last update, so that
+// all modules are updated together, once for each update.
+var currentIO = new(value.ErrorValue) // of map[string]IO
+
 // construct initialises diskio's global updating. All diskio
 // modules are updated with just one read of /proc/diskstats.
 func construct() {
@@ -67,6 +69,7 @@ func construct() {
 		modules = make(map[string]*diskInfo)
 		updater = timing.NewScheduler()
 		l.Attach(nil, updater, "diskio.updater")
+		l.Attach(nil, currentIO, "diskio.currentIO")
 		updater.Every(3 * time.Second)
 		update()
 		go func(updater *timing.Scheduler) {
@@ -84,59 +87,131 @@ func RefreshInterval(interval time.Duration) {
 	updater.Every(interval)
 }
 
-// Module represents a bar.Module for a single disk's io activity.
+// Module represents a bar.Module for the io activity of a disk, or the total
+// across a group of disks.
 type Module struct {
-	ioChan     <-chan IO
+	disks      []string
+	smoothing  value.Value // of float64
 	outputFunc value.Value
 }
 
 // New creates a diskio module that displays disk io rates for the given disk.
 func New(disk string) *Module {
+	return Aggregate(disk)
+}
+
+// Aggregate creates a diskio module that displays the total io rate across
+// all the given disks. Use Members to get the disks that make up an LVM
+// volume or RAID array. If no disks are given, the io rate is always zero.
+func Aggregate(disks ...string) *Module {
 	construct()
-	lock.Lock()
-	defer lock.Unlock()
-	mInfo, found := modules[disk]
-	if !found {
-		mInfo = &diskInfo{}
-		modules[disk] = mInfo
-	}
-	m := &Module{ioChan: mInfo.makeChannel()}
-	l.Label(m, disk)
-	l.Register(m, "ioChan", "outputFunc")
+	m := &Module{disks: disks}
+	l.Label(m, strings.Join(disks, "+"))
+	l.Register(m, "smoothing", "outputFunc")
+	m.smoothing.Set(1.0)
 	m.Output(func(i IO) bar.Output {
 		return outputs.Textf("Disk: %s", format.IByterate(i.Total()))
 	})
 	return m
 }
 
+// Members returns the disks that make up the given device-mapper (e.g. LVM)
+// or md RAID device, e.g. Members("md0") might return ["sda1", "sdb1"].
+func Members(device string) []string {
+	files, _ := afero.ReadDir(fs, "/sys/block/"+device+"/slaves")
+	var disks []string
+	for _, f := range files {
+		disks = append(disks, f.Name())
+	}
+	return disks
+}
+
 // Output configures a module to display the output of a user-defined function.
 func (m *Module) Output(outputFunc func(IO) bar.Output) *Module {
 	m.outputFunc.Set(outputFunc)
 	return m
 }
 
+// Smoothing configures an exponentially weighted moving average for io
+// rates, to avoid a jumpy display for bursty io. Each new rate is weighted
+// by alpha, which must be in (0, 1]. The default of 1 disables smoothing.
+func (m *Module) Smoothing(alpha float64) *Module {
+	m.smoothing.Set(alpha)
+	return m
+}
+
 // Stream starts the module. Note that diskio updates begin as soon as the
 // first module is constructed, even if no modules are streaming.
 func (m *Module) Stream(s bar.Sink) {
-	var i IO
+	// Updates are shared, so only stop while all modules are paused.
+	updater.OwnedBy(m)
+	rates, err := currentIO.Get()
+	nextIO, done := currentIO.Subscribe()
+	defer done()
+	disks := make([]IO, len(m.disks))
 	outputFunc := m.outputFunc.Get().(func(IO) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
+	fresh := true
 	for {
+		if rates, ok := rates.(map[string]IO); ok && fresh {
+			m.smooth(disks, rates)
+		}
+		i := sum(disks)
+		if len(m.disks) == 0 {
+			i.shouldOutput = true
+		}
+		switch {
+		case s.Error(err):
+		case s.Error(i.err):
+		case i.shouldOutput:
+			s.Output(outputFunc(i))
+		default:
+			s.Output(nil)
+		}
 		select {
-		case i = <-m.ioChan:
+		case <-nextIO:
+			rates, err = currentIO.Get()
+			fresh = true
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(IO) bar.Output)
+			fresh = false
 		}
-		if s.Error(i.err) {
-			continue
+	}
+}
+
+// smooth updates the io rates of the module's disks from the given update.
+func (m *Module) smooth(disks []IO, rates map[string]IO) {
+	alpha := m.smoothing.Get().(float64)
+	for idx, disk := range m.disks {
+		d, prev := rates[disk], disks[idx]
+		if prev.shouldOutput && d.shouldOutput {
+			d.Input = ewma(alpha, d.Input, prev.Input)
+			d.Output = ewma(alpha, d.Output, prev.Output)
 		}
-		if i.shouldOutput {
-			s.Output(outputFunc(i))
-		} else {
-			s.Output(nil)
+		disks[idx] = d
+	}
+}
+
+func ewma(alpha float64, current, previous unit.Datarate) unit.Datarate {
+	return unit.Datarate(alpha)*current + unit.Datarate(1-alpha)*previous
+}
+
+// sum combines the io rates of all disks. Disks without data are ignored,
+// and an error in any disk is propagated.
+func sum(disks []IO) IO {
+	var total IO
+	for _, d := range disks {
+		if d.err != nil {
+			return d
+		}
+		if d.shouldOutput {
+			total.Input += d.Input
+			total.Output += d.Output
+			total.shouldOutput = true
 		}
 	}
+	return total
 }
 
 // update updates the last read information, and returns
@@ -155,31 +230,6 @@ func (m *diskInfo) update(read, write uint64) (readRate, writeRate int) {
 	return // readRate, writeRate
 }
 
-func (m *diskInfo) Error(err error) bool {
-	if err == nil {
-		return false
-	}
-	m.send(IO{err: err})
-	return true
-}
-
-func (m *diskInfo) send(i IO) {
-	if m.ioChan == nil {
-		m.lastIO = &i
-	} else {
-		m.ioChan <- i
-	}
-}
-
-func (m *diskInfo) makeChannel() <-chan IO {
-	ioChan := make(chan IO, 1)
-	m.ioChan = ioChan
-	if m.lastIO != nil {
-		m.ioChan <- *m.lastIO
-	}
-	return ioChan
-}
-
 var fs = afero.NewOsFs()
 
 // To prevent data races in tests.
@@ -190,18 +240,13 @@ func update() {
 	defer lock.Unlock()
 	var err error
 	f, err := fs.Open("/proc/diskstats")
-	if err != nil {
-		for _, m := range modules {
-			m.Error(err)
-		}
+	if currentIO.Error(err) {
 		return
 	}
 	defer f.Close()
 	s := bufio.NewScanner(f)
 	s.Split(bufio.ScanLines)
-	// Keep track of which submodules were updated, so that any drives
-	// that were removed can be cleared instead of showing stale data.
-	updated := make(map[string]bool)
+	rates := map[string]IO{}
 	for s.Scan() {
 		info := strings.Fields(s.Text())
 		if len(info) < 14 {
@@ -214,32 +259,33 @@ func update() {
 			module = &diskInfo{}
 			modules[disk] = module
 		}
-		updated[disk] = true
 		reads, err := strconv.ParseUint(info[5], 10, 64)
-		if module.Error(err) {
+		if err != nil {
+			rates[disk] = IO{err: err}
 			continue
 		}
 		writes, err := strconv.ParseUint(info[9], 10, 64)
-		if module.Error(err) {
+		if err != nil {
+			rates[disk] = IO{err: err}
 			continue
 		}
 		shouldOutput := !module.updateTime.IsZero()
 		readRate, writeRate := module.update(reads, writes)
-		module.send(IO{
+		rates[disk] = IO{
 			// Linux always considers sectors to be 512 bytes long
 			// independently of the devices real block size.
 			// (from linux/types.h)
 			Input:        unit.Datarate(readRate) * 512 * unit.BytePerSecond,
 			Output:       unit.Datarate(writeRate) * 512 * unit.BytePerSecond,
 			shouldOutput: shouldOutput,
-		})
+		}
 	}
-	for disk, module := range modules {
-		if !updated[disk] {
-			module.lastRead = 0
-			module.lastWrite = 0
-			module.updateTime = time.Time{}
-			module.send(IO{})
+	// Forget any drives that were removed, so that they start afresh
+	// if they are added back instead of showing stale data.
+	for disk := range modules {
+		if _, ok := rates[disk]; !ok {
+			delete(modules, disk)
 		}
 	}
+	currentIO.Set(rates)
 }
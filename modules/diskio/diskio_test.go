@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/value"
 	"barista.run/format"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
@@ -50,6 +51,7 @@ func shouldReturn(stats diskstats) {
 func resetForTest() {
 	fs = afero.NewMemMapFs()
 	modules = nil
+	currentIO = new(value.ErrorValue)
 	updater = nil
 	once = sync.Once{}
 }
@@ -81,7 +83,7 @@ func TestDiskIo(t *testing.T) {
 	testBar.Tick()
 
 	// 9+9 sectors / 3 seconds = 6 sectors / second * 512 bytes / sector = 3072 bytes.
-	testBar.LatestOutput(0).At(0).AssertText("sda1: 3.1 kB/s", "on tick")
+	testBar.LatestOutput().At(0).AssertText("sda1: 3.1 kB/s", "on tick")
 
 	// Simpler math.
 	RefreshInterval(time.Second)
@@ -141,10 +143,10 @@ func TestErrors(t *testing.T) {
 	sda2 := New("sda2")
 
 	testBar.Run(sda, sda1, sda2)
-	testBar.AssertNoOutput("on start with missing diskstats")
+	testBar.LatestOutput().AssertError("on start with missing diskstats")
 
 	testBar.Tick()
-	testBar.LatestOutput().AssertError("on first tick if missing diskstats")
+	testBar.LatestOutput().AssertError("on tick with missing diskstats")
 
 	lock.Lock()
 	afero.WriteFile(fs, "/proc/diskstats", []byte(`
@@ -180,3 +182,61 @@ a b sda2 0 0 100 0 0 0 b 0 0 0 0
 		"Disk: 100 KiB/s",
 		"ignores invalid lines in diskstats")
 }
+
+func TestAggregate(t *testing.T) {
+	resetForTest()
+	testBar.New(t)
+
+	afero.WriteFile(fs, "/sys/block/md0/slaves/sda1", nil, 0644)
+	afero.WriteFile(fs, "/sys/block/md0/slaves/sdb1", nil, 0644)
+	require.Equal(t, []string{"sda1", "sdb1"}, Members("md0"))
+	require.Empty(t, Members("sda"))
+
+	shouldReturn(diskstats{
+		"sda1": []int{0, 0},
+		"sdb1": []int{0, 0},
+	})
+	construct()
+	RefreshInterval(time.Second)
+
+	md0 := Aggregate(Members("md0")...).Output(func(i IO) bar.Output {
+		return outputs.Textf("%.0f/%.0f",
+			i.Input.BytesPerSecond(), i.Output.BytesPerSecond())
+	})
+	sda1 := New("sda1").Output(func(i IO) bar.Output {
+		return outputs.Textf("%.0f", i.Total().BytesPerSecond())
+	})
+	none := Aggregate(Members("sda")...).Output(func(i IO) bar.Output {
+		return outputs.Textf("none: %.0f", i.Total().BytesPerSecond())
+	})
+	testBar.Run(md0, sda1, none)
+	testBar.LatestOutput().AssertText([]string{"none: 0"},
+		"on start, without members")
+
+	shouldReturn(diskstats{
+		"sda1": []int{2, 4},
+		"sdb1": []int{1, 0},
+	})
+	testBar.Tick()
+	testBar.LatestOutput().AssertText(
+		[]string{"1536/2048", "3072", "none: 0"}, "on tick")
+	testBar.AssertNoOutput("only one output for each tick")
+
+	md0.Smoothing(0.5)
+	shouldReturn(diskstats{
+		"sda1": []int{2, 4},
+		"sdb1": []int{1, 8},
+	})
+	testBar.Tick()
+	// sda1: (0+1024)/2, (0+2048)/2; sdb1: (0+512)/2, (4096+0)/2.
+	testBar.LatestOutput().AssertText(
+		[]string{"768/3072", "0", "none: 0"}, "on tick with smoothing")
+
+	shouldReturn(diskstats{
+		"sdb1": []int{1, 8},
+	})
+	testBar.Tick()
+	// sda1 removed; sdb1: (0+256)/2, (0+2048)/2.
+	testBar.LatestOutput().AssertText(
+		[]string{"128/1024", "none: 0"}, "on disk removed")
+}
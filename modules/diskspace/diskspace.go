@@ -21,6 +21,7 @@ import (
 
 	"barista.run/bar"
 	"barista.run/base/value"
+	"barista.run/format"
 	l "barista.run/logging"
 	"barista.run/outputs"
 	"barista.run/timing"
@@ -78,9 +79,9 @@ func New(path string) *Module {
 	l.Label(m, path)
 	l.Register(m, "scheduler", "format")
 	m.RefreshInterval(3 * time.Second)
-	// Construct a simple output that's just 2 decimals of the used disk space.
+	// Construct a simple output that's just the used disk space.
 	m.Output(func(i Info) bar.Output {
-		return outputs.Textf("%.2f GB", i.Used().Gigabytes())
+		return outputs.Text(format.Size(i.Used()))
 	})
 	return m
 }
@@ -0,0 +1,123 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnd
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+
+	"barista.run/base/value"
+	"barista.run/base/watchers/dbus"
+	"barista.run/timing"
+
+	godbus "github.com/godbus/dbus/v5"
+)
+
+const (
+	dunstService = "org.freedesktop.Notifications"
+	dunstObject  = "/org/freedesktop/Notifications"
+	dunstIface   = "org.dunstproject.cmd0"
+)
+
+// Overridden in tests.
+var busType = dbus.Session
+
+type dunst struct {
+	w *dbus.PropertiesWatcher
+}
+
+// Dunst returns a provider for the dunst notification daemon, which pauses
+// notifications while do-not-disturb is enabled.
+func Dunst() Provider {
+	return dunst{}
+}
+
+// Worker watches the paused property for changes.
+func (d dunst) Worker(s *value.ErrorValue) {
+	d.w = dbus.WatchProperties(busType, dunstService, dunstObject, dunstIface).
+		Add("paused")
+	defer d.w.Unsubscribe()
+	for {
+		if paused, ok := d.w.Get()["paused"].(bool); ok {
+			s.Set(MakeState(paused, d))
+		} else {
+			s.Error(errors.New("dunst is not running"))
+		}
+		<-d.w.Updates
+	}
+}
+
+// SetEnabled pauses or resumes notifications.
+func (d dunst) SetEnabled(enabled bool) error {
+	_, err := d.w.Call("org.freedesktop.DBus.Properties.Set",
+		dunstIface, "paused", godbus.MakeVariant(enabled))
+	return err
+}
+
+// makoMode is the mako mode used for do-not-disturb.
+const makoMode = "do-not-disturb"
+
+type mako struct {
+	interval time.Duration
+}
+
+// Mako returns a provider for the mako notification daemon, which enables
+// the "do-not-disturb" mode while do-not-disturb is enabled. Since mako does
+// not notify clients of mode changes, the current mode is checked at the
+// given interval. The mode must be defined in the mako config, e.g.
+// "[mode=do-not-disturb] invisible=1".
+func Mako(interval time.Duration) Provider {
+	return mako{interval}
+}
+
+// For tests.
+var runCommand = func(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+// Worker periodically reads the current mako modes.
+func (m mako) Worker(s *value.ErrorValue) {
+	sch := timing.NewScheduler().Every(m.interval)
+	defer sch.Stop()
+	for {
+		out, err := runCommand("makoctl", "mode")
+		if s.Error(err) {
+			return
+		}
+		enabled := false
+		for _, mode := range strings.Fields(string(out)) {
+			if mode == makoMode {
+				enabled = true
+			}
+		}
+		// Only update on changes, since polling is not an update.
+		if cur, _ := s.Get(); cur == nil || cur.(State).Enabled != enabled {
+			s.Set(MakeState(enabled, m))
+		}
+		<-sch.C
+	}
+}
+
+// SetEnabled adds or removes the do-not-disturb mode.
+func (m mako) SetEnabled(enabled bool) error {
+	flag := "-r"
+	if enabled {
+		flag = "-a"
+	}
+	_, err := runCommand("makoctl", "mode", flag, makoMode)
+	return err
+}
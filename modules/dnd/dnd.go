@@ -0,0 +1,156 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dnd provides an i3bar module that displays and toggles the
+// do-not-disturb state of a notification daemon, and can suppress urgent
+// segments on the bar while do-not-disturb is enabled.
+package dnd // import "barista.run/modules/dnd"
+
+import (
+	"barista.run"
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/modules/meta/alert"
+	"barista.run/outputs"
+)
+
+// State represents the current do-not-disturb state.
+type State struct {
+	// Enabled is true if notifications are being held back.
+	Enabled    bool
+	controller Controller
+	update     func(State)
+}
+
+// MakeState creates a State instance with the given data.
+func MakeState(enabled bool, controller Controller) State {
+	return State{Enabled: enabled, controller: controller}
+}
+
+// SetEnabled enables or disables do-not-disturb.
+func (s State) SetEnabled(enabled bool) {
+	if s.Enabled == enabled {
+		return
+	}
+	if err := s.controller.SetEnabled(enabled); err != nil {
+		l.Log("Error updating do-not-disturb: %v", err)
+		return
+	}
+	s.Enabled = enabled
+	s.update(s)
+}
+
+// Toggle toggles do-not-disturb.
+func (s State) Toggle() {
+	s.SetEnabled(!s.Enabled)
+}
+
+// Controller for a notification daemon.
+type Controller interface {
+	// SetEnabled enables or disables do-not-disturb.
+	SetEnabled(enabled bool) error
+}
+
+// Provider is the interface that must be implemented by individual
+// notification daemon implementations.
+type Provider interface {
+	// Worker pushes updates and errors to the provided ErrorValue.
+	Worker(s *value.ErrorValue)
+}
+
+// Module represents a bar.Module that displays the do-not-disturb state.
+type Module struct {
+	outputFunc     value.Value // of func(State) bar.Output
+	provider       Provider
+	suppressUrgent bool
+}
+
+// Output configures a module to display the output of a user-defined
+// function.
+func (m *Module) Output(outputFunc func(State) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// SuppressUrgent configures the module to prevent segments from being marked
+// urgent on the bar, and alert notifications from being sent, while
+// do-not-disturb is enabled. Error segments are still marked urgent.
+func (m *Module) SuppressUrgent() *Module {
+	m.suppressUrgent = true
+	return m
+}
+
+// Overridden in tests.
+var suppressUrgent = func(suppress bool) {
+	barista.SuppressUrgent(suppress)
+	alert.Suppress(suppress)
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	var state value.ErrorValue
+
+	v, err := state.Get()
+	nextV, done := state.Subscribe()
+	defer done()
+	go m.provider.Worker(&state)
+
+	outputFunc := m.outputFunc.Get().(func(State) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	if m.suppressUrgent {
+		// The do-not-disturb state is unknown once the module stops.
+		defer suppressUrgent(false)
+	}
+
+	for {
+		if sink.Error(err) {
+			return
+		}
+		if s, ok := v.(State); ok {
+			s.update = func(s State) { state.Set(s) }
+			if m.suppressUrgent {
+				suppressUrgent(s.Enabled)
+			}
+			sink.Output(outputs.Group(outputFunc(s)).
+				OnClick(func(e bar.Event) {
+					if e.Button == bar.ButtonLeft {
+						s.Toggle()
+					}
+				}))
+		}
+		select {
+		case <-nextV:
+			v, err = state.Get()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(State) bar.Output)
+		}
+	}
+}
+
+// New creates a new module with the given backing implementation.
+func New(provider Provider) *Module {
+	m := &Module{provider: provider}
+	l.Register(m, "outputFunc", "provider")
+	// Default output is "DND ON" or "DND OFF", toggled on left click.
+	m.Output(func(s State) bar.Output {
+		if s.Enabled {
+			return outputs.Text("DND ON")
+		}
+		return outputs.Text("DND OFF")
+	})
+	return m
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnd
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/base/watchers/dbus"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMako struct {
+	sync.Mutex
+	modes    []string
+	commands []string
+	err      error
+}
+
+func (f *fakeMako) run(name string, args ...string) ([]byte, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.commands = append(f.commands, name+" "+strings.Join(args, " "))
+	if f.err != nil {
+		return nil, f.err
+	}
+	switch {
+	case len(args) == 3 && args[1] == "-a":
+		f.modes = append(f.modes, args[2])
+	case len(args) == 3 && args[1] == "-r":
+		var modes []string
+		for _, m := range f.modes {
+			if m != args[2] {
+				modes = append(modes, m)
+			}
+		}
+		f.modes = modes
+	}
+	return []byte(strings.Join(f.modes, "\n") + "\n"), nil
+}
+
+func (f *fakeMako) get() []string {
+	f.Lock()
+	defer f.Unlock()
+	r := f.commands
+	f.commands = nil
+	return r
+}
+
+func (f *fakeMako) setModes(modes ...string) {
+	f.Lock()
+	defer f.Unlock()
+	f.modes = modes
+}
+
+func (f *fakeMako) setError(err error) {
+	f.Lock()
+	defer f.Unlock()
+	f.err = err
+}
+
+func TestMako(t *testing.T) {
+	f := &fakeMako{modes: []string{"default"}}
+	runCommand = f.run
+
+	testBar.New(t)
+	testBar.Run(New(Mako(time.Minute)))
+	out := testBar.NextOutput("initial")
+	out.AssertText([]string{"DND OFF"})
+	require.Equal(t, []string{"makoctl mode"}, f.get())
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"DND ON"})
+	require.Equal(t, []string{"makoctl mode -a do-not-disturb"}, f.get())
+
+	testBar.Tick()
+	testBar.AssertNoOutput("unchanged on refresh")
+	require.Equal(t, []string{"makoctl mode"}, f.get())
+
+	f.setModes("default")
+	testBar.Tick()
+	out = testBar.NextOutput("on external change")
+	out.AssertText([]string{"DND OFF"})
+
+	f.setError(errors.New("mako is not running"))
+	out.At(0).LeftClick()
+	testBar.AssertNoOutput("on command error")
+	require.Equal(t, []string{"makoctl mode", "makoctl mode -a do-not-disturb"}, f.get())
+
+	testBar.Tick()
+	testBar.NextOutput("on refresh error").AssertError()
+}
+
+func TestDunst(t *testing.T) {
+	busType = dbus.Test
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService(dunstService)
+	obj := srv.Object(dunstObject, dunstIface)
+	obj.SetProperty("paused", false)
+
+	var suppressed []bool
+	var mu sync.Mutex
+	suppressUrgent = func(s bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		suppressed = append(suppressed, s)
+	}
+	getSuppressed := func() []bool {
+		mu.Lock()
+		defer mu.Unlock()
+		r := suppressed
+		suppressed = nil
+		return r
+	}
+
+	testBar.New(t)
+	testBar.Run(New(Dunst()).SuppressUrgent())
+	// The initial properties can be reported more than once.
+	out := testBar.Drain(50*time.Millisecond, "on start")
+	out.AssertText([]string{"DND OFF"})
+	require.NotContains(t, getSuppressed(), true)
+
+	out.At(0).LeftClick()
+	out = testBar.Drain(50*time.Millisecond, "on click")
+	out.AssertText([]string{"DND ON"})
+	val, err := obj.GetProperty(dunstIface + ".paused")
+	require.NoError(t, err)
+	require.Equal(t, true, val.Value())
+	require.Contains(t, getSuppressed(), true)
+
+	obj.SetProperty("paused", false)
+	testBar.NextOutput("on external change").AssertText([]string{"DND OFF"})
+	require.Equal(t, []bool{false}, getSuppressed())
+
+	srv.Unregister()
+	testBar.NextOutput("on service stop").AssertError()
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(suppressed) > 0 && !suppressed[len(suppressed)-1]
+	}, time.Second, time.Millisecond, "urgent restored when stopped")
+}
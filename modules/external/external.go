@@ -0,0 +1,375 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package external provides a module that displays the output of an
+// external program, so that scripts written for i3blocks or other bars can
+// be used without rewriting them in Go. Three protocols are supported:
+// plain lines of text, i3blocks-compatible blocklets, and JSON blocks in
+// the i3bar format, with click events sent to the program's stdin.
+package external // import "barista.run/modules/external"
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/colors"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+type protocol int
+
+const (
+	lines protocol = iota
+	blocklet
+	jsonBlocks
+)
+
+// Module represents a bar.Module that displays the output of an external
+// program.
+type Module struct {
+	cmd       string
+	args      []string
+	protocol  protocol
+	scheduler *timing.Scheduler
+	// For blocklets, the last click event is passed to the program.
+	lastEventMu sync.Mutex
+	lastEvent   *clickEvent
+	notifyFn    func()
+	notifyCh    <-chan struct{}
+}
+
+func newModule(p protocol, cmd string, args ...string) *Module {
+	m := &Module{cmd: cmd, args: args, protocol: p}
+	m.scheduler = timing.NewScheduler()
+	m.notifyFn, m.notifyCh = notifier.New()
+	l.Label(m, cmd)
+	l.Register(m, "scheduler")
+	return m
+}
+
+// Lines constructs a module that runs a long-running program, and displays
+// each line it prints in turn.
+func Lines(cmd string, args ...string) *Module {
+	return newModule(lines, cmd, args...)
+}
+
+// JSON constructs a module that runs a long-running program that prints
+// i3bar blocks as JSON, either a single object or an array of objects per
+// line. The i3bar header and the surrounding array are accepted, so that
+// programs that implement the i3bar protocol (e.g. i3status with output
+// format i3bar) also work. Click events are written to the program's stdin
+// in the i3bar format, one per line, with the name and instance of the
+// block that was clicked.
+func JSON(cmd string, args ...string) *Module {
+	return newModule(jsonBlocks, cmd, args...)
+}
+
+// Blocklet constructs a module that runs an i3blocks blocklet. The program
+// is run once, on every click, and at the interval set by Every. The first
+// three lines of its output are the full text, short text, and color. An
+// exit code of 33 marks the output as urgent, and any other non-zero exit
+// code is an error. The click event is passed using the same environment
+// variables as i3blocks (e.g. $BLOCK_BUTTON).
+func Blocklet(cmd string, args ...string) *Module {
+	return newModule(blocklet, cmd, args...)
+}
+
+// Every sets the interval at which a blocklet is run again. It has no effect
+// on the other protocols, whose programs run continuously.
+func (m *Module) Every(interval time.Duration) *Module {
+	if interval == 0 {
+		m.scheduler.Stop()
+	} else {
+		m.scheduler.Every(interval)
+	}
+	return m
+}
+
+// Refresh runs a blocklet again.
+func (m *Module) Refresh() {
+	m.notifyFn()
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	if m.protocol == blocklet {
+		m.streamBlocklet(s)
+	} else {
+		m.streamPersistent(s)
+	}
+}
+
+// clickEvent is a click event in the i3bar format.
+type clickEvent struct {
+	bar.Event
+	Name     string `json:"name,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+func command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	// Prevent SIGUSR for bar pause/resume from propagating to the
+	// child process. Some commands don't play nice with signals.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
+func (m *Module) streamPersistent(s bar.Sink) {
+	cmd := command(m.cmd, m.args...)
+	stdout, err := cmd.StdoutPipe()
+	if s.Error(err) {
+		return
+	}
+	var stdin io.WriteCloser
+	if m.protocol == jsonBlocks {
+		if stdin, err = cmd.StdinPipe(); s.Error(err) {
+			return
+		}
+	}
+	if s.Error(cmd.Start()) {
+		return
+	}
+	// Clicks are never closed, since click handlers can be called after
+	// the program exits. Events are dropped once the buffer is full.
+	clicks := make(chan clickEvent, 10)
+	if stdin != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go forwardClicks(stdin, clicks, done)
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m.protocol == lines {
+			s.Output(outputs.Text(line))
+			continue
+		}
+		out, ok, err := parseJSONLine(line)
+		switch {
+		case err != nil:
+			l.Log("%s: invalid output %q: %v", l.ID(m), line, err)
+		case ok:
+			s.Output(withClickForwarding(out, clicks))
+		}
+	}
+	s.Error(cmd.Wait())
+}
+
+// forwardClicks writes click events to the program's stdin, until the
+// program exits or stops reading.
+func forwardClicks(stdin io.WriteCloser, clicks <-chan clickEvent, done <-chan struct{}) {
+	defer stdin.Close()
+	enc := json.NewEncoder(stdin)
+	for {
+		select {
+		case e := <-clicks:
+			if enc.Encode(e) != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func withClickForwarding(blocks []block, clicks chan<- clickEvent) bar.Segments {
+	var out bar.Segments
+	for _, b := range blocks {
+		// because go.
+		b := b
+		out = append(out, b.segment().OnClick(func(e bar.Event) {
+			select {
+			case clicks <- clickEvent{e, b.Name, b.Instance}:
+			default:
+			}
+		}))
+	}
+	return out
+}
+
+// parseJSONLine parses a line of JSON output, which can be an array of
+// blocks or a single block. Lines that are part of the i3bar protocol but
+// do not contain blocks are ignored.
+func parseJSONLine(line string) ([]block, bool, error) {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSuffix(strings.TrimPrefix(line, ","), ",")
+	switch {
+	case line == "", line == "[":
+		return nil, false, nil
+	case strings.HasPrefix(line, "["):
+		var blocks []block
+		err := json.Unmarshal([]byte(line), &blocks)
+		return blocks, err == nil, err
+	}
+	var b struct {
+		block
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(line), &b); err != nil {
+		return nil, false, err
+	}
+	if b.Version != nil {
+		// i3bar protocol header.
+		return nil, false, nil
+	}
+	return []block{b.block}, true, nil
+}
+
+func (m *Module) streamBlocklet(s bar.Sink) {
+	for {
+		m.lastEventMu.Lock()
+		e := m.lastEvent
+		m.lastEvent = nil
+		m.lastEventMu.Unlock()
+		out, err := m.runBlocklet(e)
+		if s.Error(err) {
+			return
+		}
+		s.Output(out)
+		select {
+		case <-m.scheduler.C:
+		case <-m.notifyCh:
+		}
+	}
+}
+
+func (m *Module) runBlocklet(e *clickEvent) (bar.Output, error) {
+	cmd := command(m.cmd, m.args...)
+	cmd.Env = os.Environ()
+	if e != nil {
+		cmd.Env = append(cmd.Env, blockletEnv(*e)...)
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
+	urgent := false
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 33 {
+		urgent, err = true, nil
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(string(stdout), "\n"), "\n")
+	b := block{FullText: lines[0]}
+	if len(lines) > 1 {
+		b.ShortText = lines[1]
+	}
+	if len(lines) > 2 {
+		b.Color = lines[2]
+	}
+	seg := b.segment().OnClick(func(e bar.Event) {
+		m.lastEventMu.Lock()
+		m.lastEvent = &clickEvent{Event: e}
+		m.lastEventMu.Unlock()
+		m.notifyFn()
+	})
+	if urgent {
+		seg.Urgent(true)
+	}
+	return seg, nil
+}
+
+// blockletEnv returns the environment variables used by i3blocks to pass
+// click events to blocklets.
+func blockletEnv(e clickEvent) []string {
+	env := map[string]int{
+		"BLOCK_BUTTON":     int(e.Button),
+		"BLOCK_X":          e.ScreenX,
+		"BLOCK_Y":          e.ScreenY,
+		"BLOCK_RELATIVE_X": e.X,
+		"BLOCK_RELATIVE_Y": e.Y,
+		"BLOCK_WIDTH":      e.Width,
+		"BLOCK_HEIGHT":     e.Height,
+	}
+	var vars []string
+	for k, v := range env {
+		vars = append(vars, k+"="+strconv.Itoa(v))
+	}
+	return vars
+}
+
+// block is a block of output in the i3bar format.
+type block struct {
+	FullText            string      `json:"full_text"`
+	ShortText           string      `json:"short_text"`
+	Color               string      `json:"color"`
+	Background          string      `json:"background"`
+	Border              string      `json:"border"`
+	MinWidth            interface{} `json:"min_width"`
+	Align               string      `json:"align"`
+	Name                string      `json:"name"`
+	Instance            string      `json:"instance"`
+	Urgent              bool        `json:"urgent"`
+	Separator           *bool       `json:"separator"`
+	SeparatorBlockWidth *int        `json:"separator_block_width"`
+	Markup              string      `json:"markup"`
+}
+
+func (b block) segment() *bar.Segment {
+	var s *bar.Segment
+	if b.Markup == "pango" {
+		s = bar.PangoSegment(b.FullText)
+	} else {
+		s = bar.TextSegment(b.FullText)
+	}
+	if b.ShortText != "" {
+		s.ShortText(b.ShortText)
+	}
+	if c := colors.Hex(b.Color); c != nil {
+		s.Color(c)
+	}
+	if c := colors.Hex(b.Background); c != nil {
+		s.Background(c)
+	}
+	if c := colors.Hex(b.Border); c != nil {
+		s.Border(c)
+	}
+	switch w := b.MinWidth.(type) {
+	case float64:
+		s.MinWidth(int(w))
+	case string:
+		s.MinWidthPlaceholder(w)
+	}
+	if b.Align != "" {
+		s.Align(bar.TextAlignment(b.Align))
+	}
+	if b.Urgent {
+		s.Urgent(true)
+	}
+	if b.Separator != nil {
+		s.Separator(*b.Separator)
+	}
+	if b.SeparatorBlockWidth != nil {
+		s.Padding(*b.SeparatorBlockWidth)
+	}
+	return s
+}
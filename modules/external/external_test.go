@@ -0,0 +1,134 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/colors"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLines(t *testing.T) {
+	testBar.New(t)
+	testBar.Run(Lines("bash", "-c", "for i in 1 2 3; do echo $i; sleep 0.075; done; exit 1"))
+	for _, i := range []string{"1", "2", "3"} {
+		testBar.NextOutput().AssertText([]string{i}, i)
+	}
+	testBar.NextOutput().AssertError("when program exits with an error")
+}
+
+const jsonScript = `
+echo '{"version": 1, "click_events": true}'
+echo '['
+echo '[{"full_text": "prev", "name": "media", "instance": "prev"}, {"full_text": "next", "instance": "next", "color": "#ff0000"}],'
+while read -r line; do
+	case "$line" in
+	*'"name":"media","instance":"prev"'*) echo ',{"full_text": "back", "urgent": true, "separator": false}' ;;
+	*'"instance":"next"'*) echo ',{"full_text": "forward", "short_text": "fwd", "separator_block_width": 3}' ;;
+	*) echo "not json: $line" ;;
+	esac
+done
+`
+
+func TestJSON(t *testing.T) {
+	testBar.New(t)
+	testBar.Run(JSON("bash", "-c", jsonScript))
+
+	buttons := testBar.NextOutput("on start")
+	buttons.AssertText([]string{"prev", "next"})
+	col, _ := buttons.At(1).Segment().GetColor()
+	require.Equal(t, colors.Hex("#ff0000"), col)
+
+	buttons.At(0).Click(bar.Event{Button: bar.ButtonLeft, X: 4, Width: 20})
+	out := testBar.NextOutput("on click")
+	out.AssertText([]string{"back"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+	sep, _ := out.At(0).Segment().HasSeparator()
+	require.False(t, sep)
+
+	out.At(0).LeftClick()
+	testBar.AssertNoOutput("on invalid output")
+
+	buttons.At(1).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on click of previous output")
+	out.AssertText([]string{"forward"})
+	shortText, _ := out.At(0).Segment().GetShortText()
+	require.Equal(t, "fwd", shortText)
+	padding, _ := out.At(0).Segment().GetPadding()
+	require.Equal(t, 3, padding)
+}
+
+const blockletScript = `
+if [ -n "$BLOCK_BUTTON" ]; then
+	echo "clicked $BLOCK_BUTTON at $BLOCK_RELATIVE_X/$BLOCK_WIDTH"
+	exit 33
+fi
+echo "full text"
+echo "short"
+echo "#00ff00"
+`
+
+func TestBlocklet(t *testing.T) {
+	testBar.New(t)
+	b := Blocklet("bash", "-c", blockletScript).Every(time.Minute)
+	testBar.Run(b)
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"full text"})
+	shortText, _ := out.At(0).Segment().GetShortText()
+	require.Equal(t, "short", shortText)
+	col, _ := out.At(0).Segment().GetColor()
+	require.Equal(t, colors.Hex("#00ff00"), col)
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight, X: 5, Width: 40})
+	out = testBar.NextOutput("on click")
+	out.AssertText([]string{"clicked 3 at 5/40"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent, "exit code 33 is urgent")
+
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"full text"})
+
+	b.Refresh()
+	testBar.NextOutput("on refresh").AssertText([]string{"full text"})
+}
+
+func TestBlockletError(t *testing.T) {
+	testBar.New(t)
+	testBar.Run(Blocklet("bash", "-c", "echo 'not found' >&2; exit 1"))
+	errStr := testBar.NextOutput().At(0).AssertError()
+	require.Contains(t, errStr, "not found")
+}
+
+func TestParseJSONLine(t *testing.T) {
+	for _, line := range []string{"", "[", `{"version":1}`, "  "} {
+		_, ok, err := parseJSONLine(line)
+		require.NoError(t, err, line)
+		require.False(t, ok, line)
+	}
+	_, _, err := parseJSONLine("[{]")
+	require.Error(t, err)
+	blocks, ok, err := parseJSONLine(`,{"full_text": "a", "min_width": "100%"}`)
+	require.NoError(t, err)
+	require.True(t, ok)
+	minWidth, _ := blocks[0].segment().GetMinWidth()
+	require.Equal(t, "100%", minWidth)
+}
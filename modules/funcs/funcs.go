@@ -16,21 +16,78 @@
 package funcs // import "barista.run/modules/funcs"
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/base/watchers/file"
 	"barista.run/timing"
 )
 
 // Func receives a bar.Sink and uses it for output.
 type Func func(bar.Sink)
 
+// CtxFunc receives a context and a bar.Sink, and uses the sink for output.
+// The context is cancelled once the function is no longer needed, so that
+// long-running work (e.g. HTTP requests) can be aborted.
+type CtxFunc func(context.Context, bar.Sink)
+
+// PauseTimeout is how long the bar can remain paused while a CtxFunc is
+// running before its context is cancelled.
+var PauseTimeout = time.Minute
+
+// runWithContext runs the function with a context that is cancelled when the
+// function returns, or if the bar is paused for longer than PauseTimeout.
+func runWithContext(f CtxFunc, s bar.Sink) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cancelOnPause(ctx, cancel)
+	f(ctx, s)
+}
+
+func cancelOnPause(ctx context.Context, cancel func()) {
+	for {
+		paused, stop := timing.Paused()
+		select {
+		case <-ctx.Done():
+			stop()
+			return
+		case <-paused:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-timing.Resumed():
+		case <-time.After(PauseTimeout):
+			cancel()
+			return
+		}
+	}
+}
+
 // Once constructs a bar module that runs the given function once.
 // Useful if the function loops internally.
 func Once(f Func) *OnceModule {
 	return &OnceModule{Func: f}
 }
 
+// OnceWithContext constructs a bar module that runs the given function once,
+// with a context that is cancelled when the function returns. Since the
+// function is never run again, its context is not cancelled if the bar is
+// paused.
+func OnceWithContext(f CtxFunc) *OnceModule {
+	return Once(func(s bar.Sink) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		f(ctx, s)
+	})
+}
+
 // OnceModule represents a bar.Module that runs a function once.
 // If the function sets an error output, it will be restarted on
 // the next click.
@@ -53,6 +110,13 @@ func OnClick(f Func) *OnclickModule {
 	return &OnclickModule{f}
 }
 
+// OnClickWithContext constructs a bar module that runs the given function
+// when clicked, with a context that is cancelled when the function returns or
+// if the bar is paused for too long.
+func OnClickWithContext(f CtxFunc) *OnclickModule {
+	return OnClick(func(s bar.Sink) { runWithContext(f, s) })
+}
+
 // OnclickModule represents a bar.Module that runs a function and
 // marks the module as finished, causing the next click to start the
 // module again.
@@ -68,21 +132,172 @@ func (o OnclickModule) Stream(s bar.Sink) {
 // Every constructs a bar module that repeatedly runs the given function.
 // Useful if the function needs to poll a resource for output.
 func Every(d time.Duration, f Func) *RepeatingModule {
-	return &RepeatingModule{fn: f, duration: d}
+	return EveryWithContext(d, func(_ context.Context, s bar.Sink) { f(s) })
+}
+
+// EveryWithContext constructs a bar module that repeatedly runs the given
+// function, with a context that is cancelled when each run returns or if the
+// bar is paused for too long.
+func EveryWithContext(d time.Duration, f CtxFunc) *RepeatingModule {
+	r := &RepeatingModule{fn: f, duration: d}
+	r.resumeFn, r.resumeCh = notifier.New()
+	r.refreshFn, r.refreshCh = notifier.New()
+	return r
+}
+
+// EveryWithBackoff constructs a bar module that repeatedly runs the given
+// function, and retries it using the default retry policy when it outputs an
+// error.
+func EveryWithBackoff(d time.Duration, f Func) *RepeatingModule {
+	return Every(d, f).Retry(timing.DefaultRetryPolicy)
 }
 
 // RepeatingModule represents a bar.Module that runs a function at a fixed
 // interval (while accounting for bar paused/resumed state).
 type RepeatingModule struct {
-	fn       Func
+	fn       CtxFunc
 	duration time.Duration
+	retry    value.Value // of timing.RetryPolicy
+	paused   int32       // bool
+	resumeFn func()
+	resumeCh <-chan struct{}
+
+	refreshFn func()
+	refreshCh <-chan struct{}
+}
+
+// Retry configures the module to run the function again with exponential
+// backoff when it outputs an error, in addition to the fixed interval. The
+// delay is reset once the function succeeds.
+func (r *RepeatingModule) Retry(p timing.RetryPolicy) *RepeatingModule {
+	r.retry.Set(p)
+	return r
 }
 
 // Stream starts the module.
 func (r *RepeatingModule) Stream(s bar.Sink) {
 	sch := timing.NewScheduler().Every(r.duration)
+	retry := timing.NewBackoff()
+	failed := false
+	sink := func(o bar.Output) {
+		failed = hasError(o)
+		s(o)
+	}
+	for {
+		if atomic.LoadInt32(&r.paused) == 0 {
+			failed = false
+			runWithContext(r.fn, sink)
+			if p, ok := r.retry.Get().(timing.RetryPolicy); ok && failed {
+				retry.Failed(p)
+			} else {
+				retry.Succeeded()
+			}
+		}
+		select {
+		case <-sch.C:
+		case <-retry.C:
+		case <-r.resumeCh:
+		case <-r.refreshCh:
+		}
+	}
+}
+
+// hasError returns true if any segment of the output has an error.
+func hasError(o bar.Output) bool {
+	if o == nil {
+		return false
+	}
+	for _, seg := range o.Segments() {
+		if seg.GetError() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Refresh runs the function immediately, without changing the interval at
+// which it is run. It has no effect while the module is paused.
+func (r *RepeatingModule) Refresh() {
+	r.refreshFn()
+}
+
+// Pause stops running the function until the module is resumed.
+func (r *RepeatingModule) Pause() {
+	atomic.StoreInt32(&r.paused, 1)
+}
+
+// Resume runs the function immediately, and then continues running it at
+// the original interval.
+func (r *RepeatingModule) Resume() {
+	if atomic.CompareAndSwapInt32(&r.paused, 1, 0) {
+		r.resumeFn()
+	}
+}
+
+var outputType = reflect.TypeOf((*bar.Output)(nil)).Elem()
+
+// FromChan constructs a bar module that displays each value received from the
+// given channel, using the render function to convert it to an output. The
+// channel must be a receivable chan T, and render a func(T) bar.Output. The
+// module finishes when the channel is closed.
+func FromChan(ch interface{}, render interface{}) *ChanModule {
+	c, r := reflect.ValueOf(ch), reflect.ValueOf(render)
+	if c.Kind() != reflect.Chan || c.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic(fmt.Sprintf("FromChan: %T is not a receivable channel", ch))
+	}
+	rt := r.Type()
+	if r.Kind() != reflect.Func || rt.NumIn() != 1 || rt.NumOut() != 1 ||
+		!c.Type().Elem().AssignableTo(rt.In(0)) || rt.Out(0) != outputType {
+		panic(fmt.Sprintf("FromChan: render func must be func(%s) bar.Output, got %T",
+			c.Type().Elem(), render))
+	}
+	return &ChanModule{ch: c, render: r}
+}
+
+// ChanModule represents a bar.Module that displays values from a channel.
+type ChanModule struct {
+	ch     reflect.Value
+	render reflect.Value
+}
+
+// Stream starts the module.
+func (c *ChanModule) Stream(s bar.Sink) {
+	for {
+		v, ok := c.ch.Recv()
+		if !ok {
+			return
+		}
+		out, _ := c.render.Call([]reflect.Value{v})[0].Interface().(bar.Output)
+		s.Output(out)
+	}
+}
+
+// OnFileChange constructs a bar module that runs the given function when
+// the module starts, and again whenever the file at the given path changes.
+// If the file cannot be watched, the module shows an error and can be
+// restarted by clicking on it.
+func OnFileChange(path string, f Func) *FileModule {
+	return &FileModule{path: path, fn: f}
+}
+
+// FileModule represents a bar.Module that runs a function when a file
+// changes.
+type FileModule struct {
+	path string
+	fn   Func
+}
+
+// Stream starts the module.
+func (m *FileModule) Stream(s bar.Sink) {
+	w := file.Watch(m.path)
+	defer w.Unsubscribe()
 	for {
-		r.fn(s)
-		sch.Tick()
+		m.fn(s)
+		select {
+		case <-w.Updates:
+		case err := <-w.Errors:
+			s.Error(err)
+			return
+		}
 	}
 }
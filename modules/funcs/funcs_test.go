@@ -15,7 +15,11 @@
 package funcs
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -23,6 +27,7 @@ import (
 	"barista.run/bar"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
+	"barista.run/timing"
 
 	"github.com/stretchr/testify/require"
 )
@@ -121,3 +126,193 @@ func TestRepeated(t *testing.T) {
 	testBar.NextOutput().AssertText(
 		[]string{"3"}, "Function is called on next tick")
 }
+
+func TestRepeatedPause(t *testing.T) {
+	testBar.New(t)
+	var calls int64
+	module := Every(time.Minute, func(s bar.Sink) {
+		s.Output(outputs.Textf("%d", atomic.AddInt64(&calls, 1)))
+	})
+
+	testBar.Run(module)
+	testBar.NextOutput().AssertText([]string{"1"}, "on start")
+
+	module.Pause()
+	testBar.Tick()
+	testBar.AssertNoOutput("while paused")
+
+	module.Resume()
+	testBar.NextOutput().AssertText([]string{"2"}, "immediately on resume")
+
+	module.Resume()
+	testBar.AssertNoOutput("when not paused")
+
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"3"}, "on next tick")
+}
+
+func TestRepeatedRefresh(t *testing.T) {
+	testBar.New(t)
+	var calls int64
+	module := Every(time.Minute, func(s bar.Sink) {
+		s.Output(outputs.Textf("%d", atomic.AddInt64(&calls, 1)))
+	})
+	start := timing.Now()
+	testBar.Run(module)
+	testBar.NextOutput().AssertText([]string{"1"}, "on start")
+
+	timing.AdvanceBy(40 * time.Second)
+	module.Refresh()
+	testBar.NextOutput().AssertText([]string{"2"}, "on refresh")
+
+	testBar.Tick()
+	require.Equal(t, start.Add(time.Minute), timing.Now(),
+		"refresh does not reset the interval")
+	testBar.NextOutput().AssertText([]string{"3"}, "on next tick")
+
+	module.Pause()
+	module.Refresh()
+	testBar.AssertNoOutput("refresh while paused")
+}
+
+func TestRepeatedWithBackoff(t *testing.T) {
+	testBar.New(t)
+	var calls int64
+	var fail atomic.Value
+	fail.Store(true)
+	module := EveryWithBackoff(time.Hour, func(s bar.Sink) {
+		n := atomic.AddInt64(&calls, 1)
+		if fail.Load().(bool) {
+			s.Error(fmt.Errorf("failed %d", n))
+		} else {
+			s.Output(outputs.Textf("%d", n))
+		}
+	})
+
+	start := timing.Now()
+	testBar.Run(module)
+	testBar.NextOutput().AssertError("on start")
+
+	testBar.Tick()
+	require.Equal(t, start.Add(5*time.Second), timing.Now(), "first retry")
+	testBar.NextOutput().AssertError("on first retry")
+
+	testBar.Tick()
+	require.Equal(t, start.Add(15*time.Second), timing.Now(), "backs off")
+	testBar.NextOutput().AssertError("on second retry")
+
+	fail.Store(false)
+	testBar.Tick()
+	require.Equal(t, start.Add(35*time.Second), timing.Now(), "backs off")
+	testBar.NextOutput().AssertText([]string{"4"}, "on success")
+
+	testBar.Tick()
+	require.Equal(t, start.Add(time.Hour), timing.Now(), "interval after success")
+	testBar.NextOutput().AssertText([]string{"5"})
+
+	fail.Store(true)
+	testBar.Tick()
+	testBar.NextOutput().AssertError("on next tick")
+	testBar.Tick()
+	require.Equal(t, start.Add(2*time.Hour+5*time.Second), timing.Now(),
+		"delay reset after success")
+	testBar.NextOutput().AssertError("on retry")
+}
+
+func TestContextCancelledOnReturn(t *testing.T) {
+	testBar.New(t)
+	cancelled := make(chan struct{})
+	testBar.Run(OnClickWithContext(func(ctx context.Context, s bar.Sink) {
+		go func() {
+			<-ctx.Done()
+			close(cancelled)
+		}()
+		s.Output(outputs.Text("done"))
+	}))
+	testBar.LatestOutput().AssertText([]string{"done"})
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		require.Fail(t, "context not cancelled when function returns")
+	}
+}
+
+func TestContextCancelledOnPause(t *testing.T) {
+	testBar.New(t)
+	PauseTimeout = 10 * time.Millisecond
+	var calls int64
+	module := EveryWithContext(time.Minute, func(ctx context.Context, s bar.Sink) {
+		n := atomic.AddInt64(&calls, 1)
+		if n > 1 {
+			<-ctx.Done()
+			s.Error(ctx.Err())
+			return
+		}
+		s.Output(outputs.Textf("%d", n))
+	})
+	testBar.Run(module)
+	testBar.NextOutput().AssertText([]string{"1"}, "on start")
+
+	testBar.Tick()
+	testBar.AssertNoOutput("while function is running")
+
+	timing.Pause()
+	defer timing.Resume()
+	out := testBar.NextOutput("when paused for too long")
+	require.Equal(t, context.Canceled, out.At(0).Segment().GetError())
+}
+
+func TestFromChan(t *testing.T) {
+	testBar.New(t)
+	ch := make(chan int)
+	testBar.Run(FromChan(ch, func(i int) bar.Output {
+		if i == 0 {
+			return nil
+		}
+		return outputs.Textf("%d", i)
+	}))
+	testBar.AssertNoOutput("until a value is received")
+
+	ch <- 1
+	testBar.NextOutput().AssertText([]string{"1"})
+	ch <- 2
+	testBar.NextOutput().AssertText([]string{"2"})
+	ch <- 0
+	testBar.NextOutput().AssertEmpty("on nil output")
+
+	close(ch)
+	testBar.NextOutput().Expect("module finished on close")
+
+	require.Panics(t, func() { FromChan(1, func(int) bar.Output { return nil }) },
+		"not a channel")
+	require.Panics(t, func() { FromChan(make(chan<- int), func(int) bar.Output { return nil }) },
+		"send-only channel")
+	require.Panics(t, func() { FromChan(ch, func(string) bar.Output { return nil }) },
+		"mismatched type")
+	require.Panics(t, func() { FromChan(ch, func(int) string { return "" }) },
+		"not returning output")
+}
+
+func TestOnFileChange(t *testing.T) {
+	testBar.New(t)
+	dir, err := ioutil.TempDir("", "funcs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "status")
+	require.NoError(t, ioutil.WriteFile(path, []byte("one"), 0644))
+
+	testBar.Run(OnFileChange(path, func(s bar.Sink) {
+		content, err := ioutil.ReadFile(path)
+		if s.Error(err) {
+			return
+		}
+		s.Output(outputs.Text(string(content)))
+	}))
+	testBar.NextOutput().AssertText([]string{"one"}, "on start")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("two"), 0644))
+	testBar.LatestOutput().AssertText([]string{"two"}, "on file change")
+
+	testBar.Tick()
+	testBar.AssertNoOutput("does not poll")
+}
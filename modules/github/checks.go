@@ -0,0 +1,337 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/base/watchers/connectivity"
+	l "barista.run/logging"
+	"barista.run/oauth"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+// CheckState represents the combined state of a set of check runs.
+type CheckState int
+
+// Possible check states, in increasing order of severity.
+const (
+	// ChecksNone means that there are no check runs.
+	ChecksNone CheckState = iota
+	// ChecksSuccess means that all check runs completed successfully.
+	ChecksSuccess
+	// ChecksPending means that some check runs have not completed yet, but
+	// none of the completed ones have failed.
+	ChecksPending
+	// ChecksFailure means that at least one check run has failed.
+	ChecksFailure
+)
+
+// CheckRun represents a single check run, e.g. a CI job.
+type CheckRun struct {
+	Name string
+	// Status is one of "queued", "in_progress", or "completed".
+	Status string
+	// Conclusion is set for completed runs, e.g. "success" or "failure".
+	Conclusion string
+	URL        string
+}
+
+// Failed returns true if the check run completed unsuccessfully.
+func (c CheckRun) Failed() bool {
+	switch c.Conclusion {
+	case "failure", "cancelled", "timed_out", "action_required", "stale":
+		return true
+	}
+	return false
+}
+
+// RefChecks represents the check runs of a branch or pull request.
+type RefChecks struct {
+	// Repo is the repository, as "owner/name".
+	Repo string
+	// Ref is the name of the branch, or "#123" for pull requests.
+	Ref string
+	// SHA is the commit that the check runs are for.
+	SHA string
+	// URL is the web page of the branch or pull request.
+	URL  string
+	Runs []CheckRun
+}
+
+// State returns the combined state of all check runs.
+func (r RefChecks) State() CheckState {
+	if len(r.Runs) == 0 {
+		return ChecksNone
+	}
+	state := ChecksSuccess
+	for _, c := range r.Runs {
+		if c.Failed() {
+			return ChecksFailure
+		}
+		if c.Status != "completed" {
+			state = ChecksPending
+		}
+	}
+	return state
+}
+
+// Failed returns all check runs that have failed.
+func (r RefChecks) Failed() []CheckRun {
+	var failed []CheckRun
+	for _, c := range r.Runs {
+		if c.Failed() {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Checks represents the check runs of all configured branches and pull
+// requests, in the order they were configured.
+type Checks []RefChecks
+
+// State returns the most severe state across all branches and pull requests.
+func (c Checks) State() CheckState {
+	state := ChecksNone
+	for _, r := range c {
+		if s := r.State(); s > state {
+			state = s
+		}
+	}
+	return state
+}
+
+// ref is a configured branch (number == 0) or pull request.
+type ref struct {
+	repo   string
+	branch string
+	number int
+}
+
+func parseRef(spec string) (ref, error) {
+	if i := strings.LastIndex(spec, "#"); i >= 0 {
+		number, err := strconv.Atoi(spec[i+1:])
+		if err == nil && number > 0 && strings.Count(spec[:i], "/") == 1 {
+			return ref{repo: spec[:i], number: number}, nil
+		}
+	}
+	if i := strings.Index(spec, "@"); i >= 0 {
+		if spec[i+1:] != "" && strings.Count(spec[:i], "/") == 1 {
+			return ref{repo: spec[:i], branch: spec[i+1:]}, nil
+		}
+	}
+	return ref{}, fmt.Errorf("Invalid ref %q, expected owner/repo@branch or owner/repo#123", spec)
+}
+
+// ChecksModule represents a GitHub barista module that displays the status of
+// check runs (e.g. CI) for branches and pull requests.
+type ChecksModule struct {
+	config     *oauth.Config
+	refs       []ref
+	refErr     error
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Checks) bar.Output
+}
+
+// NewChecks creates a GitHub module that shows check runs for the given
+// branches ("owner/repo@branch") and pull requests ("owner/repo#123"),
+// using the given clientID and secret.
+func NewChecks(clientID, clientSecret string, refs ...string) *ChecksModule {
+	config := oauth.Register(&oauth2.Config{
+		Endpoint:     github.Endpoint,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"repo"},
+	})
+	m := &ChecksModule{
+		config:    config,
+		scheduler: timing.NewScheduler(),
+	}
+	for _, spec := range refs {
+		r, err := parseRef(spec)
+		if err != nil {
+			m.refErr = err
+			break
+		}
+		m.refs = append(m.refs, r)
+	}
+	l.Register(m, "scheduler", "outputFunc")
+	m.RefreshInterval(time.Minute)
+	m.Output(func(c Checks) bar.Output {
+		out := outputs.Group()
+		for _, r := range c {
+			var status string
+			switch r.State() {
+			case ChecksNone:
+				continue
+			case ChecksSuccess:
+				status = "ok"
+			case ChecksPending:
+				status = "running"
+			case ChecksFailure:
+				status = fmt.Sprintf("%d failed", len(r.Failed()))
+			}
+			out.Append(outputs.Textf("%s %s", r.Ref, status).
+				Urgent(r.State() == ChecksFailure).
+				OnClick(defaultClickHandler(r)))
+		}
+		return out
+	})
+	return m
+}
+
+// Overridden in tests.
+var openURL = func(url string) error {
+	return exec.Command("xdg-open", url).Run()
+}
+
+// defaultClickHandler opens the branch or pull request on left click.
+func defaultClickHandler(r RefChecks) func(bar.Event) {
+	return func(e bar.Event) {
+		if e.Button != bar.ButtonLeft {
+			return
+		}
+		if err := openURL(r.URL); err != nil {
+			l.Log("Failed to open %s: %s", r.URL, err)
+		}
+	}
+}
+
+// Output sets the output format for this module.
+func (m *ChecksModule) Output(outputFunc func(Checks) bar.Output) *ChecksModule {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *ChecksModule) RefreshInterval(interval time.Duration) *ChecksModule {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *ChecksModule) Stream(sink bar.Sink) {
+	if sink.Error(m.refErr) {
+		return
+	}
+	client, _ := m.config.Client()
+	if wrapForTest != nil {
+		wrapForTest(client)
+	}
+	outf := m.outputFunc.Get().(func(Checks) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	ticks, stop := connectivity.Gate(m.scheduler.C)
+	defer stop()
+	checks, err := m.getChecks(client)
+	for {
+		if sink.Error(err) {
+			return
+		}
+		sink.Output(outf(checks))
+		select {
+		case <-nextOutputFunc:
+			outf = m.outputFunc.Get().(func(Checks) bar.Output)
+		case <-ticks:
+			checks, err = m.getChecks(client)
+		}
+	}
+}
+
+type ghCheckRuns struct {
+	CheckRuns []struct {
+		Name       string
+		HeadSHA    string `json:"head_sha"`
+		Status     string
+		Conclusion string
+		HTMLURL    string `json:"html_url"`
+	} `json:"check_runs"`
+}
+
+type ghPull struct {
+	HTMLURL string `json:"html_url"`
+	Head    struct {
+		SHA string
+	}
+}
+
+func (m *ChecksModule) getChecks(client *http.Client) (Checks, error) {
+	checks := Checks{}
+	for _, r := range m.refs {
+		rc := RefChecks{Repo: r.repo}
+		commit := url.PathEscape(r.branch)
+		if r.number > 0 {
+			rc.Ref = fmt.Sprintf("#%d", r.number)
+			pull := ghPull{}
+			path := fmt.Sprintf("/repos/%s/pulls/%d", r.repo, r.number)
+			if err := get(client, path, &pull); err != nil {
+				return nil, err
+			}
+			rc.URL = pull.HTMLURL
+			rc.SHA = pull.Head.SHA
+			commit = pull.Head.SHA
+		} else {
+			rc.Ref = r.branch
+			rc.URL = fmt.Sprintf("https://github.com/%s/tree/%s", r.repo, commit)
+		}
+		runs := ghCheckRuns{}
+		path := fmt.Sprintf("/repos/%s/commits/%s/check-runs?per_page=100", r.repo, commit)
+		if err := get(client, path, &runs); err != nil {
+			return nil, err
+		}
+		for _, c := range runs.CheckRuns {
+			rc.SHA = c.HeadSHA
+			rc.Runs = append(rc.Runs, CheckRun{
+				Name:       c.Name,
+				Status:     c.Status,
+				Conclusion: c.Conclusion,
+				URL:        c.HTMLURL,
+			})
+		}
+		checks = append(checks, rc)
+	}
+	return checks, nil
+}
+
+// get fetches the given API path into out. The oauth client revalidates cached
+// responses using their ETag, and responses that have not changed do not count
+// against the rate limit.
+func get(client *http.Client, path string, out interface{}) error {
+	req, _ := http.NewRequest("GET", "https://api.github.com"+path, nil)
+	req.Header.Add("Accept", "application/vnd.github.v3+json")
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != 200 {
+		return fmt.Errorf("HTTP Status %d", r.StatusCode)
+	}
+	return json.NewDecoder(r.Body).Decode(out)
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	repoResponses   = map[string]string{}
+	repoNotModified int
+	repoMu          sync.Mutex
+)
+
+// serveRepos serves canned responses for /repos/ paths, using the content
+// itself as the ETag.
+func serveRepos(w http.ResponseWriter, r *http.Request) {
+	repoMu.Lock()
+	defer repoMu.Unlock()
+	body, ok := repoResponses[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	etag := fmt.Sprintf(`"%x"`, body)
+	if r.Header.Get("If-None-Match") == etag {
+		repoNotModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Add("ETag", etag)
+	io.WriteString(w, body)
+}
+
+func setRepoResponse(path, body string) {
+	repoMu.Lock()
+	defer repoMu.Unlock()
+	repoResponses[path] = body
+}
+
+func checkRuns(headSHA string, runs ...string) string {
+	var r []string
+	for _, run := range runs {
+		// name:status[:conclusion]
+		parts := strings.Split(run+":", ":")
+		r = append(r, fmt.Sprintf(
+			`{"name":%q,"head_sha":%q,"status":%q,"conclusion":%q,"html_url":"https://ci/%s"}`,
+			parts[0], headSHA, parts[1], parts[2], parts[0]))
+	}
+	return fmt.Sprintf(`{"total_count":%d,"check_runs":[%s]}`,
+		len(runs), strings.Join(r, ","))
+}
+
+func TestChecks(t *testing.T) {
+	testBar.New(t)
+	repoMu.Lock()
+	repoNotModified = 0
+	repoMu.Unlock()
+	setRepoResponse("/repos/foo/bar/commits/main/check-runs",
+		checkRuns("abc", "build:completed:success", "test:in_progress"))
+	setRepoResponse("/repos/foo/bar/pulls/12",
+		`{"html_url":"https://github.com/foo/bar/pull/12","head":{"sha":"def"}}`)
+	setRepoResponse("/repos/foo/bar/commits/def/check-runs",
+		checkRuns("def", "build:completed:failure", "lint:completed:timed_out", "test:queued"))
+	setRepoResponse("/repos/foo/baz/commits/dev/check-runs", checkRuns("123"))
+
+	var opened []string
+	openURL = func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}
+
+	gh := NewChecks("clientid", "clientsecret", "foo/bar@main", "foo/bar#12", "foo/baz@dev")
+	testBar.Run(gh)
+
+	out := testBar.NextOutput("initial")
+	out.AssertText([]string{"main running", "#12 2 failed"})
+	urgent, _ := out.At(1).Segment().IsUrgent()
+	require.True(t, urgent, "on failure")
+	out.At(1).LeftClick()
+	out.At(0).LeftClick()
+	require.Equal(t, []string{
+		"https://github.com/foo/bar/pull/12",
+		"https://github.com/foo/bar/tree/main",
+	}, opened)
+
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"main running", "#12 2 failed"})
+	repoMu.Lock()
+	require.Equal(t, 4, repoNotModified, "conditional requests")
+	repoMu.Unlock()
+
+	setRepoResponse("/repos/foo/bar/commits/main/check-runs",
+		checkRuns("abc", "build:completed:success", "test:completed:skipped"))
+	setRepoResponse("/repos/foo/bar/pulls/12",
+		`{"html_url":"https://github.com/foo/bar/pull/12","head":{"sha":"fed"}}`)
+	setRepoResponse("/repos/foo/bar/commits/fed/check-runs",
+		checkRuns("fed", "build:completed:success"))
+	testBar.Tick()
+	testBar.NextOutput("on update").AssertText([]string{"main ok", "#12 ok"})
+
+	gh.Output(func(c Checks) bar.Output {
+		var refs []string
+		for _, r := range c {
+			refs = append(refs, fmt.Sprintf("%s/%s@%s:%d", r.Repo, r.Ref, r.SHA, r.State()))
+		}
+		return outputs.Textf("%d %s", c.State(), strings.Join(refs, " "))
+	})
+	testBar.NextOutput("on output change").AssertText(
+		[]string{"1 foo/bar/main@abc:1 foo/bar/#12@fed:1 foo/baz/dev@:0"})
+
+	setRepoResponse("/repos/foo/bar/commits/main/check-runs",
+		checkRuns("abc", "build:completed:success", "test:completed:cancelled"))
+	testBar.Tick()
+	testBar.NextOutput("on update").AssertText(
+		[]string{"3 foo/bar/main@abc:3 foo/bar/#12@fed:1 foo/baz/dev@:0"})
+
+	repoMu.Lock()
+	delete(repoResponses, "/repos/foo/bar/pulls/12")
+	repoMu.Unlock()
+	testBar.Tick()
+	err := testBar.NextOutput("on error").AssertError()
+	require.Contains(t, err, "HTTP Status 404")
+}
+
+func TestChecksInvalidRef(t *testing.T) {
+	testBar.New(t)
+	testBar.Run(NewChecks("clientid", "clientsecret", "foo/bar@main", "foo"))
+	err := testBar.NextOutput("invalid ref").AssertError()
+	require.Contains(t, err[0], `Invalid ref "foo"`)
+}
+
+func TestParseRef(t *testing.T) {
+	for spec, expected := range map[string]ref{
+		"foo/bar@main":         {repo: "foo/bar", branch: "main"},
+		"foo/bar@feature/x#1":  {repo: "foo/bar", branch: "feature/x#1"},
+		"foo/bar#12":           {repo: "foo/bar", number: 12},
+		"foo/bar@release@v1.0": {repo: "foo/bar", branch: "release@v1.0"},
+	} {
+		r, err := parseRef(spec)
+		require.NoError(t, err, spec)
+		require.Equal(t, expected, r, spec)
+	}
+	for _, spec := range []string{"foo", "foo/bar", "foo/bar@", "foo/bar#0", "foo#1", "a/b/c@main"} {
+		_, err := parseRef(spec)
+		require.Error(t, err, spec)
+	}
+}
@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package github provides a barista module to show github notifications.
+// Package github provides barista modules to show github notifications, and
+// the status of check runs for branches and pull requests.
 package github // import "barista.run/modules/github"
 
 import (
@@ -24,7 +25,9 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/notifier"
 	"barista.run/base/value"
+	"barista.run/base/watchers/connectivity"
 	"barista.run/oauth"
 	"barista.run/outputs"
 	"barista.run/timing"
@@ -53,10 +56,15 @@ type Module struct {
 	config     *oauth.Config
 	outputFunc value.Value // of func(Notifications) bar.Output
 
-	// Use the poll interval and last modified from the previous response to
-	// control when we next check for notifications.
+	// Use the poll interval, last modified, and etag from the previous
+	// response to control when we next check for notifications. Conditional
+	// requests do not count against the rate limit.
 	scheduler    *timing.Scheduler
 	lastModified string
+	etag         string
+
+	refreshFn func()
+	refreshCh <-chan struct{}
 }
 
 // New creates a GitHub module using the given clientID and secret.
@@ -71,6 +79,7 @@ func New(clientID, clientSecret string) *Module {
 		config:    config,
 		scheduler: timing.NewScheduler(),
 	}
+	m.refreshFn, m.refreshCh = notifier.New()
 	m.Output(func(n Notifications) bar.Output {
 		if n.Total() == 0 {
 			return nil
@@ -80,6 +89,11 @@ func New(clientID, clientSecret string) *Module {
 	return m
 }
 
+// Refresh checks for notifications immediately, without changing the refresh interval.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
 type ghNotification struct {
 	Reason string
 	Unread bool
@@ -97,7 +111,16 @@ func (m *Module) Stream(sink bar.Sink) {
 	outf := m.outputFunc.Get().(func(Notifications) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
+	ticks, stop := connectivity.Gate(m.scheduler.C)
+	defer stop()
 	info, err := m.getNotifications(client)
+	update := func() {
+		i, e := m.getNotifications(client)
+		err = e
+		if e != errCached {
+			info = i
+		}
+	}
 	for {
 		if err != errCached {
 			if sink.Error(err) {
@@ -109,12 +132,10 @@ func (m *Module) Stream(sink bar.Sink) {
 		select {
 		case <-nextOutputFunc:
 			outf = m.outputFunc.Get().(func(Notifications) bar.Output)
-		case <-m.scheduler.C:
-			i, e := m.getNotifications(client)
-			err = e
-			if e != errCached {
-				info = i
-			}
+		case <-ticks:
+			update()
+		case <-m.refreshCh:
+			update()
 		}
 	}
 }
@@ -127,11 +148,15 @@ func (m *Module) getNotifications(client *http.Client) (Notifications, error) {
 	if m.lastModified != "" {
 		req.Header.Add("If-Modified-Since", m.lastModified)
 	}
+	if m.etag != "" {
+		req.Header.Add("If-None-Match", m.etag)
+	}
 	r, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	m.lastModified = r.Header.Get("Last-Modified")
+	m.etag = r.Header.Get("ETag")
 	interval, _ := strconv.ParseInt(r.Header.Get("X-Poll-Interval"), 10, 64)
 	if interval < 10 {
 		interval = 10
@@ -24,9 +24,11 @@ import (
 	"time"
 
 	"barista.run/bar"
+	sharedhttp "barista.run/base/httpclient"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/testing/httpclient"
+	"barista.run/testing/httpserver"
 	"barista.run/timing"
 	"github.com/stretchr/testify/require"
 )
@@ -111,6 +113,22 @@ func TestSimple(t *testing.T) {
 	testBar.NextOutput().AssertText([]string{"GH:4"},
 		"keeps previous value on cached response")
 
+	respondWith(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("ETag", `"abcd"`)
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `[{"reason": "mention", "unread": true}]`)
+	})
+	testBar.Tick()
+	testBar.NextOutput().AssertText([]string{"GH:1"})
+
+	respondWith(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, `"abcd"`, r.Header.Get("If-None-Match"),
+			"ETag header is passed along")
+		w.WriteHeader(http.StatusNotModified)
+	})
+	testBar.Tick()
+	testBar.AssertNoOutput("On 304")
+
 	respondWithSuccess(`[
 {"reason": "mention", "unread": true},
 {"reason": "mention", "unread": false},
@@ -149,13 +167,44 @@ func TestErrors(t *testing.T) {
 	testBar.NextOutput().AssertError("On HTTP Client Error")
 }
 
+func TestRecorded(t *testing.T) {
+	testBar.New(t)
+	// Re-record with go test -record, with a personal access token in
+	// GITHUB_TOKEN.
+	srv := httpserver.Replay("testdata/fixtures", "https://api.github.com", "Authorization")
+	defer srv.Close()
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = "authtoken-placeholder"
+	}
+	oldWrap := wrapForTest
+	defer func() { wrapForTest = oldWrap }()
+	wrapForTest = func(c *http.Client) {
+		httpclient.FreezeOauthToken(c, token)
+		httpclient.Wrap(c, srv.URL)
+	}
+
+	gh := New("clientid", "clientsecret").Output(func(n Notifications) bar.Output {
+		return outputs.Textf("R:%d,M:%d", n["review_requested"], n["mention"])
+	})
+	testBar.Run(gh)
+	testBar.NextOutput().AssertText([]string{"R:2,M:1"},
+		"read notifications are not counted")
+	now := timing.Now()
+	testBar.Tick()
+	require.WithinDuration(t, now.Add(time.Minute), timing.Now(), time.Millisecond,
+		"X-Poll-Interval from recorded response")
+}
+
 func TestMain(m *testing.M) {
+	sharedhttp.TestMode()
 	mux := http.NewServeMux()
 	mux.HandleFunc("/notifications", func(w http.ResponseWriter, r *http.Request) {
 		responseFuncMu.Lock()
 		defer responseFuncMu.Unlock()
 		responseFunc(w, r)
 	})
+	mux.HandleFunc("/repos/", serveRepos)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
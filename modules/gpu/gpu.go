@@ -0,0 +1,208 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gpu provides an i3bar module that shows GPU utilization, memory,
+// temperature, and power draw. AMD GPUs are read from the amdgpu sysfs
+// interface, and NVIDIA GPUs using nvidia-smi.
+package gpu // import "barista.run/modules/gpu"
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+	"github.com/spf13/afero"
+)
+
+// Info represents the current state of a GPU. Values not reported by the
+// GPU or driver are left as zero.
+type Info struct {
+	// Utilization is the percentage of time the GPU was busy.
+	Utilization int
+	MemUsed     unit.Datasize
+	MemTotal    unit.Datasize
+	Temperature unit.Temperature
+	Power       unit.Power
+}
+
+// MemFrac returns the fraction of GPU memory in use.
+func (i Info) MemFrac() float64 {
+	if i.MemTotal == 0 {
+		return 0
+	}
+	return float64(i.MemUsed / i.MemTotal)
+}
+
+// backend reads GPU information from a specific driver.
+type backend interface {
+	read() (Info, error)
+}
+
+// Module represents a bar.Module that displays GPU information.
+type Module struct {
+	backend    backend
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+func newModule(b backend) *Module {
+	m := &Module{backend: b, scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "outputFunc")
+	m.RefreshInterval(3 * time.Second)
+	// Default output is just the utilization.
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("GPU %d%%", i.Utilization)
+	})
+	return m
+}
+
+// AMD constructs a GPU module for the given amdgpu DRM card, e.g. "card0".
+func AMD(card string) *Module {
+	m := newModule(amdBackend(filepath.Join("/sys/class/drm", card, "device")))
+	l.Label(m, card)
+	return m
+}
+
+// NVIDIA constructs a GPU module for the NVIDIA GPU with the given index.
+// It requires nvidia-smi, which is included with the proprietary driver.
+func NVIDIA(index int) *Module {
+	m := newModule(nvidiaBackend(index))
+	l.Labelf(m, "nvidia%d", index)
+	return m
+}
+
+// New constructs a GPU module for the first available amdgpu card, falling
+// back to the first NVIDIA GPU if there are none.
+func New() *Module {
+	cards, _ := afero.Glob(fs, "/sys/class/drm/card*/device/gpu_busy_percent")
+	if len(cards) > 0 {
+		return AMD(filepath.Base(filepath.Dir(filepath.Dir(cards[0]))))
+	}
+	return NVIDIA(0)
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for GPU information.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.backend.read()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if s.Error(err) {
+			return
+		}
+		s.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.C:
+			info, err = m.backend.read()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+var fs = afero.NewOsFs()
+
+func readInt(filename string) (int64, error) {
+	bytes, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(bytes)), 10, 64)
+}
+
+// amdBackend reads from the amdgpu sysfs interface in the given device dir.
+type amdBackend string
+
+func (a amdBackend) read() (Info, error) {
+	var i Info
+	busy, err := readInt(filepath.Join(string(a), "gpu_busy_percent"))
+	if err != nil {
+		return i, err
+	}
+	i.Utilization = int(busy)
+	if used, err := readInt(filepath.Join(string(a), "mem_info_vram_used")); err == nil {
+		i.MemUsed = unit.Datasize(used) * unit.Byte
+	}
+	if total, err := readInt(filepath.Join(string(a), "mem_info_vram_total")); err == nil {
+		i.MemTotal = unit.Datasize(total) * unit.Byte
+	}
+	hwmons, _ := afero.Glob(fs, filepath.Join(string(a), "hwmon", "hwmon*"))
+	if len(hwmons) == 0 {
+		return i, nil
+	}
+	if milliC, err := readInt(filepath.Join(hwmons[0], "temp1_input")); err == nil {
+		i.Temperature = unit.FromCelsius(float64(milliC) / 1000.0)
+	}
+	if microW, err := readInt(filepath.Join(hwmons[0], "power1_average")); err == nil {
+		i.Power = unit.Power(microW) * unit.Microwatt
+	}
+	return i, nil
+}
+
+// nvidiaBackend reads from nvidia-smi for the GPU at the given index.
+type nvidiaBackend int
+
+// nvidiaSmi runs nvidia-smi with the given arguments. Overridden in tests.
+var nvidiaSmi = func(args ...string) ([]byte, error) {
+	return exec.Command("nvidia-smi", args...).Output()
+}
+
+func (n nvidiaBackend) read() (Info, error) {
+	var i Info
+	out, err := nvidiaSmi(
+		fmt.Sprintf("--id=%d", int(n)),
+		"--query-gpu=utilization.gpu,memory.used,memory.total,temperature.gpu,power.draw",
+		"--format=csv,noheader,nounits")
+	if err != nil {
+		return i, err
+	}
+	fields := strings.Split(strings.TrimSpace(string(out)), ",")
+	if len(fields) != 5 {
+		return i, fmt.Errorf("unexpected nvidia-smi output: %q", out)
+	}
+	vals := make([]float64, len(fields))
+	for idx, f := range fields {
+		// Unsupported values are reported as "[N/A]", and left as zero.
+		vals[idx], _ = strconv.ParseFloat(strings.TrimSpace(f), 64)
+	}
+	i.Utilization = int(vals[0])
+	i.MemUsed = unit.Datasize(vals[1]) * unit.Mebibyte
+	i.MemTotal = unit.Datasize(vals[2]) * unit.Mebibyte
+	i.Temperature = unit.FromCelsius(vals[3])
+	i.Power = unit.Power(vals[4]) * unit.Watt
+	return i, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gpu
+
+import (
+	"errors"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+const amdDir = "/sys/class/drm/card1/device/"
+
+func writeAMD(vals map[string]string) {
+	for name, val := range vals {
+		afero.WriteFile(fs, amdDir+name, []byte(val+"\n"), 0644)
+	}
+}
+
+func TestAMD(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	writeAMD(map[string]string{
+		"gpu_busy_percent":            "12",
+		"mem_info_vram_used":          "536870912",
+		"mem_info_vram_total":         "2147483648",
+		"hwmon/hwmon3/temp1_input":    "45000",
+		"hwmon/hwmon3/power1_average": "15250000",
+	})
+
+	gpu := New().Output(func(i Info) bar.Output {
+		return outputs.Textf("%d%% %.2f %.0f℃ %.2fW",
+			i.Utilization, i.MemFrac(), i.Temperature.Celsius(), i.Power.Watts())
+	})
+	testBar.Run(gpu)
+	testBar.NextOutput("on start").AssertText([]string{"12% 0.25 45℃ 15.25W"})
+
+	writeAMD(map[string]string{"gpu_busy_percent": "99"})
+	testBar.AssertNoOutput("until refresh")
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText([]string{"99% 0.25 45℃ 15.25W"})
+
+	fs.Remove(amdDir + "gpu_busy_percent")
+	testBar.Tick()
+	testBar.NextOutput("on error").At(0).AssertError("missing busy percent")
+}
+
+func TestNVIDIA(t *testing.T) {
+	testBar.New(t)
+	var args []string
+	smiOutput := "35, 2048, 8192, 61, 120.50\n"
+	nvidiaSmi = func(a ...string) ([]byte, error) {
+		args = a
+		if smiOutput == "" {
+			return nil, errors.New("nvidia-smi failed")
+		}
+		return []byte(smiOutput), nil
+	}
+
+	gpu := NVIDIA(1)
+	testBar.Run(gpu)
+	testBar.NextOutput("on start").AssertText([]string{"GPU 35%"})
+	require.Equal(t, "--id=1", args[0])
+
+	gpu.Output(func(i Info) bar.Output {
+		return outputs.Textf("%.0f/%.0f MiB %.0f℃ %.1fW",
+			i.MemUsed.Mebibytes(), i.MemTotal.Mebibytes(),
+			i.Temperature.Celsius(), i.Power.Watts())
+	})
+	testBar.NextOutput("on output change").
+		AssertText([]string{"2048/8192 MiB 61℃ 120.5W"})
+
+	smiOutput = "10, 512, 4096, 40, [N/A]"
+	testBar.Tick()
+	testBar.NextOutput("power not supported").
+		AssertText([]string{"512/4096 MiB 40℃ 0.0W"})
+
+	smiOutput = "10, 512"
+	testBar.Tick()
+	testBar.NextOutput("on bad output").At(0).AssertError("unexpected output")
+	_, err := nvidiaBackend(0).read()
+	require.Contains(t, err.Error(), "unexpected nvidia-smi output")
+
+	smiOutput = ""
+	testBar.New(t)
+	testBar.Run(NVIDIA(0))
+	testBar.NextOutput("on failure").At(0).AssertError("nvidia-smi error")
+}
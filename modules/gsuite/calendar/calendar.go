@@ -20,7 +20,9 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/notifier"
 	"barista.run/base/value"
+	"barista.run/base/watchers/connectivity"
 	"barista.run/oauth"
 	"barista.run/outputs"
 	"barista.run/timing"
@@ -92,6 +94,8 @@ type Module struct {
 	oauthConfig *oauth.Config
 	config      value.Value // of config
 	scheduler   *timing.Scheduler
+	refreshFn   func()
+	refreshCh   <-chan struct{}
 	outputFunc  value.Value // of func(EventList) bar.Output
 }
 
@@ -105,6 +109,7 @@ func New(clientConfig []byte) *Module {
 		oauthConfig: oauth.Register(conf),
 		scheduler:   timing.NewScheduler(),
 	}
+	m.refreshFn, m.refreshCh = notifier.New()
 	m.config.Set(config{calendarID: "primary"})
 	m.RefreshInterval(10 * time.Minute)
 	m.TimeWindow(18 * time.Hour)
@@ -134,6 +139,11 @@ func New(clientConfig []byte) *Module {
 	return m
 }
 
+// Refresh fetches the events immediately, without changing the refresh interval.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
 // for tests, to wrap the client in a transport that redirects requests.
 var wrapForTest func(*http.Client)
 
@@ -147,6 +157,8 @@ func (m *Module) Stream(sink bar.Sink) {
 	outf := m.outputFunc.Get().(func(EventList) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
+	ticks, stop := connectivity.Gate(m.scheduler.C)
+	defer stop()
 	conf := m.getConfig()
 	nextConfig, done := m.config.Subscribe()
 	defer done()
@@ -167,7 +179,9 @@ func (m *Module) Stream(sink bar.Sink) {
 		case <-nextConfig:
 			conf = m.getConfig()
 			evts, err = fetch(srv, conf)
-		case <-m.scheduler.C:
+		case <-ticks:
+			evts, err = fetch(srv, conf)
+		case <-m.refreshCh:
 			evts, err = fetch(srv, conf)
 		case <-renderer.C:
 		}
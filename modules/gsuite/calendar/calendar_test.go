@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"barista.run/bar"
+	sharedhttp "barista.run/base/httpclient"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/testing/httpclient"
@@ -261,6 +262,7 @@ func TestErrors(t *testing.T) {
 }
 
 func TestMain(m *testing.M) {
+	sharedhttp.TestMode()
 	mux := http.NewServeMux()
 	mux.HandleFunc("/calendar/v3/calendars/", func(w http.ResponseWriter, r *http.Request) {
 		cal := strings.Split(r.URL.Path, "/")[4]
@@ -20,7 +20,9 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/notifier"
 	"barista.run/base/value"
+	"barista.run/base/watchers/connectivity"
 	"barista.run/oauth"
 	"barista.run/outputs"
 	"barista.run/timing"
@@ -61,6 +63,8 @@ type Module struct {
 	config     *oauth.Config
 	labels     []string
 	scheduler  *timing.Scheduler
+	refreshFn  func()
+	refreshCh  <-chan struct{}
 	outputFunc value.Value // of func(Info) bar.Output
 }
 
@@ -79,6 +83,7 @@ func New(clientConfig []byte, labels ...string) *Module {
 		labels:    labels,
 		scheduler: timing.NewScheduler(),
 	}
+	m.refreshFn, m.refreshCh = notifier.New()
 	m.RefreshInterval(5 * time.Minute)
 	m.Output(func(i Info) bar.Output {
 		if i.TotalUnread() == 0 {
@@ -89,6 +94,11 @@ func New(clientConfig []byte, labels ...string) *Module {
 	return m
 }
 
+// Refresh fetches the thread counts immediately, without changing the refresh interval.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
 // for tests, to wrap the client in a transport that redirects requests.
 var wrapForTest func(*http.Client)
 
@@ -111,6 +121,8 @@ func (m *Module) Stream(sink bar.Sink) {
 	outf := m.outputFunc.Get().(func(Info) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
+	ticks, stop := connectivity.Gate(m.scheduler.C)
+	defer stop()
 	for {
 		if sink.Error(err) {
 			return
@@ -119,7 +131,9 @@ func (m *Module) Stream(sink bar.Sink) {
 		select {
 		case <-nextOutputFunc:
 			outf = m.outputFunc.Get().(func(Info) bar.Output)
-		case <-m.scheduler.C:
+		case <-ticks:
+			i, err = fetch(srv, m.labels, labelIDs)
+		case <-m.refreshCh:
 			i, err = fetch(srv, m.labels, labelIDs)
 		}
 	}
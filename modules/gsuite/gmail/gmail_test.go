@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"barista.run/bar"
+	sharedhttp "barista.run/base/httpclient"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
 	"barista.run/testing/httpclient"
@@ -118,6 +119,7 @@ func TestErrors(t *testing.T) {
 }
 
 func TestMain(m *testing.M) {
+	sharedhttp.TestMode()
 	mux := http.NewServeMux()
 	mux.HandleFunc("/gmail/v1/users/me/labels", func(w http.ResponseWriter, r *http.Request) {
 		labelsMu.Lock()
@@ -0,0 +1,98 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imap
+
+import (
+	"errors"
+
+	"barista.run/oauth"
+
+	"github.com/emersion/go-imap/client"
+	"github.com/zalando/go-keyring"
+)
+
+// Auth provides credentials for an IMAP account.
+type Auth interface {
+	login(c *client.Client, username string) error
+}
+
+type password string
+
+// Password authenticates using a plain-text password. Prefer Keyring to
+// avoid storing passwords in the bar's source code.
+func Password(pass string) Auth {
+	return password(pass)
+}
+
+func (p password) login(c *client.Client, username string) error {
+	return c.Login(username, string(p))
+}
+
+type keyringAuth string
+
+// Keyring authenticates using a password stored in the system keyring
+// (e.g. gnome-keyring), under the given service name and the account's
+// username. For example, with secret-tool:
+//
+//	secret-tool store --label "IMAP" service <service> username <username>
+func Keyring(service string) Auth {
+	return keyringAuth(service)
+}
+
+func (k keyringAuth) login(c *client.Client, username string) error {
+	pass, err := keyring.Get(string(k), username)
+	if err != nil {
+		return err
+	}
+	return c.Login(username, pass)
+}
+
+type oauthAuth struct {
+	config *oauth.Config
+}
+
+// OAuth authenticates using the XOAUTH2 SASL mechanism, with an access token
+// from the given oauth configuration. The token is set up using the same
+// interactive flow as other oauth modules, see oauth.InteractiveSetup.
+func OAuth(config *oauth.Config) Auth {
+	return oauthAuth{config}
+}
+
+func (o oauthAuth) login(c *client.Client, username string) error {
+	if ok, _ := c.SupportAuth("XOAUTH2"); !ok {
+		return errors.New("Server does not support XOAUTH2")
+	}
+	tok, err := o.config.Token()
+	if err != nil {
+		return err
+	}
+	return c.Authenticate(xoauth2{username, tok.AccessToken})
+}
+
+// xoauth2 implements the XOAUTH2 SASL mechanism.
+// See https://developers.google.com/gmail/imap/xoauth2-protocol.
+type xoauth2 struct {
+	username, token string
+}
+
+func (x xoauth2) Start() (string, []byte, error) {
+	return "XOAUTH2", []byte("user=" + x.username + "\x01auth=Bearer " + x.token + "\x01\x01"), nil
+}
+
+func (x xoauth2) Next(challenge []byte) ([]byte, error) {
+	// The server sends an error as a challenge, which must be acknowledged
+	// with an empty response before it fails the authentication.
+	return []byte{}, nil
+}
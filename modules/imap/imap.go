@@ -0,0 +1,238 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package imap provides an i3bar module that shows unread mail counts from
+// IMAP servers. Folders are watched using IMAP IDLE where supported, so the
+// counts are updated as soon as new mail arrives.
+package imap // import "barista.run/modules/imap"
+
+import (
+	"strings"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// Account represents an IMAP account and the folders to watch.
+type Account struct {
+	// Name identifies the account in the Info, e.g. "work".
+	Name string
+	// Addr is the host:port of the IMAP server. TLS is always used, so this
+	// is usually port 993.
+	Addr     string
+	Username string
+	Auth     Auth
+	// Folders to watch for unread mail. Defaults to just "INBOX".
+	Folders []string
+}
+
+func (a Account) folders() []string {
+	if len(a.Folders) == 0 {
+		return []string{"INBOX"}
+	}
+	return a.Folders
+}
+
+// Folder represents the unread count of a single folder.
+type Folder struct {
+	Account string
+	Name    string
+	Unread  int
+}
+
+// Info represents the unread counts of all watched folders.
+type Info struct {
+	Folders []Folder
+}
+
+// TotalUnread returns the number of unread messages across all folders.
+func (i Info) TotalUnread() int {
+	t := 0
+	for _, f := range i.Folders {
+		t += f.Unread
+	}
+	return t
+}
+
+// Unread returns the number of unread messages for the named account.
+func (i Info) Unread(account string) int {
+	t := 0
+	for _, f := range i.Folders {
+		if f.Account == account {
+			t += f.Unread
+		}
+	}
+	return t
+}
+
+// Module represents a bar.Module that displays unread IMAP mail.
+type Module struct {
+	accounts   []Account
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs an IMAP module that watches the given accounts.
+func New(accounts ...Account) *Module {
+	m := &Module{accounts: accounts}
+	var names []string
+	for _, a := range accounts {
+		names = append(names, a.Name)
+	}
+	l.Label(m, strings.Join(names, ","))
+	l.Register(m, "outputFunc")
+	m.Output(func(i Info) bar.Output {
+		if i.TotalUnread() == 0 {
+			return nil
+		}
+		return outputs.Textf("Mail: %d", i.TotalUnread())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+type folderUpdate struct {
+	idx    int
+	unread int
+	err    error
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	var info Info
+	updates := make(chan folderUpdate)
+	stop := make(chan struct{})
+	defer close(stop)
+	for _, a := range m.accounts {
+		for _, f := range a.folders() {
+			info.Folders = append(info.Folders, Folder{Account: a.Name, Name: f})
+			go watch(a, f, len(info.Folders)-1, updates, stop)
+		}
+	}
+	// Wait for all folders to report their initial count.
+	for pending := len(info.Folders); pending > 0; pending-- {
+		u := <-updates
+		if s.Error(u.err) {
+			return
+		}
+		info.Folders[u.idx].Unread = u.unread
+	}
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		s.Output(outputFunc(info))
+		select {
+		case u := <-updates:
+			if s.Error(u.err) {
+				return
+			}
+			// Copy to avoid modifying an Info passed to the output function.
+			info.Folders = append([]Folder(nil), info.Folders...)
+			info.Folders[u.idx].Unread = u.unread
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// dial connects to an IMAP server. Overridden in tests.
+var dial = func(addr string) (*client.Client, error) {
+	return client.DialTLS(addr, nil)
+}
+
+// watch connects to the account and sends the unread count for the folder
+// to updates whenever it might have changed, until stop is closed.
+func watch(a Account, folder string, idx int, updates chan<- folderUpdate, stop <-chan struct{}) {
+	send := func(unread int, err error) bool {
+		select {
+		case updates <- folderUpdate{idx, unread, err}:
+			return err == nil
+		case <-stop:
+			return false
+		}
+	}
+	quit := make(chan struct{})
+	defer close(quit)
+	c, err := dial(a.Addr)
+	if err != nil {
+		send(0, err)
+		return
+	}
+	defer c.Logout()
+	if err := a.Auth.login(c, a.Username); err != nil {
+		send(0, err)
+		return
+	}
+	if _, err := c.Select(folder, true); err != nil {
+		send(0, err)
+		return
+	}
+	// Mailbox updates can arrive at any time, and must always be consumed.
+	notifyFn, changed := notifier.New()
+	clientUpdates := make(chan client.Update)
+	c.Updates = clientUpdates
+	go func() {
+		for {
+			select {
+			case <-clientUpdates:
+				notifyFn()
+			case <-quit:
+				return
+			}
+		}
+	}()
+	for {
+		if !send(unread(c)) {
+			return
+		}
+		stopIdle := make(chan struct{})
+		idleErr := make(chan error, 1)
+		go func() { idleErr <- c.Idle(stopIdle, nil) }()
+		select {
+		case <-changed:
+			close(stopIdle)
+			if err := <-idleErr; err != nil {
+				send(0, err)
+				return
+			}
+		case err := <-idleErr:
+			if err != nil {
+				send(0, err)
+				return
+			}
+		case <-stop:
+			close(stopIdle)
+			<-idleErr
+			return
+		}
+	}
+}
+
+func unread(c *client.Client) (int, error) {
+	ids, err := c.Search(&imap.SearchCriteria{
+		WithoutFlags: []string{imap.SeenFlag},
+	})
+	return len(ids), err
+}
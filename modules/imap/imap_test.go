@@ -0,0 +1,169 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imap
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/server"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackend adds unilateral updates to the memory backend, so that
+// connections in IDLE are notified of new messages.
+type testBackend struct {
+	*memory.Backend
+	updates chan backend.Update
+}
+
+func (b *testBackend) Updates() <-chan backend.Update {
+	return b.updates
+}
+
+func (b *testBackend) addMessage(t *testing.T, folder string, seen bool) {
+	user, err := b.Login(nil, "username", "password")
+	require.NoError(t, err)
+	mbox, err := user.GetMailbox(folder)
+	require.NoError(t, err)
+	var flags []string
+	if seen {
+		flags = append(flags, imap.SeenFlag)
+	}
+	body := bytes.NewBufferString("Subject: test\r\n\r\nHello")
+	require.NoError(t, mbox.CreateMessage(flags, time.Now(), body))
+	status, err := mbox.Status([]imap.StatusItem{imap.StatusMessages})
+	require.NoError(t, err)
+	b.updates <- &backend.MailboxUpdate{
+		Update:        backend.NewUpdate("username", folder),
+		MailboxStatus: status,
+	}
+}
+
+func startServer(t *testing.T) (string, *testBackend) {
+	be := &testBackend{memory.New(), make(chan backend.Update)}
+	user, _ := be.Login(nil, "username", "password")
+	require.NoError(t, user.CreateMailbox("Lists"))
+	s := server.New(be)
+	s.AllowInsecureAuth = true
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l)
+	return l.Addr().String(), be
+}
+
+func init() {
+	dial = func(addr string) (*client.Client, error) {
+		return client.Dial(addr)
+	}
+}
+
+func TestUnread(t *testing.T) {
+	addr, be := startServer(t)
+	testBar.New(t)
+
+	m := New(Account{
+		Name:     "home",
+		Addr:     addr,
+		Username: "username",
+		Auth:     Password("password"),
+		Folders:  []string{"INBOX", "Lists"},
+	})
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d/%d", i.Folders[0].Unread, i.Folders[1].Unread)
+	})
+	testBar.Run(m)
+	testBar.NextOutput("initial counts").AssertText([]string{"0/0"})
+
+	be.addMessage(t, "INBOX", false)
+	testBar.NextOutput("new unread message").AssertText([]string{"1/0"})
+
+	be.addMessage(t, "Lists", false)
+	testBar.NextOutput("new message in other folder").AssertText([]string{"1/1"})
+
+	be.addMessage(t, "Lists", true)
+	testBar.NextOutput("new seen message").AssertText([]string{"1/1"})
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d (%d home)", i.TotalUnread(), i.Unread("home"))
+	})
+	testBar.NextOutput("output func changed").AssertText([]string{"2 (2 home)"})
+}
+
+func TestMultipleAccounts(t *testing.T) {
+	addr1, be1 := startServer(t)
+	addr2, _ := startServer(t)
+	be1.addMessage(t, "INBOX", false)
+	testBar.New(t)
+
+	m := New(
+		Account{Name: "a", Addr: addr1, Username: "username", Auth: Password("password")},
+		Account{Name: "b", Addr: addr2, Username: "username", Auth: Password("password")},
+	)
+	testBar.Run(m)
+	testBar.NextOutput().AssertText([]string{"Mail: 1"})
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, f := range i.Folders {
+			out.Append(outputs.Textf("%s/%s:%d", f.Account, f.Name, f.Unread))
+		}
+		return out
+	})
+	testBar.NextOutput().AssertText([]string{"a/INBOX:1", "b/INBOX:0"})
+}
+
+func TestErrors(t *testing.T) {
+	addr, _ := startServer(t)
+	testBar.New(t)
+
+	testBar.Run(New(Account{
+		Addr: addr, Username: "username", Auth: Password("wrong"),
+	}))
+	testBar.NextOutput("login failure").At(0).AssertError()
+
+	testBar.New(t)
+	testBar.Run(New(Account{
+		Addr: addr, Username: "username", Auth: Password("password"),
+		Folders: []string{"Missing"},
+	}))
+	testBar.NextOutput("missing folder").At(0).AssertError()
+
+	testBar.New(t)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	l.Close()
+	testBar.Run(New(Account{
+		Addr: l.Addr().String(), Username: "username", Auth: Password("password"),
+	}))
+	testBar.NextOutput("connection failure").At(0).AssertError()
+}
+
+func TestXOAuth2(t *testing.T) {
+	mech, ir, err := xoauth2{"user@example.com", "token"}.Start()
+	require.NoError(t, err)
+	require.Equal(t, "XOAUTH2", mech)
+	require.Equal(t, fmt.Sprintf("user=%s\x01auth=Bearer %s\x01\x01",
+		"user@example.com", "token"), string(ir))
+}
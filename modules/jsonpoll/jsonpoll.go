@@ -0,0 +1,154 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsonpoll provides an i3bar module that periodically fetches JSON
+// from a URL, and displays a value extracted from it using a gjson path
+// (see https://github.com/tidwall/gjson/blob/master/SYNTAX.md).
+package jsonpoll // import "barista.run/modules/jsonpoll"
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/httpclient"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	"barista.run/base/watchers/connectivity"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/tidwall/gjson"
+)
+
+// maxResponseSize limits the size of the JSON documents that are read.
+const maxResponseSize = 10 << 20
+
+// Module represents a bar.Module that displays a value from a JSON document.
+type Module struct {
+	url        string
+	path       string
+	scheduler  *timing.Scheduler
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+	headers    value.Value // of http.Header
+	outputFunc value.Value // of func(gjson.Result) bar.Output
+}
+
+// New constructs a module that fetches JSON from the given URL, and extracts
+// the value at the given path. The path "@this" selects the whole document,
+// which can be useful to unmarshal the raw JSON into a struct, or to extract
+// multiple values in the output function.
+func New(url, path string) *Module {
+	m := &Module{url: url, path: path, scheduler: timing.NewScheduler()}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Label(m, url)
+	l.Register(m, "scheduler", "headers", "outputFunc")
+	m.headers.Set(http.Header{})
+	m.RefreshInterval(time.Minute)
+	m.Output(func(r gjson.Result) bar.Output {
+		if !r.Exists() {
+			return nil
+		}
+		return outputs.Text(r.String())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+// The function is called with the result even if the path was not found in
+// the JSON document, which can be checked using Exists().
+func (m *Module) Output(outputFunc func(gjson.Result) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Refresh fetches the JSON document immediately, without changing the refresh interval.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
+// Header adds a header to send with each request, e.g. for authentication.
+func (m *Module) Header(key, value string) *Module {
+	headers := http.Header{}
+	for k, v := range m.headers.Get().(http.Header) {
+		headers[k] = append([]string(nil), v...)
+	}
+	headers.Add(key, value)
+	m.headers.Set(headers)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	result, err := m.fetch()
+	outputFunc := m.outputFunc.Get().(func(gjson.Result) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	ticks, stop := connectivity.Gate(m.scheduler.C)
+	defer stop()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(result))
+		}
+		select {
+		case <-ticks:
+			result, err = m.fetch()
+		case <-m.refreshCh:
+			result, err = m.fetch()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(gjson.Result) bar.Output)
+		}
+	}
+}
+
+var client = httpclient.New()
+
+func (m *Module) fetch() (gjson.Result, error) {
+	req, err := http.NewRequest("GET", m.url, nil)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range m.headers.Get().(http.Header) {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return gjson.Result{}, fmt.Errorf("HTTP Status %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	if !gjson.ValidBytes(body) {
+		return gjson.Result{}, errors.New("Invalid JSON response")
+	}
+	return gjson.GetBytes(body, m.path), nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonpoll
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+type fakeServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	status int
+	body   string
+	auth   []string
+}
+
+func newFakeServer(body string) *fakeServer {
+	f := &fakeServer{status: http.StatusOK, body: body}
+	f.Server = httptest.NewServer(f)
+	return f
+}
+
+func (f *fakeServer) respond(status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status, f.body = status, body
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	w.WriteHeader(f.status)
+	io.WriteString(w, f.body)
+}
+
+func TestModule(t *testing.T) {
+	srv := newFakeServer(`{"status": {"indicator": "minor", "errors": [1, 2, 3]}}`)
+	defer srv.Close()
+
+	testBar.New(t)
+	m := New(srv.URL, "status.indicator")
+	testBar.Run(m)
+	testBar.NextOutput("initial").AssertText([]string{"minor"})
+
+	srv.respond(http.StatusOK, `{"status": {"indicator": "none"}}`)
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"none"})
+
+	srv.respond(http.StatusOK, `{"other": true}`)
+	testBar.Tick()
+	testBar.NextOutput("on missing value").AssertEmpty()
+
+	srv.respond(http.StatusServiceUnavailable, `{"status": {"indicator": "major"}}`)
+	testBar.Tick()
+	err := testBar.NextOutput("on HTTP error").AssertError()
+	require.Equal(t, []string{"HTTP Status 503"}, err)
+
+	srv.respond(http.StatusOK, `{"status": `)
+	testBar.Tick()
+	err = testBar.NextOutput("on invalid JSON").AssertError()
+	require.Equal(t, []string{"Invalid JSON response"}, err)
+
+	srv.respond(http.StatusOK, `{"status": {"indicator": "critical"}}`)
+	testBar.Tick()
+	testBar.NextOutput("on recovery").AssertText([]string{"critical"})
+
+	m.Output(func(r gjson.Result) bar.Output {
+		return outputs.Textf("%s!", r.String()).Urgent(r.String() == "critical")
+	})
+	out := testBar.NextOutput("on output change")
+	out.AssertText([]string{"critical!"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+}
+
+func TestRefresh(t *testing.T) {
+	srv := newFakeServer(`{"count": 1}`)
+	defer srv.Close()
+
+	testBar.New(t)
+	m := New(srv.URL, "count").RefreshInterval(time.Hour)
+	testBar.Run(m)
+	testBar.NextOutput("initial").AssertText([]string{"1"})
+	start := timing.Now()
+
+	srv.respond(http.StatusOK, `{"count": 2}`)
+	m.Refresh()
+	testBar.NextOutput("on refresh").AssertText([]string{"2"})
+	require.Equal(t, start, timing.Now(), "refresh is immediate")
+
+	srv.respond(http.StatusOK, `{"count": 3}`)
+	testBar.Tick()
+	require.Equal(t, start.Add(time.Hour), timing.Now(),
+		"refresh does not reset the interval")
+	testBar.NextOutput("on tick").AssertText([]string{"3"})
+}
+
+func TestWholeDocument(t *testing.T) {
+	srv := newFakeServer(`{"name": "build", "jobs": [{"ok": true}, {"ok": false}]}`)
+	defer srv.Close()
+
+	type status struct {
+		Name string
+		Jobs []struct{ OK bool }
+	}
+	testBar.New(t)
+	m := New(srv.URL, "@this").
+		Header("Authorization", "Bearer token").
+		Output(func(r gjson.Result) bar.Output {
+			var s status
+			if err := json.Unmarshal([]byte(r.Raw), &s); err != nil {
+				return outputs.Error(err)
+			}
+			return outputs.Textf("%s: %d jobs, %d ok", s.Name, len(s.Jobs),
+				r.Get(`jobs.#(ok==true)#`).Get("#").Int())
+		})
+	testBar.Run(m)
+	testBar.NextOutput("initial").AssertText([]string{"build: 2 jobs, 1 ok"})
+
+	srv.mu.Lock()
+	require.Equal(t, []string{"Bearer token"}, srv.auth)
+	srv.mu.Unlock()
+}
+
+func TestInvalidURL(t *testing.T) {
+	testBar.New(t)
+	testBar.Run(New("http://[::1", "foo"))
+	testBar.NextOutput("invalid URL").AssertError()
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keyboard provides an i3bar module that shows the active keyboard
+// layout and the state of the Caps Lock and Num Lock keys, using the XKB
+// extension on X11, or the sway IPC on wayland.
+package keyboard // import "barista.run/modules/keyboard"
+
+import (
+	"os"
+	"strings"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// Info represents the current keyboard state.
+type Info struct {
+	// Layouts contains the names of the configured layouts,
+	// e.g. "English (US)".
+	Layouts []string
+	// Current is the index of the active layout.
+	Current    int
+	CapsLock   bool
+	NumLock    bool
+	controller controller
+}
+
+// Layout returns the name of the active layout.
+func (i Info) Layout() string {
+	if i.Current < 0 || i.Current >= len(i.Layouts) {
+		return ""
+	}
+	return i.Layouts[i.Current]
+}
+
+// SetLayout activates the layout at the given index. Indices wrap around,
+// so that Current+1 is always the next layout.
+func (i Info) SetLayout(index int) {
+	if len(i.Layouts) == 0 || i.controller == nil {
+		return
+	}
+	index %= len(i.Layouts)
+	if index < 0 {
+		index += len(i.Layouts)
+	}
+	if err := i.controller.setLayout(index); err != nil {
+		l.Log("Error setting keyboard layout: %v", err)
+	}
+}
+
+// NextLayout activates the next layout.
+func (i Info) NextLayout() {
+	i.SetLayout(i.Current + 1)
+}
+
+// PreviousLayout activates the previous layout.
+func (i Info) PreviousLayout() {
+	i.SetLayout(i.Current - 1)
+}
+
+// controller changes the active keyboard layout.
+type controller interface {
+	setLayout(index int) error
+}
+
+// connection provides keyboard state from a display server.
+type connection interface {
+	controller
+	// next blocks until the keyboard state changes, and returns the new
+	// state. The first call returns the current state.
+	next() (Info, error)
+	close()
+}
+
+// Module represents a bar.Module that displays the keyboard layout and
+// lock key state.
+type Module struct {
+	connect    func() (connection, error)
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+func newModule(name string, connect func() (connection, error)) *Module {
+	m := &Module{connect: connect}
+	l.Label(m, name)
+	l.Register(m, "outputFunc")
+	// Default output is the layout name, with the lock keys if active.
+	m.Output(func(i Info) bar.Output {
+		parts := []string{i.Layout()}
+		if i.CapsLock {
+			parts = append(parts, "CAPS")
+		}
+		if i.NumLock {
+			parts = append(parts, "NUM")
+		}
+		return outputs.Text(strings.Join(parts, " "))
+	})
+	return m
+}
+
+// New constructs a keyboard module for the current session, using sway if
+// available, or X11 otherwise.
+func New() *Module {
+	if os.Getenv("SWAYSOCK") != "" {
+		return Sway()
+	}
+	return X11()
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// defaultClickHandler cycles through layouts on click or scroll.
+func defaultClickHandler(i Info) func(bar.Event) {
+	return func(e bar.Event) {
+		switch e.Button {
+		case bar.ButtonLeft, bar.ScrollDown, bar.ScrollRight:
+			i.NextLayout()
+		case bar.ButtonRight, bar.ScrollUp, bar.ScrollLeft:
+			i.PreviousLayout()
+		}
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	conn, err := m.connect()
+	if s.Error(err) {
+		return
+	}
+	defer conn.close()
+
+	var info value.ErrorValue
+	nextInfo, done := info.Subscribe()
+	defer done()
+	go func() {
+		for {
+			i, err := conn.next()
+			if info.SetOrError(i, err) {
+				return
+			}
+		}
+	}()
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	<-nextInfo
+	for {
+		i, err := info.Get()
+		if s.Error(err) {
+			return
+		}
+		in := i.(Info)
+		in.controller = conn
+		s.Output(outputs.Group(outputFunc(in)).OnClick(defaultClickHandler(in)))
+		select {
+		case <-nextInfo:
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
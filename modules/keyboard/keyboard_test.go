@@ -0,0 +1,277 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyboard
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/jezek/xgb/xproto"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConn struct {
+	updates chan Info
+	errs    chan error
+	layouts chan int
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		updates: make(chan Info, 10),
+		errs:    make(chan error, 1),
+		layouts: make(chan int, 10),
+	}
+}
+
+func (f *fakeConn) next() (Info, error) {
+	select {
+	case i := <-f.updates:
+		return i, nil
+	case err := <-f.errs:
+		return Info{}, err
+	}
+}
+
+func (f *fakeConn) setLayout(index int) error {
+	f.layouts <- index
+	return nil
+}
+
+func (f *fakeConn) close() {}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	conn := newFakeConn()
+	m := newModule("test", func() (connection, error) { return conn, nil })
+	conn.updates <- Info{Layouts: []string{"English (US)", "German", "French"}}
+	testBar.Run(m)
+	out := testBar.NextOutput("initial")
+	out.AssertText([]string{"English (US)"})
+
+	out.At(0).LeftClick()
+	require.Equal(t, 1, <-conn.layouts)
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	require.Equal(t, 2, <-conn.layouts, "wraps around")
+
+	conn.updates <- Info{Layouts: []string{"English (US)", "German", "French"},
+		Current: 1, CapsLock: true}
+	out = testBar.NextOutput("on update")
+	out.AssertText([]string{"German CAPS"})
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	require.Equal(t, 0, <-conn.layouts)
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	require.Equal(t, 2, <-conn.layouts)
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%d/%d %v %v", i.Current, len(i.Layouts), i.CapsLock, i.NumLock)
+	})
+	testBar.NextOutput("on output func change").AssertText([]string{"1/3 true false"})
+
+	conn.errs <- errors.New("something went wrong")
+	testBar.NextOutput("on error").At(0).AssertError()
+
+	testBar.New(t)
+	m = newModule("test", func() (connection, error) {
+		return nil, errors.New("no display")
+	})
+	testBar.Run(m)
+	testBar.NextOutput("connect error").At(0).AssertError()
+
+	i := Info{}
+	require.Equal(t, "", i.Layout())
+	i.NextLayout() // Should not panic without layouts or controller.
+}
+
+// fakeSway is a minimal sway IPC server.
+type fakeSway struct {
+	listener net.Listener
+	inputs   string
+	commands chan string
+	events   chan net.Conn
+}
+
+func startFakeSway(t *testing.T, inputs string) *fakeSway {
+	dir, err := ioutil.TempDir("", "sway")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "sway.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	os.Setenv("SWAYSOCK", path)
+	f := &fakeSway{l, inputs, make(chan string, 10), make(chan net.Conn, 1)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				os.RemoveAll(dir)
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSway) serve(conn net.Conn) {
+	for {
+		typ, payload, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case swayGetInputs:
+			writeMessage(conn, typ, f.inputs)
+		case swaySubscribe:
+			writeMessage(conn, typ, `{"success":true}`)
+			f.events <- conn
+		case swayRunCommand:
+			f.commands <- string(payload)
+			if string(payload) == "input type:keyboard xkb_switch_layout 2" {
+				writeMessage(conn, typ, `[{"success":false,"error":"bad layout"}]`)
+			} else {
+				writeMessage(conn, typ, `[{"success":true}]`)
+			}
+		}
+	}
+}
+
+func (f *fakeSway) close() {
+	f.listener.Close()
+}
+
+const swayInputEvent = 0x80000015
+
+func TestSway(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	testBar.New(t)
+	f := startFakeSway(t, `[
+		{"identifier":"1:1:Power_Button","type":"switch"},
+		{"identifier":"1:1:AT_Keyboard","type":"keyboard",
+		 "xkb_layout_names":["English (US)","Russian"],
+		 "xkb_active_layout_index":0}
+	]`)
+	defer f.close()
+	afero.WriteFile(fs, "/sys/class/leds/input3::capslock/brightness", []byte("0\n"), 0644)
+	afero.WriteFile(fs, "/sys/class/leds/input3::numlock/brightness", []byte("1\n"), 0644)
+
+	testBar.Run(Sway())
+	out := testBar.NextOutput("initial")
+	out.AssertText([]string{"English (US) NUM"})
+	events := <-f.events
+
+	out.At(0).LeftClick()
+	require.Equal(t, "input type:keyboard xkb_switch_layout 1", <-f.commands)
+	writeMessage(events, swayInputEvent, `{"change":"xkb_layout","input":{
+		"identifier":"1:1:AT_Keyboard","type":"keyboard",
+		"xkb_layout_names":["English (US)","Russian"],
+		"xkb_active_layout_index":1}}`)
+	out = testBar.NextOutput("on layout change")
+	out.AssertText([]string{"Russian NUM"})
+
+	writeMessage(events, swayInputEvent, `{"change":"added","input":{
+		"identifier":"2:2:Mouse","type":"pointer"}}`)
+	testBar.AssertNoOutput("non-keyboard input event")
+
+	afero.WriteFile(fs, "/sys/class/leds/input3::capslock/brightness", []byte("1\n"), 0644)
+	timing.NextTick()
+	out = testBar.NextOutput("on led change")
+	out.AssertText([]string{"Russian CAPS NUM"})
+	timing.NextTick()
+	testBar.AssertNoOutput("without changes")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	require.Equal(t, "input type:keyboard xkb_switch_layout 0", <-f.commands)
+
+	events.Close()
+	testBar.NextOutput("on disconnect").At(0).AssertError()
+
+	// Falls back to X11, which will fail without an X server.
+	testBar.New(t)
+	os.Setenv("SWAYSOCK", "")
+	oldDisplay := os.Getenv("DISPLAY")
+	defer os.Setenv("DISPLAY", oldDisplay)
+	os.Setenv("DISPLAY", "")
+	testBar.Run(New())
+	testBar.NextOutput("no sway").At(0).AssertError()
+
+	testBar.New(t)
+	testBar.Run(Sway())
+	testBar.NextOutput("no sway").At(0).AssertError()
+}
+
+func TestSwayErrors(t *testing.T) {
+	for _, tc := range []string{`not json`, `{"inputs": []}`} {
+		testBar.New(t)
+		f := startFakeSway(t, tc)
+		testBar.Run(Sway())
+		testBar.NextOutput("bad inputs: %s", tc).At(0).AssertError()
+		f.close()
+	}
+}
+
+func TestSetLayoutError(t *testing.T) {
+	f := startFakeSway(t, `[]`)
+	defer f.close()
+	conn, err := connectSway()
+	require.NoError(t, err)
+	defer conn.close()
+	require.Error(t, conn.setLayout(2))
+	require.NoError(t, conn.setLayout(1))
+}
+
+func TestParseGroupNames(t *testing.T) {
+	reply := make([]byte, 32)
+	_, err := parseGroupNames(reply[:20])
+	require.Error(t, err, "short reply")
+
+	atoms, err := parseGroupNames(reply)
+	require.NoError(t, err)
+	require.Empty(t, atoms)
+
+	reply[15] = 0x5 // Groups 1 and 3.
+	_, err = parseGroupNames(reply)
+	require.Error(t, err, "missing names")
+
+	reply = append(reply, 0x10, 0x01, 0, 0, 0x20, 0x02, 0, 0)
+	atoms, err = parseGroupNames(reply)
+	require.NoError(t, err)
+	require.Equal(t, []xproto.Atom{0x110, 0x220}, atoms)
+}
+
+func TestMessages(t *testing.T) {
+	r, w := net.Pipe()
+	go writeMessage(w, swayGetInputs, "[]")
+	typ, payload, err := readMessage(r)
+	require.NoError(t, err)
+	require.Equal(t, uint32(swayGetInputs), typ)
+	require.Equal(t, "[]", string(payload))
+
+	go fmt.Fprint(w, "not-ipc-message")
+	_, _, err = readMessage(r)
+	require.Error(t, err)
+	r.Close()
+	w.Close()
+}
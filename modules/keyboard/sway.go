@@ -0,0 +1,255 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyboard
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"barista.run/timing"
+
+	"github.com/spf13/afero"
+)
+
+// Sway constructs a keyboard module that uses the sway IPC, as described in
+// sway-ipc(7). Since sway does not report the state of the lock keys, they
+// are read from the keyboard LEDs every second.
+func Sway() *Module {
+	return newModule("sway", connectSway)
+}
+
+// sway IPC message types.
+const (
+	swayRunCommand = 0
+	swaySubscribe  = 2
+	swayGetInputs  = 100
+)
+
+var swayMagic = []byte("i3-ipc")
+
+// writeMessage writes a sway IPC message. The IPC uses the native byte order,
+// which is little-endian on all platforms sway runs on in practice.
+func writeMessage(w io.Writer, typ uint32, payload string) error {
+	msg := make([]byte, len(swayMagic)+8+len(payload))
+	n := copy(msg, swayMagic)
+	binary.LittleEndian.PutUint32(msg[n:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(msg[n+4:], typ)
+	copy(msg[n+8:], payload)
+	_, err := w.Write(msg)
+	return err
+}
+
+// readMessage reads a sway IPC message, returning its type and payload.
+func readMessage(r io.Reader) (uint32, []byte, error) {
+	header := make([]byte, len(swayMagic)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(swayMagic)]) != string(swayMagic) {
+		return 0, nil, errors.New("sway: invalid IPC message")
+	}
+	n := len(swayMagic)
+	payload := make([]byte, binary.LittleEndian.Uint32(header[n:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return binary.LittleEndian.Uint32(header[n+4:]), payload, nil
+}
+
+// swayInput represents an input device from the sway IPC.
+type swayInput struct {
+	Type         string   `json:"type"`
+	LayoutNames  []string `json:"xkb_layout_names"`
+	ActiveLayout int      `json:"xkb_active_layout_index"`
+}
+
+type swayConn struct {
+	events   net.Conn
+	commands net.Conn
+	mu       sync.Mutex // for commands
+	inputs   chan swayInput
+	errs     chan error
+	leds     *timing.Scheduler
+	info     Info
+	started  bool
+}
+
+func connectSway() (connection, error) {
+	path := os.Getenv("SWAYSOCK")
+	if path == "" {
+		return nil, errors.New("sway: SWAYSOCK is not set")
+	}
+	c := &swayConn{
+		inputs: make(chan swayInput),
+		errs:   make(chan error, 1),
+	}
+	var err error
+	if c.commands, err = net.Dial("unix", path); err != nil {
+		return nil, err
+	}
+	if c.events, err = net.Dial("unix", path); err != nil {
+		c.commands.Close()
+		return nil, err
+	}
+	var inputs []swayInput
+	err = c.command(swayGetInputs, "", &inputs)
+	if err == nil {
+		err = subscribe(c.events)
+	}
+	if err != nil {
+		c.commands.Close()
+		c.events.Close()
+		return nil, err
+	}
+	for _, in := range inputs {
+		c.updateLayout(in)
+	}
+	c.updateLocks()
+	c.leds = timing.NewScheduler().Every(time.Second)
+	go c.readEvents()
+	return c, nil
+}
+
+// subscribe subscribes to input events.
+func subscribe(conn net.Conn) error {
+	if err := writeMessage(conn, swaySubscribe, `["input"]`); err != nil {
+		return err
+	}
+	_, payload, err := readMessage(conn)
+	if err != nil {
+		return err
+	}
+	var result struct{ Success bool }
+	if err := json.Unmarshal(payload, &result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return errors.New("sway: failed to subscribe to input events")
+	}
+	return nil
+}
+
+// command sends a message on the commands connection, and decodes the reply.
+func (c *swayConn) command(typ uint32, payload string, reply interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := writeMessage(c.commands, typ, payload); err != nil {
+		return err
+	}
+	_, data, err := readMessage(c.commands)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, reply)
+}
+
+func (c *swayConn) readEvents() {
+	for {
+		_, payload, err := readMessage(c.events)
+		if err != nil {
+			c.errs <- err
+			return
+		}
+		var event struct{ Input swayInput }
+		if json.Unmarshal(payload, &event) == nil {
+			c.inputs <- event.Input
+		}
+	}
+}
+
+// updateLayout updates the layouts from a keyboard input device. Other
+// devices are ignored.
+func (c *swayConn) updateLayout(in swayInput) {
+	if in.Type != "keyboard" || len(in.LayoutNames) == 0 {
+		return
+	}
+	c.info.Layouts = in.LayoutNames
+	c.info.Current = in.ActiveLayout
+}
+
+// updateLocks updates the lock key state from the keyboard LEDs.
+func (c *swayConn) updateLocks() {
+	c.info.CapsLock = ledState("capslock")
+	c.info.NumLock = ledState("numlock")
+}
+
+// Overridden in tests.
+var fs = afero.NewOsFs()
+
+// ledState returns true if the named LED is on for any keyboard.
+func ledState(name string) bool {
+	files, _ := afero.Glob(fs, "/sys/class/leds/*::"+name+"/brightness")
+	for _, f := range files {
+		val, err := afero.ReadFile(fs, f)
+		if err == nil && strings.TrimSpace(string(val)) != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *swayConn) next() (Info, error) {
+	if !c.started {
+		c.started = true
+		return c.info, nil
+	}
+	for {
+		last := c.info
+		select {
+		case in := <-c.inputs:
+			c.updateLayout(in)
+		case <-c.leds.C:
+			c.updateLocks()
+		case err := <-c.errs:
+			return Info{}, err
+		}
+		if !reflect.DeepEqual(last, c.info) {
+			return c.info, nil
+		}
+	}
+}
+
+func (c *swayConn) setLayout(index int) error {
+	var results []struct {
+		Success bool
+		Error   string
+	}
+	err := c.command(swayRunCommand,
+		fmt.Sprintf("input type:keyboard xkb_switch_layout %d", index), &results)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if !r.Success {
+			return fmt.Errorf("sway: %s", r.Error)
+		}
+	}
+	return nil
+}
+
+func (c *swayConn) close() {
+	c.leds.Close()
+	c.events.Close()
+	c.commands.Close()
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyboard
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// X11 constructs a keyboard module that uses the XKB extension of the X
+// server given by $DISPLAY.
+func X11() *Module {
+	return newModule("x11", connectX11)
+}
+
+// XKB requests, see the X Keyboard Extension protocol specification.
+// xgb does not include the XKB extension, so the few requests needed here
+// are encoded by hand.
+const (
+	xkbUseExtension   = 0
+	xkbSelectEvents   = 1
+	xkbGetState       = 4
+	xkbLatchLockState = 5
+	xkbGetNames       = 17
+)
+
+// XKB event types and masks.
+const (
+	xkbNewKeyboardNotify = 0
+	xkbStateNotify       = 2
+	xkbNamesNotify       = 6
+
+	xkbEventMask = 1<<xkbNewKeyboardNotify | 1<<xkbStateNotify | 1<<xkbNamesNotify
+)
+
+const (
+	xkbUseCoreKbd    = 0x100
+	xkbGroupNames    = 1 << 12
+	modLock          = 1 << 1 // Caps Lock
+	modMod2          = 1 << 4 // Num Lock, in almost all keymaps
+	xkbExtensionName = "XKEYBOARD"
+)
+
+// xkbEvent is an XKB event. All XKB events share a single event code, and
+// are distinguished by the second byte.
+type xkbEvent []byte
+
+func (e xkbEvent) Bytes() []byte  { return e }
+func (e xkbEvent) String() string { return fmt.Sprintf("XkbEvent(%d)", e[1]) }
+
+var registerEvents sync.Once
+
+type x11Conn struct {
+	conn    *xgb.Conn
+	opcode  byte
+	info    Info
+	started bool
+}
+
+func connectX11() (connection, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, err
+	}
+	c := &x11Conn{conn: conn}
+	if err := c.init(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// request sends an XKB request, and returns the reply if wanted.
+func (c *x11Conn) request(minor byte, body []byte, reply bool) ([]byte, error) {
+	buf := make([]byte, 4+len(body))
+	buf[0] = c.opcode
+	buf[1] = minor
+	xgb.Put16(buf[2:], uint16(len(buf)/4))
+	copy(buf[4:], body)
+	cookie := c.conn.NewCookie(true, reply)
+	c.conn.NewRequest(buf, cookie)
+	if !reply {
+		return nil, cookie.Check()
+	}
+	return cookie.Reply()
+}
+
+func (c *x11Conn) init() error {
+	ext, err := xproto.QueryExtension(c.conn,
+		uint16(len(xkbExtensionName)), xkbExtensionName).Reply()
+	if err != nil {
+		return err
+	}
+	if !ext.Present {
+		return errors.New("X server does not support XKB")
+	}
+	c.opcode = ext.MajorOpcode
+	registerEvents.Do(func() {
+		xgb.NewEventFuncs[int(ext.FirstEvent)] = func(buf []byte) xgb.Event {
+			return xkbEvent(buf)
+		}
+	})
+
+	body := make([]byte, 4)
+	xgb.Put16(body, 1) // Version 1.0
+	reply, err := c.request(xkbUseExtension, body, true)
+	if err != nil {
+		return err
+	}
+	if reply[1] == 0 {
+		return errors.New("X server does not support XKB 1.0")
+	}
+
+	body = make([]byte, 12)
+	xgb.Put16(body, xkbUseCoreKbd)
+	xgb.Put16(body[2:], xkbEventMask) // affectWhich
+	xgb.Put16(body[6:], xkbEventMask) // selectAll
+	if _, err := c.request(xkbSelectEvents, body, false); err != nil {
+		return err
+	}
+
+	if err := c.updateLayouts(); err != nil {
+		return err
+	}
+	body = make([]byte, 4)
+	xgb.Put16(body, xkbUseCoreKbd)
+	state, err := c.request(xkbGetState, body, true)
+	if err != nil {
+		return err
+	}
+	c.updateState(state[11], state[12])
+	return nil
+}
+
+// updateLayouts reads the names of the configured layouts (or "groups", in
+// XKB terms).
+func (c *x11Conn) updateLayouts() error {
+	body := make([]byte, 8)
+	xgb.Put16(body, xkbUseCoreKbd)
+	xgb.Put32(body[4:], xkbGroupNames)
+	reply, err := c.request(xkbGetNames, body, true)
+	if err != nil {
+		return err
+	}
+	atoms, err := parseGroupNames(reply)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, atom := range atoms {
+		name, err := xproto.GetAtomName(c.conn, atom).Reply()
+		if err != nil {
+			return err
+		}
+		names = append(names, name.Name)
+	}
+	c.info.Layouts = names
+	return nil
+}
+
+// parseGroupNames returns the group name atoms from a GetNames reply.
+func parseGroupNames(reply []byte) ([]xproto.Atom, error) {
+	if len(reply) < 32 {
+		return nil, errors.New("XKB: short GetNames reply")
+	}
+	var atoms []xproto.Atom
+	offset := 32
+	// Each set bit in the groupNames mask has a name in the reply.
+	for mask := reply[15]; mask != 0; mask >>= 1 {
+		if mask&1 == 0 {
+			continue
+		}
+		if len(reply) < offset+4 {
+			return nil, errors.New("XKB: short GetNames reply")
+		}
+		atoms = append(atoms, xproto.Atom(xgb.Get32(reply[offset:])))
+		offset += 4
+	}
+	return atoms, nil
+}
+
+// updateState updates the current layout and lock keys from the XKB state.
+func (c *x11Conn) updateState(lockedMods, group byte) {
+	c.info.Current = int(group)
+	c.info.CapsLock = lockedMods&modLock != 0
+	c.info.NumLock = lockedMods&modMod2 != 0
+}
+
+func (c *x11Conn) next() (Info, error) {
+	if !c.started {
+		c.started = true
+		return c.info, nil
+	}
+	for {
+		ev, xerr := c.conn.WaitForEvent()
+		if xerr != nil {
+			return Info{}, xerr
+		}
+		if ev == nil {
+			return Info{}, errors.New("X connection closed")
+		}
+		e, ok := ev.(xkbEvent)
+		if !ok || len(e) < 14 {
+			continue
+		}
+		last := c.info
+		switch e[1] {
+		case xkbStateNotify:
+			c.updateState(e[12], e[13])
+		case xkbNamesNotify, xkbNewKeyboardNotify:
+			if err := c.updateLayouts(); err != nil {
+				return Info{}, err
+			}
+		}
+		if !reflect.DeepEqual(last, c.info) {
+			return c.info, nil
+		}
+	}
+}
+
+func (c *x11Conn) setLayout(index int) error {
+	body := make([]byte, 12)
+	xgb.Put16(body, xkbUseCoreKbd)
+	body[4] = 1 // lockGroup
+	body[5] = byte(index)
+	_, err := c.request(xkbLatchLockState, body, false)
+	return err
+}
+
+func (c *x11Conn) close() {
+	c.conn.Close()
+}
@@ -0,0 +1,347 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kubernetes provides an i3bar module that shows the current
+// kubectl context and namespace, and optionally the health of pods matching
+// a label selector.
+package kubernetes // import "barista.run/modules/kubernetes"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/base/watchers/file"
+	l "barista.run/logging"
+	"barista.run/outputs"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Pod represents the status of a single pod.
+type Pod struct {
+	Name string
+	// Phase is the pod phase, e.g. "Pending", "Running", "Failed".
+	Phase string
+	// Ready is true if all containers in the pod are ready.
+	Ready bool
+	// Restarts is the total number of container restarts.
+	Restarts int
+	// CrashLooping is true if any container is in CrashLoopBackOff.
+	CrashLooping bool
+}
+
+// Info represents the current kubernetes context, and the status of any
+// watched pods.
+type Info struct {
+	Context   string
+	Cluster   string
+	Namespace string
+	// Pods contains the pods matching the selector, sorted by name. It is
+	// always empty if no selector was given.
+	Pods []Pod
+}
+
+// Ready returns the number of ready pods.
+func (i Info) Ready() int {
+	c := 0
+	for _, p := range i.Pods {
+		if p.Ready {
+			c++
+		}
+	}
+	return c
+}
+
+// CrashLooping returns the number of pods with crash-looping containers.
+func (i Info) CrashLooping() int {
+	c := 0
+	for _, p := range i.Pods {
+		if p.CrashLooping {
+			c++
+		}
+	}
+	return c
+}
+
+// Module represents a bar.Module that displays kubernetes information.
+type Module struct {
+	kubeconfig string
+	selector   value.Value // of string
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// Config constructs a kubernetes module that uses the given kubeconfig file.
+func Config(kubeconfig string) *Module {
+	m := &Module{kubeconfig: kubeconfig}
+	l.Label(m, kubeconfig)
+	l.Register(m, "selector", "outputFunc")
+	m.selector.Set("")
+	m.Output(func(i Info) bar.Output {
+		if i.Context == "" {
+			return nil
+		}
+		if len(i.Pods) == 0 {
+			return outputs.Textf("%s:%s", i.Context, i.Namespace)
+		}
+		return outputs.Textf("%s:%s %d/%d",
+			i.Context, i.Namespace, i.Ready(), len(i.Pods)).
+			Urgent(i.CrashLooping() > 0)
+	})
+	return m
+}
+
+// New constructs a kubernetes module that uses the default kubeconfig, which
+// is the first entry in $KUBECONFIG, or ~/.kube/config.
+func New() *Module {
+	if env := filepath.SplitList(os.Getenv("KUBECONFIG")); len(env) > 0 {
+		return Config(env[0])
+	}
+	home, _ := os.UserHomeDir()
+	return Config(filepath.Join(home, ".kube", "config"))
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// WatchPods configures the module to watch pods matching the given label
+// selector (e.g. "app=frontend") in the current context and namespace.
+func (m *Module) WatchPods(selector string) *Module {
+	m.selector.Set(selector)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	w := file.Watch(m.kubeconfig)
+	defer w.Unsubscribe()
+
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextSelector, done := m.selector.Subscribe()
+	defer done()
+
+	info, err := readConfig(m.kubeconfig)
+	if s.Error(err) {
+		return
+	}
+	pods := m.watch(info)
+	defer func() { pods.stop() }()
+
+	for {
+		nextPods := pods.Next()
+		p, err := pods.Get()
+		if s.Error(err) {
+			return
+		}
+		info.Pods, _ = p.([]Pod)
+		s.Output(outputFunc(info))
+		select {
+		case <-w.Updates:
+			newInfo, err := readConfig(m.kubeconfig)
+			if s.Error(err) {
+				return
+			}
+			if newInfo.Context != info.Context || newInfo.Namespace != info.Namespace {
+				pods.stop()
+				pods = m.watch(newInfo)
+			}
+			info = newInfo
+		case err := <-w.Errors:
+			s.Error(err)
+			return
+		case <-nextSelector:
+			pods.stop()
+			pods = m.watch(info)
+		case <-nextPods:
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Contexts       []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+func readConfig(path string) (Info, error) {
+	var i Info
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return i, err
+	}
+	var conf kubeconfig
+	if err := yaml.Unmarshal(bytes, &conf); err != nil {
+		return i, err
+	}
+	i.Context = conf.CurrentContext
+	i.Namespace = "default"
+	for _, c := range conf.Contexts {
+		if c.Name != conf.CurrentContext {
+			continue
+		}
+		i.Cluster = c.Context.Cluster
+		if c.Context.Namespace != "" {
+			i.Namespace = c.Context.Namespace
+		}
+	}
+	return i, nil
+}
+
+// podWatcher tracks the pods matching a selector. The value is updated on
+// every watch event, and errors if the watch terminates.
+type podWatcher struct {
+	value.ErrorValue // of []Pod
+	stop             func()
+}
+
+func (m *Module) watch(i Info) *podWatcher {
+	w := &podWatcher{stop: func() {}}
+	w.Set([]Pod(nil))
+	selector := m.selector.Get().(string)
+	if selector == "" || i.Context == "" {
+		return w
+	}
+	r, err := watchPods(i.Context, i.Namespace, selector)
+	if w.Error(err) {
+		return w
+	}
+	stopped := make(chan struct{})
+	var once sync.Once
+	w.stop = func() {
+		once.Do(func() {
+			close(stopped)
+			r.Close()
+		})
+	}
+	go func() {
+		dec := json.NewDecoder(r)
+		pods := map[string]Pod{}
+		for {
+			var e watchEvent
+			if err := dec.Decode(&e); err != nil {
+				select {
+				case <-stopped:
+				default:
+					w.Error(fmt.Errorf("pod watch terminated: %v", err))
+				}
+				return
+			}
+			p := e.Object.pod()
+			if e.Type == "DELETED" {
+				delete(pods, p.Name)
+			} else {
+				pods[p.Name] = p
+			}
+			list := make([]Pod, 0, len(pods))
+			for _, p := range pods {
+				list = append(list, p)
+			}
+			sort.Slice(list, func(a, b int) bool { return list[a].Name < list[b].Name })
+			w.Set(list)
+		}
+	}()
+	return w
+}
+
+type watchEvent struct {
+	Type   string `json:"type"`
+	Object apiPod `json:"object"`
+}
+
+type apiPod struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Status struct {
+		Phase      string `json:"phase"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+		ContainerStatuses []struct {
+			RestartCount int `json:"restartCount"`
+			State        struct {
+				Waiting *struct {
+					Reason string `json:"reason"`
+				} `json:"waiting"`
+			} `json:"state"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+func (a apiPod) pod() Pod {
+	p := Pod{Name: a.Metadata.Name, Phase: a.Status.Phase}
+	for _, c := range a.Status.Conditions {
+		if c.Type == "Ready" {
+			p.Ready = c.Status == "True"
+		}
+	}
+	for _, c := range a.Status.ContainerStatuses {
+		p.Restarts += c.RestartCount
+		if c.State.Waiting != nil && c.State.Waiting.Reason == "CrashLoopBackOff" {
+			p.CrashLooping = true
+		}
+	}
+	return p
+}
+
+type cmdReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (c cmdReader) Close() error {
+	c.cmd.Process.Kill()
+	return c.cmd.Wait()
+}
+
+// watchPods streams pod watch events as JSON objects. Overridden in tests.
+var watchPods = func(context, namespace, selector string) (io.ReadCloser, error) {
+	cmd := exec.Command("kubectl",
+		"--context", context, "--namespace", namespace,
+		"get", "pods", "--selector", strings.TrimSpace(selector),
+		"--watch", "--output-watch-events", "--output", "json")
+	// Prevent SIGUSR for bar pause/resume from propagating to kubectl.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmdReader{stdout, cmd}, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type watchCall struct {
+	context, namespace, selector string
+	events                       *io.PipeWriter
+}
+
+func mockWatch() chan watchCall {
+	calls := make(chan watchCall, 10)
+	watchPods = func(context, namespace, selector string) (io.ReadCloser, error) {
+		r, w := io.Pipe()
+		calls <- watchCall{context, namespace, selector, w}
+		return r, nil
+	}
+	return calls
+}
+
+func writeConfig(t *testing.T, path, context string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(fmt.Sprintf(`
+apiVersion: v1
+kind: Config
+current-context: %s
+contexts:
+- name: prod
+  context:
+    cluster: prod-cluster
+    namespace: web
+- name: dev
+  context:
+    cluster: minikube
+`, context)), 0644))
+}
+
+func podEvent(typ, name string, ready bool, waiting string) string {
+	status := "False"
+	if ready {
+		status = "True"
+	}
+	return fmt.Sprintf(`{"type": %q, "object": {
+		"metadata": {"name": %q},
+		"status": {
+			"phase": "Running",
+			"conditions": [{"type": "Ready", "status": %q}],
+			"containerStatuses": [{"restartCount": 3, "state": {"waiting": {"reason": %q}}}]
+		}}}`, typ, name, status, waiting)
+}
+
+func TestContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	conf := filepath.Join(dir, "config")
+	writeConfig(t, conf, "prod")
+
+	testBar.New(t)
+	testBar.Run(Config(conf))
+	testBar.NextOutput("on start").AssertText([]string{"prod:web"})
+
+	writeConfig(t, conf, "dev")
+	testBar.Drain(10*time.Millisecond, "on context switch").
+		AssertText([]string{"dev:default"})
+
+	require.NoError(t, os.Remove(conf))
+	testBar.Drain(10*time.Millisecond, "on config removed").At(0).
+		AssertError("kubeconfig removed")
+}
+
+func TestPods(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	conf := filepath.Join(dir, "config")
+	writeConfig(t, conf, "prod")
+	calls := mockWatch()
+
+	testBar.New(t)
+	testBar.Run(Config(conf).WatchPods("app=frontend"))
+	testBar.NextOutput("on start").AssertText([]string{"prod:web"})
+
+	call := <-calls
+	require.Equal(t, "prod", call.context)
+	require.Equal(t, "web", call.namespace)
+	require.Equal(t, "app=frontend", call.selector)
+
+	fmt.Fprintln(call.events, podEvent("ADDED", "fe-1", true, ""))
+	testBar.NextOutput("on pod added").AssertText([]string{"prod:web 1/1"})
+
+	fmt.Fprintln(call.events, podEvent("ADDED", "fe-2", false, "CrashLoopBackOff"))
+	out := testBar.NextOutput("on crash looping pod")
+	out.AssertText([]string{"prod:web 1/2"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent, "urgent when crash looping")
+
+	fmt.Fprintln(call.events, podEvent("DELETED", "fe-2", false, ""))
+	testBar.NextOutput("on pod deleted").AssertText([]string{"prod:web 1/1"})
+
+	writeConfig(t, conf, "dev")
+	newCall := <-calls
+	require.Equal(t, "dev", newCall.context)
+	testBar.Drain(10*time.Millisecond, "on context switch").
+		AssertText([]string{"dev:default"})
+
+	newCall.events.Close()
+	testBar.NextOutput("on watch terminated").At(0).
+		AssertError("error when watch terminates")
+}
+
+func TestPodConversion(t *testing.T) {
+	var e watchEvent
+	dec := []byte(podEvent("MODIFIED", "api", false, "CrashLoopBackOff"))
+	require.NoError(t, json.Unmarshal(dec, &e))
+	require.Equal(t, Pod{
+		Name:         "api",
+		Phase:        "Running",
+		Restarts:     3,
+		CrashLooping: true,
+	}, e.Object.pod())
+}
@@ -60,10 +60,49 @@ func (i Info) AvailFrac() float64 {
 	return float64(i.Available()) / float64(i["MemTotal"])
 }
 
+// SwapUsed returns the amount of swap space in use.
+func (i Info) SwapUsed() unit.Datasize {
+	return i["SwapTotal"] - i["SwapFree"]
+}
+
+// PressureStats represents one line of pressure stall information: the
+// percentage of time that tasks were stalled over the last 10s, 60s, and 300s.
+type PressureStats struct {
+	Avg10, Avg60, Avg300 float64
+	// Total is the total time tasks have been stalled.
+	Total time.Duration
+}
+
+// ResourcePressure represents pressure stall information for a resource.
+// Some is the share of time at least one task was stalled, and Full is the
+// share of time all tasks were stalled simultaneously.
+type ResourcePressure struct {
+	Some, Full PressureStats
+}
+
+// PSI represents pressure stall information for each resource, read from
+// /proc/pressure.
+type PSI struct {
+	Memory, CPU, IO ResourcePressure
+}
+
 // currentInfo stores the last value read by the updater.
 // This allows newly created modules to start with data.
 var currentInfo = new(value.ErrorValue) // of Info
 
+// currentPressure stores the pressure stall information read along with
+// currentInfo.
+var currentPressure value.Value // of PSI
+
+// Pressure returns the pressure stall information (PSI) read along with the
+// latest Info, for use in output functions. All values are zero if PSI is not
+// supported by the kernel.
+func Pressure() PSI {
+	construct()
+	p, _ := currentPressure.Get().(PSI)
+	return p
+}
+
 var once sync.Once
 var updater *timing.Scheduler
 
@@ -73,6 +112,7 @@ func construct() {
 	once.Do(func() {
 		updater = timing.NewScheduler()
 		l.Attach(nil, &currentInfo, "meminfo.currentInfo")
+		l.Attach(nil, &currentPressure, "meminfo.currentPressure")
 		l.Attach(nil, updater, "meminfo.updater")
 		updater.Every(3 * time.Second)
 		update()
@@ -116,6 +156,8 @@ func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 
 // Stream subscribes to meminfo and updates the module's output accordingly.
 func (m *Module) Stream(s bar.Sink) {
+	// Updates are shared, so only stop while all modules are paused.
+	updater.OwnedBy(m)
 	i, err := currentInfo.Get()
 	nextInfo, done := currentInfo.Subscribe()
 	defer done()
@@ -167,5 +209,57 @@ func update() {
 			info[name] = unit.Datasize(intval) * mult
 		}
 	}
+	currentPressure.Set(PSI{
+		Memory: readPressure("memory"),
+		CPU:    readPressure("cpu"),
+		IO:     readPressure("io"),
+	})
 	currentInfo.Set(info)
 }
+
+// readPressure reads pressure stall information from /proc/pressure. Lines
+// are of the form "some avg10=0.00 avg60=0.00 avg300=0.00 total=0".
+func readPressure(resource string) ResourcePressure {
+	var p ResourcePressure
+	bytes, err := afero.ReadFile(fs, "/proc/pressure/"+resource)
+	if err != nil {
+		// Not supported by this kernel.
+		return p
+	}
+	for _, line := range strings.Split(string(bytes), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var stats *PressureStats
+		switch fields[0] {
+		case "some":
+			stats = &p.Some
+		case "full":
+			stats = &p.Full
+		default:
+			continue
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			val, err := strconv.ParseFloat(kv[1], 64)
+			if err != nil {
+				continue
+			}
+			switch kv[0] {
+			case "avg10":
+				stats.Avg10 = val
+			case "avg60":
+				stats.Avg60 = val
+			case "avg300":
+				stats.Avg300 = val
+			case "total":
+				stats.Total = time.Duration(val) * time.Microsecond
+			}
+		}
+	}
+	return p
+}
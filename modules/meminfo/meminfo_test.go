@@ -115,6 +115,47 @@ func TestMeminfo(t *testing.T) {
 	testBar.LatestOutput().Expect("on tick after refresh interval change")
 }
 
+func TestSwapAndPressure(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	shouldReturn(meminfo{
+		"MemAvailable": 2048,
+		"MemTotal":     4096,
+		"SwapTotal":    8192,
+		"SwapFree":     6144,
+	})
+	testBar.New(t)
+	resetForTest()
+
+	m := New().Output(func(i Info) bar.Output {
+		return outputs.Textf("mem %.0f%% / psi %.1f / swap %v",
+			(1-i.AvailFrac())*100,
+			Pressure().Memory.Some.Avg10,
+			i.SwapUsed().Mebibytes())
+	})
+	testBar.Run(m)
+	testBar.NextOutput("on start").AssertText(
+		[]string{"mem 50% / psi 0.0 / swap 2"}, "without PSI support")
+
+	afero.WriteFile(fs, "/proc/pressure/memory", []byte(
+		"some avg10=4.30 avg60=2.10 avg300=0.50 total=123456\n"+
+			"full avg10=1.00 avg60=0.25 avg300=0.00 total=4567\n"), 0644)
+	afero.WriteFile(fs, "/proc/pressure/cpu", []byte(
+		"some avg10=12.00 avg60=bad avg300=3.00 total=99\n"), 0644)
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText(
+		[]string{"mem 50% / psi 4.3 / swap 2"}, "with PSI")
+
+	psi := Pressure()
+	require.Equal(t, ResourcePressure{
+		Some: PressureStats{4.3, 2.1, 0.5, 123456 * time.Microsecond},
+		Full: PressureStats{1.0, 0.25, 0, 4567 * time.Microsecond},
+	}, psi.Memory)
+	require.Equal(t, ResourcePressure{
+		Some: PressureStats{Avg10: 12, Avg300: 3, Total: 99 * time.Microsecond},
+	}, psi.CPU)
+	require.Equal(t, ResourcePressure{}, psi.IO)
+}
+
 func TestErrors(t *testing.T) {
 	fs = afero.NewMemMapFs()
 	testBar.New(t)
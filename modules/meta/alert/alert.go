@@ -0,0 +1,214 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package alert provides a module that "wraps" an existing module and sends a
+desktop notification when its output becomes urgent, e.g. when the battery is
+critical or a systemd unit fails, so that alerts are noticed even when the bar
+is hidden behind a fullscreen window.
+
+Notifications are sent using the freedesktop notification service, and are
+replaced when the module becomes urgent again, and closed when it is no
+longer urgent. For example:
+
+	b := alert.New(battery.All().Output(...)).
+	  Summary("Battery").
+	  Throttle(10 * time.Minute)
+*/
+package alert // import "barista.run/modules/meta/alert"
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/core"
+	l "barista.run/logging"
+	"barista.run/sink"
+	"barista.run/timing"
+
+	godbus "github.com/godbus/dbus/v5"
+)
+
+const (
+	notifyService = "org.freedesktop.Notifications"
+	notifyObject  = "/org/freedesktop/Notifications"
+	notifyIface   = "org.freedesktop.Notifications"
+	// Critical urgency, see the desktop notifications specification.
+	urgencyCritical = byte(2)
+)
+
+// Overridden in tests.
+var busType = dbus.Session
+
+var suppressed int32
+
+// Suppress controls whether notifications are sent by all modules, e.g. to
+// avoid distractions while do-not-disturb is enabled.
+func Suppress(suppress bool) {
+	var val int32
+	if suppress {
+		val = 1
+	}
+	atomic.StoreInt32(&suppressed, val)
+}
+
+// Module wraps a bar.Module and sends notifications when its output becomes
+// urgent.
+type Module struct {
+	wrapped *core.Module
+
+	mu       sync.Mutex
+	summary  string
+	throttle time.Duration
+	suppress func(bar.Segments) bool
+	// Notification state.
+	urgent bool
+	sent   time.Time
+	id     uint32
+}
+
+// New wraps an existing bar.Module, sending a notification each time its
+// output becomes urgent.
+func New(original bar.Module) *Module {
+	m := &Module{
+		wrapped:  core.NewModule(original),
+		summary:  "Alert",
+		throttle: time.Minute,
+	}
+	l.Label(m, l.ID(original))
+	return m
+}
+
+// Summary sets the summary (title) of notifications. The body of
+// notifications is the text of the urgent segments.
+func (m *Module) Summary(summary string) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.summary = summary
+	return m
+}
+
+// Throttle sets the minimum time between notifications from the module.
+// Outputs that become urgent within this duration of the last notification
+// do not send a notification. By default, one notification per minute is
+// allowed.
+func (m *Module) Throttle(throttle time.Duration) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.throttle = throttle
+	return m
+}
+
+// SuppressWhen sets a function that can suppress notifications for specific
+// urgent outputs, e.g. to only notify for some units, or during work hours.
+func (m *Module) SuppressWhen(suppress func(bar.Segments) bool) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.suppress = suppress
+	return m
+}
+
+// Stream sets up the output pipeline to send notifications for urgent output.
+func (m *Module) Stream(s bar.Sink) {
+	m.wrapped.Stream(sink.Func(func(o bar.Segments) {
+		m.update(o)
+		s.Output(o)
+	}))
+}
+
+// Pause pauses the wrapped module, see core.Module.Pause.
+func (m *Module) Pause() {
+	m.wrapped.Pause()
+}
+
+// Resume resumes the wrapped module.
+func (m *Module) Resume() {
+	m.wrapped.Resume()
+}
+
+func (m *Module) update(o bar.Segments) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	body := urgentText(o)
+	urgent := body != ""
+	wasUrgent := m.urgent
+	m.urgent = urgent
+	switch {
+	case !urgent && wasUrgent && m.id != 0:
+		go closeNotification(m.id)
+	case !urgent || wasUrgent:
+	case atomic.LoadInt32(&suppressed) == 1:
+		l.Fine("%s: notifications suppressed", l.ID(m))
+	case m.suppress != nil && m.suppress(o):
+		l.Fine("%s: notification suppressed for %v", l.ID(m), body)
+	case timing.Now().Sub(m.sent) < m.throttle:
+		l.Fine("%s: notification throttled", l.ID(m))
+	default:
+		m.sent = timing.Now()
+		go m.notify(m.summary, body, m.id)
+	}
+}
+
+func (m *Module) notify(summary, body string, replaces uint32) {
+	w := dbus.WatchProperties(busType, notifyService, notifyObject, notifyIface)
+	defer w.Unsubscribe()
+	res, err := w.Call("Notify", "barista", replaces, "dialog-warning",
+		summary, body, []string{},
+		map[string]godbus.Variant{"urgency": godbus.MakeVariant(urgencyCritical)},
+		int32(-1))
+	if err != nil {
+		l.Log("%s: failed to send notification: %v", l.ID(m), err)
+		return
+	}
+	if len(res) > 0 {
+		if id, ok := res[0].(uint32); ok {
+			m.mu.Lock()
+			m.id = id
+			m.mu.Unlock()
+		}
+	}
+}
+
+func closeNotification(id uint32) {
+	w := dbus.WatchProperties(busType, notifyService, notifyObject, notifyIface)
+	defer w.Unsubscribe()
+	w.Call("CloseNotification", id)
+}
+
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+// urgentText returns the plain text of all urgent segments, one per line,
+// or an empty string if the output is not urgent.
+func urgentText(o bar.Segments) string {
+	var texts []string
+	for _, s := range o {
+		if urgent, _ := s.IsUrgent(); !urgent {
+			continue
+		}
+		txt, isPango := s.Content()
+		if isPango {
+			txt = html.UnescapeString(tagRe.ReplaceAllString(txt, ""))
+		}
+		if txt = strings.TrimSpace(txt); txt != "" {
+			texts = append(texts, txt)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
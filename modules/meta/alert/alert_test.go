@@ -0,0 +1,123 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	fakedbus "barista.run/testing/dbus"
+	testModule "barista.run/testing/module"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func assertNotified(t *testing.T, calls <-chan []interface{}, msgAndArgs ...interface{}) []interface{} {
+	select {
+	case args := <-calls:
+		return args
+	case <-time.After(time.Second):
+		require.Fail(t, "no notification", msgAndArgs...)
+	}
+	return nil
+}
+
+func assertNotNotified(t *testing.T, calls <-chan []interface{}, msgAndArgs ...interface{}) {
+	select {
+	case args := <-calls:
+		require.Fail(t, "unexpected notification", "%v: %v", msgAndArgs, args)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAlert(t *testing.T) {
+	busType = dbus.Test
+	bus := fakedbus.New()
+	obj := bus.Service(notifyService).Object(notifyObject, notifyIface)
+	notifications := fakedbus.Record(obj, "Notify", uint32(7))
+	closed := fakedbus.Record(obj, "CloseNotification")
+
+	testBar.New(t)
+	tm := testModule.New(t)
+	m := New(tm).Summary("Battery").Throttle(time.Minute)
+	testBar.Run(m)
+	tm.AssertStarted()
+
+	tm.Output(outputs.Text("50%"))
+	testBar.NextOutput().AssertText([]string{"50%"})
+	assertNotNotified(t, notifications, "when not urgent")
+
+	tm.Output(outputs.Text("5%").Urgent(true))
+	testBar.NextOutput().AssertText([]string{"5%"}, "output passed through")
+	args := assertNotified(t, notifications, "on urgent output")
+	require.Equal(t, "barista", args[0])
+	require.Equal(t, uint32(0), args[1])
+	require.Equal(t, "Battery", args[3])
+	require.Equal(t, "5%", args[4])
+	require.Eventually(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.id == 7
+	}, time.Second, time.Millisecond)
+
+	tm.Output(outputs.Text("4%").Urgent(true))
+	testBar.NextOutput()
+	assertNotNotified(t, notifications, "while still urgent")
+
+	tm.Output(outputs.Text("60%"))
+	testBar.NextOutput()
+	args = assertNotified(t, closed, "when no longer urgent")
+	require.Equal(t, uint32(7), args[0])
+
+	tm.Output(outputs.Text("5%").Urgent(true))
+	testBar.NextOutput()
+	assertNotNotified(t, notifications, "within throttle duration")
+
+	timing.AdvanceBy(time.Minute)
+	tm.Output(outputs.Text("60%"))
+	testBar.NextOutput()
+	<-closed
+	Suppress(true)
+	tm.Output(outputs.Text("5%").Urgent(true))
+	testBar.NextOutput()
+	assertNotNotified(t, notifications, "while suppressed")
+	Suppress(false)
+
+	m.SuppressWhen(func(o bar.Segments) bool {
+		txt, _ := o[0].Content()
+		return txt == "ignored"
+	})
+	tm.Output(outputs.Text("60%"))
+	testBar.NextOutput()
+	tm.Output(outputs.Text("ignored").Urgent(true))
+	testBar.NextOutput()
+	assertNotNotified(t, notifications, "when suppressed by module")
+
+	tm.Output(outputs.Text("60%"))
+	testBar.NextOutput()
+	tm.Output(outputs.Group(
+		outputs.Text("battery"),
+		bar.PangoSegment("<b>1%</b> &amp; falling").Urgent(true),
+	))
+	testBar.NextOutput()
+	args = assertNotified(t, notifications, "on urgent output after throttle")
+	require.Equal(t, uint32(7), args[1], "replaces previous notification")
+	require.Equal(t, "1% & falling", args[4], "only urgent segments, as plain text")
+}
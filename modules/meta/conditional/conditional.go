@@ -0,0 +1,149 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package conditional provides a module that "wraps" an existing module and only
+shows its output while a condition holds. For example, to show a VPN module
+only while the VPN interface exists:
+
+	conditional.New(vpnModule).ShowWhen(func() bool {
+		_, err := net.InterfaceByName("tun0")
+		return err == nil
+	})
+
+The condition can also be a *value.Value of bool, which updates the module
+immediately on changes. The module is paused while hidden, which stops its
+schedulers, see core.Module.Pause.
+*/
+package conditional // import "barista.run/modules/meta/conditional"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/core"
+	l "barista.run/logging"
+	"barista.run/sink"
+	"barista.run/timing"
+)
+
+// condition is a predicate with an optional source of update notifications.
+type condition struct {
+	check     func() bool
+	subscribe func() (<-chan struct{}, func())
+}
+
+// Module wraps a bar.Module and hides its output unless a condition holds.
+type Module struct {
+	wrapped   *core.Module
+	condition value.Value // of condition
+	scheduler *timing.Scheduler
+}
+
+// New wraps an existing bar.Module. The module is always shown until a
+// condition is set.
+func New(original bar.Module) *Module {
+	m := &Module{
+		wrapped:   core.NewModule(original),
+		scheduler: timing.NewScheduler(),
+	}
+	l.Label(m, l.ID(original))
+	l.Register(m, "wrapped", "scheduler")
+	m.ShowWhen(func() bool { return true })
+	m.RefreshInterval(5 * time.Second)
+	return m
+}
+
+// ShowWhen shows the module only while the given function returns true. The
+// function is checked whenever the wrapped module updates, and at the refresh
+// interval.
+func (m *Module) ShowWhen(check func() bool) *Module {
+	m.condition.Set(condition{check: check})
+	return m
+}
+
+// ShowWhenValue shows the module only while the given value holds true. The
+// module is shown or hidden immediately when the value changes.
+func (m *Module) ShowWhenValue(v *value.Value) *Module {
+	m.condition.Set(condition{
+		check: func() bool {
+			b, _ := v.Get().(bool)
+			return b
+		},
+		subscribe: v.Subscribe,
+	})
+	return m
+}
+
+// RefreshInterval configures how often a condition set by ShowWhen is
+// checked.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the wrapped module, and sends its output to the bar only
+// while the condition holds.
+func (m *Module) Stream(s bar.Sink) {
+	outputs := make(chan bar.Segments)
+	go m.wrapped.Stream(sink.Func(func(o bar.Segments) { outputs <- o }))
+
+	cond := m.condition.Get().(condition)
+	nextCondition, done := m.condition.Subscribe()
+	defer done()
+	condUpdates, stop := cond.updates()
+	defer func() { stop() }()
+
+	var out bar.Segments
+	visible := true
+	changed := false
+	for {
+		if v := cond.check(); v != visible {
+			l.Fine("%s visible = %v", l.ID(m), v)
+			visible = v
+			changed = true
+			if visible {
+				m.wrapped.Resume()
+			} else {
+				m.wrapped.Pause()
+			}
+		}
+		if changed {
+			if visible {
+				s.Output(out)
+			} else {
+				s.Output(nil)
+			}
+		}
+		changed = false
+		select {
+		case out = <-outputs:
+			changed = visible
+		case <-m.scheduler.C:
+		case <-condUpdates:
+		case <-nextCondition:
+			stop()
+			cond = m.condition.Get().(condition)
+			condUpdates, stop = cond.updates()
+		}
+	}
+}
+
+func (c condition) updates() (<-chan struct{}, func()) {
+	if c.subscribe == nil {
+		return nil, func() {}
+	}
+	return c.subscribe()
+}
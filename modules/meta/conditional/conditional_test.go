@@ -0,0 +1,99 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conditional
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"barista.run/base/value"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	testModule "barista.run/testing/module"
+)
+
+type pausableModule struct {
+	*testModule.TestModule
+	paused int32
+}
+
+func (p *pausableModule) Pause()  { atomic.StoreInt32(&p.paused, 1) }
+func (p *pausableModule) Resume() { atomic.StoreInt32(&p.paused, 0) }
+
+func TestShowWhen(t *testing.T) {
+	testBar.New(t)
+	original := &pausableModule{TestModule: testModule.New(t)}
+	var show int32
+	m := New(original).ShowWhen(func() bool { return atomic.LoadInt32(&show) == 1 })
+
+	original.AssertNotStarted("on construction")
+	testBar.Run(m)
+	original.AssertStarted("on stream")
+	testBar.NextOutput("on start").AssertEmpty("hidden")
+
+	original.Output(outputs.Text("foo"))
+	testBar.AssertNoOutput("while hidden")
+	if atomic.LoadInt32(&original.paused) != 1 {
+		t.Error("module not paused while hidden")
+	}
+
+	atomic.StoreInt32(&show, 1)
+	testBar.AssertNoOutput("until refresh")
+	testBar.Tick()
+	out := testBar.NextOutput("on refresh")
+	out.AssertText([]string{"foo"}, "last output shown")
+	if atomic.LoadInt32(&original.paused) != 0 {
+		t.Error("module not resumed when shown")
+	}
+
+	out.At(0).LeftClick()
+	original.AssertClicked("clicks passed through")
+
+	original.Output(outputs.Text("bar"))
+	testBar.NextOutput("on update").AssertText([]string{"bar"})
+
+	atomic.StoreInt32(&show, 0)
+	original.Output(outputs.Text("baz"))
+	testBar.NextOutput("on update").AssertEmpty("condition checked on update")
+
+	testBar.Tick()
+	testBar.AssertNoOutput("on refresh if unchanged")
+}
+
+func TestShowWhenValue(t *testing.T) {
+	testBar.New(t)
+	original := testModule.New(t)
+	var show value.Value
+	show.Set(true)
+	m := New(original).ShowWhenValue(&show)
+
+	testBar.Run(m)
+	original.AssertStarted("on stream")
+	original.OutputText("foo")
+	testBar.NextOutput("on update").AssertText([]string{"foo"})
+
+	show.Set(false)
+	testBar.NextOutput("on value change").AssertEmpty()
+
+	show.Set(true)
+	testBar.NextOutput("on value change").AssertText([]string{"foo"})
+
+	m.ShowWhen(func() bool { return false })
+	testBar.NextOutput("on condition change").AssertEmpty()
+
+	show.Set(false)
+	show.Set(true)
+	testBar.AssertNoOutput("after previous value is replaced")
+}
@@ -0,0 +1,288 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mounts provides an i3bar module that shows removable drives, using
+// UDisks2 over DBus to track drives being added, removed, mounted, and
+// unmounted.
+package mounts // import "barista.run/modules/mounts"
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/base/watchers/dbus"
+	"barista.run/format"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/martinlindhe/unit"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	udisks     = "org.freedesktop.UDisks2"
+	udisksPath = "/org/freedesktop/UDisks2"
+	blockIface = udisks + ".Block"
+	fsIface    = udisks + ".Filesystem"
+	driveIface = udisks + ".Drive"
+)
+
+// Device represents a filesystem on a removable drive.
+type Device struct {
+	// Label is the filesystem label, which may be empty.
+	Label string
+	// Device is the path of the block device, e.g. /dev/sdb1.
+	Device string
+	// Drive is the vendor and model of the drive containing the filesystem.
+	Drive string
+	// MountPoint is where the filesystem is mounted, empty if not mounted.
+	MountPoint string
+	// Size is the size of the block device.
+	Size unit.Datasize
+	// Available, Free, and Total are the disk space of the filesystem, and
+	// are only set if it is mounted.
+	Available unit.Datasize
+	Free      unit.Datasize
+	Total     unit.Datasize
+	// Ejectable is true if the drive's media can be ejected.
+	Ejectable bool
+
+	path    godbus.ObjectPath
+	drive   godbus.ObjectPath
+	watcher *dbus.ObjectsWatcher
+}
+
+// Name returns a name for the device, the filesystem label if set,
+// otherwise the name of the block device.
+func (d Device) Name() string {
+	if d.Label != "" {
+		return d.Label
+	}
+	return filepath.Base(d.Device)
+}
+
+// Mounted returns true if the filesystem is mounted.
+func (d Device) Mounted() bool {
+	return d.MountPoint != ""
+}
+
+// Used returns the disk space currently in use.
+func (d Device) Used() unit.Datasize {
+	return d.Total - d.Free
+}
+
+// UsedPct returns the percentage of disk space currently in use.
+func (d Device) UsedPct() int {
+	if d.Total == 0 {
+		return 0
+	}
+	return int(float64(d.Used())/float64(d.Total)*100 + 0.5)
+}
+
+// Unmount unmounts the filesystem.
+func (d Device) Unmount() {
+	if !d.Mounted() || d.watcher == nil {
+		return
+	}
+	_, err := d.watcher.Call(d.path, fsIface+".Unmount", noOptions())
+	if err != nil {
+		l.Log("Failed to unmount %s: %s", d.Device, err)
+	}
+}
+
+// Eject unmounts the filesystem, and then ejects the media or powers off the
+// drive if the media cannot be ejected, so that it can be safely removed.
+func (d Device) Eject() {
+	if d.watcher == nil {
+		return
+	}
+	if d.Mounted() {
+		_, err := d.watcher.Call(d.path, fsIface+".Unmount", noOptions())
+		if err != nil {
+			l.Log("Failed to unmount %s: %s", d.Device, err)
+			return
+		}
+	}
+	method := driveIface + ".PowerOff"
+	if d.Ejectable {
+		method = driveIface + ".Eject"
+	}
+	if _, err := d.watcher.Call(d.drive, method, noOptions()); err != nil {
+		l.Log("Failed to eject %s: %s", d.Device, err)
+	}
+}
+
+func noOptions() map[string]godbus.Variant {
+	return map[string]godbus.Variant{}
+}
+
+// Info represents the removable filesystems known to UDisks.
+type Info struct {
+	// Devices lists all removable filesystems, mounted or not, sorted by
+	// device path.
+	Devices []Device
+}
+
+// Mounted returns only the devices that are currently mounted.
+func (i Info) Mounted() []Device {
+	var r []Device
+	for _, d := range i.Devices {
+		if d.Mounted() {
+			r = append(r, d)
+		}
+	}
+	return r
+}
+
+// Module represents a bar.Module that displays removable drives.
+type Module struct {
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a new mounts module.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "outputFunc")
+	m.RefreshInterval(5 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, d := range i.Mounted() {
+			out.Append(outputs.Textf("%s %s", d.Name(), format.IBytesize(d.Available)).
+				OnClick(defaultClickHandler(d)))
+		}
+		return out
+	})
+	return m
+}
+
+// defaultClickHandler unmounts the device on middle click, and ejects it on
+// right click.
+func defaultClickHandler(d Device) func(bar.Event) {
+	return func(e bar.Event) {
+		switch e.Button {
+		case bar.ButtonMiddle:
+			d.Unmount()
+		case bar.ButtonRight:
+			d.Eject()
+		}
+	}
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for the disk space of
+// mounted filesystems. Drives being added or mounted are reported immediately.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Overridden in tests.
+var busType = dbus.System
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	w := dbus.WatchObjects(busType, udisks, udisksPath)
+	defer w.Unsubscribe()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		s.Output(outputFunc(getInfo(w)))
+		select {
+		case <-w.Updates:
+		case <-m.scheduler.C:
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func getInfo(w *dbus.ObjectsWatcher) Info {
+	objects := w.Get()
+	info := Info{}
+	for path, ifaces := range objects {
+		block, fs := ifaces[blockIface], ifaces[fsIface]
+		if block == nil || fs == nil {
+			continue
+		}
+		if hintSystem, _ := block["HintSystem"].(bool); hintSystem {
+			continue
+		}
+		if hintIgnore, _ := block["HintIgnore"].(bool); hintIgnore {
+			continue
+		}
+		d := Device{path: path, watcher: w}
+		d.Label, _ = block["IdLabel"].(string)
+		d.Device = byteString(block["PreferredDevice"])
+		if d.Device == "" {
+			d.Device = byteString(block["Device"])
+		}
+		if size, ok := block["Size"].(uint64); ok {
+			d.Size = unit.Datasize(size) * unit.Byte
+		}
+		if mountPoints, ok := fs["MountPoints"].([][]byte); ok && len(mountPoints) > 0 {
+			d.MountPoint = byteString(mountPoints[0])
+		}
+		d.drive, _ = block["Drive"].(godbus.ObjectPath)
+		if drive := objects[d.drive][driveIface]; drive != nil {
+			vendor, _ := drive["Vendor"].(string)
+			model, _ := drive["Model"].(string)
+			d.Drive = strings.TrimSpace(vendor + " " + model)
+			d.Ejectable, _ = drive["Ejectable"].(bool)
+		}
+		if d.Mounted() {
+			fillSpace(&d)
+		}
+		info.Devices = append(info.Devices, d)
+	}
+	sort.Slice(info.Devices, func(i, j int) bool {
+		return info.Devices[i].Device < info.Devices[j].Device
+	})
+	return info
+}
+
+// byteString converts a UDisks byte array, which includes a trailing NUL,
+// into a string.
+func byteString(val interface{}) string {
+	b, _ := val.([]byte)
+	return string(bytes.TrimRight(b, "\x00"))
+}
+
+func fillSpace(d *Device) {
+	var statfsT unix.Statfs_t
+	if err := statfs(d.MountPoint, &statfsT); err != nil {
+		l.Log("statfs(%s): %s", d.MountPoint, err)
+		return
+	}
+	mult := unit.Datasize(statfsT.Bsize) * unit.Byte
+	d.Available = unit.Datasize(statfsT.Bavail) * mult
+	d.Free = unit.Datasize(statfsT.Bfree) * mult
+	d.Total = unit.Datasize(statfsT.Blocks) * mult
+}
+
+// To allow tests to mock out statfs.
+var statfs = unix.Statfs
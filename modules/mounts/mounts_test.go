@@ -0,0 +1,225 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mounts
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/martinlindhe/unit"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+type interfaces = map[string]map[string]godbus.Variant
+
+func props(kv ...interface{}) map[string]godbus.Variant {
+	r := map[string]godbus.Variant{}
+	for i := 0; i < len(kv); i += 2 {
+		r[kv[i].(string)] = godbus.MakeVariant(kv[i+1])
+	}
+	return r
+}
+
+func nulTerminated(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+const (
+	sdb1  godbus.ObjectPath = "/org/freedesktop/UDisks2/block_devices/sdb1"
+	sdc1  godbus.ObjectPath = "/org/freedesktop/UDisks2/block_devices/sdc1"
+	stick godbus.ObjectPath = "/org/freedesktop/UDisks2/drives/Stick"
+	cdrom godbus.ObjectPath = "/org/freedesktop/UDisks2/drives/CDROM"
+)
+
+func usbStick(mountPoints ...string) interfaces {
+	mp := [][]byte{}
+	for _, m := range mountPoints {
+		mp = append(mp, nulTerminated(m))
+	}
+	return interfaces{
+		blockIface: props(
+			"Device", nulTerminated("/dev/sdb1"),
+			"IdLabel", "STICK",
+			"Size", uint64(8e9),
+			"Drive", stick,
+			"HintSystem", false,
+		),
+		fsIface: props("MountPoints", mp),
+	}
+}
+
+var statfsMu sync.Mutex
+
+func mockStatfs(path string, st *unix.Statfs_t) error {
+	statfsMu.Lock()
+	defer statfsMu.Unlock()
+	if !strings.HasPrefix(path, "/media/") {
+		return os.ErrNotExist
+	}
+	*st = unix.Statfs_t{Bsize: 1024, Blocks: 2048, Bfree: 1024, Bavail: 512}
+	return nil
+}
+
+func TestMounts(t *testing.T) {
+	statfs = mockStatfs
+	bus := dbus.SetupTestBus()
+	busType = dbus.Test
+	srv := bus.RegisterService(udisks)
+	root := srv.Object(udisksPath, udisks)
+	root.On("org.freedesktop.DBus.ObjectManager.GetManagedObjects",
+		func(...interface{}) ([]interface{}, error) {
+			return []interface{}{map[godbus.ObjectPath]interfaces{
+				"/org/freedesktop/UDisks2/block_devices/sda1": {
+					blockIface: props("Device", nulTerminated("/dev/sda1"),
+						"HintSystem", true),
+					fsIface: props("MountPoints", [][]byte{nulTerminated("/")}),
+				},
+				"/org/freedesktop/UDisks2/Manager": {
+					udisks + ".Manager": props("Version", "2.8.4"),
+				},
+				stick: {
+					driveIface: props("Vendor", "Acme", "Model", "Stick",
+						"Ejectable", false),
+				},
+				sdb1: usbStick(),
+			}}, nil
+		})
+
+	testBar.New(t)
+	m := New()
+	testBar.Run(m)
+	testBar.NextOutput("initial").AssertEmpty("not mounted")
+
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, d := range i.Devices {
+			out.Append(outputs.Textf("%s (%s) %s %v",
+				d.Name(), d.Drive, d.Device, d.Mounted()))
+		}
+		return out
+	})
+	testBar.NextOutput("on output change").AssertText(
+		[]string{"STICK (Acme Stick) /dev/sdb1 false"})
+
+	m.Output(func(i Info) bar.Output {
+		out := outputs.Group()
+		for _, d := range i.Mounted() {
+			out.Append(outputs.Textf("%s %s %d%%",
+				d.Name(), d.MountPoint, d.UsedPct()))
+		}
+		return out
+	})
+	testBar.NextOutput("on output change").AssertEmpty()
+
+	fs := srv.Object(sdb1, fsIface)
+	fs.SetProperty("MountPoints", [][]byte{nulTerminated("/media/STICK")})
+	testBar.NextOutput("on mount").AssertText([]string{"STICK /media/STICK 50%"})
+
+	root.Emit("org.freedesktop.DBus.ObjectManager.InterfacesAdded", cdrom,
+		interfaces{driveIface: props("Vendor", "ACME", "Model", "DVD-RW",
+			"Ejectable", true)})
+	testBar.NextOutput("on drive added")
+	root.Emit("org.freedesktop.DBus.ObjectManager.InterfacesAdded", sdc1,
+		interfaces{
+			blockIface: props("Device", nulTerminated("/dev/sr0"),
+				"Drive", cdrom, "Size", uint64(4e9)),
+			fsIface: props("MountPoints", [][]byte{nulTerminated("/media/cdrom")}),
+		})
+	testBar.NextOutput("on disc inserted").AssertText([]string{
+		"STICK /media/STICK 50%",
+		"sr0 /media/cdrom 50%",
+	})
+
+	root.Emit("org.freedesktop.DBus.ObjectManager.InterfacesRemoved", sdc1,
+		[]string{blockIface, fsIface})
+	testBar.NextOutput("on disc removed").AssertText([]string{"STICK /media/STICK 50%"})
+
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"STICK /media/STICK 50%"})
+}
+
+func TestActions(t *testing.T) {
+	statfs = mockStatfs
+	bus := dbus.SetupTestBus()
+	busType = dbus.Test
+	srv := bus.RegisterService(udisks)
+	srv.Object(udisksPath, udisks).On(
+		"org.freedesktop.DBus.ObjectManager.GetManagedObjects",
+		func(...interface{}) ([]interface{}, error) {
+			return []interface{}{map[godbus.ObjectPath]interfaces{
+				stick: {driveIface: props("Ejectable", false)},
+				sdb1:  usbStick("/media/STICK"),
+				cdrom: {driveIface: props("Ejectable", true)},
+				sdc1: {
+					blockIface: props("Device", nulTerminated("/dev/sr0"), "Drive", cdrom),
+					fsIface:    props("MountPoints", [][]byte{}),
+				},
+			}}, nil
+		})
+
+	calls := make(chan string, 10)
+	recordCalls := func(path godbus.ObjectPath, iface string) {
+		srv.Object(path, iface).OnElse(
+			func(method string, args ...interface{}) ([]interface{}, error) {
+				calls <- string(path) + " " + method
+				return nil, nil
+			})
+	}
+	recordCalls(sdb1, fsIface)
+	recordCalls(stick, driveIface)
+	recordCalls(cdrom, driveIface)
+
+	testBar.New(t)
+	testBar.Run(New())
+	out := testBar.NextOutput("initial")
+	out.AssertText([]string{"STICK 512 KiB"})
+
+	out.At(0).LeftClick()
+	out.At(0).Click(bar.Event{Button: bar.ButtonMiddle})
+	require.Equal(t, string(sdb1)+" "+fsIface+".Unmount", <-calls)
+	require.Empty(t, calls, "only middle click unmounts")
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	require.Equal(t, string(sdb1)+" "+fsIface+".Unmount", <-calls)
+	require.Equal(t, string(stick)+" "+driveIface+".PowerOff", <-calls)
+
+	var i Info
+	m := New().Output(func(in Info) bar.Output {
+		i = in
+		return nil
+	})
+	testBar.New(t)
+	testBar.Run(m)
+	testBar.NextOutput("info")
+	require.Len(t, i.Devices, 2)
+	require.Equal(t, unit.Datasize(2*unit.Mebibyte), i.Devices[0].Total)
+	require.Equal(t, unit.Datasize(unit.Mebibyte), i.Devices[0].Used())
+
+	i.Devices[1].Unmount()
+	require.Empty(t, calls, "not mounted")
+	i.Devices[1].Eject()
+	require.Equal(t, string(cdrom)+" "+driveIface+".Eject", <-calls)
+
+	Device{}.Eject() // Should not panic without a watcher.
+}
@@ -16,6 +16,9 @@
 package netspeed // import "barista.run/modules/netspeed"
 
 import (
+	"net"
+	"os"
+	"path/filepath"
 	"time"
 
 	"barista.run/bar"
@@ -51,25 +54,61 @@ func (s Speeds) Connected() bool {
 // Module represents a netspeed bar module. It supports setting the output
 // format, click handler, and update frequency.
 type Module struct {
-	iface      string
+	links      func() ([]netlink.Link, error)
 	scheduler  *timing.Scheduler
 	outputFunc value.Value // of func(Speeds) bar.Output
 }
 
-// New constructs an instance of the netspeed module for the given interface.
-func New(iface string) *Module {
+func newModule(links func() ([]netlink.Link, error)) *Module {
 	m := &Module{
-		iface:     iface,
+		links:     links,
 		scheduler: timing.NewScheduler(),
 	}
-	l.Label(m, iface)
 	l.Register(m, "scheduler", "outputFunc")
 	m.RefreshInterval(3 * time.Second)
-	// Default output is just the up and down speeds in SI.
+	// Default output is just the up and down speeds in IEC units.
 	m.Output(func(s Speeds) bar.Output {
 		return outputs.Textf("%s up | %s down",
-			format.IByterate(s.Tx), format.IByterate(s.Rx))
+			format.IRate(s.Tx), format.IRate(s.Rx))
+	})
+	return m
+}
+
+// New constructs an instance of the netspeed module for the given interface.
+func New(iface string) *Module {
+	m := newModule(func() ([]netlink.Link, error) {
+		link, err := linkByName(iface)
+		if err != nil {
+			return nil, err
+		}
+		return []netlink.Link{link}, nil
 	})
+	l.Label(m, iface)
+	return m
+}
+
+// All constructs an instance of the netspeed module that aggregates traffic
+// across all physical network interfaces. Loopback and virtual interfaces
+// (bridges, veth pairs, VPN tunnels, and so on) are excluded, since their
+// traffic is either local or also counted on a physical interface. Interfaces
+// that appear or disappear between updates are counted from the next update
+// onwards. The aggregated speeds are considered connected if any interface is.
+func All() *Module {
+	m := newModule(func() ([]netlink.Link, error) {
+		links, err := linkList()
+		if err != nil {
+			return nil, err
+		}
+		var physical []netlink.Link
+		for _, link := range links {
+			if link.Attrs().Flags&net.FlagLoopback == 0 &&
+				hasDevice(link.Attrs().Name) {
+				physical = append(physical, link)
+			}
+		}
+		return physical, nil
+	})
+	l.Label(m, "all")
 	return m
 }
 
@@ -89,11 +128,19 @@ func (m *Module) RefreshInterval(interval time.Duration) *Module {
 
 // For tests.
 var linkByName = netlink.LinkByName
+var linkList = netlink.LinkList
+
+// hasDevice returns true if a link is backed by a device, i.e. it is a
+// physical interface and not a virtual one. Overridden in tests.
+var hasDevice = func(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/class/net", name, "device"))
+	return err == nil
+}
 
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	lastRead := timing.Now()
-	lastRx, lastTx, _, err := linkRxTxState(m.iface)
+	lastCounters, err := m.counters()
 	if s.Error(err) {
 		return
 	}
@@ -111,34 +158,58 @@ func (m *Module) Stream(s bar.Sink) {
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(Speeds) bar.Output)
 		case <-m.scheduler.C:
-			rx, tx, state, err := linkRxTxState(m.iface)
+			counters, err := m.counters()
 			if s.Error(err) {
 				return
 			}
 			now := timing.Now()
 			duration := now.Sub(lastRead).Seconds()
 
+			var rx, tx uint64
+			speeds.state = 0
+			for name, c := range counters {
+				if c.state > speeds.state {
+					speeds.state = c.state
+				}
+				last, ok := lastCounters[name]
+				if !ok || c.rx < last.rx || c.tx < last.tx {
+					// New interface, or counters were reset.
+					continue
+				}
+				rx += c.rx - last.rx
+				tx += c.tx - last.tx
+			}
+
 			speeds.available = true
-			speeds.Rx = unit.Datarate(float64(rx-lastRx)/duration) * unit.BytePerSecond
-			speeds.Tx = unit.Datarate(float64(tx-lastTx)/duration) * unit.BytePerSecond
-			speeds.state = state
+			speeds.Rx = unit.Datarate(float64(rx)/duration) * unit.BytePerSecond
+			speeds.Tx = unit.Datarate(float64(tx)/duration) * unit.BytePerSecond
 
 			lastRead = now
-			lastRx = rx
-			lastTx = tx
+			lastCounters = counters
 		}
 	}
 }
 
-func linkRxTxState(iface string) (rx, tx uint64, state netlink.LinkOperState, err error) {
-	var link netlink.Link
-	link, err = linkByName(iface)
+// linkCounters stores the traffic counters and state of a single link.
+type linkCounters struct {
+	rx, tx uint64
+	state  netlink.LinkOperState
+}
+
+func (m *Module) counters() (map[string]linkCounters, error) {
+	links, err := m.links()
 	if err != nil {
-		return
+		return nil, err
+	}
+	counters := map[string]linkCounters{}
+	for _, link := range links {
+		attrs := link.Attrs()
+		c := linkCounters{state: attrs.OperState}
+		if stats := attrs.Statistics; stats != nil {
+			c.rx = stats.RxBytes
+			c.tx = stats.TxBytes
+		}
+		counters[attrs.Name] = c
 	}
-	state = link.Attrs().OperState
-	linkStats := link.Attrs().Statistics
-	rx = linkStats.RxBytes
-	tx = linkStats.TxBytes
-	return
+	return counters, nil
 }
@@ -16,6 +16,7 @@ package netspeed
 
 import (
 	"fmt"
+	"net"
 	"sync"
 	"testing"
 	"time"
@@ -49,6 +50,7 @@ func removeLink(name string) {
 func setLink(name string, stats netlink.LinkAttrs) {
 	ifacesLock.Lock()
 	defer ifacesLock.Unlock()
+	stats.Name = name
 	ifaces[name] = testLink(stats)
 }
 
@@ -69,6 +71,22 @@ func init() {
 		}
 		return link, nil
 	}
+	linkList = func() ([]netlink.Link, error) {
+		ifacesLock.Lock()
+		defer ifacesLock.Unlock()
+		var links []netlink.Link
+		for _, link := range ifaces {
+			links = append(links, link)
+		}
+		return links, nil
+	}
+	hasDevice = func(name string) bool {
+		switch name {
+		case "docker0", "veth0", "tun0":
+			return false
+		}
+		return true
+	}
 }
 
 func TestNetspeed(t *testing.T) {
@@ -237,9 +255,92 @@ func TestErrors(t *testing.T) {
 	})
 	testBar.Tick()
 	testBar.NextOutput().AssertText(
-		[]string{"2.0 KiB/s up | 4.0 KiB/s down"}, "on tick")
+		[]string{"2.00 KiB/s up | 4.00 KiB/s down"}, "on tick")
 
 	removeLink("if0")
 	testBar.Tick()
 	testBar.NextOutput().AssertError("on tick after losing interface")
 }
+
+func TestAll(t *testing.T) {
+	testBar.New(t)
+	ifacesLock.Lock()
+	ifaces = make(map[string]testLink)
+	ifacesLock.Unlock()
+
+	setLink("lo", netlink.LinkAttrs{
+		OperState:  0,
+		Flags:      net.FlagLoopback,
+		Statistics: &netlink.LinkStatistics{RxBytes: 0, TxBytes: 0},
+	})
+	setLink("eth0", netlink.LinkAttrs{
+		OperState:  2,
+		Statistics: &netlink.LinkStatistics{RxBytes: 1024, TxBytes: 1024},
+	})
+	setLink("wlan0", netlink.LinkAttrs{
+		OperState:  6,
+		Statistics: &netlink.LinkStatistics{RxBytes: 2048, TxBytes: 0},
+	})
+	virtual := []string{"docker0", "veth0", "tun0"}
+	for _, name := range virtual {
+		setLink(name, netlink.LinkAttrs{
+			OperState:  6,
+			Statistics: &netlink.LinkStatistics{},
+		})
+	}
+
+	n := All().
+		RefreshInterval(time.Second).
+		Output(func(s Speeds) bar.Output {
+			return outputs.Textf("%v/%v %v",
+				s.Rx.KibibytesPerSecond(), s.Tx.KibibytesPerSecond(),
+				s.Connected())
+		})
+
+	testBar.Run(n)
+	testBar.AssertNoOutput("on start")
+
+	setLink("lo", netlink.LinkAttrs{
+		OperState:  0,
+		Flags:      net.FlagLoopback,
+		Statistics: &netlink.LinkStatistics{RxBytes: 102400, TxBytes: 102400},
+	})
+	setLink("eth0", netlink.LinkAttrs{
+		OperState:  2,
+		Statistics: &netlink.LinkStatistics{RxBytes: 2048, TxBytes: 3072},
+	})
+	setLink("wlan0", netlink.LinkAttrs{
+		OperState:  6,
+		Statistics: &netlink.LinkStatistics{RxBytes: 4096, TxBytes: 1024},
+	})
+	for _, name := range virtual {
+		setLink(name, netlink.LinkAttrs{
+			OperState:  6,
+			Statistics: &netlink.LinkStatistics{RxBytes: 8192, TxBytes: 8192},
+		})
+	}
+	testBar.Tick()
+	testBar.NextOutput().AssertEqual(outputs.Text("3/3 true"),
+		"sums all physical interfaces")
+
+	removeLink("wlan0")
+	setLink("usb0", netlink.LinkAttrs{
+		OperState:  0,
+		Statistics: &netlink.LinkStatistics{RxBytes: 8192, TxBytes: 8192},
+	})
+	testBar.Tick()
+	testBar.NextOutput().AssertEqual(outputs.Text("0/0 false"),
+		"interface added and removed")
+
+	setLink("usb0", netlink.LinkAttrs{
+		OperState:  0,
+		Statistics: &netlink.LinkStatistics{RxBytes: 9216, TxBytes: 8192},
+	})
+	setLink("eth0", netlink.LinkAttrs{
+		OperState:  6,
+		Statistics: &netlink.LinkStatistics{RxBytes: 0, TxBytes: 4096},
+	})
+	testBar.Tick()
+	testBar.NextOutput().AssertEqual(outputs.Text("1/0 true"),
+		"ignores counter resets")
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nightlight
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+
+	"barista.run/base/value"
+	"barista.run/base/watchers/dbus"
+
+	godbus "github.com/godbus/dbus/v5"
+)
+
+type command struct {
+	name string
+}
+
+// Redshift returns a provider that sets the color temperature by running
+// redshift in one-shot mode. It cannot be used together with the redshift
+// daemon, which would override the temperature.
+func Redshift() Provider {
+	return command{"redshift"}
+}
+
+// Gammastep returns a provider that sets the color temperature by running
+// gammastep in one-shot mode, for wlroots based wayland compositors. It
+// cannot be used together with the gammastep daemon, which would override the
+// temperature.
+func Gammastep() Provider {
+	return command{"gammastep"}
+}
+
+// For tests.
+var runCommand = func(name string, args ...string) error {
+	return exec.Command(name, args...).Run()
+}
+
+// Worker sets the initial state, since the current temperature cannot be
+// read back from redshift or gammastep.
+func (c command) Worker(s *value.ErrorValue) {
+	s.Set(MakeState(false, DefaultTemperature, c))
+}
+
+// Apply runs the command to set or reset the color temperature.
+func (c command) Apply(enabled bool, temperature int) error {
+	if !enabled {
+		return runCommand(c.name, "-x")
+	}
+	return runCommand(c.name, "-P", "-O", strconv.Itoa(temperature))
+}
+
+const (
+	gnomeService = "org.gnome.SettingsDaemon.Color"
+	gnomeObject  = "/org/gnome/SettingsDaemon/Color"
+	gnomeIface   = "org.gnome.SettingsDaemon.Color"
+)
+
+// Overridden in tests.
+var busType = dbus.Session
+
+type gnome struct {
+	w *dbus.PropertiesWatcher
+}
+
+// GNOME returns a provider for the GNOME night light, using the GNOME
+// settings daemon. The night light must be turned on in the GNOME settings,
+// and is only enabled during its schedule. Disabling it lasts until the next
+// day, as in the GNOME system menu.
+func GNOME() Provider {
+	return gnome{}
+}
+
+// Worker watches the night light properties for changes.
+func (g gnome) Worker(s *value.ErrorValue) {
+	g.w = dbus.WatchProperties(busType, gnomeService, gnomeObject, gnomeIface).
+		Add("NightLightActive", "DisabledUntilTomorrow", "Temperature")
+	defer g.w.Unsubscribe()
+	for {
+		props := g.w.Get()
+		active, _ := props["NightLightActive"].(bool)
+		disabled, _ := props["DisabledUntilTomorrow"].(bool)
+		if temp, ok := props["Temperature"].(uint32); ok {
+			s.Set(MakeState(active && !disabled, int(temp), g))
+		} else {
+			s.Error(errors.New("GNOME night light is not available"))
+		}
+		<-g.w.Updates
+	}
+}
+
+// Apply sets the GNOME night light properties.
+func (g gnome) Apply(enabled bool, temperature int) error {
+	if _, err := g.set("DisabledUntilTomorrow", !enabled); err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+	_, err := g.set("Temperature", uint32(temperature))
+	return err
+}
+
+func (g gnome) set(prop string, val interface{}) ([]interface{}, error) {
+	return g.w.Call("org.freedesktop.DBus.Properties.Set",
+		gnomeIface, prop, godbus.MakeVariant(val))
+}
@@ -0,0 +1,188 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nightlight provides an i3bar module that displays and controls the
+// color temperature of the screen, using redshift, gammastep, or the GNOME
+// night light.
+package nightlight // import "barista.run/modules/nightlight"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// MinTemperature is the lowest supported color temperature, in Kelvin.
+	MinTemperature = 1000
+	// MaxTemperature is the highest supported color temperature, in Kelvin.
+	// This is the neutral temperature, i.e. no color adjustment.
+	MaxTemperature = 6500
+	// DefaultTemperature is the initial color temperature for backends that
+	// cannot report the current temperature.
+	DefaultTemperature = 4500
+)
+
+// State represents the current night light state.
+type State struct {
+	// Enabled is true if the color temperature is being adjusted.
+	Enabled bool
+	// Temperature is the color temperature used when enabled, in Kelvin.
+	Temperature int
+	controller  Controller
+	update      func(State)
+}
+
+// MakeState creates a State instance with the given data.
+func MakeState(enabled bool, temperature int, controller Controller) State {
+	return State{
+		Enabled:     enabled,
+		Temperature: temperature,
+		controller:  controller,
+	}
+}
+
+// SetTemperature sets the color temperature, clamped to the supported range.
+// It does not enable the night light if it is disabled.
+func (s State) SetTemperature(temperature int) {
+	if temperature > MaxTemperature {
+		temperature = MaxTemperature
+	}
+	if temperature < MinTemperature {
+		temperature = MinTemperature
+	}
+	if temperature == s.Temperature {
+		return
+	}
+	s.apply(s.Enabled, temperature)
+}
+
+// SetEnabled controls whether the color temperature is adjusted.
+func (s State) SetEnabled(enabled bool) {
+	if s.Enabled == enabled {
+		return
+	}
+	s.apply(enabled, s.Temperature)
+}
+
+func (s State) apply(enabled bool, temperature int) {
+	if err := s.controller.Apply(enabled, temperature); err != nil {
+		l.Log("Error updating night light: %v", err)
+		return
+	}
+	s.Enabled, s.Temperature = enabled, temperature
+	s.update(s)
+}
+
+// Controller for a night light implementation.
+type Controller interface {
+	// Apply enables or disables the night light, using the given color
+	// temperature when enabled.
+	Apply(enabled bool, temperature int) error
+}
+
+// Provider is the interface that must be implemented by individual night
+// light implementations.
+type Provider interface {
+	// Worker pushes updates and errors to the provided ErrorValue.
+	Worker(s *value.ErrorValue)
+}
+
+// Module represents a bar.Module that displays the night light state.
+type Module struct {
+	outputFunc value.Value // of func(State) bar.Output
+	provider   Provider
+}
+
+// Output configures a module to display the output of a user-defined
+// function.
+func (m *Module) Output(outputFunc func(State) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RateLimiter throttles temperature updates to once every ~50ms, since some
+// implementations run a command for each update.
+var RateLimiter = rate.NewLimiter(rate.Every(50*time.Millisecond), 1)
+
+// Step is the temperature change for each scroll event, in Kelvin.
+const Step = 100
+
+// defaultClickHandler toggles the night light on left click, and raises or
+// lowers the temperature on scroll.
+func defaultClickHandler(s State) func(bar.Event) {
+	return func(e bar.Event) {
+		if !RateLimiter.Allow() {
+			return
+		}
+		switch e.Button {
+		case bar.ButtonLeft:
+			s.SetEnabled(!s.Enabled)
+		case bar.ScrollUp:
+			s.SetTemperature(s.Temperature + Step)
+		case bar.ScrollDown:
+			s.SetTemperature(s.Temperature - Step)
+		}
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	var state value.ErrorValue
+
+	v, err := state.Get()
+	nextV, done := state.Subscribe()
+	defer done()
+	go m.provider.Worker(&state)
+
+	outputFunc := m.outputFunc.Get().(func(State) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	for {
+		if sink.Error(err) {
+			return
+		}
+		if s, ok := v.(State); ok {
+			s.update = func(s State) { state.Set(s) }
+			sink.Output(outputs.Group(outputFunc(s)).
+				OnClick(defaultClickHandler(s)))
+		}
+		select {
+		case <-nextV:
+			v, err = state.Get()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(State) bar.Output)
+		}
+	}
+}
+
+// New creates a new module with the given backing implementation.
+func New(provider Provider) *Module {
+	m := &Module{provider: provider}
+	l.Register(m, "outputFunc", "provider")
+	// Default output is the temperature when enabled, "OFF" otherwise.
+	m.Output(func(s State) bar.Output {
+		if !s.Enabled {
+			return outputs.Text("OFF")
+		}
+		return outputs.Textf("%dK", s.Temperature)
+	})
+	return m
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nightlight
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+type commandLog struct {
+	sync.Mutex
+	commands []string
+	err      error
+}
+
+func (c *commandLog) run(name string, args ...string) error {
+	c.Lock()
+	defer c.Unlock()
+	c.commands = append(c.commands, name+" "+strings.Join(args, " "))
+	return c.err
+}
+
+func (c *commandLog) get() []string {
+	c.Lock()
+	defer c.Unlock()
+	r := c.commands
+	c.commands = nil
+	return r
+}
+
+func TestCommand(t *testing.T) {
+	RateLimiter = rate.NewLimiter(rate.Inf, 0)
+	log := &commandLog{}
+	runCommand = log.run
+
+	testBar.New(t)
+	testBar.Run(New(Redshift()))
+	out := testBar.NextOutput("initial")
+	out.AssertText([]string{"OFF"})
+	require.Empty(t, log.get(), "no commands on start")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll while disabled")
+	out.AssertText([]string{"OFF"})
+	require.Equal(t, []string{"redshift -x"}, log.get())
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on toggle")
+	out.AssertText([]string{"4400K"})
+	require.Equal(t, []string{"redshift -P -O 4400"}, log.get())
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on scroll up")
+	out.AssertText([]string{"4500K"})
+	require.Equal(t, []string{"redshift -P -O 4500"}, log.get())
+
+	log.err = errors.New("no display")
+	out.At(0).LeftClick()
+	testBar.AssertNoOutput("on command error")
+	require.Equal(t, []string{"redshift -x"}, log.get())
+}
+
+func TestLimits(t *testing.T) {
+	RateLimiter = rate.NewLimiter(rate.Inf, 0)
+	log := &commandLog{}
+	runCommand = log.run
+
+	testBar.New(t)
+	m := New(Gammastep())
+	testBar.Run(m)
+	out := testBar.NextOutput("initial")
+	out.At(0).LeftClick()
+	testBar.NextOutput("on toggle").AssertText([]string{"4500K"})
+	require.Equal(t, []string{"gammastep -P -O 4500"}, log.get())
+
+	var state State
+	m.Output(func(s State) bar.Output {
+		state = s
+		return outputs.Textf("%v %d", s.Enabled, s.Temperature)
+	})
+	testBar.NextOutput("on output change").AssertText([]string{"true 4500"})
+
+	state.SetTemperature(10000)
+	testBar.NextOutput("above max").AssertText([]string{"true 6500"})
+	require.Equal(t, []string{"gammastep -P -O 6500"}, log.get())
+
+	state.SetTemperature(100)
+	testBar.NextOutput("below min").AssertText([]string{"true 1000"})
+	require.Equal(t, []string{"gammastep -P -O 1000"}, log.get())
+
+	state.SetTemperature(0)
+	testBar.AssertNoOutput("unchanged after clamping")
+	require.Empty(t, log.get())
+}
+
+func TestGNOME(t *testing.T) {
+	RateLimiter = rate.NewLimiter(rate.Inf, 0)
+	busType = dbus.Test
+	bus := dbus.SetupTestBus()
+	srv := bus.RegisterService(gnomeService)
+	obj := srv.Object(gnomeObject, gnomeIface)
+	obj.SetProperties(map[string]interface{}{
+		"NightLightActive":      true,
+		"DisabledUntilTomorrow": false,
+		"Temperature":           uint32(3700),
+	}, dbus.SignalTypeNone)
+
+	testBar.New(t)
+	testBar.Run(New(GNOME()))
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"3700K"})
+
+	// Each property set is reflected separately, so intermediate outputs are
+	// possible.
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.Drain(50*time.Millisecond, "on scroll")
+	out.AssertText([]string{"3800K"})
+
+	out.At(0).LeftClick()
+	testBar.Drain(50*time.Millisecond, "on toggle").AssertText([]string{"OFF"})
+
+	val, err := obj.GetProperty(gnomeIface + ".DisabledUntilTomorrow")
+	require.NoError(t, err)
+	require.Equal(t, true, val.Value())
+	val, err = obj.GetProperty(gnomeIface + ".Temperature")
+	require.NoError(t, err)
+	require.Equal(t, uint32(3800), val.Value())
+
+	obj.SetProperty("DisabledUntilTomorrow", false)
+	testBar.NextOutput("on external change").AssertText([]string{"3800K"})
+
+	obj.SetProperty("NightLightActive", false)
+	testBar.NextOutput("outside schedule").AssertText([]string{"OFF"})
+
+	srv.Unregister()
+	testBar.NextOutput("on service stop").AssertError()
+}
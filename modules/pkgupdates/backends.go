@@ -0,0 +1,159 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgupdates
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runCommand runs a command and returns its stdout and exit code. An error
+// is only returned if the command could not be run. Overridden in tests.
+var runCommand = func(name string, args ...string) ([]byte, int, error) {
+	out, err := exec.Command(name, args...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return out, exitErr.ExitCode(), nil
+	}
+	return out, 0, err
+}
+
+func commandError(name string, code int) error {
+	return fmt.Errorf("%s exited with status %d", name, code)
+}
+
+// eachLine calls fn with the whitespace-separated fields of each non-empty
+// line of output.
+func eachLine(out []byte, fn func(fields []string)) {
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		if fields := strings.Fields(s.Text()); len(fields) > 0 {
+			fn(fields)
+		}
+	}
+}
+
+type pacman struct{}
+
+// Pacman returns a backend for Arch Linux, using checkupdates from
+// pacman-contrib, which checks for updates without modifying the system's
+// package database.
+func Pacman() Backend {
+	return pacman{}
+}
+
+func (pacman) Updates() ([]Package, error) {
+	out, code, err := runCommand("checkupdates")
+	if err != nil {
+		return nil, err
+	}
+	switch code {
+	case 0:
+	case 2:
+		// No updates available.
+		return nil, nil
+	default:
+		return nil, commandError("checkupdates", code)
+	}
+	var pkgs []Package
+	// Lines are of the form "name old-version -> new-version".
+	eachLine(out, func(f []string) {
+		if len(f) == 4 && f[2] == "->" {
+			pkgs = append(pkgs, Package{f[0], f[1], f[3]})
+		}
+	})
+	return pkgs, nil
+}
+
+type apt struct{}
+
+// Apt returns a backend for Debian and derivatives, which simulates an
+// upgrade using apt-get. The package lists are not refreshed, this is
+// usually done by a system timer (apt-daily.timer).
+func Apt() Backend {
+	return apt{}
+}
+
+func (apt) Updates() ([]Package, error) {
+	out, code, err := runCommand("apt-get",
+		"--simulate", "-o", "Debug::NoLocking=true", "upgrade")
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, commandError("apt-get", code)
+	}
+	var pkgs []Package
+	// Lines are of the form "Inst name [old-version] (new-version repo [arch])".
+	eachLine(out, func(f []string) {
+		if len(f) < 3 || f[0] != "Inst" {
+			return
+		}
+		p := Package{Name: f[1]}
+		rest := f[2:]
+		if strings.HasPrefix(rest[0], "[") {
+			p.OldVersion = strings.Trim(rest[0], "[]")
+			rest = rest[1:]
+		}
+		if len(rest) > 0 {
+			p.NewVersion = strings.TrimPrefix(rest[0], "(")
+		}
+		pkgs = append(pkgs, p)
+	})
+	return pkgs, nil
+}
+
+type dnf struct{}
+
+// Dnf returns a backend for Fedora and derivatives, using dnf check-update.
+func Dnf() Backend {
+	return dnf{}
+}
+
+func (dnf) Updates() ([]Package, error) {
+	out, code, err := runCommand("dnf", "check-update", "--quiet")
+	if err != nil {
+		return nil, err
+	}
+	switch code {
+	case 0:
+		return nil, nil
+	case 100:
+		// Updates available.
+	default:
+		return nil, commandError("dnf", code)
+	}
+	var pkgs []Package
+	// Lines are of the form "name.arch new-version repo". A blank line
+	// separates the updates from any obsoleted packages, which are ignored.
+	done := false
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() && !done {
+		f := strings.Fields(s.Text())
+		switch {
+		case len(f) == 0 && len(pkgs) > 0:
+			done = true
+		case len(f) == 3:
+			name := f[0]
+			if dot := strings.LastIndex(name, "."); dot > 0 {
+				name = name[:dot]
+			}
+			pkgs = append(pkgs, Package{Name: name, NewVersion: f[1]})
+		}
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pkgupdates provides an i3bar module that shows the number of
+// pending system package updates.
+package pkgupdates // import "barista.run/modules/pkgupdates"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Package represents a single package with a pending update.
+type Package struct {
+	Name string
+	// OldVersion is the installed version, if known.
+	OldVersion string
+	NewVersion string
+}
+
+// Info represents the pending updates.
+type Info struct {
+	Packages []Package
+	// Updated is the time of the last successful check.
+	Updated time.Time
+}
+
+// Count returns the number of pending updates.
+func (i Info) Count() int {
+	return len(i.Packages)
+}
+
+// Backend is an interface for package managers that can list updates.
+type Backend interface {
+	Updates() ([]Package, error)
+}
+
+// Module represents a bar.Module that displays pending package updates.
+type Module struct {
+	backend    Backend
+	scheduler  *timing.Scheduler
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a pkgupdates module using the given backend.
+func New(backend Backend) *Module {
+	m := &Module{backend: backend, scheduler: timing.NewScheduler()}
+	m.refreshFn, m.refreshCh = notifier.New()
+	l.Register(m, "scheduler", "outputFunc")
+	m.RefreshInterval(time.Hour)
+	m.Output(func(i Info) bar.Output {
+		if i.Count() == 0 {
+			return nil
+		}
+		return outputs.Textf("%d updates", i.Count())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency. Checking for updates
+// can be expensive, so the default is hourly.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Refresh checks for updates immediately.
+func (m *Module) Refresh() {
+	m.refreshFn()
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	var info Info
+	update := func() error {
+		pkgs, err := m.backend.Updates()
+		if err != nil {
+			return err
+		}
+		info = Info{Packages: pkgs, Updated: timing.Now()}
+		return nil
+	}
+	err := update()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputs.Group(outputFunc(info)).OnClick(func(e bar.Event) {
+				if e.Button == bar.ButtonLeft {
+					m.Refresh()
+				}
+			}))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.scheduler.C:
+			err = update()
+		case <-m.refreshCh:
+			err = update()
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgupdates
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type result struct {
+	out  string
+	code int
+	err  error
+}
+
+var mu sync.Mutex
+var results = map[string]result{}
+
+func init() {
+	runCommand = func(name string, args ...string) ([]byte, int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		r := results[name]
+		return []byte(r.out), r.code, r.err
+	}
+}
+
+func shouldReturn(name, out string, code int) {
+	mu.Lock()
+	defer mu.Unlock()
+	results[name] = result{out: out, code: code}
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	shouldReturn("checkupdates", "", 2)
+	pkg := New(Pacman())
+	testBar.Run(pkg)
+	testBar.NextOutput("on start").AssertEmpty("no updates")
+
+	shouldReturn("checkupdates", `
+linux 5.9.10.arch1-1 -> 5.9.11.arch1-1
+firefox 83.0-1 -> 83.0-2
+`, 0)
+	testBar.Tick()
+	out := testBar.NextOutput("on tick")
+	out.AssertText([]string{"2 updates"})
+
+	pkg.Output(func(i Info) bar.Output {
+		var names []string
+		for _, p := range i.Packages {
+			names = append(names, p.Name+"="+p.NewVersion)
+		}
+		return outputs.Text(strings.Join(names, ","))
+	})
+	out = testBar.NextOutput("on output change")
+	out.AssertText([]string{"linux=5.9.11.arch1-1,firefox=83.0-2"})
+
+	shouldReturn("checkupdates", "vim 8.2.1989-1 -> 8.2.1989-2\n", 0)
+	out.At(0).LeftClick()
+	testBar.NextOutput("on click").AssertText([]string{"vim=8.2.1989-2"})
+
+	shouldReturn("checkupdates", "", 1)
+	pkg.Refresh()
+	testBar.NextOutput("on error").At(0).AssertError()
+}
+
+func TestApt(t *testing.T) {
+	shouldReturn("apt-get", `NOTE: This is only a simulation!
+Reading package lists...
+The following packages will be upgraded:
+  libc6 tzdata
+Inst libc6 [2.31-4] (2.31-5 Debian:testing [amd64])
+Inst tzdata (2020e-1 Debian:testing [all])
+Conf libc6 (2.31-5 Debian:testing [amd64])
+`, 0)
+	pkgs, err := Apt().Updates()
+	require.NoError(t, err)
+	require.Equal(t, []Package{
+		{"libc6", "2.31-4", "2.31-5"},
+		{"tzdata", "", "2020e-1"},
+	}, pkgs)
+
+	shouldReturn("apt-get", "", 100)
+	_, err = Apt().Updates()
+	require.Error(t, err)
+}
+
+func TestDnf(t *testing.T) {
+	shouldReturn("dnf", "", 0)
+	pkgs, err := Dnf().Updates()
+	require.NoError(t, err)
+	require.Empty(t, pkgs)
+
+	shouldReturn("dnf", `
+kernel.x86_64          5.9.11-200.fc33        updates
+vim-minimal.x86_64     2:8.2.1971-1.fc33      updates
+
+Obsoleting Packages
+grub2-tools.x86_64     1:2.04-33.fc33         updates
+    grub2-tools.x86_64 1:2.04-31.fc33         @anaconda
+`, 100)
+	pkgs, err = Dnf().Updates()
+	require.NoError(t, err)
+	require.Equal(t, []Package{
+		{Name: "kernel", NewVersion: "5.9.11-200.fc33"},
+		{Name: "vim-minimal", NewVersion: "2:8.2.1971-1.fc33"},
+	}, pkgs)
+
+	shouldReturn("dnf", "", 1)
+	_, err = Dnf().Updates()
+	require.Error(t, err)
+
+	mu.Lock()
+	results["dnf"] = result{err: errors.New("dnf: not found")}
+	mu.Unlock()
+	_, err = Dnf().Updates()
+	require.Error(t, err)
+}
@@ -0,0 +1,262 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package promql provides an i3bar module that evaluates a PromQL instant
+// query against a Prometheus server, e.g. to show an error rate or the number
+// of alerts firing.
+package promql // import "barista.run/modules/promql"
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/colors"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Sample is a single value of the query result.
+type Sample struct {
+	// Labels of the time series, empty for scalar results.
+	Labels map[string]string
+	Value  float64
+	Time   time.Time
+}
+
+// thresholds is the pair of warning and critical thresholds.
+type thresholds struct {
+	warning, critical float64
+}
+
+// Info represents the result of the query.
+type Info struct {
+	// Samples are the values of the result, one for each time series of a
+	// vector result, or a single sample for a scalar result.
+	Samples []Sample
+	thresholds
+}
+
+// Empty returns true if the query returned no samples.
+func (i Info) Empty() bool {
+	return len(i.Samples) == 0
+}
+
+// Value returns the value of the first sample, or NaN if there are none.
+// This is a convenience for queries that return a single value.
+func (i Info) Value() float64 {
+	if i.Empty() {
+		return math.NaN()
+	}
+	return i.Samples[0].Value
+}
+
+// Warning returns true if the value of any sample is past the warning
+// threshold.
+func (i Info) Warning() bool {
+	return i.past(i.warning)
+}
+
+// Critical returns true if the value of any sample is past the critical
+// threshold.
+func (i Info) Critical() bool {
+	return i.past(i.critical)
+}
+
+func (i Info) past(threshold float64) bool {
+	// Thresholds are "lower is worse" if the critical threshold is lower.
+	lowerIsWorse := i.critical < i.warning
+	for _, s := range i.Samples {
+		if lowerIsWorse && s.Value <= threshold ||
+			!lowerIsWorse && s.Value >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// Module represents a bar.Module that displays the result of a PromQL query.
+type Module struct {
+	server     string
+	query      string
+	scheduler  *timing.Scheduler
+	thresholds value.Value // of thresholds
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a module that evaluates the query against the Prometheus
+// server at the given address, e.g. "http://localhost:9090".
+func New(server, query string) *Module {
+	m := &Module{
+		server:    strings.TrimSuffix(server, "/"),
+		query:     query,
+		scheduler: timing.NewScheduler(),
+	}
+	l.Label(m, query)
+	l.Register(m, "scheduler", "thresholds", "outputFunc")
+	m.RefreshInterval(30 * time.Second)
+	m.thresholds.Set(thresholds{math.NaN(), math.NaN()})
+	m.Output(func(i Info) bar.Output {
+		if i.Empty() {
+			return nil
+		}
+		out := outputs.Textf("%.4g", i.Value())
+		switch {
+		case i.Critical():
+			out.Color(colors.Scheme("bad"))
+		case i.Warning():
+			out.Color(colors.Scheme("degraded"))
+		}
+		return out
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Thresholds sets the values at which the result is considered a warning or
+// critical. If the critical threshold is lower than the warning threshold,
+// lower values are considered worse, e.g. for availability.
+func (m *Module) Thresholds(warning, critical float64) *Module {
+	m.thresholds.Set(thresholds{warning, critical})
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	samples, err := m.evaluate()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextThresholds, done := m.thresholds.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			s.Output(outputFunc(Info{
+				Samples:    samples,
+				thresholds: m.thresholds.Get().(thresholds),
+			}))
+		}
+		select {
+		case <-m.scheduler.C:
+			samples, err = m.evaluate()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextThresholds:
+		}
+	}
+}
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// queryResponse is the response of the /api/v1/query endpoint, see
+// https://prometheus.io/docs/prometheus/latest/querying/api/#instant-queries.
+type queryResponse struct {
+	Status string
+	Error  string
+	Data   struct {
+		ResultType string
+		Result     json.RawMessage
+	}
+}
+
+// sampleValue is a [timestamp, "value"] pair.
+type sampleValue [2]interface{}
+
+func (v sampleValue) sample(labels map[string]string) (Sample, error) {
+	ts, ok := v[0].(float64)
+	str, ok2 := v[1].(string)
+	if !ok || !ok2 {
+		return Sample{}, fmt.Errorf("Invalid sample %v", v)
+	}
+	val, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return Sample{}, err
+	}
+	sec, frac := math.Modf(ts)
+	return Sample{
+		Labels: labels,
+		Value:  val,
+		Time:   time.Unix(int64(sec), int64(frac*1e9)),
+	}, nil
+}
+
+func (m *Module) evaluate() ([]Sample, error) {
+	u := m.server + "/api/v1/query?" + url.Values{"query": {m.query}}.Encode()
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var r queryResponse
+	// Prometheus returns JSON with an error message for most failures, so
+	// only fall back to the HTTP status if the body cannot be decoded.
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("HTTP Status %d", resp.StatusCode)
+		}
+		return nil, err
+	}
+	if r.Status != "success" {
+		return nil, fmt.Errorf("Prometheus: %s", r.Error)
+	}
+	switch r.Data.ResultType {
+	case "scalar":
+		var v sampleValue
+		if err := json.Unmarshal(r.Data.Result, &v); err != nil {
+			return nil, err
+		}
+		s, err := v.sample(map[string]string{})
+		if err != nil {
+			return nil, err
+		}
+		return []Sample{s}, nil
+	case "vector":
+		var vector []struct {
+			Metric map[string]string
+			Value  sampleValue
+		}
+		if err := json.Unmarshal(r.Data.Result, &vector); err != nil {
+			return nil, err
+		}
+		samples := make([]Sample, 0, len(vector))
+		for _, v := range vector {
+			s, err := v.Value.sample(v.Metric)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, s)
+		}
+		return samples, nil
+	}
+	return nil, fmt.Errorf("Unsupported result type %q", r.Data.ResultType)
+}
@@ -0,0 +1,188 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promql
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/colors"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	status  int
+	body    string
+	queries []string
+}
+
+func newFakeServer(body string) *fakeServer {
+	f := &fakeServer{status: http.StatusOK, body: body}
+	f.Server = httptest.NewServer(f)
+	return f
+}
+
+func (f *fakeServer) respond(status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status, f.body = status, body
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path != "/api/v1/query" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	f.queries = append(f.queries, r.URL.Query().Get("query"))
+	w.WriteHeader(f.status)
+	io.WriteString(w, f.body)
+}
+
+func vector(values ...string) string {
+	var r []string
+	for i, v := range values {
+		r = append(r, fmt.Sprintf(
+			`{"metric":{"job":"job%d"},"value":[1435781451.781,%q]}`, i, v))
+	}
+	return fmt.Sprintf(`{"status":"success","data":{"resultType":"vector","result":[%s]}}`,
+		strings.Join(r, ","))
+}
+
+func TestModule(t *testing.T) {
+	srv := newFakeServer(vector("0.05"))
+	defer srv.Close()
+
+	testBar.New(t)
+	colors.LoadFromMap(map[string]string{"bad": "#ff0000", "degraded": "#ffff00"})
+	m := New(srv.URL+"/", `sum(rate(errors[5m]))`).Thresholds(1, 5)
+	testBar.Run(m)
+	testBar.NextOutput("initial").AssertText([]string{"0.05"})
+
+	srv.mu.Lock()
+	require.Equal(t, []string{`sum(rate(errors[5m]))`}, srv.queries)
+	srv.mu.Unlock()
+
+	srv.respond(http.StatusOK, vector("0.5", "2"))
+	testBar.Tick()
+	out := testBar.NextOutput("on warning")
+	out.AssertText([]string{"0.5"})
+	color, _ := out.At(0).Segment().GetColor()
+	require.Equal(t, colors.Scheme("degraded"), color, "any sample past threshold")
+
+	srv.respond(http.StatusOK, vector("12345.678"))
+	testBar.Tick()
+	out = testBar.NextOutput("on critical")
+	out.AssertText([]string{"1.235e+04"})
+	color, _ = out.At(0).Segment().GetColor()
+	require.Equal(t, colors.Scheme("bad"), color)
+
+	m.Thresholds(0.99, 0.9)
+	out = testBar.NextOutput("on threshold change")
+	color, _ = out.At(0).Segment().GetColor()
+	require.Nil(t, color, "lower is worse")
+
+	srv.respond(http.StatusOK, vector())
+	testBar.Tick()
+	testBar.NextOutput("on empty result").AssertEmpty()
+
+	srv.respond(http.StatusOK,
+		`{"status":"success","data":{"resultType":"scalar","result":[1435781451.781,"0.95"]}}`)
+	testBar.Tick()
+	out = testBar.NextOutput("on scalar")
+	out.AssertText([]string{"0.95"})
+	color, _ = out.At(0).Segment().GetColor()
+	require.Equal(t, colors.Scheme("degraded"), color)
+
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%v %v %v", i.Value(), i.Warning(), i.Critical())
+	})
+	testBar.NextOutput("on output change").AssertText([]string{"0.95 true false"})
+}
+
+func TestErrors(t *testing.T) {
+	srv := newFakeServer(`{"status":"error","errorType":"bad_data","error":"parse error"}`)
+	defer srv.Close()
+
+	testBar.New(t)
+	testBar.Run(New(srv.URL, "up{"))
+	err := testBar.NextOutput("on query error").AssertError()
+	require.Equal(t, []string{"Prometheus: parse error"}, err)
+
+	srv.respond(http.StatusBadGateway, `<html>Bad Gateway</html>`)
+	testBar.Tick()
+	err = testBar.NextOutput("on HTTP error").AssertError()
+	require.Equal(t, []string{"HTTP Status 502"}, err)
+
+	srv.respond(http.StatusOK,
+		`{"status":"success","data":{"resultType":"matrix","result":[]}}`)
+	testBar.Tick()
+	err = testBar.NextOutput("on matrix").AssertError()
+	require.Equal(t, []string{`Unsupported result type "matrix"`}, err)
+
+	srv.respond(http.StatusOK, vector("NaN"))
+	testBar.Tick()
+	testBar.NextOutput("on recovery").AssertText([]string{"NaN"})
+
+	srv.respond(http.StatusOK,
+		`{"status":"success","data":{"resultType":"vector","result":[{"value":[1,"foo"]}]}}`)
+	testBar.Tick()
+	testBar.NextOutput("on invalid value").AssertError()
+}
+
+func TestInfo(t *testing.T) {
+	srv := newFakeServer(vector("1", "3", "2"))
+	defer srv.Close()
+
+	testBar.New(t)
+	var info Info
+	var mu sync.Mutex
+	testBar.Run(New(srv.URL, "up").Output(func(i Info) bar.Output {
+		mu.Lock()
+		defer mu.Unlock()
+		info = i
+		return outputs.Textf("%d", len(i.Samples))
+	}))
+	testBar.NextOutput("initial").AssertText([]string{"3"})
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.False(t, info.Warning(), "no thresholds")
+	require.False(t, info.Critical(), "no thresholds")
+	var jobs []string
+	for _, s := range info.Samples {
+		jobs = append(jobs, s.Labels["job"])
+		require.Equal(t, int64(1435781451), s.Time.Unix())
+		require.InDelta(t, 781*time.Millisecond, s.Time.Nanosecond(), float64(time.Millisecond))
+	}
+	sort.Strings(jobs)
+	require.Equal(t, []string{"job0", "job1", "job2"}, jobs)
+	require.True(t, math.IsNaN(Info{}.Value()))
+}
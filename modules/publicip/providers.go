@@ -0,0 +1,137 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publicip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Overridden in tests.
+var (
+	ipinfoURL = "https://ipinfo.io/json"
+	ipapiURL  = "http://ip-api.com/json/?fields=status,message,query,countryCode,as"
+	ipifyURL  = "https://api.ipify.org"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+func get(url string) ([]byte, error) {
+	r, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %s", url, r.Status)
+	}
+	return ioutil.ReadAll(r.Body)
+}
+
+// splitASN splits an "AS15169 Google LLC" string into the ASN and the
+// organisation name.
+func splitASN(as string) (asn, org string) {
+	parts := strings.SplitN(as, " ", 2)
+	if !strings.HasPrefix(parts[0], "AS") {
+		return "", as
+	}
+	if len(parts) > 1 {
+		org = parts[1]
+	}
+	return parts[0], org
+}
+
+type ipinfo struct{}
+
+// IPInfo returns a provider that uses ipinfo.io, which includes the country
+// and ASN of the IP address.
+func IPInfo() Provider {
+	return ipinfo{}
+}
+
+func (ipinfo) Lookup() (Info, error) {
+	var i Info
+	body, err := get(ipinfoURL)
+	if err != nil {
+		return i, err
+	}
+	var resp struct {
+		IP      string `json:"ip"`
+		Country string `json:"country"`
+		Org     string `json:"org"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return i, err
+	}
+	i.IP = net.ParseIP(resp.IP)
+	i.Country = resp.Country
+	i.ASN, i.Org = splitASN(resp.Org)
+	return i, nil
+}
+
+type ipapi struct{}
+
+// IPAPI returns a provider that uses ip-api.com, which includes the country
+// and ASN of the IP address. The free tier of ip-api.com only supports http.
+func IPAPI() Provider {
+	return ipapi{}
+}
+
+func (ipapi) Lookup() (Info, error) {
+	var i Info
+	body, err := get(ipapiURL)
+	if err != nil {
+		return i, err
+	}
+	var resp struct {
+		Status      string `json:"status"`
+		Message     string `json:"message"`
+		Query       string `json:"query"`
+		CountryCode string `json:"countryCode"`
+		AS          string `json:"as"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return i, err
+	}
+	if resp.Status != "success" {
+		return i, fmt.Errorf("ip-api.com: %s", resp.Message)
+	}
+	i.IP = net.ParseIP(resp.Query)
+	i.Country = resp.CountryCode
+	i.ASN, i.Org = splitASN(resp.AS)
+	return i, nil
+}
+
+// PlainText returns a provider that fetches the IP address from a service
+// that returns only the address as plain text, e.g. "https://api.ipify.org"
+// or "https://icanhazip.com". No country or ASN information is available.
+func PlainText(url string) Provider {
+	return plainText(url)
+}
+
+type plainText string
+
+func (p plainText) Lookup() (Info, error) {
+	body, err := get(string(p))
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{IP: net.ParseIP(strings.TrimSpace(string(body)))}, nil
+}
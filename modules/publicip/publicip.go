@@ -0,0 +1,197 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package publicip provides an i3bar module that shows the public IP address
+// of the machine, along with its country and ASN when available.
+package publicip // import "barista.run/modules/publicip"
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Info represents the public IP address and its location.
+type Info struct {
+	IP net.IP
+	// Country is the ISO 3166-1 alpha-2 country code, e.g. "US".
+	Country string
+	// ASN is the autonomous system number, e.g. "AS15169".
+	ASN string
+	// Org is the name of the organisation that owns the ASN.
+	Org string
+	// Previous is the IP address before the most recent change, or nil if
+	// the address has not changed since the module started.
+	Previous net.IP
+	// ChangedAt is the time the IP address was last seen to change.
+	ChangedAt time.Time
+}
+
+// Changed returns true if the IP address has changed since the module
+// started.
+func (i Info) Changed() bool {
+	return i.Previous != nil
+}
+
+// Provider is an interface for public IP lookup services.
+type Provider interface {
+	Lookup() (Info, error)
+}
+
+// MinRefreshInterval is the shortest refresh interval allowed, to avoid
+// hammering the free lookup services.
+const MinRefreshInterval = time.Minute
+
+// maxBackoff is the longest delay between lookups when all providers fail.
+const maxBackoff = time.Hour
+
+// Module represents a bar.Module that displays the public IP address.
+type Module struct {
+	providers  []Provider
+	scheduler  *timing.Scheduler
+	interval   value.Value // of time.Duration
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a public IP module that queries each of the given
+// providers in order until one succeeds. If no providers are given, ipinfo.io
+// is used, falling back to ipify.org.
+func New(providers ...Provider) *Module {
+	if len(providers) == 0 {
+		providers = []Provider{IPInfo(), PlainText(ipifyURL)}
+	}
+	m := &Module{providers: providers, scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "interval", "outputFunc")
+	m.RefreshInterval(10 * time.Minute)
+	m.Output(func(i Info) bar.Output {
+		if i.Country == "" {
+			return outputs.Text(i.IP.String())
+		}
+		return outputs.Textf("%s (%s)", i.IP, i.Country)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency. Intervals shorter than
+// MinRefreshInterval are increased to the minimum.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	if interval < MinRefreshInterval {
+		interval = MinRefreshInterval
+	}
+	m.interval.Set(interval)
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	var info Info
+	var err error
+	failures := 0
+	update := func() {
+		var newInfo Info
+		newInfo, err = m.lookup()
+		interval := m.interval.Get().(time.Duration)
+		if err != nil {
+			// Back off exponentially, but keep showing the last known IP.
+			failures++
+			backoff := interval << uint(failures)
+			if backoff > maxBackoff || backoff <= 0 {
+				backoff = maxBackoff
+			}
+			l.Log("%s: lookup failed (%v), retrying in %v", l.ID(m), err, backoff)
+			m.scheduler.After(backoff)
+			if info.IP != nil {
+				err = nil
+			}
+			return
+		}
+		if failures > 0 {
+			failures = 0
+			m.scheduler.Every(interval)
+		}
+		newInfo.Previous, newInfo.ChangedAt = info.Previous, info.ChangedAt
+		if info.IP != nil && !info.IP.Equal(newInfo.IP) {
+			newInfo.Previous = info.IP
+			newInfo.ChangedAt = timing.Now()
+		}
+		info = newInfo
+	}
+	update()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		// err is only set if no address was ever found, since failures
+		// after that keep showing the last known IP while backing off.
+		if s.Error(err) {
+			return
+		}
+		ip := info.IP.String()
+		s.Output(outputs.Group(outputFunc(info)).OnClick(func(e bar.Event) {
+			if e.Button == bar.ButtonLeft {
+				if err := copyToClipboard(ip); err != nil {
+					l.Log("Failed to copy IP to clipboard: %v", err)
+				}
+			}
+		}))
+		select {
+		case <-m.scheduler.C:
+			update()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) lookup() (Info, error) {
+	var errs []string
+	for _, p := range m.providers {
+		info, err := p.Lookup()
+		if err == nil && info.IP == nil {
+			err = errors.New("no IP address in response")
+		}
+		if err == nil {
+			return info, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return Info{}, errors.New(strings.Join(errs, "; "))
+}
+
+// copyToClipboard copies text to the clipboard using wl-copy under Wayland,
+// or xclip under X11. Overridden in tests.
+var copyToClipboard = func(text string) error {
+	cmd := exec.Command("xclip", "-selection", "clipboard")
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		cmd = exec.Command("wl-copy")
+	}
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
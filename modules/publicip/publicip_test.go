@@ -0,0 +1,152 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publicip
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServices struct {
+	sync.Mutex
+	responses map[string]string
+}
+
+func (f *fakeServices) set(path, response string) {
+	f.Lock()
+	defer f.Unlock()
+	f.responses[path] = response
+}
+
+func (f *fakeServices) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	resp, ok := f.responses[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprint(w, resp)
+}
+
+func setup(t *testing.T) (*fakeServices, func()) {
+	f := &fakeServices{responses: map[string]string{}}
+	srv := httptest.NewServer(f)
+	ipinfoURL = srv.URL + "/ipinfo"
+	ipapiURL = srv.URL + "/ipapi"
+	ipifyURL = srv.URL + "/ipify"
+	return f, srv.Close
+}
+
+func TestPublicIP(t *testing.T) {
+	f, cleanup := setup(t)
+	defer cleanup()
+	testBar.New(t)
+
+	f.set("/ipinfo", `{"ip": "203.0.113.7", "country": "NZ", "org": "AS64500 Example Ltd"}`)
+	ip := New()
+	testBar.Run(ip)
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"203.0.113.7 (NZ)"})
+
+	var copied string
+	copyToClipboard = func(text string) error {
+		copied = text
+		return nil
+	}
+	out.At(0).LeftClick()
+	require.Equal(t, "203.0.113.7", copied)
+
+	ip.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s %s %s %s %v", i.IP, i.ASN, i.Org, i.Previous, i.Changed())
+	})
+	testBar.NextOutput("on output change").AssertText(
+		[]string{"203.0.113.7 AS64500 Example Ltd <nil> false"})
+
+	f.set("/ipinfo", "not json")
+	f.set("/ipify", "198.51.100.1\n")
+	testBar.Tick()
+	testBar.NextOutput("fallback provider").AssertText(
+		[]string{"198.51.100.1   203.0.113.7 true"})
+}
+
+func TestBackoff(t *testing.T) {
+	f, cleanup := setup(t)
+	defer cleanup()
+	testBar.New(t)
+
+	f.set("/ipapi", `{"status": "success", "query": "2001:db8::1", "countryCode": "DE", "as": "AS64501 Beispiel GmbH"}`)
+	ip := New(IPAPI()).RefreshInterval(time.Second)
+	testBar.Run(ip)
+	testBar.NextOutput("on start").AssertText([]string{"2001:db8::1 (DE)"})
+
+	start := timing.Now()
+	testBar.Tick()
+	testBar.NextOutput("on tick")
+	require.Equal(t, MinRefreshInterval, timing.Now().Sub(start),
+		"refresh interval is at least the minimum")
+
+	f.set("/ipapi", `{"status": "fail", "message": "quota exceeded"}`)
+	start = timing.Now()
+	testBar.Tick()
+	testBar.NextOutput("on failure").AssertText([]string{"2001:db8::1 (DE)"},
+		"keeps showing last known IP")
+	testBar.Tick()
+	testBar.NextOutput("on failure")
+	require.Equal(t, 3*time.Minute, timing.Now().Sub(start), "backs off")
+	testBar.Tick()
+	testBar.NextOutput("on failure")
+	require.Equal(t, 7*time.Minute, timing.Now().Sub(start), "backs off")
+
+	f.set("/ipapi", `{"status": "success", "query": "2001:db8::1", "countryCode": "DE"}`)
+	testBar.Tick()
+	testBar.NextOutput("on success")
+	start = timing.Now()
+	testBar.Tick()
+	testBar.NextOutput("on tick after recovery")
+	require.Equal(t, time.Minute, timing.Now().Sub(start), "resets backoff")
+}
+
+func TestErrors(t *testing.T) {
+	f, cleanup := setup(t)
+	defer cleanup()
+	testBar.New(t)
+	testBar.Run(New())
+	testBar.NextOutput("on start").At(0).AssertError("all providers failed")
+	out := testBar.NextOutput("sets restart click handler")
+	testBar.AssertNoOutput("stops on error")
+
+	f.set("/ipify", "192.0.2.4\n")
+	out.At(0).LeftClick()
+	testBar.NextOutput("on click").AssertText([]string{}, "clears error")
+	testBar.NextOutput("on restart").AssertText([]string{"192.0.2.4"},
+		"restarts on click")
+
+	f.set("/ipify", "<html>rate limited</html>")
+	testBar.New(t)
+	testBar.Run(New(PlainText(ipifyURL)))
+	testBar.NextOutput("on invalid response").At(0).AssertError()
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remote provides a module that displays a module served by another
+// process using barista.run/remote, e.g. a long-lived daemon running
+// modules that are slow to start or keep state.
+package remote // import "barista.run/modules/remote"
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+	"barista.run/remote"
+)
+
+// Module represents a bar.Module that displays a remote module.
+type Module struct {
+	socket string
+	name   string
+}
+
+// New constructs a module that displays the module with the given name from
+// the server listening on the given unix socket. If the connection fails or
+// is closed, an error is shown, and the module reconnects when clicked.
+func New(socket, name string) *Module {
+	m := &Module{socket: socket, name: name}
+	l.Label(m, name)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	conn, err := net.Dial("unix", m.socket)
+	if s.Error(err) {
+		return
+	}
+	defer conn.Close()
+
+	var mu sync.Mutex
+	enc := json.NewEncoder(conn)
+	send := func(req remote.Request) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return enc.Encode(req)
+	}
+	if s.Error(send(remote.Request{Module: m.name})) {
+		return
+	}
+
+	responses := bufio.NewScanner(conn)
+	responses.Buffer(nil, 1<<20)
+	for responses.Scan() {
+		var resp remote.Response
+		if s.Error(json.Unmarshal(responses.Bytes(), &resp)) {
+			return
+		}
+		if resp.Error != "" {
+			s.Error(errors.New(resp.Error))
+			return
+		}
+		var out bar.Segments
+		for i, r := range resp.Segments {
+			seg := r.ToSegment()
+			if r.Clickable {
+				// because go.
+				i := i
+				seg.OnClick(func(e bar.Event) {
+					if err := send(remote.Request{Segment: i, Event: &e}); err != nil {
+						l.Log("%s: failed to send click: %v", l.ID(m), err)
+					}
+				})
+			}
+			out = append(out, seg)
+		}
+		s.Output(out)
+	}
+	err = responses.Err()
+	if err == nil {
+		err = errors.New("connection closed")
+	}
+	s.Error(err)
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/remote"
+	testBar "barista.run/testing/bar"
+	testModule "barista.run/testing/module"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemote(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "barista.sock")
+
+	served := testModule.New(t)
+	srv := remote.NewServer().Add("served", served)
+	go srv.ListenAndServe(socket)
+	served.AssertStarted()
+	served.OutputText("hello")
+
+	testBar.New(t)
+	testBar.Run(New(socket, "served"), New(socket, "unknown"))
+	// The initial output can be sent more than once, since the module might
+	// update while the client is connecting.
+	out := testBar.Drain(100*time.Millisecond, "on start")
+	out.At(0).AssertText("hello")
+	require.Contains(t, out.At(1).AssertError(), `unknown module "unknown"`)
+
+	served.OutputText("world")
+	out = testBar.NextOutput("on remote update")
+	out.At(0).AssertText("world")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp, X: 3})
+	e := served.AssertClicked("click forwarded")
+	require.Equal(t, bar.ScrollUp, e.Button)
+	require.Equal(t, 3, e.X)
+
+	served.Close()
+	out = testBar.NextOutput("on remote module finished")
+	out.At(0).AssertText("world")
+	out.At(0).LeftClick()
+	served.AssertStarted("restarted by click forwarded to server")
+}
+
+func TestNoServer(t *testing.T) {
+	testBar.New(t)
+	testBar.Run(New("/does/not/exist.sock", "m"))
+	testBar.NextOutput().AssertError("when server is not running")
+}
@@ -0,0 +1,297 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sensors provides an i3bar module that shows hardware sensor
+// readings (temperatures, fans, voltages, power, current) from all hwmon
+// chips, similar to lm-sensors.
+package sensors // import "barista.run/modules/sensors"
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+	"github.com/spf13/afero"
+)
+
+// Kind represents the type of value measured by a sensor.
+type Kind int
+
+// Types of hwmon sensors.
+const (
+	Temperature Kind = iota
+	Fan
+	Voltage
+	Power
+	Current
+)
+
+// prefixes maps hwmon file prefixes to sensor kinds.
+var prefixes = map[string]Kind{
+	"temp":  Temperature,
+	"fan":   Fan,
+	"in":    Voltage,
+	"power": Power,
+	"curr":  Current,
+}
+
+// Sensor represents a single reading from a hwmon chip.
+type Sensor struct {
+	// Chip is the name of the hwmon chip, e.g. "coretemp" or "nct6775".
+	Chip string
+	// Label is the sensor label provided by the driver, e.g. "Core 0", or
+	// the input name (e.g. "temp2") if the driver does not provide one.
+	Label string
+	Kind  Kind
+	// raw value in the hwmon sysfs units (milli-°C, RPM, mV, µW, mA).
+	raw int64
+}
+
+// Temperature returns the value of a temperature sensor.
+func (s Sensor) Temperature() unit.Temperature {
+	return unit.FromCelsius(float64(s.raw) / 1000.0)
+}
+
+// RPM returns the speed of a fan sensor.
+func (s Sensor) RPM() int {
+	return int(s.raw)
+}
+
+// Voltage returns the value of a voltage sensor.
+func (s Sensor) Voltage() unit.Voltage {
+	return unit.Voltage(s.raw) * unit.Millivolt
+}
+
+// Power returns the value of a power sensor.
+func (s Sensor) Power() unit.Power {
+	return unit.Power(s.raw) * unit.Microwatt
+}
+
+// Current returns the value of a current sensor.
+func (s Sensor) Current() unit.ElectricCurrent {
+	return unit.ElectricCurrent(s.raw) * unit.Milliampere
+}
+
+// String returns the sensor value formatted with the appropriate unit.
+func (s Sensor) String() string {
+	switch s.Kind {
+	case Temperature:
+		return fmt.Sprintf("%.1f℃", s.Temperature().Celsius())
+	case Fan:
+		return fmt.Sprintf("%d RPM", s.RPM())
+	case Voltage:
+		return fmt.Sprintf("%.2fV", s.Voltage().Volts())
+	case Power:
+		return fmt.Sprintf("%.1fW", s.Power().Watts())
+	case Current:
+		return fmt.Sprintf("%.2fA", s.Current().Amperes())
+	}
+	return strconv.FormatInt(s.raw, 10)
+}
+
+// Sensors is a list of sensor readings, sorted by chip and label.
+type Sensors []Sensor
+
+// Filter returns the sensors that match all the given filters.
+func (s Sensors) Filter(filters ...Filter) Sensors {
+	var r Sensors
+	for _, sensor := range s {
+		if matchAll(sensor, filters) {
+			r = append(r, sensor)
+		}
+	}
+	return r
+}
+
+// Get returns the first sensor with the given chip and label.
+func (s Sensors) Get(chip, label string) (Sensor, bool) {
+	for _, sensor := range s {
+		if sensor.Chip == chip && sensor.Label == label {
+			return sensor, true
+		}
+	}
+	return Sensor{}, false
+}
+
+// MaxTemperature returns the highest reading across all temperature sensors,
+// or zero (i.e. 0K) if there are no temperature sensors.
+func (s Sensors) MaxTemperature() unit.Temperature {
+	var max *Sensor
+	for idx, sensor := range s {
+		if sensor.Kind == Temperature && (max == nil || sensor.raw > max.raw) {
+			max = &s[idx]
+		}
+	}
+	if max == nil {
+		return 0
+	}
+	return max.Temperature()
+}
+
+// Filter is used to select sensors.
+type Filter func(Sensor) bool
+
+// Chip selects sensors from the named hwmon chip.
+func Chip(name string) Filter {
+	return func(s Sensor) bool { return s.Chip == name }
+}
+
+// Label selects sensors whose label matches the given shell pattern, e.g.
+// "Core *" for all per-core temperatures.
+func Label(pattern string) Filter {
+	return func(s Sensor) bool {
+		m, _ := path.Match(pattern, s.Label)
+		return m
+	}
+}
+
+// OfKind selects sensors of the given kind.
+func OfKind(k Kind) Filter {
+	return func(s Sensor) bool { return s.Kind == k }
+}
+
+func matchAll(s Sensor, filters []Filter) bool {
+	for _, f := range filters {
+		if !f(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Module represents a bar.Module that displays hwmon sensor readings.
+type Module struct {
+	filters    []Filter
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Sensors) bar.Output
+}
+
+// New constructs a sensors module for all sensors that match the given
+// filters. With no filters, all available sensors are included.
+func New(filters ...Filter) *Module {
+	m := &Module{filters: filters, scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "outputFunc")
+	m.RefreshInterval(3 * time.Second)
+	// Default output is the highest temperature, or the first reading if
+	// none of the sensors are temperature sensors.
+	m.Output(func(s Sensors) bar.Output {
+		if len(s.Filter(OfKind(Temperature))) > 0 {
+			return outputs.Textf("%.1f℃", s.MaxTemperature().Celsius())
+		}
+		return outputs.Text(s[0].String())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Sensors) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for sensor readings.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	sensors, err := m.read()
+	outputFunc := m.outputFunc.Get().(func(Sensors) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if s.Error(err) {
+			return
+		}
+		s.Output(outputFunc(sensors))
+		select {
+		case <-m.scheduler.C:
+			sensors, err = m.read()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Sensors) bar.Output)
+		}
+	}
+}
+
+func (m *Module) read() (Sensors, error) {
+	all, err := Read()
+	if err != nil {
+		return nil, err
+	}
+	s := all.Filter(m.filters...)
+	if len(s) == 0 {
+		return nil, errors.New("No matching sensors")
+	}
+	return s, nil
+}
+
+var fs = afero.NewOsFs()
+
+const hwmonDir = "/sys/class/hwmon"
+
+// Read returns the current readings of all available hwmon sensors.
+func Read() (Sensors, error) {
+	chips, err := afero.Glob(fs, filepath.Join(hwmonDir, "hwmon*"))
+	if err != nil {
+		return nil, err
+	}
+	var s Sensors
+	for _, chipDir := range chips {
+		chip := readString(filepath.Join(chipDir, "name"))
+		inputs, _ := afero.Glob(fs, filepath.Join(chipDir, "*_input"))
+		for _, input := range inputs {
+			name := strings.TrimSuffix(filepath.Base(input), "_input")
+			kind, ok := prefixes[strings.TrimRight(name, "0123456789")]
+			if !ok {
+				continue
+			}
+			raw, err := strconv.ParseInt(readString(input), 10, 64)
+			if err != nil {
+				// Some drivers expose inputs that cannot be read.
+				continue
+			}
+			label := readString(filepath.Join(chipDir, name+"_label"))
+			if label == "" {
+				label = name
+			}
+			s = append(s, Sensor{Chip: chip, Label: label, Kind: kind, raw: raw})
+		}
+	}
+	sort.SliceStable(s, func(a, b int) bool {
+		if s[a].Chip != s[b].Chip {
+			return s[a].Chip < s[b].Chip
+		}
+		return s[a].Label < s[b].Label
+	})
+	return s, nil
+}
+
+func readString(file string) string {
+	bytes, _ := afero.ReadFile(fs, file)
+	return strings.TrimSpace(string(bytes))
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sensors
+
+import (
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(chip string, files map[string]string) {
+	for name, val := range files {
+		afero.WriteFile(fs, "/sys/class/hwmon/"+chip+"/"+name, []byte(val+"\n"), 0644)
+	}
+}
+
+func setupSensors() {
+	fs = afero.NewMemMapFs()
+	writeFiles("hwmon0", map[string]string{
+		"name":        "acpitz",
+		"temp1_input": "27800",
+	})
+	writeFiles("hwmon2", map[string]string{
+		"name":        "coretemp",
+		"temp1_input": "52000",
+		"temp1_label": "Package id 0",
+		"temp2_input": "48000",
+		"temp2_label": "Core 0",
+		"temp3_input": "51000",
+		"temp3_label": "Core 1",
+	})
+	writeFiles("hwmon3", map[string]string{
+		"name":         "nct6775",
+		"fan1_input":   "1250",
+		"fan1_label":   "CPU Fan",
+		"in0_input":    "1208",
+		"power1_input": "15250000",
+		"curr1_input":  "500",
+		"temp7_input":  "invalid",
+		"intrusion0":   "0",
+	})
+}
+
+func TestRead(t *testing.T) {
+	setupSensors()
+	s, err := Read()
+	require.NoError(t, err)
+
+	var labels, values []string
+	for _, sensor := range s {
+		labels = append(labels, sensor.Chip+"/"+sensor.Label)
+		values = append(values, sensor.String())
+	}
+	require.Equal(t, []string{
+		"acpitz/temp1",
+		"coretemp/Core 0", "coretemp/Core 1", "coretemp/Package id 0",
+		"nct6775/CPU Fan", "nct6775/curr1", "nct6775/in0", "nct6775/power1",
+	}, labels)
+	require.Equal(t, []string{
+		"27.8℃",
+		"48.0℃", "51.0℃", "52.0℃",
+		"1250 RPM", "0.50A", "1.21V", "15.2W",
+	}, values)
+
+	fan, ok := s.Get("nct6775", "CPU Fan")
+	require.True(t, ok)
+	require.Equal(t, 1250, fan.RPM())
+	_, ok = s.Get("nct6775", "GPU Fan")
+	require.False(t, ok)
+
+	cores := s.Filter(Chip("coretemp"), Label("Core *"))
+	require.Len(t, cores, 2)
+	require.InDelta(t, 51.0, cores.MaxTemperature().Celsius(), 0.01)
+	require.InDelta(t, 52.0, s.MaxTemperature().Celsius(), 0.01)
+	require.Equal(t, 0.0, s.Filter(OfKind(Fan)).MaxTemperature().Kelvin())
+}
+
+func TestModule(t *testing.T) {
+	setupSensors()
+	testBar.New(t)
+
+	cores := New(Chip("coretemp"), Label("Core *"))
+	fans := New(OfKind(Fan))
+	testBar.Run(cores, fans)
+
+	out := testBar.LatestOutput(0, 1)
+	out.At(0).AssertText("51.0℃")
+	out.At(1).AssertText("1250 RPM")
+
+	writeFiles("hwmon2", map[string]string{"temp2_input": "71500"})
+	testBar.AssertNoOutput("until refresh")
+	testBar.Tick()
+	testBar.LatestOutput(0, 1).At(0).AssertText("71.5℃")
+
+	cores.Output(func(s Sensors) bar.Output {
+		return outputs.Textf("%d cores", len(s))
+	})
+	testBar.NextOutput("on output change").At(0).AssertText("2 cores")
+
+	testBar.New(t)
+	testBar.Run(New(Chip("k10temp")))
+	testBar.NextOutput("no sensors").At(0).AssertError("no matching sensors")
+}
@@ -17,6 +17,7 @@ package shell
 import (
 	"bufio"
 	"os/exec"
+	"sync"
 	"syscall"
 
 	"barista.run/bar"
@@ -30,6 +31,10 @@ type TailModule struct {
 	cmd  string
 	args []string
 	outf value.Value // of func(string) bar.Output
+
+	mu     sync.Mutex
+	pid    int // of the running command, or 0.
+	paused bool
 }
 
 // Tail constructs a module that displays the last line of output from a long
@@ -58,6 +63,10 @@ func (m *TailModule) Stream(s bar.Sink) {
 	if s.Error(cmd.Start()) {
 		return
 	}
+	m.mu.Lock()
+	m.pid = cmd.Process.Pid
+	m.signalLocked()
+	m.mu.Unlock()
 	var out *string
 	outf := m.outf.Get().(func(string) bar.Output)
 	errChan := make(chan error)
@@ -67,7 +76,11 @@ func (m *TailModule) Stream(s bar.Sink) {
 		for scanner.Scan() {
 			outChan <- scanner.Text()
 		}
-		errChan <- cmd.Wait()
+		err := cmd.Wait()
+		m.mu.Lock()
+		m.pid = 0
+		m.mu.Unlock()
+		errChan <- err
 	}()
 	for {
 		select {
@@ -90,3 +103,38 @@ func (m *TailModule) Output(format func(string) bar.Output) *TailModule {
 	m.outf.Set(format)
 	return m
 }
+
+// Pause suspends the command while the module is not visible, e.g. while the
+// bar is hidden, by stopping its process group.
+func (m *TailModule) Pause() {
+	m.setPaused(true)
+}
+
+// Resume continues the suspended command.
+func (m *TailModule) Resume() {
+	m.setPaused(false)
+}
+
+func (m *TailModule) setPaused(paused bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.paused == paused {
+		return
+	}
+	m.paused = paused
+	m.signalLocked()
+}
+
+// signalLocked stops or continues the running command, if any, to match
+// the paused state of the module.
+func (m *TailModule) signalLocked() {
+	if m.pid == 0 {
+		return
+	}
+	sig := syscall.SIGCONT
+	if m.paused {
+		sig = syscall.SIGSTOP
+	}
+	// The command is started in its own process group.
+	syscall.Kill(-m.pid, sig)
+}
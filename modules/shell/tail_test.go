@@ -59,3 +59,16 @@ func TestTailInvalidCommand(t *testing.T) {
 	testBar.NextOutput().AssertError(
 		"when starting an invalid command")
 }
+
+func TestTailPause(t *testing.T) {
+	testBar.New(t)
+	tail := Tail("bash", "-c", "for i in `seq 1 100`; do echo $i; sleep 0.02; done")
+	testBar.Run(tail)
+	testBar.NextOutput().AssertText([]string{"1"})
+
+	tail.Pause()
+	testBar.AssertNoOutput("while paused")
+
+	tail.Resume()
+	testBar.NextOutput("on resume").Expect("command continues")
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package smart provides an i3bar module that shows S.M.A.R.T. disk health
+// using smartctl (from smartmontools, version 7.0 or later).
+//
+// Drives that are spun down are not woken up to be queried, the last known
+// information is shown instead. Note that smartctl usually requires root
+// privileges, see Sudo.
+package smart // import "barista.run/modules/smart"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+)
+
+// Info represents the health of a drive.
+type Info struct {
+	Device string
+	Model  string
+	// Passed is the drive's overall self-assessment.
+	Passed      bool
+	Temperature unit.Temperature
+	// ReallocatedSectors is the number of bad sectors that have been remapped
+	// (ATA attribute 5).
+	ReallocatedSectors int
+	// PendingSectors is the number of unstable sectors waiting to be remapped
+	// (ATA attribute 197).
+	PendingSectors int
+	// MediaErrors is the number of unrecovered data integrity errors (NVMe).
+	MediaErrors int
+	// Standby is true if the drive was spun down at the last check, in which
+	// case all other information is from the previous successful check.
+	Standby bool
+	// Updated is the time of the last successful check.
+	Updated time.Time
+}
+
+// Failing returns true if the drive failed its self-assessment, or has
+// started to develop bad sectors.
+func (i Info) Failing() bool {
+	return !i.Passed || i.ReallocatedSectors > 0 ||
+		i.PendingSectors > 0 || i.MediaErrors > 0
+}
+
+// Module represents a bar.Module that displays S.M.A.R.T. information.
+type Module struct {
+	device     string
+	sudo       value.Value // of bool
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a module for the given device, e.g. "/dev/sda".
+func New(device string) *Module {
+	m := &Module{device: device, scheduler: timing.NewScheduler()}
+	l.Label(m, device)
+	l.Register(m, "sudo", "scheduler", "outputFunc")
+	m.sudo.Set(false)
+	m.RefreshInterval(10 * time.Minute)
+	m.Output(func(i Info) bar.Output {
+		name := filepath.Base(i.Device)
+		if i.Failing() {
+			return outputs.Textf("%s FAILING", name).Urgent(true)
+		}
+		if i.Updated.IsZero() {
+			return outputs.Textf("%s asleep", name)
+		}
+		return outputs.Textf("%s %.0f℃", name, i.Temperature.Celsius())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency. Drive health changes
+// slowly, so the default is 10 minutes.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Sudo configures the module to run smartctl using `sudo -n`. This requires
+// a sudoers rule that allows running smartctl without a password.
+func (m *Module) Sudo() *Module {
+	m.sudo.Set(true)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info := Info{Device: m.device, Passed: true}
+	err := m.update(&info)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	for {
+		if s.Error(err) {
+			return
+		}
+		s.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.C:
+			err = m.update(&info)
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// smartctl runs smartctl with the given arguments. Overridden in tests.
+var smartctl = func(sudo bool, args ...string) ([]byte, error) {
+	if sudo {
+		return exec.Command("sudo", append([]string{"-n", "smartctl"}, args...)...).Output()
+	}
+	return exec.Command("smartctl", args...).Output()
+}
+
+// smartctlOutput is the subset of smartctl's JSON output used by the module.
+type smartctlOutput struct {
+	Smartctl struct {
+		Messages []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+		ExitStatus int `json:"exit_status"`
+	} `json:"smartctl"`
+	ModelName   string `json:"model_name"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current float64 `json:"current"`
+	} `json:"temperature"`
+	AtaSmartAttributes struct {
+		Table []struct {
+			ID  int `json:"id"`
+			Raw struct {
+				Value int `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NvmeHealth struct {
+		MediaErrors int `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+// Bits in the smartctl exit status that indicate the command failed
+// (command line error, device open failed).
+const smartctlFailed = 0x3
+
+// update refreshes the info for the module's device, leaving it unchanged
+// (apart from Standby) if the drive is spun down.
+func (m *Module) update(i *Info) error {
+	// smartctl exits with a non-zero status for many reasons, including the
+	// drive health, so rely on the JSON exit_status instead.
+	stdout, err := smartctl(m.sudo.Get().(bool),
+		"--json", "--all", "--nocheck=standby", m.device)
+	var out smartctlOutput
+	if jsonErr := json.Unmarshal(stdout, &out); jsonErr != nil {
+		if err == nil {
+			err = jsonErr
+		}
+		return err
+	}
+	for _, msg := range out.Smartctl.Messages {
+		if strings.Contains(msg.String, "STANDBY") {
+			i.Standby = true
+			return nil
+		}
+	}
+	if out.Smartctl.ExitStatus&smartctlFailed != 0 {
+		for _, msg := range out.Smartctl.Messages {
+			if msg.Severity == "error" {
+				return errors.New(msg.String)
+			}
+		}
+		return fmt.Errorf("smartctl failed with status %d", out.Smartctl.ExitStatus)
+	}
+	i.Standby = false
+	i.Updated = timing.Now()
+	i.Model = out.ModelName
+	i.Passed = out.SmartStatus == nil || out.SmartStatus.Passed
+	i.Temperature = unit.FromCelsius(out.Temperature.Current)
+	i.MediaErrors = out.NvmeHealth.MediaErrors
+	for _, attr := range out.AtaSmartAttributes.Table {
+		switch attr.ID {
+		case 5:
+			i.ReallocatedSectors = attr.Raw.Value
+		case 197:
+			i.PendingSectors = attr.Raw.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smart
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
+)
+
+var mu sync.Mutex
+var nextOutput string
+var lastArgs []string
+
+func init() {
+	smartctl = func(sudo bool, args ...string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lastArgs = args
+		if sudo {
+			lastArgs = append([]string{"sudo"}, args...)
+		}
+		return []byte(nextOutput), errors.New("exit status 4")
+	}
+}
+
+func shouldReturn(out string) {
+	mu.Lock()
+	defer mu.Unlock()
+	nextOutput = out
+}
+
+const healthyATA = `{
+  "smartctl": {"exit_status": 0},
+  "model_name": "Some SSD",
+  "smart_status": {"passed": true},
+  "temperature": {"current": 34},
+  "ata_smart_attributes": {"table": [
+    {"id": 5, "name": "Reallocated_Sector_Ct", "raw": {"value": 0}},
+    {"id": 9, "name": "Power_On_Hours", "raw": {"value": 12345}},
+    {"id": 197, "name": "Current_Pending_Sector", "raw": {"value": 0}}
+  ]}
+}`
+
+const failingATA = `{
+  "smartctl": {"exit_status": 4},
+  "model_name": "Some SSD",
+  "smart_status": {"passed": true},
+  "temperature": {"current": 41},
+  "ata_smart_attributes": {"table": [
+    {"id": 5, "name": "Reallocated_Sector_Ct", "raw": {"value": 8}},
+    {"id": 197, "name": "Current_Pending_Sector", "raw": {"value": 0}}
+  ]}
+}`
+
+const standby = `{
+  "smartctl": {
+    "messages": [{"string": "Device is in STANDBY mode, exit(2)", "severity": "information"}],
+    "exit_status": 2
+  }
+}`
+
+func TestSmart(t *testing.T) {
+	testBar.New(t)
+	shouldReturn(healthyATA)
+	sda := New("/dev/sda")
+	testBar.Run(sda)
+	testBar.NextOutput("on start").AssertText([]string{"sda 34℃"})
+	require.Equal(t, []string{"--json", "--all", "--nocheck=standby", "/dev/sda"}, lastArgs)
+
+	shouldReturn(standby)
+	sda.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s %v %.0f", i.Model, i.Standby, i.Temperature.Celsius())
+	})
+	testBar.NextOutput("on output change").AssertText([]string{"Some SSD false 34"})
+	testBar.Tick()
+	testBar.NextOutput("on standby").AssertText([]string{"Some SSD true 34"})
+
+	shouldReturn(failingATA)
+	testBar.Tick()
+	testBar.NextOutput("on wake").AssertText([]string{"Some SSD false 41"})
+}
+
+func TestFailing(t *testing.T) {
+	testBar.New(t)
+	shouldReturn(failingATA)
+	testBar.Run(New("/dev/sdb").Sudo())
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"sdb FAILING"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+	require.Equal(t, "sudo", lastArgs[0])
+
+	for _, tc := range []struct {
+		desc    string
+		info    Info
+		failing bool
+	}{
+		{"healthy", Info{Passed: true}, false},
+		{"self-assessment", Info{Passed: false}, true},
+		{"pending sectors", Info{Passed: true, PendingSectors: 1}, true},
+		{"nvme media errors", Info{Passed: true, MediaErrors: 2}, true},
+	} {
+		require.Equal(t, tc.failing, tc.info.Failing(), tc.desc)
+	}
+}
+
+func TestStandbyOnStart(t *testing.T) {
+	testBar.New(t)
+	shouldReturn(standby)
+	testBar.Run(New("/dev/sdc"))
+	testBar.NextOutput("on start").AssertText([]string{"sdc asleep"})
+}
+
+func TestErrors(t *testing.T) {
+	testBar.New(t)
+	shouldReturn(`{"smartctl": {
+		"messages": [{"string": "Smartctl open device: /dev/sdz failed", "severity": "error"}],
+		"exit_status": 2
+	}}`)
+	testBar.Run(New("/dev/sdz"))
+	testBar.NextOutput("on open failure").At(0).AssertError()
+
+	testBar.New(t)
+	shouldReturn(`{"smartctl": {"exit_status": 1}}`)
+	testBar.Run(New("/dev/sdz"))
+	testBar.NextOutput("on bad command line").At(0).AssertError()
+
+	testBar.New(t)
+	shouldReturn("smartctl: command not found")
+	testBar.Run(New("/dev/sdz"))
+	testBar.NextOutput("on missing smartctl").At(0).AssertError()
+}
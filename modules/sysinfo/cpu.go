@@ -0,0 +1,107 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysinfo
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+var fs = afero.NewOsFs()
+
+// cpuTimes holds the cumulative busy and total jiffies for a cpu.
+type cpuTimes struct {
+	busy, total uint64
+}
+
+// lastCPUTimes stores the cpu times from the previous update, keyed by the
+// name in /proc/stat ("cpu" for the aggregate, "cpuN" for each core).
+var lastCPUTimes map[string]cpuTimes
+
+// readCPUTimes reads cumulative cpu times from /proc/stat.
+func readCPUTimes() (map[string]cpuTimes, []string) {
+	bytes, err := afero.ReadFile(fs, "/proc/stat")
+	if err != nil {
+		return nil, nil
+	}
+	times := map[string]cpuTimes{}
+	var cores []string
+	for _, line := range strings.Split(string(bytes), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		// user nice system idle iowait irq softirq steal; guest time is
+		// already included in user and nice.
+		var t cpuTimes
+		for idx, f := range fields[1:9] {
+			v, _ := strconv.ParseUint(f, 10, 64)
+			t.total += v
+			if idx != 3 && idx != 4 {
+				t.busy += v
+			}
+		}
+		times[fields[0]] = t
+		if fields[0] != "cpu" {
+			cores = append(cores, fields[0])
+		}
+	}
+	return times, cores
+}
+
+// updateCPU sets the cpu usage in info to the utilization since the last
+// update. Usage is left at zero on the first update, or if /proc/stat is
+// not available.
+func updateCPU(info *Info) {
+	times, cores := readCPUTimes()
+	last := lastCPUTimes
+	lastCPUTimes = times
+	if last == nil {
+		return
+	}
+	usage := func(name string) float64 {
+		prev, ok := last[name]
+		cur := times[name]
+		if !ok || cur.total <= prev.total {
+			return 0
+		}
+		return float64(cur.busy-prev.busy) / float64(cur.total-prev.total)
+	}
+	info.CPUUsage = usage("cpu")
+	for _, core := range cores {
+		info.CoreUsage = append(info.CoreUsage, usage(core))
+	}
+}
+
+var graphBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// CoreGraph returns a bar graph of the usage of each core, using one block
+// character per core, e.g. "▁▃█▂".
+func (i Info) CoreGraph() string {
+	var graph strings.Builder
+	for _, u := range i.CoreUsage {
+		idx := int(u * float64(len(graphBlocks)))
+		if idx >= len(graphBlocks) {
+			idx = len(graphBlocks) - 1
+		}
+		if idx < 0 {
+			idx = 0
+		}
+		graph.WriteRune(graphBlocks[idx])
+	}
+	return graph.String()
+}
@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package sysinfo implements i3bar modules that show system information.
+// Package sysinfo implements i3bar modules that show system information,
+// including load averages and per-core cpu usage.
 package sysinfo // import "barista.run/modules/sysinfo"
 
 import (
@@ -42,6 +43,11 @@ type Info struct {
 	Procs        uint16
 	TotalHighRAM unit.Datasize
 	FreeHighRAM  unit.Datasize
+	// CPUUsage is the overall cpu utilization since the last update, in
+	// the range [0, 1].
+	CPUUsage float64
+	// CoreUsage is the utilization of each cpu core since the last update.
+	CoreUsage []float64
 }
 
 // currentInfo stores the last value read by the updater.
@@ -99,6 +105,8 @@ func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
 
 // Stream subscribes to sysinfo and updates the module's output.
 func (m *Module) Stream(s bar.Sink) {
+	// Updates are shared, so only stop while all modules are paused.
+	updater.OwnedBy(m)
 	i, err := currentInfo.Get()
 	nextInfo, done := currentInfo.Subscribe()
 	defer done()
@@ -146,6 +154,7 @@ func update() {
 		TotalHighRAM: unit.Datasize(sysinfoT.Totalhigh) * mult,
 		FreeHighRAM:  unit.Datasize(sysinfoT.Freehigh) * mult,
 	}
+	updateCPU(&sysinfo)
 	currentInfo.Set(sysinfo)
 }
 
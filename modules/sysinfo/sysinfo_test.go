@@ -27,6 +27,7 @@ import (
 	testBar "barista.run/testing/bar"
 	"barista.run/timing"
 
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sys/unix"
 )
@@ -59,6 +60,8 @@ var mockSysinfo = func(out *unix.Sysinfo_t) error {
 func resetForTest() {
 	shouldReturn(unix.Sysinfo_t{})
 	sysinfo = mockSysinfo
+	fs = afero.NewMemMapFs()
+	lastCPUTimes = nil
 	currentInfo = &value.ErrorValue{}
 	once = sync.Once{}
 	construct()
@@ -143,3 +146,44 @@ func TestErrors(t *testing.T) {
 	errs = testBar.NextOutput().AssertError("on next tick with error")
 	require.Equal("something else", errs[0], "new error is propagated")
 }
+
+func TestCPU(t *testing.T) {
+	testBar.New(t)
+	resetForTest()
+	afero.WriteFile(fs, "/proc/stat", []byte(`
+cpu  100 0 100 800 0 0 0 0 0 0
+cpu0 50 0 50 400 0 0 0 0 0 0
+cpu1 50 0 50 400 0 0 0 0 0 0
+intr 12345 0 0
+`), 0644)
+
+	cpu := New().Output(func(i Info) bar.Output {
+		return outputs.Textf("%.2f %v %s", i.CPUUsage, i.CoreUsage, i.CoreGraph())
+	})
+	testBar.Run(cpu)
+	testBar.NextOutput("on start").AssertText(
+		[]string{"0.00 [] "}, "no usage without previous sample")
+
+	testBar.Tick()
+	testBar.NextOutput("first sample").AssertText(
+		[]string{"0.00 [] "}, "no usage until the second sample")
+
+	afero.WriteFile(fs, "/proc/stat", []byte(`
+cpu  200 0 150 900 50 0 0 0 0 0
+cpu0 150 0 100 400 0 0 0 0 0 0
+cpu1 50 0 50 500 50 0 0 0 0 0
+`), 0644)
+	testBar.Tick()
+	testBar.NextOutput("on tick").AssertText(
+		[]string{"0.50 [1 0] █▁"}, "busy core, idle/iowait core")
+
+	afero.WriteFile(fs, "/proc/stat", []byte(`
+cpu  300 0 200 1000 50 0 0 0 0 0
+cpu0 200 0 150 450 0 0 0 0 0 0
+cpu1 100 0 50 550 50 0 0 0 0 0
+cpu2 0 0 0 0 0 0 0 0 0 0
+`), 0644)
+	testBar.Tick()
+	testBar.NextOutput("on core added").AssertText(
+		[]string{"0.60 [0.6666666666666666 0.5 0] ▆▅▁"})
+}
@@ -22,6 +22,7 @@ import (
 	"barista.run/base/watchers/dbus"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
+	fakedbus "barista.run/testing/dbus"
 	"barista.run/timing"
 	"github.com/stretchr/testify/require"
 )
@@ -32,39 +33,26 @@ func init() {
 
 func TestService(t *testing.T) {
 	testBar.New(t)
-	bus := dbus.SetupTestBus()
-	sysd := bus.RegisterService("org.freedesktop.systemd1")
+	sysd := fakedbus.New().Systemd()
 
-	unit0 := sysd.Object("/org/freedesktop/systemd1/unit/foo_2eservice",
-		"org.freedesktop.systemd1.Unit")
-	unit0.SetProperties(map[string]interface{}{
-		"Id":                   "foo.service",
+	unit0, _ := sysd.Unit("foo.service", map[string]interface{}{
 		"Description":          "A service that foos",
 		"ActiveState":          "active",
 		"SubState":             "running",
 		"StateChangeTimestamp": uint64(timing.Now().UnixNano() / 1000),
-	}, dbus.SignalTypeNone)
-	srv0 := sysd.Object("/org/freedesktop/systemd1/unit/foo_2eservice",
-		"org.freedesktop.systemd1.Service")
-	srv0.SetProperties(map[string]interface{}{
+	}, map[string]interface{}{
 		"Type":        "oneshot",
 		"MainPID":     uint32(941),
 		"ExecMainPID": uint32(931),
-	}, dbus.SignalTypeNone)
+	})
 
-	unit1 := sysd.Object("/org/freedesktop/systemd1/unit/baz_2dsrv_2eservice",
-		"org.freedesktop.systemd1.Unit")
-	unit1.SetProperties(map[string]interface{}{
-		"Id":          "baz-srv.service",
+	sysd.Unit("baz-srv.service", map[string]interface{}{
 		"Description": "A service that services baz",
 		"ActiveState": "inactive",
 		"SubState":    "dead",
-	}, dbus.SignalTypeNone)
-	srv1 := sysd.Object("/org/freedesktop/systemd1/unit/baz_2dsrv_2eservice",
-		"org.freedesktop.systemd1.Service")
-	srv1.SetProperties(map[string]interface{}{
+	}, map[string]interface{}{
 		"Type": "oneshot",
-	}, dbus.SignalTypeNone)
+	})
 
 	m0 := Service("foo")
 	m1 := Service("baz-srv")
@@ -124,24 +112,17 @@ func TestService(t *testing.T) {
 
 func TestTimer(t *testing.T) {
 	testBar.New(t)
-	bus := dbus.SetupTestBus()
-	sysd := bus.RegisterService("org.freedesktop.systemd1")
+	sysd := fakedbus.New().Systemd()
 
-	unit0 := sysd.Object("/org/freedesktop/systemd1/unit/foo_2etimer",
-		"org.freedesktop.systemd1.Unit")
-	unit0.SetProperties(map[string]interface{}{
-		"Id":                   "foo.timer",
+	unit0, tim0 := sysd.Unit("foo.timer", map[string]interface{}{
 		"Description":          "A timer for the foo service",
 		"ActiveState":          "active",
 		"SubState":             "waiting",
 		"StateChangeTimestamp": uint64(timing.Now().Add(-720*time.Hour).UnixNano() / 1000),
-	}, dbus.SignalTypeNone)
-	tim0 := sysd.Object("/org/freedesktop/systemd1/unit/foo_2etimer",
-		"org.freedesktop.systemd1.Timer")
-	tim0.SetProperties(map[string]interface{}{
+	}, map[string]interface{}{
 		"Unit":                   "foo.service",
 		"NextElapseUSecRealtime": uint64(timing.Now().Add(6*time.Hour).UnixNano() / 1000),
-	}, dbus.SignalTypeNone)
+	})
 
 	m0 := Timer("foo")
 	testBar.Run(m0)
@@ -0,0 +1,176 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// taskExport runs "task export" for pending tasks. Overridden in tests.
+var taskExport = func() ([]byte, error) {
+	return exec.Command("task",
+		"rc.verbose=nothing", "rc.hooks=off", "status:pending", "export").Output()
+}
+
+type taskwarrior struct {
+	dataDir string
+}
+
+// Taskwarrior returns a backend that lists pending tasks using the task
+// command, ordered by urgency. The task data file is watched for changes.
+func Taskwarrior() FileBackend {
+	dir := os.Getenv("TASKDATA")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".task")
+	}
+	return taskwarrior{dir}
+}
+
+func (t taskwarrior) File() string {
+	// Taskwarrior 3 replaced the data files with a database.
+	db := filepath.Join(t.dataDir, "taskchampion.sqlite3")
+	if _, err := os.Stat(db); err == nil {
+		return db
+	}
+	return filepath.Join(t.dataDir, "pending.data")
+}
+
+type twTask struct {
+	Description string
+	Due         string
+	Priority    string
+	Project     string
+	Tags        []string
+	Urgency     float64
+}
+
+func (t taskwarrior) Tasks() ([]Task, error) {
+	out, err := taskExport()
+	if err != nil {
+		return nil, err
+	}
+	var twTasks []twTask
+	if err := json.Unmarshal(out, &twTasks); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(twTasks, func(i, j int) bool {
+		return twTasks[i].Urgency > twTasks[j].Urgency
+	})
+	tasks := make([]Task, len(twTasks))
+	for i, tw := range twTasks {
+		tasks[i] = Task{
+			Description: tw.Description,
+			Priority:    tw.Priority,
+			Project:     tw.Project,
+			Tags:        tw.Tags,
+		}
+		if tw.Due != "" {
+			tasks[i].Due, err = time.Parse("20060102T150405Z", tw.Due)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return tasks, nil
+}
+
+type todoTxt string
+
+// TodoTxt returns a backend that reads pending tasks from a todo.txt file
+// (see http://todotxt.org). Due dates are read from "due:YYYY-MM-DD" tags.
+// Tasks are ordered by due date, then priority.
+func TodoTxt(path string) FileBackend {
+	return todoTxt(path)
+}
+
+func (t todoTxt) File() string {
+	return string(t)
+}
+
+var priorityRe = regexp.MustCompile(`^\(([A-Z])\) `)
+var dateRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `)
+
+func (t todoTxt) Tasks() ([]Task, error) {
+	data, err := ioutil.ReadFile(string(t))
+	if err != nil {
+		return nil, err
+	}
+	var tasks []Task
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "x ") {
+			// Skip empty lines and completed tasks.
+			continue
+		}
+		var task Task
+		if m := priorityRe.FindStringSubmatch(line); m != nil {
+			task.Priority = m[1]
+			line = line[len(m[0]):]
+		}
+		// Skip the creation date.
+		line = dateRe.ReplaceAllString(line, "")
+		var desc []string
+		for _, word := range strings.Fields(line) {
+			switch {
+			case strings.HasPrefix(word, "due:"):
+				due, err := time.ParseInLocation("2006-01-02", word[4:], time.Local)
+				if err == nil {
+					task.Due, task.AllDay = due, true
+					continue
+				}
+			case len(word) > 1 && word[0] == '+':
+				if task.Project == "" {
+					task.Project = word[1:]
+				}
+			case len(word) > 1 && word[0] == '@':
+				task.Tags = append(task.Tags, word[1:])
+			}
+			desc = append(desc, word)
+		}
+		task.Description = strings.Join(desc, " ")
+		tasks = append(tasks, task)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		if a.Due.IsZero() != b.Due.IsZero() {
+			return !a.Due.IsZero()
+		}
+		if !a.Due.Equal(b.Due) {
+			return a.Due.Before(b.Due)
+		}
+		// Tasks without a priority sort last.
+		pa, pb := a.Priority, b.Priority
+		if pa == "" {
+			pa = "~"
+		}
+		if pb == "" {
+			pb = "~"
+		}
+		return pa < pb
+	})
+	return tasks, s.Err()
+}
@@ -0,0 +1,215 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tasks provides an i3bar module that shows pending tasks from a
+// todo list, such as Taskwarrior or a todo.txt file.
+package tasks // import "barista.run/modules/tasks"
+
+import (
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/base/watchers/file"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Task represents a single pending task.
+type Task struct {
+	Description string
+	// Due is the due date of the task, or zero if the task has no due date.
+	Due time.Time
+	// AllDay is true if the task is due on a date rather than a specific
+	// time, in which case it is overdue only from the following day.
+	AllDay   bool
+	Priority string
+	Project  string
+	Tags     []string
+}
+
+// Overdue returns true if the task is past its due date.
+func (t Task) Overdue() bool {
+	if t.Due.IsZero() {
+		return false
+	}
+	now := timing.Now()
+	if t.AllDay {
+		return startOfDay(t.Due).Before(startOfDay(now))
+	}
+	return t.Due.Before(now)
+}
+
+// DueToday returns true if the task is due today, but not yet overdue.
+func (t Task) DueToday() bool {
+	return !t.Due.IsZero() && !t.Overdue() &&
+		startOfDay(t.Due).Equal(startOfDay(timing.Now()))
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.In(time.Local)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// Info represents the pending tasks.
+type Info struct {
+	// Tasks contains all pending tasks, in the order provided by the backend,
+	// usually most important first.
+	Tasks []Task
+	index int
+}
+
+// Count returns the number of pending tasks.
+func (i Info) Count() int {
+	return len(i.Tasks)
+}
+
+// Overdue returns the number of overdue tasks.
+func (i Info) Overdue() int {
+	c := 0
+	for _, t := range i.Tasks {
+		if t.Overdue() {
+			c++
+		}
+	}
+	return c
+}
+
+// DueToday returns the number of tasks due today that are not yet overdue.
+func (i Info) DueToday() int {
+	c := 0
+	for _, t := range i.Tasks {
+		if t.DueToday() {
+			c++
+		}
+	}
+	return c
+}
+
+// Current returns the task currently selected by scrolling, or false if
+// there are no pending tasks.
+func (i Info) Current() (Task, bool) {
+	if len(i.Tasks) == 0 {
+		return Task{}, false
+	}
+	return i.Tasks[i.index], true
+}
+
+// Backend is an interface for sources of tasks.
+type Backend interface {
+	Tasks() ([]Task, error)
+}
+
+// FileBackend is a Backend that stores tasks in a file, which is watched for
+// changes in addition to the regular refresh.
+type FileBackend interface {
+	Backend
+	File() string
+}
+
+// Module represents a bar.Module that displays pending tasks.
+type Module struct {
+	backend    Backend
+	scheduler  *timing.Scheduler
+	offset     value.Value // of int
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a tasks module using the given backend.
+func New(backend Backend) *Module {
+	m := &Module{backend: backend, scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "offset", "outputFunc")
+	m.offset.Set(0)
+	m.RefreshInterval(5 * time.Minute)
+	m.Output(func(i Info) bar.Output {
+		t, ok := i.Current()
+		if !ok {
+			return nil
+		}
+		return outputs.Textf("%d overdue, %d today: %s",
+			i.Overdue(), i.DueToday(), t.Description).
+			Urgent(i.Overdue() > 0)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency. Tasks are also refreshed
+// whenever the file of a FileBackend changes, and the counts are updated at
+// midnight.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	var updates <-chan struct{}
+	var errs <-chan error
+	if fb, ok := m.backend.(FileBackend); ok {
+		w := file.Watch(fb.File())
+		defer w.Unsubscribe()
+		updates, errs = w.Updates, w.Errors
+	}
+	tasks, err := m.backend.Tasks()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextOffset, done := m.offset.Subscribe()
+	defer done()
+	midnight := timing.NewScheduler()
+	for {
+		// Due today and overdue counts change at midnight.
+		midnight.At(startOfDay(timing.Now()).AddDate(0, 0, 1))
+		if !s.Error(err) {
+			info := Info{Tasks: tasks}
+			if len(tasks) > 0 {
+				info.index = m.offset.Get().(int) % len(tasks)
+				if info.index < 0 {
+					info.index += len(tasks)
+				}
+			}
+			s.Output(outputs.Group(outputFunc(info)).OnClick(m.click))
+		}
+		select {
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextOffset:
+		case <-midnight.C:
+		case <-m.scheduler.C:
+			tasks, err = m.backend.Tasks()
+		case <-updates:
+			tasks, err = m.backend.Tasks()
+		case err := <-errs:
+			s.Error(err)
+			return
+		}
+	}
+}
+
+// click cycles through tasks on scroll.
+func (m *Module) click(e bar.Event) {
+	switch e.Button {
+	case bar.ScrollUp, bar.ScrollLeft:
+		m.offset.Set(m.offset.Get().(int) - 1)
+	case bar.ScrollDown, bar.ScrollRight:
+		m.offset.Set(m.offset.Get().(int) + 1)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func date(offset int) string {
+	return timing.Now().AddDate(0, 0, offset).Format("2006-01-02")
+}
+
+func TestTodoTxt(t *testing.T) {
+	testBar.New(t)
+	dir, err := ioutil.TempDir("", "tasks")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "todo.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte(strings.Join([]string{
+		"(B) 2016-11-01 Call Mom +Family @phone due:" + date(0),
+		"x 2016-11-20 2016-11-01 Completed task due:" + date(-3),
+		"Water plants due:" + date(-1),
+		"",
+		"(A) Someday +Garden",
+		"(A) File taxes due:" + date(0),
+		"Read book",
+	}, "\n")), 0644))
+
+	m := New(TodoTxt(path))
+	m.Output(func(i Info) bar.Output {
+		t, _ := i.Current()
+		return outputs.Textf("%d/%d/%d: %s", i.Overdue(), i.DueToday(), i.Count(),
+			t.Description)
+	})
+	testBar.Run(m)
+	out := testBar.NextOutput("initial tasks")
+	out.AssertText([]string{"1/2/5: Water plants"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll")
+	out.AssertText([]string{"1/2/5: File taxes"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll")
+	out.AssertText([]string{"1/2/5: Call Mom +Family @phone"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.NextOutput("on scroll").AssertText([]string{"1/2/5: File taxes"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on scroll")
+	out.AssertText([]string{"1/2/5: Water plants"})
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.NextOutput("wraps around").AssertText([]string{"1/2/5: Read book"})
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(
+		"Water plants due:"+date(-1)+"\n"), 0644))
+	testBar.Drain(time.Second, "on file change").
+		AssertText([]string{"1/0/1: Water plants"})
+
+	timing.AdvanceBy(24 * time.Hour)
+	testBar.NextOutput("on tick").AssertText([]string{"1/0/1: Water plants"})
+
+	require.NoError(t, ioutil.WriteFile(path, nil, 0644))
+	testBar.Drain(time.Second, "no tasks").AssertText([]string{"0/0/0: "})
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	dir, err := ioutil.TempDir("", "tasks")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "todo.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte(
+		"(A) File taxes due:"+date(-1)+"\nRead book due:"+date(0)+"\n"), 0644))
+
+	testBar.Run(New(TodoTxt(path)))
+	out := testBar.NextOutput("default output")
+	out.AssertText([]string{"1 overdue, 1 today: File taxes"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent, "urgent with overdue tasks")
+
+	require.NoError(t, ioutil.WriteFile(path, nil, 0644))
+	testBar.Drain(time.Second, "no tasks").AssertEmpty()
+
+	testBar.New(t)
+	testBar.Run(New(TodoTxt(filepath.Join(dir, "missing.txt"))))
+	testBar.NextOutput("missing file").At(0).AssertError()
+}
+
+func TestTaskwarrior(t *testing.T) {
+	testBar.New(t)
+	export := `[
+		{"id":1,"description":"Low","status":"pending","urgency":1.5},
+		{"id":2,"description":"Review PR","status":"pending","project":"work",
+		 "priority":"H","tags":["code"],"due":"20161125T200000Z","urgency":12.3},
+		{"id":3,"description":"Lunch","status":"pending",
+		 "due":"20161125T230000Z","urgency":4}
+	]`
+	var exportErr error
+	taskExport = func() ([]byte, error) { return []byte(export), exportErr }
+
+	tw := Taskwarrior()
+	tasks, err := tw.Tasks()
+	require.NoError(t, err)
+	require.Equal(t, []Task{
+		{
+			Description: "Review PR",
+			Due:         time.Date(2016, time.November, 25, 20, 0, 0, 0, time.UTC),
+			Priority:    "H",
+			Project:     "work",
+			Tags:        []string{"code"},
+		},
+		{
+			Description: "Lunch",
+			Due:         time.Date(2016, time.November, 25, 23, 0, 0, 0, time.UTC),
+		},
+		{Description: "Low"},
+	}, tasks)
+	require.True(t, tasks[0].Overdue())
+	require.False(t, tasks[1].Overdue())
+	require.False(t, tasks[2].Overdue())
+	require.False(t, tasks[2].DueToday())
+
+	export = `[{"description":"Bad date","due":"tomorrow"}]`
+	_, err = tw.Tasks()
+	require.Error(t, err, "bad due date")
+
+	export = `{`
+	_, err = tw.Tasks()
+	require.Error(t, err, "bad json")
+
+	exportErr = errors.New("task not found")
+	_, err = tw.Tasks()
+	require.Error(t, err, "command error")
+}
+
+func TestTaskwarriorFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tasks")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	oldEnv := os.Getenv("TASKDATA")
+	defer os.Setenv("TASKDATA", oldEnv)
+	os.Setenv("TASKDATA", dir)
+
+	require.Equal(t, filepath.Join(dir, "pending.data"), Taskwarrior().File())
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "taskchampion.sqlite3"), nil, 0644))
+	require.Equal(t, filepath.Join(dir, "taskchampion.sqlite3"), Taskwarrior().File())
+
+	os.Setenv("TASKDATA", "")
+	home, _ := os.UserHomeDir()
+	require.Equal(t, filepath.Join(home, ".task", "pending.data"), Taskwarrior().File())
+}
+
+func TestDue(t *testing.T) {
+	testBar.New(t)
+	now := timing.Now()
+	for _, tc := range []struct {
+		task     Task
+		overdue  bool
+		dueToday bool
+	}{
+		{Task{}, false, false},
+		{Task{Due: now.Add(-time.Minute)}, true, false},
+		{Task{Due: now.Add(time.Minute)}, false, true},
+		{Task{Due: now.AddDate(0, 0, 1)}, false, false},
+		{Task{Due: startOfDay(now), AllDay: true}, false, true},
+		{Task{Due: startOfDay(now).AddDate(0, 0, -1), AllDay: true}, true, false},
+		{Task{Due: startOfDay(now).AddDate(0, 0, 1), AllDay: true}, false, false},
+	} {
+		desc := fmt.Sprintf("%+v", tc.task)
+		require.Equal(t, tc.overdue, tc.task.Overdue(), desc)
+		require.Equal(t, tc.dueToday, tc.task.DueToday(), desc)
+	}
+}
@@ -0,0 +1,470 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package timer provides a pomodoro-style countdown timer, alternating between
+work phases and short or long breaks.
+
+By default, left click starts or pauses the timer, right click resets the
+current phase (or the whole cycle, if the phase is already reset), middle
+click skips to the next phase, and scrolling adjusts the remaining time by
+a minute.
+
+The timer state is saved using the state package, so a running timer
+continues across bar restarts.
+*/
+package timer // import "barista.run/modules/timer"
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	persist "barista.run/state"
+	"barista.run/timing"
+)
+
+// Phase represents a phase of the pomodoro cycle.
+type Phase int
+
+// Valid values for Phase.
+const (
+	Work Phase = iota
+	ShortBreak
+	LongBreak
+)
+
+func (p Phase) String() string {
+	switch p {
+	case Work:
+		return "work"
+	case ShortBreak:
+		return "short break"
+	case LongBreak:
+		return "long break"
+	}
+	return fmt.Sprintf("Phase(%d)", int(p))
+}
+
+// IsBreak returns true for both short and long breaks.
+func (p Phase) IsBreak() bool {
+	return p == ShortBreak || p == LongBreak
+}
+
+// state is the persisted state of the timer.
+type state struct {
+	Phase Phase `json:"phase"`
+	// Completed is the number of work phases completed in this cycle.
+	Completed int `json:"completed"`
+	// Duration is the full length of the current phase.
+	Duration time.Duration `json:"duration"`
+	// Remaining is the time left in the current phase, if not running.
+	Remaining time.Duration `json:"remaining"`
+	// End is the time at which the current phase ends, if running.
+	End time.Time `json:"end,omitempty"`
+}
+
+func (s state) running() bool {
+	return !s.End.IsZero()
+}
+
+// Info represents the current state of the timer.
+type Info struct {
+	Phase Phase
+	// Remaining is the time left in the current phase.
+	Remaining time.Duration
+	// Duration is the full length of the current phase.
+	Duration time.Duration
+	// Completed is the number of work phases completed since the last long
+	// break.
+	Completed int
+	// Running is true if the timer is counting down.
+	Running bool
+}
+
+// Paused returns true if the timer was started, but is not currently
+// running.
+func (i Info) Paused() bool {
+	return !i.Running && i.Remaining < i.Duration
+}
+
+// Stopped returns true if the timer has not been started in this phase.
+func (i Info) Stopped() bool {
+	return !i.Running && i.Remaining >= i.Duration
+}
+
+// Progress returns the elapsed fraction of the current phase, from 0 to 1.
+func (i Info) Progress() float64 {
+	if i.Duration <= 0 {
+		return 0
+	}
+	return 1 - float64(i.Remaining)/float64(i.Duration)
+}
+
+// Module represents a pomodoro timer bar.Module.
+type Module struct {
+	mu         sync.Mutex
+	durations  [3]time.Duration
+	longEvery  int
+	autoStart  bool
+	name       string
+	store      *persist.Store
+	onEnd      []func(Phase)
+	state      value.Value // of state
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a new pomodoro timer, with 25 minute work phases, 5 minute
+// short breaks, and a 15 minute long break after every 4 work phases.
+// The state is saved in the state store under the given name, which should
+// be unique to each timer in the bar.
+func New(name string) *Module {
+	m := &Module{
+		durations: [3]time.Duration{25 * time.Minute, 5 * time.Minute, 15 * time.Minute},
+		longEvery: 4,
+		name:      name,
+		store:     persist.Open("timer"),
+	}
+	l.Register(m, "state", "outputFunc")
+	l.Label(m, name)
+	m.state.Set(m.phaseState(Work, 0))
+	m.Output(func(i Info) bar.Output {
+		// Round up, so that 00:00 is never shown while running.
+		rem := (i.Remaining + time.Second - 1) / time.Second
+		paused := ""
+		if i.Paused() {
+			paused = " (paused)"
+		}
+		return outputs.Textf("%s %02d:%02d%s", i.Phase, rem/60, rem%60, paused)
+	})
+	return m
+}
+
+// Durations sets the lengths of the work phase, short break, and long break.
+// A saved state keeps the length of its current phase.
+func (m *Module) Durations(work, shortBreak, longBreak time.Duration) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.durations = [3]time.Duration{work, shortBreak, longBreak}
+	m.state.Set(m.phaseState(Work, 0))
+	return m
+}
+
+// LongBreakEvery sets the number of work phases after which the break is a
+// long break.
+func (m *Module) LongBreakEvery(count int) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.longEvery = count
+	return m
+}
+
+// AutoStart configures whether the timer starts the next phase automatically
+// when a phase ends, or waits for it to be started manually (the default).
+func (m *Module) AutoStart(autoStart bool) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.autoStart = autoStart
+	return m
+}
+
+// StateFile sets the file used to save the timer state across restarts,
+// instead of the "timer" namespace of the state store. An empty path
+// disables saving the state.
+func (m *Module) StateFile(path string) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = nil
+	if path != "" {
+		m.store = persist.OpenFile(path)
+	}
+	return m
+}
+
+// OnPhaseEnd adds a function that is called (in a new goroutine) with the
+// phase that just ended, whenever a phase runs to completion.
+func (m *Module) OnPhaseEnd(fn func(Phase)) *Module {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onEnd = append(m.onEnd, fn)
+	return m
+}
+
+// For tests.
+var runCommand = func(env []string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	return cmd.Run()
+}
+
+// RunOnPhaseEnd runs a command whenever a phase runs to completion, for
+// example to show a notification or play a sound. The phase that ended is
+// available to the command in the BARISTA_TIMER_PHASE environment variable.
+func (m *Module) RunOnPhaseEnd(name string, args ...string) *Module {
+	return m.OnPhaseEnd(func(p Phase) {
+		err := runCommand([]string{"BARISTA_TIMER_PHASE=" + p.String()}, name, args...)
+		if err != nil {
+			l.Log("%s: command on %s end failed: %v", l.ID(m), p, err)
+		}
+	})
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Start starts or resumes the timer.
+func (m *Module) Start() {
+	m.update(func(s *state) {
+		if !s.running() {
+			s.End = timing.Now().Add(s.Remaining)
+		}
+	})
+}
+
+// Pause pauses the timer.
+func (m *Module) Pause() {
+	m.update(func(s *state) {
+		if s.running() {
+			s.Remaining = s.End.Sub(timing.Now())
+			s.End = time.Time{}
+		}
+	})
+}
+
+// Toggle starts the timer if paused, or pauses it if running.
+func (m *Module) Toggle() {
+	m.update(func(s *state) {
+		if s.running() {
+			s.Remaining = s.End.Sub(timing.Now())
+			s.End = time.Time{}
+		} else {
+			s.End = timing.Now().Add(s.Remaining)
+		}
+	})
+}
+
+// Reset stops the timer and resets the current phase to its configured
+// length. If the current phase is already reset, the whole cycle is reset.
+func (m *Module) Reset() {
+	m.update(func(s *state) {
+		phase := m.phaseState(s.Phase, s.Completed)
+		if !s.running() && s.Duration == phase.Duration && s.Remaining == phase.Remaining {
+			*s = m.phaseState(Work, 0)
+		} else {
+			*s = phase
+		}
+	})
+}
+
+// Skip stops the timer and moves to the next phase, without counting the
+// current phase as completed.
+func (m *Module) Skip() {
+	m.update(func(s *state) {
+		if s.Phase == Work {
+			*s = m.phaseState(ShortBreak, s.Completed)
+		} else {
+			*s = m.phaseState(Work, s.Completed)
+		}
+	})
+}
+
+// Adjust adds the given (possibly negative) duration to the current phase.
+// Adjustments that would leave less than a minute in the phase are ignored.
+func (m *Module) Adjust(delta time.Duration) {
+	m.update(func(s *state) {
+		remaining := s.Remaining
+		if s.running() {
+			remaining = s.End.Sub(timing.Now())
+		}
+		if remaining+delta < time.Minute {
+			return
+		}
+		s.Duration += delta
+		if s.running() {
+			s.End = s.End.Add(delta)
+		} else {
+			s.Remaining += delta
+		}
+	})
+}
+
+// phaseState returns the initial state for a phase. Must be called with the
+// lock held, except in New.
+func (m *Module) phaseState(p Phase, completed int) state {
+	d := m.durations[p]
+	return state{Phase: p, Completed: completed, Duration: d, Remaining: d}
+}
+
+// update applies a change to the state, and saves the new state.
+func (m *Module) update(fn func(*state)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.state.Get().(state)
+	fn(&s)
+	m.state.Set(s)
+	m.save(s)
+}
+
+// save writes the state to the state store. Must be called with the lock
+// held.
+func (m *Module) save(s state) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.Set(m.name, s); err != nil {
+		l.Log("%s: failed to save state: %v", l.ID(m), err)
+	}
+}
+
+// load restores the state from the state file, if available.
+func (m *Module) load() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.store == nil {
+		return
+	}
+	var s state
+	ok, err := m.store.Get(m.name, &s)
+	if !ok && err == nil {
+		return
+	}
+	if err != nil || s.Phase < Work || s.Phase > LongBreak {
+		l.Log("%s: ignoring invalid state: %v", l.ID(m), err)
+		return
+	}
+	if s.running() && !timing.Now().Before(s.End) {
+		// The phase ended while the bar was not running, so move on to the
+		// next phase, but don't start it or run the phase end hooks.
+		s = m.next(s)
+		s.End = time.Time{}
+	}
+	m.state.Set(s)
+}
+
+// next returns the state for the phase after the given one. Must be called
+// with the lock held.
+func (m *Module) next(s state) state {
+	if s.Phase.IsBreak() {
+		if s.Phase == LongBreak {
+			s.Completed = 0
+		}
+		s = m.phaseState(Work, s.Completed)
+	} else {
+		s.Completed++
+		if m.longEvery > 0 && s.Completed%m.longEvery == 0 {
+			s = m.phaseState(LongBreak, s.Completed)
+		} else {
+			s = m.phaseState(ShortBreak, s.Completed)
+		}
+	}
+	if m.autoStart {
+		s.End = timing.Now().Add(s.Remaining)
+	}
+	return s
+}
+
+// completePhase moves to the next phase if the current phase has ended,
+// and runs the phase end hooks. Returns true if the phase ended.
+func (m *Module) completePhase() bool {
+	m.mu.Lock()
+	s := m.state.Get().(state)
+	if !s.running() || timing.Now().Before(s.End) {
+		m.mu.Unlock()
+		return false
+	}
+	ended := s.Phase
+	s = m.next(s)
+	m.state.Set(s)
+	m.save(s)
+	hooks := m.onEnd
+	m.mu.Unlock()
+	for _, fn := range hooks {
+		go fn(ended)
+	}
+	return true
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	m.load()
+	st := m.state.Get().(state)
+	nextState, done := m.state.Subscribe()
+	defer done()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	ticker := timing.NewScheduler()
+	defer ticker.Close()
+	for {
+		info := Info{
+			Phase:     st.Phase,
+			Remaining: st.Remaining,
+			Duration:  st.Duration,
+			Completed: st.Completed,
+			Running:   st.running(),
+		}
+		ticker.Stop()
+		if st.running() {
+			info.Remaining = st.End.Sub(timing.Now())
+			if info.Remaining < 0 {
+				info.Remaining = 0
+			}
+			// Update the output whenever the remaining whole seconds change.
+			wait := info.Remaining % time.Second
+			if wait == 0 {
+				wait = time.Second
+			}
+			ticker.After(wait)
+		}
+		s.Output(outputs.Group(outputFunc(info)).OnClick(m.click))
+		select {
+		case <-nextState:
+			st = m.state.Get().(state)
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-ticker.C:
+			if m.completePhase() {
+				// Consume the notification for the new phase, to avoid
+				// rendering it twice.
+				<-nextState
+				st = m.state.Get().(state)
+			}
+		}
+	}
+}
+
+// click handles clicks on the module output.
+func (m *Module) click(e bar.Event) {
+	switch e.Button {
+	case bar.ButtonLeft:
+		m.Toggle()
+	case bar.ButtonRight:
+		m.Reset()
+	case bar.ButtonMiddle:
+		m.Skip()
+	case bar.ScrollUp, bar.ScrollRight:
+		m.Adjust(time.Minute)
+	case bar.ScrollDown, bar.ScrollLeft:
+		m.Adjust(-time.Minute)
+	}
+}
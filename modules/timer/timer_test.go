@@ -0,0 +1,209 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	persist "barista.run/state"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimer(t *testing.T) {
+	persist.TestMode()
+	testBar.New(t)
+	ended := make(chan Phase, 10)
+	m := New("simple").
+		Durations(2*time.Minute, time.Minute, 3*time.Minute).
+		OnPhaseEnd(func(p Phase) { ended <- p })
+	testBar.Run(m)
+	out := testBar.NextOutput("initial")
+	out.AssertText([]string{"work 02:00"})
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on start").AssertText([]string{"work 02:00"})
+	timing.NextTick()
+	testBar.NextOutput("on tick").AssertText([]string{"work 01:59"})
+	timing.AdvanceBy(30 * time.Second)
+	out = testBar.NextOutput("on tick")
+	out.AssertText([]string{"work 01:29"})
+
+	out.At(0).LeftClick()
+	out = testBar.NextOutput("on pause")
+	out.AssertText([]string{"work 01:29 (paused)"})
+	timing.AdvanceBy(time.Minute)
+	testBar.AssertNoOutput("while paused")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on scroll up")
+	out.AssertText([]string{"work 02:29 (paused)"})
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll down")
+	out.AssertText([]string{"work 01:29 (paused)"})
+	out.At(0).Click(bar.Event{Button: bar.ScrollDown})
+	out = testBar.NextOutput("on scroll down")
+	out.AssertText([]string{"work 01:29 (paused)"},
+		"adjustment leaving less than a minute is ignored")
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on resume").AssertText([]string{"work 01:29"})
+	timing.AdvanceBy(89 * time.Second)
+	out = testBar.NextOutput("phase ended")
+	out.AssertText([]string{"short break 01:00"})
+	require.Equal(t, Work, <-ended)
+	testBar.AssertNoOutput("break does not start automatically")
+
+	out.At(0).LeftClick()
+	testBar.NextOutput("on start").AssertText([]string{"short break 01:00"})
+	timing.AdvanceBy(time.Minute)
+	out = testBar.NextOutput("break ended")
+	out.AssertText([]string{"work 02:00"})
+	require.Equal(t, ShortBreak, <-ended)
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonMiddle})
+	out = testBar.NextOutput("on skip")
+	out.AssertText([]string{"short break 01:00"})
+	require.Empty(t, ended, "skipped phases do not run hooks")
+
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	out = testBar.NextOutput("on scroll up")
+	out.AssertText([]string{"short break 02:00"})
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	out = testBar.NextOutput("on reset")
+	out.AssertText([]string{"short break 01:00"})
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	testBar.NextOutput("on reset").AssertText([]string{"work 02:00"})
+}
+
+func TestLongBreak(t *testing.T) {
+	persist.TestMode()
+	testBar.New(t)
+	m := New("long").
+		Durations(time.Minute, time.Minute, 5*time.Minute).
+		LongBreakEvery(2).
+		AutoStart(true).
+		Output(func(i Info) bar.Output {
+			return outputs.Textf("%s/%d/%v", i.Phase, i.Completed, i.Running)
+		})
+	testBar.Run(m)
+	testBar.NextOutput("initial").AssertText([]string{"work/0/false"})
+	m.Start()
+	testBar.NextOutput("on start").AssertText([]string{"work/0/true"})
+
+	for _, tc := range []struct {
+		after    time.Duration
+		expected string
+	}{
+		{time.Minute, "short break/1/true"},
+		{time.Minute, "work/1/true"},
+		{time.Minute, "long break/2/true"},
+		{5 * time.Minute, "work/0/true"},
+	} {
+		timing.AdvanceBy(tc.after)
+		testBar.NextOutput("phase ended").AssertText([]string{tc.expected})
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	persist.TestMode()
+	testBar.New(t)
+	m := New("persist")
+	testBar.Run(m)
+	testBar.NextOutput("initial").AssertText([]string{"work 25:00"})
+	m.Start()
+	testBar.NextOutput("on start")
+	timing.AdvanceBy(10 * time.Minute)
+	testBar.NextOutput("on tick").AssertText([]string{"work 15:00"})
+
+	// Restarting the bar resets the test time, so the end is 25 minutes away.
+	testBar.New(t)
+	timing.AdvanceBy(10*time.Minute + 20*time.Second)
+	testBar.Run(New("persist"))
+	testBar.NextOutput("restored running").AssertText([]string{"work 14:40"})
+
+	testBar.New(t)
+	timing.AdvanceBy(25 * time.Minute)
+	m = New("persist")
+	testBar.Run(m)
+	testBar.NextOutput("ended while not running").
+		AssertText([]string{"short break 05:00"})
+	testBar.AssertNoOutput("does not start automatically")
+
+	m.Adjust(2 * time.Minute)
+	testBar.NextOutput("on adjust").AssertText([]string{"short break 07:00"})
+	m.Start()
+	testBar.NextOutput("on start")
+	timing.AdvanceBy(20 * time.Second)
+	testBar.NextOutput("on tick").AssertText([]string{"short break 06:40"})
+	m.Pause()
+	testBar.NextOutput("on pause").AssertText([]string{"short break 06:40 (paused)"})
+
+	testBar.New(t)
+	testBar.Run(New("persist"))
+	testBar.NextOutput("restored paused").
+		AssertText([]string{"short break 06:40 (paused)"})
+
+	testBar.New(t)
+	testBar.Run(New("persist").StateFile(""))
+	testBar.NextOutput("without state file").AssertText([]string{"work 25:00"})
+
+	require.NoError(t, persist.Open("timer").Set("bad", "invalid"))
+	testBar.New(t)
+	testBar.Run(New("bad"))
+	testBar.NextOutput("invalid state file").AssertText([]string{"work 25:00"})
+}
+
+func TestRunOnPhaseEnd(t *testing.T) {
+	persist.TestMode()
+	type call struct {
+		env  []string
+		name string
+		args []string
+	}
+	calls := make(chan call, 10)
+	runCommand = func(env []string, name string, args ...string) error {
+		calls <- call{env, name, args}
+		return errors.New("something went wrong")
+	}
+
+	testBar.New(t)
+	m := New("command").
+		Durations(time.Minute, time.Minute, time.Minute).
+		RunOnPhaseEnd("notify-send", "Timer", "Phase ended")
+	testBar.Run(m)
+	testBar.NextOutput("initial")
+	m.Start()
+	testBar.NextOutput("on start")
+	timing.AdvanceBy(time.Minute)
+	testBar.NextOutput("on phase end")
+
+	select {
+	case c := <-calls:
+		require.Equal(t, call{
+			[]string{"BARISTA_TIMER_PHASE=work"},
+			"notify-send",
+			[]string{"Timer", "Phase ended"},
+		}, c)
+	case <-time.After(time.Second):
+		require.Fail(t, "command not run on phase end")
+	}
+}
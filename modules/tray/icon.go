@@ -0,0 +1,55 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tray
+
+import (
+	"image"
+
+	godbus "github.com/godbus/dbus/v5"
+)
+
+// pixmap is an icon image sent by a tray item, with pixels in ARGB32 format
+// and network byte order.
+type pixmap struct {
+	Width, Height int32
+	Pixels        []byte
+}
+
+// pixmapImage returns the largest valid image from a list of pixmaps, or nil
+// if there are none.
+func pixmapImage(v interface{}) image.Image {
+	var pixmaps []pixmap
+	if v == nil || godbus.Store([]interface{}{v}, &pixmaps) != nil {
+		return nil
+	}
+	var best *pixmap
+	for idx, p := range pixmaps {
+		if p.Width <= 0 || p.Height <= 0 || len(p.Pixels) < int(p.Width*p.Height*4) {
+			continue
+		}
+		if best == nil || p.Width*p.Height > best.Width*best.Height {
+			best = &pixmaps[idx]
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, int(best.Width), int(best.Height)))
+	for i := 0; i < len(img.Pix); i += 4 {
+		a, r, g, b := best.Pixels[i], best.Pixels[i+1], best.Pixels[i+2], best.Pixels[i+3]
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = r, g, b, a
+	}
+	return img
+}
@@ -0,0 +1,222 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tray
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+
+	godbus "github.com/godbus/dbus/v5"
+)
+
+const menuIface = "com.canonical.dbusmenu"
+
+// MenuItem represents an entry in a tray item's menu.
+type MenuItem struct {
+	ID      int32
+	Label   string
+	Enabled bool
+	Visible bool
+	// Separator is true for entries that separate groups of other entries.
+	Separator bool
+	// ToggleType is "checkmark" or "radio" for entries that can be toggled,
+	// and empty otherwise.
+	ToggleType string
+	Checked    bool
+	Children   []MenuItem
+
+	item *item
+}
+
+// Click activates the menu entry and closes the menu. For entries with
+// children, it opens the submenu instead.
+func (m MenuItem) Click() {
+	m.item.clickMenu(m.ID, len(m.Children) > 0)
+}
+
+// menu tracks the layout of an open menu.
+type menu struct {
+	path    godbus.ObjectPath
+	signals *dbus.SignalWatcher
+	done    chan struct{}
+	stale   int32 // atomic bool
+	layout  menuNode
+}
+
+// menuNode is a parsed entry of a DBusMenu layout.
+type menuNode struct {
+	id       int32
+	props    map[string]godbus.Variant
+	children []menuNode
+}
+
+func newMenu(service string, path godbus.ObjectPath, notify func()) *menu {
+	m := &menu{
+		path: path,
+		signals: dbus.WatchSignals(busType, service, string(path)).
+			Add(menuIface+".LayoutUpdated", menuIface+".ItemsPropertiesUpdated"),
+		done:  make(chan struct{}),
+		stale: 1,
+	}
+	go func() {
+		for {
+			select {
+			case <-m.signals.Signals:
+				atomic.StoreInt32(&m.stale, 1)
+				notify()
+			case <-m.done:
+				return
+			}
+		}
+	}()
+	m.call("AboutToShow", int32(0))
+	m.event(0, "opened")
+	return m
+}
+
+func (m *menu) close() {
+	m.event(0, "closed")
+	close(m.done)
+	m.signals.Unsubscribe()
+}
+
+func (m *menu) call(method string, args ...interface{}) ([]interface{}, error) {
+	return m.signals.Call(m.path, menuIface+"."+method, args...)
+}
+
+// event sends an event for the menu entry with the given id.
+func (m *menu) event(id int32, eventID string) {
+	_, err := m.call("Event", id, eventID,
+		godbus.MakeVariant(""), uint32(time.Now().Unix()))
+	if err != nil {
+		l.Log("Tray menu %s: %s event failed: %v", m.path, eventID, err)
+	}
+}
+
+// entries returns the entries of the innermost open submenu of the item,
+// fetching the layout if it has changed. Must be called with the item's
+// lock held.
+func (m *menu) entries(i *item) []MenuItem {
+	if atomic.CompareAndSwapInt32(&m.stale, 1, 0) {
+		body, err := m.call("GetLayout", int32(0), int32(-1), []string{})
+		if err == nil && len(body) == 2 {
+			m.layout, _ = parseLayout(body[1])
+		} else {
+			l.Log("Tray menu %s: could not get layout: %v", m.path, err)
+		}
+	}
+	node := m.layout
+	for idx, id := range i.level {
+		found := false
+		for _, c := range node.children {
+			if c.id == id {
+				node, found = c, true
+				break
+			}
+		}
+		if !found {
+			// The submenu went away, show the last one that still exists.
+			i.level = i.level[:idx]
+			break
+		}
+	}
+	r := make([]MenuItem, 0, len(node.children))
+	for _, c := range node.children {
+		r = append(r, c.menuItem(i))
+	}
+	return r
+}
+
+// parseLayout parses a DBusMenu layout, which is a recursive struct of
+// (id, properties, children as variants).
+func parseLayout(v interface{}) (menuNode, bool) {
+	var raw struct {
+		ID       int32
+		Props    map[string]godbus.Variant
+		Children []godbus.Variant
+	}
+	if godbus.Store([]interface{}{v}, &raw) != nil {
+		return menuNode{}, false
+	}
+	n := menuNode{id: raw.ID, props: raw.Props}
+	for _, c := range raw.Children {
+		if child, ok := parseLayout(c.Value()); ok {
+			n.children = append(n.children, child)
+		}
+	}
+	return n, true
+}
+
+func (n menuNode) menuItem(i *item) MenuItem {
+	e := MenuItem{ID: n.id, Enabled: true, Visible: true, item: i}
+	if label, ok := n.props["label"].Value().(string); ok {
+		e.Label = stripMnemonic(label)
+	}
+	if enabled, ok := n.props["enabled"].Value().(bool); ok {
+		e.Enabled = enabled
+	}
+	if visible, ok := n.props["visible"].Value().(bool); ok {
+		e.Visible = visible
+	}
+	typ, _ := n.props["type"].Value().(string)
+	e.Separator = typ == "separator"
+	e.ToggleType, _ = n.props["toggle-type"].Value().(string)
+	state, _ := n.props["toggle-state"].Value().(int32)
+	e.Checked = state == 1
+	for _, c := range n.children {
+		e.Children = append(e.Children, c.menuItem(i))
+	}
+	return e
+}
+
+// stripMnemonic removes the underscores that mark access keys in labels,
+// keeping escaped (doubled) underscores as a single underscore.
+func stripMnemonic(label string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range label {
+		if r == '_' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (i *item) clickMenu(id int32, submenu bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.menu == nil {
+		return
+	}
+	if submenu {
+		i.menu.call("AboutToShow", id)
+		i.menu.event(id, "opened")
+		i.level = append(i.level, id)
+		i.notify()
+		return
+	}
+	i.menu.event(id, "clicked")
+	i.menu.close()
+	i.menu = nil
+	i.level = nil
+	i.notify()
+}
@@ -0,0 +1,533 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tray provides a system tray module, which hosts StatusNotifierItem
+// (a.k.a. AppIndicator) icons and their DBusMenu menus on the bar.
+//
+// Items are discovered through the org.kde.StatusNotifierWatcher service.
+// Most desktop environments provide one, and if none is running (e.g. under
+// i3 or sway), the module runs its own watcher on the session bus.
+package tray // import "barista.run/modules/tray"
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+	"barista.run/outputs"
+
+	godbus "github.com/godbus/dbus/v5"
+)
+
+const (
+	watcherService = "org.kde.StatusNotifierWatcher"
+	watcherPath    = "/StatusNotifierWatcher"
+	watcherIface   = "org.kde.StatusNotifierWatcher"
+
+	itemIface       = "org.kde.StatusNotifierItem"
+	defaultItemPath = "/StatusNotifierItem"
+)
+
+var busType = dbus.Session
+
+// hostID distinguishes the host names of multiple tray modules.
+var hostID int64
+
+// Status represents the status of a tray item.
+type Status string
+
+const (
+	// StatusPassive items don't convey important information, and are
+	// usually hidden.
+	StatusPassive Status = "Passive"
+	// StatusActive items are shown normally.
+	StatusActive Status = "Active"
+	// StatusNeedsAttention items need the user's attention.
+	StatusNeedsAttention Status = "NeedsAttention"
+)
+
+// Item represents a single item in the tray.
+type Item struct {
+	ID       string
+	Title    string
+	Category string
+	Status   Status
+	// IconName is the name of the item's icon in the icon theme.
+	IconName string
+	// Icon is the item's icon, if the item provides image data. The
+	// attention icon is used instead for items that need attention.
+	Icon    image.Image
+	ToolTip string
+	// ItemIsMenu is true if the item only supports showing its menu, and
+	// activating it should open the menu instead.
+	ItemIsMenu bool
+	// MenuOpen is true if the item's menu has been opened with ToggleMenu.
+	MenuOpen bool
+	// Menu contains the entries of the open menu or submenu, and is empty
+	// if the menu is closed.
+	Menu []MenuItem
+
+	item *item
+}
+
+// Label returns a textual label for the item, for use when it has no icon.
+func (i Item) Label() string {
+	for _, s := range []string{i.Title, i.ID, i.IconName} {
+		if s != "" {
+			return s
+		}
+	}
+	return "?"
+}
+
+// Activate performs the item's primary action, usually opening its window.
+// The coordinates are the screen position of the click.
+func (i Item) Activate(x, y int) {
+	i.item.call("Activate", int32(x), int32(y))
+}
+
+// SecondaryActivate performs the item's secondary action, usually bound to
+// middle-click.
+func (i Item) SecondaryActivate(x, y int) {
+	i.item.call("SecondaryActivate", int32(x), int32(y))
+}
+
+// ContextMenu asks the item to show its own context menu. This is only
+// useful for items that don't export a menu, see HasMenu.
+func (i Item) ContextMenu(x, y int) {
+	i.item.call("ContextMenu", int32(x), int32(y))
+}
+
+// Scroll sends a scroll event to the item, with a positive or negative delta
+// in the given orientation ("vertical" or "horizontal").
+func (i Item) Scroll(delta int, orientation string) {
+	i.item.call("Scroll", int32(delta), orientation)
+}
+
+// HasMenu returns true if the item exports a menu that can be shown on the
+// bar using ToggleMenu.
+func (i Item) HasMenu() bool {
+	return i.item.menuPath() != ""
+}
+
+// ToggleMenu opens the item's menu, or closes it if it is already open.
+func (i Item) ToggleMenu() {
+	i.item.toggleMenu()
+}
+
+// CloseMenu closes the item's menu, if it is open.
+func (i Item) CloseMenu() {
+	i.item.closeMenu()
+}
+
+// Module represents a system tray bar module.
+type Module struct {
+	outputFunc value.Value // of func([]Item) bar.Output
+}
+
+// New creates a new tray module.
+func New() *Module {
+	m := new(Module)
+	m.Output(defaultOutput)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func([]Item) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(sink bar.Sink) {
+	owner := dbus.WatchNameOwner(busType, watcherService)
+	defer owner.Unsubscribe()
+	stopWatcher := func() {}
+	defer func() { stopWatcher() }()
+	// Run a watcher if there isn't one, including when another watcher
+	// (e.g. the desktop's) goes away.
+	ensureWatcher := func() {
+		if owner.GetOwner() != "" {
+			return
+		}
+		stopWatcher()
+		stopWatcher = func() {}
+		stop, err := embedWatcher()
+		if err != nil {
+			l.Log("Could not start StatusNotifierWatcher: %v", err)
+			return
+		}
+		stopWatcher = stop
+	}
+	ensureWatcher()
+
+	hostName := fmt.Sprintf("org.kde.StatusNotifierHost-%d-%d",
+		os.Getpid(), atomic.AddInt64(&hostID, 1))
+	release, err := dbus.RequestName(busType, hostName)
+	if sink.Error(err) {
+		return
+	}
+	defer release()
+
+	w := dbus.WatchProperties(busType, watcherService, watcherPath, watcherIface).
+		Add("RegisteredStatusNotifierItems").
+		AddSignalHandler("StatusNotifierItemRegistered", refetchItems).
+		AddSignalHandler("StatusNotifierItemUnregistered", refetchItems)
+	defer w.Unsubscribe()
+	if _, ok := w.Get()["RegisteredStatusNotifierItems"]; ok {
+		registerHost(w, hostName)
+	}
+
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+
+	items := map[string]*item{}
+	defer func() {
+		for _, i := range items {
+			i.close()
+		}
+	}()
+
+	outputFunc := m.outputFunc.Get().(func([]Item) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	order := updateItems(items, w.Get(), notify)
+	for {
+		infos := make([]Item, 0, len(order))
+		for _, key := range order {
+			infos = append(infos, items[key].info())
+		}
+		sink.Output(outputFunc(infos))
+		select {
+		case ch := <-w.Updates:
+			if c, ok := ch["RegisteredStatusNotifierItems"]; ok && c[0] == nil && c[1] != nil {
+				// The watcher (re)started, and needs to know about the host.
+				registerHost(w, hostName)
+			}
+			order = updateItems(items, w.Get(), notify)
+		case u := <-owner.Updates:
+			if u.Owner == "" {
+				ensureWatcher()
+			}
+		case <-changed:
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func([]Item) bar.Output)
+		}
+	}
+}
+
+// refetchItems handles the item (un)registration signals by fetching the
+// complete list of items from the watcher.
+func refetchItems(_ *dbus.Signal, fetch dbus.Fetcher) map[string]interface{} {
+	items, err := fetch("RegisteredStatusNotifierItems")
+	if err != nil {
+		return nil
+	}
+	return map[string]interface{}{"RegisteredStatusNotifierItems": items}
+}
+
+func registerHost(w *dbus.PropertiesWatcher, hostName string) {
+	if _, err := w.Call("RegisterStatusNotifierHost", hostName); err != nil {
+		l.Log("Could not register tray host: %v", err)
+	}
+}
+
+// updateItems starts watching newly registered items, stops watching
+// unregistered items, and returns the keys of all items in order.
+func updateItems(items map[string]*item, props map[string]interface{}, notify func()) []string {
+	registered, _ := props["RegisteredStatusNotifierItems"].([]string)
+	order := make([]string, 0, len(registered))
+	seen := map[string]bool{}
+	for _, key := range registered {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		order = append(order, key)
+		if _, ok := items[key]; !ok {
+			items[key] = newItem(key, notify)
+		}
+	}
+	for key, i := range items {
+		if !seen[key] {
+			i.close()
+			delete(items, key)
+		}
+	}
+	return order
+}
+
+// splitItem splits an item as registered with the watcher into the service
+// name and object path. Items registered with just a service name use the
+// default object path.
+func splitItem(key string) (service, path string) {
+	if idx := strings.IndexRune(key, '/'); idx >= 0 {
+		return key[:idx], key[idx:]
+	}
+	return key, defaultItemPath
+}
+
+// item tracks the properties and menu state of a single tray item.
+type item struct {
+	service string
+	props   *dbus.PropertiesWatcher
+	notify  func()
+	done    chan struct{}
+
+	mu    sync.Mutex
+	menu  *menu
+	level []int32 // ids of the open submenus, outermost first.
+}
+
+func newItem(key string, notify func()) *item {
+	service, path := splitItem(key)
+	i := &item{service: service, notify: notify, done: make(chan struct{})}
+	i.props = dbus.WatchProperties(busType, service, path, itemIface).
+		Add("Id", "Title", "Category", "Status", "IconName", "IconPixmap",
+			"AttentionIconName", "AttentionIconPixmap", "ToolTip",
+			"ItemIsMenu", "Menu").
+		AddSignalHandler("NewTitle", refetch("Title")).
+		AddSignalHandler("NewIcon", refetch("IconName", "IconPixmap")).
+		AddSignalHandler("NewAttentionIcon", refetch("AttentionIconName", "AttentionIconPixmap")).
+		AddSignalHandler("NewStatus", refetch("Status")).
+		AddSignalHandler("NewToolTip", refetch("ToolTip"))
+	go i.forward(i.props.Updates)
+	return i
+}
+
+// refetch returns a signal handler that fetches the given properties, for
+// the item signals that announce changes without PropertiesChanged.
+func refetch(props ...string) func(*dbus.Signal, dbus.Fetcher) map[string]interface{} {
+	return func(_ *dbus.Signal, fetch dbus.Fetcher) map[string]interface{} {
+		r := map[string]interface{}{}
+		for _, p := range props {
+			if v, err := fetch(p); err == nil {
+				r[p] = v
+			}
+		}
+		return r
+	}
+}
+
+// forward notifies the module of any updates until the item is closed.
+func (i *item) forward(updates <-chan dbus.PropertiesChange) {
+	for {
+		select {
+		case <-updates:
+			i.notify()
+		case <-i.done:
+			return
+		}
+	}
+}
+
+func (i *item) close() {
+	i.closeMenu()
+	close(i.done)
+	i.props.Unsubscribe()
+}
+
+func (i *item) call(method string, args ...interface{}) {
+	if _, err := i.props.Call(method, args...); err != nil {
+		l.Log("Tray item %s: %s failed: %v", i.service, method, err)
+	}
+}
+
+func (i *item) menuPath() godbus.ObjectPath {
+	path, _ := i.props.Get()["Menu"].(godbus.ObjectPath)
+	if path == "/" {
+		// Some items use the root path to indicate that they have no menu.
+		return ""
+	}
+	return path
+}
+
+// info returns the current state of the item.
+func (i *item) info() Item {
+	props := i.props.Get()
+	info := Item{item: i}
+	info.ID, _ = props["Id"].(string)
+	info.Title, _ = props["Title"].(string)
+	info.Category, _ = props["Category"].(string)
+	status, _ := props["Status"].(string)
+	info.Status = Status(status)
+	info.IconName, _ = props["IconName"].(string)
+	info.Icon = pixmapImage(props["IconPixmap"])
+	if info.Status == StatusNeedsAttention {
+		if name, _ := props["AttentionIconName"].(string); name != "" {
+			info.IconName = name
+		}
+		if img := pixmapImage(props["AttentionIconPixmap"]); img != nil {
+			info.Icon = img
+		}
+	}
+	info.ToolTip = toolTipText(props["ToolTip"])
+	info.ItemIsMenu, _ = props["ItemIsMenu"].(bool)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.menu != nil {
+		info.MenuOpen = true
+		info.Menu = i.menu.entries(i)
+	}
+	return info
+}
+
+// toolTipText extracts the title of an item's tooltip, which is a struct of
+// (icon name, icon pixmaps, title, description).
+func toolTipText(v interface{}) string {
+	var tip struct {
+		IconName    string
+		IconPixmap  []pixmap
+		Title       string
+		Description string
+	}
+	if v == nil || godbus.Store([]interface{}{v}, &tip) != nil {
+		return ""
+	}
+	return tip.Title
+}
+
+func (i *item) toggleMenu() {
+	i.mu.Lock()
+	isOpen := i.menu != nil
+	i.mu.Unlock()
+	if isOpen {
+		i.closeMenu()
+	} else {
+		i.openMenu()
+	}
+}
+
+func (i *item) openMenu() {
+	path := i.menuPath()
+	if path == "" {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.menu != nil {
+		return
+	}
+	i.menu = newMenu(i.service, path, i.notify)
+	i.level = nil
+	i.notify()
+}
+
+func (i *item) closeMenu() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.menu == nil {
+		return
+	}
+	i.menu.close()
+	i.menu = nil
+	i.level = nil
+	i.notify()
+}
+
+func defaultOutput(items []Item) bar.Output {
+	out := outputs.Group()
+	for _, i := range items {
+		if i.Status == StatusPassive {
+			continue
+		}
+		out.Append(itemSegment(i))
+		if !i.MenuOpen {
+			continue
+		}
+		for _, e := range i.Menu {
+			if !e.Visible || e.Separator {
+				continue
+			}
+			out.Append(menuSegment(e))
+		}
+		out.Append(outputs.Text("✕").OnClick(closeOnClick(i)))
+	}
+	return out
+}
+
+func itemSegment(i Item) *bar.Segment {
+	var s *bar.Segment
+	if i.Icon != nil {
+		s = outputs.Text("").Image(i.Icon)
+	} else {
+		s = outputs.Text(i.Label())
+	}
+	return s.Urgent(i.Status == StatusNeedsAttention).OnClick(func(e bar.Event) {
+		switch e.Button {
+		case bar.ButtonLeft:
+			if i.ItemIsMenu {
+				showMenu(i, e)
+			} else {
+				i.Activate(e.ScreenX, e.ScreenY)
+			}
+		case bar.ButtonMiddle:
+			i.SecondaryActivate(e.ScreenX, e.ScreenY)
+		case bar.ButtonRight:
+			showMenu(i, e)
+		case bar.ScrollUp:
+			i.Scroll(-1, "vertical")
+		case bar.ScrollDown:
+			i.Scroll(1, "vertical")
+		case bar.ScrollLeft:
+			i.Scroll(-1, "horizontal")
+		case bar.ScrollRight:
+			i.Scroll(1, "horizontal")
+		}
+	})
+}
+
+// showMenu shows the item's menu on the bar if it has one, and otherwise
+// asks the item to show its own context menu.
+func showMenu(i Item, e bar.Event) {
+	if i.HasMenu() {
+		i.ToggleMenu()
+	} else {
+		i.ContextMenu(e.ScreenX, e.ScreenY)
+	}
+}
+
+func menuSegment(e MenuItem) *bar.Segment {
+	label := e.Label
+	switch {
+	case len(e.Children) > 0:
+		label += " ›"
+	case e.ToggleType != "" && e.Checked:
+		label = "☑ " + label
+	case e.ToggleType != "":
+		label = "☐ " + label
+	}
+	s := outputs.Text(label)
+	if !e.Enabled {
+		return s
+	}
+	return s.OnClick(func(bar.Event) { e.Click() })
+}
+
+func closeOnClick(i Item) func(bar.Event) {
+	return func(bar.Event) { i.CloseMenu() }
+}
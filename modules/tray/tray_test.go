@@ -0,0 +1,265 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tray
+
+import (
+	"errors"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/dbus"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	fakedbus "barista.run/testing/dbus"
+	"barista.run/testing/output"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	busType = dbus.Test
+}
+
+// nextOutput returns the next output with the given text, skipping any
+// intermediate outputs from partial updates.
+func nextOutput(t *testing.T, expected ...string) output.Assertions {
+	deadline := time.Now().Add(5 * time.Second)
+	var texts []string
+	for time.Now().Before(deadline) {
+		out := testBar.NextOutput("waiting for %v", expected)
+		texts = nil
+		for i := 0; i < out.Len(); i++ {
+			txt, _ := out.At(i).Segment().Content()
+			texts = append(texts, txt)
+		}
+		if strings.Join(texts, "|") == strings.Join(expected, "|") {
+			return out
+		}
+	}
+	require.Fail(t, "Expected output not received",
+		"expected %v, last output %v", expected, texts)
+	return output.Assertions{}
+}
+
+type fakeTray struct {
+	bus     *fakedbus.Bus
+	watcher *dbus.TestBusObject
+	hosts   <-chan []interface{}
+}
+
+func setupTray(t *testing.T, items ...string) *fakeTray {
+	embedWatcher = func() (func(), error) {
+		return nil, errors.New("not available in tests")
+	}
+	f := &fakeTray{bus: fakedbus.New()}
+	f.watcher = f.bus.Service(watcherService).Object(watcherPath, watcherIface)
+	f.hosts = fakedbus.Record(f.watcher, "RegisterStatusNotifierHost")
+	f.watcher.SetPropertyForTest("RegisteredStatusNotifierItems", items, dbus.SignalTypeNone)
+	return f
+}
+
+func (f *fakeTray) item(service, path string, props map[string]interface{}) *dbus.TestBusObject {
+	obj := f.bus.Service(service).Object(godbus.ObjectPath(path), itemIface)
+	obj.SetProperties(props, dbus.SignalTypeNone)
+	return obj
+}
+
+func textOutput(items []Item) bar.Output {
+	out := outputs.Group()
+	for _, i := range items {
+		out.Append(outputs.Textf("%s:%s", i.Label(), i.Status))
+	}
+	return out
+}
+
+func TestTray(t *testing.T) {
+	testBar.New(t)
+	f := setupTray(t, "org.example.App")
+	app := f.item("org.example.App", "/StatusNotifierItem", map[string]interface{}{
+		"Id": "app", "Title": "App", "Status": "Active",
+	})
+
+	testBar.Run(New().Output(textOutput))
+	nextOutput(t, "App:Active")
+	select {
+	case args := <-f.hosts:
+		require.True(t, strings.HasPrefix(args[0].(string), "org.kde.StatusNotifierHost-"))
+		require.NotPanics(t, func() { f.bus.Object(args[0].(string), "/") },
+			"host owns its name")
+	case <-time.After(time.Second):
+		require.Fail(t, "Host not registered")
+	}
+
+	app.SetPropertyForTest("Title", "Renamed", dbus.SignalTypeNone)
+	app.Emit("NewTitle")
+	nextOutput(t, "Renamed:Active")
+
+	app.SetPropertyForTest("Status", "NeedsAttention", dbus.SignalTypeNone)
+	app.Emit("NewStatus", "NeedsAttention")
+	nextOutput(t, "Renamed:NeedsAttention")
+
+	f.item("org.example.Other", "/custom/path", map[string]interface{}{
+		"Id": "other", "Status": "Passive",
+	})
+	f.watcher.SetPropertyForTest("RegisteredStatusNotifierItems",
+		[]string{"org.example.App", "org.example.Other/custom/path"},
+		dbus.SignalTypeNone)
+	f.watcher.Emit("StatusNotifierItemRegistered", "org.example.Other/custom/path")
+	nextOutput(t, "Renamed:NeedsAttention", "other:Passive")
+
+	f.watcher.SetPropertyForTest("RegisteredStatusNotifierItems",
+		[]string{"org.example.Other/custom/path"}, dbus.SignalTypeChanged)
+	nextOutput(t, "other:Passive")
+}
+
+func TestWatcherRestart(t *testing.T) {
+	testBar.New(t)
+	f := setupTray(t)
+	testBar.Run(New().Output(textOutput))
+	testBar.NextOutput().AssertEmpty()
+	<-f.hosts
+
+	svc := f.bus.Service(watcherService)
+	svc.RemoveName(watcherService)
+	testBar.NextOutput().AssertEmpty("watcher stopped")
+
+	svc.AddName(watcherService)
+	select {
+	case <-f.hosts:
+	case <-time.After(time.Second):
+		require.Fail(t, "Host not registered again after watcher restart")
+	}
+}
+
+func TestEmbeddedWatcher(t *testing.T) {
+	testBar.New(t)
+	fakedbus.New()
+	started := make(chan bool, 1)
+	embedWatcher = func() (func(), error) {
+		started <- true
+		return func() {}, nil
+	}
+	testBar.Run(New())
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		require.Fail(t, "Embedded watcher not started")
+	}
+}
+
+func TestDefaultOutput(t *testing.T) {
+	testBar.New(t)
+	f := setupTray(t, "org.example.App", "org.example.Hidden")
+	app := f.item("org.example.App", "/StatusNotifierItem", map[string]interface{}{
+		"Id": "app", "Status": "Active",
+		"IconPixmap": [][]interface{}{
+			{int32(1), int32(1), []byte{0xff, 0x10, 0x20, 0x30}},
+			{int32(2), int32(1), []byte{0xff, 0x10, 0x20, 0x30, 0x80, 0x40, 0x50, 0x60}},
+			{int32(4), int32(4), []byte{0x00}},
+		},
+		"Menu": godbus.ObjectPath("/Menu"),
+	})
+	f.item("org.example.Hidden", "/StatusNotifierItem", map[string]interface{}{
+		"Id": "hidden", "Status": "Passive",
+	})
+	activate := fakedbus.Record(app, "Activate")
+	secondary := fakedbus.Record(app, "SecondaryActivate")
+	scroll := fakedbus.Record(app, "Scroll")
+
+	menu := f.bus.Service("org.example.App").Object("/Menu", menuIface)
+	menu.On("GetLayout", func(...interface{}) ([]interface{}, error) {
+		return []interface{}{uint32(1), layout(0, nil,
+			layout(1, map[string]interface{}{"label": "_Open"}),
+			layout(2, map[string]interface{}{"type": "separator"}),
+			layout(3, map[string]interface{}{"label": "More"},
+				layout(4, map[string]interface{}{
+					"label": "Check", "toggle-type": "checkmark", "toggle-state": int32(1),
+				}),
+			),
+			layout(5, map[string]interface{}{"label": "Disabled", "enabled": false}),
+		)}, nil
+	})
+	fakedbus.Record(menu, "AboutToShow", false)
+	events := fakedbus.Record(menu, "Event")
+
+	testBar.Run(New())
+	out := nextOutput(t, "")
+	img, ok := out.At(0).Segment().GetImage()
+	require.True(t, ok, "item with pixmap shows image")
+	require.Equal(t, 2, img.Bounds().Dx(), "largest valid pixmap")
+	require.Equal(t, color.NRGBA{0x40, 0x50, 0x60, 0x80}, img.At(1, 0))
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonLeft, ScreenX: 10, ScreenY: 20})
+	require.Equal(t, []interface{}{int32(10), int32(20)}, <-activate)
+	out.At(0).Click(bar.Event{Button: bar.ButtonMiddle})
+	<-secondary
+	out.At(0).Scroll(-1)
+	require.Equal(t, []interface{}{int32(1), "vertical"}, <-scroll)
+
+	app.SetPropertyForTest("Status", "NeedsAttention", dbus.SignalTypeChanged)
+	out = nextOutput(t, "")
+	out.At(0).AssertUrgent(true)
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	require.Equal(t, "opened", (<-events)[1])
+	out = nextOutput(t, "", "Open", "More ›", "Disabled", "✕")
+	require.False(t, out.At(3).Segment().HasClick(), "disabled entry")
+
+	out.At(2).LeftClick()
+	e := <-events
+	require.Equal(t, []interface{}{int32(3), "opened"}, e[:2])
+	out = nextOutput(t, "", "☑ Check", "✕")
+
+	out.At(1).LeftClick()
+	e = <-events
+	require.Equal(t, []interface{}{int32(4), "clicked"}, e[:2])
+	require.Equal(t, "closed", (<-events)[1])
+	out = nextOutput(t, "")
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	<-events
+	out = nextOutput(t, "", "Open", "More ›", "Disabled", "✕")
+	out.At(4).LeftClick()
+	require.Equal(t, "closed", (<-events)[1])
+	nextOutput(t, "")
+}
+
+func layout(id int32, props map[string]interface{}, children ...interface{}) []interface{} {
+	p := map[string]godbus.Variant{}
+	for k, v := range props {
+		p[k] = godbus.MakeVariant(v)
+	}
+	c := []godbus.Variant{}
+	for _, ch := range children {
+		c = append(c, godbus.MakeVariant(ch))
+	}
+	return []interface{}{id, p, c}
+}
+
+func TestStripMnemonic(t *testing.T) {
+	for in, out := range map[string]string{
+		"":            "",
+		"_File":       "File",
+		"Save _As":    "Save As",
+		"snake__case": "snake_case",
+		"___x":        "_x",
+	} {
+		require.Equal(t, out, stripMnemonic(in), "stripMnemonic(%q)", in)
+	}
+}
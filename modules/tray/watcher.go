@@ -0,0 +1,192 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tray
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// embedWatcher runs a StatusNotifierWatcher on the session bus, for desktops
+// that don't provide one. Overridden in tests.
+var embedWatcher = runWatcher
+
+// registry keeps track of the items and hosts registered with the watcher.
+type registry struct {
+	items []string
+	hosts map[string]bool
+}
+
+func newRegistry() *registry {
+	return &registry{hosts: map[string]bool{}}
+}
+
+// addItem registers an item, and returns the key under which it was
+// registered, or an empty string if it was already registered. Items can
+// register with a service name, using the default object path, or with an
+// object path on the sender's connection.
+func (r *registry) addItem(sender, service string) string {
+	key := service
+	if strings.HasPrefix(service, "/") {
+		key = sender + service
+	}
+	for _, i := range r.items {
+		if i == key {
+			return ""
+		}
+	}
+	r.items = append(r.items, key)
+	return key
+}
+
+// addHost registers a host, and returns true if it is the first one.
+func (r *registry) addHost(name string) bool {
+	r.hosts[name] = true
+	return len(r.hosts) == 1
+}
+
+// removeName removes all items and hosts for a name that left the bus, and
+// returns the keys of the removed items.
+func (r *registry) removeName(name string) (removed []string) {
+	delete(r.hosts, name)
+	kept := r.items[:0]
+	for _, key := range r.items {
+		if service, _ := splitItem(key); service == name {
+			removed = append(removed, key)
+		} else {
+			kept = append(kept, key)
+		}
+	}
+	r.items = kept
+	return removed
+}
+
+// watcher implements the org.kde.StatusNotifierWatcher interface.
+type watcher struct {
+	conn  *godbus.Conn
+	props *prop.Properties
+
+	mu       sync.Mutex
+	registry *registry
+}
+
+func runWatcher() (stop func(), err error) {
+	conn, err := godbus.SessionBusPrivate()
+	if err == nil {
+		err = conn.Auth(nil)
+	}
+	if err == nil {
+		err = conn.Hello()
+	}
+	if err != nil {
+		return nil, err
+	}
+	w := &watcher{conn: conn, registry: newRegistry()}
+	err = conn.Export(w, watcherPath, watcherIface)
+	if err == nil {
+		w.props, err = prop.Export(conn, watcherPath, map[string]map[string]*prop.Prop{
+			watcherIface: {
+				"RegisteredStatusNotifierItems":  {Value: []string{}, Emit: prop.EmitTrue},
+				"IsStatusNotifierHostRegistered": {Value: false, Emit: prop.EmitTrue},
+				"ProtocolVersion":                {Value: int32(0), Emit: prop.EmitFalse},
+			},
+		})
+	}
+	if err == nil {
+		err = conn.AddMatchSignal(
+			godbus.WithMatchInterface("org.freedesktop.DBus"),
+			godbus.WithMatchMember("NameOwnerChanged"),
+		)
+	}
+	var reply godbus.RequestNameReply
+	if err == nil {
+		reply, err = conn.RequestName(watcherService, godbus.NameFlagDoNotQueue)
+	}
+	if err == nil && reply != godbus.RequestNameReplyPrimaryOwner {
+		err = errors.New("another watcher is running")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	signals := make(chan *godbus.Signal, 10)
+	conn.Signal(signals)
+	go w.listen(signals)
+	return func() { conn.Close() }, nil
+}
+
+// RegisterStatusNotifierItem is called by items to register with the watcher.
+func (w *watcher) RegisterStatusNotifierItem(sender godbus.Sender, service string) *godbus.Error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if key := w.registry.addItem(string(sender), service); key != "" {
+		w.itemsChanged()
+		w.emit("StatusNotifierItemRegistered", key)
+	}
+	return nil
+}
+
+// RegisterStatusNotifierHost is called by hosts to register with the watcher.
+func (w *watcher) RegisterStatusNotifierHost(sender godbus.Sender, service string) *godbus.Error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Hosts are tracked by connection, since that is what leaves the bus.
+	if w.registry.addHost(string(sender)) {
+		w.props.SetMust(watcherIface, "IsStatusNotifierHostRegistered", true)
+	}
+	w.emit("StatusNotifierHostRegistered")
+	return nil
+}
+
+func (w *watcher) listen(signals <-chan *godbus.Signal) {
+	for sig := range signals {
+		if sig.Name != "org.freedesktop.DBus.NameOwnerChanged" || len(sig.Body) != 3 {
+			continue
+		}
+		name, _ := sig.Body[0].(string)
+		if newOwner, _ := sig.Body[2].(string); name == "" || newOwner != "" {
+			continue
+		}
+		w.mu.Lock()
+		hadHosts := len(w.registry.hosts) > 0
+		removed := w.registry.removeName(name)
+		if len(removed) > 0 {
+			w.itemsChanged()
+		}
+		for _, key := range removed {
+			w.emit("StatusNotifierItemUnregistered", key)
+		}
+		if hadHosts && len(w.registry.hosts) == 0 {
+			w.props.SetMust(watcherIface, "IsStatusNotifierHostRegistered", false)
+		}
+		w.mu.Unlock()
+	}
+}
+
+// itemsChanged updates the list of registered items. Must be called with the
+// lock held.
+func (w *watcher) itemsChanged() {
+	items := make([]string, len(w.registry.items))
+	copy(items, w.registry.items)
+	w.props.SetMust(watcherIface, "RegisteredStatusNotifierItems", items)
+}
+
+func (w *watcher) emit(signal string, args ...interface{}) {
+	w.conn.Emit(watcherPath, watcherIface+"."+signal, args...)
+}
@@ -0,0 +1,42 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tray
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	r := newRegistry()
+	require.Equal(t, "org.example.App", r.addItem(":1.5", "org.example.App"))
+	require.Equal(t, ":1.6/org/ayatana/Item", r.addItem(":1.6", "/org/ayatana/Item"))
+	require.Empty(t, r.addItem(":1.5", "org.example.App"), "duplicate registration")
+	require.Equal(t, []string{"org.example.App", ":1.6/org/ayatana/Item"}, r.items)
+
+	require.True(t, r.addHost(":1.7"), "first host")
+	require.False(t, r.addHost(":1.8"), "second host")
+
+	require.Empty(t, r.removeName(":1.5"), "items registered by name")
+	require.Equal(t, []string{":1.6/org/ayatana/Item"}, r.removeName(":1.6"))
+	require.Equal(t, []string{"org.example.App"}, r.removeName("org.example.App"))
+	require.Empty(t, r.items)
+
+	r.removeName(":1.7")
+	require.Len(t, r.hosts, 1)
+	r.removeName(":1.8")
+	require.Empty(t, r.hosts)
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ups
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type nut struct {
+	ups  string
+	addr string
+}
+
+// NUT returns a backend that reads the status of a UPS from a NUT server
+// (upsd), using the same "upsname[@hostname[:port]]" syntax as upsc.
+// The host defaults to localhost, and the port to 3493.
+func NUT(ups string) Backend {
+	host := "localhost"
+	if at := strings.LastIndex(ups, "@"); at >= 0 {
+		ups, host = ups[:at], ups[at+1:]
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "3493")
+	}
+	return nut{ups, host}
+}
+
+func (n nut) Info() (Info, error) {
+	vars, err := n.listVars()
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{
+		Model:  vars["ups.model"],
+		Status: strings.Fields(vars["ups.status"]),
+		Charge: -1,
+		Load:   -1,
+	}
+	if info.Model == "" {
+		info.Model = vars["device.model"]
+	}
+	if c, err := strconv.ParseFloat(vars["battery.charge"], 64); err == nil {
+		info.Charge = int(math.Round(c))
+	}
+	if l, err := strconv.ParseFloat(vars["ups.load"], 64); err == nil {
+		info.Load = int(math.Round(l))
+	}
+	if r, err := strconv.ParseFloat(vars["battery.runtime"], 64); err == nil {
+		info.Runtime = time.Duration(r) * time.Second
+	}
+	return info, nil
+}
+
+// listVars gets all variables of the UPS, using the network protocol
+// described at https://networkupstools.org/docs/developer-guide.chunked/ar01s09.html.
+func (n nut) listVars() (map[string]string, error) {
+	conn, err := net.DialTimeout("tcp", n.addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := fmt.Fprintf(conn, "LIST VAR %s\n", n.ups); err != nil {
+		return nil, err
+	}
+	vars := map[string]string{}
+	s := bufio.NewScanner(conn)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.HasPrefix(line, "ERR "):
+			return nil, fmt.Errorf("NUT: %s", strings.TrimPrefix(line, "ERR "))
+		case strings.HasPrefix(line, "BEGIN LIST VAR "):
+		case strings.HasPrefix(line, "END LIST VAR "):
+			fmt.Fprintf(conn, "LOGOUT\n")
+			return vars, nil
+		default:
+			// VAR <upsname> <varname> "<value>"
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) == 4 && fields[0] == "VAR" {
+				vars[fields[2]] = unquote(fields[3])
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("NUT: unexpected end of response")
+}
+
+// unquote removes the quotes and escapes from a NUT value.
+func unquote(value string) string {
+	value = strings.TrimPrefix(value, `"`)
+	value = strings.TrimSuffix(value, `"`)
+	var out strings.Builder
+	escaped := false
+	for _, r := range value {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		out.WriteRune(r)
+	}
+	return out.String()
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ups
+
+import (
+	"bufio"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const powerSupplyDir = "/sys/class/power_supply"
+
+// Overridden in tests.
+var fs = afero.NewOsFs()
+
+type sysfs string
+
+// Sysfs returns a backend that reads the status of a UPS from
+// /sys/class/power_supply, as provided by the kernel for some USB HID UPSes.
+// If name is empty, the first power supply of type UPS is used.
+func Sysfs(name string) Backend {
+	return sysfs(name)
+}
+
+// readUevent reads the properties of a power supply.
+func readUevent(name string) (map[string]string, error) {
+	f, err := fs.Open(filepath.Join(powerSupplyDir, name, "uevent"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	props := map[string]string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		split := strings.SplitN(strings.TrimSpace(s.Text()), "=", 2)
+		if len(split) == 2 {
+			props[strings.TrimPrefix(split[0], "POWER_SUPPLY_")] = split[1]
+		}
+	}
+	return props, s.Err()
+}
+
+// findUPS returns the properties of the first power supply of type UPS.
+func findUPS() (map[string]string, error) {
+	dir, err := fs.Open(powerSupplyDir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		props, err := readUevent(name)
+		if err == nil && props["TYPE"] == "UPS" {
+			return props, nil
+		}
+	}
+	return nil, errors.New("No UPS found in " + powerSupplyDir)
+}
+
+func (s sysfs) Info() (Info, error) {
+	var props map[string]string
+	var err error
+	if s == "" {
+		props, err = findUPS()
+	} else {
+		props, err = readUevent(string(s))
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Model: props["MODEL_NAME"], Charge: -1, Load: -1}
+	if c, err := strconv.Atoi(props["CAPACITY"]); err == nil {
+		info.Charge = c
+	}
+	if t, err := strconv.Atoi(props["TIME_TO_EMPTY_NOW"]); err == nil {
+		info.Runtime = time.Duration(t) * time.Second
+	}
+	switch props["STATUS"] {
+	case "Discharging":
+		info.Status = []string{OnBattery, Discharging}
+	case "Charging":
+		info.Status = []string{Online, Charging}
+	default:
+		info.Status = []string{Online}
+	}
+	switch props["CAPACITY_LEVEL"] {
+	case "Low", "Critical":
+		info.Status = append(info.Status, LowBattery)
+	}
+	return info, nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ups provides an i3bar module that shows the status of an
+// uninterruptible power supply, from a Network UPS Tools (NUT) server, or
+// from sysfs for UPSes supported directly by the kernel.
+package ups // import "barista.run/modules/ups"
+
+import (
+	"fmt"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+)
+
+// Status flags, as used by NUT in ups.status. Backends that do not use NUT
+// map their status to the closest flags.
+const (
+	Online         = "OL"
+	OnBattery      = "OB"
+	LowBattery     = "LB"
+	Charging       = "CHRG"
+	Discharging    = "DISCHRG"
+	ReplaceBattery = "RB"
+	Overloaded     = "OVER"
+	Bypass         = "BYPASS"
+	ForcedShutdown = "FSD"
+)
+
+// Info represents the status of a UPS.
+type Info struct {
+	// Model of the UPS, if known.
+	Model string
+	// Status contains the status flags of the UPS, e.g. "OL" and "CHRG".
+	Status []string
+	// Charge is the battery charge, in percent, or -1 if unknown.
+	Charge int
+	// Runtime is the estimated battery runtime, or zero if unknown.
+	Runtime time.Duration
+	// Load is the output load, in percent, or -1 if unknown.
+	Load       int
+	lowRuntime time.Duration
+}
+
+// HasStatus returns true if the UPS reports the given status flag.
+func (i Info) HasStatus(flag string) bool {
+	for _, s := range i.Status {
+		if s == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// OnBattery returns true if the UPS is running on battery power.
+func (i Info) OnBattery() bool {
+	return i.HasStatus(OnBattery)
+}
+
+// LowBattery returns true if the UPS reports a low battery, or if the
+// estimated runtime is below the module's low runtime threshold.
+func (i Info) LowBattery() bool {
+	if i.HasStatus(LowBattery) {
+		return true
+	}
+	return i.Runtime > 0 && i.Runtime < i.lowRuntime
+}
+
+// Alert returns true if the UPS needs attention: it is on battery, low on
+// battery, overloaded, or shutting down.
+func (i Info) Alert() bool {
+	return i.OnBattery() || i.LowBattery() ||
+		i.HasStatus(Overloaded) || i.HasStatus(ForcedShutdown)
+}
+
+// Backend is an interface for sources of UPS status.
+type Backend interface {
+	Info() (Info, error)
+}
+
+// Module represents a bar.Module that displays UPS status.
+type Module struct {
+	backend    Backend
+	scheduler  *timing.Scheduler
+	lowRuntime value.Value // of time.Duration
+	outputFunc value.Value // of func(Info) bar.Output
+}
+
+// New constructs a UPS module using the given backend.
+func New(backend Backend) *Module {
+	m := &Module{backend: backend, scheduler: timing.NewScheduler()}
+	l.Register(m, "scheduler", "lowRuntime", "outputFunc")
+	m.RefreshInterval(5 * time.Second)
+	m.LowRuntime(5 * time.Minute)
+	m.Output(func(i Info) bar.Output {
+		var out string
+		switch {
+		case i.OnBattery():
+			out = "UPS on battery"
+		case i.HasStatus(ReplaceBattery):
+			out = "UPS replace battery"
+		default:
+			out = "UPS"
+		}
+		if i.Charge >= 0 {
+			out += fmt.Sprintf(" %d%%", i.Charge)
+		}
+		if i.OnBattery() && i.Runtime > 0 {
+			out += fmt.Sprintf(" %s", i.Runtime.Round(time.Minute))
+		}
+		return outputs.Text(out).Urgent(i.Alert())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// LowRuntime sets the runtime below which the battery is considered low,
+// even if the UPS does not report a low battery.
+func (m *Module) LowRuntime(runtime time.Duration) *Module {
+	m.lowRuntime.Set(runtime)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	info, err := m.backend.Info()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+	nextLowRuntime, done := m.lowRuntime.Subscribe()
+	defer done()
+	for {
+		if !s.Error(err) {
+			info.lowRuntime = m.lowRuntime.Get().(time.Duration)
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-m.scheduler.C:
+			info, err = m.backend.Info()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-nextLowRuntime:
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ups
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBackend struct {
+	info Info
+	err  error
+}
+
+func (f *fakeBackend) Info() (Info, error) {
+	return f.info, f.err
+}
+
+func TestModule(t *testing.T) {
+	testBar.New(t)
+	b := &fakeBackend{info: Info{Status: []string{Online}, Charge: 100, Load: 20}}
+	m := New(b)
+	testBar.Run(m)
+	out := testBar.NextOutput("initial")
+	out.AssertText([]string{"UPS 100%"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+
+	b.info = Info{Status: []string{OnBattery, Discharging}, Charge: 80,
+		Runtime: 40*time.Minute + 10*time.Second}
+	testBar.Tick()
+	out = testBar.NextOutput("on battery")
+	out.AssertText([]string{"UPS on battery 80% 40m0s"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.True(t, urgent)
+
+	b.info = Info{Status: []string{Online, ReplaceBattery}, Charge: -1}
+	testBar.Tick()
+	out = testBar.NextOutput("replace battery")
+	out.AssertText([]string{"UPS replace battery"})
+	urgent, _ = out.At(0).Segment().IsUrgent()
+	require.False(t, urgent)
+
+	b.err = errors.New("connection refused")
+	testBar.Tick()
+	testBar.NextOutput("on error").At(0).AssertError()
+
+	b.err = nil
+	b.info = Info{Status: []string{Online}, Charge: 100, Runtime: 8 * time.Minute}
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"UPS 100%"})
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%v", i.LowBattery())
+	})
+	testBar.NextOutput("on output change").AssertText([]string{"false"})
+	m.LowRuntime(10 * time.Minute)
+	testBar.NextOutput("on low runtime change").AssertText([]string{"true"})
+}
+
+func TestInfo(t *testing.T) {
+	for _, tc := range []struct {
+		info       Info
+		onBattery  bool
+		lowBattery bool
+		alert      bool
+	}{
+		{Info{Status: []string{Online}}, false, false, false},
+		{Info{Status: []string{Online, Overloaded}}, false, false, true},
+		{Info{Status: []string{OnBattery}}, true, false, true},
+		{Info{Status: []string{OnBattery, LowBattery}}, true, true, true},
+		{Info{Status: []string{Online}, Runtime: time.Minute,
+			lowRuntime: 2 * time.Minute}, false, true, true},
+		{Info{Status: []string{Online}, lowRuntime: time.Minute}, false, false, false},
+		{Info{Status: []string{ForcedShutdown}}, false, false, true},
+	} {
+		desc := fmt.Sprintf("%+v", tc.info)
+		require.Equal(t, tc.onBattery, tc.info.OnBattery(), desc)
+		require.Equal(t, tc.lowBattery, tc.info.LowBattery(), desc)
+		require.Equal(t, tc.alert, tc.info.Alert(), desc)
+	}
+}
+
+// startNUT starts a fake NUT server, which responds to LIST VAR requests
+// with the given responses, keyed by UPS name.
+func startNUT(t *testing.T, responses map[string][]string) (string, func()) {
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				s := bufio.NewScanner(conn)
+				for s.Scan() {
+					line := s.Text()
+					switch {
+					case line == "LOGOUT":
+						fmt.Fprintln(conn, "OK Goodbye")
+						return
+					case strings.HasPrefix(line, "LIST VAR "):
+						ups := strings.TrimPrefix(line, "LIST VAR ")
+						resp, ok := responses[ups]
+						if !ok {
+							resp = []string{"ERR UNKNOWN-UPS"}
+						}
+						for _, r := range resp {
+							fmt.Fprintln(conn, r)
+						}
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func TestNUT(t *testing.T) {
+	addr, stop := startNUT(t, map[string][]string{
+		"myups": {
+			"BEGIN LIST VAR myups",
+			`VAR myups battery.charge "87"`,
+			`VAR myups battery.runtime "1530"`,
+			`VAR myups device.model "Back-UPS \"XS\" 1500G"`,
+			`VAR myups ups.load "23.6"`,
+			`VAR myups ups.status "OB DISCHRG"`,
+			"END LIST VAR myups",
+		},
+		"minimal": {
+			"BEGIN LIST VAR minimal",
+			`VAR minimal ups.status "OL"`,
+			`VAR minimal ups.model "Eaton"`,
+			"END LIST VAR minimal",
+		},
+	})
+	defer stop()
+
+	info, err := NUT("myups@" + addr).Info()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		Model:   `Back-UPS "XS" 1500G`,
+		Status:  []string{OnBattery, Discharging},
+		Charge:  87,
+		Runtime: 1530 * time.Second,
+		Load:    24,
+	}, info)
+
+	info, err = NUT("minimal@" + addr).Info()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		Model:  "Eaton",
+		Status: []string{Online},
+		Charge: -1,
+		Load:   -1,
+	}, info)
+
+	_, err = NUT("other@" + addr).Info()
+	require.EqualError(t, err, "NUT: UNKNOWN-UPS")
+
+	require.Equal(t, nut{"ups", "localhost:3493"}, NUT("ups"))
+	require.Equal(t, nut{"ups", "nas:3493"}, NUT("ups@nas"))
+	require.Equal(t, nut{"ups", "nas:1234"}, NUT("ups@nas:1234"))
+	require.Equal(t, nut{"ups", "[::1]:3493"}, NUT("ups@::1"))
+}
+
+func TestNUTErrors(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		bufio.NewReader(conn).ReadString('\n')
+		fmt.Fprintln(conn, "BEGIN LIST VAR ups")
+		conn.Close()
+	}()
+	_, err = NUT("ups@" + addr).Info()
+	require.Error(t, err, "truncated response")
+
+	l.Close()
+	_, err = NUT("ups@" + addr).Info()
+	require.Error(t, err, "connection refused")
+}
+
+func TestSysfs(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	_, err := Sysfs("").Info()
+	require.Error(t, err, "no power supplies")
+
+	afero.WriteFile(fs, "/sys/class/power_supply/BAT0/uevent", []byte(
+		"POWER_SUPPLY_NAME=BAT0\nPOWER_SUPPLY_TYPE=Battery\nPOWER_SUPPLY_CAPACITY=50\n"), 0644)
+	_, err = Sysfs("").Info()
+	require.Error(t, err, "no UPS")
+
+	afero.WriteFile(fs, "/sys/class/power_supply/hid-0003:051D:0002.0001-battery/uevent", []byte(`
+POWER_SUPPLY_NAME=hid-0003:051D:0002.0001-battery
+POWER_SUPPLY_TYPE=UPS
+POWER_SUPPLY_MODEL_NAME=Back-UPS ES 700
+POWER_SUPPLY_STATUS=Discharging
+POWER_SUPPLY_CAPACITY=35
+POWER_SUPPLY_CAPACITY_LEVEL=Low
+POWER_SUPPLY_TIME_TO_EMPTY_NOW=420
+`), 0644)
+	info, err := Sysfs("").Info()
+	require.NoError(t, err)
+	require.Equal(t, Info{
+		Model:   "Back-UPS ES 700",
+		Status:  []string{OnBattery, Discharging, LowBattery},
+		Charge:  35,
+		Runtime: 7 * time.Minute,
+		Load:    -1,
+	}, info)
+
+	info, err = Sysfs("BAT0").Info()
+	require.NoError(t, err)
+	require.Equal(t, Info{Status: []string{Online}, Charge: 50, Load: -1}, info)
+
+	afero.WriteFile(fs, "/sys/class/power_supply/BAT0/uevent", []byte(
+		"POWER_SUPPLY_STATUS=Charging\n"), 0644)
+	info, err = Sysfs("BAT0").Info()
+	require.NoError(t, err)
+	require.Equal(t, []string{Online, Charging}, info.Status)
+	require.Equal(t, -1, info.Charge)
+
+	_, err = Sysfs("BAT1").Info()
+	require.Error(t, err, "missing power supply")
+}
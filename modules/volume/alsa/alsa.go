@@ -32,10 +32,12 @@ import (
 type alsaModule struct {
 	cardName  string
 	mixerName string
+	capture   bool
 }
 
 type alsaController struct {
-	elem *ctyp_snd_mixer_elem_t
+	elem    *ctyp_snd_mixer_elem_t
+	capture bool
 }
 
 func alsaError(result int32, desc string) error {
@@ -59,7 +61,30 @@ func DefaultMixer() volume.Provider {
 	return Mixer("default", "Master")
 }
 
+// CaptureMixer constructs an instance of the volume module for the capture
+// (recording) controls of a specific card and mixer on that card. ALSA does
+// not report whether applications are recording, so Volume.Recording is
+// always false.
+func CaptureMixer(card, mixer string) volume.Provider {
+	return &alsaModule{
+		cardName:  card,
+		mixerName: mixer,
+		capture:   true,
+	}
+}
+
+// DefaultCaptureMixer constructs an instance of the volume module for the
+// default capture mixer.
+func DefaultCaptureMixer() volume.Provider {
+	return CaptureMixer("default", "Capture")
+}
+
 func (c alsaController) SetVolume(newVol int64) error {
+	if c.capture {
+		return alsaError(
+			alsa.snd_mixer_selem_set_capture_volume_all(c.elem, newVol),
+			"snd_mixer_selem_set_capture_volume_all")
+	}
 	return alsaError(
 		alsa.snd_mixer_selem_set_playback_volume_all(c.elem, newVol),
 		"snd_mixer_selem_set_playback_volume_all")
@@ -72,6 +97,11 @@ func (c alsaController) SetMuted(muted bool) error {
 	} else {
 		muteInt = 1
 	}
+	if c.capture {
+		return alsaError(
+			alsa.snd_mixer_selem_set_capture_switch_all(c.elem, muteInt),
+			"snd_mixer_selem_set_capture_switch_all")
+	}
 	return alsaError(
 		alsa.snd_mixer_selem_set_playback_switch_all(c.elem, muteInt),
 		"snd_mixer_selem_set_playback_switch_all")
@@ -113,13 +143,11 @@ func (m *alsaModule) Worker(s *value.ErrorValue) {
 		s.Error(fmt.Errorf("snd_mixer_find_selem NULL"))
 		return
 	}
-	var min, max, vol int64
-	var mute int32
-	alsa.snd_mixer_selem_get_playback_volume_range(elem, &min, &max)
+	ctrl := alsaController{elem, m.capture}
+	min, max := ctrl.volumeRange()
 	for {
-		alsa.snd_mixer_selem_get_playback_volume(elem, C.SND_MIXER_SCHN_MONO, &vol)
-		alsa.snd_mixer_selem_get_playback_switch(elem, C.SND_MIXER_SCHN_MONO, &mute)
-		s.Set(volume.MakeVolume(min, max, vol, (mute == 0), alsaController{elem}))
+		vol, mute := ctrl.current()
+		s.Set(volume.MakeVolume(min, max, vol, mute, ctrl))
 		errCode := alsa.snd_mixer_wait(handle, -1)
 		// 4 == Interrupted system call, try again.
 		for errCode == -4 {
@@ -133,3 +161,24 @@ func (m *alsaModule) Worker(s *value.ErrorValue) {
 		}
 	}
 }
+
+func (c alsaController) volumeRange() (min, max int64) {
+	if c.capture {
+		alsa.snd_mixer_selem_get_capture_volume_range(c.elem, &min, &max)
+	} else {
+		alsa.snd_mixer_selem_get_playback_volume_range(c.elem, &min, &max)
+	}
+	return min, max
+}
+
+func (c alsaController) current() (vol int64, muted bool) {
+	var enabled int32
+	if c.capture {
+		alsa.snd_mixer_selem_get_capture_volume(c.elem, C.SND_MIXER_SCHN_MONO, &vol)
+		alsa.snd_mixer_selem_get_capture_switch(c.elem, C.SND_MIXER_SCHN_MONO, &enabled)
+	} else {
+		alsa.snd_mixer_selem_get_playback_volume(c.elem, C.SND_MIXER_SCHN_MONO, &vol)
+		alsa.snd_mixer_selem_get_playback_switch(c.elem, C.SND_MIXER_SCHN_MONO, &enabled)
+	}
+	return vol, enabled == 0
+}
@@ -37,6 +37,9 @@ type alsaI interface {
 	snd_mixer_handle_events(arg_mixer *ctyp_snd_mixer_t) int32
 	snd_mixer_load(arg_mixer *ctyp_snd_mixer_t) int32
 	snd_mixer_open(arg_mixer **ctyp_snd_mixer_t, arg_mode int32) int32
+	snd_mixer_selem_get_capture_switch(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value *int32) int32
+	snd_mixer_selem_get_capture_volume(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value *int64) int32
+	snd_mixer_selem_get_capture_volume_range(arg_elem *ctyp_snd_mixer_elem_t, arg_min *int64, arg_max *int64) int32
 	snd_mixer_selem_get_playback_switch(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value *int32) int32
 	snd_mixer_selem_get_playback_volume(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value *int64) int32
 	snd_mixer_selem_get_playback_volume_range(arg_elem *ctyp_snd_mixer_elem_t, arg_min *int64, arg_max *int64) int32
@@ -45,6 +48,8 @@ type alsaI interface {
 	snd_mixer_selem_id_set_index(arg_obj *ctyp_snd_mixer_selem_id_t, arg_val uint32)
 	snd_mixer_selem_id_set_name(arg_obj *ctyp_snd_mixer_selem_id_t, arg_val string)
 	snd_mixer_selem_register(arg_mixer *ctyp_snd_mixer_t, arg_options *ctyp_struct_snd_mixer_selem_regopt, arg_classp **ctyp_snd_mixer_class_t) int32
+	snd_mixer_selem_set_capture_switch_all(arg_elem *ctyp_snd_mixer_elem_t, arg_value int32) int32
+	snd_mixer_selem_set_capture_volume_all(arg_elem *ctyp_snd_mixer_elem_t, arg_value int64) int32
 	snd_mixer_selem_set_playback_switch(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value int32) int32
 	snd_mixer_selem_set_playback_switch_all(arg_elem *ctyp_snd_mixer_elem_t, arg_value int32) int32
 	snd_mixer_selem_set_playback_volume(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value int64) int32
@@ -101,6 +106,27 @@ func (alsaImpl) snd_mixer_open(arg_mixer **ctyp_snd_mixer_t, arg_mode int32) int
 	result_c := C.snd_mixer_open(tmp_arg_mixer, tmp_arg_mode)
 	return int32(result_c)
 }
+func (alsaImpl) snd_mixer_selem_get_capture_switch(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value *int32) int32 {
+	tmp_arg_elem := (*C.snd_mixer_elem_t)(arg_elem)
+	tmp_arg_channel := C.snd_mixer_selem_channel_id_t(arg_channel)
+	tmp_arg_value := (*C.int)(arg_value)
+	result_c := C.snd_mixer_selem_get_capture_switch(tmp_arg_elem, tmp_arg_channel, tmp_arg_value)
+	return int32(result_c)
+}
+func (alsaImpl) snd_mixer_selem_get_capture_volume(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value *int64) int32 {
+	tmp_arg_elem := (*C.snd_mixer_elem_t)(arg_elem)
+	tmp_arg_channel := C.snd_mixer_selem_channel_id_t(arg_channel)
+	tmp_arg_value := (*C.long)(arg_value)
+	result_c := C.snd_mixer_selem_get_capture_volume(tmp_arg_elem, tmp_arg_channel, tmp_arg_value)
+	return int32(result_c)
+}
+func (alsaImpl) snd_mixer_selem_get_capture_volume_range(arg_elem *ctyp_snd_mixer_elem_t, arg_min *int64, arg_max *int64) int32 {
+	tmp_arg_elem := (*C.snd_mixer_elem_t)(arg_elem)
+	tmp_arg_min := (*C.long)(arg_min)
+	tmp_arg_max := (*C.long)(arg_max)
+	result_c := C.snd_mixer_selem_get_capture_volume_range(tmp_arg_elem, tmp_arg_min, tmp_arg_max)
+	return int32(result_c)
+}
 func (alsaImpl) snd_mixer_selem_get_playback_switch(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value *int32) int32 {
 	tmp_arg_elem := (*C.snd_mixer_elem_t)(arg_elem)
 	tmp_arg_channel := C.snd_mixer_selem_channel_id_t(arg_channel)
@@ -149,6 +175,18 @@ func (alsaImpl) snd_mixer_selem_register(arg_mixer *ctyp_snd_mixer_t, arg_option
 	result_c := C.snd_mixer_selem_register(tmp_arg_mixer, tmp_arg_options, tmp_arg_classp)
 	return int32(result_c)
 }
+func (alsaImpl) snd_mixer_selem_set_capture_switch_all(arg_elem *ctyp_snd_mixer_elem_t, arg_value int32) int32 {
+	tmp_arg_elem := (*C.snd_mixer_elem_t)(arg_elem)
+	tmp_arg_value := C.int(arg_value)
+	result_c := C.snd_mixer_selem_set_capture_switch_all(tmp_arg_elem, tmp_arg_value)
+	return int32(result_c)
+}
+func (alsaImpl) snd_mixer_selem_set_capture_volume_all(arg_elem *ctyp_snd_mixer_elem_t, arg_value int64) int32 {
+	tmp_arg_elem := (*C.snd_mixer_elem_t)(arg_elem)
+	tmp_arg_value := C.long(arg_value)
+	result_c := C.snd_mixer_selem_set_capture_volume_all(tmp_arg_elem, tmp_arg_value)
+	return int32(result_c)
+}
 func (alsaImpl) snd_mixer_selem_set_playback_switch(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value int32) int32 {
 	tmp_arg_elem := (*C.snd_mixer_elem_t)(arg_elem)
 	tmp_arg_channel := C.snd_mixer_selem_channel_id_t(arg_channel)
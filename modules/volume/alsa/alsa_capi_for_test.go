@@ -28,6 +28,9 @@ type alsaTester struct {
 	mock_snd_mixer_handle_events                   func(*ctyp_snd_mixer_t) int32
 	mock_snd_mixer_load                            func(*ctyp_snd_mixer_t) int32
 	mock_snd_mixer_open                            func(**ctyp_snd_mixer_t, int32) int32
+	mock_snd_mixer_selem_get_capture_switch        func(*ctyp_snd_mixer_elem_t, ctyp_snd_mixer_selem_channel_id_t, *int32) int32
+	mock_snd_mixer_selem_get_capture_volume        func(*ctyp_snd_mixer_elem_t, ctyp_snd_mixer_selem_channel_id_t, *int64) int32
+	mock_snd_mixer_selem_get_capture_volume_range  func(*ctyp_snd_mixer_elem_t, *int64, *int64) int32
 	mock_snd_mixer_selem_get_playback_switch       func(*ctyp_snd_mixer_elem_t, ctyp_snd_mixer_selem_channel_id_t, *int32) int32
 	mock_snd_mixer_selem_get_playback_volume       func(*ctyp_snd_mixer_elem_t, ctyp_snd_mixer_selem_channel_id_t, *int64) int32
 	mock_snd_mixer_selem_get_playback_volume_range func(*ctyp_snd_mixer_elem_t, *int64, *int64) int32
@@ -36,6 +39,8 @@ type alsaTester struct {
 	mock_snd_mixer_selem_id_set_index              func(*ctyp_snd_mixer_selem_id_t, uint32)
 	mock_snd_mixer_selem_id_set_name               func(*ctyp_snd_mixer_selem_id_t, string)
 	mock_snd_mixer_selem_register                  func(*ctyp_snd_mixer_t, *ctyp_struct_snd_mixer_selem_regopt, **ctyp_snd_mixer_class_t) int32
+	mock_snd_mixer_selem_set_capture_switch_all    func(*ctyp_snd_mixer_elem_t, int32) int32
+	mock_snd_mixer_selem_set_capture_volume_all    func(*ctyp_snd_mixer_elem_t, int64) int32
 	mock_snd_mixer_selem_set_playback_switch       func(*ctyp_snd_mixer_elem_t, ctyp_snd_mixer_selem_channel_id_t, int32) int32
 	mock_snd_mixer_selem_set_playback_switch_all   func(*ctyp_snd_mixer_elem_t, int32) int32
 	mock_snd_mixer_selem_set_playback_volume       func(*ctyp_snd_mixer_elem_t, ctyp_snd_mixer_selem_channel_id_t, int64) int32
@@ -183,6 +188,57 @@ func (t *alsaTester) snd_mixer_open(arg_mixer **ctyp_snd_mixer_t, arg_mode int32
 	return ret
 }
 
+func (t *alsaTester) on_snd_mixer_selem_get_capture_switch(fn func(*ctyp_snd_mixer_elem_t, ctyp_snd_mixer_selem_channel_id_t, *int32) int32) *alsaTester {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mock_snd_mixer_selem_get_capture_switch = fn
+	return t
+}
+
+func (t *alsaTester) snd_mixer_selem_get_capture_switch(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value *int32) int32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var ret int32
+	if t.mock_snd_mixer_selem_get_capture_switch != nil {
+		ret = t.mock_snd_mixer_selem_get_capture_switch(arg_elem, arg_channel, arg_value)
+	}
+	return ret
+}
+
+func (t *alsaTester) on_snd_mixer_selem_get_capture_volume(fn func(*ctyp_snd_mixer_elem_t, ctyp_snd_mixer_selem_channel_id_t, *int64) int32) *alsaTester {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mock_snd_mixer_selem_get_capture_volume = fn
+	return t
+}
+
+func (t *alsaTester) snd_mixer_selem_get_capture_volume(arg_elem *ctyp_snd_mixer_elem_t, arg_channel ctyp_snd_mixer_selem_channel_id_t, arg_value *int64) int32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var ret int32
+	if t.mock_snd_mixer_selem_get_capture_volume != nil {
+		ret = t.mock_snd_mixer_selem_get_capture_volume(arg_elem, arg_channel, arg_value)
+	}
+	return ret
+}
+
+func (t *alsaTester) on_snd_mixer_selem_get_capture_volume_range(fn func(*ctyp_snd_mixer_elem_t, *int64, *int64) int32) *alsaTester {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mock_snd_mixer_selem_get_capture_volume_range = fn
+	return t
+}
+
+func (t *alsaTester) snd_mixer_selem_get_capture_volume_range(arg_elem *ctyp_snd_mixer_elem_t, arg_min *int64, arg_max *int64) int32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var ret int32
+	if t.mock_snd_mixer_selem_get_capture_volume_range != nil {
+		ret = t.mock_snd_mixer_selem_get_capture_volume_range(arg_elem, arg_min, arg_max)
+	}
+	return ret
+}
+
 func (t *alsaTester) on_snd_mixer_selem_get_playback_switch(fn func(*ctyp_snd_mixer_elem_t, ctyp_snd_mixer_selem_channel_id_t, *int32) int32) *alsaTester {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -313,6 +369,40 @@ func (t *alsaTester) snd_mixer_selem_register(arg_mixer *ctyp_snd_mixer_t, arg_o
 	return ret
 }
 
+func (t *alsaTester) on_snd_mixer_selem_set_capture_switch_all(fn func(*ctyp_snd_mixer_elem_t, int32) int32) *alsaTester {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mock_snd_mixer_selem_set_capture_switch_all = fn
+	return t
+}
+
+func (t *alsaTester) snd_mixer_selem_set_capture_switch_all(arg_elem *ctyp_snd_mixer_elem_t, arg_value int32) int32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var ret int32
+	if t.mock_snd_mixer_selem_set_capture_switch_all != nil {
+		ret = t.mock_snd_mixer_selem_set_capture_switch_all(arg_elem, arg_value)
+	}
+	return ret
+}
+
+func (t *alsaTester) on_snd_mixer_selem_set_capture_volume_all(fn func(*ctyp_snd_mixer_elem_t, int64) int32) *alsaTester {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mock_snd_mixer_selem_set_capture_volume_all = fn
+	return t
+}
+
+func (t *alsaTester) snd_mixer_selem_set_capture_volume_all(arg_elem *ctyp_snd_mixer_elem_t, arg_value int64) int32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var ret int32
+	if t.mock_snd_mixer_selem_set_capture_volume_all != nil {
+		ret = t.mock_snd_mixer_selem_set_capture_volume_all(arg_elem, arg_value)
+	}
+	return ret
+}
+
 func (t *alsaTester) on_snd_mixer_selem_set_playback_switch(fn func(*ctyp_snd_mixer_elem_t, ctyp_snd_mixer_selem_channel_id_t, int32) int32) *alsaTester {
 	t.mu.Lock()
 	defer t.mu.Unlock()
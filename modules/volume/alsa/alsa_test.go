@@ -285,3 +285,87 @@ func TestAlsaModule(t *testing.T) {
 		notifier.AssertClosed(t, ch, "mixer closed on error")
 	}
 }
+
+func TestCaptureMixer(t *testing.T) {
+	testBar.New(t)
+	alsaT := alsaTest()
+
+	oldRateLimiter := volume.RateLimiter
+	defer func() { volume.RateLimiter = oldRateLimiter }()
+	volume.RateLimiter = rate.NewLimiter(rate.Inf, 0)
+
+	mic := &elem{0, 20, 10, 1}
+	mixerWait := make(chan struct{}, 1)
+	var mixerName string
+
+	alsaT.on_snd_mixer_selem_id_malloc(func(ptrToPtr **ctyp_snd_mixer_selem_id_t) int32 {
+		*ptrToPtr = (*ctyp_snd_mixer_selem_id_t)(unsafe.Pointer(new(selem)))
+		return 0
+	})
+	alsaT.on_snd_mixer_selem_id_set_name(func(_ *ctyp_snd_mixer_selem_id_t, n string) {
+		mixerName = n
+	})
+	alsaT.on_snd_mixer_find_selem(func(*ctyp_snd_mixer_t, *ctyp_snd_mixer_selem_id_t) *ctyp_snd_mixer_elem_t {
+		return (*ctyp_snd_mixer_elem_t)(unsafe.Pointer(mic))
+	})
+	alsaT.on_snd_mixer_selem_get_capture_volume_range(func(cptrElem *ctyp_snd_mixer_elem_t, min *int64, max *int64) int32 {
+		ptrElem := (*elem)(unsafe.Pointer(cptrElem))
+		*min = ptrElem.min
+		*max = ptrElem.max
+		return 0
+	})
+	alsaT.on_snd_mixer_selem_get_capture_volume(func(cptrElem *ctyp_snd_mixer_elem_t, _ ctyp_snd_mixer_selem_channel_id_t, vol *int64) int32 {
+		*vol = (*elem)(unsafe.Pointer(cptrElem)).vol
+		return 0
+	})
+	alsaT.on_snd_mixer_selem_get_capture_switch(func(cptrElem *ctyp_snd_mixer_elem_t, _ ctyp_snd_mixer_selem_channel_id_t, enabled *int32) int32 {
+		*enabled = (*elem)(unsafe.Pointer(cptrElem)).enabled
+		return 0
+	})
+	alsaT.on_snd_mixer_selem_set_capture_switch_all(func(cptrElem *ctyp_snd_mixer_elem_t, enabled int32) int32 {
+		(*elem)(unsafe.Pointer(cptrElem)).enabled = enabled
+		return 0
+	})
+	alsaT.on_snd_mixer_selem_set_capture_volume_all(func(cptrElem *ctyp_snd_mixer_elem_t, vol int64) int32 {
+		(*elem)(unsafe.Pointer(cptrElem)).vol = vol
+		return 0
+	})
+	alsaT.on_snd_mixer_selem_set_playback_switch_all(func(*ctyp_snd_mixer_elem_t, int32) int32 {
+		require.Fail(t, "playback switch changed by capture mixer")
+		return -1
+	})
+	alsaT.on_snd_mixer_wait(func(*ctyp_snd_mixer_t, int32) int32 {
+		select {
+		case <-mixerWait:
+			return 0
+		case <-time.After(10 * time.Millisecond):
+			return -4 // interrupted.
+		}
+	})
+
+	testBar.Run(volume.New(DefaultCaptureMixer()))
+	out := testBar.NextOutput()
+	out.AssertText([]string{"50%"})
+	require.Equal(t, "Capture", mixerName)
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonLeft})
+	out = testBar.NextOutput()
+	out.AssertText([]string{"MUT"})
+	require.Equal(t, int32(0), mic.enabled, "capture switch turned off")
+
+	out.At(0).Click(bar.Event{Button: bar.ButtonLeft})
+	out = testBar.NextOutput()
+	out.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	testBar.NextOutput().AssertText([]string{"55%"})
+
+	doneChan := make(chan struct{})
+	alsaT.on_snd_mixer_close(func(*ctyp_snd_mixer_t) int32 {
+		close(doneChan)
+		return 0
+	})
+	alsaT.on_snd_mixer_wait(func(*ctyp_snd_mixer_t, int32) int32 {
+		return -1
+	})
+	mixerWait <- struct{}{}
+	notifier.AssertClosed(t, doneChan, "mixer closed on error")
+}
@@ -27,11 +27,13 @@ import (
 
 // PulseAudio implementation.
 type paModule struct {
-	sinkName string
+	deviceName string
+	// kind is "Sink" for playback devices, or "Source" for capture devices.
+	kind string
 }
 
 type paController struct {
-	sink dbus.BusObject
+	device dbus.BusObject
 }
 
 func dialAndAuth(addr string) (*dbus.Conn, error) {
@@ -81,7 +83,7 @@ func openPulseAudio() (*dbus.Conn, error) {
 
 // Sink creates a PulseAudio volume module for a named sink.
 func Sink(sinkName string) volume.Provider {
-	return &paModule{sinkName: sinkName}
+	return &paModule{deviceName: sinkName, kind: "Sink"}
 }
 
 // DefaultSink creates a PulseAudio volume module that follows the default sink.
@@ -89,8 +91,21 @@ func DefaultSink() volume.Provider {
 	return Sink("")
 }
 
+// Source creates a PulseAudio volume module for a named source (e.g. a
+// microphone). Volume.Recording is set while any application records from
+// the source.
+func Source(sourceName string) volume.Provider {
+	return &paModule{deviceName: sourceName, kind: "Source"}
+}
+
+// DefaultSource creates a PulseAudio volume module that follows the default
+// source.
+func DefaultSource() volume.Provider {
+	return Source("")
+}
+
 func (c *paController) SetVolume(newVol int64) error {
-	return c.sink.Call(
+	return c.device.Call(
 		"org.freedesktop.DBus.Properties.Set",
 		0,
 		"org.PulseAudio.Core1.Device",
@@ -100,7 +115,7 @@ func (c *paController) SetVolume(newVol int64) error {
 }
 
 func (c *paController) SetMuted(muted bool) error {
-	return c.sink.Call(
+	return c.device.Call(
 		"org.freedesktop.DBus.Properties.Set",
 		0,
 		"org.PulseAudio.Core1.Device",
@@ -118,39 +133,39 @@ func listen(core dbus.BusObject, signal string, objects ...dbus.ObjectPath) erro
 	).Err
 }
 
-func openSink(conn *dbus.Conn, core dbus.BusObject, sinkPath dbus.ObjectPath) (dbus.BusObject, error) {
-	sink := conn.Object("org.PulseAudio.Core1.Sink", sinkPath)
-	if err := listen(core, "Device.VolumeUpdated", sinkPath); err != nil {
+func (m *paModule) openDevice(conn *dbus.Conn, core dbus.BusObject, path dbus.ObjectPath) (dbus.BusObject, error) {
+	device := conn.Object("org.PulseAudio.Core1."+m.kind, path)
+	if err := listen(core, "Device.VolumeUpdated", path); err != nil {
 		return nil, err
 	}
-	return sink, listen(core, "Device.MuteUpdated", sinkPath)
+	return device, listen(core, "Device.MuteUpdated", path)
 }
 
-func openSinkByName(conn *dbus.Conn, core dbus.BusObject, name string) (dbus.BusObject, error) {
+func (m *paModule) openDeviceByName(conn *dbus.Conn, core dbus.BusObject) (dbus.BusObject, error) {
 	var path dbus.ObjectPath
-	err := core.Call("org.PulseAudio.Core1.GetSinkByName", 0, name).Store(&path)
+	err := core.Call("org.PulseAudio.Core1.Get"+m.kind+"ByName", 0, m.deviceName).Store(&path)
 	if err != nil {
 		return nil, err
 	}
-	return openSink(conn, core, path)
+	return m.openDevice(conn, core, path)
 }
 
-func openFallbackSink(conn *dbus.Conn, core dbus.BusObject) (dbus.BusObject, error) {
-	path, err := core.GetProperty("org.PulseAudio.Core1.FallbackSink")
+func (m *paModule) openFallbackDevice(conn *dbus.Conn, core dbus.BusObject) (dbus.BusObject, error) {
+	path, err := core.GetProperty("org.PulseAudio.Core1.Fallback" + m.kind)
 	if err != nil {
 		return nil, err
 	}
-	return openSink(conn, core, path.Value().(dbus.ObjectPath))
+	return m.openDevice(conn, core, path.Value().(dbus.ObjectPath))
 }
 
-func getVolume(sink dbus.BusObject) (volume.Volume, error) {
-	max, err := sink.GetProperty("org.PulseAudio.Core1.Device.BaseVolume")
+func getVolume(device dbus.BusObject) (volume.Volume, error) {
+	max, err := device.GetProperty("org.PulseAudio.Core1.Device.BaseVolume")
 	if err != nil {
 		return volume.Volume{}, err
 	}
 	maxVol := int64(max.Value().(uint32))
 
-	vol, err := sink.GetProperty("org.PulseAudio.Core1.Device.Volume")
+	vol, err := device.GetProperty("org.PulseAudio.Core1.Device.Volume")
 	if err != nil {
 		return volume.Volume{}, err
 	}
@@ -163,13 +178,33 @@ func getVolume(sink dbus.BusObject) (volume.Volume, error) {
 	}
 	currentVol := totalVol / int64(len(channels))
 
-	mute, err := sink.GetProperty("org.PulseAudio.Core1.Device.Mute")
+	mute, err := device.GetProperty("org.PulseAudio.Core1.Device.Mute")
 	if err != nil {
 		return volume.Volume{}, err
 	}
 	muted := mute.Value().(bool)
 
-	return volume.MakeVolume(0, maxVol, currentVol, muted, &paController{sink}), nil
+	return volume.MakeVolume(0, maxVol, currentVol, muted, &paController{device}), nil
+}
+
+// isRecording returns true if any record stream is connected to the source.
+func isRecording(conn *dbus.Conn, core dbus.BusObject, source dbus.ObjectPath) bool {
+	streams, err := core.GetProperty("org.PulseAudio.Core1.RecordStreams")
+	if err != nil {
+		return false
+	}
+	paths, _ := streams.Value().([]dbus.ObjectPath)
+	for _, p := range paths {
+		stream := conn.Object("org.PulseAudio.Core1.Stream", p)
+		dev, err := stream.GetProperty("org.PulseAudio.Core1.Stream.Device")
+		if err != nil {
+			continue
+		}
+		if path, ok := dev.Value().(dbus.ObjectPath); ok && path == source {
+			return true
+		}
+	}
+	return false
 }
 
 func (m *paModule) Worker(s *value.ErrorValue) {
@@ -181,19 +216,32 @@ func (m *paModule) Worker(s *value.ErrorValue) {
 
 	core := conn.Object("org.PulseAudio.Core1", "/org/pulseaudio/core1")
 
-	var sink dbus.BusObject
-	if m.sinkName != "" {
-		sink, err = openSinkByName(conn, core, m.sinkName)
+	var device dbus.BusObject
+	if m.deviceName != "" {
+		device, err = m.openDeviceByName(conn, core)
 	} else {
-		sink, err = openFallbackSink(conn, core)
+		device, err = m.openFallbackDevice(conn, core)
+		if err == nil {
+			err = listen(core, "Fallback"+m.kind+"Updated")
+		}
+	}
+	if err == nil && m.kind == "Source" {
+		err = listen(core, "NewRecordStream")
 		if err == nil {
-			err = listen(core, "FallbackSinkUpdated")
+			err = listen(core, "RecordStreamRemoved")
 		}
 	}
 	if s.Error(err) {
 		return
 	}
-	if s.SetOrError(getVolume(sink)) {
+	update := func() bool {
+		vol, err := getVolume(device)
+		if err == nil && m.kind == "Source" {
+			vol.Recording = isRecording(conn, core, device.Path())
+		}
+		return s.SetOrError(vol, err)
+	}
+	if update() {
 		return
 	}
 
@@ -202,14 +250,15 @@ func (m *paModule) Worker(s *value.ErrorValue) {
 
 	// Listen for signals from D-Bus, and update appropriately.
 	for signal := range signals {
-		// If the fallback sink changed, open the new one.
-		if m.sinkName == "" && signal.Path == core.Path() {
-			sink, err = openFallbackSink(conn, core)
+		// If the fallback device changed, open the new one.
+		if m.deviceName == "" &&
+			signal.Name == "org.PulseAudio.Core1.Fallback"+m.kind+"Updated" {
+			device, err = m.openFallbackDevice(conn, core)
 			if s.Error(err) {
 				return
 			}
 		}
-		if s.SetOrError(getVolume(sink)) {
+		if update() {
 			return
 		}
 	}
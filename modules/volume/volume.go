@@ -13,7 +13,8 @@
 // limitations under the License.
 
 // Package volume provides an i3bar module that interfaces with alsa or pulse
-// to display and control the system volume.
+// to display and control the system volume, or the volume of a capture device
+// such as a microphone.
 package volume // import "barista.run/modules/volume"
 
 import (
@@ -23,6 +24,7 @@ import (
 	"barista.run/base/value"
 	l "barista.run/logging"
 	"barista.run/outputs"
+	"barista.run/timing"
 
 	"golang.org/x/time/rate"
 )
@@ -31,8 +33,11 @@ import (
 type Volume struct {
 	Min, Max, Vol int64
 	Mute          bool
-	controller    Controller
-	update        func(Volume)
+	// Recording is true if any application is recording from a capture
+	// device. It is only set by providers that support it.
+	Recording  bool
+	controller Controller
+	update     func(Volume)
 }
 
 // MakeVolume creates a Volume instance with the given data.
@@ -104,6 +109,7 @@ type Provider interface {
 // Module represents a bar.Module that displays volume information.
 type Module struct {
 	outputFunc value.Value // of func(Volume) bar.Output
+	flash      value.Value // of time.Duration
 	provider   Provider
 }
 
@@ -114,6 +120,13 @@ func (m *Module) Output(outputFunc func(Volume) bar.Output) *Module {
 	return m
 }
 
+// Flash sets how long the module is marked urgent when an application starts
+// recording from a capture device. Use zero to disable.
+func (m *Module) Flash(duration time.Duration) *Module {
+	m.flash.Set(duration)
+	return m
+}
+
 // RateLimiter throttles volume updates to once every ~20ms to avoid unexpected behaviour.
 var RateLimiter = rate.NewLimiter(rate.Every(20*time.Millisecond), 1)
 
@@ -155,18 +168,39 @@ func (m *Module) Stream(s bar.Sink) {
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
 
+	flash := timing.NewScheduler()
+	defer flash.Close()
+	recording, flashing := false, false
+
 	for {
 		if s.Error(err) {
 			return
 		}
 		if volume, ok := v.(Volume); ok {
+			if volume.Recording && !recording {
+				if d := m.flash.Get().(time.Duration); d > 0 {
+					flashing = true
+					flash.After(d)
+				}
+			}
+			if !volume.Recording {
+				flashing = false
+				flash.Stop()
+			}
+			recording = volume.Recording
 			volume.update = func(v Volume) { vol.Set(v) }
-			s.Output(outputs.Group(outputFunc(volume)).
-				OnClick(defaultClickHandler(volume)))
+			out := outputs.Group(outputFunc(volume)).
+				OnClick(defaultClickHandler(volume))
+			if flashing {
+				out.Urgent(true)
+			}
+			s.Output(out)
 		}
 		select {
 		case <-nextV:
 			v, err = vol.Get()
+		case <-flash.C:
+			flashing = false
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(Volume) bar.Output)
 		}
@@ -176,7 +210,8 @@ func (m *Module) Stream(s bar.Sink) {
 // New creates a new module with the given backing implementation.
 func New(provider Provider) *Module {
 	m := &Module{provider: provider}
-	l.Register(m, "outputFunc", "impl")
+	l.Register(m, "outputFunc", "flash", "impl")
+	m.Flash(3 * time.Second)
 	// Default output is just the volume %, "MUT" when muted.
 	m.Output(func(v Volume) bar.Output {
 		if v.Mute {
@@ -18,12 +18,15 @@ import (
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"barista.run/bar"
 	"barista.run/base/value"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
+	"barista.run/timing"
 
+	"github.com/stretchr/testify/require"
 	"golang.org/x/time/rate"
 )
 
@@ -32,8 +35,10 @@ type testVolumeProvider struct {
 	error
 	min, max, vol int64
 	mute          bool
+	recording     bool
 	volChan       chan int64
 	muteChan      chan bool
+	recChan       chan bool
 }
 
 func (t *testVolumeProvider) SetVolume(vol int64) error {
@@ -70,6 +75,7 @@ func (t *testVolumeProvider) Worker(v *value.ErrorValue) {
 			Max:        t.max,
 			Vol:        t.vol,
 			Mute:       t.mute,
+			Recording:  t.recording,
 			controller: t,
 		}, t.error)
 		t.Unlock()
@@ -80,6 +86,9 @@ func (t *testVolumeProvider) Worker(v *value.ErrorValue) {
 		case muted := <-t.muteChan:
 			t.Lock()
 			t.mute = muted
+		case recording := <-t.recChan:
+			t.Lock()
+			t.recording = recording
 		}
 	}
 }
@@ -183,3 +192,52 @@ func TestModule(t *testing.T) {
 
 	testBar.NextOutput("on error").AssertError()
 }
+
+func TestRecordingFlash(t *testing.T) {
+	testBar.New(t)
+	testProvider := &testVolumeProvider{
+		min: 0, max: 100, vol: 50,
+		volChan: make(chan int64, 1), muteChan: make(chan bool, 1),
+		recChan: make(chan bool, 1),
+	}
+	v := New(testProvider)
+	testBar.Run(v)
+
+	out := testBar.NextOutput("on start")
+	out.AssertText([]string{"50%"})
+	out.At(0).AssertUrgent(false)
+
+	testProvider.recChan <- true
+	out = testBar.NextOutput("recording started")
+	out.At(0).AssertUrgent(true)
+
+	testProvider.volChan <- 60
+	out = testBar.NextOutput("volume changed while flashing")
+	out.AssertText([]string{"60%"})
+	out.At(0).AssertUrgent(true)
+
+	now := timing.Now()
+	require.Equal(t, now.Add(3*time.Second), testBar.Tick(), "flash ends")
+	out = testBar.NextOutput("flash ended")
+	out.At(0).AssertUrgent(false)
+
+	testProvider.volChan <- 70
+	out = testBar.NextOutput("volume changed while recording")
+	out.At(0).AssertUrgent(false)
+
+	testProvider.recChan <- false
+	testBar.NextOutput("recording stopped").At(0).AssertUrgent(false)
+
+	v.Flash(0)
+	testProvider.recChan <- true
+	testBar.NextOutput("recording with flash disabled").At(0).AssertUrgent(false)
+	testProvider.recChan <- false
+	testBar.NextOutput("recording stopped")
+
+	v.Flash(time.Minute)
+	testProvider.recChan <- true
+	testBar.NextOutput("recording again").At(0).AssertUrgent(true)
+	testProvider.recChan <- false
+	testBar.NextOutput("stopped before flash ended").At(0).AssertUrgent(false)
+	testBar.AssertNoOutput("flash cancelled")
+}
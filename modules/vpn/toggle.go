@@ -0,0 +1,85 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vpn
+
+import (
+	"os/exec"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+)
+
+// Toggler brings a VPN connection up or down.
+type Toggler interface {
+	Up() error
+	Down() error
+}
+
+type commandToggler struct {
+	up, down []string
+}
+
+// Commands returns a Toggler that runs the given commands to bring the VPN
+// up or down, e.g. wg-quick up wg0 and wg-quick down wg0. Each command is
+// given as the program name followed by its arguments.
+func Commands(up, down []string) Toggler {
+	return commandToggler{up, down}
+}
+
+// NetworkManager returns a Toggler that activates or deactivates the named
+// NetworkManager connection.
+func NetworkManager(connection string) Toggler {
+	return Commands(
+		[]string{"nmcli", "connection", "up", "id", connection},
+		[]string{"nmcli", "connection", "down", "id", connection},
+	)
+}
+
+// For tests.
+var runCommand = func(name string, args ...string) error {
+	return exec.Command(name, args...).Run()
+}
+
+func (c commandToggler) Up() error   { return run(c.up) }
+func (c commandToggler) Down() error { return run(c.down) }
+
+func run(cmd []string) error {
+	if len(cmd) == 0 {
+		return nil
+	}
+	return runCommand(cmd[0], cmd[1:]...)
+}
+
+// toggleClickHandler returns a click handler that toggles the VPN on left
+// click, or nil if no Toggler is configured.
+func toggleClickHandler(t Toggler, s State) func(bar.Event) {
+	if t == nil {
+		return nil
+	}
+	return func(e bar.Event) {
+		if e.Button != bar.ButtonLeft {
+			return
+		}
+		var err error
+		if s.Disconnected() {
+			err = t.Up()
+		} else {
+			err = t.Down()
+		}
+		if err != nil {
+			l.Log("Error toggling vpn: %v", err)
+		}
+	}
+}
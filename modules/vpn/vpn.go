@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package vpn provides an i3bar module for openvpn information.
+// Package vpn provides i3bar modules for VPN information, based on either
+// the presence of a tunnel interface (e.g. openvpn), or WireGuard peers.
 package vpn // import "barista.run/modules/vpn"
 
 import (
@@ -47,18 +48,23 @@ const (
 type Module struct {
 	intf       string
 	outputFunc value.Value // of func(State) bar.Output
+	toggler    value.Value // of Toggler
 }
 
 // New constructs an instance of the VPN module for the specified interface.
 func New(iface string) *Module {
 	m := &Module{intf: iface}
 	l.Label(m, iface)
-	l.Register(m, "outputFunc")
-	// Default output is just 'VPN' when connected.
+	l.Register(m, "outputFunc", "toggler")
+	// Default output is just 'VPN' when connected, and if a toggler is set,
+	// 'VPN off' when disconnected, so that there is something to click on.
 	m.Output(func(s State) bar.Output {
 		if s.Connected() {
 			return outputs.Text("VPN")
 		}
+		if _, ok := m.toggler.Get().(Toggler); ok && s.Disconnected() {
+			return outputs.Text("VPN off")
+		}
 		return nil
 	})
 	return m
@@ -76,23 +82,37 @@ func (m *Module) Output(outputFunc func(State) bar.Output) *Module {
 	return m
 }
 
+// Toggle configures the module to bring the VPN up or down on left click,
+// using the given Toggler.
+func (m *Module) Toggle(t Toggler) *Module {
+	m.toggler.Set(t)
+	return m
+}
+
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
 	outputFunc := m.outputFunc.Get().(func(State) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
 
+	toggler, _ := m.toggler.Get().(Toggler)
+	nextToggler, done := m.toggler.Subscribe()
+	defer done()
+
 	linkSub := netlink.ByName(m.intf)
 	defer linkSub.Unsubscribe()
 
 	state := getState(linkSub.Get().State)
 	for {
-		s.Output(outputFunc(state))
+		s.Output(outputs.Group(outputFunc(state)).
+			OnClick(toggleClickHandler(toggler, state)))
 		select {
 		case <-linkSub.C:
 			state = getState(linkSub.Get().State)
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(State) bar.Output)
+		case <-nextToggler:
+			toggler, _ = m.toggler.Get().(Toggler)
 		}
 	}
 }
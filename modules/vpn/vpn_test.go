@@ -15,12 +15,15 @@
 package vpn
 
 import (
+	"errors"
 	"testing"
 
 	"barista.run/bar"
 	"barista.run/base/watchers/netlink"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestVpn(t *testing.T) {
@@ -65,3 +68,92 @@ func TestVpn(t *testing.T) {
 	nlt.RemoveLink(link)
 	testBar.NextOutput().AssertText([]string{"NO VPN"})
 }
+
+type testToggler struct {
+	calls chan string
+	err   error
+}
+
+func (t testToggler) Up() error {
+	t.calls <- "up"
+	return t.err
+}
+
+func (t testToggler) Down() error {
+	t.calls <- "down"
+	return t.err
+}
+
+func TestDefaultToggleOutput(t *testing.T) {
+	nlt := netlink.TestMode()
+	link := nlt.AddLink(netlink.Link{Name: "tun0", State: netlink.Down})
+
+	testBar.New(t)
+	v := DefaultInterface()
+	testBar.Run(v)
+	testBar.NextOutput("on start").AssertEmpty()
+
+	toggler := testToggler{calls: make(chan string, 1)}
+	v.Toggle(toggler)
+	out := testBar.NextOutput("on toggler change")
+	out.AssertText([]string{"VPN off"})
+	out.At(0).LeftClick()
+	require.Equal(t, "up", <-toggler.calls)
+
+	nlt.UpdateLink(link, netlink.Link{Name: "tun0", State: netlink.Up})
+	out = testBar.NextOutput("on connect")
+	out.AssertText([]string{"VPN"})
+	out.At(0).LeftClick()
+	require.Equal(t, "down", <-toggler.calls)
+}
+
+func TestToggle(t *testing.T) {
+	nlt := netlink.TestMode()
+	link := nlt.AddLink(netlink.Link{Name: "tun0", State: netlink.Down})
+
+	testBar.New(t)
+	v := New("tun0").Output(func(s State) bar.Output {
+		return outputs.Textf("%v", s)
+	})
+	testBar.Run(v)
+	out := testBar.NextOutput("on start")
+	out.At(0).LeftClick()
+	testBar.AssertNoOutput("click without toggler")
+
+	toggler := testToggler{calls: make(chan string, 1)}
+	v.Toggle(toggler)
+	out = testBar.NextOutput("on toggler change")
+	out.At(0).LeftClick()
+	require.Equal(t, "up", <-toggler.calls)
+
+	nlt.UpdateLink(link, netlink.Link{Name: "tun0", State: netlink.Up})
+	out = testBar.NextOutput("on connect")
+	out.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	out.At(0).LeftClick()
+	require.Equal(t, "down", <-toggler.calls)
+
+	toggler.err = errors.New("foo")
+	v.Toggle(toggler)
+	out = testBar.NextOutput("on toggler change")
+	out.At(0).LeftClick()
+	require.Equal(t, "down", <-toggler.calls, "errors are only logged")
+}
+
+func TestCommands(t *testing.T) {
+	var ran [][]string
+	runCommand = func(name string, args ...string) error {
+		ran = append(ran, append([]string{name}, args...))
+		return nil
+	}
+	c := Commands([]string{"wg-quick", "up", "wg0"}, nil)
+	require.NoError(t, c.Up())
+	require.NoError(t, c.Down())
+	nm := NetworkManager("work")
+	require.NoError(t, nm.Up())
+	require.NoError(t, nm.Down())
+	require.Equal(t, [][]string{
+		{"wg-quick", "up", "wg0"},
+		{"nmcli", "connection", "up", "id", "work"},
+		{"nmcli", "connection", "down", "id", "work"},
+	}, ran)
+}
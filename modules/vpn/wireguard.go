@@ -0,0 +1,216 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vpn
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/value"
+	"barista.run/base/watchers/netlink"
+	l "barista.run/logging"
+	"barista.run/outputs"
+	"barista.run/timing"
+
+	"github.com/martinlindhe/unit"
+)
+
+// Peer represents a single WireGuard peer.
+type Peer struct {
+	PublicKey  string
+	Endpoint   string
+	AllowedIPs []string
+	// LatestHandshake is zero if no handshake has taken place.
+	LatestHandshake time.Time
+	Rx, Tx          unit.Datasize
+}
+
+// HandshakeAge returns the time elapsed since the latest handshake with
+// the peer, or 0 if there has been no handshake.
+func (p Peer) HandshakeAge() time.Duration {
+	if p.LatestHandshake.IsZero() {
+		return 0
+	}
+	return timing.Now().Sub(p.LatestHandshake)
+}
+
+// WireGuardInfo represents the state of a WireGuard interface and its peers.
+type WireGuardInfo struct {
+	State
+	Peers []Peer
+}
+
+// LatestHandshake returns the most recent handshake time across all peers.
+func (i WireGuardInfo) LatestHandshake() time.Time {
+	var latest time.Time
+	for _, p := range i.Peers {
+		if p.LatestHandshake.After(latest) {
+			latest = p.LatestHandshake
+		}
+	}
+	return latest
+}
+
+// WireGuardModule represents a bar module that shows the status of a
+// WireGuard interface, including its peers' endpoints and handshake times.
+type WireGuardModule struct {
+	intf       string
+	scheduler  *timing.Scheduler
+	outputFunc value.Value // of func(WireGuardInfo) bar.Output
+	toggler    value.Value // of Toggler
+}
+
+// WireGuard constructs a module for the given WireGuard interface. Link state
+// is tracked using netlink, while peer information is read from `wg show`.
+// Reading peers requires CAP_NET_ADMIN, both using `wg` and WireGuard's own
+// netlink interface, so without it only the link state is shown, and Peers
+// is always empty.
+func WireGuard(iface string) *WireGuardModule {
+	m := &WireGuardModule{intf: iface, scheduler: timing.NewScheduler()}
+	l.Label(m, iface)
+	l.Register(m, "scheduler", "outputFunc", "toggler")
+	m.RefreshInterval(10 * time.Second)
+	// Default output is the interface name when connected, and if a toggler
+	// is set, a segment to click on to connect when disconnected.
+	m.Output(func(i WireGuardInfo) bar.Output {
+		if i.Connected() {
+			return outputs.Text(iface)
+		}
+		if _, ok := m.toggler.Get().(Toggler); ok && i.Disconnected() {
+			return outputs.Textf("%s off", iface)
+		}
+		return nil
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *WireGuardModule) Output(outputFunc func(WireGuardInfo) bar.Output) *WireGuardModule {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for peer information.
+// Link state changes are always reflected immediately.
+func (m *WireGuardModule) RefreshInterval(interval time.Duration) *WireGuardModule {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Toggle configures the module to bring the interface up or down on left
+// click, using the given Toggler.
+func (m *WireGuardModule) Toggle(t Toggler) *WireGuardModule {
+	m.toggler.Set(t)
+	return m
+}
+
+// Stream starts the module.
+func (m *WireGuardModule) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get().(func(WireGuardInfo) bar.Output)
+	nextOutputFunc, done := m.outputFunc.Subscribe()
+	defer done()
+
+	toggler, _ := m.toggler.Get().(Toggler)
+	nextToggler, done := m.toggler.Subscribe()
+	defer done()
+
+	linkSub := netlink.ByName(m.intf)
+	defer linkSub.Unsubscribe()
+
+	var info WireGuardInfo
+	var lastErr string
+	update := func() {
+		info.State = getState(linkSub.Get().State)
+		info.Peers = nil
+		if info.Disconnected() {
+			return
+		}
+		peers, err := wgPeers(m.intf)
+		if err == nil {
+			info.Peers, lastErr = peers, ""
+			return
+		}
+		// Peers are usually unavailable due to missing permissions, which
+		// will not change, so keep showing the link state and only log
+		// each distinct error once.
+		if err.Error() != lastErr {
+			lastErr = err.Error()
+			l.Log("%s: failed to read peers: %v", l.ID(m), err)
+		}
+	}
+	update()
+	for {
+		s.Output(outputs.Group(outputFunc(info)).
+			OnClick(toggleClickHandler(toggler, info.State)))
+		select {
+		case <-linkSub.C:
+			update()
+		case <-m.scheduler.C:
+			update()
+		case <-nextOutputFunc:
+			outputFunc = m.outputFunc.Get().(func(WireGuardInfo) bar.Output)
+		case <-nextToggler:
+			toggler, _ = m.toggler.Get().(Toggler)
+		}
+	}
+}
+
+// For tests.
+var wgDump = func(iface string) ([]byte, error) {
+	return exec.Command("wg", "show", iface, "dump").Output()
+}
+
+// wgPeers parses the output of `wg show <iface> dump`. The first line
+// describes the interface, and each subsequent line describes a peer:
+// public-key, preshared-key, endpoint, allowed-ips, latest-handshake,
+// transfer-rx, transfer-tx, persistent-keepalive.
+func wgPeers(iface string) ([]Peer, error) {
+	out, err := wgDump(iface)
+	if err != nil {
+		return nil, err
+	}
+	var peers []Peer
+	s := bufio.NewScanner(bytes.NewReader(out))
+	// Skip the interface line.
+	s.Scan()
+	for s.Scan() {
+		fields := strings.Split(s.Text(), "\t")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("unexpected wg dump line: %q", s.Text())
+		}
+		p := Peer{PublicKey: fields[0]}
+		if fields[2] != "(none)" {
+			p.Endpoint = fields[2]
+		}
+		if fields[3] != "(none)" {
+			p.AllowedIPs = strings.Split(fields[3], ",")
+		}
+		if hs, _ := strconv.ParseInt(fields[4], 10, 64); hs > 0 {
+			p.LatestHandshake = time.Unix(hs, 0)
+		}
+		rx, _ := strconv.ParseUint(fields[5], 10, 64)
+		tx, _ := strconv.ParseUint(fields[6], 10, 64)
+		p.Rx = unit.Datasize(rx) * unit.Byte
+		p.Tx = unit.Datasize(tx) * unit.Byte
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
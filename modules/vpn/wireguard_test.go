@@ -0,0 +1,133 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vpn
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/base/watchers/netlink"
+	"barista.run/outputs"
+	testBar "barista.run/testing/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var dumpMu sync.Mutex
+var dumpOut string
+var dumpErr error
+
+func setDump(out string, err error) {
+	dumpMu.Lock()
+	defer dumpMu.Unlock()
+	dumpOut, dumpErr = out, err
+}
+
+func init() {
+	wgDump = func(string) ([]byte, error) {
+		dumpMu.Lock()
+		defer dumpMu.Unlock()
+		return []byte(dumpOut), dumpErr
+	}
+}
+
+const ifaceLine = "privkey\tpubkey\t51820\toff\n"
+
+func TestWireGuard(t *testing.T) {
+	nlt := netlink.TestMode()
+	link := nlt.AddLink(netlink.Link{Name: "wg0", State: netlink.Down})
+	testBar.New(t)
+	setDump(ifaceLine, nil)
+
+	wg := WireGuard("wg0").RefreshInterval(time.Minute)
+	testBar.Run(wg)
+	testBar.NextOutput("disconnected").AssertEmpty()
+
+	hs := timing.Now().Add(-30 * time.Second)
+	setDump(ifaceLine+strings.Join([]string{
+		"peerkey", "(none)", "203.0.113.1:51820", "10.0.0.0/24,10.1.0.0/24",
+		strconv.FormatInt(hs.Unix(), 10), "2048", "1024", "25",
+	}, "\t")+"\n", nil)
+
+	wg.Output(func(i WireGuardInfo) bar.Output {
+		if !i.Connected() {
+			return outputs.Text("down")
+		}
+		p := i.Peers[0]
+		return outputs.Textf("%s %v %v %v/%v", p.Endpoint, p.AllowedIPs,
+			p.HandshakeAge(), p.Rx.Bytes(), p.Tx.Bytes())
+	})
+	testBar.NextOutput("output func change").AssertText([]string{"down"})
+
+	nlt.UpdateLink(link, netlink.Link{Name: "wg0", State: netlink.Up})
+	testBar.NextOutput("link up").AssertText([]string{
+		"203.0.113.1:51820 [10.0.0.0/24 10.1.0.0/24] 30s 2048/1024"})
+
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{
+		"203.0.113.1:51820 [10.0.0.0/24 10.1.0.0/24] 1m30s 2048/1024"})
+
+	wg.Output(func(i WireGuardInfo) bar.Output {
+		return outputs.Textf("%v %d", i.State, len(i.Peers))
+	})
+	testBar.NextOutput("output func change").AssertText([]string{"2 1"})
+
+	setDump("", errors.New("permission denied"))
+	testBar.Tick()
+	testBar.NextOutput("on error").AssertText([]string{"2 0"},
+		"shows link state without peers")
+	testBar.Tick()
+	testBar.NextOutput("on refresh").AssertText([]string{"2 0"})
+
+	nlt.UpdateLink(link, netlink.Link{Name: "wg0", State: netlink.Down})
+	testBar.NextOutput("link down").AssertText([]string{"0 0"})
+}
+
+func TestWireGuardToggle(t *testing.T) {
+	nlt := netlink.TestMode()
+	nlt.AddLink(netlink.Link{Name: "wg0", State: netlink.Down})
+	testBar.New(t)
+	setDump(ifaceLine, nil)
+
+	wg := WireGuard("wg0")
+	testBar.Run(wg)
+	testBar.NextOutput("disconnected").AssertEmpty()
+
+	toggler := testToggler{calls: make(chan string, 1)}
+	wg.Toggle(toggler)
+	out := testBar.NextOutput("on toggler change")
+	out.AssertText([]string{"wg0 off"})
+	out.At(0).LeftClick()
+	require.Equal(t, "up", <-toggler.calls)
+}
+
+func TestWgPeers(t *testing.T) {
+	setDump(ifaceLine+"a\t(none)\t(none)\t(none)\t0\t0\t0\toff\n", nil)
+	peers, err := wgPeers("wg0")
+	require.NoError(t, err)
+	require.Equal(t, []Peer{{PublicKey: "a"}}, peers)
+	require.Equal(t, time.Duration(0), peers[0].HandshakeAge())
+	require.True(t, WireGuardInfo{Peers: peers}.LatestHandshake().IsZero())
+
+	setDump(ifaceLine+"a\tb\n", nil)
+	_, err = wgPeers("wg0")
+	require.Error(t, err, "malformed peer line")
+}
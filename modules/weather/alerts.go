@@ -0,0 +1,130 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weather
+
+import (
+	"strings"
+	"time"
+
+	l "barista.run/logging"
+	"barista.run/timing"
+)
+
+// Severity represents the severity of a weather alert, using the levels of
+// the Common Alerting Protocol.
+type Severity int
+
+// Possible alert severities, from least to most severe.
+const (
+	SeverityUnknown Severity = iota
+	Minor
+	Moderate
+	Severe
+	Extreme
+)
+
+// ParseSeverity parses a Common Alerting Protocol severity (e.g. "Severe"),
+// as used by most national weather services.
+func ParseSeverity(severity string) Severity {
+	switch strings.ToLower(severity) {
+	case "minor":
+		return Minor
+	case "moderate":
+		return Moderate
+	case "severe":
+		return Severe
+	case "extreme":
+		return Extreme
+	}
+	return SeverityUnknown
+}
+
+// Alert represents a weather alert, such as a storm warning.
+type Alert struct {
+	// Event is the type of the alert, e.g. "Severe Thunderstorm Warning".
+	Event       string
+	Headline    string
+	Description string
+	Severity    Severity
+	// Start and End are the times during which the alert is in effect. Either
+	// may be zero if not known.
+	Start time.Time
+	End   time.Time
+	// Sender is the name of the agency that issued the alert.
+	Sender string
+}
+
+// Active returns true if the alert is currently in effect.
+func (a Alert) Active() bool {
+	now := timing.Now()
+	return !now.Before(a.Start) && (a.End.IsZero() || now.Before(a.End))
+}
+
+// ActiveAlerts returns the alerts that are currently in effect.
+func (w Weather) ActiveAlerts() []Alert {
+	var active []Alert
+	for _, a := range w.Alerts {
+		if a.Active() {
+			active = append(active, a)
+		}
+	}
+	return active
+}
+
+// AlertSeverity returns the highest severity of all active alerts, or
+// SeverityUnknown if there are none.
+func (w Weather) AlertSeverity() Severity {
+	s := SeverityUnknown
+	for _, a := range w.ActiveAlerts() {
+		if a.Severity > s {
+			s = a.Severity
+		}
+	}
+	return s
+}
+
+// AlertProvider is an interface for sources of weather alerts, which can be
+// combined with any Provider using WithAlerts.
+type AlertProvider interface {
+	GetAlerts() ([]Alert, error)
+}
+
+type withAlerts struct {
+	Provider
+	alerts []AlertProvider
+}
+
+// WithAlerts returns a provider that adds alerts from the given alert
+// providers to the weather from an existing provider. Alert providers that
+// fail are logged and skipped, so that the weather is still shown.
+func WithAlerts(provider Provider, alerts ...AlertProvider) Provider {
+	return withAlerts{provider, alerts}
+}
+
+func (w withAlerts) GetWeather() (Weather, error) {
+	weather, err := w.Provider.GetWeather()
+	if err != nil {
+		return weather, err
+	}
+	for _, a := range w.alerts {
+		alerts, err := a.GetAlerts()
+		if err != nil {
+			l.Log("Failed to get weather alerts from %s: %v", l.ID(a), err)
+			continue
+		}
+		weather.Alerts = append(weather.Alerts, alerts...)
+	}
+	return weather, nil
+}
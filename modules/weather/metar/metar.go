@@ -23,12 +23,12 @@ import (
 	"encoding/xml"
 	"fmt"
 	"math"
-	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
 	"time"
 
+	"barista.run/base/httpclient"
 	"barista.run/modules/weather"
 
 	"github.com/martinlindhe/unit"
@@ -294,9 +294,13 @@ func (m metar) getCondition() weather.Condition {
 	return weather.Clear
 }
 
+// ADDS observations are updated hourly, so the last good response is still
+// useful while offline.
+var client = httpclient.NewTransport(nil).ServeStale(true).Client()
+
 // GetWeather gets weather information from NOAA ADDS.
 func (p *provider) GetWeather() (weather.Weather, error) {
-	response, err := http.Get(p.url)
+	response, err := client.Get(p.url)
 	if err != nil {
 		return weather.Weather{}, err
 	}
@@ -0,0 +1,221 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package metno provides weather using the Locationforecast API from the
+Norwegian Meteorological Institute (MET Norway), available at
+https://api.met.no. The API is free and does not require an API key, but the
+terms of service require an identifying User-Agent, and ask that clients do
+not poll more often than necessary, so a refresh interval of at least 30
+minutes is recommended.
+*/
+package metno // import "barista.run/modules/weather/metno"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"barista.run/base/httpclient"
+	"barista.run/modules/weather"
+
+	"github.com/martinlindhe/unit"
+)
+
+// Provider wraps a MET Norway API url and User-Agent so that it can be used
+// as a weather.Provider.
+type Provider struct {
+	url       string
+	userAgent string
+}
+
+// Coords creates a provider for the given lat/lon co-ordinates. The provider
+// includes an hourly forecast for the next two to three days.
+func Coords(lat, lon float64) Provider {
+	qp := url.Values{}
+	// The API rejects co-ordinates with more than 4 decimals.
+	qp.Add("lat", fmt.Sprintf("%.4f", lat))
+	qp.Add("lon", fmt.Sprintf("%.4f", lon))
+	u := url.URL{
+		Scheme:   "https",
+		Host:     "api.met.no",
+		Path:     "/weatherapi/locationforecast/2.0/compact",
+		RawQuery: qp.Encode(),
+	}
+	return Provider{url: u.String(), userAgent: "barista (https://barista.run)"}
+}
+
+// UserAgent sets the User-Agent sent with requests. MET Norway recommends
+// including contact information, e.g. "mybar github.com/user/dotfiles".
+func (p Provider) UserAgent(userAgent string) Provider {
+	p.userAgent = userAgent
+	return p
+}
+
+type details struct {
+	Pressure      *float64 `json:"air_pressure_at_sea_level"`
+	Temperature   *float64 `json:"air_temperature"`
+	CloudCover    *float64 `json:"cloud_area_fraction"`
+	Humidity      *float64 `json:"relative_humidity"`
+	WindDirection *float64 `json:"wind_from_direction"`
+	WindSpeed     *float64 `json:"wind_speed"`
+	Precipitation *float64 `json:"precipitation_amount"`
+	// Only available for some locations.
+	PrecipitationChance *float64 `json:"probability_of_precipitation"`
+}
+
+// metnoWeather represents a MET Norway locationforecast json response.
+type metnoWeather struct {
+	Properties struct {
+		Meta struct {
+			UpdatedAt time.Time `json:"updated_at"`
+		}
+		Timeseries []struct {
+			Time time.Time
+			Data struct {
+				Instant struct {
+					Details details
+				}
+				NextHour *struct {
+					Summary struct {
+						SymbolCode string `json:"symbol_code"`
+					}
+					Details details
+				} `json:"next_1_hours"`
+			}
+		}
+	}
+}
+
+func value(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+// getCondition converts a symbol code (e.g. "lightrainshowers_day") into a
+// condition and description.
+func getCondition(symbol string) (weather.Condition, string) {
+	symbol = strings.SplitN(symbol, "_", 2)[0]
+	cond := weather.ConditionUnknown
+	switch {
+	case symbol == "":
+		return cond, ""
+	case strings.Contains(symbol, "thunder"):
+		cond = weather.Thunderstorm
+	case strings.Contains(symbol, "sleet"):
+		cond = weather.Sleet
+	case strings.Contains(symbol, "snow"):
+		cond = weather.Snow
+	case strings.Contains(symbol, "rain"):
+		cond = weather.Rain
+	case symbol == "fog":
+		cond = weather.Fog
+	case symbol == "clearsky":
+		cond = weather.Clear
+	case symbol == "fair", symbol == "partlycloudy":
+		cond = weather.PartlyCloudy
+	case symbol == "cloudy":
+		cond = weather.Cloudy
+	}
+	desc := descReplacer.Replace(symbol)
+	return cond, strings.TrimSpace(desc)
+}
+
+var descReplacer = strings.NewReplacer(
+	// Some symbol codes are misspelled in the API.
+	"lightssleet", "light sleet",
+	"lightssnow", "light snow",
+	"clearsky", "clear sky",
+	"partlycloudy", "partly cloudy",
+	"light", "light ",
+	"heavy", "heavy ",
+	"showers", " showers",
+	"andthunder", " and thunder",
+)
+
+// MET Norway requires clients to honour Expires and Last-Modified, which the
+// shared client does, and the last good response is used while offline.
+var client = httpclient.NewTransport(nil).ServeStale(true).Client()
+
+// GetWeather gets weather information from MET Norway.
+func (p Provider) GetWeather() (weather.Weather, error) {
+	req, err := http.NewRequest("GET", p.url, nil)
+	if err != nil {
+		return weather.Weather{}, err
+	}
+	req.Header.Set("User-Agent", p.userAgent)
+	response, err := client.Do(req)
+	if err != nil {
+		return weather.Weather{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return weather.Weather{}, fmt.Errorf("MET Norway: HTTP %s", response.Status)
+	}
+	m := metnoWeather{}
+	err = json.NewDecoder(response.Body).Decode(&m)
+	if err != nil {
+		return weather.Weather{}, err
+	}
+	series := m.Properties.Timeseries
+	if len(series) == 0 || series[0].Data.Instant.Details.Temperature == nil {
+		return weather.Weather{}, fmt.Errorf("Bad response from MET Norway")
+	}
+	now := series[0].Data
+	w := weather.Weather{
+		Temperature: unit.FromCelsius(value(now.Instant.Details.Temperature)),
+		Humidity:    value(now.Instant.Details.Humidity) / 100.0,
+		Pressure:    unit.Pressure(value(now.Instant.Details.Pressure)) * unit.Millibar,
+		CloudCover:  value(now.Instant.Details.CloudCover) / 100.0,
+		Wind: weather.Wind{
+			Speed:     unit.Speed(value(now.Instant.Details.WindSpeed)) * unit.MetersPerSecond,
+			Direction: weather.Direction(int(value(now.Instant.Details.WindDirection))),
+		},
+		Updated:     m.Properties.Meta.UpdatedAt,
+		Attribution: "MET Norway",
+	}
+	if now.NextHour != nil {
+		w.Condition, w.Description = getCondition(now.NextHour.Summary.SymbolCode)
+	}
+	// The timeseries is hourly for the first few days, and every 6 hours
+	// after that. Only the hourly entries are included in the forecast.
+	for _, s := range series {
+		if s.Data.NextHour == nil {
+			break
+		}
+		inst := s.Data.Instant.Details
+		f := weather.Forecast{
+			Time:          s.Time,
+			Temperature:   unit.FromCelsius(value(inst.Temperature)),
+			Precipitation: unit.Length(value(s.Data.NextHour.Details.Precipitation)) * unit.Millimeter,
+			CloudCover:    value(inst.CloudCover) / 100.0,
+			Wind: weather.Wind{
+				Speed:     unit.Speed(value(inst.WindSpeed)) * unit.MetersPerSecond,
+				Direction: weather.Direction(int(value(inst.WindDirection))),
+			},
+			PrecipitationChance: -1,
+		}
+		if c := s.Data.NextHour.Details.PrecipitationChance; c != nil {
+			f.PrecipitationChance = *c / 100.0
+		}
+		f.Condition, f.Description = getCondition(s.Data.NextHour.Summary.SymbolCode)
+		w.Hourly = append(w.Hourly, f)
+	}
+	return w, nil
+}
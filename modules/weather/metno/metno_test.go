@@ -0,0 +1,146 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metno
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/base/httpclient"
+	"barista.run/modules/weather"
+	testServer "barista.run/testing/httpserver"
+
+	"github.com/martinlindhe/unit"
+	"github.com/stretchr/testify/require"
+)
+
+var ts *httptest.Server
+
+func TestMain(m *testing.M) {
+	httpclient.TestMode()
+	ts = testServer.New()
+	defer ts.Close()
+	os.Exit(m.Run())
+}
+
+func TestGood(t *testing.T) {
+	wthr, err := Provider{url: ts.URL + "/static/good.json"}.GetWeather()
+	require.NoError(t, err)
+	require.Equal(t, weather.Weather{
+		Condition:   weather.PartlyCloudy,
+		Description: "partly cloudy",
+		Temperature: unit.FromCelsius(-1.5),
+		Humidity:    0.912,
+		Pressure:    1012.3 * unit.Millibar,
+		CloudCover:  0.75,
+		Wind: weather.Wind{
+			Speed:     2.1 * unit.MetersPerSecond,
+			Direction: weather.Direction(350),
+		},
+		Updated:     time.Date(2016, time.November, 25, 20, 12, 55, 0, time.UTC),
+		Attribution: "MET Norway",
+		Hourly: []weather.Forecast{
+			{
+				Time:                time.Date(2016, time.November, 25, 20, 0, 0, 0, time.UTC),
+				Condition:           weather.PartlyCloudy,
+				Description:         "partly cloudy",
+				Temperature:         unit.FromCelsius(-1.5),
+				PrecipitationChance: -1,
+				CloudCover:          0.75,
+				Wind: weather.Wind{
+					Speed:     2.1 * unit.MetersPerSecond,
+					Direction: weather.Direction(350),
+				},
+			},
+			{
+				Time:                time.Date(2016, time.November, 25, 21, 0, 0, 0, time.UTC),
+				Condition:           weather.Thunderstorm,
+				Description:         "light snow showers and thunder",
+				Temperature:         unit.FromCelsius(-1.9),
+				Precipitation:       0.3 * unit.Millimeter,
+				PrecipitationChance: 0.4,
+				CloudCover:          1.0,
+				Wind: weather.Wind{
+					Speed:     2.4 * unit.MetersPerSecond,
+					Direction: weather.Direction(10),
+				},
+			},
+		},
+	}, wthr)
+}
+
+func TestUserAgent(t *testing.T) {
+	var ua string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ua = r.Header.Get("User-Agent")
+		http.ServeFile(w, r, "testdata/good.json")
+	}))
+	defer srv.Close()
+
+	p := Coords(59.913868, 10.752245)
+	require.True(t, strings.HasSuffix(p.url, "compact?lat=59.9139&lon=10.7522"), p.url)
+
+	p.url = srv.URL
+	_, err := p.GetWeather()
+	require.NoError(t, err)
+	require.Equal(t, "barista (https://barista.run)", ua)
+
+	_, err = p.UserAgent("mybar test@example.com").GetWeather()
+	require.NoError(t, err)
+	require.Equal(t, "mybar test@example.com", ua)
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Provider{url: ts.URL + "/static/bad.json"}.GetWeather()
+	require.Error(t, err, "bad json")
+
+	_, err = Provider{url: ts.URL + "/code/403"}.GetWeather()
+	require.Error(t, err, "http error")
+
+	_, err = Provider{url: ts.URL + "/static/empty.json"}.GetWeather()
+	require.Error(t, err, "valid json but bad response")
+
+	_, err = Provider{url: ts.URL + "/redir"}.GetWeather()
+	require.Error(t, err, "http error")
+}
+
+func TestConditions(t *testing.T) {
+	for _, tc := range []struct {
+		symbol      string
+		condition   weather.Condition
+		description string
+	}{
+		{"clearsky_day", weather.Clear, "clear sky"},
+		{"fair_polartwilight", weather.PartlyCloudy, "fair"},
+		{"cloudy", weather.Cloudy, "cloudy"},
+		{"fog", weather.Fog, "fog"},
+		{"heavyrain", weather.Rain, "heavy rain"},
+		{"rainshowers_night", weather.Rain, "rain showers"},
+		{"lightsleet", weather.Sleet, "light sleet"},
+		{"lightssleetshowersandthunder_day", weather.Thunderstorm,
+			"light sleet showers and thunder"},
+		{"heavysnowshowers_day", weather.Snow, "heavy snow showers"},
+		{"unknownsymbol", weather.ConditionUnknown, "unknownsymbol"},
+		{"", weather.ConditionUnknown, ""},
+	} {
+		cond, desc := getCondition(tc.symbol)
+		require.Equal(t, tc.condition, cond, tc.symbol)
+		require.Equal(t, tc.description, desc, tc.symbol)
+	}
+}
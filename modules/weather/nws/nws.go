@@ -0,0 +1,132 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package nws provides severe weather alerts for the United States from the
+National Weather Service API, available at https://www.weather.gov/documentation/services-web-api.
+
+Alerts can be added to any weather provider using weather.WithAlerts:
+
+	weather.New(weather.WithAlerts(
+		openmeteo.Coords(47.6, -122.3),
+		nws.Alerts(47.6, -122.3),
+	))
+*/
+package nws // import "barista.run/modules/weather/nws"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"barista.run/base/httpclient"
+	"barista.run/modules/weather"
+)
+
+// Provider wraps an NWS alerts API url so that it can be used as a
+// weather.AlertProvider.
+type Provider struct {
+	url       string
+	userAgent string
+}
+
+// Alerts creates an alert provider for active alerts at the given lat/lon
+// co-ordinates.
+func Alerts(lat, lon float64) Provider {
+	qp := url.Values{}
+	qp.Add("point", fmt.Sprintf("%.4f,%.4f", lat, lon))
+	u := url.URL{
+		Scheme:   "https",
+		Host:     "api.weather.gov",
+		Path:     "/alerts/active",
+		RawQuery: qp.Encode(),
+	}
+	return Provider{url: u.String(), userAgent: "barista (https://barista.run)"}
+}
+
+// UserAgent sets the User-Agent sent with requests. The NWS requires a
+// User-Agent that identifies the application, and recommends including
+// contact information.
+func (p Provider) UserAgent(userAgent string) Provider {
+	p.userAgent = userAgent
+	return p
+}
+
+// nwsAlerts represents an NWS alerts GeoJSON response.
+type nwsAlerts struct {
+	Features []struct {
+		Properties struct {
+			Event       string
+			Headline    string
+			Description string
+			Severity    string
+			Onset       *time.Time
+			Effective   *time.Time
+			Ends        *time.Time
+			Expires     *time.Time
+			SenderName  string
+		}
+	}
+}
+
+// firstTime returns the first non-nil time, or the zero time.
+func firstTime(times ...*time.Time) time.Time {
+	for _, t := range times {
+		if t != nil {
+			return *t
+		}
+	}
+	return time.Time{}
+}
+
+var client = httpclient.New()
+
+// GetAlerts gets the active alerts from the NWS.
+func (p Provider) GetAlerts() ([]weather.Alert, error) {
+	req, err := http.NewRequest("GET", p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", p.userAgent)
+	req.Header.Set("Accept", "application/geo+json")
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("NWS: HTTP %s", response.Status)
+	}
+	n := nwsAlerts{}
+	err = json.NewDecoder(response.Body).Decode(&n)
+	if err != nil {
+		return nil, err
+	}
+	var alerts []weather.Alert
+	for _, f := range n.Features {
+		a := f.Properties
+		alerts = append(alerts, weather.Alert{
+			Event:       a.Event,
+			Headline:    a.Headline,
+			Description: a.Description,
+			Severity:    weather.ParseSeverity(a.Severity),
+			Start:       firstTime(a.Onset, a.Effective),
+			End:         firstTime(a.Ends, a.Expires),
+			Sender:      a.SenderName,
+		})
+	}
+	return alerts, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"barista.run/base/httpclient"
+	"barista.run/modules/weather"
+	testServer "barista.run/testing/httpserver"
+
+	"github.com/stretchr/testify/require"
+)
+
+var ts *httptest.Server
+
+func TestMain(m *testing.M) {
+	httpclient.TestMode()
+	ts = testServer.New()
+	defer ts.Close()
+	os.Exit(m.Run())
+}
+
+func TestAlerts(t *testing.T) {
+	alerts, err := Provider{url: ts.URL + "/static/alerts.json"}.GetAlerts()
+	require.NoError(t, err)
+	cst := time.FixedZone("", -6*60*60)
+	require.Len(t, alerts, 2)
+	require.Equal(t, weather.Alert{
+		Event:       "Severe Thunderstorm Warning",
+		Headline:    "Severe Thunderstorm Warning issued November 25 at 2:10PM CST until November 25 at 3:00PM CST by NWS Springfield MO",
+		Description: "At 210 PM CST, a severe thunderstorm was located near Springfield.",
+		Severity:    weather.Severe,
+		Start:       time.Date(2016, time.November, 25, 14, 10, 0, 0, cst),
+		End:         time.Date(2016, time.November, 25, 15, 0, 0, 0, cst),
+		Sender:      "NWS Springfield MO",
+	}, normalise(alerts[0], cst))
+	a := normalise(alerts[1], cst)
+	require.Equal(t, weather.Moderate, a.Severity)
+	require.Equal(t, time.Date(2016, time.November, 25, 12, 0, 0, 0, cst), a.Start,
+		"falls back to effective time without onset")
+	require.Equal(t, time.Date(2016, time.November, 26, 6, 0, 0, 0, cst), a.End,
+		"falls back to expiry time without end")
+
+	alerts, err = Provider{url: ts.URL + "/static/none.json"}.GetAlerts()
+	require.NoError(t, err)
+	require.Empty(t, alerts)
+}
+
+// normalise converts the alert times to the given location, for comparison.
+func normalise(a weather.Alert, loc *time.Location) weather.Alert {
+	a.Start = a.Start.In(loc)
+	a.End = a.End.In(loc)
+	return a
+}
+
+func TestRequest(t *testing.T) {
+	var req *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		http.ServeFile(w, r, "testdata/none.json")
+	}))
+	defer srv.Close()
+
+	p := Alerts(37.208957, -93.292299)
+	require.Equal(t, "https://api.weather.gov/alerts/active?point=37.2090%2C-93.2923", p.url)
+
+	p.url = srv.URL
+	_, err := p.UserAgent("mybar test@example.com").GetAlerts()
+	require.NoError(t, err)
+	require.Equal(t, "mybar test@example.com", req.Header.Get("User-Agent"))
+	require.Equal(t, "application/geo+json", req.Header.Get("Accept"))
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Provider{url: ts.URL + "/static/bad.json"}.GetAlerts()
+	require.Error(t, err, "bad json")
+
+	_, err = Provider{url: ts.URL + "/code/500"}.GetAlerts()
+	require.Error(t, err, "http error")
+
+	_, err = Provider{url: ts.URL + "/redir"}.GetAlerts()
+	require.Error(t, err, "http error")
+}
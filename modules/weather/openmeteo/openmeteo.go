@@ -0,0 +1,246 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package openmeteo provides weather using the Open-Meteo API, available at
+https://open-meteo.com. The API is free for non-commercial use, and does not
+require an API key.
+*/
+package openmeteo // import "barista.run/modules/weather/openmeteo"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"barista.run/base/httpclient"
+	"barista.run/modules/weather"
+
+	"github.com/martinlindhe/unit"
+)
+
+// Provider wraps an Open-Meteo API url so that it can be used as a
+// weather.Provider.
+type Provider string
+
+const currentFields = "temperature_2m,relative_humidity_2m,weather_code," +
+	"cloud_cover,pressure_msl,wind_speed_10m,wind_direction_10m"
+
+const dailyFields = "weather_code,temperature_2m_max,temperature_2m_min," +
+	"precipitation_sum,precipitation_probability_max,sunrise,sunset"
+
+const hourlyFields = "temperature_2m,precipitation,precipitation_probability," +
+	"weather_code,cloud_cover,wind_speed_10m,wind_direction_10m"
+
+// Coords creates a provider for the given lat/lon co-ordinates. The provider
+// includes hourly and daily forecasts for the next seven days.
+func Coords(lat, lon float64) Provider {
+	qp := url.Values{}
+	qp.Add("latitude", fmt.Sprintf("%.6f", lat))
+	qp.Add("longitude", fmt.Sprintf("%.6f", lon))
+	qp.Add("current", currentFields)
+	qp.Add("hourly", hourlyFields)
+	qp.Add("daily", dailyFields)
+	qp.Add("timezone", "auto")
+	qp.Add("timeformat", "unixtime")
+	qp.Add("wind_speed_unit", "ms")
+	qp.Add("forecast_days", "7")
+	u := url.URL{
+		Scheme:   "https",
+		Host:     "api.open-meteo.com",
+		Path:     "/v1/forecast",
+		RawQuery: qp.Encode(),
+	}
+	return Provider(u.String())
+}
+
+// omWeather represents an Open-Meteo json response.
+type omWeather struct {
+	Error  bool
+	Reason string
+	// Current conditions.
+	Current struct {
+		Time          int64
+		Temperature   float64 `json:"temperature_2m"`
+		Humidity      float64 `json:"relative_humidity_2m"`
+		WeatherCode   int     `json:"weather_code"`
+		CloudCover    float64 `json:"cloud_cover"`
+		Pressure      float64 `json:"pressure_msl"`
+		WindSpeed     float64 `json:"wind_speed_10m"`
+		WindDirection float64 `json:"wind_direction_10m"`
+	}
+	// Hourly forecast, with one entry per hour in each slice.
+	Hourly struct {
+		Time                     []int64
+		Temperature              []float64 `json:"temperature_2m"`
+		Precipitation            []float64
+		PrecipitationProbability []*float64 `json:"precipitation_probability"`
+		WeatherCode              []int      `json:"weather_code"`
+		CloudCover               []float64  `json:"cloud_cover"`
+		WindSpeed                []float64  `json:"wind_speed_10m"`
+		WindDirection            []float64  `json:"wind_direction_10m"`
+	}
+	// Daily forecast, with one entry per day in each slice.
+	Daily struct {
+		Time                     []int64
+		WeatherCode              []int      `json:"weather_code"`
+		TemperatureMax           []float64  `json:"temperature_2m_max"`
+		TemperatureMin           []float64  `json:"temperature_2m_min"`
+		Precipitation            []float64  `json:"precipitation_sum"`
+		PrecipitationProbability []*float64 `json:"precipitation_probability_max"`
+		Sunrise                  []int64
+		Sunset                   []int64
+	}
+}
+
+// wmoCodes maps WMO weather interpretation codes to conditions and
+// descriptions.
+var wmoCodes = map[int]struct {
+	weather.Condition
+	string
+}{
+	0:  {weather.Clear, "clear sky"},
+	1:  {weather.PartlyCloudy, "mainly clear"},
+	2:  {weather.PartlyCloudy, "partly cloudy"},
+	3:  {weather.Overcast, "overcast"},
+	45: {weather.Fog, "fog"},
+	48: {weather.Fog, "depositing rime fog"},
+	51: {weather.Drizzle, "light drizzle"},
+	53: {weather.Drizzle, "drizzle"},
+	55: {weather.Drizzle, "dense drizzle"},
+	56: {weather.Drizzle, "light freezing drizzle"},
+	57: {weather.Drizzle, "dense freezing drizzle"},
+	61: {weather.Rain, "light rain"},
+	63: {weather.Rain, "rain"},
+	65: {weather.Rain, "heavy rain"},
+	66: {weather.Sleet, "light freezing rain"},
+	67: {weather.Sleet, "heavy freezing rain"},
+	71: {weather.Snow, "light snow"},
+	73: {weather.Snow, "snow"},
+	75: {weather.Snow, "heavy snow"},
+	77: {weather.Snow, "snow grains"},
+	80: {weather.Rain, "light rain showers"},
+	81: {weather.Rain, "rain showers"},
+	82: {weather.Rain, "violent rain showers"},
+	85: {weather.Snow, "light snow showers"},
+	86: {weather.Snow, "heavy snow showers"},
+	95: {weather.Thunderstorm, "thunderstorm"},
+	96: {weather.Thunderstorm, "thunderstorm with light hail"},
+	99: {weather.Thunderstorm, "thunderstorm with heavy hail"},
+}
+
+func getCondition(code int) (weather.Condition, string) {
+	c, ok := wmoCodes[code]
+	if !ok {
+		return weather.ConditionUnknown, ""
+	}
+	return c.Condition, c.string
+}
+
+func getWind(speed, dir float64) weather.Wind {
+	return weather.Wind{
+		Speed:     unit.Speed(speed) * unit.MetersPerSecond,
+		Direction: weather.Direction(int(dir)),
+	}
+}
+
+// Uses the last good forecast while offline.
+var client = httpclient.NewTransport(nil).ServeStale(true).Client()
+
+// GetWeather gets weather information from Open-Meteo.
+func (p Provider) GetWeather() (weather.Weather, error) {
+	response, err := client.Get(string(p))
+	if err != nil {
+		return weather.Weather{}, err
+	}
+	defer response.Body.Close()
+	o := omWeather{}
+	err = json.NewDecoder(response.Body).Decode(&o)
+	if err != nil {
+		return weather.Weather{}, err
+	}
+	if o.Error {
+		return weather.Weather{}, fmt.Errorf("Open-Meteo: %s", o.Reason)
+	}
+	if o.Current.Time == 0 {
+		return weather.Weather{}, fmt.Errorf("Bad response from Open-Meteo")
+	}
+	cond, desc := getCondition(o.Current.WeatherCode)
+	w := weather.Weather{
+		Condition:   cond,
+		Description: desc,
+		Temperature: unit.FromCelsius(o.Current.Temperature),
+		Humidity:    o.Current.Humidity / 100.0,
+		Pressure:    unit.Pressure(o.Current.Pressure) * unit.Millibar,
+		CloudCover:  o.Current.CloudCover / 100.0,
+		Wind:        getWind(o.Current.WindSpeed, o.Current.WindDirection),
+		Updated:     time.Unix(o.Current.Time, 0),
+		Attribution: "Open-Meteo",
+	}
+	d := o.Daily
+	for i, t := range d.Time {
+		if i >= len(d.WeatherCode) || i >= len(d.TemperatureMax) ||
+			i >= len(d.TemperatureMin) || i >= len(d.Precipitation) ||
+			i >= len(d.PrecipitationProbability) || i >= len(d.Sunrise) ||
+			i >= len(d.Sunset) {
+			return weather.Weather{}, fmt.Errorf("Bad daily forecast from Open-Meteo")
+		}
+		day := weather.Day{
+			Date:                time.Unix(t, 0),
+			Low:                 unit.FromCelsius(d.TemperatureMin[i]),
+			High:                unit.FromCelsius(d.TemperatureMax[i]),
+			Precipitation:       unit.Length(d.Precipitation[i]) * unit.Millimeter,
+			PrecipitationChance: -1,
+			Sunrise:             time.Unix(d.Sunrise[i], 0),
+			Sunset:              time.Unix(d.Sunset[i], 0),
+		}
+		day.Condition, day.Description = getCondition(d.WeatherCode[i])
+		if prob := d.PrecipitationProbability[i]; prob != nil {
+			day.PrecipitationChance = *prob / 100.0
+		}
+		w.Daily = append(w.Daily, day)
+	}
+	if len(w.Daily) > 0 {
+		w.Sunrise = w.Daily[0].Sunrise
+		w.Sunset = w.Daily[0].Sunset
+	}
+	h := o.Hourly
+	for i, t := range h.Time {
+		if t+3600 <= o.Current.Time {
+			// Skip hours that have already passed.
+			continue
+		}
+		if i >= len(h.Temperature) || i >= len(h.Precipitation) ||
+			i >= len(h.PrecipitationProbability) || i >= len(h.WeatherCode) ||
+			i >= len(h.CloudCover) || i >= len(h.WindSpeed) ||
+			i >= len(h.WindDirection) {
+			return weather.Weather{}, fmt.Errorf("Bad hourly forecast from Open-Meteo")
+		}
+		f := weather.Forecast{
+			Time:                time.Unix(t, 0),
+			Temperature:         unit.FromCelsius(h.Temperature[i]),
+			Precipitation:       unit.Length(h.Precipitation[i]) * unit.Millimeter,
+			PrecipitationChance: -1,
+			CloudCover:          h.CloudCover[i] / 100.0,
+			Wind:                getWind(h.WindSpeed[i], h.WindDirection[i]),
+		}
+		f.Condition, f.Description = getCondition(h.WeatherCode[i])
+		if prob := h.PrecipitationProbability[i]; prob != nil {
+			f.PrecipitationChance = *prob / 100.0
+		}
+		w.Hourly = append(w.Hourly, f)
+	}
+	return w, nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openmeteo
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"barista.run/base/httpclient"
+	"barista.run/modules/weather"
+	testServer "barista.run/testing/httpserver"
+
+	"github.com/martinlindhe/unit"
+	"github.com/stretchr/testify/require"
+)
+
+var ts *httptest.Server
+
+func TestMain(m *testing.M) {
+	httpclient.TestMode()
+	ts = testServer.New()
+	defer ts.Close()
+	os.Exit(m.Run())
+}
+
+func TestGood(t *testing.T) {
+	wthr, err := Provider(ts.URL + "/static/good.json").GetWeather()
+	require.NoError(t, err)
+	require.Equal(t, weather.Weather{
+		Condition:   weather.Rain,
+		Description: "light rain",
+		Temperature: unit.FromCelsius(4.3),
+		Humidity:    0.87,
+		Pressure:    1021.4 * unit.Millibar,
+		CloudCover:  1.0,
+		Wind: weather.Wind{
+			Speed:     3.2 * unit.MetersPerSecond,
+			Direction: weather.Direction(225),
+		},
+		Sunrise:     time.Unix(1480057080, 0),
+		Sunset:      time.Unix(1480087320, 0),
+		Updated:     time.Unix(1480106700, 0),
+		Attribution: "Open-Meteo",
+		Hourly: []weather.Forecast{
+			{
+				Time:                time.Unix(1480104000, 0),
+				Condition:           weather.Rain,
+				Description:         "light rain",
+				Temperature:         unit.FromCelsius(4.5),
+				Precipitation:       0.4 * unit.Millimeter,
+				PrecipitationChance: 0.6,
+				CloudCover:          1.0,
+				Wind: weather.Wind{
+					Speed:     3.2 * unit.MetersPerSecond,
+					Direction: weather.Direction(225),
+				},
+			},
+			{
+				Time:                time.Unix(1480107600, 0),
+				Condition:           weather.Overcast,
+				Description:         "overcast",
+				Temperature:         unit.FromCelsius(4.1),
+				PrecipitationChance: 0.2,
+				CloudCover:          0.9,
+				Wind: weather.Wind{
+					Speed:     3.5 * unit.MetersPerSecond,
+					Direction: weather.Direction(230),
+				},
+			},
+			{
+				Time:                time.Unix(1480111200, 0),
+				Condition:           weather.Rain,
+				Description:         "rain",
+				Temperature:         unit.FromCelsius(3.9),
+				Precipitation:       1.2 * unit.Millimeter,
+				PrecipitationChance: -1,
+				CloudCover:          1.0,
+				Wind: weather.Wind{
+					Speed:     4.0 * unit.MetersPerSecond,
+					Direction: weather.Direction(240),
+				},
+			},
+		},
+		Daily: []weather.Day{
+			{
+				Date:                time.Unix(1480028400, 0),
+				Condition:           weather.Rain,
+				Description:         "light rain",
+				Low:                 unit.FromCelsius(1.2),
+				High:                unit.FromCelsius(6.1),
+				Precipitation:       2.4 * unit.Millimeter,
+				PrecipitationChance: 0.8,
+				Sunrise:             time.Unix(1480057080, 0),
+				Sunset:              time.Unix(1480087320, 0),
+			},
+			{
+				Date:                time.Unix(1480114800, 0),
+				Condition:           weather.Snow,
+				Description:         "light snow",
+				Low:                 unit.FromCelsius(-3.4),
+				High:                unit.FromCelsius(2.0),
+				Precipitation:       5.0 * unit.Millimeter,
+				PrecipitationChance: -1,
+				Sunrise:             time.Unix(1480143600, 0),
+				Sunset:              time.Unix(1480173660, 0),
+			},
+		},
+	}, wthr)
+}
+
+func TestCoords(t *testing.T) {
+	u, err := url.Parse(string(Coords(52.52, 13.41)))
+	require.NoError(t, err)
+	require.Equal(t, "api.open-meteo.com", u.Host)
+	q := u.Query()
+	require.Equal(t, "52.520000", q.Get("latitude"))
+	require.Equal(t, "13.410000", q.Get("longitude"))
+	require.Equal(t, "unixtime", q.Get("timeformat"))
+	require.Equal(t, "ms", q.Get("wind_speed_unit"))
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Provider(ts.URL + "/static/bad.json").GetWeather()
+	require.Error(t, err, "bad json")
+
+	_, err = Provider(ts.URL + "/static/error.json").GetWeather()
+	require.Error(t, err, "api error")
+	require.Contains(t, err.Error(), "Latitude must be in range")
+
+	_, err = Provider(ts.URL + "/code/500").GetWeather()
+	require.Error(t, err, "http error")
+
+	_, err = Provider(ts.URL + "/static/empty.json").GetWeather()
+	require.Error(t, err, "valid json but bad response")
+
+	_, err = Provider(ts.URL + "/static/short.json").GetWeather()
+	require.Error(t, err, "mismatched hourly data")
+
+	_, err = Provider(ts.URL + "/static/shortdaily.json").GetWeather()
+	require.Error(t, err, "mismatched daily data")
+
+	_, err = Provider(ts.URL + "/redir").GetWeather()
+	require.Error(t, err, "http error")
+}
+
+func TestConditions(t *testing.T) {
+	for code, expected := range map[int]weather.Condition{
+		0:  weather.Clear,
+		2:  weather.PartlyCloudy,
+		45: weather.Fog,
+		55: weather.Drizzle,
+		67: weather.Sleet,
+		75: weather.Snow,
+		82: weather.Rain,
+		99: weather.Thunderstorm,
+		42: weather.ConditionUnknown,
+	} {
+		cond, _ := getCondition(code)
+		require.Equal(t, expected, cond, "code %d", code)
+	}
+}
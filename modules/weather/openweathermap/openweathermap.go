@@ -21,10 +21,10 @@ package openweathermap // import "barista.run/modules/weather/openweathermap"
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"net/url"
 	"time"
 
+	"barista.run/base/httpclient"
 	"barista.run/modules/weather"
 
 	"github.com/martinlindhe/unit"
@@ -164,9 +164,13 @@ func getCondition(owmCondition int) weather.Condition {
 	return weather.ConditionUnknown
 }
 
+// Uses the last good response while offline. The free tier is limited to 60
+// calls per minute, which is well above the default per-host rate limit.
+var client = httpclient.NewTransport(nil).ServeStale(true).Client()
+
 // GetWeather gets weather information from OpenWeatherMap.
 func (owm Provider) GetWeather() (weather.Weather, error) {
-	response, err := http.Get(string(owm))
+	response, err := client.Get(string(owm))
 	if err != nil {
 		return weather.Weather{}, err
 	}
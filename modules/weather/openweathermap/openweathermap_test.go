@@ -17,9 +17,11 @@ package openweathermap
 import (
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"barista.run/base/httpclient"
 	"barista.run/modules/weather"
 	"barista.run/testing/cron"
 	testServer "barista.run/testing/httpserver"
@@ -31,6 +33,7 @@ import (
 var ts *httptest.Server
 
 func TestMain(m *testing.M) {
+	httpclient.TestMode()
 	ts = testServer.New()
 	defer ts.Close()
 	os.Exit(m.Run())
@@ -59,6 +62,24 @@ func TestGood(t *testing.T) {
 	}, wthr)
 }
 
+func TestRecorded(t *testing.T) {
+	// Re-record with go test -record, using a real API key.
+	srv := testServer.Replay("testdata/fixtures", "https://api.openweathermap.org", "appid")
+	defer srv.Close()
+	p := New(os.Getenv("OWM_API_KEY")).CityID("2172797").(Provider)
+	p = Provider(strings.Replace(string(p), "https://api.openweathermap.org", srv.URL, 1))
+
+	wthr, err := p.GetWeather()
+	require.NoError(t, err)
+	require.Equal(t, "Cairns", wthr.Location)
+	require.Equal(t, weather.Rain, wthr.Condition)
+	require.Equal(t, "light rain", wthr.Description)
+	require.InDelta(t, 23.0, wthr.Temperature.Celsius(), 0.01)
+	require.Equal(t, 0.88, wthr.Humidity)
+	require.Equal(t, 0.4, wthr.CloudCover)
+	require.Equal(t, weather.Direction(130), wthr.Wind.Direction)
+}
+
 func TestErrors(t *testing.T) {
 	_, err := Provider(ts.URL + "/static/bad.json").GetWeather()
 	require.Error(t, err, "bad json")
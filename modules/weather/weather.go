@@ -21,6 +21,8 @@ import (
 	"barista.run/bar"
 	"barista.run/base/notifier"
 	"barista.run/base/value"
+	"barista.run/base/watchers/connectivity"
+	"barista.run/i18n"
 	l "barista.run/logging"
 	"barista.run/outputs"
 	"barista.run/timing"
@@ -42,6 +44,68 @@ type Weather struct {
 	Sunset      time.Time
 	Updated     time.Time
 	Attribution string
+	// Hourly contains the hourly forecast, starting with the current hour,
+	// for providers that support it.
+	Hourly []Forecast
+	// Daily contains the daily forecast, starting with today, for providers
+	// that support it.
+	Daily []Day
+	// Alerts contains any severe weather alerts for the location, from
+	// providers that support them, or added using WithAlerts.
+	Alerts []Alert
+}
+
+// Day represents the expected weather conditions for a day.
+type Day struct {
+	// Date is the start of the day.
+	Date        time.Time
+	Condition   Condition
+	Description string
+	Low         unit.Temperature
+	High        unit.Temperature
+	// Precipitation is the expected amount of precipitation during the day.
+	Precipitation unit.Length
+	// PrecipitationChance is the highest probability of precipitation during
+	// the day, or -1 if the provider does not supply it.
+	PrecipitationChance float64
+	Sunrise             time.Time
+	Sunset              time.Time
+}
+
+// Forecast represents the expected weather conditions for an hour.
+type Forecast struct {
+	// Time is the start of the hour.
+	Time        time.Time
+	Condition   Condition
+	Description string
+	Temperature unit.Temperature
+	// Precipitation is the expected amount of precipitation in the hour.
+	Precipitation unit.Length
+	// PrecipitationChance is the probability of any precipitation in the
+	// hour, or -1 if the provider does not supply it.
+	PrecipitationChance float64
+	Wind                Wind
+	CloudCover          float64
+}
+
+// Until returns the time remaining until the start of the forecast hour,
+// or a negative duration if it has already started.
+func (f Forecast) Until() time.Duration {
+	return f.Time.Sub(timing.Now())
+}
+
+// NextPrecipitation returns the first forecast hour that has not yet ended
+// and expects some precipitation, for example to show "rain in 40m".
+func (w Weather) NextPrecipitation() (Forecast, bool) {
+	for _, f := range w.Hourly {
+		if f.Until() <= -time.Hour {
+			continue
+		}
+		if f.Precipitation > 0 {
+			return f, true
+		}
+	}
+	return Forecast{}, false
 }
 
 // Wind stores the wind speed and direction together.
@@ -79,11 +143,30 @@ const (
 	Hail
 )
 
+var conditionNames = map[Condition]string{
+	Thunderstorm: "Thunderstorm", Drizzle: "Drizzle", Rain: "Rain",
+	Snow: "Snow", Sleet: "Sleet", Mist: "Mist", Smoke: "Smoke",
+	Whirls: "Whirls", Haze: "Haze", Fog: "Fog", Clear: "Clear",
+	Cloudy: "Cloudy", PartlyCloudy: "Partly cloudy", Overcast: "Overcast",
+	Tornado: "Tornado", TropicalStorm: "Tropical storm",
+	Hurricane: "Hurricane", Cold: "Cold", Hot: "Hot", Windy: "Windy",
+	Hail: "Hail",
+}
+
+// String returns a short description of the condition, translated to the
+// current language.
+func (c Condition) String() string {
+	return i18n.T(conditionNames[c])
+}
+
 // Direction represents a compass direction stored as degrees.
 type Direction int
 
 // Provider is an interface for weather providers,
 // implemented by the various provider packages.
+// Providers that support forecasts or alerts also fill in Weather.Hourly,
+// Weather.Daily, and Weather.Alerts. Providers that do not can be combined
+// with a separate source of alerts using WithAlerts.
 type Provider interface {
 	GetWeather() (Weather, error)
 }
@@ -94,6 +177,7 @@ type Module struct {
 	scheduler  *timing.Scheduler
 	refreshFn  func()
 	refreshCh  <-chan struct{}
+	retry      value.Value // of timing.RetryPolicy
 	outputFunc value.Value // of func(Weather) bar.Output
 }
 
@@ -105,10 +189,17 @@ func New(provider Provider) *Module {
 	}
 	m.refreshFn, m.refreshCh = notifier.New()
 	l.Register(m, "outputFunc", "clickHandler", "scheduler")
-	// Default output is just the temperature and conditions.
+	// Default output is just the temperature and conditions, and is urgent
+	// while a severe weather alert is in effect. Providers that do not give
+	// a description fall back to the translated condition.
 	m.Output(func(w Weather) bar.Output {
+		desc := w.Description
+		if desc == "" {
+			desc = w.Condition.String()
+		}
 		return outputs.Textf("%.1f℃ %s (%s)",
-			w.Temperature.Celsius(), w.Description, w.Attribution)
+			w.Temperature.Celsius(), desc, w.Attribution).
+			Urgent(w.AlertSeverity() >= Severe)
 	})
 	m.RefreshInterval(10 * time.Minute)
 	return m
@@ -126,6 +217,14 @@ func (m *Module) RefreshInterval(interval time.Duration) *Module {
 	return m
 }
 
+// Retry configures the module to fetch the weather again with exponential
+// backoff when the provider returns an error, instead of waiting for the next
+// refresh. The delay is reset once the weather is fetched successfully.
+func (m *Module) Retry(p timing.RetryPolicy) *Module {
+	m.retry.Set(p)
+	return m
+}
+
 // Refresh fetches updated weather information.
 func (m *Module) Refresh() {
 	m.refreshFn()
@@ -133,10 +232,25 @@ func (m *Module) Refresh() {
 
 // Stream starts the module.
 func (m *Module) Stream(s bar.Sink) {
-	weather, err := m.provider.GetWeather()
+	retry := timing.NewBackoff()
+	var weather Weather
+	var err error
+	fetch := func() {
+		weather, err = m.provider.GetWeather()
+		if p, ok := m.retry.Get().(timing.RetryPolicy); ok && err != nil {
+			retry.Failed(p)
+		} else {
+			retry.Succeeded()
+		}
+	}
+	fetch()
 	outputFunc := m.outputFunc.Get().(func(Weather) bar.Output)
 	nextOutputFunc, done := m.outputFunc.Subscribe()
 	defer done()
+	ticks, stop := connectivity.Gate(m.scheduler.C)
+	defer stop()
+	retries, stopRetries := connectivity.Gate(retry.C)
+	defer stopRetries()
 	for {
 		if !s.Error(err) {
 			s.Output(outputFunc(weather))
@@ -144,13 +258,15 @@ func (m *Module) Stream(s bar.Sink) {
 		select {
 		case <-nextOutputFunc:
 			outputFunc = m.outputFunc.Get().(func(Weather) bar.Output)
-		case <-m.scheduler.C:
-			weather, err = m.provider.GetWeather()
+		case <-ticks:
+			fetch()
+		case <-retries:
+			fetch()
 		case <-m.refreshCh:
 			if err != nil {
 				s(nil)
 			}
-			weather, err = m.provider.GetWeather()
+			fetch()
 		}
 	}
 }
@@ -18,12 +18,17 @@ import (
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"barista.run/bar"
+	"barista.run/base/watchers/connectivity"
+	"barista.run/i18n"
 	"barista.run/outputs"
 	testBar "barista.run/testing/bar"
+	"barista.run/timing"
 
 	"github.com/martinlindhe/unit"
+	"github.com/stretchr/testify/require"
 )
 
 type testProvider struct {
@@ -81,3 +86,162 @@ func TestWeather(t *testing.T) {
 	p.Unlock()
 	testBar.NextOutput().AssertText([]string{"72, by FLDSMDFR"})
 }
+
+func TestConnectivity(t *testing.T) {
+	testBar.New(t)
+	p := &testProvider{Weather: Weather{Temperature: unit.FromCelsius(20)}}
+	testBar.Run(New(p).Output(func(w Weather) bar.Output {
+		return outputs.Textf("%.0f", w.Temperature.Celsius())
+	}))
+	testBar.NextOutput().AssertText([]string{"20"}, "on start")
+
+	connectivity.SetForTest(connectivity.Offline)
+	p.Lock()
+	p.Temperature = unit.FromCelsius(25)
+	p.Unlock()
+	testBar.Tick()
+	testBar.AssertNoOutput("while offline")
+
+	connectivity.SetForTest(connectivity.Online)
+	testBar.NextOutput().AssertText([]string{"25"}, "when connectivity is restored")
+}
+
+func TestConditionFallback(t *testing.T) {
+	testBar.New(t)
+	p := &testProvider{Weather: Weather{
+		Condition:   PartlyCloudy,
+		Temperature: unit.FromCelsius(20),
+		Attribution: "test",
+	}}
+	testBar.Run(New(p))
+	testBar.NextOutput().AssertText(
+		[]string{"20.0℃ Partly cloudy (test)"}, "without description")
+
+	i18n.SetLanguage("de")
+	defer i18n.SetLanguage("")
+	require.Equal(t, "Teilweise bewölkt", PartlyCloudy.String())
+	require.Equal(t, "", ConditionUnknown.String())
+}
+
+func TestRetry(t *testing.T) {
+	testBar.New(t)
+	p := &testProvider{error: errors.New("unavailable")}
+	start := timing.Now()
+	testBar.Run(New(p).Retry(timing.RetryPolicy{Initial: time.Minute, Max: 4 * time.Minute}))
+	testBar.NextOutput().AssertError("on start")
+
+	for _, d := range []time.Duration{1, 3, 7, 10} {
+		testBar.Tick()
+		require.Equal(t, start.Add(d*time.Minute), timing.Now())
+		testBar.NextOutput().AssertError("on retry")
+	}
+
+	p.Lock()
+	p.error = nil
+	p.Unlock()
+	testBar.Tick()
+	require.Equal(t, start.Add(14*time.Minute), timing.Now())
+	testBar.NextOutput().Expect("on successful retry")
+
+	testBar.Tick()
+	require.Equal(t, start.Add(20*time.Minute), timing.Now(),
+		"refresh interval after success")
+	testBar.NextOutput().Expect("on tick")
+}
+
+func TestNextPrecipitation(t *testing.T) {
+	testBar.New(t)
+	now := timing.Now()
+	w := Weather{Hourly: []Forecast{
+		{Time: now.Add(-2 * time.Hour), Precipitation: 1 * unit.Millimeter},
+		{Time: now.Add(-40 * time.Minute)},
+		{Time: now.Add(20 * time.Minute), Condition: Rain, Precipitation: 0.2 * unit.Millimeter},
+		{Time: now.Add(80 * time.Minute), Condition: Snow, Precipitation: 2 * unit.Millimeter},
+	}}
+	f, ok := w.NextPrecipitation()
+	require.True(t, ok)
+	require.Equal(t, Rain, f.Condition)
+	require.Equal(t, 20*time.Minute, f.Until())
+
+	w.Hourly[1].Precipitation = 0.1 * unit.Millimeter
+	f, ok = w.NextPrecipitation()
+	require.True(t, ok)
+	require.Equal(t, -40*time.Minute, f.Until(), "already started")
+
+	_, ok = Weather{Hourly: w.Hourly[:1]}.NextPrecipitation()
+	require.False(t, ok, "only past precipitation")
+
+	_, ok = Weather{}.NextPrecipitation()
+	require.False(t, ok, "no hourly forecast")
+}
+
+type testAlerts []Alert
+
+func (t testAlerts) GetAlerts() ([]Alert, error) {
+	if len(t) == 1 && t[0].Event == "error" {
+		return nil, errors.New("alerts unavailable")
+	}
+	return t, nil
+}
+
+func TestAlerts(t *testing.T) {
+	testBar.New(t)
+	now := timing.Now()
+	storm := Alert{Event: "Storm Warning", Severity: Severe,
+		Start: now.Add(-time.Hour), End: now.Add(time.Hour)}
+	wind := Alert{Event: "Wind Advisory", Severity: Moderate}
+	flood := Alert{Event: "Flood Warning", Severity: Extreme,
+		Start: now.Add(time.Hour)}
+	expired := Alert{Event: "Heat Warning", Severity: Extreme,
+		End: now.Add(-time.Minute)}
+
+	p := &testProvider{Weather: Weather{
+		Description: "stormy",
+		Temperature: unit.FromCelsius(20),
+		Attribution: "test",
+	}}
+	w := New(WithAlerts(p, testAlerts{wind, flood}, testAlerts{storm, expired}))
+	testBar.Run(w)
+	out := testBar.NextOutput("with alerts")
+	out.AssertText([]string{"20.0℃ stormy (test)"})
+	urgent, _ := out.At(0).Segment().IsUrgent()
+	require.True(t, urgent, "urgent during severe alert")
+
+	var wthr Weather
+	w.Output(func(w Weather) bar.Output {
+		wthr = w
+		return nil
+	})
+	testBar.NextOutput().AssertEmpty()
+	require.Equal(t, []Alert{wind, flood, storm, expired}, wthr.Alerts)
+	require.Equal(t, []Alert{wind, storm}, wthr.ActiveAlerts())
+	require.Equal(t, Severe, wthr.AlertSeverity())
+	require.Equal(t, SeverityUnknown, Weather{}.AlertSeverity())
+
+	p.Lock()
+	p.error = errors.New("foo")
+	p.Unlock()
+	_, err := WithAlerts(p, testAlerts{storm}).GetWeather()
+	require.Error(t, err, "weather error")
+
+	p.Lock()
+	p.error = nil
+	p.Unlock()
+	wthr, err = WithAlerts(p, testAlerts{storm}, testAlerts{{Event: "error"}}).GetWeather()
+	require.NoError(t, err, "alert error")
+	require.Equal(t, "stormy", wthr.Description, "weather without failed alerts")
+	require.Equal(t, []Alert{storm}, wthr.Alerts)
+}
+
+func TestParseSeverity(t *testing.T) {
+	for in, out := range map[string]Severity{
+		"Minor":    Minor,
+		"moderate": Moderate,
+		"SEVERE":   Severe,
+		"Extreme":  Extreme,
+		"Unknown":  SeverityUnknown,
+		"":         SeverityUnknown,
+	} {
+		require.Equal(t, out, ParseSeverity(in), in)
+	}
+}
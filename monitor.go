@@ -0,0 +1,242 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"io"
+	"net"
+	"os"
+	"reflect"
+
+	"barista.run/bar"
+	l "barista.run/logging"
+)
+
+// Monitor is an additional bar, e.g. for another monitor, that is served
+// from the same process as the main bar, so that modules are only created
+// once and can be shown on any number of bars.
+type Monitor struct {
+	socket  string
+	modules []bar.Module
+	layout  func([]*bar.Segment) []*bar.Segment
+	// The indices of modules in the bar, resolved when the bar starts.
+	indices []int
+}
+
+// monitorConn is an i3bar instance connected to a monitor socket.
+type monitorConn struct {
+	view
+	conn net.Conn
+	// The latest output that has not been written to the connection yet.
+	// Writes happen on a separate goroutine, since a stopped i3bar (e.g.
+	// one hidden by a fullscreen window) stops reading, and would otherwise
+	// block the main bar once the socket buffer is full.
+	frames chan []byte
+	closed chan struct{}
+}
+
+func newMonitorConn(conn net.Conn) *monitorConn {
+	return &monitorConn{
+		conn:   conn,
+		frames: make(chan []byte, 1),
+		closed: make(chan struct{}),
+	}
+}
+
+// Write queues a copy of the output to be written to the connection,
+// replacing any output that has not been written yet. It never blocks,
+// since the print loop is the only writer.
+func (c *monitorConn) Write(frame []byte) (int, error) {
+	select {
+	case <-c.frames:
+	default:
+	}
+	c.frames <- append([]byte(nil), frame...)
+	return len(frame), nil
+}
+
+// writeFrames writes queued output to the connection until it is closed.
+func (b *i3Bar) writeFrames(c *monitorConn) {
+	for {
+		select {
+		case frame := <-c.frames:
+			if _, err := c.conn.Write(frame); err != nil {
+				l.Log("Disconnecting monitor bar: %v", err)
+				b.disconnect(c)
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// AddMonitor serves an additional bar on a unix socket at the given path.
+// The i3bar instance for the monitor should connect to the socket in its
+// status_command, using Relay or a tool like socat, e.g.
+//
+//	status_command socat STDIO UNIX-CONNECT:/run/user/1000/barista-hdmi.sock
+//
+// Any number of i3bar instances can connect to the same socket. By default,
+// the monitor shows the same modules as the main bar. Pause and resume only
+// follow the main bar. Must be called before Run.
+func AddMonitor(socket string) *Monitor {
+	construct()
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot add a monitor after .Run()")
+	}
+	m := &Monitor{socket: socket}
+	instance.monitors = append(instance.monitors, m)
+	return m
+}
+
+// Modules sets the modules shown on the monitor, in order. Modules that are
+// not added to the main bar are only shown on this monitor, and modules that
+// are shown on multiple bars share the same output, so a module is only
+// streamed once regardless of the number of bars. Must be called before Run.
+func (m *Monitor) Modules(modules ...bar.Module) *Monitor {
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot change monitor modules after .Run()")
+	}
+	m.modules = modules
+	return m
+}
+
+// Layout sets a function that transforms the segments shown on the monitor,
+// replacing the layout set by SetLayout. Must be called before Run.
+func (m *Monitor) Layout(layout func([]*bar.Segment) []*bar.Segment) *Monitor {
+	instance.Lock()
+	defer instance.Unlock()
+	if instance.started {
+		panic("Cannot change monitor layout after .Run()")
+	}
+	m.layout = layout
+	return m
+}
+
+// resolve finds the index of each module shown on the monitor, adding any
+// modules that are not already on the bar, and returns the new module list.
+func (m *Monitor) resolve(modules []bar.Module) []bar.Module {
+	if m.modules == nil {
+		return modules
+	}
+	m.indices = make([]int, 0, len(m.modules))
+	for _, mod := range m.modules {
+		idx := indexOf(modules, mod)
+		if idx < 0 {
+			idx = len(modules)
+			modules = append(modules, mod)
+		}
+		m.indices = append(m.indices, idx)
+	}
+	return modules
+}
+
+// indexOf returns the index of the module in the list, or -1 if not found.
+// Modules are compared by identity, so modules of types that cannot be
+// compared are never found.
+func indexOf(modules []bar.Module, module bar.Module) int {
+	t := reflect.TypeOf(module)
+	if !t.Comparable() {
+		return -1
+	}
+	for idx, m := range modules {
+		if reflect.TypeOf(m) == t && m == module {
+			return idx
+		}
+	}
+	return -1
+}
+
+// listenMonitor starts accepting i3bar instances on the monitor socket,
+// replacing any socket left over from a previous run.
+func (b *i3Bar) listenMonitor(m *Monitor) error {
+	removeSocket(m.socket)
+	listener, err := net.Listen("unix", m.socket)
+	if err != nil {
+		return err
+	}
+	l.Log("Listening for bars on %s", m.socket)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				l.Log("Monitor socket closed: %v", err)
+				return
+			}
+			go b.connectMonitor(m, conn)
+		}
+	}()
+	return nil
+}
+
+func (b *i3Bar) connectMonitor(m *Monitor, conn net.Conn) {
+	if err := writeHeader(conn, false); err != nil {
+		conn.Close()
+		return
+	}
+	c := newMonitorConn(conn)
+	c.view.modules = m.indices
+	c.view.layout = m.layout
+	b.Lock()
+	b.monitorConns = append(b.monitorConns, c)
+	b.Unlock()
+	go b.writeFrames(c)
+	l.Log("Bar connected on %s", m.socket)
+	b.refresh()
+	err := b.readEvents(conn, &c.view)
+	l.Fine("Bar on %s stopped sending events: %v", m.socket, err)
+	b.disconnect(c)
+}
+
+// disconnect closes the connection to an i3bar instance, and stops sending
+// output to it. It can be called more than once for the same connection.
+func (b *i3Bar) disconnect(c *monitorConn) {
+	b.Lock()
+	defer b.Unlock()
+	for i, conn := range b.monitorConns {
+		if conn == c {
+			b.monitorConns = append(b.monitorConns[:i], b.monitorConns[i+1:]...)
+			c.conn.Close()
+			close(c.closed)
+			break
+		}
+	}
+}
+
+// Relay connects the standard input and output of the process to a monitor
+// socket, so that the bar's own binary can be used as the status_command of
+// an i3bar instance for another monitor. It returns when either side closes.
+func Relay(socket string) error {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	errChan := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(os.Stdout, conn)
+		errChan <- err
+	}()
+	go func() {
+		_, err := io.Copy(conn, os.Stdin)
+		errChan <- err
+	}()
+	return <-errChan
+}
@@ -0,0 +1,189 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/testing/mockio"
+	testModule "barista.run/testing/module"
+
+	"github.com/stretchr/testify/require"
+)
+
+// monitorBar is an i3bar instance connected to a monitor socket.
+type monitorBar struct {
+	net.Conn
+	lines chan string
+}
+
+func connectMonitor(t *testing.T, socket string) *monitorBar {
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	m := &monitorBar{conn, make(chan string, 10)}
+	go func() {
+		s := bufio.NewScanner(conn)
+		for s.Scan() {
+			m.lines <- s.Text()
+		}
+		close(m.lines)
+	}()
+	return m
+}
+
+// assertTexts asserts that the monitor bar eventually shows the given texts.
+func (m *monitorBar) assertTexts(t *testing.T, expected ...string) {
+	var texts []string
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case line, ok := <-m.lines:
+			require.True(t, ok, "monitor bar disconnected")
+			if strings.HasPrefix(line, "[[") {
+				// The first output starts the infinite array.
+				line = line[1:]
+			}
+			if !strings.HasPrefix(line, "[") {
+				continue
+			}
+			var out []map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &out))
+			texts = []string{}
+			for _, segment := range out {
+				texts = append(texts, segment["full_text"].(string))
+			}
+			if reflect.DeepEqual(texts, expected) {
+				return
+			}
+		case <-timeout:
+			require.Equal(t, expected, texts, "output on monitor bar")
+			return
+		}
+	}
+}
+
+func TestMonitors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "barista")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	mirror := filepath.Join(dir, "mirror.sock")
+	minimal := filepath.Join(dir, "minimal.sock")
+
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+
+	tray := testModule.New(t)
+	clock := testModule.New(t)
+	extra := testModule.New(t)
+	AddMonitor(mirror)
+	AddMonitor(minimal).
+		Modules(clock, extra).
+		Layout(func(in []*bar.Segment) []*bar.Segment {
+			return []*bar.Segment{in[1], in[0]}
+		})
+	go Run(tray, clock)
+
+	_, err = mockStdout.ReadUntil('[', time.Second)
+	require.NoError(t, err)
+	mockStdin.WriteString("[")
+	tray.AssertStarted()
+	clock.AssertStarted()
+	extra.AssertStarted("modules only on monitors are started")
+
+	tray.OutputText("tray")
+	readOutputTexts(t, mockStdout)
+	extra.OutputText("extra")
+	readOutputTexts(t, mockStdout)
+	clock.OutputText("12:00")
+	require.Equal(t, []string{"tray", "12:00"}, readOutputTexts(t, mockStdout))
+
+	mirrorBar := connectMonitor(t, mirror)
+	defer mirrorBar.Close()
+	header := <-mirrorBar.lines
+	require.NotContains(t, header, "stop_signal", "pause only follows the main bar")
+	mirrorBar.assertTexts(t, "tray", "12:00")
+
+	minimalBar := connectMonitor(t, minimal)
+	defer minimalBar.Close()
+	minimalBar.assertTexts(t, "extra", "12:00")
+	minimalBar.Write([]byte("["))
+
+	mockStdout.ReadNow()
+	clock.OutputText("12:01")
+	require.Equal(t, []string{"tray", "12:01"}, readOutputTexts(t, mockStdout))
+	mirrorBar.assertTexts(t, "tray", "12:01")
+	minimalBar.assertTexts(t, "extra", "12:01")
+
+	fmt.Fprintf(minimalBar, `{"name": "1", "button": 1},`)
+	e := clock.AssertClicked("click on monitor bar")
+	require.Equal(t, bar.ButtonLeft, e.Button)
+	tray.AssertNotClicked()
+
+	mirrorBar.Close()
+	clock.OutputText("12:02")
+	require.Equal(t, []string{"tray", "12:02"}, readOutputTexts(t, mockStdout),
+		"main bar continues after a monitor bar disconnects")
+	minimalBar.assertTexts(t, "extra", "12:02")
+
+	require.Panics(t, func() { AddMonitor(mirror) }, "after Run")
+}
+
+func TestStalledMonitor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "barista")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "stalled.sock")
+
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+
+	module := testModule.New(t)
+	AddMonitor(socket)
+	go Run(module)
+
+	_, err = mockStdout.ReadUntil('[', time.Second)
+	require.NoError(t, err)
+	module.AssertStarted()
+	module.OutputText("start")
+	readOutputTexts(t, mockStdout)
+
+	// Never read from the connection, as if the relay was stopped.
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, []string{"start"}, readOutputTexts(t, mockStdout),
+		"redrawn when the monitor bar connects")
+
+	long := strings.Repeat("x", 10000)
+	for i := 0; i < 100; i++ {
+		text := fmt.Sprintf("%d%s", i, long)
+		module.OutputText(text)
+		require.Equal(t, []string{text}, readOutputTexts(t, mockStdout),
+			"main bar updates while a monitor bar is stalled")
+	}
+}
@@ -53,20 +53,26 @@ func SetEncryptionKey(key []byte) {
 	globalEncryptionKey = key
 }
 
-func getEncryptionKeyChecked() []byte {
+func getEncryptionKey() []byte {
 	globalEncryptionKeyMu.Lock()
 	defer globalEncryptionKeyMu.Unlock()
-	if len(globalEncryptionKey) == 0 {
+	return globalEncryptionKey
+}
+
+func getEncryptionKeyChecked() []byte {
+	key := getEncryptionKey()
+	if len(key) == 0 {
 		panic("Encryption key not set")
 	}
-	return globalEncryptionKey
+	return key
 }
 
 var fs = afero.NewOsFs()
 
 type encryptedToken struct{ Salt, IV, Token []byte }
 
-func loadToken(filename string) (*oauth2.Token, error) {
+// readEncrypted reads and decrypts the contents of an encrypted file.
+func readEncrypted(filename string) ([]byte, error) {
 	key := getEncryptionKeyChecked()
 	f, err := fs.Open(filename)
 	if err != nil {
@@ -81,21 +87,30 @@ func loadToken(filename string) (*oauth2.Token, error) {
 	dk := pbkdf2.Key(key, eTok.Salt, pbkdf2Iterations, aes256KeySize, sha256.New)
 	block, _ := aes.NewCipher(dk) // no error, key size is fixed.
 	cipher.NewCFBDecrypter(block, eTok.IV).XORKeyStream(eTok.Token, eTok.Token)
+	return eTok.Token, nil
+}
+
+func loadToken(filename string) (*oauth2.Token, error) {
+	data, err := readEncrypted(filename)
+	if err != nil {
+		return nil, err
+	}
 	tok := &oauth2.Token{}
-	err = json.Unmarshal(eTok.Token, tok)
+	err = json.Unmarshal(data, tok)
 	return tok, err
 }
 
 var randRead = rand.Read // for tests.
 
-func storeToken(filename string, token *oauth2.Token) error {
+// writeEncrypted encrypts the data and writes it to the given file.
+func writeEncrypted(filename string, data []byte) error {
 	key := getEncryptionKeyChecked()
 	eTok := encryptedToken{
-		Salt: make([]byte, 64),
-		IV:   make([]byte, aes.BlockSize),
+		Salt:  make([]byte, 64),
+		IV:    make([]byte, aes.BlockSize),
+		Token: append([]byte(nil), data...),
 	}
 	var err error
-	eTok.Token, _ = json.Marshal(token) // no error, input is only []bytes.
 	if _, err := randRead(eTok.Salt); err != nil {
 		return err
 	}
@@ -112,3 +127,8 @@ func storeToken(filename string, token *oauth2.Token) error {
 	}
 	return err
 }
+
+func storeToken(filename string, token *oauth2.Token) error {
+	data, _ := json.Marshal(token) // no error, input is only []bytes.
+	return writeEncrypted(filename, data)
+}
@@ -0,0 +1,160 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// deviceGrantType is the grant type for the device authorization grant,
+// see RFC 8628.
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// deviceAuthURLs maps the token URLs of well-known providers to their device
+// authorization endpoints.
+var deviceAuthURLs = map[string]string{
+	"https://oauth2.googleapis.com/token":         "https://oauth2.googleapis.com/device/code",
+	"https://accounts.google.com/o/oauth2/token":  "https://oauth2.googleapis.com/device/code",
+	"https://github.com/login/oauth/access_token": "https://github.com/login/device/code",
+}
+
+// DeviceAuthURL sets the device authorization endpoint for this config, for
+// providers that are not already known. The device authorization grant is
+// used during setup when running `setup-oauth --device`, and requires that
+// the client be allowed to use it (e.g. a "TVs and Limited Input devices"
+// client for Google, or an app with device flow enabled for GitHub).
+func (c *Config) DeviceAuthURL(url string) *Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deviceAuthURL = url
+	return c
+}
+
+type deviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	// Google uses verification_url instead of verification_uri.
+	VerificationURL string `json:"verification_url"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+type deviceToken struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// For tests.
+var sleep = time.Sleep
+
+// postForm posts the form values and decodes the JSON response. Providers
+// return errors in the JSON response body, with varying status codes, so the
+// status code is only used if the body cannot be decoded.
+func postForm(u string, vals url.Values, out interface{}) error {
+	req, err := http.NewRequest("POST", u, strings.NewReader(vals.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub returns form-encoded responses by default.
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s: %s", resp.Status, err)
+	}
+	return nil
+}
+
+// deviceFlow obtains a token using the device authorization grant, by asking
+// the user to enter a code on any device with a browser, and polling the
+// token endpoint until the user approves or denies the request.
+func (c *Config) deviceFlow() (*oauth2.Token, error) {
+	var code deviceCode
+	err := postForm(c.deviceAuthURL, url.Values{
+		"client_id": {c.config.ClientID},
+		"scope":     {strings.Join(c.config.Scopes, " ")},
+	}, &code)
+	if err != nil {
+		return nil, err
+	}
+	if code.DeviceCode == "" {
+		return nil, errors.New("no device code in response")
+	}
+	verificationURI := code.VerificationURI
+	if verificationURI == "" {
+		verificationURI = code.VerificationURL
+	}
+	fmt.Fprintf(stdout, "- Visit %s and enter the code %s\n", verificationURI, code.UserCode)
+	interval := time.Duration(code.Interval) * time.Second
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	expiresIn := time.Duration(code.ExpiresIn) * time.Second
+	for waited := time.Duration(0); waited < expiresIn; waited += interval {
+		sleep(interval)
+		var tok deviceToken
+		err := postForm(c.config.Endpoint.TokenURL, url.Values{
+			"grant_type":    {deviceGrantType},
+			"device_code":   {code.DeviceCode},
+			"client_id":     {c.config.ClientID},
+			"client_secret": {c.config.ClientSecret},
+		}, &tok)
+		if err != nil {
+			return nil, err
+		}
+		switch tok.Error {
+		case "":
+			return tok.token(), nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			if tok.ErrorDescription != "" {
+				return nil, fmt.Errorf("%s: %s", tok.Error, tok.ErrorDescription)
+			}
+			return nil, errors.New(tok.Error)
+		}
+	}
+	return nil, errors.New("device code expired")
+}
+
+func (d deviceToken) token() *oauth2.Token {
+	tok := &oauth2.Token{
+		AccessToken:  d.AccessToken,
+		TokenType:    d.TokenType,
+		RefreshToken: d.RefreshToken,
+	}
+	if d.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(d.ExpiresIn) * time.Second)
+	}
+	return tok
+}
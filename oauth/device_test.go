@@ -0,0 +1,234 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+var deviceURL string
+var devicePolls int32
+
+func handleDeviceCode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"device_code":      "devicecode-" + r.FormValue("client_id"),
+		"user_code":        "ABCD-EFGH",
+		"verification_uri": "https://example.com/device",
+		"expires_in":       60,
+		"interval":         5,
+	})
+}
+
+func handleDeviceToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	resp := map[string]interface{}{}
+	switch r.FormValue("device_code") {
+	case "devicecode-DeniedClient":
+		resp["error"] = "access_denied"
+		resp["error_description"] = "The user denied the request"
+	case "devicecode-SlowClient":
+		resp["error"] = "authorization_pending"
+	case "devicecode-ClientID":
+		switch atomic.AddInt32(&devicePolls, 1) {
+		case 1:
+			resp["error"] = "authorization_pending"
+		case 2:
+			resp["error"] = "slow_down"
+		default:
+			resp["access_token"] = "mocktoken"
+			resp["token_type"] = "bearer"
+			resp["refresh_token"] = "mockrefreshtoken"
+			resp["expires_in"] = tokenExpirySeconds
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		resp["error"] = "invalid_client"
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func registerDevice(clientID string) *Config {
+	return Register(&oauth2.Config{
+		Endpoint:     testEndpoint,
+		ClientID:     clientID,
+		ClientSecret: "not-really-secret",
+		Scopes:       []string{"a", "b"},
+	}).DeviceAuthURL(deviceURL)
+}
+
+func TestDeviceFlow(t *testing.T) {
+	defer func(args []string) { os.Args = args }(os.Args)
+	mockStdout, _, exitCode := resetForTest()
+	atomic.StoreInt32(&devicePolls, 0)
+	var sleeps []time.Duration
+	sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	conf := registerDevice("ClientID")
+	os.Args = []string{"arg0", "setup-oauth", "--device"}
+	go InteractiveSetup()
+	assertExitCode(t, exitCode, 0)
+	require.Equal(t, `Updating registered Oauth configurations:
+
+[1 of 1] #pkg#.registerDevice
+* Domain: #host#
+* Scopes: a, b
+- Visit https://example.com/device and enter the code ABCD-EFGH
++ Successfully updated token, expires #expiry#
+
+All tokens updated successfully
+`, sanitiseOauthOutput(mockStdout.ReadNow()))
+	require.Equal(t,
+		[]time.Duration{5 * time.Second, 5 * time.Second, 10 * time.Second},
+		sleeps, "polls at the given interval, slowing down if asked")
+
+	client, err := conf.Client()
+	require.NoError(t, err)
+	resp, _ := client.Get(checkURL)
+	require.Equal(t, 200, resp.StatusCode)
+}
+
+func TestDeviceFlowErrors(t *testing.T) {
+	resetForTest()
+	var sleeps []time.Duration
+	sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	_, err := registerDevice("DeniedClient").deviceFlow()
+	require.EqualError(t, err, "access_denied: The user denied the request")
+
+	sleeps = nil
+	_, err = registerDevice("SlowClient").deviceFlow()
+	require.EqualError(t, err, "device code expired")
+	require.Len(t, sleeps, 12, "polls until expiry")
+
+	_, err = registerDevice("UnknownClient").deviceFlow()
+	require.EqualError(t, err, "invalid_client")
+
+	_, err = registerDevice("ClientID").DeviceAuthURL(checkURL).deviceFlow()
+	require.Error(t, err, "with invalid device code response")
+}
+
+func TestSetupProviderFilter(t *testing.T) {
+	defer func(args []string) { os.Args = args }(os.Args)
+	mockStdout, mockStdin, exitCode := resetForTest()
+
+	registerA()
+	Register(&oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://example.com/auth",
+			TokenURL: "https://example.com/token",
+		},
+		ClientID: "ClientID",
+	})
+
+	os.Args = []string{"arg0", "setup-oauth", "force-refresh", testHostname()}
+	go InteractiveSetup()
+	mockStdin.Write([]byte("authcode\n"))
+	assertExitCode(t, exitCode, 0)
+	require.Equal(t, `Updating registered Oauth configurations:
+
+[1 of 1] #pkg#.registerA
+* Domain: #host#
+* Scopes: a, b
+- Visit #authURL# and enter the code here:
+> + Successfully updated token, expires #expiry#
+
+All tokens updated successfully
+`, sanitiseOauthOutput(mockStdout.ReadNow()))
+
+	mockStdout, _, exitCode = resetForTest()
+	registerA()
+	os.Args = []string{"arg0", "setup-oauth", "example.org"}
+	go InteractiveSetup()
+	assertExitCode(t, exitCode, 0)
+	require.Equal(t, "Nothing to update\n", mockStdout.ReadNow())
+}
+
+func TestRefreshBackoff(t *testing.T) {
+	resetForTest()
+	timing.TestMode()
+	jitter = func(d time.Duration) time.Duration { return d }
+
+	var requests, failures int32 = 0, 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= atomic.LoadInt32(&failures) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"refreshed","expires_in":%d}`, tokenExpirySeconds)
+	}))
+	defer srv.Close()
+
+	conf := Register(&oauth2.Config{
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   srv.URL + "/auth",
+			TokenURL:  srv.URL,
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+		ClientID: "ClientID",
+	})
+	require.NoError(t, conf.save(&oauth2.Token{
+		AccessToken:  "expired",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Add(-time.Hour),
+	}))
+
+	_, err := conf.Token()
+	require.Error(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	_, err = conf.Token()
+	require.Error(t, err, "during backoff")
+	timing.AdvanceBy(9 * time.Second)
+	_, err = conf.Token()
+	require.Error(t, err, "during backoff")
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests), "no requests during backoff")
+
+	timing.AdvanceBy(time.Second)
+	_, err = conf.Token()
+	require.Error(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests), "retried after backoff")
+
+	timing.AdvanceBy(10 * time.Second)
+	_, err = conf.Token()
+	require.Error(t, err, "backoff doubles")
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	timing.AdvanceBy(10 * time.Second)
+	tok, err := conf.Token()
+	require.NoError(t, err)
+	require.Equal(t, "refreshed", tok.AccessToken)
+	require.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	saved, _ := conf.load()
+	require.Equal(t, "refreshed", saved.AccessToken, "refreshed token saved")
+	require.Equal(t, time.Duration(0), conf.backoff, "backoff reset on success")
+
+	require.Equal(t, 10*time.Second, nextRefreshBackoff(0))
+	require.Equal(t, 15*time.Minute, nextRefreshBackoff(10*time.Minute))
+}
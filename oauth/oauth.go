@@ -19,8 +19,10 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
@@ -31,7 +33,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"barista.run/base/httpclient"
 	l "barista.run/logging"
+	"barista.run/timing"
 
 	"golang.org/x/oauth2"
 )
@@ -40,15 +44,21 @@ import (
 // It can be used to create an authenticated client after the user has setup
 // oauth for barista using the InteractiveSetup() method.
 type Config struct {
-	config   *oauth2.Config
-	filename string
+	config *oauth2.Config
+	name   string
 	// For more context during interactive auth
 	domain  string
 	callers []string
+	// For the device authorization grant, if supported.
+	deviceAuthURL string
 	// To support automatic saving of refreshed tokens.
 	tokenSource oauth2.TokenSource
 	token       *oauth2.Token
 	mu          sync.Mutex
+	// To back off after failed refreshes.
+	refreshErr error
+	backoff    time.Duration
+	retryAt    time.Time
 }
 
 // Track all registered configs, so that InteractiveSetup() can work.
@@ -82,8 +92,9 @@ func Register(config *oauth2.Config) *Config {
 	}
 	providerU, _ := url.Parse(config.Endpoint.AuthURL)
 	c := &Config{
-		config: config,
-		domain: providerU.Hostname(),
+		config:        config,
+		domain:        providerU.Hostname(),
+		deviceAuthURL: deviceAuthURLs[config.Endpoint.TokenURL],
 	}
 	caller := "<unknown>"
 	pc, _, _, ok := runtime.Caller(1)
@@ -101,21 +112,21 @@ func Register(config *oauth2.Config) *Config {
 		io.WriteString(hasher, scope)
 	}
 	hash := base64.RawURLEncoding.EncodeToString(hasher.Sum(nil))
-	filename := filepath.Join(configDir,
-		fmt.Sprintf("%s_%s.json", c.domain, hash))
-	// So the final resulting filename will be something like
-	// ~/.config/barista/oauth/accounts.google.com_MTIzNDU2Nzg5MDEyMzQ1Njc4OTAxMjM0NTY3OA.json
-	c.filename = filename
+	// So the final resulting name will be something like
+	// accounts.google.com_MTIzNDU2Nzg5MDEyMzQ1Njc4OTAxMjM0NTY3OA, which is
+	// also the filename (with .json) when using encrypted files.
+	name := fmt.Sprintf("%s_%s", c.domain, hash)
+	c.name = name
 	registeredConfigsMu.Lock()
 	defer registeredConfigsMu.Unlock()
-	existing, ok := registeredConfigsMap[filename]
+	existing, ok := registeredConfigsMap[name]
 	if ok {
 		existing.addCaller(caller)
 		return existing
 	}
 	c.callers = []string{caller}
 	registeredConfigs = append(registeredConfigs, c)
-	registeredConfigsMap[filename] = c
+	registeredConfigsMap[name] = c
 	return c
 }
 
@@ -133,10 +144,39 @@ var stdin io.Reader = os.Stdin
 var stdout io.Writer = os.Stdout
 var osExit = os.Exit
 
+// setupOptions are the command-line options for interactive setup.
+type setupOptions struct {
+	force   bool
+	device  bool
+	domains map[string]bool
+}
+
+func parseSetupArgs(args []string) setupOptions {
+	opts := setupOptions{domains: map[string]bool{}}
+	for _, arg := range args {
+		switch arg {
+		case "force-refresh", "--force-refresh":
+			opts.force = true
+		case "--device":
+			opts.device = true
+		default:
+			opts.domains[arg] = true
+		}
+	}
+	return opts
+}
+
 // InteractiveSetup checks each registered config and guides the user through
 // a command-line interactive auth process for each config that doesn't have a
 // valid token. Only intended for use by barista's Run() method, calling it
 // at the wrong time can leave you unable to save tokens for some configs.
+//
+// Setup is started by running the bar with `setup-oauth`, optionally followed
+// by `force-refresh` to refresh all saved tokens, `--device` to use the device
+// authorization grant where supported, and one or more provider domains (e.g.
+// `github.com`) to only set up those providers. The device authorization grant
+// does not need a browser on the same machine, so setup can be completed over
+// SSH on a headless machine.
 func InteractiveSetup() {
 	if !atomic.CompareAndSwapInt32(&setupHasBeenCalled, 0, 1) {
 		l.Log("Setup called more than once!")
@@ -145,11 +185,16 @@ func InteractiveSetup() {
 	if len(os.Args) < 2 || os.Args[1] != "setup-oauth" {
 		return
 	}
-	// TODO: Argument parsing here.
-	forceUpdate := len(os.Args) > 2 && os.Args[2] == "force-refresh"
+	opts := parseSetupArgs(os.Args[2:])
 	registeredConfigsMu.Lock()
 	defer registeredConfigsMu.Unlock()
-	if len(registeredConfigs) == 0 {
+	var configs []*Config
+	for _, c := range registeredConfigs {
+		if len(opts.domains) == 0 || opts.domains[c.domain] {
+			configs = append(configs, c)
+		}
+	}
+	if len(configs) == 0 {
 		fmt.Fprintln(stdout, "Nothing to update")
 		osExit(0)
 		return
@@ -157,8 +202,8 @@ func InteractiveSetup() {
 	os.MkdirAll(configDir, 0700)
 	success := true
 	fmt.Fprintln(stdout, "Updating registered Oauth configurations:")
-	for idx, c := range registeredConfigs {
-		if !c.prompt(idx, len(registeredConfigs), forceUpdate) {
+	for idx, c := range configs {
+		if !c.prompt(idx, len(configs), opts) {
 			success = false
 		}
 	}
@@ -174,13 +219,13 @@ func commas(s []string) string {
 	return strings.Join(s, ", ")
 }
 
-func (c *Config) prompt(index, total int, force bool) bool {
+func (c *Config) prompt(index, total int, opts setupOptions) bool {
 	fmt.Fprintf(stdout, "\n[%d of %d] %s\n* Domain: %s\n* Scopes: %s\n",
 		index+1, total, commas(c.callers), c.domain, commas(c.config.Scopes))
 
 	err := c.autoUpdateToken()
 	if err == nil {
-		if force && c.token.RefreshToken != "" {
+		if opts.force && c.token.RefreshToken != "" {
 			c.tokenSource = c.config.TokenSource(context.Background(), c.token)
 			c.token.Expiry = time.Now().Add(-time.Hour)
 		}
@@ -200,14 +245,18 @@ func (c *Config) prompt(index, total int, force bool) bool {
 		fmt.Fprintf(stdout, "! Automatic refresh failed\n")
 	}
 
-	authURL := c.config.AuthCodeURL("no-state", oauth2.AccessTypeOffline)
-	fmt.Fprintf(stdout, "- Visit %v and enter the code here:\n> ", authURL)
-	var authCode string
-	if _, err = fmt.Fscan(stdin, &authCode); err == nil {
-		c.token, err = c.config.Exchange(oauth2.NoContext, authCode)
+	if opts.device && c.deviceAuthURL != "" {
+		c.token, err = c.deviceFlow()
+	} else {
+		authURL := c.config.AuthCodeURL("no-state", oauth2.AccessTypeOffline)
+		fmt.Fprintf(stdout, "- Visit %v and enter the code here:\n> ", authURL)
+		var authCode string
+		if _, err = fmt.Fscan(stdin, &authCode); err == nil {
+			c.token, err = c.config.Exchange(oauth2.NoContext, authCode)
+		}
 	}
 	if err == nil {
-		err = storeToken(c.filename, c.token)
+		err = c.save(c.token)
 	}
 	if err != nil {
 		fmt.Fprintf(stdout, "! Failed to update token: %v\n", err)
@@ -227,12 +276,29 @@ func formatExpiry(expiry time.Time) string {
 
 func (c *Config) autoUpdateToken() error {
 	var err error
-	c.token, err = loadToken(c.filename)
+	c.token, err = c.load()
 	return err
 }
 
+// load reads the saved token from the secret store.
+func (c *Config) load() (*oauth2.Token, error) {
+	data, err := getSecret(c.name)
+	if err != nil {
+		return nil, err
+	}
+	tok := &oauth2.Token{}
+	err = json.Unmarshal(data, tok)
+	return tok, err
+}
+
+// save writes the token to the secret store.
+func (c *Config) save(tok *oauth2.Token) error {
+	data, _ := json.Marshal(tok) // no error, input is only []bytes.
+	return getSecretStore().Set(c.name, data)
+}
+
 // Token makes Config a TokenSource, in a way that automatically saves any
-// newly fetched tokens to disk.
+// newly fetched tokens to the secret store.
 func (c *Config) Token() (*oauth2.Token, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -245,18 +311,55 @@ func (c *Config) Token() (*oauth2.Token, error) {
 	if c.token.Valid() {
 		return c.token, nil
 	}
+	if timing.Now().Before(c.retryAt) {
+		return nil, c.refreshErr
+	}
 	tok, err := c.tokenSource.Token()
 	if err != nil {
+		c.backoff = nextRefreshBackoff(c.backoff)
+		wait := jitter(c.backoff)
+		l.Log("Failed to refresh %s token, next attempt in %v: %v", c.domain, wait, err)
+		c.refreshErr = err
+		c.retryAt = timing.Now().Add(wait)
 		return nil, err
 	}
+	c.backoff = 0
+	c.retryAt = time.Time{}
 	c.token = tok
-	return tok, storeToken(c.filename, tok)
+	return tok, c.save(tok)
+}
+
+const (
+	minRefreshBackoff = 10 * time.Second
+	maxRefreshBackoff = 15 * time.Minute
+)
+
+// nextRefreshBackoff doubles the backoff after each failed refresh, so that
+// transient failures are retried quickly without flooding the provider if the
+// refresh token has been revoked.
+func nextRefreshBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff < minRefreshBackoff {
+		backoff = minRefreshBackoff
+	}
+	if backoff > maxRefreshBackoff {
+		backoff = maxRefreshBackoff
+	}
+	return backoff
+}
+
+// jitter returns a random duration between 50% and 150% of the given
+// duration, to avoid synchronised retries across configs. For tests.
+var jitter = func(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
 }
 
 // Client returns an http client that authorises requests using the previously
-// saved token for this configuration.
+// saved token for this configuration. Requests are cached and rate limited
+// using the shared client from barista.run/base/httpclient.
 func (c *Config) Client() (*http.Client, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return oauth2.NewClient(context.Background(), c), c.autoUpdateToken()
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpclient.New())
+	return oauth2.NewClient(ctx, c), c.autoUpdateToken()
 }
@@ -76,6 +76,7 @@ func resetForTest() (mockStdout *mockio.Writable, mockStdin *mockio.Readable, ex
 	mockStdout = mockio.Stdout()
 	stdout = mockStdout
 	configDir = "/conf/dir"
+	SetSecretStore(EncryptedFiles())
 	exitCode = make(chan int, 1)
 	osExit = func(code int) { exitCode <- code }
 	resetKey([]byte("test"))
@@ -697,7 +698,12 @@ func TestOauthRegisterAfterSetup(t *testing.T) {
 
 func TestMain(m *testing.M) {
 	mux := http.NewServeMux()
+	mux.HandleFunc("/device/code", handleDeviceCode)
 	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") == deviceGrantType {
+			handleDeviceToken(w, r)
+			return
+		}
 		if r.FormValue("code") == "authcode" {
 			w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
 			w.Write([]byte(strings.Join([]string{
@@ -734,6 +740,7 @@ func TestMain(m *testing.M) {
 		TokenURL: server.URL + "/token",
 	}
 	checkURL = server.URL + "/check"
+	deviceURL = server.URL + "/device/code"
 
 	os.Exit(m.Run())
 }
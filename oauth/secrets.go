@@ -0,0 +1,276 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"barista.run/base/watchers/dbus"
+	l "barista.run/logging"
+
+	godbus "github.com/godbus/dbus/v5"
+)
+
+// SecretStore is a backend for storing secrets, such as oauth tokens and API
+// keys. Secrets are identified by name, and stored as opaque bytes.
+type SecretStore interface {
+	// Get returns the secret with the given name, or ErrNotFound if there is
+	// no such secret.
+	Get(name string) ([]byte, error)
+	// Set stores a secret, replacing any existing secret with the same name.
+	Set(name string, secret []byte) error
+}
+
+// ErrNotFound is returned by secret stores when a secret does not exist.
+var ErrNotFound = errors.New("secret not found")
+
+var (
+	secretStore   SecretStore = EncryptedFiles()
+	secretStoreMu sync.Mutex
+)
+
+// SetSecretStore sets the backend used to store oauth tokens and secrets. It
+// should be called before Run, and defaults to encrypted files.
+//
+// When using a store other than encrypted files, tokens that were previously
+// saved to encrypted files are migrated to the new store the first time they
+// are needed, as long as the encryption key is set, and the encrypted files
+// are removed after a successful migration.
+func SetSecretStore(store SecretStore) {
+	secretStoreMu.Lock()
+	defer secretStoreMu.Unlock()
+	secretStore = store
+}
+
+func getSecretStore() SecretStore {
+	secretStoreMu.Lock()
+	defer secretStoreMu.Unlock()
+	return secretStore
+}
+
+// Secret returns a secret (e.g. an API key) from the secret store, with any
+// surrounding whitespace removed.
+func Secret(name string) (string, error) {
+	data, err := getSecret(name)
+	return strings.TrimSpace(string(data)), err
+}
+
+// SetSecret saves a secret (e.g. an API key) to the secret store.
+func SetSecret(name, secret string) error {
+	return getSecretStore().Set(name, []byte(secret))
+}
+
+// getSecret gets a secret from the current secret store, migrating it from
+// encrypted files if necessary.
+func getSecret(name string) ([]byte, error) {
+	store := getSecretStore()
+	data, err := store.Get(name)
+	if err != ErrNotFound {
+		return data, err
+	}
+	if _, ok := store.(encryptedFiles); ok || len(getEncryptionKey()) == 0 {
+		return nil, err
+	}
+	legacy := encryptedFiles{}.filename(name)
+	data, err = readEncrypted(legacy)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Set(name, data); err != nil {
+		l.Log("Failed to migrate %s to secret store: %v", name, err)
+		return data, nil
+	}
+	if err := fs.Remove(legacy); err != nil {
+		l.Log("Failed to remove %s after migration: %v", legacy, err)
+	}
+	l.Log("Migrated %s to secret store", name)
+	return data, nil
+}
+
+type encryptedFiles struct{}
+
+// EncryptedFiles returns a secret store that saves each secret to a separate
+// file in the barista config directory, encrypted using a key derived from the
+// encryption key set by SetEncryptionKey.
+func EncryptedFiles() SecretStore {
+	return encryptedFiles{}
+}
+
+func (encryptedFiles) filename(name string) string {
+	return filepath.Join(configDir, name+".json")
+}
+
+func (e encryptedFiles) Get(name string) ([]byte, error) {
+	data, err := readEncrypted(e.filename(name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (e encryptedFiles) Set(name string, secret []byte) error {
+	return writeEncrypted(e.filename(name), secret)
+}
+
+const (
+	secretsService    = "org.freedesktop.secrets"
+	secretsPath       = "/org/freedesktop/secrets"
+	secretsIface      = "org.freedesktop.Secret"
+	defaultCollection = "/org/freedesktop/secrets/aliases/default"
+)
+
+// Overridden in tests.
+var busType = dbus.Session
+
+type secretService struct{}
+
+// SecretService returns a secret store that uses the freedesktop secret
+// service API over DBus, as implemented by gnome-keyring (libsecret) and
+// KWallet. Secrets are saved to the default collection, which must be
+// unlocked, and can be viewed with tools like seahorse or secret-tool.
+func SecretService() SecretStore {
+	return secretService{}
+}
+
+// secretValue is the Secret struct from the secret service API.
+type secretValue struct {
+	Session     godbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+func secretAttributes(name string) map[string]string {
+	return map[string]string{"application": "barista", "name": name}
+}
+
+// session opens a secret service session, and calls fn with a watcher that
+// can be used to call methods on the service. Secrets are transferred
+// unencrypted, since the session bus is only accessible to the user.
+func (secretService) session(fn func(*dbus.SignalWatcher, godbus.ObjectPath) error) error {
+	w := dbus.WatchSignals(busType, secretsService, "")
+	defer w.Unsubscribe()
+	r, err := w.Call(secretsPath, secretsIface+".Service.OpenSession",
+		"plain", godbus.MakeVariant(""))
+	if err != nil {
+		return err
+	}
+	var output godbus.Variant
+	var session godbus.ObjectPath
+	if err := godbus.Store(r, &output, &session); err != nil {
+		return err
+	}
+	defer w.Call(session, secretsIface+".Session.Close")
+	return fn(w, session)
+}
+
+func (s secretService) Get(name string) (secret []byte, err error) {
+	err = s.session(func(w *dbus.SignalWatcher, session godbus.ObjectPath) error {
+		r, err := w.Call(secretsPath, secretsIface+".Service.SearchItems",
+			secretAttributes(name))
+		if err != nil {
+			return err
+		}
+		var unlocked, locked []godbus.ObjectPath
+		if err := godbus.Store(r, &unlocked, &locked); err != nil {
+			return err
+		}
+		if len(unlocked) == 0 {
+			if len(locked) > 0 {
+				return errors.New("secret service collection is locked")
+			}
+			return ErrNotFound
+		}
+		r, err = w.Call(unlocked[0], secretsIface+".Item.GetSecret", session)
+		if err != nil {
+			return err
+		}
+		var val secretValue
+		if err := godbus.Store(r, &val); err != nil {
+			return err
+		}
+		secret = val.Value
+		return nil
+	})
+	return secret, err
+}
+
+func (s secretService) Set(name string, secret []byte) error {
+	return s.session(func(w *dbus.SignalWatcher, session godbus.ObjectPath) error {
+		props := map[string]godbus.Variant{
+			secretsIface + ".Item.Label":      godbus.MakeVariant("barista: " + name),
+			secretsIface + ".Item.Attributes": godbus.MakeVariant(secretAttributes(name)),
+		}
+		val := secretValue{session, []byte{}, secret, "text/plain"}
+		r, err := w.Call(defaultCollection, secretsIface+".Collection.CreateItem",
+			props, val, true)
+		if err != nil {
+			return err
+		}
+		var item, prompt godbus.ObjectPath
+		if err := godbus.Store(r, &item, &prompt); err != nil {
+			return err
+		}
+		if prompt != "/" {
+			return errors.New("secret service collection is locked")
+		}
+		return nil
+	})
+}
+
+// For tests.
+var runCommand = func(stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if _, ok := err.(*exec.ExitError); ok && stderr.Len() > 0 {
+		err = errors.New(strings.TrimSpace(stderr.String()))
+	}
+	return out, err
+}
+
+type pass struct {
+	prefix string
+}
+
+// Pass returns a secret store that uses pass(1), the standard unix password
+// manager. Secrets are stored under the given prefix, e.g. "barista/".
+func Pass(prefix string) SecretStore {
+	return pass{prefix}
+}
+
+func (p pass) Get(name string) ([]byte, error) {
+	out, err := runCommand(nil, "pass", "show", p.prefix+name)
+	if err != nil && strings.Contains(err.Error(), "is not in the password store") {
+		return nil, ErrNotFound
+	}
+	return out, err
+}
+
+func (p pass) Set(name string, secret []byte) error {
+	_, err := runCommand(secret, "pass", "insert", "--multiline", "--force", p.prefix+name)
+	return err
+}
@@ -0,0 +1,230 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oauth
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"barista.run/base/watchers/dbus"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+type fakeStore struct {
+	sync.Mutex
+	secrets map[string][]byte
+	err     error
+}
+
+func (f *fakeStore) Get(name string) ([]byte, error) {
+	f.Lock()
+	defer f.Unlock()
+	s, ok := f.secrets[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s, nil
+}
+
+func (f *fakeStore) Set(name string, secret []byte) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.secrets[name] = secret
+	return nil
+}
+
+func TestEncryptedFilesStore(t *testing.T) {
+	resetForTest()
+	s := EncryptedFiles()
+
+	_, err := s.Get("foo")
+	require.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, s.Set("foo", []byte("bar")))
+	exists, _ := afero.Exists(fs, "/conf/dir/foo.json")
+	require.True(t, exists, "secret saved to file")
+	data, _ := afero.ReadFile(fs, "/conf/dir/foo.json")
+	require.NotContains(t, string(data), "bar", "secret is encrypted")
+
+	secret, err := s.Get("foo")
+	require.NoError(t, err)
+	require.Equal(t, []byte("bar"), secret)
+
+	require.NoError(t, SetSecret("api-key", "abcdef"))
+	key, err := Secret("api-key")
+	require.NoError(t, err)
+	require.Equal(t, "abcdef", key)
+}
+
+func TestMigration(t *testing.T) {
+	resetForTest()
+	conf := Register(&oauth2.Config{
+		Endpoint: testEndpoint,
+		ClientID: "ClientID",
+		Scopes:   []string{"a", "b"},
+	})
+	require.NoError(t, storeToken(configFile(), &oauth2.Token{AccessToken: "saved"}))
+
+	store := &fakeStore{secrets: map[string][]byte{}, err: errors.New("locked")}
+	SetSecretStore(store)
+	tok, err := conf.load()
+	require.NoError(t, err, "uses the legacy token if migration fails")
+	require.Equal(t, "saved", tok.AccessToken)
+	exists, _ := afero.Exists(fs, configFile())
+	require.True(t, exists, "legacy file kept if migration fails")
+
+	store.err = nil
+	tok, err = conf.load()
+	require.NoError(t, err)
+	require.Equal(t, "saved", tok.AccessToken)
+	exists, _ = afero.Exists(fs, configFile())
+	require.False(t, exists, "legacy file removed after migration")
+	require.Len(t, store.secrets, 1)
+
+	tok, err = conf.load()
+	require.NoError(t, err, "loads migrated token")
+	require.Equal(t, "saved", tok.AccessToken)
+
+	require.NoError(t, conf.save(&oauth2.Token{AccessToken: "new"}))
+	exists, _ = afero.Exists(fs, configFile())
+	require.False(t, exists, "new tokens not saved to files")
+	tok, _ = conf.load()
+	require.Equal(t, "new", tok.AccessToken)
+
+	_, err = Secret("nonexistent")
+	require.Equal(t, ErrNotFound, err)
+
+	resetKey(nil)
+	_, err = Secret("nonexistent")
+	require.Equal(t, ErrNotFound, err, "no migration without encryption key")
+}
+
+func TestPass(t *testing.T) {
+	var commands []string
+	var stored []byte
+	runCommand = func(stdin []byte, name string, args ...string) ([]byte, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		switch args[0] {
+		case "insert":
+			stored = stdin
+			return nil, nil
+		case "show":
+			if stored == nil {
+				return nil, errors.New("Error: barista/foo is not in the password store.")
+			}
+			return stored, nil
+		}
+		return nil, errors.New("unknown command")
+	}
+
+	s := Pass("barista/")
+	_, err := s.Get("foo")
+	require.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, s.Set("foo", []byte("secret")))
+	secret, err := s.Get("foo")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), secret)
+
+	require.Equal(t, []string{
+		"pass show barista/foo",
+		"pass insert --multiline --force barista/foo",
+		"pass show barista/foo",
+	}, commands)
+}
+
+func TestSecretService(t *testing.T) {
+	busType = dbus.Test
+	srv := dbus.SetupTestBus().RegisterService(secretsService)
+	var mu sync.Mutex
+	secrets := map[string][]byte{}
+	locked := false
+
+	session := godbus.ObjectPath("/org/freedesktop/secrets/session/1")
+	svc := srv.Object(secretsPath, secretsIface+".Service")
+	svc.On("OpenSession", func(args ...interface{}) ([]interface{}, error) {
+		require.Equal(t, "plain", args[0])
+		return []interface{}{godbus.MakeVariant(""), session}, nil
+	})
+	svc.On("SearchItems", func(args ...interface{}) ([]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		name := args[0].(map[string]string)["name"]
+		if _, ok := secrets[name]; !ok {
+			return []interface{}{[]godbus.ObjectPath{}, []godbus.ObjectPath{}}, nil
+		}
+		item := []godbus.ObjectPath{godbus.ObjectPath("/item/" + name)}
+		if locked {
+			return []interface{}{[]godbus.ObjectPath{}, item}, nil
+		}
+		return []interface{}{item, []godbus.ObjectPath{}}, nil
+	})
+	srv.Object(session, secretsIface+".Session").On("Close",
+		func(...interface{}) ([]interface{}, error) { return nil, nil })
+	srv.Object("/item/foo", secretsIface+".Item").On("GetSecret",
+		func(args ...interface{}) ([]interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			require.Equal(t, session, args[0])
+			return []interface{}{[]interface{}{
+				session, []byte{}, secrets["foo"], "text/plain",
+			}}, nil
+		})
+	srv.Object(defaultCollection, secretsIface+".Collection").On("CreateItem",
+		func(args ...interface{}) ([]interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			props := args[0].(map[string]godbus.Variant)
+			attrs := props[secretsIface+".Item.Attributes"].Value().(map[string]string)
+			require.Equal(t, "barista", attrs["application"])
+			require.Equal(t, true, args[2], "replaces existing secrets")
+			if locked {
+				return []interface{}{godbus.ObjectPath("/"), godbus.ObjectPath("/prompt/1")}, nil
+			}
+			val := args[1].(secretValue)
+			secrets[attrs["name"]] = val.Value
+			return []interface{}{godbus.ObjectPath("/item/" + attrs["name"]), godbus.ObjectPath("/")}, nil
+		})
+
+	s := SecretService()
+	_, err := s.Get("foo")
+	require.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, s.Set("foo", []byte("secret")))
+	secret, err := s.Get("foo")
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), secret)
+
+	mu.Lock()
+	locked = true
+	mu.Unlock()
+	_, err = s.Get("foo")
+	require.Error(t, err, "when locked")
+	require.NotEqual(t, ErrNotFound, err, "when locked")
+	require.Error(t, s.Set("foo", []byte("other")), "when locked")
+
+	srv.Unregister()
+	_, err = s.Get("foo")
+	require.Error(t, err, "when service is not running")
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package actions provides a declarative way to bind mouse buttons to
+// commands or functions on the segments of an output, for simple launcher
+// behaviour without writing a click handler. For example,
+// actions.OnLeftClick(actions.Exec("pavucontrol")).Bind(output) opens the
+// volume control when the output is clicked.
+package actions // import "barista.run/outputs/actions"
+
+import (
+	"os/exec"
+
+	"barista.run/bar"
+	"barista.run/base/click"
+	l "barista.run/logging"
+	"barista.run/outputs"
+)
+
+// Action is performed when a bound button is clicked.
+type Action func(bar.Event)
+
+// For tests.
+var runCommand = func(name string, args ...string) error {
+	return exec.Command(name, args...).Run()
+}
+
+// Exec returns an action that runs the given command, logging any errors.
+func Exec(cmd string, args ...string) Action {
+	return func(bar.Event) {
+		if err := runCommand(cmd, args...); err != nil {
+			l.Log("Error running %s: %v", cmd, err)
+		}
+	}
+}
+
+// Shell returns an action that runs the given command line using sh,
+// allowing the use of pipes, redirection, and other shell features.
+func Shell(cmdline string) Action {
+	return Exec("sh", "-c", cmdline)
+}
+
+// Do returns an action that calls the given function.
+func Do(fn func()) Action {
+	return func(bar.Event) { fn() }
+}
+
+// Bindings is a set of actions for mouse buttons.
+type Bindings struct {
+	handlers click.Map
+}
+
+// On creates bindings that perform the action when the given button is
+// clicked.
+func On(btn bar.Button, a Action) *Bindings {
+	return (&Bindings{click.Map{}}).On(btn, a)
+}
+
+// OnLeftClick creates bindings that perform the action on a left click.
+func OnLeftClick(a Action) *Bindings { return On(bar.ButtonLeft, a) }
+
+// OnRightClick creates bindings that perform the action on a right click.
+func OnRightClick(a Action) *Bindings { return On(bar.ButtonRight, a) }
+
+// OnMiddleClick creates bindings that perform the action on a middle click.
+func OnMiddleClick(a Action) *Bindings { return On(bar.ButtonMiddle, a) }
+
+// OnScrollUp creates bindings that perform the action on scrolling up.
+func OnScrollUp(a Action) *Bindings { return On(bar.ScrollUp, a) }
+
+// OnScrollDown creates bindings that perform the action on scrolling down.
+func OnScrollDown(a Action) *Bindings { return On(bar.ScrollDown, a) }
+
+// On adds an action for the given button, replacing any existing action.
+func (b *Bindings) On(btn bar.Button, a Action) *Bindings {
+	b.handlers.Set(btn, a)
+	return b
+}
+
+// OnLeftClick adds an action for left clicks.
+func (b *Bindings) OnLeftClick(a Action) *Bindings { return b.On(bar.ButtonLeft, a) }
+
+// OnRightClick adds an action for right clicks.
+func (b *Bindings) OnRightClick(a Action) *Bindings { return b.On(bar.ButtonRight, a) }
+
+// OnMiddleClick adds an action for middle clicks.
+func (b *Bindings) OnMiddleClick(a Action) *Bindings { return b.On(bar.ButtonMiddle, a) }
+
+// OnScrollUp adds an action for scrolling up.
+func (b *Bindings) OnScrollUp(a Action) *Bindings { return b.On(bar.ScrollUp, a) }
+
+// OnScrollDown adds an action for scrolling down.
+func (b *Bindings) OnScrollDown(a Action) *Bindings { return b.On(bar.ScrollDown, a) }
+
+// Handle performs the action bound to the button of the event, if any.
+// Button release events are ignored.
+func (b *Bindings) Handle(e bar.Event) {
+	if !e.Release {
+		b.handlers.Handle(e)
+	}
+}
+
+// Bind attaches the bindings to all segments of the output that do not
+// already have a click handler, so that different segments of an output can
+// be bound to different actions.
+func (b *Bindings) Bind(o bar.Output) *outputs.SegmentGroup {
+	return outputs.Group(o).OnClick(b.Handle)
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/outputs"
+	testOutput "barista.run/testing/output"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindings(t *testing.T) {
+	var commands []string
+	var err error
+	runCommand = func(name string, args ...string) error {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		return err
+	}
+	clicks := 0
+
+	out := outputs.Group(
+		OnLeftClick(Exec("pavucontrol")).
+			OnRightClick(Shell("pactl set-sink-mute @DEFAULT_SINK@ toggle")).
+			Bind(outputs.Text("vol")),
+		OnScrollUp(Do(func() { clicks++ })).Bind(outputs.Text("scroll")),
+		outputs.Text("custom").OnClick(func(bar.Event) { clicks += 10 }),
+	)
+	out = OnMiddleClick(Exec("ignored")).Bind(out)
+	segs := testOutput.New(t, out)
+
+	segs.At(0).LeftClick()
+	segs.At(0).Click(bar.Event{Button: bar.ButtonRight})
+	segs.At(0).Click(bar.Event{Button: bar.ButtonLeft, Release: true})
+	segs.At(0).Click(bar.Event{Button: bar.ScrollUp})
+	require.Equal(t, []string{
+		"pavucontrol",
+		"sh -c pactl set-sink-mute @DEFAULT_SINK@ toggle",
+	}, commands)
+	require.Equal(t, 0, clicks)
+
+	commands = nil
+	segs.At(1).Click(bar.Event{Button: bar.ScrollUp})
+	segs.At(1).LeftClick()
+	require.Equal(t, 1, clicks)
+	require.Empty(t, commands, "segments keep their own bindings")
+
+	segs.At(2).LeftClick()
+	require.Equal(t, 11, clicks, "existing handlers are not replaced")
+
+	err = errors.New("not found")
+	require.NotPanics(t, func() { segs.At(0).LeftClick() })
+	require.Equal(t, []string{"pavucontrol"}, commands)
+}
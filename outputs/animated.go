@@ -0,0 +1,67 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"math"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/timing"
+)
+
+// marqueeGap is the space shown between the end and start of scrolling text.
+const marqueeGap = "   "
+
+// Marquee displays text that is longer than width characters by scrolling
+// it one character every interval, showing width characters at a time. Text
+// that fits is displayed as is.
+func Marquee(text string, width int, interval time.Duration) bar.Output {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return Text(text)
+	}
+	loop := append(runes, []rune(marqueeGap)...)
+	start := timing.Now()
+	return Repeat(func(now time.Time) bar.Output {
+		offset := frame(start, now, interval) % len(loop)
+		visible := make([]rune, width)
+		for i := range visible {
+			visible[i] = loop[(offset+i)%len(loop)]
+		}
+		return Text(string(visible))
+	}).Every(interval)
+}
+
+// BlinkUrgent alternates the output between urgent and not urgent every
+// interval, to draw attention to it. Any urgent state set on the output
+// itself is replaced.
+func BlinkUrgent(out bar.Output, interval time.Duration) bar.Output {
+	start := timing.Now()
+	return Repeat(func(now time.Time) bar.Output {
+		urgent := frame(start, now, interval)%2 == 0
+		var segments bar.Segments
+		for _, s := range out.Segments() {
+			segments = append(segments, s.Clone().Urgent(urgent))
+		}
+		return segments
+	}).Every(interval)
+}
+
+// frame returns the number of intervals since start. The time is rounded,
+// since groups may align the start time of repeating outputs.
+func frame(start, now time.Time, interval time.Duration) int {
+	return int(math.Round(float64(now.Sub(start)) / float64(interval)))
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarquee(t *testing.T) {
+	timing.TestMode()
+
+	o := Marquee("short", 5, time.Second)
+	_, ok := o.(bar.TimedOutput)
+	require.False(t, ok, "text that fits is not animated")
+	txt, _ := o.Segments()[0].Content()
+	require.Equal(t, "short", txt)
+
+	m := Marquee("hello", 4, time.Second).(bar.TimedOutput)
+	assertCurrentTexts(t, m, []string{"hell"})
+	assertNextTexts(t, m, []string{"ello"})
+	assertNextTexts(t, m, []string{"llo "})
+	assertNextTexts(t, m, []string{"lo  "})
+	assertNextTexts(t, m, []string{"o   "})
+	assertNextTexts(t, m, []string{"   h"})
+	assertNextTexts(t, m, []string{"  he"})
+	assertNextTexts(t, m, []string{" hel"})
+	assertNextTexts(t, m, []string{"hell"}, "wraps around")
+
+	timing.AdvanceBy(1500 * time.Millisecond)
+	assertCurrentTexts(t, m, []string{"ello"}, "between ticks")
+}
+
+func TestBlinkUrgent(t *testing.T) {
+	timing.TestMode()
+
+	o := BlinkUrgent(Group(Text("a").Urgent(false), Text("b")), time.Second).(bar.TimedOutput)
+	assertUrgent := func(expected bool, msg string) {
+		segs := o.Segments()
+		require.Len(t, segs, 2)
+		for _, s := range segs {
+			urgent, _ := s.IsUrgent()
+			require.Equal(t, expected, urgent, msg)
+		}
+	}
+	assertUrgent(true, "initially urgent")
+	timing.AdvanceTo(o.NextRefresh())
+	assertUrgent(false, "after one interval")
+	timing.AdvanceTo(o.NextRefresh())
+	assertUrgent(true, "after two intervals")
+}
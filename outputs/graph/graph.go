@@ -0,0 +1,131 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graph provides helpers to render a short history of values as
+// text graphs, using unicode block characters or braille patterns.
+package graph // import "barista.run/outputs/graph"
+
+import (
+	"math"
+	"sync"
+)
+
+// History is a fixed-size buffer of the most recent values, which can be fed
+// by a module on each update and rendered as a graph. It is safe for
+// concurrent use.
+type History struct {
+	mu     sync.Mutex
+	values []float64
+	next   int
+	full   bool
+}
+
+// NewHistory creates a history that keeps the last size values.
+func NewHistory(size int) *History {
+	return &History{values: make([]float64, size)}
+}
+
+// Add adds a value to the history, replacing the oldest value if full.
+func (h *History) Add(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.values[h.next] = value
+	h.next = (h.next + 1) % len(h.values)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Values returns the values in the history, oldest first.
+func (h *History) Values() []float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]float64(nil), h.values[:h.next]...)
+	}
+	return append(append([]float64(nil), h.values[h.next:]...), h.values[:h.next]...)
+}
+
+var blocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline renders values as block characters, one per value, scaled
+// between min and max. If max is not greater than min, the values are scaled
+// between their own minimum and maximum.
+func Sparkline(values []float64, min, max float64) string {
+	min, max = scale(values, min, max)
+	out := make([]rune, len(values))
+	for i, v := range values {
+		out[i] = blocks[level(v, min, max, len(blocks)-1)]
+	}
+	return string(out)
+}
+
+// braille dots for each column, from bottom to top.
+var (
+	brailleLeft  = []rune{0x40, 0x04, 0x02, 0x01}
+	brailleRight = []rune{0x80, 0x20, 0x10, 0x08}
+)
+
+// Braille renders values as a bar graph of braille patterns, two values per
+// character, scaled between min and max. If max is not greater than min, the
+// values are scaled between their own minimum and maximum. Braille graphs
+// have a lower vertical resolution than sparklines, but are twice as dense.
+func Braille(values []float64, min, max float64) string {
+	min, max = scale(values, min, max)
+	if len(values)%2 != 0 {
+		// Keep the newest value in the last column by padding at the start.
+		values = append([]float64{math.NaN()}, values...)
+	}
+	out := make([]rune, len(values)/2)
+	for i := range out {
+		r := rune(0x2800)
+		for j := 0; j < level(values[2*i], min, max, 4); j++ {
+			r |= brailleLeft[j]
+		}
+		for j := 0; j < level(values[2*i+1], min, max, 4); j++ {
+			r |= brailleRight[j]
+		}
+		out[i] = r
+	}
+	return string(out)
+}
+
+// scale returns the range to use for the given values.
+func scale(values []float64, min, max float64) (float64, float64) {
+	if max > min {
+		return min, max
+	}
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	return min, max
+}
+
+// level scales a value to an integer between 0 and levels. NaN values, and
+// all values when the range is empty, are at level 0.
+func level(value, min, max float64, levels int) int {
+	if math.IsNaN(value) || !(max > min) {
+		return 0
+	}
+	l := int(math.Round((value - min) / (max - min) * float64(levels)))
+	if l < 0 {
+		return 0
+	}
+	if l > levels {
+		return levels
+	}
+	return l
+}
@@ -0,0 +1,62 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	h := NewHistory(3)
+	require.Empty(t, h.Values())
+
+	h.Add(1)
+	h.Add(2)
+	require.Equal(t, []float64{1, 2}, h.Values())
+
+	h.Add(3)
+	require.Equal(t, []float64{1, 2, 3}, h.Values())
+
+	h.Add(4)
+	h.Add(5)
+	require.Equal(t, []float64{3, 4, 5}, h.Values(), "oldest values dropped")
+
+	v := h.Values()
+	v[0] = 10
+	require.Equal(t, []float64{3, 4, 5}, h.Values(), "returns a copy")
+}
+
+func TestSparkline(t *testing.T) {
+	require.Equal(t, "", Sparkline(nil, 0, 100))
+	require.Equal(t, "▁▂▅▆█", Sparkline([]float64{0, 15, 50, 70, 100}, 0, 100))
+	require.Equal(t, "▁▁██", Sparkline([]float64{-10, 0, 100, 200}, 0, 100),
+		"values clamped to range")
+	require.Equal(t, "▁▅█", Sparkline([]float64{10, 15, 20}, 0, 0),
+		"auto-scaled")
+	require.Equal(t, "▁▁▁", Sparkline([]float64{5, 5, 5}, 0, 0),
+		"constant values")
+	require.Equal(t, "▁█", Sparkline([]float64{math.NaN(), 1}, 0, 1))
+}
+
+func TestBraille(t *testing.T) {
+	require.Equal(t, "", Braille(nil, 0, 100))
+	require.Equal(t, "⣀⣿", Braille([]float64{25, 25, 100, 100}, 0, 100))
+	require.Equal(t, "⢀⣴", Braille([]float64{25, 50, 75}, 0, 100),
+		"odd number of values padded at start")
+	require.Equal(t, "⢀⣿", Braille([]float64{1, 2, 4, 4}, 0, 0), "auto-scaled")
+}
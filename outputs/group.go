@@ -36,6 +36,7 @@ type SegmentGroup struct {
 	color          color.Color
 	background     color.Color
 	border         color.Color
+	borderWidths   [4]int
 	minWidth       int
 	align          bar.TextAlignment
 	urgent         bool
@@ -52,6 +53,7 @@ const (
 	sgaInnerPadding
 	sgaOuterSeparator
 	sgaOuterPadding
+	sgaBorderWidth
 )
 
 // OnClick sets the default click handler for the group. Any segments
@@ -79,6 +81,14 @@ func (g *SegmentGroup) Border(border color.Color) *SegmentGroup {
 	return g
 }
 
+// BorderWidth sets the width of the border on each side for all segments in
+// the group.
+func (g *SegmentGroup) BorderWidth(top, right, bottom, left int) *SegmentGroup {
+	g.attrSet |= sgaBorderWidth
+	g.borderWidths = [4]int{top, right, bottom, left}
+	return g
+}
+
 // Align sets the text alignment for all segments in the group.
 func (g *SegmentGroup) Align(align bar.TextAlignment) *SegmentGroup {
 	g.align = align
@@ -214,6 +224,10 @@ func (g *SegmentGroup) Segments() []*bar.Segment {
 		if !isSet(s.GetBorder()) && g.border != nil {
 			s.Border(g.border)
 		}
+		if _, _, _, _, ok := s.GetBorderWidth(); !ok && g.attrSet&sgaBorderWidth != 0 {
+			w := g.borderWidths
+			s.BorderWidth(w[0], w[1], w[2], w[3])
+		}
 		if !isSet(s.GetAlignment()) && g.align != "" {
 			s.Align(g.align)
 		}
@@ -70,6 +70,14 @@ func TestSegmentGroup(t *testing.T) {
 		func(s *bar.Segment) (interface{}, bool) { return s.GetBorder() },
 		"sets border for all segments")
 
+	out.BorderWidth(0, 0, 2, 0)
+	assertAllEqual([]int{0, 0, 2, 0},
+		func(s *bar.Segment) (interface{}, bool) {
+			top, right, bottom, left, isSet := s.GetBorderWidth()
+			return []int{top, right, bottom, left}, isSet
+		},
+		"sets border width for all segments")
+
 	out.Urgent(true)
 	assertAllEqual(true,
 		func(s *bar.Segment) (interface{}, bool) { return s.IsUrgent() },
@@ -0,0 +1,79 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"image/color"
+
+	"barista.run/bar"
+)
+
+// Separator glyphs for Powerline, available in fonts patched for powerline,
+// including Nerd Fonts.
+const (
+	PowerlineArrow     = "\ue0b2"
+	PowerlineThinArrow = "\ue0b3"
+	PowerlineRound     = "\ue0b6"
+	PowerlineThinRound = "\ue0b7"
+)
+
+// Powerline returns a layout for barista.SetLayout that inserts powerline
+// style separators before each segment, coloured using the backgrounds of the
+// segment and the segment before it. Segments with the same background are
+// separated by the thin separator if given, using the segment's colour.
+// All segments should set a background, which can be done for the entire bar
+// using barista.SetDefaults. The separators and padding of segments are
+// removed, since the powerline separators replace them.
+func Powerline(separator string, thinSeparator ...string) func([]*bar.Segment) []*bar.Segment {
+	thin := ""
+	if len(thinSeparator) > 0 {
+		thin = thinSeparator[0]
+	}
+	return func(segments []*bar.Segment) []*bar.Segment {
+		var out []*bar.Segment
+		var prevBg color.Color
+		for idx, s := range segments {
+			bg, _ := s.GetBackground()
+			var sep *bar.Segment
+			switch {
+			case idx > 0 && sameColor(bg, prevBg):
+				if thin != "" {
+					sep = bar.TextSegment(thin).Background(bg)
+					if fg, ok := s.GetColor(); ok {
+						sep.Color(fg)
+					}
+				}
+			case bg != nil:
+				sep = bar.TextSegment(separator).Color(bg).Background(prevBg)
+			}
+			if sep != nil {
+				out = append(out, sep.Separator(false).Padding(0))
+			}
+			out = append(out, s.Clone().Separator(false).Padding(0))
+			prevBg = bg
+		}
+		return out
+	}
+}
+
+// sameColor returns true if both colours are the same, or both are unset.
+func sameColor(a, b color.Color) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
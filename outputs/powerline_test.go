@@ -0,0 +1,88 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outputs
+
+import (
+	"image/color"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/colors"
+
+	"github.com/lucasb-eyer/go-colorful"
+	"github.com/stretchr/testify/require"
+)
+
+type plSegment struct{ text, fg, bg string }
+
+func hexOrEmpty(c color.Color, ok bool) string {
+	if !ok {
+		return ""
+	}
+	cful, _ := colorful.MakeColor(c)
+	return cful.Hex()
+}
+
+func summarise(t *testing.T, segments []*bar.Segment) []plSegment {
+	var out []plSegment
+	for _, s := range segments {
+		separator, _ := s.HasSeparator()
+		require.False(t, separator, "separators removed")
+		padding, _ := s.GetPadding()
+		require.Equal(t, 0, padding, "padding removed")
+		txt, _ := s.Content()
+		out = append(out, plSegment{txt, hexOrEmpty(s.GetColor()), hexOrEmpty(s.GetBackground())})
+	}
+	return out
+}
+
+func TestPowerline(t *testing.T) {
+	red, blue := colors.Hex("#ff0000"), colors.Hex("#0000ff")
+	white := colors.Hex("#ffffff")
+	original := []*bar.Segment{
+		bar.TextSegment("a").Background(red),
+		bar.TextSegment("b").Background(red).Color(white),
+		bar.TextSegment("c").Background(blue).Padding(10),
+		bar.TextSegment("d"),
+		bar.TextSegment("e").Background(red),
+	}
+
+	require.Equal(t, []plSegment{
+		{PowerlineArrow, "#ff0000", ""},
+		{"a", "", "#ff0000"},
+		{"b", "#ffffff", "#ff0000"},
+		{PowerlineArrow, "#0000ff", "#ff0000"},
+		{"c", "", "#0000ff"},
+		{"d", "", ""},
+		{PowerlineArrow, "#ff0000", ""},
+		{"e", "", "#ff0000"},
+	}, summarise(t, Powerline(PowerlineArrow)(original)))
+
+	require.Equal(t, []plSegment{
+		{PowerlineRound, "#ff0000", ""},
+		{"a", "", "#ff0000"},
+		{PowerlineThinRound, "#ffffff", "#ff0000"},
+		{"b", "#ffffff", "#ff0000"},
+		{PowerlineRound, "#0000ff", "#ff0000"},
+		{"c", "", "#0000ff"},
+		{"d", "", ""},
+		{PowerlineRound, "#ff0000", ""},
+		{"e", "", "#ff0000"},
+	}, summarise(t, Powerline(PowerlineRound, PowerlineThinRound)(original)))
+
+	padding, _ := original[2].GetPadding()
+	require.Equal(t, 10, padding, "original segments unchanged")
+	require.Empty(t, Powerline(PowerlineArrow)(nil))
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pango
+
+import (
+	"image/color"
+	"math"
+	"strings"
+
+	"barista.run/colors"
+)
+
+// partialBlocks are the block characters for 1/8 to 7/8 of a full block.
+var partialBlocks = []rune("▏▎▍▌▋▊▉")
+
+// Gauge renders a fraction between 0 and 1 as a gauge of block characters,
+// always width characters wide, with 1/8 character precision. If colours are
+// given, the gauge is coloured using a gradient between them, evenly spaced
+// from 0 to 1.
+func Gauge(fraction float64, width int, gradient ...color.Color) *Node {
+	eighths := int(math.Round(clamp(fraction) * float64(width) * 8))
+	var b strings.Builder
+	b.WriteString(strings.Repeat("█", eighths/8))
+	filled := eighths / 8
+	if rem := eighths % 8; rem > 0 {
+		b.WriteRune(partialBlocks[rem-1])
+		filled++
+	}
+	b.WriteString(strings.Repeat(" ", width-filled))
+	n := Text(b.String())
+	if c := gradientAt(gradient, fraction); c != nil {
+		n.Color(c)
+	}
+	return n
+}
+
+// GaugeText renders text with a background span covering the given fraction
+// of its characters, to use the text itself as a progress bar. If multiple
+// fill colours are given, the fill is coloured using a gradient between them,
+// evenly spaced from 0 to 1.
+func GaugeText(text string, fraction float64, fill ...color.Color) *Node {
+	runes := []rune(text)
+	filled := int(math.Round(clamp(fraction) * float64(len(runes))))
+	n := New()
+	if c := gradientAt(fill, fraction); c != nil && filled > 0 {
+		n.Append(Text(string(runes[:filled])).Background(c))
+	} else {
+		n.AppendText(string(runes[:filled]))
+	}
+	return n.AppendText(string(runes[filled:]))
+}
+
+func clamp(fraction float64) float64 {
+	return math.Max(0, math.Min(1, fraction))
+}
+
+// gradientAt returns the colour at the given fraction of a gradient between
+// evenly spaced colours, or nil if there are no colours.
+func gradientAt(gradient []color.Color, fraction float64) color.Color {
+	if c := colors.EvenGradient(gradient...).At(fraction); c != nil {
+		return c
+	}
+	return nil
+}
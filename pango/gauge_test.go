@@ -0,0 +1,67 @@
+// Copyright 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pango
+
+import (
+	"testing"
+
+	"barista.run/colors"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGauge(t *testing.T) {
+	for _, tc := range []struct {
+		fraction float64
+		width    int
+		expected string
+	}{
+		{0, 4, "    "},
+		{1, 4, "████"},
+		{0.5, 4, "██  "},
+		{0.5, 3, "█▌ "},
+		{0.3, 2, "▋ "},
+		{0.01, 5, "     "},
+		{0.02, 5, "▏    "},
+		{-1, 3, "   "},
+		{2, 3, "███"},
+	} {
+		require.Equal(t, tc.expected, Gauge(tc.fraction, tc.width).String(),
+			"Gauge(%v, %d)", tc.fraction, tc.width)
+	}
+
+	red, green := colors.Hex("#f00"), colors.Hex("#0f0")
+	require.Equal(t, "<span color='#ff0000'>    </span>",
+		Gauge(0, 4, red, green).String())
+	require.Equal(t, "<span color='#00ff00'>████</span>",
+		Gauge(1, 4, red, green).String())
+	require.Equal(t, "<span color='#ff0000'>██  </span>",
+		Gauge(0.5, 4, red).String(), "single colour")
+	mid := Gauge(0.5, 4, red, colors.Hex("#ff0"), green).String()
+	require.Equal(t, "<span color='#ffff00'>██  </span>", mid,
+		"colour stops are evenly spaced")
+}
+
+func TestGaugeText(t *testing.T) {
+	blue := colors.Hex("#00f")
+	require.Equal(t, "<span background='#0000ff'>ca</span>ts",
+		GaugeText("cats", 0.5, blue).String())
+	require.Equal(t, "cats", GaugeText("cats", 0.1, blue).String())
+	require.Equal(t, "<span background='#0000ff'>cats</span>",
+		GaugeText("cats", 1.5, blue).String())
+	require.Equal(t, "<span background='#0000ff'>ça</span>…",
+		GaugeText("ça…", 0.7, blue).String(), "counts characters")
+	require.Equal(t, "cats", GaugeText("cats", 0.25).String(), "no fill colour")
+}
@@ -24,6 +24,8 @@ Compatible icon fonts:
  - Material Design Icons (community fork)
  - FontAwesome
  - Typicons
+ - Nerd Fonts (built-in, no repository needed)
+ - Material Symbols
 
 Example usage:
   mdi.Load("/Users/me/Github/Templarian/MaterialDesign-Webfont")
@@ -41,8 +43,9 @@ import (
 
 // Provider provides pango nodes for icons
 type Provider struct {
-	symbols map[string]string
-	styles  []func(*pango.Node)
+	symbols  map[string]string
+	fallback func(string) (string, bool)
+	styles   []func(*pango.Node)
 }
 
 // NewProvider creates a new icon provider with the given name,
@@ -57,6 +60,9 @@ func NewProvider(name string) *Provider {
 // icon creates a pango node that renders the named icon.
 func (p *Provider) icon(name string) *pango.Node {
 	symbol, ok := p.symbols[name]
+	if !ok && p.fallback != nil {
+		symbol, ok = p.fallback(name)
+	}
 	if !ok {
 		return nil
 	}
@@ -84,6 +90,13 @@ func (p *Provider) Symbol(name, value string) {
 	p.symbols[name] = value
 }
 
+// Fallback sets a function that provides symbols for icons that were not
+// added to the provider, e.g. for fonts that use ligatures, where the icon's
+// name is rendered as the icon.
+func (p *Provider) Fallback(fallback func(name string) (string, bool)) {
+	p.fallback = fallback
+}
+
 // Font sets the font set on the returned pango nodes.
 func (p *Provider) Font(font string) {
 	p.AddStyle(func(n *pango.Node) { n.Font(font) })
@@ -101,5 +114,5 @@ func SymbolFromHex(hex string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return string(intVal), nil
+	return string(rune(intVal)), nil
 }
@@ -63,6 +63,18 @@ func TestIconProvider(t *testing.T) {
 		pangoTesting.AssertEqual(t, tc.expected, pango.Icon("test-"+tc.icon).String(), tc.desc)
 	}
 
+	p.Fallback(func(name string) (string, bool) {
+		return "~" + name, name != "missing"
+	})
+	pangoTesting.AssertEqual(t,
+		"<span fallback='false' face='testfont' weight='200'>~other</span>",
+		pango.Icon("test-other").String(), "fallback for unknown icon")
+	pangoTesting.AssertEqual(t,
+		"<span fallback='false' face='testfont' weight='200'>a</span>",
+		pango.Icon("test-test").String(), "symbols preferred over fallback")
+	pangoTesting.AssertEqual(t, "", pango.Icon("test-missing").String(),
+		"no output when fallback fails")
+
 	pangoTesting.AssertEqual(t,
 		"<span color='#ff0000'><span fallback='false' face='testfont' weight='200'>a</span></span>",
 		pango.Icon("test-test").Color(colors.Hex("#f00")).String(),
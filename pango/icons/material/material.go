@@ -0,0 +1,115 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package material provides support for Material Symbols from
+https://fonts.google.com/icons.
+
+Icons can be rendered using ligatures, where the font replaces the icon's
+name with the icon, which only requires the font to be installed. To only
+show icons that the font provides, the code points can be loaded from a
+clone of https://github.com/google/material-design-icons instead, which
+uses variablefont/*.codepoints to get the list of icons.
+
+Icons use the Material Symbols names, e.g. pango.Icon("material-battery_full").
+*/
+package material // import "barista.run/pango/icons/material"
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"barista.run/pango/icons"
+
+	"github.com/spf13/afero"
+)
+
+// Style represents one of the styles of Material Symbols, each of which is
+// a separate font.
+type Style string
+
+// Styles of Material Symbols.
+const (
+	Outlined Style = "Outlined"
+	Rounded  Style = "Rounded"
+	Sharp    Style = "Sharp"
+)
+
+var fs = afero.NewOsFs()
+
+// Ligatures initialises the material icon provider to render icons using
+// ligatures in the font for the given style. Any name is accepted, so
+// icons missing from the font are shown as their name.
+func Ligatures(style Style) {
+	newProvider(style).Fallback(func(name string) (string, bool) {
+		return name, isLigature(name)
+	})
+}
+
+func isLigature(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Load initialises the material icon provider for the given style from the
+// code points in the given repo.
+func Load(repoPath string, style Style) error {
+	f, err := fs.Open(filepath.Join(repoPath, "variablefont",
+		fmt.Sprintf("MaterialSymbols%s[FILL,GRAD,opsz,wght].codepoints", style)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	material := newProvider(style)
+	s := bufio.NewScanner(f)
+	s.Split(bufio.ScanLines)
+	loaded := false
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("Unexpected line '%s'", line)
+		}
+		if err := material.Hex(fields[0], fields[1]); err != nil {
+			return err
+		}
+		loaded = true
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if !loaded {
+		return errors.New("Could not find any icons in codepoints")
+	}
+	return nil
+}
+
+func newProvider(style Style) *icons.Provider {
+	material := icons.NewProvider("material")
+	material.Font(fmt.Sprintf("Material Symbols %s", style))
+	return material
+}
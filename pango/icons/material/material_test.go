@@ -0,0 +1,92 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package material
+
+import (
+	"testing"
+
+	"barista.run/pango"
+	"barista.run/testing/cron"
+	"barista.run/testing/githubfs"
+	pangoTesting "barista.run/testing/pango"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLigatures(t *testing.T) {
+	Ligatures(Rounded)
+	pangoTesting.AssertEqual(t,
+		"<span fallback='false' face='Material Symbols Rounded'>battery_full</span>",
+		pango.Icon("material-battery_full").String())
+	for _, invalid := range []string{"", "Home", "wifi-off", "a b", "<b>"} {
+		require.Empty(t, pango.Icon("material-"+invalid).String(),
+			"invalid ligature %q", invalid)
+	}
+}
+
+const codepoints = "/src/material/variablefont/" +
+	"MaterialSymbolsOutlined[FILL,GRAD,opsz,wght].codepoints"
+
+func TestInvalid(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	require.Error(t, Load("/src/no-such-directory", Outlined))
+
+	afero.WriteFile(fs, codepoints, nil, 0644)
+	require.Error(t, Load("/src/material", Outlined), "empty file")
+
+	afero.WriteFile(fs, codepoints, []byte("10k e951\nwifi\n"), 0644)
+	require.Error(t, Load("/src/material", Outlined), "missing code point")
+
+	afero.WriteFile(fs, codepoints, []byte("10k e951\nwifi food\n"), 0644)
+	require.Error(t, Load("/src/material", Outlined), "invalid code point")
+
+	require.Error(t, Load("/src/material", Sharp), "missing style")
+}
+
+func TestValid(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	afero.WriteFile(fs, codepoints, []byte("10k e951\n\nwifi e63e\n"), 0644)
+	require.NoError(t, Load("/src/material", Outlined))
+	pangoTesting.AssertEqual(t,
+		"<span fallback='false' face='Material Symbols Outlined'></span>",
+		pango.Icon("material-wifi").String())
+	pangoTesting.AssertText(t, "", pango.Icon("material-10k").String())
+	require.Empty(t, pango.Icon("material-home").String(),
+		"no ligature fallback for icons missing from the font")
+}
+
+// TestLive tests that current master branch of the icon font works with
+// this package, and that common icons are available as ligatures. This
+// test only runs when CI runs tests in 'cron' mode, which provides timely
+// notifications of incompatible changes while keeping default tests
+// hermetic.
+func TestLive(t *testing.T) {
+	fs = githubfs.New()
+	cron.Test(t, func() error {
+		for _, style := range []Style{Outlined, Rounded, Sharp} {
+			if err := Load("/google/material-design-icons/master", style); err != nil {
+				return err
+			}
+			for _, name := range []string{
+				"battery_full", "calendar_today", "volume_up", "wifi",
+			} {
+				require.NotEmpty(t, pango.Icon("material-"+name).String(),
+					"Icon %s is missing from %s", name, style)
+			}
+		}
+		return nil
+	})
+}
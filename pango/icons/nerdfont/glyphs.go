@@ -0,0 +1,140 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nerdfont
+
+// glyphs maps the names of commonly used icons to their code points, as listed
+// in glyphnames.json. TestLive verifies that these are still correct.
+var glyphs = map[string]string{
+	// Font Awesome.
+	"fa-arrow_down":             "f063",
+	"fa-arrow_up":               "f062",
+	"fa-battery_empty":          "f244",
+	"fa-battery_full":           "f240",
+	"fa-battery_half":           "f242",
+	"fa-battery_quarter":        "f243",
+	"fa-battery_three_quarters": "f241",
+	"fa-bell":                   "f0f3",
+	"fa-bell_slash":             "f1f6",
+	"fa-bluetooth":              "f293",
+	"fa-bolt":                   "f0e7",
+	"fa-calendar":               "f073",
+	"fa-check":                  "f00c",
+	"fa-clock_o":                "f017",
+	"fa-cloud":                  "f0c2",
+	"fa-code":                   "f121",
+	"fa-desktop":                "f108",
+	"fa-download":               "f019",
+	"fa-envelope":               "f0e0",
+	"fa-gear":                   "f013",
+	"fa-github":                 "f09b",
+	"fa-globe":                  "f0ac",
+	"fa-hdd_o":                  "f0a0",
+	"fa-home":                   "f015",
+	"fa-keyboard_o":             "f11c",
+	"fa-laptop":                 "f109",
+	"fa-linux":                  "f17c",
+	"fa-lock":                   "f023",
+	"fa-microchip":              "f2db",
+	"fa-microphone":             "f130",
+	"fa-microphone_slash":       "f131",
+	"fa-moon_o":                 "f186",
+	"fa-music":                  "f001",
+	"fa-pause":                  "f04c",
+	"fa-play":                   "f04b",
+	"fa-plug":                   "f1e6",
+	"fa-power_off":              "f011",
+	"fa-refresh":                "f021",
+	"fa-search":                 "f002",
+	"fa-signal":                 "f012",
+	"fa-step_backward":          "f048",
+	"fa-step_forward":           "f051",
+	"fa-stop":                   "f04d",
+	"fa-sun_o":                  "f185",
+	"fa-terminal":               "f120",
+	"fa-thermometer_half":       "f2c9",
+	"fa-times":                  "f00d",
+	"fa-unlock":                 "f09c",
+	"fa-upload":                 "f093",
+	"fa-user":                   "f007",
+	"fa-volume_down":            "f027",
+	"fa-volume_off":             "f026",
+	"fa-volume_up":              "f028",
+	"fa-warning":                "f071",
+	"fa-wifi":                   "f1eb",
+
+	// Material Design Icons.
+	"md-battery":          "f0079",
+	"md-battery_charging": "f0084",
+	"md-bell":             "f009a",
+	"md-bell_off":         "f009b",
+	"md-bluetooth":        "f00af",
+	"md-calendar":         "f00ed",
+	"md-clock_outline":    "f0150",
+	"md-cpu_64_bit":       "f0ee0",
+	"md-ethernet":         "f0200",
+	"md-harddisk":         "f02ca",
+	"md-lock":             "f033e",
+	"md-memory":           "f035b",
+	"md-microphone":       "f036c",
+	"md-microphone_off":   "f036d",
+	"md-power":            "f0425",
+	"md-thermometer":      "f050f",
+	"md-volume_high":      "f057e",
+	"md-volume_low":       "f057d",
+	"md-volume_medium":    "f057f",
+	"md-volume_off":       "f0581",
+	"md-weather_night":    "f0594",
+	"md-weather_sunny":    "f0599",
+	"md-wifi":             "f05a9",
+	"md-wifi_off":         "f05aa",
+
+	// Octicons.
+	"oct-git_branch":  "f418",
+	"oct-mark_github": "f408",
+
+	// Distribution logos.
+	"linux-archlinux": "f303",
+	"linux-debian":    "f306",
+	"linux-fedora":    "f30a",
+	"linux-tux":       "f31a",
+	"linux-ubuntu":    "f31b",
+
+	// Weather Icons.
+	"weather-cloudy":       "e312",
+	"weather-day_sunny":    "e30d",
+	"weather-fog":          "e313",
+	"weather-night_clear":  "e32b",
+	"weather-rain":         "e318",
+	"weather-snow":         "e31a",
+	"weather-thunderstorm": "e31d",
+
+	// Pomicons.
+	"pom-away":               "e007",
+	"pom-long_pause":         "e006",
+	"pom-pomodoro_done":      "e001",
+	"pom-pomodoro_estimated": "e002",
+	"pom-pomodoro_squashed":  "e004",
+	"pom-pomodoro_ticking":   "e003",
+	"pom-short_pause":        "e005",
+
+	// Powerline symbols.
+	"pl-branch":                   "e0a0",
+	"pl-left_hard_divider":        "e0b0",
+	"pl-left_soft_divider":        "e0b1",
+	"pl-right_hard_divider":       "e0b2",
+	"pl-right_soft_divider":       "e0b3",
+	"ple-left_half_circle_thick":  "e0b6",
+	"ple-right_half_circle_thick": "e0b4",
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package nerdfont provides support for Nerd Fonts from https://www.nerdfonts.com/,
+either using a patched font or the "Symbols Nerd Font".
+
+It has a built-in table of commonly used icons, so it does not need a clone of
+the repository. The full set of icons can be loaded from glyphnames.json in
+https://github.com/ryanoasis/nerd-fonts instead.
+
+Icons use the Nerd Fonts class names, e.g. pango.Icon("nf-fa-battery_half")
+or pango.Icon("nf-md-wifi").
+*/
+package nerdfont // import "barista.run/pango/icons/nerdfont"
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+
+	"barista.run/pango/icons"
+
+	"github.com/spf13/afero"
+)
+
+var fs = afero.NewOsFs()
+
+// Load initialises the nerd fonts icon provider from the built-in table of
+// icons. The font is the font family to use for icons, e.g. "Symbols Nerd
+// Font", or "" to use the bar's font, which is sufficient for patched fonts.
+func Load(font string) {
+	nf := newProvider(font)
+	for name, code := range glyphs {
+		// The built-in table is verified in tests.
+		nf.Hex(name, code)
+	}
+}
+
+type glyph struct {
+	Code string `json:"code"`
+}
+
+// LoadFile initialises the nerd fonts icon provider from glyphnames.json in
+// the given repo, providing all icons supported by Nerd Fonts.
+func LoadFile(repoPath, font string) error {
+	f, err := fs.Open(filepath.Join(repoPath, "glyphnames.json"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var glyphs map[string]json.RawMessage
+	if err := json.NewDecoder(f).Decode(&glyphs); err != nil {
+		return err
+	}
+	delete(glyphs, "METADATA")
+	if len(glyphs) == 0 {
+		return errors.New("Could not find any icons in glyphnames.json")
+	}
+	nf := newProvider(font)
+	for name, raw := range glyphs {
+		var g glyph
+		if err := json.Unmarshal(raw, &g); err != nil {
+			return err
+		}
+		if err := nf.Hex(name, g.Code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newProvider(font string) *icons.Provider {
+	nf := icons.NewProvider("nf")
+	if font != "" {
+		nf.Font(font)
+	}
+	return nf
+}
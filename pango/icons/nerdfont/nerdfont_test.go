@@ -0,0 +1,118 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nerdfont
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"barista.run/pango"
+	"barista.run/testing/cron"
+	"barista.run/testing/githubfs"
+	pangoTesting "barista.run/testing/pango"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltin(t *testing.T) {
+	for name, code := range glyphs {
+		r, err := strconv.ParseUint(code, 16, 32)
+		require.NoError(t, err, "code point of %s", name)
+		require.True(t, unicode.Is(unicode.Co, rune(r)),
+			"%s (%s) is in a private use area", name, code)
+		require.Equal(t, strings.ToLower(code), code)
+	}
+
+	Load("")
+	pangoTesting.AssertText(t, "", pango.Icon("nf-fa-battery_half").String())
+	pangoTesting.AssertEqual(t, "<span fallback='false'>\U000f05a9</span>",
+		pango.Icon("nf-md-wifi").String())
+	require.Empty(t, pango.Icon("nf-md-no_such_icon").String())
+
+	Load("Symbols Nerd Font")
+	pangoTesting.AssertEqual(t,
+		"<span fallback='false' face='Symbols Nerd Font'>\U000f05a9</span>",
+		pango.Icon("nf-md-wifi").String())
+}
+
+func TestLoadFile(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	require.Error(t, LoadFile("/src/no-such-directory", ""))
+
+	afero.WriteFile(fs, "/src/nf-error-1/glyphnames.json", []byte(`[]`), 0644)
+	require.Error(t, LoadFile("/src/nf-error-1", ""))
+
+	afero.WriteFile(fs, "/src/nf-error-2/glyphnames.json", []byte(
+		`{"METADATA": {"version": "3.0.0"}}`), 0644)
+	require.Error(t, LoadFile("/src/nf-error-2", ""))
+
+	afero.WriteFile(fs, "/src/nf-error-3/glyphnames.json", []byte(
+		`{"cod-account": {"char": "", "code": "food"}}`), 0644)
+	require.Error(t, LoadFile("/src/nf-error-3", ""))
+
+	afero.WriteFile(fs, "/src/nf-error-4/glyphnames.json", []byte(
+		`{"cod-account": "eb99"}`), 0644)
+	require.Error(t, LoadFile("/src/nf-error-4", ""))
+
+	afero.WriteFile(fs, "/src/nf/glyphnames.json", []byte(`{
+		"METADATA": {"website": "https://nerdfonts.com", "version": "3.0.0"},
+		"cod-account": {"char": "", "code": "eb99"},
+		"custom-vim": {"char": "", "code": "e62b"}
+	}`), 0644)
+	require.NoError(t, LoadFile("/src/nf", "Hack Nerd Font"))
+	pangoTesting.AssertEqual(t,
+		"<span fallback='false' face='Hack Nerd Font'></span>",
+		pango.Icon("nf-cod-account").String())
+	pangoTesting.AssertText(t, "", pango.Icon("nf-custom-vim").String())
+	require.Empty(t, pango.Icon("nf-fa-wifi").String(), "replaces built-in icons")
+}
+
+// TestLive tests that the built-in table matches the current master branch
+// of Nerd Fonts, and that the full set of icons can be loaded. This test
+// only runs when CI runs tests in 'cron' mode, which provides timely
+// notifications of incompatible changes while keeping default tests
+// hermetic.
+func TestLive(t *testing.T) {
+	fs = githubfs.New()
+	cron.Test(t, func() error {
+		const repo = "/ryanoasis/nerd-fonts/master"
+		f, err := fs.Open(repo + "/glyphnames.json")
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		var upstream map[string]struct{ Code string }
+		if err := json.NewDecoder(f).Decode(&upstream); err != nil {
+			return err
+		}
+		for name, code := range glyphs {
+			g, ok := upstream[name]
+			require.True(t, ok, "Built-in glyph %s is missing upstream", name)
+			require.Equal(t, code, g.Code,
+				fmt.Sprintf("Built-in glyph %s has the wrong code point", name))
+		}
+		if err := LoadFile(repo, ""); err != nil {
+			return err
+		}
+		require.NotEmpty(t, pango.Icon("nf-fa-github").String(),
+			"Expected icon was not loaded")
+		return nil
+	})
+}
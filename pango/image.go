@@ -0,0 +1,58 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pango
+
+import (
+	"image"
+	"image/color"
+
+	"barista.run/base/images"
+)
+
+// Image renders a small image as text, for bars that cannot display images.
+// The image is scaled to two pixels high, and each pair of pixels in a column
+// is drawn using a half block character with foreground and background
+// colours. Transparent pixels are left blank.
+func Image(img image.Image) *Node {
+	img = images.Scale(img, 2)
+	b := img.Bounds()
+	n := New()
+	for x := b.Min.X; x < b.Max.X; x++ {
+		top, topOk := opaque(img.At(x, b.Min.Y))
+		bottom, bottomOk := opaque(img.At(x, b.Max.Y-1))
+		switch {
+		case topOk && bottomOk:
+			n.Append(Text("▀").Color(top).Background(bottom))
+		case topOk:
+			n.Append(Text("▀").Color(top))
+		case bottomOk:
+			n.Append(Text("▄").Color(bottom))
+		default:
+			n.AppendText(" ")
+		}
+	}
+	return n
+}
+
+// opaque returns the colour of a pixel without transparency, and false if
+// the pixel is mostly transparent.
+func opaque(c color.Color) (color.Color, bool) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A < 0x80 {
+		return nil, false
+	}
+	n.A = 0xff
+	return n, true
+}
@@ -0,0 +1,42 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pango
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	pangoTesting "barista.run/testing/pango"
+)
+
+func TestImage(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	blue := color.NRGBA{0, 0, 0xff, 0xff}
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, red)
+	img.Set(0, 1, blue)
+	img.Set(1, 0, color.NRGBA{0xff, 0, 0, 0xa0})
+	img.Set(2, 1, blue)
+
+	pangoTesting.AssertEqual(t,
+		"<span color='#ff0000' background='#0000ff'>▀</span>"+
+			"<span color='#ff0000'>▀</span>"+
+			"<span color='#0000ff'>▄</span> ",
+		Image(img).String())
+
+	pangoTesting.AssertText(t, "▀▀▀▀", Image(img.SubImage(image.Rect(0, 0, 2, 1))).String(),
+		"scaled to two pixels high")
+}
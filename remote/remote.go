@@ -0,0 +1,310 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remote serves modules over a unix socket, so that they can be
+// displayed by a bar in a different process using modules/remote. This
+// allows heavy modules (e.g. weather, mail) to run in a long-lived daemon,
+// keeping their state across bar restarts.
+//
+// The protocol uses one JSON message per line. The client sends the name of
+// the module it wants, then the server sends the module's output whenever it
+// changes, and the client sends click events for the segments of the last
+// output.
+package remote // import "barista.run/remote"
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"net"
+	"os"
+	"sync"
+
+	"barista.run/bar"
+	"barista.run/base/notifier"
+	"barista.run/colors"
+	"barista.run/core"
+	l "barista.run/logging"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Request is sent by the client to subscribe to a module, or to send a
+// click event for a segment of the last output.
+type Request struct {
+	Module  string     `json:"module,omitempty"`
+	Segment int        `json:"segment,omitempty"`
+	Event   *bar.Event `json:"event,omitempty"`
+}
+
+// Response is sent by the server with the current output of a module, or
+// an error if the module could not be streamed.
+type Response struct {
+	Segments []Segment `json:"segments"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Segment is the serialised form of a bar.Segment. Click handlers are
+// replaced by a flag, since clicks are sent back to the server.
+type Segment struct {
+	Text         string      `json:"text"`
+	Pango        bool        `json:"pango,omitempty"`
+	ShortText    *string     `json:"short_text,omitempty"`
+	Error        string      `json:"error,omitempty"`
+	Color        string      `json:"color,omitempty"`
+	Background   string      `json:"background,omitempty"`
+	Border       string      `json:"border,omitempty"`
+	BorderWidths *[4]int     `json:"border_widths,omitempty"`
+	MinWidth     interface{} `json:"min_width,omitempty"`
+	Align        string      `json:"align,omitempty"`
+	Urgent       *bool       `json:"urgent,omitempty"`
+	Separator    *bool       `json:"separator,omitempty"`
+	Padding      *int        `json:"padding,omitempty"`
+	Clickable    bool        `json:"clickable,omitempty"`
+}
+
+func colorString(c color.Color, ok bool) string {
+	if !ok {
+		return ""
+	}
+	cful, _ := colorful.MakeColor(c)
+	return cful.Hex()
+}
+
+// FromSegment serialises a bar.Segment.
+func FromSegment(s *bar.Segment) Segment {
+	var r Segment
+	r.Text, r.Pango = s.Content()
+	if shortText, ok := s.GetShortText(); ok {
+		r.ShortText = &shortText
+	}
+	if err := s.GetError(); err != nil {
+		r.Error = err.Error()
+	}
+	r.Color = colorString(s.GetColor())
+	r.Background = colorString(s.GetBackground())
+	r.Border = colorString(s.GetBorder())
+	if t, rt, b, lt, ok := s.GetBorderWidth(); ok {
+		r.BorderWidths = &[4]int{t, rt, b, lt}
+	}
+	r.MinWidth, _ = s.GetMinWidth()
+	if align, ok := s.GetAlignment(); ok {
+		r.Align = string(align)
+	}
+	if urgent, ok := s.IsUrgent(); ok {
+		r.Urgent = &urgent
+	}
+	if separator, ok := s.HasSeparator(); ok {
+		r.Separator = &separator
+	}
+	if padding, ok := s.GetPadding(); ok {
+		r.Padding = &padding
+	}
+	r.Clickable = s.HasClick()
+	return r
+}
+
+// ToSegment constructs a bar.Segment from its serialised form. Click
+// handlers must be added separately.
+func (r Segment) ToSegment() *bar.Segment {
+	var s *bar.Segment
+	if r.Pango {
+		s = bar.PangoSegment(r.Text)
+	} else {
+		s = bar.TextSegment(r.Text)
+	}
+	if r.ShortText != nil {
+		s.ShortText(*r.ShortText)
+	}
+	if r.Error != "" {
+		s.Error(errors.New(r.Error))
+	}
+	if c := colors.Hex(r.Color); c != nil {
+		s.Color(c)
+	}
+	if c := colors.Hex(r.Background); c != nil {
+		s.Background(c)
+	}
+	if c := colors.Hex(r.Border); c != nil {
+		s.Border(c)
+	}
+	if w := r.BorderWidths; w != nil {
+		s.BorderWidth(w[0], w[1], w[2], w[3])
+	}
+	switch w := r.MinWidth.(type) {
+	case int:
+		s.MinWidth(w)
+	case float64: // From JSON.
+		s.MinWidth(int(w))
+	case string:
+		s.MinWidthPlaceholder(w)
+	}
+	if r.Align != "" {
+		s.Align(bar.TextAlignment(r.Align))
+	}
+	if r.Urgent != nil {
+		s.Urgent(*r.Urgent)
+	}
+	if r.Separator != nil {
+		s.Separator(*r.Separator)
+	}
+	if r.Padding != nil {
+		s.Padding(*r.Padding)
+	}
+	return s
+}
+
+// Server serves modules to remote bars.
+type Server struct {
+	mu      sync.Mutex
+	names   []string
+	modules []bar.Module
+	started bool
+
+	moduleSet *core.ModuleSet
+	updates   []*notifier.Source
+}
+
+// NewServer constructs a server with no modules.
+func NewServer() *Server {
+	return &Server{}
+}
+
+// Add adds a module that can be displayed by remote bars using the given
+// name. Modules are started when the server starts serving, and run until
+// the process exits. Must be called before Serve.
+func (s *Server) Add(name string, module bar.Module) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		panic("Cannot add modules after Serve()")
+	}
+	s.names = append(s.names, name)
+	s.modules = append(s.modules, module)
+	return s
+}
+
+func (s *Server) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	s.moduleSet = core.NewModuleSet(s.modules)
+	for range s.modules {
+		s.updates = append(s.updates, new(notifier.Source))
+	}
+	go func(updates <-chan int) {
+		for idx := range updates {
+			s.updates[idx].Notify()
+		}
+	}(s.moduleSet.Stream())
+}
+
+// ListenAndServe serves the modules on a unix socket at the given path,
+// replacing any existing socket.
+func (s *Server) ListenAndServe(path string) error {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve serves the modules to connections from the given listener.
+func (s *Server) Serve(listener net.Listener) error {
+	s.start()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) index(name string) int {
+	for i, n := range s.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	enc := json.NewEncoder(conn)
+	requests := bufio.NewScanner(conn)
+	var req Request
+	if !requests.Scan() {
+		return
+	}
+	if err := json.Unmarshal(requests.Bytes(), &req); err != nil {
+		enc.Encode(Response{Error: err.Error()})
+		return
+	}
+	idx := s.index(req.Module)
+	if idx < 0 {
+		enc.Encode(Response{Error: fmt.Sprintf("unknown module %q", req.Module)})
+		return
+	}
+	l.Fine("%s: client connected to %s", l.ID(s), req.Module)
+
+	updates, done := s.updates[idx].Subscribe()
+	defer done()
+
+	var lastMu sync.Mutex
+	var last bar.Segments
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for requests.Scan() {
+			var req Request
+			if json.Unmarshal(requests.Bytes(), &req) != nil || req.Event == nil {
+				continue
+			}
+			lastMu.Lock()
+			if req.Segment >= 0 && req.Segment < len(last) {
+				go last[req.Segment].Click(*req.Event)
+			}
+			lastMu.Unlock()
+		}
+	}()
+
+	for {
+		out := s.moduleSet.LastOutput(idx)
+		lastMu.Lock()
+		last = out
+		lastMu.Unlock()
+		resp := Response{Segments: []Segment{}}
+		for _, seg := range out {
+			resp.Segments = append(resp.Segments, FromSegment(seg))
+		}
+		if enc.Encode(resp) != nil {
+			return
+		}
+		select {
+		case <-updates:
+		case <-closed:
+			return
+		}
+	}
+}
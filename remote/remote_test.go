@@ -0,0 +1,83 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"barista.run/bar"
+	"barista.run/colors"
+	"barista.run/modules/static"
+	"barista.run/outputs"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentRoundTrip(t *testing.T) {
+	for _, s := range []*bar.Segment{
+		outputs.Text("plain"),
+		outputs.Pango("<b>bold</b>").ShortText("b"),
+		outputs.Error(errors.New("oops")),
+		outputs.Text("styled").
+			Color(colors.Hex("#ff0000")).
+			Background(colors.Hex("#00ff00")).
+			Border(colors.Hex("#0000ff")).
+			BorderWidth(1, 2, 3, 4).
+			MinWidth(100).
+			Align(bar.AlignCenter).
+			Urgent(true).
+			Separator(false).
+			Padding(3),
+		outputs.Text("placeholder").MinWidthPlaceholder("00:00"),
+	} {
+		expected := FromSegment(s)
+		data, err := json.Marshal(expected)
+		require.NoError(t, err)
+		var decoded Segment
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		actual := decoded.ToSegment()
+		require.Equal(t, expected, FromSegment(actual))
+		txt, pango := actual.Content()
+		expectedTxt, expectedPango := s.Content()
+		require.Equal(t, expectedTxt, txt)
+		require.Equal(t, expectedPango, pango)
+	}
+
+	require.False(t, FromSegment(outputs.Text("a")).Clickable)
+	require.True(t, FromSegment(outputs.Text("a").OnClick(nil)).Clickable)
+}
+
+func TestAddAfterServe(t *testing.T) {
+	s := NewServer().Add("a", static.New(outputs.Text("a")))
+	s.start()
+	require.Panics(t, func() { s.Add("b", nil) })
+}
+
+func TestListenKeepsFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.go")
+	require.NoError(t, ioutil.WriteFile(path, []byte("package main"), 0644))
+
+	s := NewServer().Add("a", static.New(outputs.Text("a")))
+	require.Error(t, s.ListenAndServe(path), "path is not a socket")
+	require.FileExists(t, path, "existing file is not removed")
+}
@@ -114,7 +114,7 @@ func main() {
 		out := outputs.Textf("%s %d%% %s",
 			statusName[b.Status],
 			b.RemainingPct(),
-			b.RemainingTime())
+			format.ShortDuration(b.RemainingTime()))
 		if b.Discharging() {
 			if b.RemainingPct() < 20 || b.RemainingTime() < 30*time.Minute {
 				out.Color(colors.Scheme("bad"))
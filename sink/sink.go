@@ -16,8 +16,11 @@
 package sink // import "barista.run/sink"
 
 import (
+	"sync/atomic"
+
 	"barista.run/bar"
 	"barista.run/base/value"
+	"barista.run/timing"
 )
 
 // Func creates a bar.Sink that sends sends output in the form of Segments.
@@ -65,3 +68,73 @@ func Value() (*value.Value, bar.Sink) {
 	val.Set(bar.Segments(nil))
 	return val, sink
 }
+
+// Map creates a sink that transforms each output using the given functions,
+// in order, before sending it to the original sink. A nil output is sent
+// unchanged.
+func Map(s bar.Sink, fns ...func(bar.Output) bar.Output) bar.Sink {
+	return func(o bar.Output) {
+		if o != nil {
+			for _, fn := range fns {
+				o = fn(o)
+			}
+		}
+		s(o)
+	}
+}
+
+// Wrap wraps a module so that each output is transformed using the given
+// functions, e.g. to add an icon or override colours without changing the
+// module's own output function. Refresh, Pause, and Resume are passed through
+// to the wrapped module if it supports them, and pausing also pauses the
+// schedulers it owns (see timing.Scheduler.OwnedBy).
+func Wrap(m bar.Module, fns ...func(bar.Output) bar.Output) bar.Module {
+	w := &wrapped{module: m, fns: fns}
+	if _, ok := m.(bar.RefresherModule); ok {
+		return refresherWrapped{w}
+	}
+	return w
+}
+
+type wrapped struct {
+	module bar.Module
+	fns    []func(bar.Output) bar.Output
+	paused int32 // atomic
+}
+
+func (w *wrapped) Stream(s bar.Sink) {
+	// Owners are released when the module stops, so schedulers owned by the
+	// wrapped module need to be paused again if it is restarted while paused.
+	if atomic.LoadInt32(&w.paused) == 1 {
+		timing.PauseOwner(w.module)
+	}
+	defer timing.ReleaseOwner(w.module)
+	w.module.Stream(Map(s, w.fns...))
+}
+
+func (w *wrapped) Pause() {
+	atomic.StoreInt32(&w.paused, 1)
+	timing.PauseOwner(w.module)
+	if p, ok := w.module.(bar.PausableModule); ok {
+		p.Pause()
+	}
+}
+
+func (w *wrapped) Resume() {
+	atomic.StoreInt32(&w.paused, 0)
+	timing.ResumeOwner(w.module)
+	if p, ok := w.module.(bar.PausableModule); ok {
+		p.Resume()
+	}
+}
+
+// refresherWrapped is a wrapped module that also supports refreshing, which
+// is kept separate since core.Module only adds refresh handlers to modules
+// that implement bar.RefresherModule.
+type refresherWrapped struct {
+	*wrapped
+}
+
+func (w refresherWrapped) Refresh() {
+	w.module.(bar.RefresherModule).Refresh()
+}
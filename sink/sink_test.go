@@ -20,7 +20,10 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/colors"
 	"barista.run/outputs"
+	"barista.run/testing/notifier"
+	"barista.run/timing"
 
 	"github.com/stretchr/testify/require"
 )
@@ -142,3 +145,70 @@ func TestValueSink(t *testing.T) {
 	out = v.Get().(bar.Segments)
 	require.Nil(t, out, "nil output")
 }
+
+type streamModule struct {
+	outputs   []bar.Output
+	refreshed bool
+}
+
+func (s *streamModule) Stream(sink bar.Sink) {
+	for _, o := range s.outputs {
+		sink.Output(o)
+	}
+}
+
+type refreshModule struct{ *streamModule }
+
+func (r refreshModule) Refresh() { r.refreshed = true }
+
+func TestWrap(t *testing.T) {
+	prefix := func(o bar.Output) bar.Output {
+		return outputs.Group(outputs.Text(">"), o)
+	}
+	color := func(o bar.Output) bar.Output {
+		var out bar.Segments
+		for _, s := range o.Segments() {
+			out = append(out, s.Clone().Color(colors.Hex("#f00")))
+		}
+		return out
+	}
+	original := &streamModule{outputs: []bar.Output{
+		outputs.Text("foo"), nil, outputs.Text("bar").Color(colors.Hex("#00f")),
+	}}
+	ch, s := Buffered(3)
+	m := Wrap(original, prefix, color)
+	m.Stream(s)
+
+	out := <-ch
+	require.Equal(t, 2, len(out))
+	txt, _ := out[1].Content()
+	require.Equal(t, "foo", txt)
+	for _, seg := range out {
+		c, _ := seg.GetColor()
+		require.Equal(t, colors.Hex("#f00"), c, "functions applied in order")
+	}
+
+	require.Nil(t, <-ch, "nil output passed through")
+
+	out = <-ch
+	c, _ := out[1].GetColor()
+	require.Equal(t, colors.Hex("#f00"), c, "module color overridden")
+
+	_, ok := m.(bar.RefresherModule)
+	require.False(t, ok, "not refreshable if wrapped module is not")
+	require.NotPanics(t, m.(bar.PausableModule).Pause)
+
+	timing.TestMode()
+	sch := timing.NewScheduler().Every(time.Minute).OwnedBy(original)
+	m = Wrap(original)
+	m.(bar.PausableModule).Pause()
+	timing.AdvanceBy(time.Minute)
+	notifier.AssertNoUpdate(t, sch.C, "wrapped module's schedulers paused")
+	m.(bar.PausableModule).Resume()
+	notifier.AssertNotified(t, sch.C, "wrapped module's schedulers resumed")
+
+	r := refreshModule{&streamModule{}}
+	m = Wrap(r)
+	m.(bar.RefresherModule).Refresh()
+	require.True(t, r.refreshed, "refresh passed through")
+}
@@ -0,0 +1,275 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package state provides a small key/value store that modules can use to
+// persist state across bar restarts, such as timer progress or the page
+// shown by a group.
+//
+// Each namespace is stored as a JSON object in its own file, under
+// $XDG_STATE_HOME/barista (~/.local/state/barista by default). Files are
+// replaced atomically on each write, so a crash while saving does not lose
+// the previous state. State that changes often, e.g. on every click, can be
+// saved using SetLater, which coalesces writes.
+//
+// The github and gmail modules do not use the store, since they only show the
+// current notification counts, and do not track which notifications have
+// been seen.
+package state // import "barista.run/state"
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	l "barista.run/logging"
+)
+
+// Store is a namespaced key/value store. Values are encoded as JSON.
+type Store struct {
+	path string
+}
+
+var (
+	// mu serialises all reads and writes, so that concurrent updates of
+	// different keys in the same file do not overwrite each other.
+	mu  sync.Mutex
+	dir = defaultDir()
+	// In test mode, files are kept in memory instead of on disk.
+	testFiles map[string][]byte
+	// pending holds values saved using SetLater that have not been written
+	// yet, keyed by path and then by key.
+	pending = map[string]map[string]json.RawMessage{}
+)
+
+// SaveDelay is the delay before values saved using SetLater are written.
+var SaveDelay = 2 * time.Second
+
+// defaultDir returns an XDG compliant directory for saving state.
+func defaultDir() string {
+	stateRoot := os.ExpandEnv("$HOME/.local/state")
+	if xdgState, ok := os.LookupEnv("XDG_STATE_HOME"); ok {
+		stateRoot = xdgState
+	}
+	return filepath.Join(stateRoot, "barista")
+}
+
+// SetDir sets the directory used for namespaces opened after this call. It
+// should be called before creating any modules that persist state.
+func SetDir(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	dir = path
+}
+
+// TestMode keeps all state in memory instead of on disk, and discards any
+// state from previous tests. State is shared by all stores for the same
+// namespace, so restarting a module in a test restores its state.
+func TestMode() {
+	mu.Lock()
+	defer mu.Unlock()
+	testFiles = map[string][]byte{}
+	pending = map[string]map[string]json.RawMessage{}
+}
+
+// Open returns the store for the given namespace, which should be unique to
+// a module or instance, e.g. "timer" or "group.cycling".
+func Open(namespace string) *Store {
+	mu.Lock()
+	defer mu.Unlock()
+	return &Store{filepath.Join(dir, namespace+".json")}
+}
+
+// OpenFile returns a store that saves state to the given file.
+func OpenFile(path string) *Store {
+	return &Store{path}
+}
+
+// Get decodes the value for the given key into v, and returns true if the
+// key was found.
+func (s *Store) Get(key string, v interface{}) (bool, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	values, err := s.readPending()
+	if err != nil {
+		return false, err
+	}
+	data, ok := values[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// Set saves the value for the given key, replacing any existing value.
+func (s *Store) Set(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.update(func(values map[string]json.RawMessage) {
+		values[key] = data
+	})
+}
+
+// SetLater saves the value for the given key after SaveDelay, so that values
+// that change often (e.g. on every click) are written at most once per delay.
+// The new value is returned by Get immediately. Errors are logged, since
+// they are only known once the value is written.
+func (s *Store) SetLater(key string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		l.Log("Failed to encode %s in %s: %v", key, s.path, err)
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	values, ok := pending[s.path]
+	if !ok {
+		values = map[string]json.RawMessage{}
+		pending[s.path] = values
+		time.AfterFunc(SaveDelay, s.flush)
+	}
+	values[key] = data
+}
+
+// Flush writes all values saved using SetLater that have not been written yet.
+func Flush() {
+	mu.Lock()
+	var paths []string
+	for path := range pending {
+		paths = append(paths, path)
+	}
+	mu.Unlock()
+	for _, path := range paths {
+		(&Store{path}).flush()
+	}
+}
+
+func (s *Store) flush() {
+	if err := s.update(func(map[string]json.RawMessage) {}); err != nil {
+		l.Log("Failed to save %s: %v", s.path, err)
+	}
+}
+
+// Delete removes the given key.
+func (s *Store) Delete(key string) error {
+	return s.update(func(values map[string]json.RawMessage) {
+		delete(values, key)
+	})
+}
+
+// Keys returns all keys in the store, in sorted order.
+func (s *Store) Keys() ([]string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	values, err := s.readPending()
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// update applies fn to the values in the store, including any pending
+// values, and writes them.
+func (s *Store) update(fn func(map[string]json.RawMessage)) error {
+	mu.Lock()
+	defer mu.Unlock()
+	pendingValues := pending[s.path]
+	delete(pending, s.path)
+	values, err := s.read()
+	if err != nil {
+		// Replace an invalid file rather than failing forever.
+		if _, ok := err.(*os.PathError); ok {
+			return err
+		}
+		values = map[string]json.RawMessage{}
+	}
+	for k, v := range pendingValues {
+		values[k] = v
+	}
+	fn(values)
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.write(data)
+}
+
+// read returns the values in the store. Must be called with mu held.
+func (s *Store) read() (map[string]json.RawMessage, error) {
+	var data []byte
+	var err error
+	if testFiles != nil {
+		data = testFiles[s.path]
+	} else {
+		data, err = ioutil.ReadFile(s.path)
+	}
+	values := map[string]json.RawMessage{}
+	if os.IsNotExist(err) || (err == nil && len(data) == 0) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return values, json.Unmarshal(data, &values)
+}
+
+// readPending returns the values in the store, including any values saved
+// using SetLater that have not been written yet. Must be called with mu held.
+func (s *Store) readPending() (map[string]json.RawMessage, error) {
+	values, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range pending[s.path] {
+		values[k] = v
+	}
+	return values, nil
+}
+
+// write atomically replaces the store's file. Must be called with mu held.
+func (s *Store) write(data []byte) error {
+	if testFiles != nil {
+		testFiles[s.path] = data
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
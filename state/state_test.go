@@ -0,0 +1,164 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type position struct {
+	Page  int    `json:"page"`
+	Label string `json:"label"`
+}
+
+func testStore(t *testing.T, s *Store) {
+	var p position
+	ok, err := s.Get("group", &p)
+	require.NoError(t, err)
+	require.False(t, ok, "missing key")
+
+	require.NoError(t, s.Set("group", position{3, "three"}))
+	require.NoError(t, s.Set("count", 42))
+
+	ok, err = s.Get("group", &p)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, position{3, "three"}, p)
+
+	keys, err := s.Keys()
+	require.NoError(t, err)
+	require.Equal(t, []string{"count", "group"}, keys)
+
+	require.NoError(t, s.Delete("group"))
+	keys, err = s.Keys()
+	require.NoError(t, err)
+	require.Equal(t, []string{"count"}, keys)
+
+	var count int
+	ok, err = s.Get("count", &count)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 42, count)
+
+	_, err = s.Get("count", &p)
+	require.Error(t, err, "wrong type")
+	require.Error(t, s.Set("bad", func() {}), "unencodable value")
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	SetDir(filepath.Join(dir, "nested"))
+	testFiles = nil
+
+	testStore(t, Open("test"))
+
+	var count int
+	ok, err := Open("test").Get("count", &count)
+	require.NoError(t, err)
+	require.True(t, ok, "persisted across instances")
+	require.Equal(t, 42, count)
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "nested"))
+	require.NoError(t, err)
+	require.Len(t, files, 1, "temporary files removed")
+	require.Equal(t, "test.json", files[0].Name())
+	require.Equal(t, os.FileMode(0600), files[0].Mode().Perm())
+
+	path := filepath.Join(dir, "invalid.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"count":`), 0600))
+	s := OpenFile(path)
+	_, err = s.Get("count", &count)
+	require.Error(t, err, "invalid file")
+	require.NoError(t, s.Set("count", 1), "invalid file is replaced")
+	ok, err = s.Get("count", &count)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 1, count)
+
+	require.NoError(t, os.Chmod(dir, 0500))
+	defer os.Chmod(dir, 0700)
+	if os.Getuid() != 0 {
+		require.Error(t, OpenFile(filepath.Join(dir, "readonly.json")).Set("a", 1))
+	}
+}
+
+func TestTestMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	SetDir(dir)
+	TestMode()
+
+	testStore(t, Open("test"))
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files, "nothing written to disk")
+
+	var count int
+	ok, _ := Open("test").Get("count", &count)
+	require.True(t, ok, "shared by stores for the same namespace")
+	ok, _ = Open("other").Get("count", &count)
+	require.False(t, ok)
+
+	TestMode()
+	ok, _ = Open("test").Get("count", &count)
+	require.False(t, ok, "discarded on TestMode")
+}
+
+func TestSetLater(t *testing.T) {
+	TestMode()
+	defer func(d time.Duration) { SaveDelay = d }(SaveDelay)
+	SaveDelay = time.Hour
+
+	s := Open("later")
+	for i := 1; i <= 3; i++ {
+		s.SetLater("count", i)
+	}
+	var count int
+	ok, err := s.Get("count", &count)
+	require.NoError(t, err)
+	require.True(t, ok, "pending value is returned")
+	require.Equal(t, 3, count)
+	keys, _ := s.Keys()
+	require.Equal(t, []string{"count"}, keys)
+	require.Empty(t, testFiles, "not written before delay")
+
+	require.NoError(t, s.Set("other", 1))
+	require.Contains(t, string(testFiles[s.path]), `"count": 3`,
+		"pending values written with other values")
+
+	s.SetLater("count", 4)
+	s.SetLater("bad", func() {})
+	Flush()
+	require.Contains(t, string(testFiles[s.path]), `"count": 4`, "on flush")
+	require.NotContains(t, string(testFiles[s.path]), "bad")
+
+	SaveDelay = time.Millisecond
+	s.SetLater("count", 5)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Contains(string(testFiles[s.path]), `"count": 5`)
+	}, time.Second, time.Millisecond, "written after delay")
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// Stats contains statistics about the bar, for monitoring the bar itself.
+type Stats struct {
+	// Goroutines is the number of goroutines that currently exist.
+	Goroutines int
+	// HeapAlloc is the number of bytes of allocated heap objects.
+	HeapAlloc uint64
+	// Renders is the number of times the bar has been printed.
+	Renders int
+	// RenderTime is the total time spent printing the bar.
+	RenderTime time.Duration
+	// Modules contains statistics for each module, in the order they were
+	// added to the bar.
+	Modules []ModuleStats
+}
+
+// ModuleStats contains statistics about the updates from a module.
+type ModuleStats struct {
+	// Name is the name given to AddNamed, or the type of the module.
+	Name string
+	// Updates is the number of times the module has updated its output.
+	Updates int
+	// Errors is the number of updates that included an error segment.
+	Errors int
+	// LastUpdate is the time of the most recent update.
+	LastUpdate time.Time
+}
+
+// GetStats returns the current statistics of the bar. Module statistics are
+// only available after Run.
+func GetStats() Stats {
+	construct()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	b := instance
+	b.Lock()
+	defer b.Unlock()
+	s := Stats{
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  mem.HeapAlloc,
+		Renders:    b.renders,
+		RenderTime: b.renderTime,
+	}
+	if b.moduleSet == nil {
+		return s
+	}
+	names := map[int]string{}
+	for name, idx := range b.names {
+		names[idx] = name
+	}
+	for idx, m := range b.moduleSet.Stats() {
+		name, ok := names[idx]
+		if !ok {
+			name = fmt.Sprintf("%T", b.modules[idx])
+		}
+		s.Modules = append(s.Modules, ModuleStats{
+			Name:       name,
+			Updates:    m.Updates,
+			Errors:     m.Errors,
+			LastUpdate: m.LastUpdate,
+		})
+	}
+	return s
+}
+
+// recordRender adds the time since start to the render statistics.
+func (b *i3Bar) recordRender(start time.Time) {
+	elapsed := time.Since(start)
+	b.Lock()
+	defer b.Unlock()
+	b.renders++
+	b.renderTime += elapsed
+}
@@ -0,0 +1,62 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package barista
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"barista.run/outputs"
+	"barista.run/testing/mockio"
+	testModule "barista.run/testing/module"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	mockStdin := mockio.Stdin()
+	mockStdout := mockio.Stdout()
+	TestMode(mockStdin, mockStdout)
+
+	s := GetStats()
+	require.Empty(t, s.Modules, "before Run")
+	require.NotZero(t, s.Goroutines)
+	require.NotZero(t, s.HeapAlloc)
+
+	named := testModule.New(t)
+	other := testModule.New(t)
+	AddNamed("named", named)
+	go Run(other)
+	mockStdout.ReadUntil('[', time.Second)
+
+	named.AssertStarted()
+	named.OutputText("a")
+	readOutput(t, mockStdout)
+	named.Output(outputs.Error(errors.New("oops")))
+	readOutput(t, mockStdout)
+
+	require.Eventually(t, func() bool { return GetStats().Renders == 2 },
+		time.Second, time.Millisecond, "renders counted")
+	s = GetStats()
+	require.Len(t, s.Modules, 2)
+	require.Equal(t, "named", s.Modules[0].Name)
+	require.Equal(t, 2, s.Modules[0].Updates)
+	require.Equal(t, 1, s.Modules[0].Errors)
+	require.False(t, s.Modules[0].LastUpdate.IsZero())
+	require.Equal(t, "*module.TestModule", s.Modules[1].Name,
+		"unnamed modules use the type")
+	require.Zero(t, s.Modules[1].Updates)
+}
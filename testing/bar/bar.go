@@ -22,7 +22,9 @@ import (
 	"time"
 
 	"barista.run/bar"
+	"barista.run/base/watchers/connectivity"
 	"barista.run/core"
+	"barista.run/i18n"
 	l "barista.run/logging"
 	"barista.run/oauth"
 	"barista.run/testing/output"
@@ -52,6 +54,8 @@ func New(t require.TestingT) {
 	}
 	instance.Store(b)
 	timing.TestMode()
+	connectivity.SetForTest(connectivity.Online)
+	i18n.SetLanguage("")
 	encryptionKeySet.Do(func() {
 		oauth.SetEncryptionKey([]byte(`not-an-encryption-key`))
 	})
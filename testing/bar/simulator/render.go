@@ -0,0 +1,351 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Colours used by i3bar when the bar does not specify one.
+const (
+	defaultBackground = "#000000"
+	defaultColor      = "#ffffff"
+	defaultSeparator  = "#666666"
+	urgentBackground  = "#900000"
+	urgentBorder      = "#2f343a"
+)
+
+// parseMarkup calls the given functions for each start tag, end tag, and
+// text node in pango markup. It returns false if the markup is malformed.
+func parseMarkup(markup string, start func(xml.StartElement), end func(string), text func(string)) bool {
+	d := xml.NewDecoder(strings.NewReader("<markup>" + markup + "</markup>"))
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth > 0 {
+				start(t)
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			if depth > 0 {
+				end(t.Name.Local)
+			}
+		case xml.CharData:
+			text(string(t))
+		}
+	}
+}
+
+func stripMarkup(markup string) string {
+	var out strings.Builder
+	if !parseMarkup(markup,
+		func(xml.StartElement) {},
+		func(string) {},
+		func(t string) { out.WriteString(t) }) {
+		return markup
+	}
+	return out.String()
+}
+
+// htmlTags maps pango convenience tags to their html equivalents.
+var htmlTags = map[string]string{
+	"b": "b", "i": "i", "u": "u", "s": "s",
+	"sub": "sub", "sup": "sup", "small": "small", "tt": "code",
+}
+
+// spanStyle converts the attributes of a pango span to css.
+func spanStyle(attrs []xml.Attr) string {
+	var style []string
+	for _, a := range attrs {
+		v := a.Value
+		switch a.Name.Local {
+		case "foreground", "fgcolor", "color":
+			style = append(style, "color:"+v)
+		case "background", "bgcolor":
+			style = append(style, "background-color:"+v)
+		case "weight":
+			if v == "heavy" || v == "ultrabold" {
+				v = "bolder"
+			}
+			style = append(style, "font-weight:"+v)
+		case "style":
+			style = append(style, "font-style:"+v)
+		case "font_family", "face":
+			style = append(style, "font-family:"+v)
+		case "size", "font_size":
+			if n, err := strconv.Atoi(v); err == nil {
+				v = fmt.Sprintf("%gpt", float64(n)/1024.0)
+			}
+			style = append(style, "font-size:"+v)
+		case "underline":
+			if v != "none" {
+				style = append(style, "text-decoration:underline")
+			}
+		case "strikethrough":
+			if v == "true" {
+				style = append(style, "text-decoration:line-through")
+			}
+		}
+	}
+	return strings.Join(style, ";")
+}
+
+// markupHTML converts pango markup to html.
+func markupHTML(markup string) string {
+	var out strings.Builder
+	var closing []string
+	ok := parseMarkup(markup,
+		func(e xml.StartElement) {
+			tag, ok := htmlTags[e.Name.Local]
+			switch {
+			case ok:
+				fmt.Fprintf(&out, "<%s>", tag)
+			case e.Name.Local == "big":
+				tag = "span"
+				out.WriteString(`<span style="font-size:larger">`)
+			default:
+				tag = "span"
+				fmt.Fprintf(&out, `<span style="%s">`, html.EscapeString(spanStyle(e.Attr)))
+			}
+			closing = append(closing, tag)
+		},
+		func(string) {
+			fmt.Fprintf(&out, "</%s>", closing[len(closing)-1])
+			closing = closing[:len(closing)-1]
+		},
+		func(t string) { out.WriteString(html.EscapeString(t)) })
+	if !ok {
+		return html.EscapeString(markup)
+	}
+	return out.String()
+}
+
+// colorOr returns the colour if set, otherwise the default.
+func colorOr(c, def string) string {
+	if c == "" {
+		return def
+	}
+	return c
+}
+
+func (s Segment) separatorWidth() int {
+	if s.SeparatorBlockWidth != nil {
+		return *s.SeparatorBlockWidth
+	}
+	return 9
+}
+
+func (s Segment) hasSeparator() bool {
+	return s.Separator == nil || *s.Separator
+}
+
+func (s Segment) borderWidths() (top, right, bottom, left int) {
+	width := func(w *int) int {
+		if w == nil {
+			return 1
+		}
+		return *w
+	}
+	return width(s.BorderTop), width(s.BorderRight),
+		width(s.BorderBottom), width(s.BorderLeft)
+}
+
+// HTML renders the segments to a self-contained html snippet that
+// approximates their appearance on i3bar.
+func HTML(segments []Segment) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, `<div class="barista" style="display:flex;align-items:stretch;`+
+		`white-space:pre;font-family:monospace;background:%s;color:%s;padding:2px">`,
+		defaultBackground, defaultColor)
+	for i, s := range segments {
+		style := []string{"color:" + colorOr(s.Color, defaultColor), "padding:0 2px"}
+		bg, border := s.Background, s.Border
+		if s.Urgent {
+			bg, border = urgentBackground, urgentBorder
+		}
+		if bg != "" {
+			style = append(style, "background:"+bg)
+		}
+		if border != "" {
+			t, r, b, l := s.borderWidths()
+			style = append(style, fmt.Sprintf(
+				"border-style:solid;border-color:%s;border-width:%dpx %dpx %dpx %dpx",
+				border, t, r, b, l))
+		}
+		switch w := s.MinWidth.(type) {
+		case float64:
+			style = append(style, fmt.Sprintf("min-width:%gpx", w))
+		case string:
+			style = append(style, fmt.Sprintf("min-width:%dch", len([]rune(w))))
+		}
+		if s.Align != "" {
+			style = append(style, "text-align:"+s.Align)
+		}
+		content := html.EscapeString(s.FullText)
+		if s.Markup == "pango" {
+			content = markupHTML(s.FullText)
+		}
+		fmt.Fprintf(&out, `<span style="%s">%s</span>`, strings.Join(style, ";"), content)
+		if i == len(segments)-1 {
+			break
+		}
+		sep := fmt.Sprintf("width:%dpx", s.separatorWidth())
+		if s.hasSeparator() && s.separatorWidth() > 0 {
+			w := s.separatorWidth()
+			sep = fmt.Sprintf("width:%dpx;margin-right:%dpx;border-right:1px solid %s",
+				w/2, w-w/2-1, defaultSeparator)
+		}
+		fmt.Fprintf(&out, `<span style="%s"></span>`, sep)
+	}
+	out.WriteString("</div>")
+	return out.String()
+}
+
+// Scale of the bitmap font used for png previews.
+const (
+	scale      = 2
+	glyphW     = 6 * scale
+	glyphH     = 8 * scale
+	padding    = 4 * scale
+	barHeight  = glyphH + 2*padding
+	textMargin = 2 * scale
+)
+
+func parseColor(c string) color.Color {
+	col, err := colorful.Hex(c)
+	if err != nil {
+		return color.White
+	}
+	return col
+}
+
+// Image renders the segments to an image that approximates their appearance
+// on i3bar, using a small bitmap font. Pango markup is rendered as plain text,
+// and non-ascii characters are rendered as boxes.
+func Image(segments []Segment) *image.RGBA {
+	width := 0
+	for i, s := range segments {
+		width += s.blockWidth()
+		if i < len(segments)-1 {
+			width += s.separatorWidth() * scale
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, barHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(parseColor(defaultBackground)),
+		image.Point{}, draw.Src)
+	x := 0
+	for i, s := range segments {
+		w := s.blockWidth()
+		block := image.Rect(x, 0, x+w, barHeight)
+		bg, border := s.Background, s.Border
+		if s.Urgent {
+			bg, border = urgentBackground, urgentBorder
+		}
+		if border != "" {
+			draw.Draw(img, block, image.NewUniform(parseColor(border)), image.Point{}, draw.Src)
+			t, r, b, l := s.borderWidths()
+			block = image.Rect(x+l*scale, t*scale, x+w-r*scale, barHeight-b*scale)
+			draw.Draw(img, block, image.NewUniform(parseColor(colorOr(bg, defaultBackground))),
+				image.Point{}, draw.Src)
+		} else if bg != "" {
+			draw.Draw(img, block, image.NewUniform(parseColor(bg)), image.Point{}, draw.Src)
+		}
+		text := []rune(s.Text())
+		textX := x + textMargin
+		switch s.Align {
+		case "center":
+			textX = x + (w-len(text)*glyphW)/2
+		case "right":
+			textX = x + w - textMargin - len(text)*glyphW
+		}
+		fg := parseColor(colorOr(s.Color, defaultColor))
+		for j, r := range text {
+			drawGlyph(img, textX+j*glyphW, padding, r, fg)
+		}
+		x += w
+		if i == len(segments)-1 {
+			break
+		}
+		sepW := s.separatorWidth() * scale
+		if s.hasSeparator() && sepW > 0 {
+			line := image.Rect(x+sepW/2, padding/2, x+sepW/2+scale/2, barHeight-padding/2)
+			draw.Draw(img, line, image.NewUniform(parseColor(defaultSeparator)),
+				image.Point{}, draw.Src)
+		}
+		x += sepW
+	}
+	return img
+}
+
+// PNG renders the segments as a png image, see Image.
+func PNG(w io.Writer, segments []Segment) error {
+	return png.Encode(w, Image(segments))
+}
+
+// blockWidth returns the width of a segment's block in the rendered image.
+func (s Segment) blockWidth() int {
+	w := len([]rune(s.Text()))*glyphW + 2*textMargin
+	switch minWidth := s.MinWidth.(type) {
+	case float64:
+		if m := int(minWidth) * scale; m > w {
+			w = m
+		}
+	case string:
+		if m := len([]rune(minWidth))*glyphW + 2*textMargin; m > w {
+			w = m
+		}
+	}
+	return w
+}
+
+// drawGlyph draws a single character of the bitmap font.
+func drawGlyph(img *image.RGBA, x, y int, r rune, c color.Color) {
+	if r == ' ' {
+		return
+	}
+	glyph := glyphBox
+	if r > ' ' && r <= '~' {
+		glyph = font[r-' ']
+	}
+	for col, bits := range glyph {
+		for row := 0; row < 8; row++ {
+			if bits&(1<<uint(row)) == 0 {
+				continue
+			}
+			px := image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale)
+			draw.Draw(img, px, image.NewUniform(c), image.Point{}, draw.Src)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package simulator runs a complete barista instance against in-memory
+// streams, acting as i3bar: it reads the bar's output, sends click events, and
+// sends the stop and continue signals. It is intended for integration tests of
+// complete bars, and for rendering previews of a bar for documentation.
+//
+// Because barista only supports a single bar per process, only one simulator
+// can be running at a time.
+package simulator // import "barista.run/testing/bar/simulator"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"barista.run"
+	"barista.run/bar"
+	"barista.run/timing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// Header is the header sent by the bar when it starts.
+type Header struct {
+	Version     int  `json:"version"`
+	StopSignal  int  `json:"stop_signal"`
+	ContSignal  int  `json:"cont_signal"`
+	ClickEvents bool `json:"click_events"`
+}
+
+// Segment is a single block of the bar's output, as received by i3bar.
+type Segment struct {
+	Name                string      `json:"name"`
+	FullText            string      `json:"full_text"`
+	ShortText           string      `json:"short_text"`
+	Markup              string      `json:"markup"`
+	Color               string      `json:"color"`
+	Background          string      `json:"background"`
+	Border              string      `json:"border"`
+	BorderTop           *int        `json:"border_top"`
+	BorderRight         *int        `json:"border_right"`
+	BorderBottom        *int        `json:"border_bottom"`
+	BorderLeft          *int        `json:"border_left"`
+	MinWidth            interface{} `json:"min_width"`
+	Align               string      `json:"align"`
+	Urgent              bool        `json:"urgent"`
+	Separator           *bool       `json:"separator"`
+	SeparatorBlockWidth *int        `json:"separator_block_width"`
+}
+
+// Text returns the text of the segment, with any pango markup removed.
+func (s Segment) Text() string {
+	if s.Markup != "pango" {
+		return s.FullText
+	}
+	return stripMarkup(s.FullText)
+}
+
+// Texts returns the text of each segment, with any pango markup removed.
+func Texts(segments []Segment) []string {
+	texts := make([]string, len(segments))
+	for i, s := range segments {
+		texts[i] = s.Text()
+	}
+	return texts
+}
+
+// Time to wait for output that is expected, and to wait to make sure that no
+// output is received when none is expected.
+var (
+	positiveTimeout = 10 * time.Second
+	negativeTimeout = 10 * time.Millisecond
+)
+
+// Simulator is a running bar, with methods to interact with it the way i3bar
+// would.
+type Simulator struct {
+	t      *testing.T
+	header Header
+
+	stdin       *io.PipeWriter
+	stdout      *io.PipeReader
+	barStdout   *io.PipeWriter
+	sentEvent   bool
+	done        chan error
+	outputReady chan struct{}
+
+	mu      sync.Mutex
+	pending [][]Segment
+	latest  []Segment
+}
+
+// New creates a new bar for the simulator. The bar can be configured using
+// the functions in package barista before calling Run.
+func New(t *testing.T) *Simulator {
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	s := &Simulator{
+		t:           t,
+		stdin:       stdinW,
+		stdout:      stdoutR,
+		barStdout:   stdoutW,
+		done:        make(chan error, 1),
+		outputReady: make(chan struct{}, 1),
+	}
+	barista.TestMode(stdinR, stdoutW)
+	return s
+}
+
+// Run starts the bar with the given modules, and waits for its header.
+func (s *Simulator) Run(modules ...bar.Module) {
+	go func() {
+		err := barista.Run(modules...)
+		s.barStdout.CloseWithError(err)
+		s.done <- err
+	}()
+	decoder := json.NewDecoder(s.stdout)
+	require.NoError(s.t, decoder.Decode(&s.header), "reading header")
+	_, err := decoder.Token()
+	require.NoError(s.t, err, "reading start of output")
+	go s.readOutputs(decoder)
+	_, err = io.WriteString(s.stdin, "[\n")
+	require.NoError(s.t, err, "starting event stream")
+}
+
+// readOutputs reads each output of the bar, and queues it for the test.
+func (s *Simulator) readOutputs(decoder *json.Decoder) {
+	for decoder.More() {
+		var out []Segment
+		if decoder.Decode(&out) != nil {
+			return
+		}
+		s.mu.Lock()
+		s.pending = append(s.pending, out)
+		s.mu.Unlock()
+		select {
+		case s.outputReady <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Header returns the header sent by the bar.
+func (s *Simulator) Header() Header {
+	return s.header
+}
+
+// next returns the next queued output, waiting up to timeout for one.
+func (s *Simulator) next(timeout time.Duration) ([]Segment, bool) {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		if len(s.pending) > 0 {
+			out := s.pending[0]
+			s.pending = s.pending[1:]
+			s.latest = out
+			s.mu.Unlock()
+			return out, true
+		}
+		s.mu.Unlock()
+		select {
+		case <-s.outputReady:
+		case <-deadline:
+			return nil, false
+		}
+	}
+}
+
+// NextOutput returns the next output of the bar, failing the test if the bar
+// does not update.
+func (s *Simulator) NextOutput(formatAndArgs ...interface{}) []Segment {
+	out, ok := s.next(positiveTimeout)
+	if !ok {
+		require.Fail(s.t, "No output from bar", formatAndArgs...)
+	}
+	return out
+}
+
+// LatestOutput waits for the bar to update, and returns the latest output,
+// discarding any intermediate outputs.
+func (s *Simulator) LatestOutput(formatAndArgs ...interface{}) []Segment {
+	out := s.NextOutput(formatAndArgs...)
+	for {
+		next, ok := s.next(negativeTimeout)
+		if !ok {
+			return out
+		}
+		out = next
+	}
+}
+
+// AssertNoOutput asserts that the bar does not update.
+func (s *Simulator) AssertNoOutput(formatAndArgs ...interface{}) {
+	if _, ok := s.next(negativeTimeout); ok {
+		require.Fail(s.t, "Expected no output from bar", formatAndArgs...)
+	}
+}
+
+// Click sends a click event for the segment at the given index of the most
+// recent output returned by NextOutput or LatestOutput.
+func (s *Simulator) Click(index int, e bar.Event) {
+	s.mu.Lock()
+	latest := s.latest
+	s.mu.Unlock()
+	if index >= len(latest) {
+		require.Fail(s.t, "Not enough segments",
+			"want #%d, have %d", index, len(latest))
+	}
+	name := latest[index].Name
+	if name == "" {
+		require.Fail(s.t, "Segment is not clickable", "#%d", index)
+	}
+	data, err := json.Marshal(struct {
+		bar.Event
+		Name string `json:"name"`
+	}{e, name})
+	require.NoError(s.t, err)
+	if s.sentEvent {
+		data = append([]byte(","), data...)
+	}
+	s.sentEvent = true
+	_, err = s.stdin.Write(append(data, '\n'))
+	require.NoError(s.t, err, "sending click event")
+}
+
+// LeftClick sends a left click to the segment at the given index.
+func (s *Simulator) LeftClick(index int) {
+	s.Click(index, bar.Event{Button: bar.ButtonLeft})
+}
+
+// SendStop sends the stop signal requested by the bar, as i3bar does when the
+// bar is hidden, and waits for the bar to pause. It has no effect if the bar
+// suppresses signals.
+func (s *Simulator) SendStop() {
+	paused, stop := timing.Paused()
+	defer stop()
+	s.signal(s.header.StopSignal, paused)
+}
+
+// SendCont sends the continue signal requested by the bar, as i3bar does when
+// the bar is visible again, and waits for the bar to resume. It has no effect
+// if the bar suppresses signals.
+func (s *Simulator) SendCont() {
+	s.signal(s.header.ContSignal, timing.Resumed())
+}
+
+func (s *Simulator) signal(sig int, handled <-chan struct{}) {
+	// A bar that doesn't request signals would be stopped with SIGSTOP, which
+	// would also stop the test.
+	if sig == 0 {
+		return
+	}
+	require.NoError(s.t, unix.Kill(os.Getpid(), unix.Signal(sig)))
+	select {
+	case <-handled:
+	case <-time.After(positiveTimeout):
+		require.Fail(s.t, "Signal not handled", "signal %d", sig)
+	}
+}
+
+// Close closes the bar's input, which stops the bar, and returns the error
+// that caused the bar to exit.
+func (s *Simulator) Close() error {
+	s.stdin.Close()
+	select {
+	case err := <-s.done:
+		s.stdout.Close()
+		return err
+	case <-time.After(positiveTimeout):
+		return fmt.Errorf("bar did not stop after %v", positiveTimeout)
+	}
+}
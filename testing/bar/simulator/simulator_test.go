@@ -0,0 +1,144 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"barista.run"
+	"barista.run/bar"
+	"barista.run/colors"
+	"barista.run/outputs"
+	testModule "barista.run/testing/module"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestSimulator(t *testing.T) {
+	m1 := testModule.New(t)
+	m2 := testModule.New(t)
+	sim := New(t)
+	sim.Run(m1, m2)
+	require.Equal(t, Header{
+		Version:     1,
+		StopSignal:  int(unix.SIGUSR1),
+		ContSignal:  int(unix.SIGUSR2),
+		ClickEvents: true,
+	}, sim.Header())
+
+	m1.AssertStarted()
+	m2.AssertStarted()
+	sim.AssertNoOutput("before modules output")
+
+	m1.Output(bar.PangoSegment("<b>bold</b> &amp; more").Color(colors.Hex("#f00")))
+	out := sim.NextOutput("on module output")
+	require.Equal(t, []string{"bold & more"}, Texts(out))
+	require.Equal(t, "pango", out[0].Markup)
+	require.Equal(t, "#ff0000", out[0].Color)
+
+	m2.Output(outputs.Text("plain").Urgent(true))
+	out = sim.LatestOutput("on second module output")
+	require.Equal(t, []string{"bold & more", "plain"}, Texts(out))
+	require.True(t, out[1].Urgent)
+
+	sim.LeftClick(1)
+	require.Equal(t, bar.ButtonLeft, m2.AssertClicked().Button)
+	sim.Click(0, bar.Event{Button: bar.ScrollUp, Modifiers: bar.ModShift})
+	e := m1.AssertClicked()
+	require.Equal(t, bar.ScrollUp, e.Button)
+	require.True(t, e.Modifiers.Has(bar.ModShift))
+
+	sim.SendStop()
+	m1.OutputText("while stopped")
+	sim.AssertNoOutput("while stopped")
+	sim.SendCont()
+	require.Equal(t, []string{"while stopped", "plain"},
+		Texts(sim.NextOutput("on continue")))
+
+	require.Error(t, sim.Close(), "bar exits when input is closed")
+}
+
+func TestSuppressedSignals(t *testing.T) {
+	m := testModule.New(t)
+	sim := New(t)
+	barista.SuppressSignals(true)
+	sim.Run(m)
+	require.Equal(t, 0, sim.Header().StopSignal)
+	m.AssertStarted()
+	sim.SendStop()
+	m.OutputText("not stopped")
+	require.Equal(t, []string{"not stopped"}, Texts(sim.NextOutput()))
+	sim.Close()
+}
+
+func TestHTML(t *testing.T) {
+	no := false
+	width := 20
+	segments := []Segment{
+		{FullText: "a<b>", Markup: "none", Color: "#ff0000", Separator: &no},
+		{FullText: `<span color="#00ff00" weight="bold">on</span> <i>it</i>`, Markup: "pango",
+			MinWidth: 100.0, Align: "center"},
+		{FullText: "!", Urgent: true, SeparatorBlockWidth: &width},
+		{FullText: "last", Background: "#123456", Border: "#abcdef"},
+	}
+	require.Equal(t, []string{"a<b>", "on it", "!", "last"}, Texts(segments))
+	html := HTML(segments)
+	for _, expected := range []string{
+		`<span style="color:#ff0000;padding:0 2px">a&lt;b&gt;</span><span style="width:9px"></span>`,
+		`<span style="color:#00ff00;font-weight:bold">on</span> <i>it</i>`,
+		`min-width:100px;text-align:center`,
+		`background:#900000`,
+		`width:10px;margin-right:9px;border-right:1px solid #666666`,
+		`background:#123456;border-style:solid;border-color:#abcdef;border-width:1px 1px 1px 1px">last</span></div>`,
+	} {
+		require.Contains(t, html, expected)
+	}
+	require.Equal(t, "&lt;b&gt;unclosed", markupHTML("<b>unclosed"), "malformed markup")
+	require.Equal(t, "<b>unclosed", stripMarkup("<b>unclosed"), "malformed markup")
+}
+
+func TestPNG(t *testing.T) {
+	segments := []Segment{
+		{FullText: "Hi", Color: "#ff0000"},
+		{FullText: "é", Background: "#0000ff"},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, PNG(&buf, segments))
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+
+	firstW := 2*glyphW + 2*textMargin
+	secondW := glyphW + 2*textMargin
+	require.Equal(t, firstW+9*scale+secondW, img.Bounds().Dx())
+	require.Equal(t, barHeight, img.Bounds().Dy())
+
+	rgba := func(x, y int) color.RGBA {
+		return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+	}
+	black := color.RGBA{0, 0, 0, 0xff}
+	require.Equal(t, black, rgba(0, 0), "default background")
+	// The left column of 'H' is fully set.
+	require.Equal(t, color.RGBA{0xff, 0, 0, 0xff}, rgba(textMargin, padding+scale))
+	require.Equal(t, color.RGBA{0x66, 0x66, 0x66, 0xff}, rgba(firstW+9*scale/2, barHeight/2),
+		"separator")
+	require.Equal(t, color.RGBA{0, 0, 0xff, 0xff}, rgba(firstW+9*scale+1, 1), "background")
+	// Unknown characters are drawn as boxes.
+	require.Equal(t, color.RGBA{0xff, 0xff, 0xff, 0xff},
+		rgba(firstW+9*scale+textMargin, padding))
+}
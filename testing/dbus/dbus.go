@@ -0,0 +1,202 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dbus provides fake system services on the in-process test bus, so
+// that modules which talk to UPower, systemd, BlueZ, or logind can be tested
+// without a real bus, root, or hardware. Modules under test must be
+// configured to use dbus.Test as their bus type.
+package dbus // import "barista.run/testing/dbus"
+
+import (
+	"strings"
+	"sync"
+
+	"barista.run/base/watchers/dbus"
+
+	systemdbus "github.com/coreos/go-systemd/v22/dbus"
+	godbus "github.com/godbus/dbus/v5"
+)
+
+// Bus is a test bus with helpers to register common system services.
+type Bus struct {
+	*dbus.TestBus
+
+	mu       sync.Mutex
+	services map[string]*dbus.TestBusService
+}
+
+// New sets up a new test bus, replacing any existing one.
+func New() *Bus {
+	return &Bus{
+		TestBus:  dbus.SetupTestBus(),
+		services: map[string]*dbus.TestBusService{},
+	}
+}
+
+// Service returns the fake service with the given name, registering it on
+// the bus on first use.
+func (b *Bus) Service(name string) *dbus.TestBusService {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s, ok := b.services[name]; ok {
+		return s
+	}
+	s := b.RegisterService(name)
+	b.services[name] = s
+	return s
+}
+
+// Record scripts a method on the object to return the given values, and
+// returns a channel that receives the arguments of each call. The channel is
+// buffered, but calls will block if it fills up.
+func Record(obj *dbus.TestBusObject, method string, results ...interface{}) <-chan []interface{} {
+	calls := make(chan []interface{}, 10)
+	obj.On(method, func(args ...interface{}) ([]interface{}, error) {
+		calls <- args
+		return results, nil
+	})
+	return calls
+}
+
+// UPower is a fake org.freedesktop.UPower service. The embedded service can
+// be used to unregister it, or to script additional objects.
+type UPower struct {
+	*dbus.TestBusService
+	obj *dbus.TestBusObject
+
+	mu      sync.Mutex
+	devices []godbus.ObjectPath
+}
+
+const upowerIface = "org.freedesktop.UPower"
+
+// UPower returns a fake UPower service with no devices.
+func (b *Bus) UPower() *UPower {
+	srv := b.Service(upowerIface)
+	u := &UPower{TestBusService: srv, obj: srv.Object("/org/freedesktop/UPower", upowerIface)}
+	u.obj.On("EnumerateDevices", func(...interface{}) ([]interface{}, error) {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		return []interface{}{append([]godbus.ObjectPath(nil), u.devices...)}, nil
+	})
+	return u
+}
+
+func upowerDevicePath(name string) godbus.ObjectPath {
+	return godbus.ObjectPath("/org/freedesktop/UPower/devices/" + name)
+}
+
+// AddDevice adds a device with the given properties (e.g. "Type",
+// "Percentage", "State"), and announces it with a DeviceAdded signal. The
+// returned object can be used to update the device's properties.
+func (u *UPower) AddDevice(name string, props map[string]interface{}) *dbus.TestBusObject {
+	p := upowerDevicePath(name)
+	dev := u.Object(p, upowerIface+".Device")
+	dev.SetProperties(props, dbus.SignalTypeNone)
+	u.mu.Lock()
+	u.devices = append(u.devices, p)
+	u.mu.Unlock()
+	u.obj.Emit("DeviceAdded", p)
+	return dev
+}
+
+// RemoveDevice removes a device, and announces it with a DeviceRemoved signal.
+func (u *UPower) RemoveDevice(name string) {
+	p := upowerDevicePath(name)
+	u.mu.Lock()
+	for i, d := range u.devices {
+		if d == p {
+			u.devices = append(u.devices[:i], u.devices[i+1:]...)
+			break
+		}
+	}
+	u.mu.Unlock()
+	u.obj.Emit("DeviceRemoved", p)
+}
+
+// Systemd is a fake org.freedesktop.systemd1 service.
+type Systemd struct {
+	*dbus.TestBusService
+}
+
+// Systemd returns a fake systemd service with no units.
+func (b *Bus) Systemd() *Systemd {
+	return &Systemd{b.Service("org.freedesktop.systemd1")}
+}
+
+// Unit adds a unit (e.g. "foo.service", "backup.timer"), with unitProps on
+// the org.freedesktop.systemd1.Unit interface, and typeProps on the interface
+// for the unit type (e.g. org.freedesktop.systemd1.Service).
+func (s *Systemd) Unit(name string, unitProps, typeProps map[string]interface{}) (unit, typed *dbus.TestBusObject) {
+	p := godbus.ObjectPath("/org/freedesktop/systemd1/unit/" + systemdbus.PathBusEscape(name))
+	unitType := name[strings.LastIndex(name, ".")+1:]
+	unitType = strings.ToUpper(unitType[:1]) + unitType[1:]
+	unit = s.Object(p, "org.freedesktop.systemd1.Unit")
+	if _, ok := unitProps["Id"]; !ok {
+		unit.SetPropertyForTest("Id", name, dbus.SignalTypeNone)
+	}
+	unit.SetProperties(unitProps, dbus.SignalTypeNone)
+	typed = s.Object(p, "org.freedesktop.systemd1."+unitType)
+	typed.SetProperties(typeProps, dbus.SignalTypeNone)
+	return unit, typed
+}
+
+// Bluez is a fake org.bluez service.
+type Bluez struct {
+	*dbus.TestBusService
+}
+
+// Bluez returns a fake BlueZ service with no adapters.
+func (b *Bus) Bluez() *Bluez {
+	return &Bluez{b.Service("org.bluez")}
+}
+
+// Adapter adds a bluetooth adapter (e.g. "hci0") with the given properties.
+func (z *Bluez) Adapter(name string, props map[string]interface{}) *dbus.TestBusObject {
+	a := z.Object(godbus.ObjectPath("/org/bluez/"+name), "org.bluez.Adapter1")
+	a.SetProperties(props, dbus.SignalTypeNone)
+	return a
+}
+
+// Device adds a bluetooth device to an adapter, with the given device
+// properties. The returned battery object can be used to set the properties
+// of the org.bluez.Battery1 interface for devices that report their battery.
+func (z *Bluez) Device(adapter, mac string, props map[string]interface{}) (device, battery *dbus.TestBusObject) {
+	macPath := strings.Replace(strings.ToUpper(mac), ":", "_", -1)
+	p := godbus.ObjectPath("/org/bluez/" + adapter + "/dev_" + macPath)
+	device = z.Object(p, "org.bluez.Device1")
+	if _, ok := props["Adapter"]; !ok {
+		device.SetPropertyForTest("Adapter",
+			godbus.ObjectPath("/org/bluez/"+adapter), dbus.SignalTypeNone)
+	}
+	device.SetProperties(props, dbus.SignalTypeNone)
+	return device, z.Object(p, "org.bluez.Battery1")
+}
+
+// Logind is a fake org.freedesktop.login1 service.
+type Logind struct {
+	*dbus.TestBusService
+}
+
+// Logind returns a fake logind service.
+func (b *Bus) Logind() *Logind {
+	return &Logind{b.Service("org.freedesktop.login1")}
+}
+
+// Session returns the current ("auto") session, which can be scripted using
+// Record, e.g. to capture SetBrightness calls.
+func (l *Logind) Session() *dbus.TestBusObject {
+	return l.Object("/org/freedesktop/login1/session/auto",
+		"org.freedesktop.login1.Session")
+}
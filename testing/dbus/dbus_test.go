@@ -0,0 +1,120 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbus
+
+import (
+	"testing"
+
+	"barista.run/base/watchers/dbus"
+
+	godbus "github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUPower(t *testing.T) {
+	u := New().UPower()
+	w := dbus.WatchSignals(dbus.Test, "org.freedesktop.UPower", "").Add(
+		"org.freedesktop.UPower.DeviceAdded",
+		"org.freedesktop.UPower.DeviceRemoved")
+	defer w.Unsubscribe()
+
+	enumerate := func() []godbus.ObjectPath {
+		r, err := w.Call("/org/freedesktop/UPower", "org.freedesktop.UPower.EnumerateDevices")
+		require.NoError(t, err)
+		return r[0].([]godbus.ObjectPath)
+	}
+	require.Empty(t, enumerate())
+
+	bat := u.AddDevice("battery_BAT0", map[string]interface{}{"Percentage": 50.0})
+	sig := <-w.Signals
+	require.Equal(t, "org.freedesktop.UPower.DeviceAdded", sig.Name)
+	require.Equal(t, []interface{}{godbus.ObjectPath("/org/freedesktop/UPower/devices/battery_BAT0")}, sig.Body)
+	require.Equal(t, []godbus.ObjectPath{"/org/freedesktop/UPower/devices/battery_BAT0"}, enumerate())
+
+	p := dbus.WatchProperties(dbus.Test, "org.freedesktop.UPower",
+		"/org/freedesktop/UPower/devices/battery_BAT0", "org.freedesktop.UPower.Device").
+		Add("Percentage")
+	defer p.Unsubscribe()
+	require.Equal(t, 50.0, p.Get()["Percentage"])
+	bat.SetProperty("Percentage", 40.0)
+	<-p.Updates
+	require.Equal(t, 40.0, p.Get()["Percentage"])
+
+	u.RemoveDevice("battery_BAT0")
+	sig = <-w.Signals
+	require.Equal(t, "org.freedesktop.UPower.DeviceRemoved", sig.Name)
+	require.Empty(t, enumerate())
+}
+
+func TestSystemd(t *testing.T) {
+	s := New().Systemd()
+	s.Unit("foo-bar.service",
+		map[string]interface{}{"ActiveState": "active"},
+		map[string]interface{}{"MainPID": uint32(42)})
+
+	path := "/org/freedesktop/systemd1/unit/foo_2dbar_2eservice"
+	unit := dbus.WatchProperties(dbus.Test, "org.freedesktop.systemd1", path,
+		"org.freedesktop.systemd1.Unit").Add("Id", "ActiveState")
+	defer unit.Unsubscribe()
+	require.Equal(t, map[string]interface{}{
+		"Id":          "foo-bar.service",
+		"ActiveState": "active",
+	}, unit.Get())
+
+	srv := dbus.WatchProperties(dbus.Test, "org.freedesktop.systemd1", path,
+		"org.freedesktop.systemd1.Service").Add("MainPID")
+	defer srv.Unsubscribe()
+	require.Equal(t, uint32(42), srv.Get()["MainPID"])
+}
+
+func TestBluez(t *testing.T) {
+	z := New().Bluez()
+	z.Adapter("hci0", map[string]interface{}{"Powered": true})
+	_, bat := z.Device("hci0", "28:c2:dd:8b:73:8c", map[string]interface{}{"Connected": true})
+	bat.SetProperties(map[string]interface{}{"Percentage": byte(80)}, dbus.SignalTypeNone)
+
+	a := dbus.WatchProperties(dbus.Test, "org.bluez", "/org/bluez/hci0", "org.bluez.Adapter1").
+		Add("Powered")
+	defer a.Unsubscribe()
+	require.Equal(t, true, a.Get()["Powered"])
+
+	path := "/org/bluez/hci0/dev_28_C2_DD_8B_73_8C"
+	d := dbus.WatchProperties(dbus.Test, "org.bluez", path, "org.bluez.Device1").
+		Add("Connected", "Adapter")
+	defer d.Unsubscribe()
+	require.Equal(t, map[string]interface{}{
+		"Connected": true,
+		"Adapter":   godbus.ObjectPath("/org/bluez/hci0"),
+	}, d.Get())
+
+	b := dbus.WatchProperties(dbus.Test, "org.bluez", path, "org.bluez.Battery1").
+		Add("Percentage")
+	defer b.Unsubscribe()
+	require.Equal(t, byte(80), b.Get()["Percentage"])
+}
+
+func TestLogind(t *testing.T) {
+	bus := New()
+	calls := Record(bus.Logind().Session(), "SetBrightness")
+	require.Same(t, bus.Service("org.freedesktop.login1"), bus.Service("org.freedesktop.login1"),
+		"services are only registered once")
+
+	w := dbus.WatchProperties(dbus.Test, "org.freedesktop.login1",
+		"/org/freedesktop/login1/session/auto", "org.freedesktop.login1.Session")
+	defer w.Unsubscribe()
+	_, err := w.Call("SetBrightness", "backlight", "intel_backlight", uint32(40))
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"backlight", "intel_backlight", uint32(40)}, <-calls)
+}
@@ -14,20 +14,36 @@
 
 // Package githubfs provides an afero FS that's backed by github.com.
 // Useful for testing against master for a repository, especially in cron.
+//
+// Paths are of the form /{owner}/{repo}/{branch}/{path}, matching
+// raw.githubusercontent.com. Fetched files are cached in memory, and
+// revalidated using conditional requests, so that repeated reads of unchanged
+// files are cheap. With an access token, files can also be written, which
+// commits them to the repository using the GitHub contents API.
 package githubfs // import "barista.run/testing/githubfs"
 
 import (
-	"errors"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/afero"
 )
 
-var root = "https://raw.githubusercontent.com"
+var (
+	root    = "https://raw.githubusercontent.com"
+	apiRoot = "https://api.github.com"
+)
 
 // Fs represents an in-memory filesystem backed by GitHub.
 type Fs struct {
@@ -35,43 +51,128 @@ type Fs struct {
 	afero.Fs
 	// backing mem-mapped fs.
 	backingFs afero.Fs
+
+	maxAge  time.Duration
+	token   string
+	message func(path string) string
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// cacheEntry tracks the validator and fetch time of a cached file.
+type cacheEntry struct {
+	etag    string
+	fetched time.Time
+	// written is set for files committed by this Fs, until they are fetched
+	// again, since GitHub may serve the previous contents for a short while.
+	written bool
 }
 
 // New constructs an instance of GitHubFs.
-// This is a readonly Fs, and calls to Read/Stat will fetch the file from github,
-// before returning a readonly view into the newly fetched files.
-func New() afero.Fs {
+// By default this is a readonly Fs, and calls to Read/Stat will fetch the file
+// from github, before returning a readonly view into the newly fetched files.
+func New() *Fs {
 	// Using a backing mem-map fs means we only need to handle fetching files
 	// from GitHub, then we can just dump contents and chtimes and delegate all
 	// calls to the backing filesystem, making this Fs much simpler.
 	backingFs := afero.NewMemMapFs()
 	// Although the external view of this Fs is readonly, we need a reference
 	// to the actual mem-map Fs so that we can write file contents.
-	return &Fs{afero.NewReadOnlyFs(backingFs), backingFs}
+	return &Fs{
+		Fs:        afero.NewReadOnlyFs(backingFs),
+		backingFs: backingFs,
+		message:   func(path string) string { return "Update " + path },
+		cache:     map[string]cacheEntry{},
+	}
+}
+
+// MaxAge sets the duration for which fetched files are used without checking
+// GitHub for changes. By default, every read makes a conditional request.
+func (f *Fs) MaxAge(maxAge time.Duration) *Fs {
+	f.maxAge = maxAge
+	return f
+}
+
+// Token sets a personal access token, which enables writes. Files opened for
+// writing are committed to the repository when they are closed.
+func (f *Fs) Token(token string) *Fs {
+	f.token = token
+	return f
+}
+
+// CommitMessage sets the function used to generate the commit message for
+// writes, given the path of the file within the repository.
+func (f *Fs) CommitMessage(message func(path string) string) *Fs {
+	f.message = message
+	return f
 }
 
 func (f *Fs) fetch(name string) error {
-	file, err := f.backingFs.Create(name)
+	f.mu.Lock()
+	entry, cached := f.cache[name]
+	f.mu.Unlock()
+	if cached && time.Since(entry.fetched) < f.maxAge {
+		return nil
+	}
+	req, err := http.NewRequest("GET",
+		fmt.Sprintf("%s/%s", root, strings.TrimPrefix(name, "/")), nil)
 	if err != nil {
 		return err
 	}
-	r, err := http.Get(fmt.Sprintf("%s/%s", root, strings.TrimPrefix(name, "/")))
+	if cached && entry.etag != "" {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+	r, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
-	if r.StatusCode != 200 {
-		return errors.New(r.Status)
-	}
 	defer r.Body.Close()
+	switch r.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		entry.fetched = time.Now()
+		f.setCache(name, entry)
+		return nil
+	case http.StatusNotFound:
+		if entry.written {
+			// Newly created, but not yet visible on GitHub.
+			return nil
+		}
+		f.backingFs.Remove(name)
+		f.setCache(name, cacheEntry{})
+		return &os.PathError{Op: "fetch", Path: name, Err: os.ErrNotExist}
+	default:
+		return &os.PathError{Op: "fetch", Path: name, Err: fmt.Errorf("%s", r.Status)}
+	}
+	f.backingFs.MkdirAll(path.Dir(name), 0755)
+	file, err := f.backingFs.Create(name)
+	if err != nil {
+		return err
+	}
 	_, err = io.Copy(file, r.Body)
+	file.Close()
 	modTime := r.Header.Get("Last-Modified")
 	if parsed, err := http.ParseTime(modTime); err == nil {
 		local := parsed.Local()
 		f.backingFs.Chtimes(name, local, local)
 	}
+	if err == nil {
+		f.setCache(name, cacheEntry{etag: r.Header.Get("ETag"), fetched: time.Now()})
+	}
 	return err
 }
 
+func (f *Fs) setCache(name string, entry cacheEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if entry.fetched.IsZero() {
+		delete(f.cache, name)
+	} else {
+		f.cache[name] = entry
+	}
+}
+
 // Open opens a file, returning it or an error, if any happens.
 func (f *Fs) Open(name string) (afero.File, error) {
 	err := f.fetch(name)
@@ -81,8 +182,14 @@ func (f *Fs) Open(name string) (afero.File, error) {
 	return f.Fs.Open(name)
 }
 
-// OpenFile opens a file using the given flags and the given mode.
+// OpenFile opens a file using the given flags and the given mode. If a token
+// is set, files can be opened for writing, and will be committed to GitHub
+// when closed.
 func (f *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_CREATE | os.O_TRUNC
+	if flag&writeFlags != 0 && f.token != "" {
+		return f.openForWrite(name, flag, perm)
+	}
 	err := f.fetch(name)
 	if err != nil {
 		return nil, err
@@ -90,6 +197,12 @@ func (f *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, erro
 	return f.Fs.OpenFile(name, flag, perm)
 }
 
+// Create creates a file, which will be committed to GitHub when closed. It
+// requires a token to be set.
+func (f *Fs) Create(name string) (afero.File, error) {
+	return f.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
 // Stat returns a FileInfo describing the named file, or an error, if any
 // happens.
 func (f *Fs) Stat(name string) (os.FileInfo, error) {
@@ -104,3 +217,118 @@ func (f *Fs) Stat(name string) (os.FileInfo, error) {
 func (f *Fs) Name() string {
 	return fmt.Sprintf("GitHubFS/backed by %s", f.Fs.Name())
 }
+
+// splitPath splits a path into the repository, branch, and path of the file
+// within the repository. Branch names cannot contain a '/'.
+func splitPath(name string) (repo, branch, file string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(name, "/"), "/", 4)
+	if len(parts) < 4 || parts[3] == "" {
+		return "", "", "", &os.PathError{Op: "write", Path: name,
+			Err: fmt.Errorf("path must be /owner/repo/branch/file")}
+	}
+	return parts[0] + "/" + parts[1], parts[2], parts[3], nil
+}
+
+// blobSHA returns the git object ID of a blob with the given contents.
+func blobSHA(contents []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(contents))
+	h.Write(contents)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func (f *Fs) openForWrite(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if _, _, _, err := splitPath(name); err != nil {
+		return nil, err
+	}
+	// The sha of the existing file is required to update it, so fetch the
+	// current contents first. This also allows appending to existing files.
+	sha := ""
+	err := f.fetch(name)
+	switch {
+	case err == nil:
+		contents, err := afero.ReadFile(f.backingFs, name)
+		if err != nil {
+			return nil, err
+		}
+		sha = blobSHA(contents)
+	case os.IsNotExist(err):
+		if flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+		f.backingFs.MkdirAll(path.Dir(name), 0755)
+	default:
+		return nil, err
+	}
+	file, err := f.backingFs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &writeFile{File: file, fs: f, name: name, sha: sha}, nil
+}
+
+// writeFile is a file that commits its contents to GitHub when closed.
+type writeFile struct {
+	afero.File
+	fs   *Fs
+	name string
+	sha  string
+}
+
+func (w *writeFile) Close() error {
+	if err := w.File.Close(); err != nil {
+		return err
+	}
+	contents, err := afero.ReadFile(w.fs.backingFs, w.name)
+	if err != nil {
+		return err
+	}
+	return w.fs.commit(w.name, contents, w.sha)
+}
+
+// commit writes the contents of a file to GitHub using the contents API.
+func (f *Fs) commit(name string, contents []byte, sha string) error {
+	repo, branch, file, err := splitPath(name)
+	if err != nil {
+		return err
+	}
+	body := map[string]string{
+		"message": f.message(file),
+		"content": base64.StdEncoding.EncodeToString(contents),
+		"branch":  branch,
+	}
+	if sha != "" {
+		body["sha"] = sha
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PUT",
+		fmt.Sprintf("%s/repos/%s/contents/%s", apiRoot, repo, file),
+		bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+f.token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Content-Type", "application/json")
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusCreated {
+		msg, _ := ioutil.ReadAll(r.Body)
+		return &os.PathError{Op: "commit", Path: name,
+			Err: fmt.Errorf("%s: %s", r.Status, bytes.TrimSpace(msg))}
+	}
+	// The raw file may take a while to update, so keep the etag of the
+	// previous contents. Until GitHub serves the new contents, conditional
+	// requests will return 304, and the committed contents will be used.
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entry := f.cache[name]
+	f.cache[name] = cacheEntry{etag: entry.etag, fetched: time.Now(), written: true}
+	return nil
+}
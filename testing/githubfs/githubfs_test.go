@@ -15,13 +15,19 @@
 package githubfs
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	testServer "barista.run/testing/httpserver"
 
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
 
@@ -60,3 +66,152 @@ func TestFs(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "bar", string(contents))
 }
+
+// fakeGitHub serves raw files with etags, and accepts commits to them.
+type fakeGitHub struct {
+	sync.Mutex
+	files   map[string]string
+	fetches int
+	commits []map[string]string
+	auth    string
+}
+
+func (g *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.Lock()
+	defer g.Unlock()
+	if r.Method == "PUT" {
+		g.auth = r.Header.Get("Authorization")
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["message"] == "conflict" {
+			w.WriteHeader(409)
+			w.Write([]byte(`{"message": "sha does not match"}`))
+			return
+		}
+		g.commits = append(g.commits, body)
+		w.WriteHeader(201)
+		return
+	}
+	g.fetches++
+	contents, ok := g.files[r.URL.Path]
+	if !ok {
+		w.WriteHeader(404)
+		return
+	}
+	etag := `"` + contents + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(304)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Write([]byte(contents))
+}
+
+func (g *fakeGitHub) fetchCount() int {
+	g.Lock()
+	defer g.Unlock()
+	return g.fetches
+}
+
+func setupFake() *fakeGitHub {
+	g := &fakeGitHub{files: map[string]string{"/o/r/main/state.txt": "hello"}}
+	ts := httptest.NewServer(g)
+	root = ts.URL
+	apiRoot = ts.URL + "/api"
+	return g
+}
+
+func TestConditionalRequests(t *testing.T) {
+	g := setupFake()
+	fs := New()
+
+	contents, err := afero.ReadFile(fs, "/o/r/main/state.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(contents))
+	require.Equal(t, 1, g.fetchCount())
+
+	contents, err = afero.ReadFile(fs, "/o/r/main/state.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(contents), "served from cache on 304")
+	require.Equal(t, 2, g.fetchCount(), "revalidated")
+
+	g.Lock()
+	g.files["/o/r/main/state.txt"] = "changed"
+	g.Unlock()
+	contents, err = afero.ReadFile(fs, "/o/r/main/state.txt")
+	require.NoError(t, err)
+	require.Equal(t, "changed", string(contents), "updated when etag changes")
+
+	g.Lock()
+	delete(g.files, "/o/r/main/state.txt")
+	g.Unlock()
+	_, err = fs.Stat("/o/r/main/state.txt")
+	require.True(t, os.IsNotExist(err), "removed upstream")
+
+	fs = New().MaxAge(time.Hour)
+	_, err = fs.Stat("/o/r/main/missing")
+	require.True(t, os.IsNotExist(err))
+	g.Lock()
+	g.files["/o/r/main/state.txt"] = "hello"
+	g.Unlock()
+	count := g.fetchCount()
+	for i := 0; i < 3; i++ {
+		_, err = afero.ReadFile(fs, "/o/r/main/state.txt")
+		require.NoError(t, err)
+	}
+	require.Equal(t, count+1, g.fetchCount(), "not revalidated within max age")
+}
+
+func TestWrite(t *testing.T) {
+	g := setupFake()
+
+	err := afero.WriteFile(New(), "/o/r/main/state.txt", []byte("x"), 0644)
+	require.Error(t, err, "readonly without a token")
+
+	fs := New().Token("s3cr3t")
+	require.NoError(t, afero.WriteFile(fs, "/o/r/main/state.txt", []byte("hello world\n"), 0644))
+	require.Equal(t, "token s3cr3t", g.auth)
+	require.Equal(t, map[string]string{
+		"message": "Update state.txt",
+		"content": base64.StdEncoding.EncodeToString([]byte("hello world\n")),
+		"branch":  "main",
+		// git hash-object of the previous contents, "hello".
+		"sha": "b6fc4c620b67d95f953a5c1c1230aaab5db5a1b0",
+	}, g.commits[0])
+
+	contents, err := afero.ReadFile(fs, "/o/r/main/state.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello world\n", string(contents), "written contents are readable")
+
+	fs.CommitMessage(func(path string) string { return "Save " + path })
+	f, err := fs.OpenFile("/o/r/main/dir/new.json", os.O_WRONLY|os.O_CREATE, 0644)
+	require.NoError(t, err)
+	f.Write([]byte("{}"))
+	require.NoError(t, f.Close())
+	require.Equal(t, map[string]string{
+		"message": "Save dir/new.json",
+		"content": base64.StdEncoding.EncodeToString([]byte("{}")),
+		"branch":  "main",
+	}, g.commits[1], "new files have no sha")
+	contents, err = afero.ReadFile(fs, "/o/r/main/dir/new.json")
+	require.NoError(t, err)
+	require.Equal(t, "{}", string(contents), "new files are readable before GitHub updates")
+
+	g.Lock()
+	g.files["/o/r/main/state.txt"] = "hello world, again"
+	g.Unlock()
+	contents, err = afero.ReadFile(fs, "/o/r/main/state.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello world, again", string(contents), "updated once GitHub changes")
+
+	_, err = fs.OpenFile("/o/r/main/other.json", os.O_WRONLY, 0644)
+	require.True(t, os.IsNotExist(err), "O_CREATE required for new files")
+
+	_, err = fs.Create("/o/r/main")
+	require.Error(t, err, "path without a file")
+
+	fs.CommitMessage(func(string) string { return "conflict" })
+	err = afero.WriteFile(fs, "/o/r/main/state.txt", []byte("x"), 0644)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "sha does not match")
+}
@@ -15,6 +15,8 @@
 // Package httpserver provides a test http server that can serve some
 // canned responses, e.g. modification time header, infinite redirect loop,
 // various http status codes, and templated responses using query params.
+// It also provides a record/replay server for testing against fixtures
+// captured from real upstream servers.
 package httpserver // import "barista.run/testing/httpserver"
 
 import (
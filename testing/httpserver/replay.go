@@ -0,0 +1,198 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+var record = flag.Bool("record", false,
+	"Record fixtures from real upstream servers instead of replaying them")
+
+// redacted replaces sensitive values in recorded fixtures.
+const redacted = "REDACTED"
+
+var fixtureNameRe = regexp.MustCompile(`[^[:alnum:]._=-]+`)
+
+// replayer serves recorded fixtures, or records them from upstream.
+type replayer struct {
+	dir      string
+	upstream *url.URL
+	redact   map[string]bool
+}
+
+// Replay creates a test server that replays responses previously recorded
+// from the upstream server into fixture files in dir. When tests are run
+// with -record, requests are instead forwarded to upstream, and the responses
+// are sanitized and saved as fixtures for later replay.
+//
+// Query parameters and headers named in redact (e.g. "appid",
+// "Authorization") are sent upstream when recording, but their values are
+// replaced in the fixture filenames, response headers, and response bodies,
+// so that API keys and tokens are never written to disk. Cookies are never
+// recorded.
+//
+// Typical usage would be to point a provider at the server's URL, or to use
+// testing/httpclient.Wrap to redirect a client's requests to it.
+func Replay(dir, upstream string, redact ...string) *httptest.Server {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		panic(err)
+	}
+	r := &replayer{dir: dir, upstream: u, redact: map[string]bool{}}
+	for _, name := range redact {
+		r.redact[strings.ToLower(name)] = true
+	}
+	return httptest.NewServer(r)
+}
+
+// fixtureName returns a human-readable filename for a request, using only
+// the method, path, and the sanitized and sorted query parameters.
+func (r *replayer) fixtureName(req *http.Request) string {
+	query := req.URL.Query()
+	keys := []string{}
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{strings.TrimPrefix(req.URL.Path, "/")}
+	if req.Method != "GET" {
+		parts = append([]string{req.Method}, parts...)
+	}
+	for _, k := range keys {
+		v := strings.Join(query[k], ",")
+		if r.redact[strings.ToLower(k)] {
+			v = redacted
+		}
+		parts = append(parts, k+"="+v)
+	}
+	name := fixtureNameRe.ReplaceAllString(strings.Join(parts, "_"), "-")
+	return filepath.Join(r.dir, strings.Trim(name, "-")+".http")
+}
+
+func (r *replayer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	filename := r.fixtureName(req)
+	if *record {
+		if handleError(w, r.record(req, filename)) {
+			return
+		}
+	}
+	file, err := fs.Open(filename)
+	if os.IsNotExist(err) {
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "no fixture %s (run tests with -record)", filename)
+		return
+	}
+	if handleError(w, err) {
+		return
+	}
+	defer file.Close()
+	resp, err := http.ReadResponse(bufio.NewReader(file), req)
+	if handleError(w, err) {
+		return
+	}
+	defer resp.Body.Close()
+	for k, vs := range resp.Header {
+		if k == "Content-Length" || k == "Transfer-Encoding" {
+			continue
+		}
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// record forwards the request to upstream, and writes the sanitized response
+// to the fixture file.
+func (r *replayer) record(req *http.Request, filename string) error {
+	u := *req.URL
+	u.Scheme = r.upstream.Scheme
+	u.Host = r.upstream.Host
+	upReq, err := http.NewRequest(req.Method, u.String(), req.Body)
+	if err != nil {
+		return err
+	}
+	secrets := []string{}
+	for k, vs := range req.Header {
+		upReq.Header[k] = vs
+		if r.redact[strings.ToLower(k)] {
+			secrets = append(secrets, vs...)
+		}
+	}
+	for k, vs := range req.URL.Query() {
+		if r.redact[strings.ToLower(k)] {
+			secrets = append(secrets, vs...)
+		}
+	}
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(upReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	resp.Header.Del("Set-Cookie")
+	for k := range resp.Header {
+		if r.redact[strings.ToLower(k)] {
+			resp.Header.Set(k, redacted)
+		}
+	}
+	for _, s := range secrets {
+		if s == "" {
+			continue
+		}
+		body = bytes.Replace(body, []byte(s), []byte(redacted), -1)
+		for k, vs := range resp.Header {
+			for i, v := range vs {
+				resp.Header[k][i] = strings.Replace(v, s, redacted, -1)
+			}
+		}
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+	resp.Header.Del("Content-Length")
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return err
+	}
+	if err := fs.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+	return afero.WriteFile(fs, filename, dump, 0644)
+}
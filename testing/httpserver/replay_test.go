@@ -0,0 +1,105 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func fetch(t *testing.T, req *http.Request) (*http.Response, string) {
+	r, err := http.DefaultTransport.RoundTrip(req)
+	require.NoError(t, err, "fetching %s", req.URL)
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	require.NoError(t, err, "reading %s", req.URL)
+	return r, string(body)
+}
+
+func TestReplay(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	srv := Replay("testdata/fixtures", "https://example.invalid", "key")
+	defer srv.Close()
+
+	r, body := fetch(t, httptest.NewRequest("GET", srv.URL+"/data?b=2&a=1", nil))
+	require.Equal(t, 500, r.StatusCode, "missing fixture")
+	require.Contains(t, body, "testdata/fixtures/data_a=1_b=2.http")
+
+	afero.WriteFile(fs, "testdata/fixtures/data_a=1_b=2.http", []byte(
+		"HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 5\r\n\r\nhello"), 0644)
+	r, body = fetch(t, httptest.NewRequest("GET", srv.URL+"/data?a=1&b=2", nil))
+	require.Equal(t, 200, r.StatusCode)
+	require.Equal(t, "text/plain", r.Header.Get("Content-Type"))
+	require.Equal(t, "hello", body)
+
+	afero.WriteFile(fs, "testdata/fixtures/POST_auth_key=REDACTED.http", []byte(
+		"HTTP/1.1 403 Forbidden\r\nContent-Length: 4\r\n\r\nnope"), 0644)
+	r, body = fetch(t, httptest.NewRequest("POST", srv.URL+"/auth?key=secret", nil))
+	require.Equal(t, 403, r.StatusCode, "redacted parameters are ignored")
+	require.Equal(t, "nope", body)
+
+	afero.WriteFile(fs, "testdata/fixtures/garbage.http", []byte("not http"), 0644)
+	r, _ = fetch(t, httptest.NewRequest("GET", srv.URL+"/garbage", nil))
+	require.Equal(t, 500, r.StatusCode, "bad fixture")
+}
+
+func TestRecord(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	*record = true
+	defer func() { *record = false }()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=12345")
+		w.Header().Set("X-Token", r.Header.Get("Authorization"))
+		w.Header().Set("X-Path", r.URL.Path)
+		w.WriteHeader(201)
+		w.Write([]byte("key=" + r.URL.Query().Get("appid") + ", q=" + r.URL.Query().Get("q")))
+	}))
+	defer upstream.Close()
+
+	srv := Replay("fixtures", upstream.URL, "appid", "Authorization")
+	defer srv.Close()
+
+	req := httptest.NewRequest("GET", srv.URL+"/weather?q=London&appid=s3cr3t", nil)
+	req.RequestURI = ""
+	req.Header.Set("Authorization", "Bearer t0k3n")
+	r, body := fetch(t, req)
+	require.Equal(t, 201, r.StatusCode)
+	require.Equal(t, "key=REDACTED, q=London", body, "secrets removed from body")
+	require.Equal(t, "REDACTED", r.Header.Get("X-Token"))
+	require.Equal(t, "/weather", r.Header.Get("X-Path"))
+	require.Empty(t, r.Header.Get("Set-Cookie"))
+
+	fixture, err := afero.ReadFile(fs, "fixtures/weather_appid=REDACTED_q=London.http")
+	require.NoError(t, err)
+	require.NotContains(t, string(fixture), "s3cr3t")
+	require.NotContains(t, string(fixture), "t0k3n")
+	require.NotContains(t, string(fixture), "session")
+
+	*record = false
+	upstream.Close()
+	r, body = fetch(t, httptest.NewRequest("GET", srv.URL+"/weather?appid=other&q=London", nil))
+	require.Equal(t, 201, r.StatusCode, "replayed without upstream")
+	require.Equal(t, "key=REDACTED, q=London", body)
+
+	*record = true
+	r, _ = fetch(t, httptest.NewRequest("GET", srv.URL+"/weather?q=Paris", nil))
+	require.Equal(t, 500, r.StatusCode, "upstream error while recording")
+}
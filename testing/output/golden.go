@@ -0,0 +1,147 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"barista.run/bar"
+)
+
+var updateGolden = flag.Bool("update-golden", false,
+	"Update golden files used by AssertGolden instead of comparing against them")
+
+// AssertGolden asserts that the rendered segments of the output, including
+// their text, colors, urgency, and other properties, match the contents of
+// the golden file testdata/<name>.json. Running the test with -update-golden
+// writes the golden file from the actual output instead.
+func (a Assertions) AssertGolden(name string, args ...interface{}) {
+	a.Expect(args...)
+	actual := Render(a.output)
+	filename := filepath.Join("testdata", name+".json")
+	if *updateGolden {
+		a.require.NoError(os.MkdirAll("testdata", 0755))
+		a.require.NoError(ioutil.WriteFile(filename, []byte(actual), 0644))
+		return
+	}
+	expected, err := ioutil.ReadFile(filename)
+	a.require.NoError(err, "reading golden file (use -update-golden to create it)")
+	// Compare line by line for a readable diff on failure.
+	a.require.Equal(
+		strings.Split(string(expected), "\n"),
+		strings.Split(actual, "\n"),
+		args...)
+}
+
+// goldenSegment is the serialised form of a segment in golden files. Only
+// properties that have been set are included.
+type goldenSegment struct {
+	Text        string      `json:"text"`
+	Pango       bool        `json:"pango,omitempty"`
+	ShortText   *string     `json:"short_text,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	Color       string      `json:"color,omitempty"`
+	Background  string      `json:"background,omitempty"`
+	Border      string      `json:"border,omitempty"`
+	Urgent      *bool       `json:"urgent,omitempty"`
+	Separator   *bool       `json:"separator,omitempty"`
+	Padding     *int        `json:"padding,omitempty"`
+	MinWidth    interface{} `json:"min_width,omitempty"`
+	Align       string      `json:"align,omitempty"`
+	Clickable   bool        `json:"clickable,omitempty"`
+	HasImage    bool        `json:"image,omitempty"`
+	MaxLength   *int        `json:"max_length,omitempty"`
+	ShortLength *int        `json:"short_length,omitempty"`
+}
+
+// Render returns a readable JSON representation of the segments in the
+// output, as used by golden files.
+func Render(out bar.Output) string {
+	segments := []goldenSegment{}
+	if out != nil {
+		for _, s := range out.Segments() {
+			segments = append(segments, golden(s))
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(segments); err != nil {
+		return err.Error()
+	}
+	return buf.String()
+}
+
+func golden(s *bar.Segment) goldenSegment {
+	var g goldenSegment
+	g.Text, g.Pango = s.Content()
+	if txt, ok := s.GetShortText(); ok {
+		g.ShortText = &txt
+	}
+	if err := s.GetError(); err != nil {
+		g.Error = err.Error()
+	}
+	if c, ok := s.GetColor(); ok {
+		g.Color = hex(c)
+	}
+	if c, ok := s.GetBackground(); ok {
+		g.Background = hex(c)
+	}
+	if c, ok := s.GetBorder(); ok {
+		g.Border = hex(c)
+	}
+	if u, ok := s.IsUrgent(); ok {
+		g.Urgent = &u
+	}
+	if sep, ok := s.HasSeparator(); ok {
+		g.Separator = &sep
+	}
+	if p, ok := s.GetPadding(); ok {
+		g.Padding = &p
+	}
+	if w, ok := s.GetMinWidth(); ok {
+		g.MinWidth = w
+	}
+	if a, ok := s.GetAlignment(); ok {
+		g.Align = string(a)
+	}
+	if l, ok := s.GetMaxLength(); ok {
+		g.MaxLength = &l
+	}
+	if l, ok := s.GetShortLength(); ok {
+		g.ShortLength = &l
+	}
+	_, g.HasImage = s.GetImage()
+	g.Clickable = s.HasClick()
+	return g
+}
+
+// hex formats a color as #rrggbbaa, or an empty string for nil colors.
+func hex(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+	r, g, b, a := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x%02x", r>>8, g>>8, b>>8, a>>8)
+}
@@ -16,6 +16,8 @@
 package output // import "barista.run/testing/output"
 
 import (
+	"image/color"
+
 	"barista.run/bar"
 
 	"github.com/stretchr/testify/require"
@@ -148,6 +150,34 @@ func (a SegmentAssertions) AssertError(args ...interface{}) string {
 	return err.Error()
 }
 
+// AssertPango asserts that the segment uses pango markup, and that the
+// markup matches the expected string.
+func (a SegmentAssertions) AssertPango(expected string, args ...interface{}) {
+	txt, isPango := a.segment.Content()
+	a.require.True(isPango, "expected pango markup, got text '%s'", txt)
+	a.require.Equal(expected, txt, args...)
+}
+
+// AssertColor asserts that the segment's text color matches the expected
+// color. A nil color asserts that no color is set.
+func (a SegmentAssertions) AssertColor(expected color.Color, args ...interface{}) {
+	actual, _ := a.segment.GetColor()
+	a.require.Equal(hex(expected), hex(actual), args...)
+}
+
+// AssertBackground asserts that the segment's background color matches the
+// expected color. A nil color asserts that no background is set.
+func (a SegmentAssertions) AssertBackground(expected color.Color, args ...interface{}) {
+	actual, _ := a.segment.GetBackground()
+	a.require.Equal(hex(expected), hex(actual), args...)
+}
+
+// AssertUrgent asserts that the segment's urgency matches the expected value.
+func (a SegmentAssertions) AssertUrgent(expected bool, args ...interface{}) {
+	actual, _ := a.segment.IsUrgent()
+	a.require.Equal(expected, actual, args...)
+}
+
 // Click clicks on the segment. Because it's provided on SegmentAssertions,
 // it automatically ensures that the expected segment is present.
 // e.g. testBar.NextOutput().At(2).Click(...).
@@ -160,11 +190,30 @@ func (a SegmentAssertions) LeftClick() {
 	a.Click(bar.Event{Button: bar.ButtonLeft})
 }
 
+// ClickWith clicks the given button on the segment while holding the given
+// keyboard modifiers.
+func (a SegmentAssertions) ClickWith(button bar.Button, mods bar.Modifiers) {
+	a.Click(bar.Event{Button: button, Modifiers: mods})
+}
+
+// Scroll sends the given number of scroll events to the segment, scrolling up
+// for positive steps and down for negative steps.
+func (a SegmentAssertions) Scroll(steps int) {
+	button := bar.ScrollUp
+	if steps < 0 {
+		button, steps = bar.ScrollDown, -steps
+	}
+	for i := 0; i < steps; i++ {
+		a.Click(bar.Event{Button: button})
+	}
+}
+
 // Segment returns the actual segment to allow fine-grained assertions.
 // This is doubly useful because Assertions.At(i) returns SegmentAssertions,
 // allowing code like:
-//     urgent, _ := out.At(2).Segment().IsUrgent()
-//     require.True(t, urgent, "segment #3 is urgent")
+//
+//	urgent, _ := out.At(2).Segment().IsUrgent()
+//	require.True(t, urgent, "segment #3 is urgent")
 func (a SegmentAssertions) Segment() *bar.Segment {
 	return a.segment
 }
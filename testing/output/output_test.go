@@ -15,6 +15,7 @@
 package output
 
 import (
+	"image/color"
 	"testing"
 
 	"barista.run/bar"
@@ -158,6 +159,31 @@ func TestSegmentAssertions(t *testing.T) {
 	require.NotPanics(t, func() { a.LeftClick() })
 	require.Equal(t, bar.Event{Button: bar.ButtonLeft}, <-evtCh)
 
+	require.NotPanics(t, func() { a.ClickWith(bar.ButtonRight, bar.ModShift) })
+	require.Equal(t, bar.Event{Button: bar.ButtonRight, Modifiers: bar.ModShift}, <-evtCh)
+
+	evtCh = make(chan bar.Event, 3)
+	a = Segment(t, bar.TextSegment("foo").OnClick(func(e bar.Event) { evtCh <- e }))
+	a.Scroll(2)
+	require.Equal(t, bar.ScrollUp, (<-evtCh).Button)
+	require.Equal(t, bar.ScrollUp, (<-evtCh).Button)
+	a.Scroll(-1)
+	require.Equal(t, bar.ScrollDown, (<-evtCh).Button)
+	require.Empty(t, evtCh)
+
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	a = Segment(t, bar.PangoSegment("<b>foo</b>").
+		Color(red).Background(color.Gray{0x80}).Urgent(true))
+	a.AssertPango("<b>foo</b>")
+	a.AssertColor(color.RGBA{0xff, 0, 0, 0xff})
+	a.AssertBackground(color.RGBA{0x80, 0x80, 0x80, 0xff})
+	a.AssertUrgent(true)
+
+	a = Segment(t, bar.TextSegment("plain"))
+	a.AssertColor(nil)
+	a.AssertBackground(nil)
+	a.AssertUrgent(false)
+
 	fail.AssertFails(t, func(fakeT *testing.T) {
 		a = Segment(fakeT, nil)
 	}, "Trying to assert on nil segment")
@@ -186,6 +212,23 @@ func TestSegmentAssertionErrors(t *testing.T) {
 	assertFail(func(s SegmentAssertions) {
 		s.AssertEqual(bar.TextSegment("not testing"))
 	}, "AssertEqual with different segment")
+	assertFail(func(s SegmentAssertions) {
+		s.AssertPango("test segment")
+	}, "AssertPango on text segment")
+	assertFail(func(s SegmentAssertions) {
+		s.AssertColor(color.White)
+	}, "AssertColor with no color")
+	assertFail(func(s SegmentAssertions) {
+		s.AssertUrgent(true)
+	}, "AssertUrgent on non-urgent segment")
+
+	segment = bar.PangoSegment("<i>x</i>").Background(color.Black)
+	assertFail(func(s SegmentAssertions) {
+		s.AssertPango("<b>x</b>")
+	}, "AssertPango with different markup")
+	assertFail(func(s SegmentAssertions) {
+		s.AssertBackground(color.White)
+	}, "AssertBackground with different color")
 
 	errorSegments := outputs.Errorf("404").Segments()
 	segment = errorSegments[0]
@@ -196,3 +239,22 @@ func TestSegmentAssertionErrors(t *testing.T) {
 		s.AssertEqual(bar.TextSegment("404"))
 	}, "AssertEqual with different segment")
 }
+
+func TestGolden(t *testing.T) {
+	out := outputs.Group(
+		bar.PangoSegment("<b>bold</b>").Color(color.RGBA{0xff, 0, 0, 0xff}),
+		outputs.Text("plain").ShortText("p").Urgent(true).MinWidth(40),
+		outputs.Errorf("oops"),
+	)
+	New(t, out).AssertGolden("golden")
+	if *updateGolden {
+		return
+	}
+
+	fail.AssertFails(t, func(fakeT *testing.T) {
+		New(fakeT, outputs.Text("other")).AssertGolden("golden")
+	}, "AssertGolden with different output")
+	fail.AssertFails(t, func(fakeT *testing.T) {
+		New(fakeT, out).AssertGolden("does-not-exist")
+	}, "AssertGolden with missing golden file")
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sysfs builds fake /sys and /proc layouts for testing modules that
+// read kernel interfaces, either in memory or in a temporary directory.
+package sysfs // import "barista.run/testing/sysfs"
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// Tree is a filesystem with helpers to build sysfs and procfs layouts. It
+// implements afero.Fs, so it can directly replace the filesystem used by
+// modules, using the same absolute paths as the real system.
+type Tree struct {
+	afero.Fs
+	t   *testing.T
+	dir string
+}
+
+// New creates an empty in-memory tree.
+func New(t *testing.T) *Tree {
+	return &Tree{Fs: afero.NewMemMapFs(), t: t}
+}
+
+// TempDir creates an empty tree in a temporary directory on disk, for modules
+// that need real files (e.g. to watch them for changes). Use Path to get the
+// location of a file on disk. The returned function removes the directory.
+func TempDir(t *testing.T) (*Tree, func()) {
+	dir, err := ioutil.TempDir("", "sysfs")
+	require.NoError(t, err)
+	return &Tree{
+		Fs:  afero.NewBasePathFs(afero.NewOsFs(), dir),
+		t:   t,
+		dir: dir,
+	}, func() { os.RemoveAll(dir) }
+}
+
+// Path returns the location of the given path on disk. For in-memory trees,
+// the path is returned unchanged.
+func (t *Tree) Path(path string) string {
+	if t.dir == "" {
+		return path
+	}
+	return filepath.Join(t.dir, path)
+}
+
+// Write writes a file, creating any parent directories. Strings are written
+// as-is, while all other values are formatted with a trailing newline, the
+// way the kernel formats sysfs attributes.
+func (t *Tree) Write(path string, content interface{}) *Tree {
+	data, ok := content.(string)
+	if !ok {
+		data = fmt.Sprintf("%v\n", content)
+	}
+	require.NoError(t.t, t.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t.t, afero.WriteFile(t, path, []byte(data), 0644))
+	return t
+}
+
+// Read returns the contents of a file, without surrounding whitespace.
+func (t *Tree) Read(path string) string {
+	data, err := afero.ReadFile(t, path)
+	require.NoError(t.t, err)
+	return strings.TrimSpace(string(data))
+}
+
+// Uevent writes a uevent file in dir, with one PREFIX_KEY=value line for
+// each property, sorted by key.
+func (t *Tree) Uevent(dir, prefix string, props map[string]interface{}) *Tree {
+	keys := []string{}
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var out strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&out, "%s%s=%v\n", prefix, k, props[k])
+	}
+	return t.Write(filepath.Join(dir, "uevent"), out.String())
+}
+
+// PowerSupply writes the uevent file for a power supply in
+// /sys/class/power_supply, with properties like "STATUS" and "ENERGY_NOW".
+// The "NAME" property is set to the name of the power supply if missing.
+func (t *Tree) PowerSupply(name string, props map[string]interface{}) *Tree {
+	withName := map[string]interface{}{"NAME": name}
+	for k, v := range props {
+		withName[k] = v
+	}
+	return t.Uevent("/sys/class/power_supply/"+name, "POWER_SUPPLY_", withName)
+}
+
+// Backlight writes the attributes of a backlight device in
+// /sys/class/backlight.
+func (t *Tree) Backlight(name string, brightness, max int) *Tree {
+	dir := "/sys/class/backlight/" + name
+	return t.Write(dir+"/max_brightness", max).
+		Write(dir+"/brightness", brightness).
+		Write(dir+"/actual_brightness", brightness)
+}
+
+// ThermalZone writes the type and temperature (in millidegrees Celsius) of a
+// thermal zone in /sys/class/thermal.
+func (t *Tree) ThermalZone(zone, typ string, milliC int) *Tree {
+	dir := "/sys/class/thermal/" + zone
+	return t.Write(dir+"/type", typ).Write(dir+"/temp", milliC)
+}
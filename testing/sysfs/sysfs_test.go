@@ -0,0 +1,68 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTree(t *testing.T) {
+	tree := New(t)
+	var fs afero.Fs = tree
+	tree.PowerSupply("BAT0", map[string]interface{}{
+		"STATUS":     "Charging",
+		"ENERGY_NOW": 1200000,
+	})
+	data, err := afero.ReadFile(fs, "/sys/class/power_supply/BAT0/uevent")
+	require.NoError(t, err)
+	require.Equal(t, "POWER_SUPPLY_ENERGY_NOW=1200000\n"+
+		"POWER_SUPPLY_NAME=BAT0\n"+
+		"POWER_SUPPLY_STATUS=Charging\n", string(data))
+
+	tree.Backlight("intel_backlight", 300, 1200)
+	require.Equal(t, "1200", tree.Read("/sys/class/backlight/intel_backlight/max_brightness"))
+	require.Equal(t, "300", tree.Read("/sys/class/backlight/intel_backlight/actual_brightness"))
+
+	tree.ThermalZone("thermal_zone0", "x86_pkg_temp", 45000)
+	require.Equal(t, "x86_pkg_temp", tree.Read("/sys/class/thermal/thermal_zone0/type"))
+	require.Equal(t, "45000", tree.Read("/sys/class/thermal/thermal_zone0/temp"))
+
+	tree.Write("/proc/loadavg", "0.50 0.40 0.30 1/100 1234")
+	data, err = afero.ReadFile(fs, "/proc/loadavg")
+	require.NoError(t, err)
+	require.Equal(t, "0.50 0.40 0.30 1/100 1234", string(data), "strings written as-is")
+	require.Equal(t, "/proc/loadavg", tree.Path("/proc/loadavg"))
+}
+
+func TestTempDir(t *testing.T) {
+	tree, cleanup := TempDir(t)
+	tree.Backlight("acpi_video0", 5, 10)
+	path := tree.Path("/sys/class/backlight/acpi_video0/brightness")
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "5\n", string(data))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("7\n"), 0644))
+	require.Equal(t, "7", tree.Read("/sys/class/backlight/acpi_video0/brightness"))
+
+	cleanup()
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err), "removed on cleanup")
+}
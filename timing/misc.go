@@ -33,3 +33,31 @@ func nextAlignedExpiration(initial time.Time, interval time.Duration, offset tim
 	}
 	return next
 }
+
+// layoutGranularities are the candidate granularities for LayoutGranularity,
+// from finest to coarsest.
+var layoutGranularities = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	time.Minute,
+}
+
+// LayoutGranularity returns the interval at which the output of the given
+// time layout (as used by time.Format) changes, i.e. time.Second if the layout
+// includes seconds, time.Minute if it includes minutes but not seconds, and so
+// on. Layouts that do not include minutes return time.Hour, and layouts with
+// sub-millisecond precision return time.Millisecond.
+func LayoutGranularity(layout string) time.Duration {
+	// A reference time where all the units being checked are zero, so that
+	// adding a single unit always changes the formatted value if it's shown.
+	ref := time.Date(2009, time.November, 10, 10, 0, 0, 0, time.UTC)
+	formatted := ref.Format(layout)
+	for _, g := range layoutGranularities {
+		if ref.Add(g).Format(layout) != formatted {
+			return g
+		}
+	}
+	return time.Hour
+}
@@ -0,0 +1,177 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+var (
+	// Schedulers owned by each module, see OwnedBy.
+	ownedSchedulers = map[interface{}][]*Scheduler{}
+	// Modules paused using PauseOwner.
+	pausedOwners = map[interface{}]bool{}
+	// Owned schedulers that triggered while all their owners were paused,
+	// to be triggered when any of the owners is resumed.
+	pendingOwned = map[*Scheduler]bool{}
+)
+
+// OwnedBy marks the scheduler as used by the given module, so that it stops
+// triggering while all of its owners are paused using PauseOwner. Modules
+// should call this when they start streaming, since owners are released
+// when their module stops (see ReleaseOwner). Owners must be comparable,
+// e.g. pointers to modules, and other owners are ignored.
+func (s *Scheduler) OwnedBy(owner interface{}) *Scheduler {
+	if !isKey(owner) {
+		return s
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, o := range s.owners {
+		if o == owner {
+			return s
+		}
+	}
+	s.owners = append(s.owners, owner)
+	ownedSchedulers[owner] = append(ownedSchedulers[owner], s)
+	return s
+}
+
+// PauseOwner pauses all schedulers owned by the given module, e.g. while the
+// module is hidden in a collapsed group. As when the bar is paused, a trigger
+// that occurs while paused is delayed until the module is resumed.
+func PauseOwner(owner interface{}) {
+	if !isKey(owner) {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	pausedOwners[owner] = true
+}
+
+// ResumeOwner resumes all schedulers owned by the given module, triggering any
+// that would have triggered while it was paused.
+func ResumeOwner(owner interface{}) {
+	if !isKey(owner) {
+		return
+	}
+	mu.Lock()
+	delete(pausedOwners, owner)
+	ready := readyLocked()
+	mu.Unlock()
+	for _, s := range ready {
+		await(s.trigger)
+	}
+}
+
+// ReleaseOwner forgets the given module, e.g. once it stops streaming.
+// Schedulers that it owned are no longer paused along with it, and trigger
+// any pending updates unless all their remaining owners are paused.
+func ReleaseOwner(owner interface{}) {
+	if !isKey(owner) {
+		return
+	}
+	mu.Lock()
+	delete(pausedOwners, owner)
+	for _, s := range ownedSchedulers[owner] {
+		for i, o := range s.owners {
+			if o == owner {
+				s.owners = append(s.owners[:i], s.owners[i+1:]...)
+				break
+			}
+		}
+	}
+	delete(ownedSchedulers, owner)
+	ready := readyLocked()
+	mu.Unlock()
+	for _, s := range ready {
+		await(s.trigger)
+	}
+}
+
+// disown removes a closed scheduler from all its owners.
+func (s *Scheduler) disown() {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, o := range s.owners {
+		list := ownedSchedulers[o]
+		for i, owned := range list {
+			if owned == s {
+				list = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		if len(list) > 0 {
+			ownedSchedulers[o] = list
+		} else {
+			delete(ownedSchedulers, o)
+		}
+	}
+	s.owners = nil
+	delete(pendingOwned, s)
+}
+
+// readyLocked removes and returns the pending schedulers that are no longer
+// paused. Must be called with mu held.
+func readyLocked() []*Scheduler {
+	var ready []*Scheduler
+	for s := range pendingOwned {
+		if !s.ownersPausedLocked() {
+			delete(pendingOwned, s)
+			ready = append(ready, s)
+		}
+	}
+	return ready
+}
+
+// isKey returns true if the owner can be used as a map key.
+func isKey(owner interface{}) bool {
+	t := reflect.TypeOf(owner)
+	return t != nil && t.Comparable()
+}
+
+// ownersPausedLocked returns true if the scheduler has owners, and all of
+// them are paused. Must be called with mu held.
+func (s *Scheduler) ownersPausedLocked() bool {
+	for _, o := range s.owners {
+		if !pausedOwners[o] {
+			return false
+		}
+	}
+	return len(s.owners) > 0
+}
+
+// deferIfOwnersPaused records a pending trigger for the scheduler if all its
+// owners are paused, and returns whether it did.
+func (s *Scheduler) deferIfOwnersPaused() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if s.ownersPausedLocked() {
+		pendingOwned[s] = true
+		return true
+	}
+	return false
+}
+
+// cancelPending drops a trigger that is pending until an owner resumes.
+func (s *Scheduler) cancelPending() {
+	mu.Lock()
+	defer mu.Unlock()
+	if pendingOwned[s] {
+		delete(pendingOwned, s)
+		atomic.StoreInt32(&s.waiting, 0)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/testing/notifier"
+	"github.com/stretchr/testify/require"
+)
+
+type ownerModule struct {
+	scheduler *Scheduler
+	other     *Scheduler
+}
+
+func TestPauseOwner(t *testing.T) {
+	TestMode()
+	m := &ownerModule{
+		scheduler: NewScheduler().Every(time.Minute),
+		other:     NewScheduler().Every(time.Minute),
+	}
+	m.scheduler.OwnedBy(m)
+	unowned := NewScheduler().Every(time.Minute)
+
+	PauseOwner(m)
+	AdvanceBy(time.Minute)
+	notifier.AssertNoUpdate(t, m.scheduler.C, "owned scheduler while owner paused")
+	notifier.AssertNotified(t, m.other.C, "field not declared as owned")
+	notifier.AssertNotified(t, unowned.C, "unowned scheduler while owner paused")
+
+	AdvanceBy(time.Minute)
+	ResumeOwner(m)
+	notifier.AssertNotified(t, m.scheduler.C, "on resume")
+	notifier.AssertNoUpdate(t, m.scheduler.C, "triggers while paused coalesced")
+
+	ResumeOwner(m)
+	notifier.AssertNoUpdate(t, m.scheduler.C, "repeated resume is nop")
+
+	PauseOwner(m)
+	AdvanceBy(time.Minute)
+	m.scheduler.Stop()
+	ResumeOwner(m)
+	notifier.AssertNoUpdate(t, m.scheduler.C, "stopped while paused")
+	m.scheduler.After(time.Second)
+	AdvanceBy(time.Second)
+	notifier.AssertNotified(t, m.scheduler.C, "after stopping while paused")
+
+	require.NotPanics(t, func() {
+		PauseOwner(nil)
+		ResumeOwner(nil)
+		PauseOwner(func() {})
+		NewScheduler().OwnedBy([]int{})
+	}, "owners that cannot be keyed are ignored")
+}
+
+func TestSharedScheduler(t *testing.T) {
+	TestMode()
+	a, b := new(int), new(int)
+	sch := NewScheduler().Every(time.Minute).OwnedBy(a).OwnedBy(b).OwnedBy(a)
+
+	PauseOwner(a)
+	AdvanceBy(time.Minute)
+	notifier.AssertNotified(t, sch.C, "while any owner is running")
+
+	PauseOwner(b)
+	AdvanceBy(time.Minute)
+	notifier.AssertNoUpdate(t, sch.C, "when all owners are paused")
+
+	Pause()
+	ResumeOwner(b)
+	notifier.AssertNoUpdate(t, sch.C, "while bar is paused")
+	Resume()
+	notifier.AssertNotified(t, sch.C, "when bar and owner are resumed")
+}
+
+func TestReleaseOwner(t *testing.T) {
+	TestMode()
+	a, b := new(int), new(int)
+	shared := NewScheduler().Every(time.Minute).OwnedBy(a).OwnedBy(b)
+	owned := NewScheduler().Every(time.Minute).OwnedBy(a)
+
+	PauseOwner(a)
+	PauseOwner(b)
+	AdvanceBy(time.Minute)
+	notifier.AssertNoUpdate(t, shared.C, "while all owners are paused")
+	notifier.AssertNoUpdate(t, owned.C, "while owner is paused")
+
+	ReleaseOwner(a)
+	notifier.AssertNotified(t, owned.C, "pending trigger once owner is released")
+	notifier.AssertNoUpdate(t, shared.C, "while remaining owner is paused")
+	mu.Lock()
+	require.False(t, pausedOwners[a], "released owner is not paused")
+	require.Nil(t, ownedSchedulers[a], "released owner has no schedulers")
+	mu.Unlock()
+
+	AdvanceBy(time.Minute)
+	notifier.AssertNotified(t, owned.C, "no longer paused with released owner")
+
+	shared.Close()
+	mu.Lock()
+	require.Nil(t, ownedSchedulers[b], "closed schedulers are disowned")
+	mu.Unlock()
+	ResumeOwner(b)
+	notifier.AssertNoUpdate(t, shared.C, "pending trigger dropped on close")
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import "time"
+
+// RetryPolicy describes how failed updates are retried, using exponential
+// backoff between attempts.
+type RetryPolicy struct {
+	// Initial is the delay before the first retry.
+	Initial time.Duration
+	// Max is the longest delay between retries.
+	Max time.Duration
+	// Multiplier is the factor by which the delay grows after each failed
+	// retry. Defaults to 2 if not set.
+	Multiplier float64
+}
+
+// DefaultRetryPolicy retries after 5 seconds, doubling the delay after each
+// failure up to 5 minutes.
+var DefaultRetryPolicy = RetryPolicy{
+	Initial:    5 * time.Second,
+	Max:        5 * time.Minute,
+	Multiplier: 2,
+}
+
+// Delay returns the delay before the given retry attempt, starting from 0 for
+// the first retry after a failure.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	mult := p.Multiplier
+	if mult <= 0 {
+		mult = 2
+	}
+	d := float64(p.Initial)
+	for i := 0; i < attempt; i++ {
+		d *= mult
+		if p.Max > 0 && d >= float64(p.Max) {
+			return p.Max
+		}
+	}
+	if p.Max > 0 && d > float64(p.Max) {
+		return p.Max
+	}
+	return time.Duration(d)
+}
+
+// Backoff is a Scheduler that triggers retries after failures, using
+// increasing delays for consecutive failures.
+type Backoff struct {
+	*Scheduler
+	attempt int
+}
+
+// NewBackoff creates a new backoff scheduler, which does not trigger until
+// a failure is reported.
+func NewBackoff() *Backoff {
+	return &Backoff{Scheduler: NewScheduler()}
+}
+
+// Failed schedules a retry using the given policy, and returns the delay
+// until the retry.
+func (b *Backoff) Failed(p RetryPolicy) time.Duration {
+	d := p.Delay(b.attempt)
+	b.attempt++
+	b.After(d)
+	return d
+}
+
+// Succeeded cancels any pending retry and resets the delay to the initial
+// delay of the policy.
+func (b *Backoff) Succeeded() {
+	if b.attempt > 0 {
+		b.attempt = 0
+		b.Stop()
+	}
+}
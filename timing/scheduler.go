@@ -28,7 +28,8 @@ import (
 // is intrinsically tied to the running bar. This means that if the trigger
 // condition occurs while the bar is paused, it will not fire until the bar
 // is next resumed, making it ideal for scheduling work that should only be
-// performed while the bar is active.
+// performed while the bar is active. Similarly, a scheduler owned by modules
+// does not fire while all of them are paused, see PauseOwner.
 type Scheduler struct {
 	// A channel that receives an empty struct for each tick of the scheduler.
 	C <-chan struct{}
@@ -36,6 +37,13 @@ type Scheduler struct {
 	notifyFn func()
 	waiting  int32 // basically bool, but we need atomics.
 
+	// Computes the time at which a trigger was expected, to track latency.
+	expectedMu sync.Mutex
+	expected   func(now time.Time) time.Time
+
+	// Modules that use this scheduler, see OwnedBy. Guarded by mu.
+	owners []interface{}
+
 	schedulerImpl schedulerImpl
 }
 
@@ -45,6 +53,8 @@ var (
 	// requiring a reference to each created scheduler.
 	waiters []chan struct{}
 	paused  = false
+	// A set of channels to be closed by timing.Pause.
+	pauseWaiters []chan struct{}
 
 	mu sync.Mutex
 )
@@ -71,6 +81,51 @@ func Pause() {
 	mu.Lock()
 	defer mu.Unlock()
 	paused = true
+	for _, ch := range pauseWaiters {
+		close(ch)
+	}
+	pauseWaiters = nil
+}
+
+// Paused returns a channel that will be closed when timing is next paused,
+// or a closed channel if timing is currently paused. The returned function
+// stops waiting for a pause, and should be called if the channel is no longer
+// needed before the bar is paused.
+func Paused() (<-chan struct{}, func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	ch := make(chan struct{})
+	if paused {
+		close(ch)
+		return ch, func() {}
+	}
+	pauseWaiters = append(pauseWaiters, ch)
+	return ch, func() { removePauseWaiter(ch) }
+}
+
+func removePauseWaiter(ch chan struct{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, w := range pauseWaiters {
+		if w == ch {
+			pauseWaiters = append(pauseWaiters[:i], pauseWaiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Resumed returns a channel that will be closed when timing is next resumed,
+// or a closed channel if timing is not currently paused.
+func Resumed() <-chan struct{} {
+	mu.Lock()
+	defer mu.Unlock()
+	ch := make(chan struct{})
+	if paused {
+		waiters = append(waiters, ch)
+	} else {
+		close(ch)
+	}
+	return ch
 }
 
 // await executes the given function when the bar is running.
@@ -113,6 +168,7 @@ func (s *Scheduler) Tick() bool {
 // This will replace any pending triggers.
 func (s *Scheduler) At(when time.Time) *Scheduler {
 	l.Fine("%s At(%v)", l.ID(s), when)
+	s.expect(func(time.Time) time.Time { return when })
 	s.schedulerImpl.At(when, s.maybeTrigger)
 	return s
 }
@@ -121,6 +177,8 @@ func (s *Scheduler) At(when time.Time) *Scheduler {
 // This will replace any pending triggers.
 func (s *Scheduler) After(delay time.Duration) *Scheduler {
 	l.Fine("%s After(%v)", l.ID(s), delay)
+	when := Now().Add(delay)
+	s.expect(func(time.Time) time.Time { return when })
 	s.schedulerImpl.After(delay, s.maybeTrigger)
 	return s
 }
@@ -132,6 +190,10 @@ func (s *Scheduler) Every(interval time.Duration) *Scheduler {
 		panic(errors.New("non-positive interval for Scheduler#Every"))
 	}
 	l.Fine("%s Every(%v)", l.ID(s), interval)
+	start := Now()
+	s.expect(func(now time.Time) time.Time {
+		return now.Add(-(now.Sub(start) % interval))
+	})
 	s.schedulerImpl.Every(interval, s.maybeTrigger)
 	return s
 }
@@ -155,6 +217,9 @@ func (s *Scheduler) EveryAlign(interval time.Duration, offset time.Duration) *Sc
 		panic(errors.New("negative offset for Scheduler#EveryAlign"))
 	}
 	l.Fine("%s EveryAlign(%v, %v)", l.ID(s), interval, offset)
+	s.expect(func(now time.Time) time.Time {
+		return nextAlignedExpiration(now, interval, offset).Add(-interval)
+	})
 	s.schedulerImpl.EveryAlign(interval, offset, s.maybeTrigger)
 	return s
 }
@@ -163,21 +228,41 @@ func (s *Scheduler) EveryAlign(interval time.Duration, offset time.Duration) *Sc
 func (s *Scheduler) Stop() {
 	l.Fine("%s Stop", l.ID(s))
 	s.schedulerImpl.Stop()
+	s.cancelPending()
 }
 
 // Close cleans up all resources allocated by the scheduler, if necessary.
 func (s *Scheduler) Close() {
 	l.Fine("%s Close", l.ID(s))
 	s.schedulerImpl.Close()
+	s.disown()
+}
+
+func (s *Scheduler) expect(expected func(time.Time) time.Time) {
+	s.expectedMu.Lock()
+	defer s.expectedMu.Unlock()
+	s.expected = expected
 }
 
 func (s *Scheduler) maybeTrigger() {
+	now := Now()
+	s.expectedMu.Lock()
+	expected := s.expected
+	s.expectedMu.Unlock()
+	if expected != nil {
+		recordTick(now.Sub(expected(now)))
+	}
 	if !atomic.CompareAndSwapInt32(&s.waiting, 0, 1) {
 		return
 	}
-	await(func() {
-		if atomic.CompareAndSwapInt32(&s.waiting, 1, 0) {
-			s.notifyFn()
-		}
-	})
+	if s.deferIfOwnersPaused() {
+		return
+	}
+	await(s.trigger)
+}
+
+func (s *Scheduler) trigger() {
+	if atomic.CompareAndSwapInt32(&s.waiting, 1, 0) {
+		s.notifyFn()
+	}
 }
@@ -51,14 +51,30 @@ func TestPauseResume(t *testing.T) {
 	ExitTestMode()
 	sch := NewScheduler()
 
+	pausedCh, _ := Paused()
+	_, stop := Paused()
+	stop()
+	mu.Lock()
+	require.Len(t, pauseWaiters, 1, "after stopping a pause waiter")
+	mu.Unlock()
+	notifier.AssertClosed(t, Resumed(), "when not paused")
+	notifier.AssertNoUpdate(t, pausedCh, "before pause")
+
 	sch.At(Now().Add(5 * time.Millisecond))
 	Pause()
+	notifier.AssertClosed(t, pausedCh, "on pause")
+	pausedCh, stop = Paused()
+	notifier.AssertClosed(t, pausedCh, "while paused")
+	stop() // no-op after pause.
+	resumedCh := Resumed()
 	schWhilePaused := NewScheduler().After(2 * time.Millisecond)
 
 	notifier.AssertNoUpdate(t, sch.C, "when paused")
 	notifier.AssertNoUpdate(t, schWhilePaused.C, "scheduler created while paused")
 
+	notifier.AssertNoUpdate(t, resumedCh, "while paused")
 	Resume()
+	notifier.AssertClosed(t, resumedCh, "on resume")
 	notifier.AssertNotified(t, sch.C, "when resumed")
 	notifier.AssertNotified(t, schWhilePaused.C, "when resumed")
 
@@ -145,3 +161,89 @@ func TestTick(t *testing.T) {
 		now.Add(3*time.Second), <-timeChan,
 		50*time.Millisecond, "Tick waits for expected duration")
 }
+
+func TestTickStats(t *testing.T) {
+	TestMode()
+	before := GetTickStats()
+
+	sch := NewScheduler().Every(time.Minute)
+	NextTick()
+	NextTick()
+	<-sch.C
+	sch.Stop()
+	stats := GetTickStats()
+	require.Equal(t, before.Ticks+2, stats.Ticks, "each trigger is counted")
+	require.Equal(t, before.TotalLatency, stats.TotalLatency,
+		"no latency in test mode")
+
+	recordTick(-time.Second)
+	require.Equal(t, stats.TotalLatency, GetTickStats().TotalLatency,
+		"negative latency ignored")
+
+	ExitTestMode()
+	before = GetTickStats()
+	start := time.Now()
+	sch = NewScheduler().After(10 * time.Millisecond)
+	<-sch.C
+	stats = GetTickStats()
+	require.Equal(t, before.Ticks+1, stats.Ticks)
+	require.True(t, stats.TotalLatency-before.TotalLatency < time.Since(start)-10*time.Millisecond,
+		"latency does not include the delay")
+}
+
+func TestRetryPolicy(t *testing.T) {
+	p := RetryPolicy{Initial: time.Second, Max: 10 * time.Second}
+	var delays []time.Duration
+	for i := 0; i < 6; i++ {
+		delays = append(delays, p.Delay(i))
+	}
+	require.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second,
+		8 * time.Second, 10 * time.Second, 10 * time.Second,
+	}, delays)
+
+	p = RetryPolicy{Initial: time.Second, Multiplier: 1.5}
+	require.Equal(t, 2250*time.Millisecond, p.Delay(2))
+	require.Equal(t, time.Second, RetryPolicy{Initial: time.Second, Max: time.Second}.Delay(100))
+	require.Equal(t, 5*time.Second, DefaultRetryPolicy.Delay(0))
+}
+
+func TestBackoff(t *testing.T) {
+	TestMode()
+	b := NewBackoff()
+	p := RetryPolicy{Initial: time.Second, Max: time.Minute}
+	start := Now()
+
+	require.Equal(t, time.Second, b.Failed(p))
+	require.Equal(t, start.Add(time.Second), NextTick())
+	<-b.C
+	require.Equal(t, 2*time.Second, b.Failed(p))
+
+	b.Succeeded()
+	AdvanceBy(time.Hour)
+	select {
+	case <-b.C:
+		require.Fail(t, "retry triggered after success")
+	default:
+	}
+	require.Equal(t, time.Second, b.Failed(p), "delay reset after success")
+}
+
+func TestLayoutGranularity(t *testing.T) {
+	for layout, expected := range map[string]time.Duration{
+		"15:04":                time.Minute,
+		"15:04:05":             time.Second,
+		"3:4:5 PM":             time.Second,
+		"15:04:05.000":         time.Millisecond,
+		"15:04:05.999":         time.Millisecond,
+		"05.00":                10 * time.Millisecond,
+		"05.9":                 100 * time.Millisecond,
+		"Mon Jan 2 15h":        time.Hour,
+		"2006.01.02":           time.Hour,
+		"2006-01-02 15:04 MST": time.Minute,
+		time.RFC3339:           time.Second,
+		time.RFC3339Nano:       time.Millisecond,
+	} {
+		require.Equal(t, expected, LayoutGranularity(layout), layout)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"sync"
+	"time"
+)
+
+// TickStats contains statistics about the latency of scheduler triggers, that
+// is the delay between the time a scheduler should trigger and the time it
+// actually does. High latencies indicate that the process is overloaded.
+type TickStats struct {
+	// Ticks is the number of times any scheduler was triggered.
+	Ticks int
+	// TotalLatency is the sum of the latencies of all triggers.
+	TotalLatency time.Duration
+	// MaxLatency is the highest latency of any trigger.
+	MaxLatency time.Duration
+}
+
+var (
+	tickStats   TickStats
+	tickStatsMu sync.Mutex
+)
+
+func recordTick(latency time.Duration) {
+	if latency < 0 {
+		// Time adjustments can make triggers appear early.
+		latency = 0
+	}
+	tickStatsMu.Lock()
+	defer tickStatsMu.Unlock()
+	tickStats.Ticks++
+	tickStats.TotalLatency += latency
+	if latency > tickStats.MaxLatency {
+		tickStats.MaxLatency = latency
+	}
+}
+
+// GetTickStats returns the latency statistics of all schedulers.
+func GetTickStats() TickStats {
+	tickStatsMu.Lock()
+	defer tickStatsMu.Unlock()
+	return tickStats
+}
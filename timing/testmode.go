@@ -107,8 +107,12 @@ func reset(fn func()) {
 	defer triggersMu.Unlock()
 	fn()
 	waiters = nil
+	pauseWaiters = nil
 	triggers = nil
 	paused = false
+	ownedSchedulers = map[interface{}][]*Scheduler{}
+	pausedOwners = map[interface{}]bool{}
+	pendingOwned = map[*Scheduler]bool{}
 }
 
 func (s *testModeScheduler) setNextTrigger(when time.Time) {
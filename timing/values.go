@@ -0,0 +1,98 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"sync"
+	"time"
+
+	"barista.run/base/value"
+)
+
+// Debounce returns a value that follows the source value, but is only updated
+// once the source has not changed for the given duration. Useful for sources
+// that change in bursts, where only the final value is interesting. The
+// returned function stops tracking changes to the source.
+func Debounce(source *value.Value, d time.Duration) (*value.Value, func()) {
+	debounced := new(value.Value)
+	debounced.Set(source.Get())
+	sub, unsub := source.Subscribe()
+	sch := NewScheduler()
+	done, stop := stopper()
+	go func() {
+		defer unsub()
+		defer sch.Close()
+		for {
+			select {
+			case <-sub:
+				sch.After(d)
+			case <-sch.C:
+				debounced.Set(source.Get())
+			case <-done:
+				sch.Stop()
+				return
+			}
+		}
+	}()
+	return debounced, stop
+}
+
+// Throttle returns a value that follows the source value, but is updated at
+// most once per the given duration. A change is reflected immediately if
+// there were no recent changes, otherwise it is delayed until the end of the
+// interval, and any intermediate values are dropped. The returned function
+// stops tracking changes to the source.
+func Throttle(source *value.Value, d time.Duration) (*value.Value, func()) {
+	throttled := new(value.Value)
+	throttled.Set(source.Get())
+	sub, unsub := source.Subscribe()
+	sch := NewScheduler()
+	done, stop := stopper()
+	go func() {
+		defer unsub()
+		defer sch.Close()
+		var last time.Time
+		pending := false
+		for {
+			select {
+			case <-sub:
+				if pending {
+					continue
+				}
+				if wait := d - Now().Sub(last); wait > 0 {
+					sch.After(wait)
+					pending = true
+					continue
+				}
+			case <-sch.C:
+				pending = false
+			case <-done:
+				sch.Stop()
+				return
+			}
+			last = Now()
+			throttled.Set(source.Get())
+		}
+	}()
+	return throttled, stop
+}
+
+// stopper returns a channel that is closed when the returned function is
+// first called.
+func stopper() (<-chan struct{}, func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	return done, func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"testing"
+	"time"
+
+	"barista.run/base/value"
+	"barista.run/testing/notifier"
+	"github.com/stretchr/testify/require"
+)
+
+// awaitTrigger waits until a scheduler is set to trigger at the given time,
+// since the combinators schedule updates asynchronously.
+func awaitTrigger(t *testing.T, when time.Time) {
+	require.Eventually(t, func() bool {
+		triggersMu.Lock()
+		defer triggersMu.Unlock()
+		for _, tr := range triggers {
+			if tr.when.Equal(when) {
+				return true
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond, "trigger at %v", when)
+}
+
+func TestDebounce(t *testing.T) {
+	TestMode()
+	defer ExitTestMode()
+	var a value.Value
+	a.Set(0)
+	d, stop := Debounce(&a, 50*time.Millisecond)
+	defer stop()
+	sub, done := d.Subscribe()
+	defer done()
+
+	for i := 1; i <= 5; i++ {
+		a.Set(i)
+		awaitTrigger(t, Now().Add(50*time.Millisecond))
+		AdvanceBy(10 * time.Millisecond)
+	}
+	require.Equal(t, 0, d.Get(), "while source is changing")
+	notifier.AssertNoUpdate(t, sub, "while source is changing")
+
+	start := Now()
+	require.Equal(t, start.Add(40*time.Millisecond), NextTick(),
+		"triggers after the last change")
+	notifier.AssertNotified(t, sub, "once source settles")
+	require.Equal(t, 5, d.Get())
+
+	AdvanceBy(time.Minute)
+	notifier.AssertNoUpdate(t, sub, "only once per burst")
+}
+
+func TestThrottle(t *testing.T) {
+	TestMode()
+	defer ExitTestMode()
+	var a value.Value
+	a.Set(0)
+	th, stop := Throttle(&a, 100*time.Millisecond)
+	defer stop()
+	sub, done := th.Subscribe()
+	defer done()
+
+	start := Now()
+	a.Set(1)
+	notifier.AssertNotified(t, sub, "immediately on first change")
+	require.Equal(t, 1, th.Get())
+
+	AdvanceBy(40 * time.Millisecond)
+	a.Set(2)
+	awaitTrigger(t, start.Add(100*time.Millisecond))
+	a.Set(3)
+	notifier.AssertNoUpdate(t, sub, "within interval")
+	require.Equal(t, 1, th.Get(), "within interval")
+
+	require.Equal(t, start.Add(100*time.Millisecond), NextTick())
+	notifier.AssertNotified(t, sub, "at end of interval")
+	require.Equal(t, 3, th.Get(), "latest value at end of interval")
+
+	AdvanceBy(time.Minute)
+	notifier.AssertNoUpdate(t, sub, "no further changes")
+
+	a.Set(4)
+	notifier.AssertNotified(t, sub, "immediately after a quiet interval")
+	require.Equal(t, 4, th.Get())
+}
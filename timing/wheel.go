@@ -0,0 +1,156 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// wheel runs the triggers of all time-based schedulers from a single
+// goroutine, using one timer for the earliest pending trigger, so that bars
+// with many modules do not need a timer and goroutine for each scheduler.
+type wheel struct {
+	mu      sync.Mutex
+	entries entryHeap
+	wake    chan struct{}
+	started bool
+}
+
+// wheelEntry is a pending trigger of a scheduler. Repeating entries are
+// rescheduled after each trigger, either at a fixed interval from the
+// previous trigger, or aligned to the wall clock.
+type wheelEntry struct {
+	when     time.Time
+	interval time.Duration
+	offset   time.Duration
+	align    bool
+	f        func()
+	// Position in the heap, or -1 if not scheduled.
+	index int
+}
+
+// timers is the wheel used by all time-based schedulers.
+var timers = &wheel{wake: make(chan struct{}, 1)}
+
+func newWheelEntry() *wheelEntry {
+	return &wheelEntry{index: -1}
+}
+
+// schedule adds or replaces the entry with the given trigger.
+func (w *wheel) schedule(e *wheelEntry, when time.Time, interval, offset time.Duration, align bool, f func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if e.index >= 0 {
+		heap.Remove(&w.entries, e.index)
+	}
+	e.when, e.interval, e.offset, e.align, e.f = when, interval, offset, align, f
+	heap.Push(&w.entries, e)
+	if !w.started {
+		w.started = true
+		go w.run()
+	}
+	select {
+	case w.wake <- struct{}{}:
+	default:
+		// The wheel is already going to recompute the next trigger.
+	}
+}
+
+// remove cancels any pending trigger of the entry.
+func (w *wheel) remove(e *wheelEntry) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if e.index >= 0 {
+		heap.Remove(&w.entries, e.index)
+	}
+}
+
+func (w *wheel) run() {
+	for {
+		w.mu.Lock()
+		now := time.Now()
+		var due []func()
+		for len(w.entries) > 0 && !w.entries[0].when.After(now) {
+			e := w.entries[0]
+			due = append(due, e.f)
+			if e.interval == 0 {
+				heap.Pop(&w.entries)
+				continue
+			}
+			e.when = e.next(now)
+			heap.Fix(&w.entries, 0)
+		}
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if len(w.entries) > 0 {
+			timer = time.NewTimer(w.entries[0].when.Sub(now))
+			expired = timer.C
+		}
+		w.mu.Unlock()
+		// Triggers only notify schedulers, so they do not block the wheel.
+		for _, f := range due {
+			f()
+		}
+		select {
+		case <-expired:
+		case <-w.wake:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// next returns the time of the trigger after now for a repeating entry.
+// Like time.Ticker, triggers that were missed are dropped.
+func (e *wheelEntry) next(now time.Time) time.Time {
+	if e.align {
+		return nextAlignedExpiration(now, e.interval, e.offset)
+	}
+	next := e.when.Add(e.interval)
+	if !next.After(now) {
+		next = now.Add(e.interval - now.Sub(next)%e.interval)
+	}
+	return next
+}
+
+// entryHeap is a min-heap of entries ordered by trigger time.
+type entryHeap []*wheelEntry
+
+func (h entryHeap) Len() int           { return len(h) }
+func (h entryHeap) Less(i, j int) bool { return h[i].when.Before(h[j].when) }
+
+func (h entryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *entryHeap) Push(x interface{}) {
+	e := x.(*wheelEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timing
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWheel(t *testing.T) {
+	// Ensure the wheel is running before counting goroutines.
+	NewScheduler().After(time.Millisecond).Tick()
+	goroutines := runtime.NumGoroutine()
+
+	var schedulers []*Scheduler
+	for i := 0; i < 50; i++ {
+		s := NewScheduler()
+		defer s.Close()
+		schedulers = append(schedulers, s)
+		switch i % 3 {
+		case 0:
+			s.Every(10 * time.Millisecond)
+		case 1:
+			s.EveryAlign(20*time.Millisecond, 5*time.Millisecond)
+		case 2:
+			s.After(time.Duration(i) * time.Millisecond)
+		}
+	}
+	require.Equal(t, goroutines, runtime.NumGoroutine(),
+		"schedulers do not start goroutines")
+
+	for i, s := range schedulers {
+		select {
+		case <-s.C:
+		case <-time.After(time.Second):
+			require.Fail(t, "scheduler did not trigger", "scheduler #%d", i)
+		}
+	}
+	for i, s := range schedulers {
+		if i%3 == 2 {
+			continue
+		}
+		select {
+		case <-s.C:
+		case <-time.After(time.Second):
+			require.Fail(t, "repeating scheduler did not trigger again", "scheduler #%d", i)
+		}
+	}
+
+	s := schedulers[0]
+	s.Stop()
+	select {
+	case <-s.C:
+		// Drain a trigger that raced with stopping the scheduler.
+	case <-time.After(20 * time.Millisecond):
+	}
+	select {
+	case <-s.C:
+		require.Fail(t, "stopped scheduler triggered")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.After(10 * time.Millisecond)
+	s.Every(time.Hour)
+	select {
+	case <-s.C:
+		require.Fail(t, "replaced trigger fired")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWheelEntryNext(t *testing.T) {
+	start := time.Now()
+	e := &wheelEntry{when: start, interval: 10 * time.Second}
+	require.Equal(t, start.Add(10*time.Second), e.next(start.Add(time.Second)))
+	require.Equal(t, start.Add(40*time.Second), e.next(start.Add(35*time.Second)),
+		"missed triggers are dropped")
+}